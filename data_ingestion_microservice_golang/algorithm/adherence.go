@@ -0,0 +1,59 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// ScoreAdherence compares the points of a trip with a planned route, or
+// returns nil when the route has no path or the trip no points. Stops are
+// matched in route order: each is reached at the first point within the stop
+// radius after the previous stop reached, so a stop served twice on a loop
+// is not matched early. Scheduled times are offsets from the first point.
+func ScoreAdherence(points []types.TrackPoint, route types.PlannedRoute, config types.AdherenceConfig) *types.TripAdherence {
+	if len(route.Path) == 0 || len(points) == 0 {
+		return nil
+	}
+
+	onRoute := 0
+	for _, point := range points {
+		if CrossTrackDistance(point.Location, route.Path) <= config.OnRouteMeters {
+			onRoute++
+		}
+	}
+	adherence := &types.TripAdherence{OnRoutePercent: float64(onRoute) / float64(len(points)) * 100}
+
+	start := points[0].Timestamp
+	next := 0
+	for _, stop := range route.Stops {
+		result := types.StopAdherence{StopID: stop.ID}
+		for i := next; i < len(points); i++ {
+			if HaversineDistance(points[i].Location, stop.Location) <= config.StopRadiusMeters {
+				result.ArrivalTimestamp = points[i].Timestamp
+				next = i + 1
+				adherence.StopsVisited++
+				break
+			}
+		}
+
+		if stop.ScheduledOffset != nil {
+			result.ScheduledTimestamp = start + uint64(*stop.ScheduledOffset*1000)
+			if result.ArrivalTimestamp != 0 {
+				deviation := (float64(result.ArrivalTimestamp) - float64(result.ScheduledTimestamp)) / 1000
+				result.DeviationSeconds = &deviation
+				result.OnTime = deviation >= -config.EarlyTolerance.Seconds() && deviation <= config.LateTolerance.Seconds()
+			}
+		} else {
+			result.OnTime = result.ArrivalTimestamp != 0
+		}
+		if result.OnTime {
+			adherence.StopsOnTime++
+		}
+		adherence.Stops = append(adherence.Stops, result)
+	}
+
+	adherence.Score = adherence.OnRoutePercent
+	if len(route.Stops) > 0 {
+		adherence.Score = (adherence.OnRoutePercent + float64(adherence.StopsOnTime)/float64(len(route.Stops))*100) / 2
+	}
+	return adherence
+}
@@ -0,0 +1,93 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestScoreAdherence(t *testing.T) {
+	config := types.AdherenceConfig{OnRouteMeters: 50, StopRadiusMeters: 30, EarlyTolerance: time.Minute, LateTolerance: 30 * time.Second}
+	offset := func(seconds float64) *float64 { return &seconds }
+	route := types.PlannedRoute{
+		RouteID: "route_1",
+		Path:    []types.Location{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 0.010}},
+		Stops: []types.PlannedStop{
+			{ID: "first", Location: types.Location{Latitude: 0, Longitude: 0}, ScheduledOffset: offset(0)},
+			{ID: "middle", Location: types.Location{Latitude: 0, Longitude: 0.005}, ScheduledOffset: offset(60)},
+			{ID: "last", Location: types.Location{Latitude: 0, Longitude: 0.010}, ScheduledOffset: offset(60)},
+		},
+	}
+
+	// One point every 10 seconds along the path, and one about 1.1km off it
+	var points []types.TrackPoint
+	for i := 0; i <= 10; i++ {
+		points = append(points, types.TrackPoint{
+			Location:  types.Location{Latitude: 0, Longitude: float64(i) * 0.001},
+			Timestamp: 1640995200000 + uint64(i)*10000,
+		})
+		if i == 5 {
+			points = append(points, types.TrackPoint{Location: types.Location{Latitude: 0.01, Longitude: 0.0055}, Timestamp: 1640995255000})
+		}
+	}
+
+	adherence := ScoreAdherence(points, route, config)
+	if adherence == nil {
+		t.Fatal("Expected an adherence score")
+	}
+	if math.Abs(adherence.OnRoutePercent-100*11.0/12) > 0.01 {
+		t.Errorf("Expected 11 of 12 points on route, got %f%%", adherence.OnRoutePercent)
+	}
+	if adherence.StopsVisited != 3 || adherence.StopsOnTime != 2 || len(adherence.Stops) != 3 {
+		t.Fatalf("Expected 3 stops visited, 2 on time, got %+v", adherence)
+	}
+	middle := adherence.Stops[1]
+	if middle.ArrivalTimestamp != 1640995250000 || middle.ScheduledTimestamp != 1640995260000 || *middle.DeviationSeconds != -10 || !middle.OnTime {
+		t.Errorf("Expected the middle stop 10s early and on time, got %+v", middle)
+	}
+	last := adherence.Stops[2]
+	if *last.DeviationSeconds != 40 || last.OnTime {
+		t.Errorf("Expected the last stop 40s late, got %+v", last)
+	}
+	if expected := (100*11.0/12 + 100*2.0/3) / 2; math.Abs(adherence.Score-expected) > 0.01 {
+		t.Errorf("Expected a score of %f, got %f", expected, adherence.Score)
+	}
+}
+
+func TestScoreAdherence_StopsInRouteOrder(t *testing.T) {
+	config := types.AdherenceConfig{OnRouteMeters: 50, StopRadiusMeters: 30}
+	depot := types.Location{Latitude: 0, Longitude: 0}
+	route := types.PlannedRoute{
+		Path: []types.Location{depot, {Latitude: 0, Longitude: 0.002}},
+		Stops: []types.PlannedStop{
+			{ID: "depot_out", Location: depot},
+			{ID: "skipped", Location: types.Location{Latitude: 0, Longitude: 0.002}},
+			{ID: "depot_in", Location: depot},
+		},
+	}
+	points := []types.TrackPoint{
+		{Location: depot, Timestamp: 1000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.001}, Timestamp: 2000},
+		{Location: depot, Timestamp: 3000},
+	}
+
+	adherence := ScoreAdherence(points, route, config)
+	if adherence.Stops[0].ArrivalTimestamp != 1000 || adherence.Stops[1].ArrivalTimestamp != 0 || adherence.Stops[2].ArrivalTimestamp != 3000 {
+		t.Errorf("Expected the depot reached on the way out and back and the far stop missed, got %+v", adherence.Stops)
+	}
+	if adherence.StopsVisited != 2 || adherence.StopsOnTime != 2 || adherence.Stops[1].DeviationSeconds != nil {
+		t.Errorf("Expected unscheduled stops on time when reached, got %+v", adherence)
+	}
+	if expected := (100 + 100*2.0/3) / 2; math.Abs(adherence.Score-expected) > 0.01 {
+		t.Errorf("Expected a score of %f, got %f", expected, adherence.Score)
+	}
+
+	if ScoreAdherence(points, types.PlannedRoute{}, config) != nil || ScoreAdherence(nil, route, config) != nil {
+		t.Error("Expected no score without a planned path or points")
+	}
+	if adherence := ScoreAdherence(points, types.PlannedRoute{Path: route.Path}, config); adherence.Score != 100 || adherence.Stops != nil {
+		t.Errorf("Expected the on-route percentage as the score of a route without stops, got %+v", adherence)
+	}
+}
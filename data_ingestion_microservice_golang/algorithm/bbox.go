@@ -0,0 +1,70 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// Contains reports whether a location lies inside a bounding box
+func Contains(box types.BoundingBox, location types.Location) bool {
+	return location.Longitude >= box.MinLon && location.Longitude <= box.MaxLon &&
+		location.Latitude >= box.MinLat && location.Latitude <= box.MaxLat
+}
+
+// PathIntersectsBoundingBox reports whether any point or segment of a path
+// touches a bounding box. Segments are treated as straight lines in
+// longitude/latitude, which matches GeoJSON for the short segments of GPS
+// routes.
+func PathIntersectsBoundingBox(path []types.Location, box types.BoundingBox) bool {
+	for i, location := range path {
+		if Contains(box, location) {
+			return true
+		}
+		if i > 0 && segmentIntersectsBox(path[i-1], location, box) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentIntersectsBox clips a segment against a box (Liang-Barsky) and
+// reports whether any part of it remains
+func segmentIntersectsBox(start, end types.Location, box types.BoundingBox) bool {
+	dx := end.Longitude - start.Longitude
+	dy := end.Latitude - start.Latitude
+
+	t0, t1 := 0.0, 1.0
+	edges := []struct{ p, q float64 }{
+		{-dx, start.Longitude - box.MinLon},
+		{dx, box.MaxLon - start.Longitude},
+		{-dy, start.Latitude - box.MinLat},
+		{dy, box.MaxLat - start.Latitude},
+	}
+
+	for _, edge := range edges {
+		if edge.p == 0 {
+			// Parallel to this edge and outside of it
+			if edge.q < 0 {
+				return false
+			}
+			continue
+		}
+		t := edge.q / edge.p
+		if edge.p < 0 {
+			if t > t1 {
+				return false
+			}
+			if t > t0 {
+				t0 = t
+			}
+		} else {
+			if t < t0 {
+				return false
+			}
+			if t < t1 {
+				t1 = t
+			}
+		}
+	}
+
+	return t0 <= t1
+}
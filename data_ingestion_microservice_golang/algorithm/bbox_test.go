@@ -0,0 +1,46 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestPathIntersectsBoundingBox(t *testing.T) {
+	box := types.BoundingBox{MinLon: -75.59, MinLat: 6.24, MaxLon: -75.58, MaxLat: 6.25}
+
+	tests := []struct {
+		name string
+		path []types.Location
+		want bool
+	}{
+		{
+			name: "point inside",
+			path: []types.Location{{Latitude: 6.245, Longitude: -75.585}},
+			want: true,
+		},
+		{
+			name: "segment crosses without a point inside",
+			path: []types.Location{{Latitude: 6.245, Longitude: -75.60}, {Latitude: 6.245, Longitude: -75.57}},
+			want: true,
+		},
+		{
+			name: "diagonal passing beside the corner",
+			path: []types.Location{{Latitude: 6.23, Longitude: -75.575}, {Latitude: 6.245, Longitude: -75.565}},
+			want: false,
+		},
+		{
+			name: "entirely outside",
+			path: []types.Location{{Latitude: 6.30, Longitude: -75.60}, {Latitude: 6.31, Longitude: -75.57}},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PathIntersectsBoundingBox(tt.path, box); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package algorithm
+
+import (
+	"math"
+
+	"data-ingestion-microservice/types"
+)
+
+// EarthRadiusMeters is the mean Earth radius used for distance calculations
+const EarthRadiusMeters = 6371008.8
+
+// HaversineDistance returns the great-circle distance in meters between two locations
+func HaversineDistance(a, b types.Location) float64 {
+	lat1 := toRadians(a.Latitude)
+	lat2 := toRadians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// CrossTrackDistance returns the shortest distance in meters from a location
+// to a path made of consecutive segments
+func CrossTrackDistance(location types.Location, path []types.Location) float64 {
+	if len(path) == 0 {
+		return math.Inf(1)
+	}
+	if len(path) == 1 {
+		return HaversineDistance(location, path[0])
+	}
+
+	minDistance := math.Inf(1)
+	for i := 0; i < len(path)-1; i++ {
+		distance := segmentDistance(location, path[i], path[i+1])
+		if distance < minDistance {
+			minDistance = distance
+		}
+	}
+
+	return minDistance
+}
+
+// PathProgress returns how far along a path, in meters, lies the point of
+// the path nearest to a location. A location past either end of the path
+// is at its start or end.
+func PathProgress(location types.Location, path []types.Location) float64 {
+	minDistance := math.Inf(1)
+	progress, traveled := 0.0, 0.0
+	for i := 0; i < len(path)-1; i++ {
+		length := HaversineDistance(path[i], path[i+1])
+		distance, t := segmentProjection(location, path[i], path[i+1])
+		if distance < minDistance {
+			minDistance = distance
+			progress = traveled + t*length
+		}
+		traveled += length
+	}
+	return progress
+}
+
+// segmentDistance returns the distance in meters from a location to a segment
+func segmentDistance(location, start, end types.Location) float64 {
+	distance, _ := segmentProjection(location, start, end)
+	return distance
+}
+
+// segmentProjection returns the distance in meters from a location to a
+// segment, and the fraction of the segment (0 to 1) at which the nearest
+// point lies. Segments are projected onto a local equirectangular plane
+// centered on the location, which is accurate for the short segments found
+// in GPS routes.
+func segmentProjection(location, start, end types.Location) (float64, float64) {
+	cosLat := math.Cos(toRadians(location.Latitude))
+	project := func(l types.Location) Point {
+		return Point{
+			X: toRadians(l.Longitude-location.Longitude) * cosLat * EarthRadiusMeters,
+			Y: toRadians(l.Latitude-location.Latitude) * EarthRadiusMeters,
+		}
+	}
+
+	a := project(start)
+	b := project(end)
+
+	dx := b.X - a.X
+	dy := b.Y - a.Y
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return math.Hypot(a.X, a.Y), 0
+	}
+
+	// Clamp the projection of the origin onto the segment
+	t := -(a.X*dx + a.Y*dy) / lengthSquared
+	t = math.Max(0, math.Min(1, t))
+
+	return math.Hypot(a.X+t*dx, a.Y+t*dy), t
+}
+
+// toRadians converts degrees to radians
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// InitialBearing returns the compass bearing in degrees (0-360, clockwise
+// from north) to travel from one location towards another
+func InitialBearing(from, to types.Location) float64 {
+	lat1 := toRadians(from.Latitude)
+	lat2 := toRadians(to.Latitude)
+	dLon := toRadians(to.Longitude - from.Longitude)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	bearing := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(bearing+360, 360)
+}
@@ -0,0 +1,118 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestHaversineDistance(t *testing.T) {
+	a := types.Location{Latitude: 0.0, Longitude: 0.0}
+	b := types.Location{Latitude: 0.0, Longitude: 1.0}
+
+	distance := HaversineDistance(a, b)
+
+	// One degree of longitude at the equator is roughly 111.2 km
+	if math.Abs(distance-111195) > 10 {
+		t.Errorf("Expected distance close to 111195m, got %f", distance)
+	}
+}
+
+func TestHaversineDistance_SamePoint(t *testing.T) {
+	a := types.Location{Latitude: 6.2442, Longitude: -75.5812}
+
+	if distance := HaversineDistance(a, a); distance != 0 {
+		t.Errorf("Expected distance 0, got %f", distance)
+	}
+}
+
+func TestCrossTrackDistance(t *testing.T) {
+	path := []types.Location{
+		{Latitude: 0.0, Longitude: 0.0},
+		{Latitude: 0.0, Longitude: 0.01},
+	}
+
+	// A point 0.001 degrees north of the segment is about 111 meters away
+	point := types.Location{Latitude: 0.001, Longitude: 0.005}
+	distance := CrossTrackDistance(point, path)
+	if math.Abs(distance-111.2) > 1 {
+		t.Errorf("Expected distance close to 111.2m, got %f", distance)
+	}
+
+	// A point on the segment should be on-route
+	onRoute := types.Location{Latitude: 0.0, Longitude: 0.005}
+	if distance := CrossTrackDistance(onRoute, path); distance > 0.001 {
+		t.Errorf("Expected distance close to 0, got %f", distance)
+	}
+}
+
+func TestCrossTrackDistance_BeyondSegmentEnd(t *testing.T) {
+	path := []types.Location{
+		{Latitude: 0.0, Longitude: 0.0},
+		{Latitude: 0.0, Longitude: 0.01},
+	}
+
+	// Points past the end of the path are measured to the closest endpoint
+	point := types.Location{Latitude: 0.0, Longitude: 0.011}
+	expected := HaversineDistance(point, path[1])
+	distance := CrossTrackDistance(point, path)
+	if math.Abs(distance-expected) > 0.5 {
+		t.Errorf("Expected distance close to %f, got %f", expected, distance)
+	}
+}
+
+func TestCrossTrackDistance_EmptyPath(t *testing.T) {
+	point := types.Location{Latitude: 0.0, Longitude: 0.0}
+
+	if distance := CrossTrackDistance(point, nil); !math.IsInf(distance, 1) {
+		t.Errorf("Expected infinite distance for empty path, got %f", distance)
+	}
+}
+
+func TestInitialBearing(t *testing.T) {
+	origin := types.Location{Latitude: 0.0, Longitude: 0.0}
+
+	cases := []struct {
+		to       types.Location
+		expected float64
+	}{
+		{types.Location{Latitude: 1.0, Longitude: 0.0}, 0},
+		{types.Location{Latitude: 0.0, Longitude: 1.0}, 90},
+		{types.Location{Latitude: -1.0, Longitude: 0.0}, 180},
+		{types.Location{Latitude: 0.0, Longitude: -1.0}, 270},
+	}
+
+	for _, c := range cases {
+		if bearing := InitialBearing(origin, c.to); math.Abs(bearing-c.expected) > 0.001 {
+			t.Errorf("Expected bearing %f towards %+v, got %f", c.expected, c.to, bearing)
+		}
+	}
+}
+
+func TestPathProgress(t *testing.T) {
+	path := []types.Location{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 0.01},
+		{Latitude: 0.01, Longitude: 0.01},
+	}
+	first := HaversineDistance(path[0], path[1])
+
+	tests := []struct {
+		name     string
+		location types.Location
+		want     float64
+	}{
+		{"before the start", types.Location{Latitude: 0, Longitude: -0.01}, 0},
+		{"halfway along the first segment, off the path", types.Location{Latitude: 0.0001, Longitude: 0.005}, first / 2},
+		{"on the second segment", types.Location{Latitude: 0.005, Longitude: 0.01}, first + HaversineDistance(path[1], types.Location{Latitude: 0.005, Longitude: 0.01})},
+		{"past the end", types.Location{Latitude: 0.02, Longitude: 0.01}, first + HaversineDistance(path[1], path[2])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PathProgress(tt.location, path); math.Abs(got-tt.want) > 1 {
+				t.Errorf("Expected progress %.1fm, got %.1fm", tt.want, got)
+			}
+		})
+	}
+}
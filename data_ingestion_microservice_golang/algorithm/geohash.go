@@ -0,0 +1,97 @@
+package algorithm
+
+import (
+	"strings"
+
+	"data-ingestion-microservice/types"
+)
+
+// geohashAlphabet is the base32 alphabet of geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxGeohashPrecision is the longest geohash computed, about 4 cm wide
+const MaxGeohashPrecision = 12
+
+// EncodeGeohash returns the geohash cell of a location with precision
+// characters, capped at MaxGeohashPrecision
+func EncodeGeohash(location types.Location, precision int) string {
+	precision = min(max(precision, 1), MaxGeohashPrecision)
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	var hash strings.Builder
+	hash.Grow(precision)
+	even := true
+	bits, index := 0, 0
+	for hash.Len() < precision {
+		// Bits alternate between longitude and latitude, longitude first
+		if even {
+			mid := (minLon + maxLon) / 2
+			index <<= 1
+			if location.Longitude >= mid {
+				index |= 1
+				minLon = mid
+			} else {
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			index <<= 1
+			if location.Latitude >= mid {
+				index |= 1
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		if bits++; bits == 5 {
+			hash.WriteByte(geohashAlphabet[index])
+			bits, index = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// GeohashBounds returns the bounding box of a geohash cell, or false if the
+// hash has characters outside the geohash alphabet
+func GeohashBounds(hash string) (types.BoundingBox, bool) {
+	box := types.BoundingBox{MinLon: -180, MinLat: -90, MaxLon: 180, MaxLat: 90}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		index := strings.IndexByte(geohashAlphabet, hash[i])
+		if index < 0 {
+			return types.BoundingBox{}, false
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := index>>bit&1 == 1
+			if even {
+				mid := (box.MinLon + box.MaxLon) / 2
+				if set {
+					box.MinLon = mid
+				} else {
+					box.MaxLon = mid
+				}
+			} else {
+				mid := (box.MinLat + box.MaxLat) / 2
+				if set {
+					box.MinLat = mid
+				} else {
+					box.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return box, true
+}
+
+// GeohashCenter returns the center of a geohash cell
+func GeohashCenter(hash string) (types.Location, bool) {
+	box, ok := GeohashBounds(hash)
+	if !ok {
+		return types.Location{}, false
+	}
+	return types.Location{Latitude: (box.MinLat + box.MaxLat) / 2, Longitude: (box.MinLon + box.MaxLon) / 2}, true
+}
@@ -0,0 +1,42 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestEncodeGeohash(t *testing.T) {
+	tests := []struct {
+		location  types.Location
+		precision int
+		want      string
+	}{
+		{types.Location{Latitude: 57.64911, Longitude: 10.40744}, 11, "u4pruydqqvj"},
+		{types.Location{Latitude: 6.2442, Longitude: -75.5812}, 7, "d34780e"},
+		{types.Location{Latitude: 0, Longitude: 0}, 1, "s"},
+	}
+	for _, test := range tests {
+		if got := EncodeGeohash(test.location, test.precision); got != test.want {
+			t.Errorf("EncodeGeohash(%+v, %d) = %q, want %q", test.location, test.precision, got, test.want)
+		}
+	}
+}
+
+func TestGeohashBounds(t *testing.T) {
+	location := types.Location{Latitude: 6.2442, Longitude: -75.5812}
+	box, ok := GeohashBounds(EncodeGeohash(location, 7))
+	if !ok {
+		t.Fatal("Expected a valid geohash")
+	}
+	if !Contains(box, location) {
+		t.Errorf("Expected %+v to contain %+v", box, location)
+	}
+	if width := box.MaxLon - box.MinLon; width > 0.0014 {
+		t.Errorf("Expected a cell about 150 m wide, got %v degrees", width)
+	}
+
+	if _, ok := GeohashBounds("d34a"); ok {
+		t.Error("Expected 'a' to be rejected")
+	}
+}
@@ -0,0 +1,65 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// ToGeoJSON converts a route into a GeoJSON LineString. Consecutive duplicate
+// points are dropped because 2dsphere indexes reject degenerate segments, and
+// routes with a single distinct point are stored as a GeoJSON Point instead.
+func ToGeoJSON(locations []types.Location) *types.GeoJSONGeometry {
+	if len(locations) == 0 {
+		return nil
+	}
+
+	coordinates := make([][]float64, 0, len(locations))
+	for i, location := range locations {
+		if i > 0 && location == locations[i-1] {
+			continue
+		}
+		// GeoJSON orders coordinates as [longitude, latitude]
+		coordinates = append(coordinates, []float64{location.Longitude, location.Latitude})
+	}
+
+	if len(coordinates) == 1 {
+		return &types.GeoJSONGeometry{
+			Type:        "Point",
+			Coordinates: coordinates[0],
+		}
+	}
+
+	return &types.GeoJSONGeometry{
+		Type:        "LineString",
+		Coordinates: coordinates,
+	}
+}
+
+// BoundingBoxGeoJSON returns the bounding box of a route as a GeoJSON-style
+// envelope ([[minLon, maxLat], [maxLon, minLat]]), or a Point when the route
+// never moved
+func BoundingBoxGeoJSON(locations []types.Location) *types.GeoJSONGeometry {
+	if len(locations) == 0 {
+		return nil
+	}
+
+	minLat, maxLat := locations[0].Latitude, locations[0].Latitude
+	minLon, maxLon := locations[0].Longitude, locations[0].Longitude
+	for _, location := range locations[1:] {
+		minLat = min(minLat, location.Latitude)
+		maxLat = max(maxLat, location.Latitude)
+		minLon = min(minLon, location.Longitude)
+		maxLon = max(maxLon, location.Longitude)
+	}
+
+	if minLat == maxLat && minLon == maxLon {
+		return &types.GeoJSONGeometry{
+			Type:        "Point",
+			Coordinates: []float64{minLon, minLat},
+		}
+	}
+
+	return &types.GeoJSONGeometry{
+		Type:        "envelope",
+		Coordinates: [][]float64{{minLon, maxLat}, {maxLon, minLat}},
+	}
+}
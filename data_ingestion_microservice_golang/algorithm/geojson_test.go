@@ -0,0 +1,70 @@
+package algorithm
+
+import (
+	"reflect"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestToGeoJSON_LineString(t *testing.T) {
+	locations := []types.Location{
+		{Latitude: 40.7128, Longitude: -74.0060},
+		{Latitude: 40.7128, Longitude: -74.0060},
+		{Latitude: 40.7580, Longitude: -73.9855},
+	}
+
+	geometry := ToGeoJSON(locations)
+
+	if geometry.Type != "LineString" {
+		t.Fatalf("Expected LineString, got %s", geometry.Type)
+	}
+
+	expected := [][]float64{{-74.0060, 40.7128}, {-73.9855, 40.7580}}
+	if !reflect.DeepEqual(geometry.Coordinates, expected) {
+		t.Errorf("Expected coordinates %v, got %v", expected, geometry.Coordinates)
+	}
+}
+
+func TestToGeoJSON_SinglePoint(t *testing.T) {
+	locations := []types.Location{
+		{Latitude: 40.7128, Longitude: -74.0060},
+		{Latitude: 40.7128, Longitude: -74.0060},
+	}
+
+	geometry := ToGeoJSON(locations)
+
+	if geometry.Type != "Point" {
+		t.Fatalf("Expected Point, got %s", geometry.Type)
+	}
+
+	expected := []float64{-74.0060, 40.7128}
+	if !reflect.DeepEqual(geometry.Coordinates, expected) {
+		t.Errorf("Expected coordinates %v, got %v", expected, geometry.Coordinates)
+	}
+}
+
+func TestToGeoJSON_Empty(t *testing.T) {
+	if geometry := ToGeoJSON(nil); geometry != nil {
+		t.Errorf("Expected nil geometry, got %+v", geometry)
+	}
+}
+
+func TestBoundingBoxGeoJSON(t *testing.T) {
+	locations := []types.Location{
+		{Latitude: 40.7128, Longitude: -74.0060},
+		{Latitude: 40.7580, Longitude: -73.9855},
+		{Latitude: 40.7300, Longitude: -74.0100},
+	}
+
+	box := BoundingBoxGeoJSON(locations)
+
+	if box.Type != "envelope" {
+		t.Fatalf("Expected envelope, got %s", box.Type)
+	}
+
+	expected := [][]float64{{-74.0100, 40.7580}, {-73.9855, 40.7128}}
+	if !reflect.DeepEqual(box.Coordinates, expected) {
+		t.Errorf("Expected coordinates %v, got %v", expected, box.Coordinates)
+	}
+}
@@ -0,0 +1,212 @@
+package algorithm
+
+import (
+	"math"
+
+	"data-ingestion-microservice/types"
+)
+
+// Harsh driving event types
+const (
+	HarshBraking      = "harsh_braking"
+	HarshAcceleration = "harsh_acceleration"
+	HarshCornering    = "harsh_cornering"
+)
+
+// Sources of harsh driving events
+const (
+	harshSourceAccelerometer = "accelerometer"
+	harshSourceGPS           = "gps"
+)
+
+// minCorneringSpeedKmh is the speed below which heading changes are GPS
+// noise rather than cornering
+const minCorneringSpeedKmh = 10
+
+// safetyHalvingRate is the rate of harsh events per 100 km that halves a
+// safety score
+const safetyHalvingRate = 10
+
+// motionSample is the speed (m/s) and heading of a vehicle at a moment in
+// seconds. Samples derived from two points are placed halfway between them.
+type motionSample struct {
+	seconds    float64
+	speed      float64
+	heading    float64
+	hasHeading bool
+}
+
+// harshReading is the acceleration of a vehicle at a point, along and
+// across its direction of travel, when known
+type harshReading struct {
+	point              types.TrackPoint
+	speedKmh           float64
+	longitudinal       *float64
+	lateral            *float64
+	longitudinalSource string
+	lateralSource      string
+}
+
+// DetectHarshDriving returns the harsh braking, acceleration, and cornering
+// of a trip's raw points, in the order they happened. When any point carries
+// an accelerometer reading for an axis, that axis is checked on the readings
+// alone; otherwise accelerations are derived from consecutive speed samples,
+// the device's speed and heading when sent or those of the segment to the
+// previous point. Consecutive points over a threshold make a single event
+// with the peak acceleration. Points without a timestamp or going back in
+// time are skipped.
+func DetectHarshDriving(points []types.TrackPoint, config types.HarshDrivingConfig) []types.HarshEvent {
+	longitudinalSensor, lateralSensor := false, false
+	for _, point := range points {
+		longitudinalSensor = longitudinalSensor || point.AccelerationMps2 != nil
+		lateralSensor = lateralSensor || point.LateralAccelerationMps2 != nil
+	}
+	maxGap := config.MaxSampleGap.Seconds()
+
+	var events []types.HarshEvent
+	open := make(map[string]int)
+	var prevPoint *types.TrackPoint
+	var prevSample *motionSample
+	for _, point := range points {
+		if point.Timestamp == 0 || (prevPoint != nil && point.Timestamp <= prevPoint.Timestamp) {
+			continue
+		}
+
+		sample := sampleMotion(prevPoint, point, maxGap)
+		reading := harshReading{point: point, longitudinalSource: harshSourceGPS, lateralSource: harshSourceGPS}
+		if sample != nil {
+			reading.speedKmh = sample.speed * 3.6
+		}
+		if sample != nil && prevSample != nil && sample.seconds-prevSample.seconds <= maxGap {
+			elapsed := sample.seconds - prevSample.seconds
+			longitudinal := (sample.speed - prevSample.speed) / elapsed
+			reading.longitudinal = &longitudinal
+			speed := (sample.speed + prevSample.speed) / 2
+			if sample.hasHeading && prevSample.hasHeading && speed*3.6 >= minCorneringSpeedKmh {
+				lateral := speed * toRadians(headingChange(prevSample.heading, sample.heading)) / elapsed
+				reading.lateral = &lateral
+			}
+		}
+		if longitudinalSensor {
+			reading.longitudinal, reading.longitudinalSource = point.AccelerationMps2, harshSourceAccelerometer
+		}
+		if lateralSensor {
+			reading.lateral, reading.lateralSource = point.LateralAccelerationMps2, harshSourceAccelerometer
+		}
+		if point.SpeedKmh != nil {
+			reading.speedKmh = *point.SpeedKmh
+		}
+
+		detected := make(map[string]bool)
+		if a := reading.longitudinal; a != nil && *a <= -config.BrakingMps2 {
+			events = extendHarshEvent(events, open, HarshBraking, -*a, reading, reading.longitudinalSource)
+			detected[HarshBraking] = true
+		}
+		if a := reading.longitudinal; a != nil && *a >= config.AccelerationMps2 {
+			events = extendHarshEvent(events, open, HarshAcceleration, *a, reading, reading.longitudinalSource)
+			detected[HarshAcceleration] = true
+		}
+		if a := reading.lateral; a != nil && math.Abs(*a) >= config.CorneringMps2 {
+			events = extendHarshEvent(events, open, HarshCornering, math.Abs(*a), reading, reading.lateralSource)
+			detected[HarshCornering] = true
+		}
+		for eventType := range open {
+			if !detected[eventType] {
+				delete(open, eventType)
+			}
+		}
+
+		prevPoint = &point
+		if sample != nil {
+			prevSample = sample
+		}
+	}
+	return events
+}
+
+// sampleMotion returns the speed and heading of a vehicle at a point: the
+// device's speed when sent, or else the speed of the segment from the
+// previous point, when the previous point is at most maxGap seconds earlier.
+// Without a device heading, the heading is the segment's bearing.
+func sampleMotion(prev *types.TrackPoint, point types.TrackPoint, maxGap float64) *motionSample {
+	seconds := float64(point.Timestamp) / 1000
+	var segment *motionSample
+	if prev != nil && seconds-float64(prev.Timestamp)/1000 <= maxGap {
+		elapsed := seconds - float64(prev.Timestamp)/1000
+		segment = &motionSample{seconds: seconds - elapsed/2, speed: HaversineDistance(prev.Location, point.Location) / elapsed}
+		if segment.speed > 0 {
+			segment.heading, segment.hasHeading = InitialBearing(prev.Location, point.Location), true
+		}
+	}
+
+	if point.SpeedKmh == nil {
+		return segment
+	}
+	sample := &motionSample{seconds: seconds, speed: *point.SpeedKmh / 3.6}
+	if point.Heading != nil {
+		sample.heading, sample.hasHeading = *point.Heading, true
+	} else if segment != nil {
+		sample.heading, sample.hasHeading = segment.heading, segment.hasHeading
+	}
+	return sample
+}
+
+// headingChange returns the signed change in degrees from one heading to
+// another, the short way around
+func headingChange(from, to float64) float64 {
+	return math.Mod(to-from+540, 360) - 180
+}
+
+// extendHarshEvent records an acceleration over a threshold, extending the
+// open event of its type or starting a new one
+func extendHarshEvent(events []types.HarshEvent, open map[string]int, eventType string, magnitude float64, reading harshReading, source string) []types.HarshEvent {
+	if i, ok := open[eventType]; ok {
+		if magnitude > events[i].AccelerationMps2 {
+			events[i].AccelerationMps2 = magnitude
+			events[i].Location = reading.point.Location
+			events[i].Timestamp = reading.point.Timestamp
+			events[i].SpeedKmh = reading.speedKmh
+		}
+		return events
+	}
+	open[eventType] = len(events)
+	return append(events, types.HarshEvent{
+		Type:             eventType,
+		Location:         reading.point.Location,
+		Timestamp:        reading.point.Timestamp,
+		AccelerationMps2: magnitude,
+		SpeedKmh:         reading.speedKmh,
+		Source:           source,
+	})
+}
+
+// ScoreSafety summarizes the harsh events of a trip of distanceMeters
+func ScoreSafety(events []types.HarshEvent, distanceMeters float64) types.TripSafety {
+	safety := types.TripSafety{Events: events}
+	for _, event := range events {
+		switch event.Type {
+		case HarshBraking:
+			safety.HarshBraking++
+		case HarshAcceleration:
+			safety.HarshAcceleration++
+		case HarshCornering:
+			safety.HarshCornering++
+		}
+	}
+	safety.EventsPer100Km = EventsPer100Km(len(events), distanceMeters)
+	safety.Score = SafetyScore(safety.EventsPer100Km)
+	return safety
+}
+
+// EventsPer100Km returns the rate of events over a distance, counting
+// distances under 1 km as 1 km so a single event on a short trip does not
+// dominate
+func EventsPer100Km(events int, distanceMeters float64) float64 {
+	return float64(events) / max(distanceMeters/1000, 1) * 100
+}
+
+// SafetyScore returns a score from 0 to 100 for a rate of harsh events:
+// 100 without events, halving with every safetyHalvingRate events per 100 km
+func SafetyScore(eventsPer100Km float64) float64 {
+	return 100 * math.Pow(0.5, eventsPer100Km/safetyHalvingRate)
+}
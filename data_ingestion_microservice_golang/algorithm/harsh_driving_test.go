@@ -0,0 +1,116 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// metersPerDegree is the length of a degree along the equator
+const metersPerDegree = 2 * math.Pi * 6371000 / 360
+
+var harshTestConfig = types.HarshDrivingConfig{BrakingMps2: 4, AccelerationMps2: 3, CorneringMps2: 4, MaxSampleGap: 5 * time.Second}
+
+func TestDetectHarshDriving_FromDeviceSpeeds(t *testing.T) {
+	speeds := []float64{50, 50, 50, 26, 10, 10, 10, 25, 25}
+	var points []types.TrackPoint
+	for i, speed := range speeds {
+		points = append(points, types.TrackPoint{
+			Location:  types.Location{Latitude: 0, Longitude: float64(i) * 10 / metersPerDegree},
+			Timestamp: 1640995200000 + uint64(i)*1000,
+			Telemetry: types.Telemetry{SpeedKmh: &speed},
+		})
+	}
+
+	events := DetectHarshDriving(points, harshTestConfig)
+	if len(events) != 2 {
+		t.Fatalf("Expected one braking and one acceleration event, got %+v", events)
+	}
+	braking := events[0]
+	if braking.Type != HarshBraking || braking.Timestamp != 1640995203000 || math.Abs(braking.AccelerationMps2-24/3.6) > 0.01 || braking.SpeedKmh != 26 || braking.Source != "gps" {
+		t.Errorf("Expected two seconds of braking merged into one event peaking at 6.7 m/s², got %+v", braking)
+	}
+	if acceleration := events[1]; acceleration.Type != HarshAcceleration || acceleration.Timestamp != 1640995207000 || math.Abs(acceleration.AccelerationMps2-15/3.6) > 0.01 {
+		t.Errorf("Expected an acceleration at 4.2 m/s², got %+v", acceleration)
+	}
+
+	// Samples further apart than the maximum gap are not compared
+	sparse := []types.TrackPoint{points[2], points[3]}
+	sparse[1].Timestamp += 10000
+	if events := DetectHarshDriving(sparse, harshTestConfig); len(events) != 0 {
+		t.Errorf("Expected no events across a gap, got %+v", events)
+	}
+}
+
+func TestDetectHarshDriving_CorneringFromGPS(t *testing.T) {
+	step := 10 / metersPerDegree
+	locations := []types.Location{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: step},
+		{Latitude: 0, Longitude: 2 * step},
+		{Latitude: step, Longitude: 2 * step},
+		{Latitude: 2 * step, Longitude: 2 * step},
+	}
+	var points []types.TrackPoint
+	for i, location := range locations {
+		points = append(points, types.TrackPoint{Location: location, Timestamp: 1640995200000 + uint64(i)*1000})
+	}
+
+	events := DetectHarshDriving(points, harshTestConfig)
+	if len(events) != 1 {
+		t.Fatalf("Expected one cornering event, got %+v", events)
+	}
+	// A quarter turn in a second at 10 m/s
+	if cornering := events[0]; cornering.Type != HarshCornering || math.Abs(cornering.AccelerationMps2-10*math.Pi/2) > 0.1 || math.Abs(cornering.SpeedKmh-36) > 0.1 {
+		t.Errorf("Expected cornering at 15.7 m/s² at 36 km/h, got %+v", cornering)
+	}
+
+	// Turning at walking pace is GPS noise
+	for i := range points {
+		points[i].Timestamp = 1640995200000 + uint64(i)*5000
+	}
+	if events := DetectHarshDriving(points, harshTestConfig); len(events) != 0 {
+		t.Errorf("Expected no events below the cornering speed, got %+v", events)
+	}
+}
+
+func TestDetectHarshDriving_PrefersAccelerometer(t *testing.T) {
+	readings := []float64{-2, -6, -7, -1, -5}
+	var points []types.TrackPoint
+	for i := range readings {
+		points = append(points, types.TrackPoint{
+			// Positions alone would make a hard acceleration
+			Location:  types.Location{Latitude: 0, Longitude: float64(i*i) * 10 / metersPerDegree},
+			Timestamp: 1640995200000 + uint64(i)*1000,
+			Telemetry: types.Telemetry{AccelerationMps2: &readings[i]},
+		})
+	}
+
+	events := DetectHarshDriving(points, harshTestConfig)
+	if len(events) != 2 || events[0].Type != HarshBraking || events[0].AccelerationMps2 != 7 || events[0].Timestamp != 1640995202000 || events[0].Source != "accelerometer" {
+		t.Fatalf("Expected braking peaking at 7 m/s² from the accelerometer, got %+v", events)
+	}
+	if events[1].Type != HarshBraking || events[1].AccelerationMps2 != 5 {
+		t.Errorf("Expected a second braking after the readings eased, got %+v", events[1])
+	}
+}
+
+func TestScoreSafety(t *testing.T) {
+	events := []types.HarshEvent{{Type: HarshBraking}, {Type: HarshCornering}}
+
+	safety := ScoreSafety(events, 10000)
+	if safety.HarshBraking != 1 || safety.HarshCornering != 1 || safety.HarshAcceleration != 0 || safety.EventCount() != 2 {
+		t.Errorf("Unexpected counts %+v", safety)
+	}
+	if safety.EventsPer100Km != 20 || safety.Score != 25 {
+		t.Errorf("Expected 20 events per 100 km scoring 25, got %+v", safety)
+	}
+	if safety := ScoreSafety(nil, 10000); safety.Score != 100 {
+		t.Errorf("Expected a perfect score without events, got %v", safety.Score)
+	}
+	if short := ScoreSafety(events[:1], 500); short.EventsPer100Km != 100 {
+		t.Errorf("Expected trips under 1 km counted as 1 km, got %v", short.EventsPer100Km)
+	}
+}
@@ -0,0 +1,27 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// DetectIdling returns the idle periods of a trip: the stops of its points
+// below idleSpeedKmh lasting at least minSeconds, except those starting where
+// atTerminal reports the vehicle is expected to wait. A nil atTerminal has
+// no terminals.
+func DetectIdling(points []types.TrackPoint, idleSpeedKmh, minSeconds float64, atTerminal func(types.Location) bool) types.TripIdle {
+	idle := types.TripIdle{Periods: []types.IdlePeriod{}}
+	stops := DetectStops(points, types.TripStatsConfig{IdleSpeedKmh: idleSpeedKmh, MinStopSeconds: minSeconds})
+	for _, stop := range stops {
+		if atTerminal != nil && atTerminal(stop.Location) {
+			continue
+		}
+		idle.Periods = append(idle.Periods, types.IdlePeriod{
+			Location:        stop.Location,
+			StartTimestamp:  stop.StartTimestamp,
+			EndTimestamp:    stop.EndTimestamp,
+			DurationSeconds: stop.DurationSeconds,
+		})
+		idle.IdleSeconds += stop.DurationSeconds
+	}
+	return idle
+}
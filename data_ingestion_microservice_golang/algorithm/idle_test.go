@@ -0,0 +1,38 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestDetectIdling(t *testing.T) {
+	terminal := types.Location{Latitude: 0, Longitude: 0}
+	points := []types.TrackPoint{
+		// Waiting 10 minutes at the terminal
+		{Location: terminal, Timestamp: 1640995200000},
+		{Location: terminal, Timestamp: 1640995800000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.005}, Timestamp: 1640995860000},
+		// Idling 4 minutes on the way, then 1 minute at a light
+		{Location: types.Location{Latitude: 0, Longitude: 0.005}, Timestamp: 1640996100000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.01}, Timestamp: 1640996160000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.01}, Timestamp: 1640996220000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.015}, Timestamp: 1640996280000},
+	}
+	atTerminal := func(location types.Location) bool { return HaversineDistance(location, terminal) <= 100 }
+
+	idle := DetectIdling(points, 2, 180, atTerminal)
+	if len(idle.Periods) != 1 || idle.IdleSeconds != 240 {
+		t.Fatalf("Expected one 4 minute idle period, got %+v", idle)
+	}
+	if period := idle.Periods[0]; period.StartTimestamp != 1640995860000 || period.EndTimestamp != 1640996100000 || period.Location.Longitude != 0.005 {
+		t.Errorf("Unexpected idle period %+v", period)
+	}
+
+	if idle := DetectIdling(points, 2, 180, nil); len(idle.Periods) != 2 || idle.IdleSeconds != 840 {
+		t.Errorf("Expected the terminal wait to count without terminals, got %+v", idle)
+	}
+	if idle := DetectIdling(points[:1], 2, 180, nil); idle.Periods == nil || idle.IdleSeconds != 0 {
+		t.Errorf("Expected an empty idle summary, got %+v", idle)
+	}
+}
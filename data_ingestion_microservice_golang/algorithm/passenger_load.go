@@ -0,0 +1,65 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// LoadProfile returns the passenger load profile of a trip from the counts
+// among its raw points, or nil when none carries a count. A count is
+// attributed to the stop its device reported, or else to the nearest stop
+// within radiusMeters. Consecutive counts at the same stop are merged, so
+// every door of a vehicle may report separately. Counters miss passengers,
+// so the load never drops below zero.
+func LoadProfile(points []types.TrackPoint, stops []types.PlannedStop, radiusMeters float64) *types.LoadProfile {
+	var profile *types.LoadProfile
+	load := 0
+	for _, point := range points {
+		count := point.Passengers
+		if count == nil {
+			continue
+		}
+		if profile == nil {
+			profile = &types.LoadProfile{Stops: []types.StopLoad{}}
+		}
+
+		stop := types.StopLoad{StopID: count.StopID, Location: point.Location, Timestamp: point.Timestamp}
+		if planned := countedStop(point.Location, count.StopID, stops, radiusMeters); planned != nil {
+			stop.StopID, stop.Location = planned.ID, planned.Location
+		}
+
+		last := len(profile.Stops) - 1
+		if last < 0 || stop.StopID == "" || profile.Stops[last].StopID != stop.StopID {
+			profile.Stops = append(profile.Stops, stop)
+			last++
+		}
+
+		load = max(load+count.Boardings-count.Alightings, 0)
+		profile.Stops[last].Boardings += count.Boardings
+		profile.Stops[last].Alightings += count.Alightings
+		profile.Stops[last].Load = load
+		profile.Boardings += count.Boardings
+		profile.Alightings += count.Alightings
+		profile.PeakLoad = max(profile.PeakLoad, load)
+	}
+	return profile
+}
+
+// countedStop returns the planned stop a count was made at: the stop with
+// the reported ID, or else the nearest stop within radiusMeters. It returns
+// nil when neither is known.
+func countedStop(location types.Location, stopID string, stops []types.PlannedStop, radiusMeters float64) *types.PlannedStop {
+	var nearest *types.PlannedStop
+	nearestDistance := radiusMeters
+	for i := range stops {
+		if stopID != "" {
+			if stops[i].ID == stopID {
+				return &stops[i]
+			}
+			continue
+		}
+		if distance := HaversineDistance(location, stops[i].Location); distance <= nearestDistance {
+			nearest, nearestDistance = &stops[i], distance
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,52 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestLoadProfile(t *testing.T) {
+	stops := []types.PlannedStop{
+		{ID: "first", Location: types.Location{Latitude: 0, Longitude: 0}},
+		{ID: "second", Location: types.Location{Latitude: 0, Longitude: 0.005}},
+	}
+	count := func(longitude float64, timestamp uint64, boardings, alightings int, stopID string) types.TrackPoint {
+		return types.TrackPoint{
+			Location:   types.Location{Latitude: 0, Longitude: longitude},
+			Timestamp:  timestamp,
+			Passengers: &types.PassengerCount{Boardings: boardings, Alightings: alightings, StopID: stopID},
+		}
+	}
+	points := []types.TrackPoint{
+		// Both doors report at the first stop
+		count(0.0001, 1000, 5, 0, ""),
+		count(0.0001, 2000, 3, 0, ""),
+		{Location: types.Location{Latitude: 0, Longitude: 0.002}, Timestamp: 3000},
+		// Between stops, the count keeps its own location
+		count(0.003, 4000, 1, 2, ""),
+		// Reported by ID, counted away from the stop
+		count(0.0048, 5000, 0, 9, "second"),
+	}
+
+	profile := LoadProfile(points, stops, 30)
+	if profile == nil || len(profile.Stops) != 3 {
+		t.Fatalf("Expected 3 stops in the profile, got %+v", profile)
+	}
+	if first := profile.Stops[0]; first.StopID != "first" || first.Location != stops[0].Location || first.Timestamp != 1000 || first.Boardings != 8 || first.Load != 8 {
+		t.Errorf("Expected both doors merged at the first stop, got %+v", first)
+	}
+	if between := profile.Stops[1]; between.StopID != "" || between.Location.Longitude != 0.003 || between.Load != 7 {
+		t.Errorf("Expected an unattributed count between the stops, got %+v", between)
+	}
+	if second := profile.Stops[2]; second.StopID != "second" || second.Location != stops[1].Location || second.Alightings != 9 || second.Load != 0 {
+		t.Errorf("Expected the load floored at zero at the second stop, got %+v", second)
+	}
+	if profile.Boardings != 9 || profile.Alightings != 11 || profile.PeakLoad != 8 {
+		t.Errorf("Unexpected totals %+v", profile)
+	}
+
+	if LoadProfile(points[2:3], stops, 30) != nil {
+		t.Error("Expected no profile without counts")
+	}
+}
@@ -0,0 +1,38 @@
+package algorithm
+
+import (
+	"math"
+
+	"data-ingestion-microservice/types"
+)
+
+// PolygonBounds returns the bounding box of a polygon's vertices
+func PolygonBounds(polygon []types.Location) types.BoundingBox {
+	box := types.BoundingBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, vertex := range polygon {
+		box.MinLon = math.Min(box.MinLon, vertex.Longitude)
+		box.MinLat = math.Min(box.MinLat, vertex.Latitude)
+		box.MaxLon = math.Max(box.MaxLon, vertex.Longitude)
+		box.MaxLat = math.Max(box.MaxLat, vertex.Latitude)
+	}
+	return box
+}
+
+// PointInPolygon reports whether a location lies inside a polygon using ray
+// casting in longitude/latitude. The polygon may be open or closed (first
+// vertex repeated at the end); points exactly on an edge may fall on either
+// side.
+func PointInPolygon(location types.Location, polygon []types.Location) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > location.Latitude) == (b.Latitude > location.Latitude) {
+			continue
+		}
+		crossing := a.Longitude + (location.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+		if location.Longitude < crossing {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,45 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestPointInPolygon(t *testing.T) {
+	// An L-shaped area: the top-right quadrant of the square is cut out
+	polygon := []types.Location{
+		{Latitude: 6.20, Longitude: -75.60},
+		{Latitude: 6.20, Longitude: -75.50},
+		{Latitude: 6.25, Longitude: -75.50},
+		{Latitude: 6.25, Longitude: -75.55},
+		{Latitude: 6.30, Longitude: -75.55},
+		{Latitude: 6.30, Longitude: -75.60},
+	}
+
+	tests := []struct {
+		name     string
+		location types.Location
+		want     bool
+	}{
+		{"bottom right arm", types.Location{Latitude: 6.22, Longitude: -75.52}, true},
+		{"top left arm", types.Location{Latitude: 6.28, Longitude: -75.58}, true},
+		{"cut out corner", types.Location{Latitude: 6.28, Longitude: -75.52}, false},
+		{"outside the bounds", types.Location{Latitude: 6.10, Longitude: -75.55}, false},
+	}
+
+	for _, tt := range tests {
+		if got := PointInPolygon(tt.location, polygon); got != tt.want {
+			t.Errorf("%s: PointInPolygon() = %v, want %v", tt.name, got, tt.want)
+		}
+		closed := append(append([]types.Location{}, polygon...), polygon[0])
+		if got := PointInPolygon(tt.location, closed); got != tt.want {
+			t.Errorf("%s: PointInPolygon() on a closed ring = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	box := PolygonBounds(polygon)
+	if box.MinLat != 6.20 || box.MaxLat != 6.30 || box.MinLon != -75.60 || box.MaxLon != -75.50 {
+		t.Errorf("unexpected bounds %+v", box)
+	}
+}
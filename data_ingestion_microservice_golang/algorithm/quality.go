@@ -0,0 +1,164 @@
+package algorithm
+
+import (
+	"math"
+	"sort"
+
+	"data-ingestion-microservice/types"
+)
+
+// Trip quality flags
+const (
+	QualityHasGaps           = "has_gaps"
+	QualityLowAccuracy       = "low_accuracy"
+	QualityIrregularSampling = "irregular_sampling"
+	QualityHasOutliers       = "has_outliers"
+	QualitySparse            = "sparse"
+)
+
+// Quality flag thresholds: the share of readings above the low accuracy
+// threshold that flags a trip, and the share of regular intervals below
+// which its sampling is irregular
+const (
+	lowAccuracyFlagPercent = 20
+	regularFlagPercent     = 80
+)
+
+// outlierPenalty is how much of the outlier part of a quality score every
+// outlier per point removes, so outliers in a tenth of the points remove
+// all of it
+const outlierPenalty = 10
+
+// RemoveOutliers drops the single-point GPS spikes of a trip: points reached
+// from the previous point and left to the next one faster than maxSpeedKmh,
+// when going straight from the previous point to the next is not. Points
+// without a timestamp, the first and last points, and points carrying
+// passenger counts are kept. It returns the remaining points and how many
+// were removed.
+func RemoveOutliers(points []types.TrackPoint, maxSpeedKmh float64) ([]types.TrackPoint, int) {
+	kept := make([]types.TrackPoint, 0, len(points))
+	for i, point := range points {
+		if len(kept) > 0 && i+1 < len(points) && point.Passengers == nil && isSpike(kept[len(kept)-1], point, points[i+1], maxSpeedKmh) {
+			continue
+		}
+		kept = append(kept, point)
+	}
+	return kept, len(points) - len(kept)
+}
+
+// isSpike reports whether a point is too fast to reach and to leave, while
+// skipping it is not
+func isSpike(prev, point, next types.TrackPoint, maxSpeedKmh float64) bool {
+	in, ok := speedBetween(prev, point)
+	if !ok || in <= maxSpeedKmh {
+		return false
+	}
+	out, ok := speedBetween(point, next)
+	if !ok || out <= maxSpeedKmh {
+		return false
+	}
+	across, ok := speedBetween(prev, next)
+	return ok && across <= maxSpeedKmh
+}
+
+// speedBetween returns the speed in km/h needed to go from one point to the
+// next, or false when their timestamps do not increase
+func speedBetween(from, to types.TrackPoint) (float64, bool) {
+	if from.Timestamp == 0 || to.Timestamp <= from.Timestamp {
+		return 0, false
+	}
+	seconds := float64(to.Timestamp-from.Timestamp) / 1000
+	return HaversineDistance(from.Location, to.Location) / seconds * 3.6, true
+}
+
+// ScoreQuality rates the trace of a trip from its points, after outliers
+// were removed, and the number of outliers removed. The score averages the
+// share of regular intervals, the share of the trip not spent in gaps, the
+// share of accurate readings when any point reported its accuracy, and the
+// part left by the outliers. Trips without two timestamped points are sparse
+// and score 0.
+func ScoreQuality(points []types.TrackPoint, outliers int, config types.QualityConfig) types.TripQuality {
+	quality := types.TripQuality{OutliersRemoved: outliers, Flags: []string{}}
+
+	var intervals, accuracies []float64
+	var prev uint64
+	for _, point := range points {
+		if point.AccuracyMeters != nil {
+			accuracies = append(accuracies, *point.AccuracyMeters)
+		}
+		if point.Timestamp == 0 || point.Timestamp <= prev {
+			continue
+		}
+		if prev > 0 {
+			intervals = append(intervals, float64(point.Timestamp-prev)/1000)
+		}
+		prev = point.Timestamp
+	}
+
+	var parts []float64
+	if len(intervals) > 0 {
+		median := percentile(intervals, 50)
+		quality.MedianIntervalSeconds = median
+		regular, duration := 0, 0.0
+		for _, interval := range intervals {
+			duration += interval
+			if interval >= median/2 && interval <= median*2 {
+				regular++
+			}
+			if interval > config.GapThreshold.Seconds() {
+				quality.Gaps++
+				quality.GapSeconds += interval
+			}
+		}
+		quality.RegularPercent = float64(regular) / float64(len(intervals)) * 100
+		parts = append(parts, quality.RegularPercent/100, 1-quality.GapSeconds/duration)
+	}
+
+	if len(accuracies) > 0 {
+		median, p90 := percentile(accuracies, 50), percentile(accuracies, 90)
+		low := 0
+		for _, accuracy := range accuracies {
+			if accuracy > config.LowAccuracyMeters {
+				low++
+			}
+		}
+		lowPercent := float64(low) / float64(len(accuracies)) * 100
+		quality.MedianAccuracyMeters, quality.P90AccuracyMeters, quality.LowAccuracyPercent = &median, &p90, &lowPercent
+		parts = append(parts, 1-lowPercent/100)
+	}
+
+	if total := len(points) + outliers; total > 0 {
+		parts = append(parts, max(1-outlierPenalty*float64(outliers)/float64(total), 0))
+	}
+
+	if len(intervals) == 0 {
+		quality.Flags = append(quality.Flags, QualitySparse)
+	} else {
+		sum := 0.0
+		for _, part := range parts {
+			sum += part
+		}
+		quality.Score = sum / float64(len(parts)) * 100
+	}
+	if quality.Gaps > 0 {
+		quality.Flags = append(quality.Flags, QualityHasGaps)
+	}
+	if len(intervals) > 0 && quality.RegularPercent < regularFlagPercent {
+		quality.Flags = append(quality.Flags, QualityIrregularSampling)
+	}
+	if quality.LowAccuracyPercent != nil && *quality.LowAccuracyPercent > lowAccuracyFlagPercent {
+		quality.Flags = append(quality.Flags, QualityLowAccuracy)
+	}
+	if outliers > 0 {
+		quality.Flags = append(quality.Flags, QualityHasOutliers)
+	}
+	return quality
+}
+
+// percentile returns the nearest-rank percentile of some values
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
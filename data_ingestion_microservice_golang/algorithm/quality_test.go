@@ -0,0 +1,76 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+var qualityTestConfig = types.QualityConfig{MaxSpeedKmh: 200, GapThreshold: time.Minute, LowAccuracyMeters: 25}
+
+func TestRemoveOutliers(t *testing.T) {
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0, Longitude: 0}, Timestamp: 1000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.0001}, Timestamp: 2000},
+		// 11 km away and back within a second each way
+		{Location: types.Location{Latitude: 0.1, Longitude: 0.0002}, Timestamp: 3000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.0003}, Timestamp: 4000},
+		// A spike with a passenger count is kept
+		{Location: types.Location{Latitude: 0.1, Longitude: 0.0004}, Timestamp: 5000, Passengers: &types.PassengerCount{Boardings: 1}},
+		{Location: types.Location{Latitude: 0, Longitude: 0.0005}, Timestamp: 6000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.0006}, Timestamp: 7000},
+		// The last point has nothing to come back to
+		{Location: types.Location{Latitude: 0.1, Longitude: 0.0007}, Timestamp: 8000},
+	}
+
+	kept, removed := RemoveOutliers(points, 200)
+	if removed != 1 || len(kept) != 7 || kept[2].Timestamp != 4000 {
+		t.Errorf("Expected the spike at 3000 removed, got %d removed and %+v", removed, kept)
+	}
+
+	// Two points in a row far away are a real move, not a spike
+	moved := []types.TrackPoint{points[0], points[2], {Location: types.Location{Latitude: 0.1, Longitude: 0.0003}, Timestamp: 1000000}}
+	if _, removed := RemoveOutliers(moved, 200); removed != 0 {
+		t.Errorf("Expected no outliers when the vehicle stays, got %d", removed)
+	}
+}
+
+func TestScoreQuality(t *testing.T) {
+	accurate, inaccurate := 5.0, 40.0
+	var points []types.TrackPoint
+	for _, seconds := range []uint64{0, 10, 20, 30, 40, 50, 60, 70, 80, 200} {
+		points = append(points, types.TrackPoint{Timestamp: 1640995200000 + seconds*1000, Telemetry: types.Telemetry{AccuracyMeters: &accurate}})
+	}
+	points[3].AccuracyMeters = &inaccurate
+	points[4].AccuracyMeters = &inaccurate
+	points[5].AccuracyMeters = &inaccurate
+
+	quality := ScoreQuality(points, 1, qualityTestConfig)
+	if quality.MedianIntervalSeconds != 10 || quality.Gaps != 1 || quality.GapSeconds != 120 {
+		t.Errorf("Expected one 2 minute gap between 10s samples, got %+v", quality)
+	}
+	if math.Abs(quality.RegularPercent-800.0/9) > 1e-9 {
+		t.Errorf("Expected 8 of 9 intervals regular, got %v", quality.RegularPercent)
+	}
+	if *quality.MedianAccuracyMeters != 5 || *quality.P90AccuracyMeters != 40 || *quality.LowAccuracyPercent != 30 {
+		t.Errorf("Unexpected accuracy distribution %+v", quality)
+	}
+	// Regular 8/9, 80s of 200s outside gaps, 70% accurate, and 1 outlier in 11 points
+	want := (8.0/9 + 0.4 + 0.7 + (1 - 10.0/11)) / 4 * 100
+	if math.Abs(quality.Score-want) > 1e-9 {
+		t.Errorf("Expected score %v, got %v", want, quality.Score)
+	}
+	wantFlags := []string{QualityHasGaps, QualityLowAccuracy, QualityHasOutliers}
+	if len(quality.Flags) != len(wantFlags) || quality.Flags[0] != wantFlags[0] || quality.Flags[1] != wantFlags[1] || quality.Flags[2] != wantFlags[2] {
+		t.Errorf("Expected flags %v, got %v", wantFlags, quality.Flags)
+	}
+
+	if sparse := ScoreQuality(points[:1], 0, qualityTestConfig); sparse.Score != 0 || len(sparse.Flags) != 1 || sparse.Flags[0] != QualitySparse {
+		t.Errorf("Expected a single point to be sparse, got %+v", sparse)
+	}
+	if clean := ScoreQuality(points[:3], 0, qualityTestConfig); clean.Score != 100 || len(clean.Flags) != 0 || clean.MedianAccuracyMeters == nil {
+		t.Errorf("Expected a perfect score, got %+v", clean)
+	}
+}
@@ -1,30 +1,55 @@
 package algorithm
 
 import (
+	"fmt"
 	"math"
+	"sync"
 
 	"data-ingestion-microservice/types"
 )
 
+// Supported route simplification algorithms
+const (
+	AlgorithmDouglasPeucker    = "douglas-peucker"
+	AlgorithmVisvalingamWhyatt = "visvalingam-whyatt"
+)
+
 // RouteSimplifier handles route simplification using various algorithms
 type RouteSimplifier struct {
+	mu        sync.RWMutex
 	tolerance float64
+	algorithm string
 }
 
 // NewRouteSimplifier creates a new route simplifier with the given tolerance
 func NewRouteSimplifier(tolerance float64) *RouteSimplifier {
 	return &RouteSimplifier{
 		tolerance: tolerance,
+		algorithm: AlgorithmDouglasPeucker,
 	}
 }
 
+// ValidAlgorithm reports whether name is a supported simplification algorithm
+func ValidAlgorithm(name string) bool {
+	return name == AlgorithmDouglasPeucker || name == AlgorithmVisvalingamWhyatt
+}
+
 // Point represents a 2D point for algorithm calculations
 type Point struct {
 	X, Y float64
 }
 
-// SimplifyRoute simplifies a route using the Douglas-Peucker algorithm
+// SimplifyRoute simplifies a route using the configured algorithm.
+// Visvalingam-Whyatt removes points whose triangle with their neighbours is
+// smaller than tolerance²/2, the area of a triangle with base and height
+// equal to the tolerance.
 func (rs *RouteSimplifier) SimplifyRoute(locations []types.Location) ([]types.Location, error) {
+	return rs.SimplifyRouteWith(locations, rs.GetTolerance(), rs.GetAlgorithm())
+}
+
+// SimplifyRouteWith simplifies a route with the given tolerance and
+// algorithm instead of the configured ones
+func (rs *RouteSimplifier) SimplifyRouteWith(locations []types.Location, tolerance float64, algorithm string) ([]types.Location, error) {
 	if len(locations) <= 2 {
 		return locations, nil
 	}
@@ -35,25 +60,46 @@ func (rs *RouteSimplifier) SimplifyRoute(locations []types.Location) ([]types.Lo
 		points[i] = Point{X: loc.Longitude, Y: loc.Latitude}
 	}
 
-	// Apply Douglas-Peucker algorithm
-	simplified := rs.douglasPeucker(points, rs.tolerance)
+	// Keep the locations selected by the algorithm
+	kept := rs.simplify(points, tolerance, algorithm)
+	result := make([]types.Location, len(kept))
+	for i, index := range kept {
+		result[i] = locations[index]
+	}
 
-	// Convert back to Location structs
-	result := make([]types.Location, len(simplified))
-	for i, point := range simplified {
-		result[i] = types.Location{
-			Longitude: point.X,
-			Latitude:  point.Y,
-		}
+	return result, nil
+}
+
+// SimplifyTrackWith simplifies the route of a track like SimplifyRouteWith,
+// keeping the device timestamp of every point kept
+func (rs *RouteSimplifier) SimplifyTrackWith(track []types.TrackPoint, tolerance float64, algorithm string) ([]types.RoutePoint, error) {
+	points := make([]Point, len(track))
+	for i, trackPoint := range track {
+		points[i] = Point{X: trackPoint.Longitude, Y: trackPoint.Latitude}
+	}
+
+	kept := rs.simplify(points, tolerance, algorithm)
+	result := make([]types.RoutePoint, len(kept))
+	for i, index := range kept {
+		result[i] = types.RoutePoint{Location: track[index].Location, Timestamp: track[index].Timestamp}
 	}
 
 	return result, nil
 }
 
-// douglasPeucker implements the Ramer-Douglas-Peucker algorithm
-func (rs *RouteSimplifier) douglasPeucker(points []Point, tolerance float64) []Point {
+// simplify returns the indices of the points kept by the selected algorithm
+func (rs *RouteSimplifier) simplify(points []Point, tolerance float64, algorithm string) []int {
+	if algorithm == AlgorithmVisvalingamWhyatt {
+		return visvalingamWhyattIndices(points, tolerance*tolerance/2)
+	}
+	return rs.douglasPeucker(points, tolerance)
+}
+
+// douglasPeucker implements the Ramer-Douglas-Peucker algorithm and returns
+// the indices of the points kept
+func (rs *RouteSimplifier) douglasPeucker(points []Point, tolerance float64) []int {
 	if len(points) <= 2 {
-		return points
+		return allIndices(len(points))
 	}
 
 	// Find the point with the maximum distance from the line segment
@@ -78,15 +124,27 @@ func (rs *RouteSimplifier) douglasPeucker(points []Point, tolerance float64) []P
 		// Recursive call on the second part
 		secondPart := rs.douglasPeucker(points[maxIndex:], tolerance)
 
-		// Combine results (remove duplicate point at the junction)
-		result := make([]Point, len(firstPart)+len(secondPart)-1)
+		// Combine results (remove duplicate point at the junction), with
+		// the second part's indices shifted to the junction
+		result := make([]int, len(firstPart)+len(secondPart)-1)
 		copy(result, firstPart)
-		copy(result[len(firstPart):], secondPart[1:])
+		for i, index := range secondPart[1:] {
+			result[len(firstPart)+i] = maxIndex + index
+		}
 		return result
 	}
 
 	// If no point is farther than tolerance, return only start and end points
-	return []Point{start, end}
+	return []int{0, len(points) - 1}
+}
+
+// allIndices returns the indices of n points
+func allIndices(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
 }
 
 // perpendicularDistance calculates the perpendicular distance from a point to a line segment
@@ -114,32 +172,60 @@ func (rs *RouteSimplifier) distance(p1, p2 Point) float64 {
 
 // GetCompressionStats returns statistics about the compression
 func (rs *RouteSimplifier) GetCompressionStats(original, simplified []types.Location) CompressionStats {
-	compressionRatio := float64(len(simplified)) / float64(len(original))
-	
+	return NewCompressionStats(len(original), len(simplified))
+}
+
+// NewCompressionStats returns the compression of a route simplified from
+// originalPoints to simplifiedPoints points
+func NewCompressionStats(originalPoints, simplifiedPoints int) CompressionStats {
+	compressionRatio := float64(simplifiedPoints) / float64(originalPoints)
+
 	return CompressionStats{
-		OriginalPoints:    len(original),
-		SimplifiedPoints:  len(simplified),
-		CompressionRatio:  compressionRatio,
-		PointsRemoved:     len(original) - len(simplified),
-		ReductionPercent:  (1 - compressionRatio) * 100,
+		OriginalPoints:   originalPoints,
+		SimplifiedPoints: simplifiedPoints,
+		CompressionRatio: compressionRatio,
+		PointsRemoved:    originalPoints - simplifiedPoints,
+		ReductionPercent: (1 - compressionRatio) * 100,
 	}
 }
 
 // CompressionStats holds statistics about route compression
 type CompressionStats struct {
-	OriginalPoints    int     `json:"originalPoints"`
-	SimplifiedPoints  int     `json:"simplifiedPoints"`
-	CompressionRatio  float64 `json:"compressionRatio"`
-	PointsRemoved     int     `json:"pointsRemoved"`
-	ReductionPercent  float64 `json:"reductionPercent"`
+	OriginalPoints   int     `json:"originalPoints"`
+	SimplifiedPoints int     `json:"simplifiedPoints"`
+	CompressionRatio float64 `json:"compressionRatio"`
+	PointsRemoved    int     `json:"pointsRemoved"`
+	ReductionPercent float64 `json:"reductionPercent"`
 }
 
 // SetTolerance updates the tolerance value
 func (rs *RouteSimplifier) SetTolerance(tolerance float64) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
 	rs.tolerance = tolerance
 }
 
 // GetTolerance returns the current tolerance value
 func (rs *RouteSimplifier) GetTolerance() float64 {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	return rs.tolerance
-} 
\ No newline at end of file
+}
+
+// SetAlgorithm selects the simplification algorithm
+func (rs *RouteSimplifier) SetAlgorithm(algorithm string) error {
+	if !ValidAlgorithm(algorithm) {
+		return fmt.Errorf("unknown simplification algorithm %q", algorithm)
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.algorithm = algorithm
+	return nil
+}
+
+// GetAlgorithm returns the selected simplification algorithm
+func (rs *RouteSimplifier) GetAlgorithm() string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.algorithm
+}
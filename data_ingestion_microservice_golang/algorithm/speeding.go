@@ -0,0 +1,102 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// SpeedLimit returns the speed limit in km/h at a location, or 0 when the
+// location has no limit
+type SpeedLimit func(location types.Location) float64
+
+// SpeedingTracker follows a vehicle point by point and tracks the period it
+// has been driving above the speed limit. A segment is speeding when its
+// speed exceeds the limit at its end by more than the tolerance. Points
+// without a timestamp or going back in time are skipped.
+type SpeedingTracker struct {
+	limit        SpeedLimit
+	toleranceKmh float64
+	minSeconds   float64
+
+	prev    *types.TrackPoint
+	current *types.SpeedingViolation
+}
+
+// NewSpeedingTracker creates a tracker reporting periods lasting at least
+// minSeconds
+func NewSpeedingTracker(limit SpeedLimit, toleranceKmh, minSeconds float64) *SpeedingTracker {
+	return &SpeedingTracker{limit: limit, toleranceKmh: toleranceKmh, minSeconds: minSeconds}
+}
+
+// Add records the next point. It returns the speeding period the point
+// extends once the period lasts the minimum duration, and the period the
+// point ended when that one lasted long enough.
+func (t *SpeedingTracker) Add(point types.TrackPoint) (ongoing, ended *types.SpeedingViolation) {
+	prev := t.prev
+	if point.Timestamp == 0 || (prev != nil && point.Timestamp <= prev.Timestamp) {
+		return nil, nil
+	}
+	t.prev = &point
+	if prev == nil {
+		return nil, nil
+	}
+
+	distance := HaversineDistance(prev.Location, point.Location)
+	seconds := float64(point.Timestamp-prev.Timestamp) / 1000
+	speedKmh := distance / seconds * 3.6
+	limitKmh := t.limit(point.Location)
+
+	if limitKmh <= 0 || speedKmh <= limitKmh+t.toleranceKmh {
+		return nil, t.Finish()
+	}
+
+	if t.current == nil {
+		t.current = &types.SpeedingViolation{
+			Start:          prev.Location,
+			StartTimestamp: prev.Timestamp,
+		}
+	}
+	violation := t.current
+	violation.End = point.Location
+	violation.EndTimestamp = point.Timestamp
+	violation.DurationSeconds += seconds
+	violation.DistanceMeters += distance
+	if speedKmh > violation.MaxSpeedKmh {
+		violation.MaxSpeedKmh = speedKmh
+		violation.LimitKmh = limitKmh
+	}
+
+	if violation.DurationSeconds < t.minSeconds {
+		return nil, nil
+	}
+	return violation, nil
+}
+
+// Finish ends the period in progress, returning it when it lasted the
+// minimum duration
+func (t *SpeedingTracker) Finish() *types.SpeedingViolation {
+	violation := t.current
+	t.current = nil
+	if violation == nil || violation.DurationSeconds < t.minSeconds {
+		return nil
+	}
+	return violation
+}
+
+// DetectSpeeding returns the periods of a trip in which the vehicle drove
+// above the speed limit for at least minSeconds
+func DetectSpeeding(points []types.TrackPoint, limit SpeedLimit, toleranceKmh, minSeconds float64) []types.SpeedingViolation {
+	var violations []types.SpeedingViolation
+
+	tracker := NewSpeedingTracker(limit, toleranceKmh, minSeconds)
+	for _, point := range points {
+		if _, ended := tracker.Add(point); ended != nil {
+			violations = append(violations, *ended)
+		}
+	}
+	// A trip that ends while speeding still has its final violation
+	if ended := tracker.Finish(); ended != nil {
+		violations = append(violations, *ended)
+	}
+
+	return violations
+}
@@ -0,0 +1,73 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestDetectSpeeding(t *testing.T) {
+	// 0.001 degrees of longitude at the equator is about 111 meters, so
+	// 0.001 degrees in 5 seconds is about 80 km/h and in 10 seconds 40 km/h
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.000}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}, Timestamp: 1640995205000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.002}, Timestamp: 1640995210000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.003}, Timestamp: 1640995215000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.004}, Timestamp: 1640995225000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.005}, Timestamp: 1640995230000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.006}, Timestamp: 1640995240000},
+	}
+	limit := func(types.Location) float64 { return 50 }
+
+	violations := DetectSpeeding(points, limit, 5, 10)
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, the second one being too short, got %+v", violations)
+	}
+	violation := violations[0]
+	if violation.StartTimestamp != 1640995200000 || violation.EndTimestamp != 1640995215000 {
+		t.Errorf("Expected the violation to span the first 15s, got %d-%d", violation.StartTimestamp, violation.EndTimestamp)
+	}
+	if violation.DurationSeconds != 15 {
+		t.Errorf("Expected 15s of speeding, got %f", violation.DurationSeconds)
+	}
+	if math.Abs(violation.MaxSpeedKmh-80) > 0.5 || violation.LimitKmh != 50 {
+		t.Errorf("Expected about 80 km/h in a 50 km/h zone, got %f in %f", violation.MaxSpeedKmh, violation.LimitKmh)
+	}
+	if violation.End != points[3].Location {
+		t.Errorf("Expected the violation to end at %v, got %v", points[3].Location, violation.End)
+	}
+
+	if violations := DetectSpeeding(points, func(types.Location) float64 { return 0 }, 5, 10); len(violations) != 0 {
+		t.Errorf("Expected no violations without speed limits, got %+v", violations)
+	}
+}
+
+func TestSpeedingTracker_ReportsOngoingViolation(t *testing.T) {
+	tracker := NewSpeedingTracker(func(types.Location) float64 { return 50 }, 0, 10)
+
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.000}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}, Timestamp: 1640995205000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}}, // no timestamp
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.002}, Timestamp: 1640995210000},
+	}
+	var ongoing *types.SpeedingViolation
+	for i, point := range points {
+		var ended *types.SpeedingViolation
+		ongoing, ended = tracker.Add(point)
+		if ended != nil {
+			t.Fatalf("Point %d: expected no ended violation, got %+v", i, ended)
+		}
+		if i < len(points)-1 && ongoing != nil {
+			t.Fatalf("Point %d: expected the violation to be too short, got %+v", i, ongoing)
+		}
+	}
+	if ongoing == nil || ongoing.DurationSeconds != 10 {
+		t.Fatalf("Expected a 10s violation in progress, got %+v", ongoing)
+	}
+	if ended := tracker.Finish(); ended == nil || ended.StartTimestamp != 1640995200000 {
+		t.Errorf("Expected Finish to return the violation, got %+v", ended)
+	}
+}
@@ -0,0 +1,123 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// StopVisitTracker follows a vehicle point by point and detects its visits
+// to the stops of a planned route. A vehicle arrives at a stop once it has
+// stayed within the radius for the minimum dwell, and departs at the last
+// point within it. Each stop is visited at most once, so the same location
+// listed twice on a loop is visited once per listing, in route order.
+// Scheduled times are offsets from the first point. Points without a
+// timestamp or going back in time are skipped.
+type StopVisitTracker struct {
+	stops           []types.PlannedStop
+	radiusMeters    float64
+	minDwellSeconds float64
+
+	start   uint64
+	prev    uint64
+	visited []bool
+	// current is the stop the vehicle is within the radius of, at index
+	current *types.StopVisit
+	index   int
+	arrived bool
+}
+
+// NewStopVisitTracker creates a tracker of the visits to stops
+func NewStopVisitTracker(stops []types.PlannedStop, radiusMeters, minDwellSeconds float64) *StopVisitTracker {
+	return &StopVisitTracker{
+		stops:           stops,
+		radiusMeters:    radiusMeters,
+		minDwellSeconds: minDwellSeconds,
+		visited:         make([]bool, len(stops)),
+	}
+}
+
+// Add records the next point. It returns the visit the point completed the
+// minimum dwell of, and the visit the point departed from.
+func (t *StopVisitTracker) Add(point types.TrackPoint) (arrived, departed *types.StopVisit) {
+	if point.Timestamp == 0 || point.Timestamp <= t.prev {
+		return nil, nil
+	}
+	if t.start == 0 {
+		t.start = point.Timestamp
+	}
+	t.prev = point.Timestamp
+
+	if t.current != nil && HaversineDistance(point.Location, t.current.Location) <= t.radiusMeters {
+		t.current.DepartureTimestamp = point.Timestamp
+	} else {
+		departed = t.Finish()
+		t.enter(point)
+	}
+	if t.current == nil || t.arrived {
+		return nil, departed
+	}
+
+	visit := t.current
+	visit.DwellSeconds = float64(visit.DepartureTimestamp-visit.ArrivalTimestamp) / 1000
+	if visit.DwellSeconds < t.minDwellSeconds {
+		return nil, departed
+	}
+	t.arrived = true
+	t.visited[t.index] = true
+	arrival := *visit
+	arrival.DepartureTimestamp = 0
+	return &arrival, departed
+}
+
+// Finish ends the visit in progress, returning it when the vehicle arrived
+func (t *StopVisitTracker) Finish() *types.StopVisit {
+	visit, arrived := t.current, t.arrived
+	t.current, t.arrived = nil, false
+	if visit == nil || !arrived {
+		return nil
+	}
+	visit.DwellSeconds = float64(visit.DepartureTimestamp-visit.ArrivalTimestamp) / 1000
+	return visit
+}
+
+// enter starts a visit at the first stop in route order not visited yet
+// that the point is within the radius of
+func (t *StopVisitTracker) enter(point types.TrackPoint) {
+	for i, stop := range t.stops {
+		if t.visited[i] || HaversineDistance(point.Location, stop.Location) > t.radiusMeters {
+			continue
+		}
+		visit := &types.StopVisit{
+			StopID:             stop.ID,
+			Name:               stop.Name,
+			Location:           stop.Location,
+			ArrivalTimestamp:   point.Timestamp,
+			DepartureTimestamp: point.Timestamp,
+		}
+		if stop.ScheduledOffset != nil {
+			visit.ScheduledTimestamp = t.start + uint64(*stop.ScheduledOffset*1000)
+			delay := (float64(visit.ArrivalTimestamp) - float64(visit.ScheduledTimestamp)) / 1000
+			visit.DelaySeconds = &delay
+		}
+		t.current, t.index = visit, i
+		return
+	}
+}
+
+// DetectStopVisits returns the visits of a trip to the stops of its planned
+// route, in the order they happened
+func DetectStopVisits(points []types.TrackPoint, stops []types.PlannedStop, radiusMeters, minDwellSeconds float64) []types.StopVisit {
+	var visits []types.StopVisit
+
+	tracker := NewStopVisitTracker(stops, radiusMeters, minDwellSeconds)
+	for _, point := range points {
+		if _, departed := tracker.Add(point); departed != nil {
+			visits = append(visits, *departed)
+		}
+	}
+	// A trip that ends at a stop still has its final visit
+	if visit := tracker.Finish(); visit != nil {
+		visits = append(visits, *visit)
+	}
+
+	return visits
+}
@@ -0,0 +1,87 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestStopVisitTracker(t *testing.T) {
+	offset := 30.0
+	stops := []types.PlannedStop{
+		{ID: "depot_out", Location: types.Location{Latitude: 0, Longitude: 0}},
+		{ID: "market", Name: "Market", Location: types.Location{Latitude: 0, Longitude: 0.002}, ScheduledOffset: &offset},
+		{ID: "passed", Location: types.Location{Latitude: 0, Longitude: 0.004}},
+		{ID: "depot_in", Location: types.Location{Latitude: 0, Longitude: 0}},
+	}
+	tracker := NewStopVisitTracker(stops, 30, 10)
+
+	add := func(longitude float64, seconds uint64) (*types.StopVisit, *types.StopVisit) {
+		t.Helper()
+		return tracker.Add(types.TrackPoint{Location: types.Location{Latitude: 0, Longitude: longitude}, Timestamp: 1640995200000 + seconds*1000})
+	}
+
+	if arrived, _ := add(0, 0); arrived != nil {
+		t.Fatalf("Expected no arrival before the minimum dwell, got %+v", arrived)
+	}
+	arrived, _ := add(0.00001, 10)
+	if arrived == nil || arrived.StopID != "depot_out" || arrived.DwellSeconds != 10 || arrived.DepartureTimestamp != 0 {
+		t.Fatalf("Expected the arrival at depot_out after 10s, got %+v", arrived)
+	}
+	if arrived, departed := add(0, 20); arrived != nil || departed != nil {
+		t.Errorf("Expected the arrival reported once, got %+v and %+v", arrived, departed)
+	}
+	_, departed := add(0.001, 30)
+	if departed == nil || departed.StopID != "depot_out" || departed.DepartureTimestamp != 1640995220000 || departed.DwellSeconds != 20 {
+		t.Fatalf("Expected the departure from depot_out at its last point, got %+v", departed)
+	}
+
+	add(0.002, 40)
+	arrived, _ = add(0.002, 55)
+	if arrived == nil || arrived.StopID != "market" || arrived.Name != "Market" || arrived.ScheduledTimestamp != 1640995230000 || *arrived.DelaySeconds != 10 {
+		t.Fatalf("Expected the arrival at the market 10s late, got %+v", arrived)
+	}
+
+	// Driving past a stop without dwelling is not a visit
+	if _, departed := add(0.004, 60); departed == nil || departed.StopID != "market" {
+		t.Errorf("Expected the departure from the market, got %+v", departed)
+	}
+	if arrived, departed := add(0.003, 65); arrived != nil || departed != nil {
+		t.Errorf("Expected no visit to the passed stop, got %+v and %+v", arrived, departed)
+	}
+
+	// Back at the depot, the second listing of it is visited
+	add(0, 70)
+	if arrived, _ := add(0, 90); arrived == nil || arrived.StopID != "depot_in" {
+		t.Fatalf("Expected the arrival at depot_in, got %+v", arrived)
+	}
+	if visit := tracker.Finish(); visit == nil || visit.StopID != "depot_in" || visit.DwellSeconds != 20 {
+		t.Errorf("Expected the visit in progress when the trip ends, got %+v", visit)
+	}
+}
+
+func TestDetectStopVisits(t *testing.T) {
+	stops := []types.PlannedStop{
+		{ID: "a", Location: types.Location{Latitude: 0, Longitude: 0}},
+		{ID: "b", Location: types.Location{Latitude: 0, Longitude: 0.002}},
+	}
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0, Longitude: 0}, Timestamp: 1000},
+		{Location: types.Location{Latitude: 0, Longitude: 0}, Timestamp: 20000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.001}, Timestamp: 30000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.001}, Timestamp: 25000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.002}, Timestamp: 40000},
+		{Location: types.Location{Latitude: 0, Longitude: 0.002}, Timestamp: 60000},
+	}
+
+	visits := DetectStopVisits(points, stops, 30, 15)
+	if len(visits) != 2 || visits[0].StopID != "a" || visits[1].StopID != "b" {
+		t.Fatalf("Expected visits to a and b, got %+v", visits)
+	}
+	if visits[0].ArrivalTimestamp != 1000 || visits[0].DepartureTimestamp != 20000 || visits[0].DwellSeconds != 19 {
+		t.Errorf("Expected 19s at a, got %+v", visits[0])
+	}
+	if visits[1].DwellSeconds != 20 || visits[1].DelaySeconds != nil {
+		t.Errorf("Expected 20s at b without a schedule, got %+v", visits[1])
+	}
+}
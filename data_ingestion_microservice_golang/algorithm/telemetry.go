@@ -0,0 +1,69 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// SummarizeTelemetry aggregates the telemetry of a trip's points, or returns
+// nil when none of them carried any. Battery readings are taken from the
+// first and last points reporting one.
+func SummarizeTelemetry(points []types.TrackPoint) *types.TripTelemetry {
+	var summary types.TripTelemetry
+	var accuracySum float64
+	accuracyCount := 0
+
+	for _, point := range points {
+		if point.Telemetry.IsZero() {
+			continue
+		}
+		summary.Points++
+
+		if altitude := point.AltitudeMeters; altitude != nil {
+			summary.MinAltitudeMeters = minOf(summary.MinAltitudeMeters, *altitude)
+			summary.MaxAltitudeMeters = maxOf(summary.MaxAltitudeMeters, *altitude)
+		}
+		if speed := point.SpeedKmh; speed != nil {
+			summary.MaxSpeedKmh = maxOf(summary.MaxSpeedKmh, *speed)
+		}
+		if accuracy := point.AccuracyMeters; accuracy != nil {
+			accuracySum += *accuracy
+			accuracyCount++
+		}
+		if satellites := point.Satellites; satellites != nil && (summary.MinSatellites == nil || *satellites < *summary.MinSatellites) {
+			value := *satellites
+			summary.MinSatellites = &value
+		}
+		if battery := point.BatteryPercent; battery != nil {
+			value := *battery
+			if summary.BatteryStartPercent == nil {
+				summary.BatteryStartPercent = &value
+			}
+			summary.BatteryEndPercent = &value
+		}
+	}
+
+	if summary.Points == 0 {
+		return nil
+	}
+	if accuracyCount > 0 {
+		average := accuracySum / float64(accuracyCount)
+		summary.AvgAccuracyMeters = &average
+	}
+	return &summary
+}
+
+// minOf returns the smaller of a reading and the current minimum, if any
+func minOf(current *float64, value float64) *float64 {
+	if current != nil && *current <= value {
+		return current
+	}
+	return &value
+}
+
+// maxOf returns the larger of a reading and the current maximum, if any
+func maxOf(current *float64, value float64) *float64 {
+	if current != nil && *current >= value {
+		return current
+	}
+	return &value
+}
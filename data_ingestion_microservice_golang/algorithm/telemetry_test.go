@@ -0,0 +1,42 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestSummarizeTelemetry(t *testing.T) {
+	satellites := 6
+	points := []types.TrackPoint{
+		{Telemetry: types.Telemetry{AltitudeMeters: float(-3), AccuracyMeters: float(4)}},
+		{},
+		{Telemetry: types.Telemetry{AltitudeMeters: float(12), SpeedKmh: float(40), AccuracyMeters: float(8), BatteryPercent: float(55)}},
+		{Telemetry: types.Telemetry{Satellites: &satellites, BatteryPercent: float(54)}},
+	}
+
+	summary := SummarizeTelemetry(points)
+	if summary == nil {
+		t.Fatal("Expected a telemetry summary")
+	}
+	if summary.Points != 3 {
+		t.Errorf("Expected 3 points with telemetry, got %d", summary.Points)
+	}
+	if *summary.MinAltitudeMeters != -3 || *summary.MaxAltitudeMeters != 12 {
+		t.Errorf("Expected altitudes -3 to 12, got %v to %v", *summary.MinAltitudeMeters, *summary.MaxAltitudeMeters)
+	}
+	if *summary.MaxSpeedKmh != 40 || *summary.AvgAccuracyMeters != 6 || *summary.MinSatellites != 6 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if *summary.BatteryStartPercent != 55 || *summary.BatteryEndPercent != 54 {
+		t.Errorf("Expected battery from 55 to 54, got %v to %v", *summary.BatteryStartPercent, *summary.BatteryEndPercent)
+	}
+}
+
+func TestSummarizeTelemetry_NoTelemetry(t *testing.T) {
+	if summary := SummarizeTelemetry([]types.TrackPoint{{Timestamp: 1}, {Timestamp: 2}}); summary != nil {
+		t.Errorf("Expected no summary without telemetry, got %+v", summary)
+	}
+}
@@ -0,0 +1,111 @@
+package algorithm
+
+import (
+	"data-ingestion-microservice/types"
+)
+
+// ComputeTripStats calculates duration, moving/idle time, speeds, and stops
+// for a trip. Segments whose endpoints lack timestamps only contribute distance.
+func ComputeTripStats(points []types.TrackPoint, config types.TripStatsConfig) types.TripStats {
+	var stats types.TripStats
+	if len(points) < 2 {
+		return stats
+	}
+
+	// Duration spans the first and last timestamped points
+	var firstTs, lastTs uint64
+	for _, point := range points {
+		if point.Timestamp == 0 {
+			continue
+		}
+		if firstTs == 0 {
+			firstTs = point.Timestamp
+		}
+		lastTs = point.Timestamp
+	}
+	if lastTs > firstTs {
+		stats.DurationSeconds = float64(lastTs-firstTs) / 1000
+	}
+
+	for i := 1; i < len(points); i++ {
+		prev := points[i-1]
+		curr := points[i]
+
+		distance := HaversineDistance(prev.Location, curr.Location)
+		stats.DistanceMeters += distance
+
+		if prev.Timestamp == 0 || curr.Timestamp <= prev.Timestamp {
+			continue
+		}
+
+		seconds := float64(curr.Timestamp-prev.Timestamp) / 1000
+		speedKmh := distance / seconds * 3.6
+		if speedKmh > stats.MaxSpeedKmh {
+			stats.MaxSpeedKmh = speedKmh
+		}
+
+		if speedKmh < config.IdleSpeedKmh {
+			stats.IdleSeconds += seconds
+		} else {
+			stats.MovingSeconds += seconds
+		}
+	}
+	stats.Stops = len(DetectStops(points, config))
+
+	if stats.DurationSeconds > 0 {
+		stats.AverageSpeedKmh = stats.DistanceMeters / stats.DurationSeconds * 3.6
+	}
+
+	return stats
+}
+
+// DetectStops returns the stops of a trip: idle stretches, below the idle
+// speed, that last at least the minimum stop duration. Each stop is located
+// at the point where the vehicle became idle.
+func DetectStops(points []types.TrackPoint, config types.TripStatsConfig) []types.Stop {
+	var stops []types.Stop
+
+	idleStart := -1
+	idleStreak := 0.0
+	endStop := func(end types.TrackPoint) {
+		if idleStart >= 0 && idleStreak >= config.MinStopSeconds {
+			stops = append(stops, types.Stop{
+				Location:        points[idleStart].Location,
+				StartTimestamp:  points[idleStart].Timestamp,
+				EndTimestamp:    end.Timestamp,
+				DurationSeconds: idleStreak,
+			})
+		}
+		idleStart = -1
+		idleStreak = 0
+	}
+
+	for i := 1; i < len(points); i++ {
+		prev := points[i-1]
+		curr := points[i]
+
+		if prev.Timestamp == 0 || curr.Timestamp <= prev.Timestamp {
+			continue
+		}
+
+		seconds := float64(curr.Timestamp-prev.Timestamp) / 1000
+		speedKmh := HaversineDistance(prev.Location, curr.Location) / seconds * 3.6
+
+		if speedKmh < config.IdleSpeedKmh {
+			if idleStart < 0 {
+				idleStart = i - 1
+			}
+			idleStreak += seconds
+			continue
+		}
+
+		endStop(prev)
+	}
+
+	// A trip that ends while stationary still has its final stop
+	if len(points) > 0 {
+		endStop(points[len(points)-1])
+	}
+
+	return stops
+}
@@ -0,0 +1,107 @@
+package algorithm
+
+import (
+	"math"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+var testTripStatsConfig = types.TripStatsConfig{
+	IdleSpeedKmh:   3,
+	MinStopSeconds: 30,
+}
+
+func TestComputeTripStats_TooFewPoints(t *testing.T) {
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.0}, Timestamp: 1000},
+	}
+
+	stats := ComputeTripStats(points, testTripStatsConfig)
+	if stats != (types.TripStats{}) {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+}
+
+func TestComputeTripStats_MovingAndIdle(t *testing.T) {
+	// 0.001 degrees of longitude at the equator is about 111 meters
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.000}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}, Timestamp: 1640995210000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}, Timestamp: 1640995270000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.002}, Timestamp: 1640995280000},
+	}
+
+	stats := ComputeTripStats(points, testTripStatsConfig)
+
+	if stats.DurationSeconds != 80 {
+		t.Errorf("Expected duration 80s, got %f", stats.DurationSeconds)
+	}
+	if stats.MovingSeconds != 20 {
+		t.Errorf("Expected 20s moving, got %f", stats.MovingSeconds)
+	}
+	if stats.IdleSeconds != 60 {
+		t.Errorf("Expected 60s idle, got %f", stats.IdleSeconds)
+	}
+	if stats.Stops != 1 {
+		t.Errorf("Expected 1 stop, got %d", stats.Stops)
+	}
+	if math.Abs(stats.DistanceMeters-222.4) > 1 {
+		t.Errorf("Expected distance close to 222.4m, got %f", stats.DistanceMeters)
+	}
+	// 111 meters in 10 seconds is roughly 40 km/h
+	if math.Abs(stats.MaxSpeedKmh-40.0) > 0.5 {
+		t.Errorf("Expected max speed close to 40 km/h, got %f", stats.MaxSpeedKmh)
+	}
+	expectedAverage := stats.DistanceMeters / 80 * 3.6
+	if stats.AverageSpeedKmh != expectedAverage {
+		t.Errorf("Expected average speed %f, got %f", expectedAverage, stats.AverageSpeedKmh)
+	}
+}
+
+func TestComputeTripStats_MissingTimestamps(t *testing.T) {
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.000}},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}},
+	}
+
+	stats := ComputeTripStats(points, testTripStatsConfig)
+
+	if stats.DurationSeconds != 0 || stats.MaxSpeedKmh != 0 {
+		t.Errorf("Expected no timing stats without timestamps, got %+v", stats)
+	}
+	if stats.DistanceMeters == 0 {
+		t.Errorf("Expected distance to be computed without timestamps")
+	}
+}
+
+func TestDetectStops(t *testing.T) {
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.000}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}, Timestamp: 1640995210000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.001}, Timestamp: 1640995270000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.002}, Timestamp: 1640995280000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.002}, Timestamp: 1640995290000},
+		{Location: types.Location{Latitude: 0.0, Longitude: 0.002}, Timestamp: 1640995330000},
+	}
+
+	stops := DetectStops(points, testTripStatsConfig)
+
+	if len(stops) != ComputeTripStats(points, testTripStatsConfig).Stops {
+		t.Fatalf("Expected DetectStops to agree with ComputeTripStats, got %d stops", len(stops))
+	}
+	if len(stops) != 2 {
+		t.Fatalf("Expected 2 stops, got %d", len(stops))
+	}
+
+	first := stops[0]
+	if first.Location != points[1].Location || first.StartTimestamp != points[1].Timestamp || first.EndTimestamp != points[2].Timestamp {
+		t.Errorf("Unexpected first stop %+v", first)
+	}
+	if first.DurationSeconds != 60 {
+		t.Errorf("Expected first stop to last 60s, got %f", first.DurationSeconds)
+	}
+	if last := stops[1]; last.EndTimestamp != points[5].Timestamp || last.DurationSeconds != 50 {
+		t.Errorf("Unexpected final stop %+v", last)
+	}
+}
@@ -0,0 +1,114 @@
+package algorithm
+
+import (
+	"container/heap"
+	"math"
+)
+
+// vwPoint is a route point in the Visvalingam-Whyatt linked list
+type vwPoint struct {
+	index      int
+	area       float64
+	prev, next int
+	heapIndex  int
+	removed    bool
+}
+
+// vwHeap orders the interior points by their effective area
+type vwHeap []*vwPoint
+
+func (h vwHeap) Len() int { return len(h) }
+
+func (h vwHeap) Less(i, j int) bool {
+	if h[i].area == h[j].area {
+		return h[i].index < h[j].index
+	}
+	return h[i].area < h[j].area
+}
+
+func (h vwHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIndex = i
+	h[j].heapIndex = j
+}
+
+func (h *vwHeap) Push(x interface{}) {
+	p := x.(*vwPoint)
+	p.heapIndex = len(*h)
+	*h = append(*h, p)
+}
+
+func (h *vwHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// visvalingamWhyatt repeatedly removes the point forming the smallest
+// triangle with its neighbours until every remaining triangle covers at
+// least minArea. The first and last points are always kept.
+func visvalingamWhyatt(points []Point, minArea float64) []Point {
+	kept := visvalingamWhyattIndices(points, minArea)
+	result := make([]Point, len(kept))
+	for i, index := range kept {
+		result[i] = points[index]
+	}
+	return result
+}
+
+// visvalingamWhyattIndices returns the indices of the points kept by
+// visvalingamWhyatt
+func visvalingamWhyattIndices(points []Point, minArea float64) []int {
+	if len(points) <= 2 {
+		return allIndices(len(points))
+	}
+
+	nodes := make([]*vwPoint, len(points))
+	for i := range points {
+		nodes[i] = &vwPoint{index: i, prev: i - 1, next: i + 1}
+	}
+
+	h := make(vwHeap, 0, len(points)-2)
+	for i := 1; i < len(points)-1; i++ {
+		nodes[i].area = triangleArea(points[i-1], points[i], points[i+1])
+		heap.Push(&h, nodes[i])
+	}
+
+	for h.Len() > 0 {
+		smallest := h[0]
+		if smallest.area >= minArea {
+			break
+		}
+		heap.Pop(&h)
+		smallest.removed = true
+
+		prev, next := nodes[smallest.prev], nodes[smallest.next]
+		prev.next = next.index
+		next.prev = prev.index
+
+		// Neighbours never drop below the area of the removed point so
+		// removal order stays monotonic
+		for _, neighbour := range []*vwPoint{prev, next} {
+			if neighbour.index == 0 || neighbour.index == len(points)-1 {
+				continue
+			}
+			area := triangleArea(points[neighbour.prev], points[neighbour.index], points[neighbour.next])
+			neighbour.area = math.Max(area, smallest.area)
+			heap.Fix(&h, neighbour.heapIndex)
+		}
+	}
+
+	result := make([]int, 0, h.Len()+2)
+	for i, node := range nodes {
+		if !node.removed {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// triangleArea returns the area of the triangle formed by three points
+func triangleArea(a, b, c Point) float64 {
+	return math.Abs((b.X-a.X)*(c.Y-a.Y)-(c.X-a.X)*(b.Y-a.Y)) / 2
+}
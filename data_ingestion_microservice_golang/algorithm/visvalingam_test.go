@@ -0,0 +1,66 @@
+package algorithm
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestVisvalingamWhyatt_StraightLine(t *testing.T) {
+	simplifier := NewRouteSimplifier(0.001)
+	if err := simplifier.SetAlgorithm(AlgorithmVisvalingamWhyatt); err != nil {
+		t.Fatalf("Failed to select algorithm: %v", err)
+	}
+
+	locations := []types.Location{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 2, Longitude: 2},
+		{Latitude: 3, Longitude: 3},
+	}
+
+	result, err := simplifier.SimplifyRoute(locations)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 points for a straight line, got %d", len(result))
+	}
+	if result[0] != locations[0] || result[1] != locations[3] {
+		t.Errorf("Expected endpoints to be kept, got %v", result)
+	}
+}
+
+func TestVisvalingamWhyatt_KeepsSignificantPoints(t *testing.T) {
+	points := []Point{
+		{X: 0, Y: 0},
+		{X: 1, Y: 0},
+		{X: 2, Y: 0.0001},
+		{X: 3, Y: 0},
+		{X: 4, Y: 3},
+		{X: 5, Y: 0},
+	}
+
+	result := visvalingamWhyatt(points, 0.5)
+
+	want := []Point{{X: 0, Y: 0}, {X: 3, Y: 0}, {X: 4, Y: 3}, {X: 5, Y: 0}}
+	if len(result) != len(want) {
+		t.Fatalf("Expected %d points, got %d: %v", len(want), len(result), result)
+	}
+	for i := range want {
+		if result[i] != want[i] {
+			t.Errorf("Point %d: expected %v, got %v", i, want[i], result[i])
+		}
+	}
+}
+
+func TestSetAlgorithm_RejectsUnknown(t *testing.T) {
+	simplifier := NewRouteSimplifier(0.001)
+
+	if err := simplifier.SetAlgorithm("bogus"); err == nil {
+		t.Fatal("Expected an error for an unknown algorithm")
+	}
+	if simplifier.GetAlgorithm() != AlgorithmDouglasPeucker {
+		t.Errorf("Expected algorithm to stay %s, got %s", AlgorithmDouglasPeucker, simplifier.GetAlgorithm())
+	}
+}
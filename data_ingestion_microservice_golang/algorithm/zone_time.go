@@ -0,0 +1,54 @@
+package algorithm
+
+import (
+	"slices"
+
+	"data-ingestion-microservice/types"
+)
+
+// AccountZoneTime returns the time a trip spent in each zone, in the order
+// the zones were first visited. zonesOf returns the IDs of the zones
+// containing a location. An interval between two timestamped points counts
+// toward the zones containing both ends, and half of it toward the zones
+// containing one end, as the vehicle crossed their boundary somewhere in
+// between. Intervals longer than maxGapSeconds are not counted. A visit
+// starts at every point inside a zone the previous point was outside of.
+func AccountZoneTime(points []types.TrackPoint, maxGapSeconds float64, zonesOf func(types.Location) []string) []types.ZoneTime {
+	var times []types.ZoneTime
+	account := func(id string) *types.ZoneTime {
+		i := slices.IndexFunc(times, func(t types.ZoneTime) bool { return t.ZoneID == id })
+		if i < 0 {
+			times = append(times, types.ZoneTime{ZoneID: id})
+			i = len(times) - 1
+		}
+		return &times[i]
+	}
+
+	var prev types.TrackPoint
+	var prevZones []string
+	for _, point := range points {
+		if point.Timestamp == 0 {
+			continue
+		}
+		zones := zonesOf(point.Location)
+		for _, id := range zones {
+			if !slices.Contains(prevZones, id) {
+				account(id).Visits++
+			}
+		}
+
+		if prev.Timestamp != 0 && point.Timestamp > prev.Timestamp {
+			seconds := float64(point.Timestamp-prev.Timestamp) / 1000
+			if seconds <= maxGapSeconds {
+				for _, id := range prevZones {
+					account(id).Seconds += seconds / 2
+				}
+				for _, id := range zones {
+					account(id).Seconds += seconds / 2
+				}
+			}
+		}
+		prev, prevZones = point, zones
+	}
+	return times
+}
@@ -0,0 +1,46 @@
+package algorithm
+
+import (
+	"reflect"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestAccountZoneTime(t *testing.T) {
+	// The depot covers longitudes up to 0.01, downtown 0.02 to 0.04
+	zonesOf := func(location types.Location) []string {
+		switch {
+		case location.Longitude <= 0.01:
+			return []string{"depot"}
+		case location.Longitude >= 0.02 && location.Longitude <= 0.04:
+			return []string{"downtown"}
+		}
+		return nil
+	}
+	point := func(longitude float64, seconds uint64) types.TrackPoint {
+		return types.TrackPoint{Location: types.Location{Longitude: longitude}, Timestamp: 1640995200000 + seconds*1000}
+	}
+	points := []types.TrackPoint{
+		// 5 minutes in the depot, leaving it halfway through a minute
+		point(0, 0), point(0.005, 300), point(0.015, 360),
+		// Entering downtown halfway through a minute, then 2 minutes in it
+		point(0.03, 420), point(0.035, 540),
+		// A point without a timestamp, then leaving, an hour-long gap, and coming back
+		{Location: types.Location{Longitude: 0.035}},
+		point(0.05, 600), point(0.03, 4200), point(0.03, 4260),
+	}
+
+	got := AccountZoneTime(points, 300, zonesOf)
+	want := []types.ZoneTime{
+		{ZoneID: "depot", Seconds: 330, Visits: 1},
+		{ZoneID: "downtown", Seconds: 240, Visits: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if got := AccountZoneTime(points[2:3], 300, zonesOf); got != nil {
+		t.Errorf("Expected no zone time outside the zones, got %+v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// simplificationRequest is the body of PUT /admin/simplification. Omitted
+// fields keep their current value.
+type simplificationRequest struct {
+	Tolerance float64 `json:"tolerance"`
+	Algorithm string  `json:"algorithm"`
+}
+
+// handleGetSimplification returns the simplification settings in effect
+func (s *Server) handleGetSimplification(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.service.SimplificationSettings())
+}
+
+// handleFailures returns the processing failure counts and the most recent
+// sampled failures
+func (s *Server) handleFailures(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.service.RecentFailures())
+}
+
+// handleClusterStatus returns the live instances of the cluster
+func (s *Server) handleClusterStatus(w http.ResponseWriter, r *http.Request) {
+	cluster, err := s.service.ClusterStatus(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading cluster status", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read cluster status")
+		return
+	}
+	writeJSON(w, http.StatusOK, cluster)
+}
+
+// handleUpdateSimplification changes the simplification tolerance and
+// algorithm for all future trips
+func (s *Server) handleUpdateSimplification(w http.ResponseWriter, r *http.Request) {
+	var req simplificationRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	settings, err := s.service.UpdateSimplification(r.Context(), types.SimplificationSettings{
+		Tolerance: req.Tolerance,
+		Algorithm: req.Algorithm,
+	}, requestActor(r))
+	if errors.Is(err, service.ErrInvalidSimplification) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating simplification settings", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to update simplification settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// handleGetRuntimeSettings returns the runtime settings in effect
+func (s *Server) handleGetRuntimeSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.service.RuntimeSettings())
+}
+
+// handleUpdateRuntimeSettings replaces the runtime settings of every
+// instance. Omitted fields fall back to the configuration.
+func (s *Server) handleUpdateRuntimeSettings(w http.ResponseWriter, r *http.Request) {
+	var req types.RuntimeSettings
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	settings, err := s.service.UpdateRuntimeSettings(r.Context(), req, requestActor(r))
+	switch {
+	case errors.Is(err, service.ErrInvalidRuntimeSettings):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, service.ErrRuntimeSettingsUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error updating runtime settings", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to update runtime settings")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// handleAuditLog returns the recorded administrative actions, newest first.
+// Older entries are paged by passing the timestamp of the last entry as to.
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := types.AuditQuery{
+		Action: params.Get("action"),
+		Actor:  params.Get("actor"),
+		Target: params.Get("target"),
+	}
+
+	var err error
+	if query.From, err = parseMillis(params.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if query.To, err = parseMillis(params.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if query.Limit, err = s.parseLimit(params.Get("limit")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.service.AuditLog(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrAuditLogUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error reading audit log", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read audit log")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
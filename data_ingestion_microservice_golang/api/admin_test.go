@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+func adminRequest(t *testing.T, svc Service, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/admin/simplification", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	NewServer(types.HTTPConfig{AdminToken: "secret"}, svc).Handler().ServeHTTP(recorder, req)
+	return recorder
+}
+
+func adminGet(svc Service, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	NewServer(types.HTTPConfig{AdminToken: "secret", DefaultPageSize: 100, MaxPageSize: 1000}, svc).Handler().ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestUpdateSimplification(t *testing.T) {
+	svc := &fakeService{simplification: types.SimplificationSettings{Tolerance: 0.0001, Algorithm: "douglas-peucker"}}
+
+	recorder := adminRequest(t, svc, "secret", `{"tolerance":0.0005,"algorithm":"visvalingam-whyatt"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if svc.simplification.Tolerance != 0.0005 || svc.simplification.Algorithm != "visvalingam-whyatt" {
+		t.Errorf("Expected settings to be updated, got %+v", svc.simplification)
+	}
+}
+
+func TestUpdateSimplification_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		body      string
+		updateErr error
+		want      int
+	}{
+		{"missing token", "", `{"tolerance":0.0005}`, nil, http.StatusUnauthorized},
+		{"wrong token", "guess", `{"tolerance":0.0005}`, nil, http.StatusUnauthorized},
+		{"unknown field", "secret", `{"epsilon":0.0005}`, nil, http.StatusBadRequest},
+		{"invalid settings", "secret", `{"tolerance":-1}`, fmt.Errorf("%w: tolerance must be positive", service.ErrInvalidSimplification), http.StatusBadRequest},
+		{"store failure", "secret", `{"tolerance":0.0005}`, fmt.Errorf("mongo unavailable"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{updateErr: tt.updateErr}
+			recorder := adminRequest(t, svc, tt.token, tt.body)
+			if recorder.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesDisabledWithoutToken(t *testing.T) {
+	recorder := serve(t, &fakeService{}, http.MethodGet, "/admin/simplification")
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	svc := &fakeService{failures: types.FailureReport{
+		Counts:   map[string]int64{service.FailureDecode: 2},
+		Failures: []types.ProcessingFailure{{Class: service.FailureDecode, Error: "failed to unmarshal message"}},
+	}}
+	if recorder := adminGet(svc, "/admin/failures", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	recorder := adminGet(svc, "/admin/failures", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+
+	var report types.FailureReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.Counts[service.FailureDecode] != 2 || len(report.Failures) != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
+
+func TestClusterStatus(t *testing.T) {
+	svc := &fakeService{cluster: types.ClusterStatus{
+		Instance:     "instance_a",
+		Partitioning: true,
+		Leader:       "instance_b",
+		Instances:    []types.InstanceStatus{{InstanceID: "instance_a", RingShare: 0.5}, {InstanceID: "instance_b", Leader: true, RingShare: 0.5}},
+	}}
+	if recorder := adminGet(svc, "/admin/cluster", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	recorder := adminGet(svc, "/admin/cluster", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var cluster types.ClusterStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&cluster); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if cluster.Leader != "instance_b" || len(cluster.Instances) != 2 {
+		t.Errorf("Unexpected cluster status %+v", cluster)
+	}
+
+	svc.tripErr = fmt.Errorf("redis unavailable")
+	if recorder := adminGet(svc, "/admin/cluster", "secret"); recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	svc := &fakeService{audit: []types.AuditEntry{{Action: service.AuditActionDeleteDriverData, Actor: "admin", Target: "driver_001"}}}
+
+	recorder := adminGet(svc, "/admin/audit?action=driver_data.delete&target=driver_001&from=1700000000000&limit=5", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.AuditQuery{Action: service.AuditActionDeleteDriverData, Target: "driver_001", From: 1700000000000, Limit: 5}
+	if svc.auditQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.auditQuery)
+	}
+
+	var page types.AuditLogPage
+	if err := json.NewDecoder(recorder.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].Target != "driver_001" {
+		t.Errorf("Unexpected page %+v", page)
+	}
+}
+
+func TestAuditLog_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		err    error
+		want   int
+	}{
+		{"missing token", "/admin/audit", "", nil, http.StatusUnauthorized},
+		{"invalid from", "/admin/audit?from=yesterday", "secret", nil, http.StatusBadRequest},
+		{"invalid limit", "/admin/audit?limit=0", "secret", nil, http.StatusBadRequest},
+		{"unsupported", "/admin/audit", "secret", service.ErrAuditLogUnsupported, http.StatusNotImplemented},
+		{"store failure", "/admin/audit", "secret", fmt.Errorf("mongo unavailable"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := adminGet(&fakeService{tripErr: tt.err}, tt.target, tt.token)
+			if recorder.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateRuntimeSettings(t *testing.T) {
+	svc := &fakeService{}
+	req := httptest.NewRequest(http.MethodPut, "/admin/runtime-settings", strings.NewReader(`{"tolerance":0.0005,"pausedTopics":["drivers_location/route-7/#"]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	NewServer(types.HTTPConfig{AdminToken: "secret"}, svc).Handler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if svc.runtime.Tolerance != 0.0005 || len(svc.runtime.PausedTopics) != 1 {
+		t.Errorf("Expected settings to be replaced, got %+v", svc.runtime)
+	}
+
+	for _, updateErr := range []error{service.ErrRuntimeSettingsUnsupported, fmt.Errorf("%w: invalid topic filter", service.ErrInvalidRuntimeSettings)} {
+		svc := &fakeService{updateErr: updateErr}
+		req := httptest.NewRequest(http.MethodPut, "/admin/runtime-settings", strings.NewReader(`{"pausedTopics":["a/#/b"]}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+		NewServer(types.HTTPConfig{AdminToken: "secret"}, svc).Handler().ServeHTTP(recorder, req)
+		want := http.StatusNotImplemented
+		if errors.Is(updateErr, service.ErrInvalidRuntimeSettings) {
+			want = http.StatusBadRequest
+		}
+		if recorder.Code != want {
+			t.Errorf("Expected status %d for %v, got %d", want, updateErr, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// handleAssignments returns the vehicle assignments of a driver or vehicle,
+// newest first
+func (s *Server) handleAssignments(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := types.AssignmentQuery{
+		DriverID:  params.Get("driverId"),
+		VehicleID: params.Get("vehicleId"),
+	}
+	if query.DriverID == "" && query.VehicleID == "" {
+		writeError(w, http.StatusBadRequest, "driverId or vehicleId is required")
+		return
+	}
+
+	var err error
+	if query.From, err = parseMillis(params.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if query.To, err = parseMillis(params.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if query.Limit, err = s.parseLimit(params.Get("limit")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.service.Assignments(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrAssignmentsUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error reading vehicle assignments", "driverId", query.DriverID, "vehicleId", query.VehicleID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read vehicle assignments")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
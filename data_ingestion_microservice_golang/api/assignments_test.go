@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestAssignments(t *testing.T) {
+	startedAt := time.UnixMilli(1500).UTC()
+	svc := &fakeService{assignments: []types.VehicleAssignment{{ID: "driver_001:bus_7:1500", DriverID: "driver_001", VehicleID: "bus_7", StartedAt: startedAt}}}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/assignments?vehicleId=bus_7&from=1000&to=2000&limit=5")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.AssignmentQuery{VehicleID: "bus_7", From: 1000, To: 2000, Limit: 5}
+	if svc.assignQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.assignQuery)
+	}
+
+	var page types.AssignmentPage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Assignments) != 1 || page.Assignments[0].DriverID != "driver_001" || page.Assignments[0].EndedAt != nil {
+		t.Errorf("Unexpected assignments %+v", page)
+	}
+}
+
+func TestAssignments_Errors(t *testing.T) {
+	svc := &fakeService{assignments: []types.VehicleAssignment{}}
+	for _, target := range []string{"/v1/assignments", "/v1/assignments?driverId=driver_001&limit=-1"} {
+		if recorder := serve(t, svc, http.MethodGet, target); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, recorder.Code)
+		}
+	}
+
+	recorder := serve(t, &fakeService{}, http.MethodGet, "/v1/assignments?driverId=driver_001")
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without assignment storage, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+}
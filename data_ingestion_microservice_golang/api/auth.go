@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"data-ingestion-microservice/auth"
+)
+
+// Authenticator validates the JWT bearer tokens of API clients
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (auth.Claims, error)
+}
+
+// claimsKey is the context key of the claims of a request authenticated
+// with a JWT
+type claimsKey struct{}
+
+// requiredScope returns the JWT scope a route requires, or "" for the
+// health probes, which stay public. Admin routes require admin:config and
+// every other route read:trips unless the route names its own scope.
+func (rt route) requiredScope() string {
+	switch {
+	case rt.scope != "":
+		return rt.scope
+	case rt.tag == "health":
+		return ""
+	case rt.admin:
+		return auth.ScopeAdminConfig
+	}
+	return auth.ScopeReadTrips
+}
+
+// authorize wraps a route handler with its authentication. Admin routes
+// accept the admin token or a JWT with their scope; once JWT
+// authentication is enabled, every other non-public route requires a JWT
+// with its scope. Browsers cannot set headers on WebSocket and
+// EventSource requests, so streams also read the token from the
+// access_token query parameter.
+func (s *Server) authorize(rt route) http.Handler {
+	scope := rt.requiredScope()
+	if !rt.admin && (s.auth == nil || scope == "") {
+		return rt.handler
+	}
+	stream := rt.websocket || slices.Contains(rt.produces, "text/event-stream")
+	return s.requireToken(scope, rt.admin, stream, rt.handler)
+}
+
+// requireAdmin rejects requests without the admin token or a JWT with the
+// admin:config scope
+func (s *Server) requireAdmin(next http.HandlerFunc) http.Handler {
+	return s.requireToken(auth.ScopeAdminConfig, true, false, next)
+}
+
+// requireToken rejects requests without a valid bearer token granting the
+// scope, with 401 for missing and invalid tokens, 403 for tokens without
+// the scope, and 503 when the signing keys cannot be fetched. The admin
+// token is accepted in place of a JWT when admin is set. Streams read the
+// token from the access_token query parameter too, and their request
+// context ends with auth.ErrTokenExpired as its cause when the JWT expires.
+func (s *Server) requireToken(scope string, admin, stream bool, next http.HandlerFunc) http.Handler {
+	adminToken := []byte(s.config.AdminToken)
+	realm := "api"
+	if admin {
+		realm = "admin"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r, stream)
+		if admin && len(adminToken) > 0 && subtle.ConstantTimeCompare([]byte(token), adminToken) == 1 {
+			next(w, r)
+			return
+		}
+		if s.auth == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", realm))
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		claims, err := s.auth.Authenticate(r.Context(), token)
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			slog.DebugContext(r.Context(), "Rejected bearer token", "error", err)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="invalid_token"`, realm))
+			writeError(w, http.StatusUnauthorized, "invalid bearer token")
+			return
+		case err != nil:
+			slog.ErrorContext(r.Context(), "Error authenticating request", "error", err)
+			writeError(w, http.StatusServiceUnavailable, "authentication is unavailable")
+			return
+		case !claims.HasScope(scope):
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope", scope=%q`, realm, scope))
+			writeError(w, http.StatusForbidden, "token lacks the "+scope+" scope")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsKey{}, claims)
+		if stream && !claims.ExpiresAt.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadlineCause(ctx, claims.ExpiresAt, auth.ErrTokenExpired)
+			defer cancel()
+		}
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// bearerToken returns the bearer token of the Authorization header, or of
+// the access_token query parameter when fromQuery is set
+func bearerToken(r *http.Request, fromQuery bool) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if fromQuery {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// requestActor identifies who made an administrative request in the audit
+// log: the subject of its JWT, or adminActor for the admin token
+func requestActor(r *http.Request) string {
+	if claims, ok := r.Context().Value(claimsKey{}).(auth.Claims); ok && claims.Subject != "" {
+		return claims.Subject
+	}
+	return adminActor
+}
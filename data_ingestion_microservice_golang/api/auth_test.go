@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/auth"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeAuthenticator accepts the tokens it knows, and fails every token
+// with err when it is set
+type fakeAuthenticator struct {
+	tokens map[string]auth.Claims
+	err    error
+}
+
+func (f fakeAuthenticator) Authenticate(ctx context.Context, token string) (auth.Claims, error) {
+	if f.err != nil {
+		return auth.Claims{}, f.err
+	}
+	claims, ok := f.tokens[token]
+	if !ok {
+		return auth.Claims{}, fmt.Errorf("%w: unknown token", auth.ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+var testAuthenticator = fakeAuthenticator{tokens: map[string]auth.Claims{
+	"reader":  {Subject: "dashboard", Scopes: []string{auth.ScopeReadTrips}},
+	"admin":   {Subject: "ops@example.com", Scopes: []string{auth.ScopeAdminConfig}},
+	"deleter": {Subject: "privacy@example.com", Scopes: []string{auth.ScopeDeleteData}},
+}}
+
+func authRequest(svc Service, authenticator Authenticator, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	config := types.HTTPConfig{AdminToken: "secret", DefaultPageSize: 100, MaxPageSize: 1000}
+	NewServerWithAuth(config, svc, authenticator).Handler().ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAuth_RequiresScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		token     string
+		expected  int
+		challenge string
+	}{
+		{"public health probe", http.MethodGet, "/healthz", "", http.StatusOK, ""},
+		{"missing token", http.MethodGet, "/v1/trips", "", http.StatusUnauthorized, `Bearer realm="api"`},
+		{"invalid token", http.MethodGet, "/v1/trips", "forged", http.StatusUnauthorized, `Bearer realm="api", error="invalid_token"`},
+		{"read scope", http.MethodGet, "/v1/trips", "reader", http.StatusOK, ""},
+		{"missing read scope", http.MethodGet, "/v1/trips", "admin", http.StatusForbidden, `Bearer realm="api", error="insufficient_scope", scope="read:trips"`},
+		{"admin scope", http.MethodGet, "/admin/failures", "admin", http.StatusOK, ""},
+		{"missing admin scope", http.MethodGet, "/admin/failures", "reader", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope", scope="admin:config"`},
+		{"admin token", http.MethodGet, "/admin/failures", "secret", http.StatusOK, ""},
+		{"delete scope", http.MethodDelete, "/v1/drivers/driver_001/data", "deleter", http.StatusOK, ""},
+		{"admin scope cannot delete", http.MethodDelete, "/v1/drivers/driver_001/data", "admin", http.StatusForbidden, `Bearer realm="admin", error="insufficient_scope", scope="delete:data"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := authRequest(&fakeService{}, testAuthenticator, tt.method, tt.target, tt.token)
+			if recorder.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, recorder.Code, recorder.Body.String())
+			}
+			if challenge := recorder.Header().Get("WWW-Authenticate"); challenge != tt.challenge {
+				t.Errorf("Expected challenge %q, got %q", tt.challenge, challenge)
+			}
+		})
+	}
+}
+
+func TestAuth_AuditsTokenSubject(t *testing.T) {
+	svc := &fakeService{}
+
+	if recorder := authRequest(svc, testAuthenticator, http.MethodDelete, "/v1/drivers/driver_001/data", "deleter"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if svc.deleteActor != "privacy@example.com" {
+		t.Errorf("Expected the deletion to be audited as the token subject, got %q", svc.deleteActor)
+	}
+}
+
+func TestAuth_UnavailableKeys(t *testing.T) {
+	authenticator := fakeAuthenticator{err: errors.New("no signing keys: connection refused")}
+
+	if recorder := authRequest(&fakeService{}, authenticator, http.MethodGet, "/v1/trips", "reader"); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestAuth_WebSocketQueryToken(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	server := httptest.NewServer(NewServerWithAuth(types.HTTPConfig{}, svc, testAuthenticator).Handler())
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/live"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected the upgrade to be refused with status %d, got %v", http.StatusUnauthorized, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?access_token=reader", nil)
+	if err != nil {
+		t.Fatalf("Expected the access_token parameter to authenticate the upgrade, got %v", err)
+	}
+	conn.Close()
+
+	// Other routes only read the Authorization header
+	if recorder := authRequest(&fakeService{}, testAuthenticator, http.MethodGet, "/v1/trips?access_token=reader", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestAuth_ClosesStreamsWhenTokenExpires(t *testing.T) {
+	authenticator := fakeAuthenticator{tokens: map[string]auth.Claims{
+		"expiring": {Subject: "dashboard", Scopes: []string{auth.ScopeReadTrips}, ExpiresAt: time.Now().Add(200 * time.Millisecond)},
+	}}
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	server := httptest.NewServer(NewServerWithAuth(types.HTTPConfig{StreamWriteTimeout: time.Second}, svc, authenticator).Handler())
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/live?access_token=expiring"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Expected the upgrade to succeed, got %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("Expected a policy violation close once the token expires, got %v", err)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/v1/events?access_token=expiring")
+	if err != nil {
+		t.Fatalf("Expected the event stream to open, got %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("Expected the event stream to end once the token expires, got %v", err)
+	}
+}
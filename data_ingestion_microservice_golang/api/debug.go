@@ -0,0 +1,20 @@
+package api
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerDebug serves the runtime profiles of net/http/pprof and the
+// expvar metrics under /debug. Profiles expose internals and cost CPU, so
+// every debug endpoint requires the admin token or the admin:config scope.
+func (s *Server) registerDebug(mux *http.ServeMux) {
+	// The index also serves the named profiles, such as heap and goroutine
+	mux.Handle("/debug/pprof/", s.requireAdmin(pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", s.requireAdmin(pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", s.requireAdmin(pprof.Profile))
+	mux.Handle("/debug/pprof/symbol", s.requireAdmin(pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", s.requireAdmin(pprof.Trace))
+	mux.Handle("GET /debug/vars", s.requireAdmin(expvar.Handler().ServeHTTP))
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func debugRequest(config types.HTTPConfig, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	NewServer(config, &fakeService{}).Handler().ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	config := types.HTTPConfig{AdminToken: "secret", DebugEnabled: true}
+
+	if recorder := debugRequest(config, "/debug/vars", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+	if recorder := debugRequest(config, "/debug/pprof/heap", "wrong"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d with a wrong token, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	recorder := debugRequest(config, "/debug/vars", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	for _, name := range []string{"finalization_queue_depth", "goroutines", "heap_inuse_bytes", "uptime_seconds"} {
+		if !strings.Contains(recorder.Body.String(), `"`+name+`"`) {
+			t.Errorf("Expected /debug/vars to include %s", name)
+		}
+	}
+
+	if recorder := debugRequest(config, "/debug/pprof/heap", "secret"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected a heap profile, got status %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestDebugEndpoints_Disabled(t *testing.T) {
+	for _, config := range []types.HTTPConfig{
+		{AdminToken: "secret"},
+		{DebugEnabled: true},
+	} {
+		for _, target := range []string{"/debug/vars", "/debug/pprof/"} {
+			if recorder := debugRequest(config, target, "secret"); recorder.Code != http.StatusNotFound {
+				t.Errorf("Expected status %d for %s with %+v, got %d", http.StatusNotFound, target, config, recorder.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// sseHeartbeatInterval keeps idle event streams open through proxies
+const sseHeartbeatInterval = 15 * time.Second
+
+// handleEvents streams trip_started, location_update, and trip_finished
+// events as Server-Sent Events, optionally limited by routeId, driverId, and
+// geofence parameters. Clients that fall too far behind are disconnected and
+// reconnect through EventSource's automatic retry.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := streamFilterParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sub := s.service.SubscribeEvents(filter)
+	defer sub.Close()
+
+	controller, ok := openEventStream(w)
+	if !ok {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Updates():
+			if !ok {
+				if sub.Dropped() {
+					slog.WarnContext(r.Context(), "Dropped event stream client for falling behind", "remoteAddr", r.RemoteAddr)
+				}
+				return
+			}
+			if err := s.writeEvent(w, controller, event.Type, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := s.writeHeartbeat(w, controller); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes one named event with a JSON body in the
+// text/event-stream format
+func (s *Server) writeEvent(w http.ResponseWriter, controller *http.ResponseController, name string, event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	controller.SetWriteDeadline(time.Now().Add(s.config.StreamWriteTimeout))
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return controller.Flush()
+}
+
+// writeHeartbeat writes a comment that keeps an idle event stream open
+func (s *Server) writeHeartbeat(w http.ResponseWriter, controller *http.ResponseController) error {
+	controller.SetWriteDeadline(time.Now().Add(s.config.StreamWriteTimeout))
+	if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	return controller.Flush()
+}
+
+// openEventStream sends the headers of a text/event-stream response and
+// reports whether the response writer supports streaming
+func openEventStream(w http.ResponseWriter) (*http.ResponseController, bool) {
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		slog.Error("Event stream unsupported by the response writer", "error", err)
+		return nil, false
+	}
+	return controller, true
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+func TestEvents_StreamsServerSentEvents(t *testing.T) {
+	svc := &fakeService{events: service.NewFleetEvents(0, 8)}
+	server := httptest.NewServer(NewServer(types.HTTPConfig{}, svc).Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/events?routeId=route_1")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", contentType)
+	}
+
+	svc.events.Location(context.Background(), "route:driver_001:route_2", types.BusMessage{DriverID: "driver_001", CurrentRouteID: "route_2"}, time.Now())
+	svc.events.Location(context.Background(), "route:driver_002:route_1", types.BusMessage{DriverID: "driver_002", CurrentRouteID: "route_1"}, time.Now())
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var event []string
+	timeout := time.After(5 * time.Second)
+	for len(event) < 2 {
+		select {
+		case line := <-lines:
+			event = append(event, line)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for an event, got %v", event)
+		}
+	}
+
+	if event[0] != "event: trip_started" {
+		t.Errorf("Expected a trip_started event, got %q", event[0])
+	}
+	if !strings.HasPrefix(event[1], "data: ") || !strings.Contains(event[1], `"driverId":"driver_002"`) {
+		t.Errorf("Expected the route_1 event data, got %q", event[1])
+	}
+}
+
+func TestEvents_RejectsInvalidGeofence(t *testing.T) {
+	svc := &fakeService{events: service.NewFleetEvents(0, 8)}
+	if recorder := serve(t, svc, http.MethodGet, "/v1/events?geofence=6,-76,7,-75"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", recorder.Code)
+	}
+}
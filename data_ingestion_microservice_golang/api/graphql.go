@@ -0,0 +1,395 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/graph"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Query limits used when none are configured
+const (
+	defaultGraphQLMaxDepth      = 10
+	defaultGraphQLMaxComplexity = 10000
+)
+
+// Estimated list sizes counted towards the query complexity
+const (
+	routePointsEstimate  = 50
+	tripStopsEstimate    = 10
+	routeDriversEstimate = 50
+	statsGroupsEstimate  = 30
+)
+
+// graphQLRequest is a GraphQL request as sent over HTTP
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLResponse documents the response of a GraphQL request. Data is
+// null when the request was rejected before execution.
+type graphQLResponse struct {
+	Data   interface{}         `json:"data,omitempty"`
+	Errors []graphQLFieldError `json:"errors,omitempty"`
+}
+
+// graphQLFieldError is a request error, or a field error with the path of
+// the field
+type graphQLFieldError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// handleGraphQL executes a GraphQL query sent as a JSON POST body or as
+// query, operationName, and variables URL parameters
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	start := graphql.Now()
+
+	var req graphQLRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, graphQLFailure("invalid request body: "+err.Error()))
+			return
+		}
+	} else {
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if variables := params.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeJSON(w, http.StatusBadRequest, graphQLFailure("invalid variables: "+err.Error()))
+				return
+			}
+		}
+	}
+
+	// Syntax, validation, depth, and complexity errors reject the request
+	// before anything is resolved
+	ctx := graphql.StartOperationTrace(r.Context())
+	opCtx, errs := s.graphQL.CreateOperationContext(ctx, &graphql.RawParams{
+		Query:         req.Query,
+		OperationName: req.OperationName,
+		Variables:     req.Variables,
+		ReadTime:      graphql.TraceTiming{Start: start, End: graphql.Now()},
+	})
+	if errs != nil {
+		writeJSON(w, http.StatusBadRequest, s.graphQL.DispatchError(graphql.WithOperationContext(ctx, opCtx), errs))
+		return
+	}
+
+	responses, ctx := s.graphQL.DispatchOperation(ctx, opCtx)
+	writeJSON(w, http.StatusOK, responses(ctx))
+}
+
+// handleGraphQLSchema returns the GraphQL schema in SDL
+func (s *Server) handleGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(graph.SDL))
+}
+
+// graphQLFailure is the response of a request that could not be read
+func graphQLFailure(message string) *graphql.Response {
+	return &graphql.Response{Errors: gqlerror.List{{Message: message}}}
+}
+
+// graphQLExecutor executes the graph of trips, stops, stats, drivers,
+// routes, and live positions over the service
+func (s *Server) graphQLExecutor() *executor.Executor {
+	var complexity graph.ComplexityRoot
+
+	// Every selected trip field is counted once per trip of the page
+	complexity.Query.Trips = func(child int, driverID, vehicleID, routeID *string, from, to *int64, limit *int, order *graph.Order, cursor *string) int {
+		return s.tripsCost(child, limit)
+	}
+	complexity.Driver.Trips = func(child int, routeID *string, from, to *int64, limit *int, order *graph.Order, cursor *string) int {
+		return s.tripsCost(child, limit)
+	}
+	complexity.Route.Trips = func(child int, driverID *string, from, to *int64, limit *int, order *graph.Order, cursor *string) int {
+		return s.tripsCost(child, limit)
+	}
+	complexity.Trip.SimplifiedRoute = listCost(routePointsEstimate)
+	complexity.Trip.Stops = listCost(tripStopsEstimate)
+	complexity.Route.LivePositions = listCost(routeDriversEstimate)
+	complexity.TripStatsReport.Groups = listCost(statsGroupsEstimate)
+
+	config := graph.Config{Resolvers: &graphQLResolver{server: s}, Complexity: complexity}
+	return graph.NewExecutor(config, s.config.GraphQLMaxDepth, s.config.GraphQLMaxComplexity)
+}
+
+// tripsCost counts a trips field's selections once per trip of the page
+func (s *Server) tripsCost(child int, limit *int) int {
+	n, err := s.tripLimit(limit)
+	if err != nil {
+		return 1
+	}
+	return 1 + n*child
+}
+
+// listCost counts a list field's selections a fixed number of times
+func listCost(n int) func(child int) int {
+	return func(child int) int { return 1 + n*child }
+}
+
+// graphQLResolver resolves the fields of the graph that are not read
+// directly from the Go values
+type graphQLResolver struct {
+	server *Server
+}
+
+type (
+	queryResolver           struct{ *graphQLResolver }
+	tripResolver            struct{ *graphQLResolver }
+	tripPageResolver        struct{ *graphQLResolver }
+	driverResolver          struct{ *graphQLResolver }
+	routeResolver           struct{ *graphQLResolver }
+	livePositionResolver    struct{ *graphQLResolver }
+	routePointResolver      struct{ *graphQLResolver }
+	tripAggregateResolver   struct{ *graphQLResolver }
+	tripStatsReportResolver struct{ *graphQLResolver }
+)
+
+func (r *graphQLResolver) Query() graph.QueryResolver               { return queryResolver{r} }
+func (r *graphQLResolver) Trip() graph.TripResolver                 { return tripResolver{r} }
+func (r *graphQLResolver) TripPage() graph.TripPageResolver         { return tripPageResolver{r} }
+func (r *graphQLResolver) Driver() graph.DriverResolver             { return driverResolver{r} }
+func (r *graphQLResolver) Route() graph.RouteResolver               { return routeResolver{r} }
+func (r *graphQLResolver) LivePosition() graph.LivePositionResolver { return livePositionResolver{r} }
+func (r *graphQLResolver) RoutePoint() graph.RoutePointResolver     { return routePointResolver{r} }
+func (r *graphQLResolver) TripAggregate() graph.TripAggregateResolver {
+	return tripAggregateResolver{r}
+}
+func (r *graphQLResolver) TripStatsReport() graph.TripStatsReportResolver {
+	return tripStatsReportResolver{r}
+}
+
+func (r queryResolver) Trip(ctx context.Context, id string) (*graph.Trip, error) {
+	stored, stops, err := r.server.service.TripWithStops(ctx, id)
+	if errors.Is(err, service.ErrTripNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphQLError(err, "failed to load trip")
+	}
+	return &graph.Trip{StoredTrip: *stored, LoadedStops: stops, StopsLoaded: true}, nil
+}
+
+func (r queryResolver) Trips(ctx context.Context, driverID, vehicleID, routeID *string, from, to *int64, limit *int, order *graph.Order, cursor *string) (*types.TripPage, error) {
+	query := types.TripQuery{DriverID: value(driverID), VehicleID: value(vehicleID), RouteID: value(routeID)}
+	return r.server.findTrips(ctx, query, from, to, limit, order, cursor)
+}
+
+func (r queryResolver) Driver(ctx context.Context, id string) (*graph.Driver, error) {
+	return &graph.Driver{ID: id}, nil
+}
+
+func (r queryResolver) Route(ctx context.Context, id string) (*graph.Route, error) {
+	return &graph.Route{ID: id}, nil
+}
+
+func (r queryResolver) Stats(ctx context.Context, groupBy *graph.StatsGroupBy, from, to *int64) (*types.TripStatsReport, error) {
+	query := types.TripStatsQuery{GroupBy: database.StatsGroupByDay, From: value(from), To: value(to)}
+	if groupBy != nil {
+		query.GroupBy = string(*groupBy)
+	}
+	report, err := r.server.service.TripStats(ctx, query)
+	if err != nil {
+		return nil, graphQLError(err, "failed to aggregate trips")
+	}
+	return &report, nil
+}
+
+func (r tripResolver) VehicleID(ctx context.Context, trip *graph.Trip) (*string, error) {
+	return optional(trip.VehicleID), nil
+}
+
+func (r tripResolver) RouteID(ctx context.Context, trip *graph.Trip) (string, error) {
+	return trip.CurrentRouteID, nil
+}
+
+func (r tripResolver) Driver(ctx context.Context, trip *graph.Trip) (*graph.Driver, error) {
+	return &graph.Driver{ID: trip.DriverID}, nil
+}
+
+func (r tripResolver) Route(ctx context.Context, trip *graph.Trip) (*graph.Route, error) {
+	return &graph.Route{ID: trip.CurrentRouteID}, nil
+}
+
+func (r tripResolver) RawArchiveURL(ctx context.Context, trip *graph.Trip) (*string, error) {
+	return optional(trip.RawArchiveURL), nil
+}
+
+func (r tripResolver) Status(ctx context.Context, trip *graph.Trip) (*string, error) {
+	return optional(trip.StoredTrip.Status), nil
+}
+
+func (r tripResolver) CreatedAt(ctx context.Context, trip *graph.Trip) (string, error) {
+	return trip.StoredTrip.CreatedAt.Format(time.RFC3339), nil
+}
+
+// Stops returns the stops of a trip, loading them when the trip was listed
+// without them
+func (r tripResolver) Stops(ctx context.Context, trip *graph.Trip) ([]types.Stop, error) {
+	if !trip.StopsLoaded {
+		_, stops, err := r.server.service.TripWithStops(ctx, trip.ID)
+		if err != nil {
+			return nil, graphQLError(err, "failed to load trip stops")
+		}
+		trip.LoadedStops, trip.StopsLoaded = stops, true
+	}
+	return trip.LoadedStops, nil
+}
+
+func (r tripPageResolver) Trips(ctx context.Context, page *types.TripPage) ([]graph.Trip, error) {
+	trips := make([]graph.Trip, len(page.Trips))
+	for i := range page.Trips {
+		trips[i] = graph.Trip{StoredTrip: page.Trips[i]}
+	}
+	return trips, nil
+}
+
+func (r tripPageResolver) NextCursor(ctx context.Context, page *types.TripPage) (*string, error) {
+	return optional(page.NextCursor), nil
+}
+
+func (r driverResolver) LivePosition(ctx context.Context, driver *graph.Driver) (*types.LivePosition, error) {
+	position, err := r.server.service.LivePosition(ctx, driver.ID)
+	if errors.Is(err, service.ErrDriverNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphQLError(err, "failed to read live positions")
+	}
+	return position, nil
+}
+
+func (r driverResolver) Trips(ctx context.Context, driver *graph.Driver, routeID *string, from, to *int64, limit *int, order *graph.Order, cursor *string) (*types.TripPage, error) {
+	query := types.TripQuery{DriverID: driver.ID, RouteID: value(routeID)}
+	return r.server.findTrips(ctx, query, from, to, limit, order, cursor)
+}
+
+func (r routeResolver) LivePositions(ctx context.Context, route *graph.Route) ([]types.LivePosition, error) {
+	positions, err := r.server.service.RoutePositions(ctx, route.ID)
+	return positions, graphQLError(err, "failed to read live positions")
+}
+
+func (r routeResolver) Trips(ctx context.Context, route *graph.Route, driverID *string, from, to *int64, limit *int, order *graph.Order, cursor *string) (*types.TripPage, error) {
+	query := types.TripQuery{DriverID: value(driverID), RouteID: route.ID}
+	return r.server.findTrips(ctx, query, from, to, limit, order, cursor)
+}
+
+func (r livePositionResolver) VehicleID(ctx context.Context, position *types.LivePosition) (*string, error) {
+	return optional(position.VehicleID), nil
+}
+
+func (r livePositionResolver) RouteID(ctx context.Context, position *types.LivePosition) (string, error) {
+	return position.CurrentRouteID, nil
+}
+
+func (r livePositionResolver) Driver(ctx context.Context, position *types.LivePosition) (*graph.Driver, error) {
+	return &graph.Driver{ID: position.DriverID}, nil
+}
+
+func (r livePositionResolver) Route(ctx context.Context, position *types.LivePosition) (*graph.Route, error) {
+	return &graph.Route{ID: position.CurrentRouteID}, nil
+}
+
+// Timestamp is null for route points of trips stored without timestamps
+func (r routePointResolver) Timestamp(ctx context.Context, point *types.RoutePoint) (*int64, error) {
+	if point.Timestamp == 0 {
+		return nil, nil
+	}
+	millis := int64(point.Timestamp)
+	return &millis, nil
+}
+
+func (r tripAggregateResolver) Key(ctx context.Context, aggregate *types.TripAggregate) (*string, error) {
+	return optional(aggregate.Key), nil
+}
+
+func (r tripStatsReportResolver) GroupBy(ctx context.Context, report *types.TripStatsReport) (graph.StatsGroupBy, error) {
+	return graph.StatsGroupBy(report.GroupBy), nil
+}
+
+// findTrips reads one page of trips matching the query and the paging
+// arguments of a trips field
+func (s *Server) findTrips(ctx context.Context, query types.TripQuery, from, to *int64, limit *int, order *graph.Order, cursor *string) (*types.TripPage, error) {
+	query.From, query.To = value(from), value(to)
+	query.Cursor = value(cursor)
+	query.Descending = order == nil || *order != graph.OrderAsc
+
+	var err error
+	if query.Limit, err = s.tripLimit(limit); err != nil {
+		return nil, err
+	}
+
+	page, err := s.service.FindTrips(ctx, query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, graphQLError(err, "failed to query trips")
+	}
+	return &page, nil
+}
+
+// tripLimit applies the configured default and maximum page size to the
+// limit argument
+func (s *Server) tripLimit(limit *int) (int, error) {
+	n := s.config.DefaultPageSize
+	if limit != nil {
+		n = *limit
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid limit %d", n)
+	}
+	if s.config.MaxPageSize > 0 && n > s.config.MaxPageSize {
+		n = s.config.MaxPageSize
+	}
+	return n, nil
+}
+
+// value dereferences an optional argument, or returns the zero value when
+// it is omitted
+func value[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// optional maps an empty string to null
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// graphQLError reports expected service errors to the client and hides
+// the details of unexpected ones
+func graphQLError(err error, message string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrTripQueriesUnsupported), errors.Is(err, service.ErrLiveTrackingDisabled):
+		return err
+	default:
+		slog.Error("GraphQL query failed", "message", message, "error", err)
+		return errors.New(message)
+	}
+}
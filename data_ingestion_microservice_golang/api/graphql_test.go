@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+// postGraphQL posts a GraphQL query and decodes the response
+func postGraphQL(t *testing.T, svc Service, config types.HTTPConfig, query string) (int, map[string]interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	NewServer(config, svc).Handler().ServeHTTP(recorder, req)
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return recorder.Code, resp
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	svc := &fakeService{
+		trip: &types.StoredTrip{
+			ID:              "trip_1",
+			DriverID:        "driver_001",
+			CurrentRouteID:  "route_1",
+			SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.2, Longitude: -75.5}}},
+			Stats:           types.TripStats{DistanceMeters: 1200},
+		},
+		stops: []types.Stop{{Location: types.Location{Latitude: 6.2, Longitude: -75.5}, DurationSeconds: 90}},
+		live: []types.LivePosition{
+			{DriverID: "driver_001", CurrentRouteID: "route_1", Status: "in_route", Timestamp: 1700000000000},
+		},
+	}
+	svc.tripPage = types.TripPage{Trips: []types.StoredTrip{*svc.trip}, NextCursor: "next"}
+
+	status, resp := postGraphQL(t, svc, types.HTTPConfig{DefaultPageSize: 10}, `{
+		driver(id: "driver_001") {
+			livePosition { routeId timestamp }
+			trips(limit: 5, order: asc, from: 1700000000000) {
+				trips { id stats { distanceMeters } stops { durationSeconds } }
+				nextCursor
+			}
+		}
+		route(id: "route_1") { livePositions { driverId } }
+		trip(id: "missing") { id }
+	}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, resp)
+	}
+	if resp["errors"] != nil {
+		t.Fatalf("unexpected errors: %v", resp["errors"])
+	}
+
+	got, _ := json.Marshal(resp["data"])
+	want := `{"driver":{"livePosition":{"routeId":"route_1","timestamp":1700000000000},` +
+		`"trips":{"nextCursor":"next","trips":[{"id":"trip_1","stats":{"distanceMeters":1200},"stops":[{"durationSeconds":90}]}]}},` +
+		`"route":{"livePositions":[{"driverId":"driver_001"}]},"trip":null}`
+	if string(got) != want {
+		t.Errorf("unexpected data\n got: %s\nwant: %s", got, want)
+	}
+
+	query := svc.tripQuery
+	if query.DriverID != "driver_001" || query.Limit != 5 || query.Descending || query.From != 1700000000000 {
+		t.Errorf("unexpected trip query: %+v", query)
+	}
+}
+
+func TestGraphQLComplexityLimit(t *testing.T) {
+	config := types.HTTPConfig{DefaultPageSize: 100, MaxPageSize: 1000, GraphQLMaxComplexity: 1000}
+
+	status, resp := postGraphQL(t, &fakeService{}, config, `{ trips(limit: 500) { trips { id simplifiedRoute { latitude longitude } } } }`)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", status)
+	}
+	errs, _ := resp["errors"].([]interface{})
+	if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "complexity") {
+		t.Errorf("expected a complexity error, got %v", resp)
+	}
+}
+
+func TestGraphQLDepthLimit(t *testing.T) {
+	config := types.HTTPConfig{DefaultPageSize: 10, GraphQLMaxDepth: 3}
+
+	status, resp := postGraphQL(t, &fakeService{}, config, `{ driver(id: "d") { trips { trips { driver { id } } } } }`)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", status)
+	}
+	errs, _ := resp["errors"].([]interface{})
+	if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "query depth 5 exceeds the maximum of 3") {
+		t.Errorf("expected a depth error, got %v", resp)
+	}
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	status, resp := postGraphQL(t, &fakeService{}, types.HTTPConfig{GraphQLMaxDepth: 2}, `{
+		__schema { queryType { name fields { name type { name ofType { name } } } } }
+		__type(name: "Trip") { fields { name } }
+	}`)
+	if status != http.StatusOK || resp["errors"] != nil {
+		t.Fatalf("unexpected response %d: %v", status, resp)
+	}
+
+	got, _ := json.Marshal(resp["data"])
+	for _, want := range []string{`"queryType":{"fields":[`, `"name":"Query"`, `"name":"simplifiedRoute"`} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("expected %s in %s", want, got)
+		}
+	}
+}
+
+func TestGraphQLGetAndSchema(t *testing.T) {
+	svc := &fakeService{}
+
+	params := url.Values{"query": {`query Stats($g: StatsGroupBy) { stats(groupBy: $g) { groupBy } }`}, "variables": {`{"g": "route"}`}}
+	recorder := serve(t, svc, http.MethodGet, "/graphql?"+params.Encode())
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"groupBy":"route"`) {
+		t.Errorf("unexpected response %d: %s", recorder.Code, recorder.Body)
+	}
+
+	recorder = serve(t, svc, http.MethodGet, "/graphql/schema")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "type Trip {") {
+		t.Errorf("expected the SDL, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// handleHeatmap returns the trip point counts of the geohash cells of a day
+// or week
+func (s *Server) handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := types.HeatmapQuery{Period: params.Get("period")}
+
+	switch query.Period {
+	case "":
+		query.Period = types.HeatmapPeriodDay
+	case types.HeatmapPeriodDay, types.HeatmapPeriodWeek:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid period, expected day or week")
+		return
+	}
+
+	var err error
+	if query.Date, err = parseDay(params.Get("date")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid date: "+err.Error())
+		return
+	}
+	if bbox := params.Get("bbox"); bbox != "" {
+		if query.BoundingBox, err = parseBoundingBox(bbox); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if precision := params.Get("precision"); precision != "" {
+		query.Precision, err = strconv.Atoi(precision)
+		if err != nil || query.Precision < 1 || query.Precision > algorithm.MaxGeohashPrecision {
+			writeError(w, http.StatusBadRequest, "invalid precision, expected 1 to 12")
+			return
+		}
+	}
+
+	report, err := s.service.Heatmap(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrHeatmapDisabled):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error reading heatmap", "period", query.Period, "date", query.Date, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read heatmap")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
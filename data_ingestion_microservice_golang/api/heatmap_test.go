@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestHeatmap(t *testing.T) {
+	svc := &fakeService{heatmap: []types.HeatmapCell{{Geohash: "d34780e", Latitude: 6.2442, Longitude: -75.5812, Points: 12}}}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/heatmap?period=week&date=2022-01-05&bbox=-75.59,6.24,-75.58,6.25&precision=6")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	query := svc.heatmapQuery
+	if query.Period != types.HeatmapPeriodWeek || query.Date != "2022-01-05" || query.Precision != 6 {
+		t.Errorf("Unexpected query %+v", query)
+	}
+	if query.BoundingBox == nil || *query.BoundingBox != (types.BoundingBox{MinLon: -75.59, MinLat: 6.24, MaxLon: -75.58, MaxLat: 6.25}) {
+		t.Errorf("Expected the bounding box, got %+v", query.BoundingBox)
+	}
+
+	var report types.HeatmapReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(report.Cells) != 1 || report.Cells[0].Geohash != "d34780e" || report.Cells[0].Points != 12 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	serve(t, svc, http.MethodGet, "/v1/heatmap")
+	if svc.heatmapQuery.Period != types.HeatmapPeriodDay || svc.heatmapQuery.Date != "" {
+		t.Errorf("Expected today's daily heatmap by default, got %+v", svc.heatmapQuery)
+	}
+}
+
+func TestHeatmap_Errors(t *testing.T) {
+	svc := &fakeService{heatmap: []types.HeatmapCell{}}
+	for _, target := range []string{
+		"/v1/heatmap?period=month",
+		"/v1/heatmap?date=yesterday",
+		"/v1/heatmap?bbox=1,2,3",
+		"/v1/heatmap?precision=13",
+	} {
+		if recorder := serve(t, svc, http.MethodGet, target); recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, target, recorder.Code)
+		}
+	}
+
+	recorder := serve(t, &fakeService{}, http.MethodGet, "/v1/heatmap")
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d when heatmaps are disabled, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+}
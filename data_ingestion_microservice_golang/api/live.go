@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// defaultNearestVehicles is how many drivers /v1/live/nearest returns by
+// default
+const defaultNearestVehicles = 5
+
+// handleDriverPosition returns a driver's latest position
+func (s *Server) handleDriverPosition(w http.ResponseWriter, r *http.Request) {
+	position, err := s.service.LivePosition(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLiveError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, position)
+}
+
+// handleRoutePositions returns the latest positions of the drivers on a route
+func (s *Server) handleRoutePositions(w http.ResponseWriter, r *http.Request) {
+	routeID := r.PathValue("id")
+	positions, err := s.service.RoutePositions(r.Context(), routeID)
+	if err != nil {
+		writeLiveError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, routePositionsResponse{RouteID: routeID, Positions: positions})
+}
+
+// handleRouteETAs returns the estimated arrivals of the vehicles on a route
+// at their upcoming stops
+func (s *Server) handleRouteETAs(w http.ResponseWriter, r *http.Request) {
+	routeID := r.PathValue("id")
+	etas, err := s.service.RouteETAs(r.Context(), routeID, r.URL.Query().Get("stopId"))
+	if err != nil {
+		writeLiveError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, routeETAsResponse{RouteID: routeID, Vehicles: etas})
+}
+
+// handleRouteHeadways returns the headways between the vehicles on a route
+func (s *Server) handleRouteHeadways(w http.ResponseWriter, r *http.Request) {
+	routeID := r.PathValue("id")
+	headways, err := s.service.RouteHeadways(r.Context(), routeID)
+	if err != nil {
+		writeLiveError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, routeHeadwaysResponse{RouteID: routeID, Vehicles: headways})
+}
+
+// handleFleetSnapshot returns the latest positions of every active driver,
+// for rendering a full fleet map in one request
+func (s *Server) handleFleetSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := s.service.FleetSnapshot(r.Context())
+	if err != nil {
+		writeLiveError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleNearestVehicles returns the active drivers nearest to a location,
+// for dispatchers assigning an ad-hoc trip
+func (s *Server) handleNearestVehicles(w http.ResponseWriter, r *http.Request) {
+	location, n, radius, err := s.parseNearestQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vehicles, err := s.service.NearestVehicles(r.Context(), location, n, radius)
+	if err != nil {
+		writeLiveError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nearestVehiclesResponse{Location: location, Vehicles: vehicles})
+}
+
+// parseNearestQuery reads the location, number of drivers, and optional
+// radius of a nearest drivers request
+func (s *Server) parseNearestQuery(r *http.Request) (types.Location, int, float64, error) {
+	params := r.URL.Query()
+	var location types.Location
+	lat, latErr := strconv.ParseFloat(params.Get("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(params.Get("lon"), 64)
+	if latErr != nil || lonErr != nil || !validLocation(lat, lon) {
+		return location, 0, 0, fmt.Errorf("lat and lon are required and must be a valid location")
+	}
+	location = types.Location{Latitude: lat, Longitude: lon}
+
+	n := defaultNearestVehicles
+	if value := params.Get("n"); value != "" {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil || n <= 0 {
+			return location, 0, 0, fmt.Errorf("invalid n %q", value)
+		}
+	}
+	if s.config.MaxPageSize > 0 && n > s.config.MaxPageSize {
+		n = s.config.MaxPageSize
+	}
+
+	var radius float64
+	if value := params.Get("radius"); value != "" {
+		var err error
+		if radius, err = strconv.ParseFloat(value, 64); err != nil || radius <= 0 {
+			return location, 0, 0, fmt.Errorf("invalid radius %q, expected a positive number of meters", value)
+		}
+	}
+	return location, n, radius, nil
+}
+
+// writeLiveError maps live position errors to HTTP responses
+func writeLiveError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrDriverNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrLiveTrackingDisabled), errors.Is(err, service.ErrETADisabled),
+		errors.Is(err, service.ErrHeadwaysDisabled):
+		writeError(w, http.StatusNotImplemented, err.Error())
+	default:
+		slog.ErrorContext(r.Context(), "Error reading live positions", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read live positions")
+	}
+}
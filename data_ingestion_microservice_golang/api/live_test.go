@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestLiveEndpoints(t *testing.T) {
+	svc := &fakeService{live: []types.LivePosition{
+		{DriverID: "driver_001", CurrentRouteID: "route_123", Status: "in_route", Heading: 90, Stale: true},
+		{DriverID: "driver_002", CurrentRouteID: "route_456", Status: "in_route"},
+	}}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/live/drivers/driver_001")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var position types.LivePosition
+	if err := json.NewDecoder(recorder.Body).Decode(&position); err != nil {
+		t.Fatalf("Failed to decode position: %v", err)
+	}
+	if position.Heading != 90 || !position.Stale {
+		t.Errorf("Unexpected position %+v", position)
+	}
+
+	recorder = serve(t, svc, http.MethodGet, "/v1/live/routes/route_123")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var route struct {
+		RouteID   string               `json:"routeId"`
+		Positions []types.LivePosition `json:"positions"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&route); err != nil {
+		t.Fatalf("Failed to decode route positions: %v", err)
+	}
+	if route.RouteID != "route_123" || len(route.Positions) != 1 || route.Positions[0].DriverID != "driver_001" {
+		t.Errorf("Unexpected route positions %+v", route)
+	}
+
+	recorder = serve(t, svc, http.MethodGet, "/v1/live/fleet")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var fleet types.FleetSnapshot
+	if err := json.NewDecoder(recorder.Body).Decode(&fleet); err != nil {
+		t.Fatalf("Failed to decode fleet: %v", err)
+	}
+	if fleet.Total != 2 || fleet.Stale != 1 || len(fleet.Vehicles) != 2 {
+		t.Errorf("Unexpected fleet %+v", fleet)
+	}
+
+	recorder = serve(t, svc, http.MethodGet, "/v1/live/drivers/unknown")
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestNearestVehicles(t *testing.T) {
+	if recorder := serve(t, &fakeService{}, http.MethodGet, "/v1/live/nearest?lat=6.2442&lon=-75.5812"); recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d while live positions are disabled, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+
+	bearing := 45.0
+	svc := &fakeService{live: []types.LivePosition{
+		{DriverID: "driver_001", Status: "in_route", DistanceMeters: 120, Bearing: &bearing},
+		{DriverID: "driver_002", Status: "in_route", DistanceMeters: 800},
+	}}
+	recorder := serve(t, svc, http.MethodGet, "/v1/live/nearest?lat=6.2442&lon=-75.5812")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var nearest struct {
+		Location types.Location       `json:"location"`
+		Vehicles []types.LivePosition `json:"vehicles"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&nearest); err != nil {
+		t.Fatalf("Failed to decode nearest vehicles: %v", err)
+	}
+	if nearest.Location.Latitude != 6.2442 || len(nearest.Vehicles) != 2 || nearest.Vehicles[0].Bearing == nil || *nearest.Vehicles[0].Bearing != 45 {
+		t.Errorf("Unexpected nearest vehicles %+v", nearest)
+	}
+	if svc.nearestN != defaultNearestVehicles || svc.nearestRadius != 0 {
+		t.Errorf("Expected the default count and no radius, got %d and %v", svc.nearestN, svc.nearestRadius)
+	}
+
+	if recorder := serve(t, svc, http.MethodGet, "/v1/live/nearest?lat=6.2442&lon=-75.5812&n=1&radius=5000"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if svc.nearestN != 1 || svc.nearestRadius != 5000 {
+		t.Errorf("Expected 1 vehicle within 5000m, got %d within %v", svc.nearestN, svc.nearestRadius)
+	}
+
+	for _, target := range []string{
+		"/v1/live/nearest",
+		"/v1/live/nearest?lat=91&lon=0",
+		"/v1/live/nearest?lat=6.2&lon=-75.5&n=0",
+		"/v1/live/nearest?lat=6.2&lon=-75.5&radius=-1",
+	} {
+		if recorder := serve(t, svc, http.MethodGet, target); recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, target, recorder.Code)
+		}
+	}
+}
+
+func TestRouteETAs(t *testing.T) {
+	if recorder := serve(t, &fakeService{}, http.MethodGet, "/v1/live/routes/route_123/etas"); recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d while ETAs are disabled, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+
+	svc := &fakeService{etas: []types.VehicleETA{
+		{DriverID: "driver_001", CurrentRouteID: "route_123", Stops: []types.StopETA{{StopID: "stop_2", ETASeconds: 240}}},
+		{DriverID: "driver_002", CurrentRouteID: "route_456"},
+	}}
+	recorder := serve(t, svc, http.MethodGet, "/v1/live/routes/route_123/etas?stopId=stop_2")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var route struct {
+		RouteID  string             `json:"routeId"`
+		Vehicles []types.VehicleETA `json:"vehicles"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&route); err != nil {
+		t.Fatalf("Failed to decode route ETAs: %v", err)
+	}
+	if route.RouteID != "route_123" || len(route.Vehicles) != 1 || route.Vehicles[0].Stops[0].ETASeconds != 240 {
+		t.Errorf("Unexpected route ETAs %+v", route)
+	}
+}
+
+func TestRouteHeadways(t *testing.T) {
+	if recorder := serve(t, &fakeService{}, http.MethodGet, "/v1/live/routes/route_123/headways"); recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d while headway monitoring is disabled, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+
+	headway := 75.0
+	svc := &fakeService{headways: []types.VehicleHeadway{
+		{DriverID: "driver_001", CurrentRouteID: "route_123", Status: types.HeadwayLeading},
+		{DriverID: "driver_002", CurrentRouteID: "route_123", LeaderDriverID: "driver_001", HeadwaySeconds: &headway, Status: types.HeadwayBunched},
+		{DriverID: "driver_003", CurrentRouteID: "route_456", Status: types.HeadwayLeading},
+	}}
+	recorder := serve(t, svc, http.MethodGet, "/v1/live/routes/route_123/headways")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var route struct {
+		RouteID  string                 `json:"routeId"`
+		Vehicles []types.VehicleHeadway `json:"vehicles"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&route); err != nil {
+		t.Fatalf("Failed to decode route headways: %v", err)
+	}
+	if route.RouteID != "route_123" || len(route.Vehicles) != 2 || route.Vehicles[0].HeadwaySeconds != nil {
+		t.Fatalf("Unexpected route headways %+v", route)
+	}
+	if follower := route.Vehicles[1]; follower.Status != types.HeadwayBunched || *follower.HeadwaySeconds != 75 {
+		t.Errorf("Expected the follower bunched 75s behind, got %+v", follower)
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/reporting"
+)
+
+// HeaderRequestID carries the request ID, taken from the client when it
+// sends one and generated otherwise
+const HeaderRequestID = "X-Request-Id"
+
+// maxRequestIDLength bounds request IDs taken from clients
+const maxRequestIDLength = 128
+
+// logRequests tags every request with a request ID, adds it to the logs
+// written while serving it, and logs the completed request. Probe requests
+// are logged at debug level to keep them out of the way.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r)
+		w.Header().Set(HeaderRequestID, id)
+
+		r = r.WithContext(logging.With(r.Context(), "requestId", id))
+		recorder := &statusRecorder{ResponseWriter: w}
+		serveRecovering(next, recorder, r)
+
+		level := slog.LevelInfo
+		switch {
+		case recorder.status() >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz":
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", r.Pattern,
+			"status", recorder.status(),
+			"bytes", recorder.bytes,
+			logging.Duration("durationMs", time.Since(start)),
+		)
+	})
+}
+
+// serveRecovering serves a request, reporting a panicking handler and
+// answering 500 instead of dropping the connection. Aborted handlers still
+// abort the response.
+func serveRecovering(next http.Handler, recorder *statusRecorder, r *http.Request) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+
+		slog.ErrorContext(r.Context(), "Recovered from panic in HTTP handler", "panic", recovered)
+		reporting.Panic(r.Context(), recovered, "method", r.Method, "route", r.Pattern)
+		if recorder.code == 0 && !recorder.hijacked {
+			writeError(recorder, http.StatusInternalServerError, "internal server error")
+		}
+	}()
+	next.ServeHTTP(recorder, r)
+}
+
+// requestID returns the client's request ID if it is usable, or a new one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(HeaderRequestID); id != "" && len(id) <= maxRequestIDLength {
+		return id
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// statusRecorder records the status and size of a response. It unwraps to
+// the original writer for http.ResponseController and supports the
+// hijacking that WebSocket upgrades need.
+type statusRecorder struct {
+	http.ResponseWriter
+	code     int
+	bytes    int
+	hijacked bool
+}
+
+// WriteHeader records the status code
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.code == 0 {
+		sr.code = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the response size
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// Unwrap returns the original writer
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Hijack takes over the connection of a WebSocket upgrade
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(sr.ResponseWriter).Hijack()
+	if err == nil {
+		sr.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// status returns the response status, counting upgraded connections as
+// switching protocols
+func (sr *statusRecorder) status() int {
+	switch {
+	case sr.hijacked:
+		return http.StatusSwitchingProtocols
+	case sr.code == 0:
+		return http.StatusOK
+	}
+	return sr.code
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/reporting"
+	"data-ingestion-microservice/types"
+)
+
+func TestLogRequests(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(types.LogConfig{Level: "info", Format: logging.FormatJSON}, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := NewServer(types.HTTPConfig{}, &fakeService{}).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/live/fleet", nil)
+	req.Header.Set(HeaderRequestID, "req-123")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if got := recorder.Header().Get(HeaderRequestID); got != "req-123" {
+		t.Errorf("Expected the client's request ID to be echoed, got %q", got)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q", out.String())
+	}
+	if record["requestId"] != "req-123" || record["route"] != "GET /v1/live/fleet" || record["status"] != float64(http.StatusOK) {
+		t.Errorf("Unexpected request record %v", record)
+	}
+
+	// Probes are logged below the info level and get a generated ID
+	out.Reset()
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if len(recorder.Header().Get(HeaderRequestID)) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", recorder.Header().Get(HeaderRequestID))
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected probes not to be logged at info level, got %q", out.String())
+	}
+}
+
+// panicReporter counts reported panics
+type panicReporter struct {
+	panics []map[string]string
+}
+
+func (p *panicReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {}
+
+func (p *panicReporter) CapturePanic(ctx context.Context, recovered any, tags map[string]string) {
+	p.panics = append(p.panics, tags)
+}
+
+func (p *panicReporter) Flush(timeout time.Duration) bool {
+	return true
+}
+
+func TestLogRequests_RecoversPanics(t *testing.T) {
+	reporter := &panicReporter{}
+	previous := reporting.Default()
+	reporting.SetDefault(reporter)
+	t.Cleanup(func() { reporting.SetDefault(previous) })
+
+	handler := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/trips", nil)
+	req.Header.Set(HeaderRequestID, "req-456")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+	if len(reporter.panics) != 1 || reporter.panics[0]["requestId"] != "req-456" {
+		t.Errorf("Expected the panic to be reported with the request ID, got %v", reporter.panics)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// handleDriverOdometer returns the distance a driver covered per day
+func (s *Server) handleDriverOdometer(w http.ResponseWriter, r *http.Request) {
+	s.handleOdometer(w, r, types.OdometerQuery{DriverID: r.PathValue("id")})
+}
+
+// handleVehicleOdometer returns the distance a vehicle covered per day
+func (s *Server) handleVehicleOdometer(w http.ResponseWriter, r *http.Request) {
+	s.handleOdometer(w, r, types.OdometerQuery{VehicleID: r.PathValue("id")})
+}
+
+// handleOdometer returns the daily distances selected by query between the
+// from and to days of the request
+func (s *Server) handleOdometer(w http.ResponseWriter, r *http.Request, query types.OdometerQuery) {
+	params := r.URL.Query()
+
+	var err error
+	if query.From, err = parseDay(params.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if query.To, err = parseDay(params.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+
+	report, err := s.service.Odometer(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrOdometerDisabled):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error reading daily distances", "driverId", query.DriverID, "vehicleId", query.VehicleID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read daily distances")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseDay validates an optional YYYY-MM-DD day
+func parseDay(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return "", errors.New("expected a YYYY-MM-DD day")
+	}
+	return value, nil
+}
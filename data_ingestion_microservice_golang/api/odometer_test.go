@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestOdometer(t *testing.T) {
+	svc := &fakeService{odometer: []types.DailyDistance{
+		{VehicleID: "bus_7", Day: "2022-01-01", DistanceMeters: 1500},
+		{VehicleID: "bus_7", Day: "2022-01-02", DistanceMeters: 2500},
+	}}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/vehicles/bus_7/odometer?from=2022-01-01&to=2022-01-31")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.OdometerQuery{VehicleID: "bus_7", From: "2022-01-01", To: "2022-01-31"}
+	if svc.odometerQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.odometerQuery)
+	}
+
+	var report types.OdometerReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.VehicleID != "bus_7" || len(report.Days) != 2 || report.DistanceMeters != 4000 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	recorder = serve(t, svc, http.MethodGet, "/v1/drivers/driver_001/odometer")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if want := (types.OdometerQuery{DriverID: "driver_001"}); svc.odometerQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.odometerQuery)
+	}
+}
+
+func TestOdometer_Errors(t *testing.T) {
+	recorder := serve(t, &fakeService{odometer: []types.DailyDistance{}}, http.MethodGet, "/v1/drivers/driver_001/odometer?from=2022-13-01")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = serve(t, &fakeService{}, http.MethodGet, "/v1/vehicles/bus_7/odometer")
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d when distance counting is disabled, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// route is one API endpoint: its handler and what the OpenAPI document
+// says about it
+type route struct {
+	method    string
+	pattern   string
+	handler   http.HandlerFunc
+	tag       string
+	summary   string
+	params    []parameter
+	body      interface{}
+	response  interface{}
+	produces  []string
+	errors    []int
+	admin     bool
+	websocket bool
+	// scope overrides the JWT scope the route requires, see requiredScope
+	scope string
+}
+
+// parameter is an OpenAPI path or query parameter
+type parameter struct {
+	Name        string                 `json:"name"`
+	In          string                 `json:"in"`
+	Description string                 `json:"description,omitempty"`
+	Required    bool                   `json:"required,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+}
+
+// queryParam describes an optional query parameter, restricted to values
+// when any are given
+func queryParam(name, kind, description string, values ...string) parameter {
+	schema := map[string]interface{}{"type": kind}
+	if len(values) > 0 {
+		schema["enum"] = values
+	}
+	return parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// pathParam describes a string path parameter
+func pathParam(name, description string) parameter {
+	return parameter{
+		Name:        name,
+		In:          "path",
+		Description: description,
+		Required:    true,
+		Schema:      map[string]interface{}{"type": "string"},
+	}
+}
+
+// statusResponse is the body of GET /healthz
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// readinessResponse is the body of GET /readyz
+type readinessResponse struct {
+	Status     string          `json:"status"`
+	Components map[string]bool `json:"components"`
+}
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// tripSearchResponse is the body of GET /v1/trips/search
+type tripSearchResponse struct {
+	Trips []types.StoredTrip `json:"trips"`
+}
+
+// routePositionsResponse is the body of GET /v1/live/routes/{id}
+type routePositionsResponse struct {
+	RouteID   string               `json:"routeId"`
+	Positions []types.LivePosition `json:"positions"`
+}
+
+// nearestVehiclesResponse is the body of GET /v1/live/nearest
+type nearestVehiclesResponse struct {
+	Location types.Location       `json:"location"`
+	Vehicles []types.LivePosition `json:"vehicles"`
+}
+
+// routeETAsResponse is the body of GET /v1/live/routes/{id}/etas
+type routeETAsResponse struct {
+	RouteID  string             `json:"routeId"`
+	Vehicles []types.VehicleETA `json:"vehicles"`
+}
+
+// routeHeadwaysResponse is the body of GET /v1/live/routes/{id}/headways
+type routeHeadwaysResponse struct {
+	RouteID  string                 `json:"routeId"`
+	Vehicles []types.VehicleHeadway `json:"vehicles"`
+}
+
+// handleOpenAPI returns the OpenAPI 3 document of the API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPISpec())
+}
+
+// openAPISpec builds the OpenAPI 3 document from the route table
+func (s *Server) openAPISpec() map[string]interface{} {
+	schemas := newSchemaGenerator()
+	errorSchema := schemas.schema(reflect.TypeOf(errorResponse{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, rt := range s.routes() {
+		responses := map[string]interface{}{}
+		switch {
+		case len(rt.produces) > 0:
+			content := map[string]interface{}{}
+			for _, contentType := range rt.produces {
+				content[contentType] = map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string"},
+				}
+			}
+			responses["200"] = map[string]interface{}{"description": "OK", "content": content}
+		case rt.response != nil:
+			responses["200"] = jsonContent("OK", schemas.schema(reflect.TypeOf(rt.response)))
+		case rt.websocket:
+			responses["101"] = map[string]interface{}{"description": "Switching Protocols to a WebSocket"}
+		}
+		for _, status := range rt.errors {
+			responses[strconv.Itoa(status)] = jsonContent(http.StatusText(status), errorSchema)
+		}
+
+		operation := map[string]interface{}{
+			"summary":     rt.summary,
+			"operationId": operationID(rt),
+			"tags":        []string{rt.tag},
+			"responses":   responses,
+		}
+		if len(rt.params) > 0 {
+			operation["parameters"] = rt.params
+		}
+		if rt.body != nil {
+			body := jsonContent("", schemas.schema(reflect.TypeOf(rt.body)))
+			delete(body, "description")
+			body["required"] = true
+			operation["requestBody"] = body
+		}
+		var security []map[string][]string
+		if rt.admin {
+			security = append(security, map[string][]string{"adminToken": {}})
+		}
+		if scope := rt.requiredScope(); s.auth != nil && scope != "" {
+			security = append(security, map[string][]string{"bearerJWT": {scope}})
+			responses[strconv.Itoa(http.StatusForbidden)] = jsonContent(http.StatusText(http.StatusForbidden), errorSchema)
+		}
+		if len(security) > 0 {
+			operation["security"] = security
+			responses[strconv.Itoa(http.StatusUnauthorized)] = jsonContent(http.StatusText(http.StatusUnauthorized), errorSchema)
+		}
+
+		if paths[rt.pattern] == nil {
+			paths[rt.pattern] = map[string]interface{}{}
+		}
+		paths[rt.pattern][strings.ToLower(rt.method)] = operation
+	}
+
+	securitySchemes := map[string]interface{}{
+		"adminToken": map[string]string{"type": "http", "scheme": "bearer"},
+	}
+	if s.auth != nil {
+		securitySchemes["bearerJWT"] = map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Data Ingestion Microservice API",
+			"description": "Stored trips, trip statistics, live positions, and runtime settings of the GPS route tracking system",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas":         schemas.components,
+			"securitySchemes": securitySchemes,
+		},
+	}
+}
+
+// operationID derives a stable operation ID such as getV1TripsIdExport
+func operationID(rt route) string {
+	id := strings.ToLower(rt.method)
+	for _, part := range strings.FieldsFunc(rt.pattern, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// jsonContent describes a JSON response with the given schema
+func jsonContent(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// schemaGenerator derives JSON schemas from Go types through their json
+// tags, collecting named structs as reusable components
+type schemaGenerator struct {
+	components map[string]interface{}
+}
+
+// newSchemaGenerator creates a generator with no components
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: map[string]interface{}{}}
+}
+
+// schema returns the schema of t, a $ref for named structs
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.components[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			g.components[name] = nil
+			g.components[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the object schema of a struct's JSON fields. Fields
+// without omitempty are required.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// handleDocs serves a Swagger UI for the OpenAPI document
+func handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
+
+// swaggerUIAssets holds Swagger UI 5.18.2 (swagger-ui-dist, Apache 2.0), so
+// the docs page works offline and cannot change under a floating CDN tag
+//
+//go:embed swaggerui
+var swaggerUIAssets embed.FS
+
+// swaggerUIHandler serves the embedded Swagger UI assets under /docs/assets/
+func swaggerUIHandler() http.Handler {
+	assets, err := fs.Sub(swaggerUIAssets, "swaggerui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/docs/assets/", http.FileServerFS(assets))
+}
+
+// swaggerUIPage loads the embedded Swagger UI and points it at /openapi.json
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Data Ingestion Microservice API</title>
+  <link rel="stylesheet" href="/docs/assets/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/assets/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
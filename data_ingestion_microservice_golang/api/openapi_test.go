@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+// openAPIDocument is the part of the OpenAPI document checked by the tests
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+func fetchOpenAPI(t *testing.T, config types.HTTPConfig) openAPIDocument {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	NewServer(config, &fakeService{}).Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var doc openAPIDocument
+	if err := json.NewDecoder(recorder.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	config := types.HTTPConfig{AdminToken: "secret"}
+	doc := fetchOpenAPI(t, config)
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	server := NewServer(config, &fakeService{})
+	for _, rt := range server.routes() {
+		if _, ok := doc.Paths[rt.pattern][strings.ToLower(rt.method)]; !ok {
+			t.Errorf("Route %s %s is missing from the OpenAPI document", rt.method, rt.pattern)
+		}
+	}
+
+	for _, name := range []string{"TripPage", "StoredTrip", "LivePosition", "SimplificationSettings"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Expected schema %s in components", name)
+		}
+	}
+}
+
+func TestOpenAPIOmitsDisabledAdminRoutes(t *testing.T) {
+	doc := fetchOpenAPI(t, types.HTTPConfig{})
+
+	if _, ok := doc.Paths["/admin/simplification"]; ok {
+		t.Error("Expected admin routes to be omitted without an admin token")
+	}
+	if _, ok := doc.Paths["/v1/trips"]["get"]; !ok {
+		t.Error("Expected /v1/trips to be documented")
+	}
+}
+
+func TestSchemaFromJSONTags(t *testing.T) {
+	schemas := newSchemaGenerator()
+	schemas.schema(reflect.TypeOf(types.TripPage{}))
+
+	page := schemas.components["TripPage"].(map[string]interface{})
+	properties := page["properties"].(map[string]interface{})
+	if _, ok := properties["nextCursor"]; !ok {
+		t.Fatalf("Expected nextCursor property, got %v", properties)
+	}
+	if required := page["required"].([]string); len(required) != 1 || required[0] != "trips" {
+		t.Errorf("Expected only trips to be required, got %v", required)
+	}
+
+	trip := schemas.components["StoredTrip"].(map[string]interface{})
+	createdAt := trip["properties"].(map[string]interface{})["createdAt"].(map[string]interface{})
+	if createdAt["format"] != "date-time" {
+		t.Errorf("Expected createdAt to be a date-time string, got %v", createdAt)
+	}
+}
+
+func TestDocsServesSwaggerUI(t *testing.T) {
+	recorder := serve(t, &fakeService{}, http.MethodGet, "/docs")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected HTML, got %q", recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder.Body.String(), `url: "/openapi.json"`) {
+		t.Error("Expected Swagger UI to load /openapi.json")
+	}
+	if strings.Contains(recorder.Body.String(), "https://") {
+		t.Error("Expected Swagger UI to load without a CDN")
+	}
+}
+
+func TestDocsServesEmbeddedAssets(t *testing.T) {
+	for _, asset := range []string{"/docs/assets/swagger-ui-bundle.js", "/docs/assets/swagger-ui.css"} {
+		recorder := serve(t, &fakeService{}, http.MethodGet, asset)
+		if recorder.Code != http.StatusOK || recorder.Body.Len() == 0 {
+			t.Errorf("Expected %s to be served, got status %d", asset, recorder.Code)
+		}
+	}
+	if recorder := serve(t, &fakeService{}, http.MethodGet, "/docs/assets/missing.js"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing asset, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
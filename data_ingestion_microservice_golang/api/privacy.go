@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"data-ingestion-microservice/service"
+)
+
+// adminActor identifies requests authenticated with the shared admin token
+// in the audit log
+const adminActor = "admin"
+
+// handleDeleteDriverData deletes everything stored about a driver and
+// returns what was removed
+func (s *Server) handleDeleteDriverData(w http.ResponseWriter, r *http.Request) {
+	driverID := r.PathValue("id")
+
+	report, err := s.service.DeleteDriverData(r.Context(), driverID, requestActor(r))
+	switch {
+	case errors.Is(err, service.ErrDataDeletionUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error deleting driver data", "driverId", driverID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete driver data, retry to complete the deletion")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+func deleteDriverRequest(svc Service, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/v1/drivers/driver_001/data", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	NewServer(types.HTTPConfig{AdminToken: "secret"}, svc).Handler().ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestDeleteDriverData(t *testing.T) {
+	svc := &fakeService{}
+
+	recorder := deleteDriverRequest(svc, "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+
+	var report types.DriverDeletionReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.DriverID != "driver_001" || report.Trips != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if svc.deletedDriver != "driver_001" || svc.deleteActor != adminActor {
+		t.Errorf("Expected driver_001 to be deleted by %s, got %s by %s", adminActor, svc.deletedDriver, svc.deleteActor)
+	}
+}
+
+func TestDeleteDriverData_Errors(t *testing.T) {
+	if recorder := deleteDriverRequest(&fakeService{}, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	svc := &fakeService{tripErr: service.ErrDataDeletionUnsupported}
+	if recorder := deleteDriverRequest(svc, "secret"); recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+
+	recorder := serve(t, &fakeService{}, http.MethodDelete, "/v1/drivers/driver_001/data")
+	if recorder.Code != http.StatusNotFound && recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected the route to be disabled without an admin token, got %d", recorder.Code)
+	}
+}
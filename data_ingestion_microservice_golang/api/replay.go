@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// maxReplaySpeed bounds the playback speed factor of trip replays
+const maxReplaySpeed = 1000
+
+// handleReplayTrip streams a trip's raw points as Server-Sent Events with
+// their recorded spacing divided by the speed factor: a trip event with the
+// stored trip, one point event per point, and a final end event
+func (s *Server) handleReplayTrip(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	speed, err := parseReplaySpeed(r.URL.Query().Get("speed"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trip, points, err := s.service.TripPoints(r.Context(), id)
+	switch {
+	case errors.Is(err, service.ErrTripNotFound), errors.Is(err, service.ErrRawPointsUnavailable):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error loading trip", "tripId", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to load trip")
+		return
+	}
+
+	controller, ok := openEventStream(w)
+	if !ok {
+		return
+	}
+	if err := s.writeEvent(w, controller, "trip", trip); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for i, point := range points {
+		if i > 0 {
+			if err := s.waitReplayGap(w, controller, r, replayGap(points[i-1], point, speed), heartbeat); err != nil {
+				return
+			}
+		}
+
+		event := types.ReplayPoint{Index: i, Total: len(points), Location: point.Location, Timestamp: point.Timestamp, Telemetry: point.Telemetry}
+		if err := s.writeEvent(w, controller, "point", event); err != nil {
+			return
+		}
+	}
+
+	s.writeEvent(w, controller, "end", types.ReplayEnd{TripID: trip.ID, Points: len(points)})
+}
+
+// waitReplayGap waits out the gap before the next point, keeping the stream
+// open with heartbeats, and fails when the client goes away
+func (s *Server) waitReplayGap(w http.ResponseWriter, controller *http.ResponseController, r *http.Request, gap time.Duration, heartbeat *time.Ticker) error {
+	if gap <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(gap)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case <-heartbeat.C:
+			if err := s.writeHeartbeat(w, controller); err != nil {
+				return err
+			}
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
+
+// replayGap returns the time between two points divided by the speed.
+// Points without timestamps or out of order follow immediately.
+func replayGap(prev, next types.TrackPoint, speed float64) time.Duration {
+	if prev.Timestamp == 0 || next.Timestamp <= prev.Timestamp {
+		return 0
+	}
+	elapsed := time.Duration(next.Timestamp-prev.Timestamp) * time.Millisecond
+	return time.Duration(float64(elapsed) / speed)
+}
+
+// parseReplaySpeed parses a playback speed such as "10x" or "0.5",
+// defaulting to real time
+func parseReplaySpeed(value string) (float64, error) {
+	if value == "" {
+		return 1, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value), "x"), 64)
+	if err != nil || !(speed > 0 && speed <= maxReplaySpeed) {
+		return 0, fmt.Errorf("invalid speed %q, expected a factor such as 10x between 0 and %d", value, maxReplaySpeed)
+	}
+	return speed, nil
+}
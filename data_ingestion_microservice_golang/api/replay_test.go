@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestReplayTrip_StreamsPoints(t *testing.T) {
+	svc := &fakeService{
+		trip: &types.StoredTrip{ID: "trip_1", DriverID: "driver_001"},
+		points: []types.TrackPoint{
+			{Location: types.Location{Latitude: 6.20, Longitude: -75.50}, Timestamp: 1700000000000},
+			{Location: types.Location{Latitude: 6.21, Longitude: -75.51}, Timestamp: 1700000010000},
+			{Location: types.Location{Latitude: 6.22, Longitude: -75.52}, Timestamp: 1700000020000},
+		},
+	}
+
+	// 20s of recorded trip at 200x takes 100ms
+	start := time.Now()
+	recorder := serve(t, svc, http.MethodGet, "/v1/trips/trip_1/replay?speed=200x")
+	elapsed := time.Since(start)
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the replay to keep the scaled timing, took %s", elapsed)
+	}
+
+	body := recorder.Body.String()
+	var names []string
+	for _, line := range strings.Split(body, "\n") {
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+		}
+	}
+	if got := strings.Join(names, ","); got != "trip,point,point,point,end" {
+		t.Errorf("Unexpected events %s", got)
+	}
+	if !strings.Contains(body, `"index":2,"total":3,"location":{"latitude":6.22,"longitude":-75.52},"timestamp":1700000020000`) {
+		t.Errorf("Expected the last point, got %s", body)
+	}
+	if !strings.Contains(body, `data: {"tripId":"trip_1","points":3}`) {
+		t.Errorf("Expected the end event, got %s", body)
+	}
+}
+
+func TestReplayTrip_Errors(t *testing.T) {
+	svc := &fakeService{trip: &types.StoredTrip{ID: "trip_1"}}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/v1/trips/trip_1/replay?speed=fast", http.StatusBadRequest},
+		{"/v1/trips/trip_1/replay?speed=0x", http.StatusBadRequest},
+		{"/v1/trips/trip_1/replay?speed=5000x", http.StatusBadRequest},
+		{"/v1/trips/missing/replay", http.StatusNotFound},
+		{"/v1/trips/trip_1/replay", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if recorder := serve(t, svc, http.MethodGet, tt.target); recorder.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.target, tt.status, recorder.Code)
+		}
+	}
+}
+
+func TestReplayGap(t *testing.T) {
+	tests := []struct {
+		prev, next uint64
+		speed      float64
+		want       time.Duration
+	}{
+		{1000, 11000, 1, 10 * time.Second},
+		{1000, 11000, 10, time.Second},
+		{1000, 2000, 0.5, 2 * time.Second},
+		{0, 2000, 1, 0},
+		{2000, 1000, 1, 0},
+	}
+
+	for _, tt := range tests {
+		got := replayGap(types.TrackPoint{Timestamp: tt.prev}, types.TrackPoint{Timestamp: tt.next}, tt.speed)
+		if got != tt.want {
+			t.Errorf("replayGap(%d, %d, %v) = %s, want %s", tt.prev, tt.next, tt.speed, got, tt.want)
+		}
+	}
+}
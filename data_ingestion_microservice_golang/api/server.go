@@ -0,0 +1,513 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"data-ingestion-microservice/auth"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/export"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+
+	"github.com/99designs/gqlgen/graphql/executor"
+)
+
+// Service is the part of the data ingestion service exposed over HTTP
+type Service interface {
+	GetHealthStatus(ctx context.Context) map[string]interface{}
+	ComponentHealth(ctx context.Context) map[string]bool
+	SimplificationSettings() types.SimplificationSettings
+	UpdateSimplification(ctx context.Context, update types.SimplificationSettings, actor string) (types.SimplificationSettings, error)
+	RuntimeSettings() types.RuntimeSettings
+	UpdateRuntimeSettings(ctx context.Context, settings types.RuntimeSettings, actor string) (types.RuntimeSettings, error)
+	FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error)
+	TripWithStops(ctx context.Context, id string) (*types.StoredTrip, []types.Stop, error)
+	TripPoints(ctx context.Context, id string) (*types.StoredTrip, []types.TrackPoint, error)
+	SearchTrips(ctx context.Context, query types.TripGeoQuery) ([]types.StoredTrip, error)
+	TripStats(ctx context.Context, query types.TripStatsQuery) (types.TripStatsReport, error)
+	IdleReport(ctx context.Context, query types.TripStatsQuery) (types.IdleReport, error)
+	ZoneTimeReport(ctx context.Context, query types.TripStatsQuery) (types.ZoneTimeReport, error)
+	LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error)
+	RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error)
+	RouteETAs(ctx context.Context, routeID, stopID string) ([]types.VehicleETA, error)
+	RouteHeadways(ctx context.Context, routeID string) ([]types.VehicleHeadway, error)
+	FleetSnapshot(ctx context.Context) (types.FleetSnapshot, error)
+	NearestVehicles(ctx context.Context, location types.Location, n int, radiusMeters float64) ([]types.LivePosition, error)
+	Shifts(ctx context.Context, query types.ShiftQuery) (types.ShiftPage, error)
+	Assignments(ctx context.Context, query types.AssignmentQuery) (types.AssignmentPage, error)
+	Odometer(ctx context.Context, query types.OdometerQuery) (types.OdometerReport, error)
+	Heatmap(ctx context.Context, query types.HeatmapQuery) (types.HeatmapReport, error)
+	SubscribeLive(filter service.StreamFilter) *service.LiveSubscription
+	SubscribeEvents(filter service.StreamFilter) *service.EventSubscription
+	DeleteDriverData(ctx context.Context, driverID, actor string) (types.DriverDeletionReport, error)
+	RecentFailures() types.FailureReport
+	ClusterStatus(ctx context.Context) (types.ClusterStatus, error)
+	AuditLog(ctx context.Context, query types.AuditQuery) (types.AuditLogPage, error)
+}
+
+// defaultStreamWriteTimeout bounds stream writes when no timeout is configured
+const defaultStreamWriteTimeout = 5 * time.Second
+
+// healthCheckTimeout bounds the backend pings of the health probes, so a
+// hung backend fails the probe instead of stalling it
+const healthCheckTimeout = 2 * time.Second
+
+// Server serves the HTTP API of the data ingestion service
+type Server struct {
+	config  types.HTTPConfig
+	service Service
+	server  *http.Server
+	graphQL *executor.Executor
+	auth    Authenticator
+}
+
+// NewServer creates an HTTP server for the given service, protecting only
+// the admin routes with the admin token
+func NewServer(config types.HTTPConfig, service Service) *Server {
+	return NewServerWithAuth(config, service, nil)
+}
+
+// NewServerWithAuth creates an HTTP server that also requires JWTs
+// validated by authenticator on every route but the health probes. A nil
+// authenticator disables JWT authentication.
+func NewServerWithAuth(config types.HTTPConfig, service Service, authenticator Authenticator) *Server {
+	if config.StreamWriteTimeout <= 0 {
+		config.StreamWriteTimeout = defaultStreamWriteTimeout
+	}
+	if config.GraphQLMaxDepth <= 0 {
+		config.GraphQLMaxDepth = defaultGraphQLMaxDepth
+	}
+	if config.GraphQLMaxComplexity <= 0 {
+		config.GraphQLMaxComplexity = defaultGraphQLMaxComplexity
+	}
+
+	if config.DebugEnabled && config.AdminToken == "" && authenticator == nil {
+		slog.Warn("Debug endpoints are disabled until an admin token or authentication is configured")
+	}
+
+	s := &Server{
+		config:  config,
+		service: service,
+		auth:    authenticator,
+	}
+	s.graphQL = s.graphQLExecutor()
+
+	s.server = &http.Server{
+		Addr:              config.Address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// Handler returns the HTTP handler with all routes registered
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range s.routes() {
+		mux.Handle(rt.method+" "+rt.pattern, s.authorize(rt))
+	}
+
+	mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("GET /docs", handleDocs)
+	mux.Handle("GET /docs/assets/", swaggerUIHandler())
+
+	// Debug endpoints are only served to admins
+	if s.config.DebugEnabled && s.adminEnabled() {
+		s.registerDebug(mux)
+	}
+
+	return logRequests(mux)
+}
+
+// routes lists every API endpoint. The OpenAPI document is generated from
+// the same table, so a route registered here is always documented.
+func (s *Server) routes() []route {
+	routes := []route{
+		{
+			method: http.MethodGet, pattern: "/healthz", handler: s.handleLiveness,
+			tag: "health", summary: "Liveness probe",
+			response: statusResponse{},
+		},
+		{
+			method: http.MethodGet, pattern: "/readyz", handler: s.handleReadiness,
+			tag: "health", summary: "Readiness probe, 503 while a storage or broker connection is down",
+			response: readinessResponse{}, errors: []int{http.StatusServiceUnavailable},
+		},
+		{
+			method: http.MethodGet, pattern: "/health", handler: s.handleHealth,
+			tag: "health", summary: "Detailed service health and configuration",
+			response: map[string]interface{}{},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/trips", handler: s.handleListTrips,
+			tag: "trips", summary: "List stored trips, newest first, with cursor pagination",
+			params: []parameter{
+				queryParam("driverId", "string", "Only trips of this driver"),
+				queryParam("vehicleId", "string", "Only trips of this vehicle"),
+				queryParam("routeId", "string", "Only trips of this route"),
+				queryParam("from", "integer", "Trip timestamp lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Trip timestamp upper bound in milliseconds (exclusive)"),
+				queryParam("minQuality", "number", "Only trips with a quality score of at least this, from 0 to 100"),
+				queryParam("withoutFlag", "string", "Leave out trips with this quality flag, such as has_gaps or low_accuracy"),
+				queryParam("limit", "integer", "Page size, capped at the configured maximum"),
+				queryParam("order", "string", "asc or desc (default)", "asc", "desc"),
+				queryParam("cursor", "string", "nextCursor of the previous page"),
+			},
+			response: types.TripPage{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/trips/search", handler: s.handleSearchTrips,
+			tag: "trips", summary: "Find trips crossing a bounding box or passing near a point",
+			params: []parameter{
+				queryParam("bbox", "string", "minLon,minLat,maxLon,maxLat"),
+				queryParam("near", "string", "lat,lon, requires radius"),
+				queryParam("radius", "number", "Search radius in meters around near"),
+				queryParam("from", "integer", "Trip timestamp lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Trip timestamp upper bound in milliseconds (exclusive)"),
+				queryParam("limit", "integer", "Maximum number of trips"),
+			},
+			response: tripSearchResponse{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/trips/{id}/export", handler: s.handleExportTrip,
+			tag: "trips", summary: "Download a trip's route and stops as GPX, GeoJSON, or KML",
+			params: []parameter{
+				pathParam("id", "Trip ID"),
+				queryParam("format", "string", "Export format (default geojson)", export.FormatGPX, export.FormatGeoJSON, export.FormatKML),
+			},
+			produces: []string{
+				export.ContentType(export.FormatGPX),
+				export.ContentType(export.FormatGeoJSON),
+				export.ContentType(export.FormatKML),
+			},
+			errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/trips/{id}/replay", handler: s.handleReplayTrip,
+			tag: "trips", summary: "Server-Sent Events replay of a trip's raw points with their recorded timing",
+			params: []parameter{
+				pathParam("id", "Trip ID"),
+				queryParam("speed", "string", "Playback speed factor such as 10x (default 1x, at most 1000x)"),
+			},
+			produces: []string{"text/event-stream"},
+			errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/stats", handler: s.handleTripStats,
+			tag: "trips", summary: "Trip counts, distance, compression, and duration per group",
+			params: []parameter{
+				queryParam("groupBy", "string", "Grouping (default day)", database.StatsGroupByDay, database.StatsGroupByDriver, database.StatsGroupByRoute),
+				queryParam("from", "integer", "Trip timestamp lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Trip timestamp upper bound in milliseconds (exclusive)"),
+			},
+			response: types.TripStatsReport{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/idle", handler: s.handleIdleReport,
+			tag: "trips", summary: "Idle periods and minutes of the trips checked for idling per group",
+			params: []parameter{
+				queryParam("groupBy", "string", "Grouping (default day)", database.StatsGroupByDay, database.StatsGroupByDriver, database.StatsGroupByRoute),
+				queryParam("from", "integer", "Trip timestamp lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Trip timestamp upper bound in milliseconds (exclusive)"),
+			},
+			response: types.IdleReport{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/zone-time", handler: s.handleZoneTimeReport,
+			tag: "trips", summary: "Time the trips spent in each operational zone per group",
+			params: []parameter{
+				queryParam("groupBy", "string", "Grouping (default day)", database.StatsGroupByDay, database.StatsGroupByDriver, database.StatsGroupByRoute),
+				queryParam("from", "integer", "Trip timestamp lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Trip timestamp upper bound in milliseconds (exclusive)"),
+			},
+			response: types.ZoneTimeReport{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/heatmap", handler: s.handleHeatmap,
+			tag: "trips", summary: "Trip point counts per geohash cell of a day or week",
+			params: []parameter{
+				queryParam("period", "string", "Period of the counts (default day)", types.HeatmapPeriodDay, types.HeatmapPeriodWeek),
+				queryParam("date", "string", "Day in the period, YYYY-MM-DD (default today)"),
+				queryParam("bbox", "string", "minLon,minLat,maxLon,maxLat"),
+				queryParam("precision", "integer", "Geohash length of the cells, at most the aggregated precision"),
+			},
+			response: types.HeatmapReport{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/drivers/{id}/shifts", handler: s.handleDriverShifts,
+			tag: "drivers", summary: "Shifts of a driver, newest first, with their trips, driving time, and breaks",
+			params: []parameter{
+				pathParam("id", "Driver ID"),
+				queryParam("from", "integer", "Shift start lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Shift start upper bound in milliseconds (exclusive)"),
+				queryParam("limit", "integer", "Maximum number of shifts, capped at the configured maximum"),
+			},
+			response: types.ShiftPage{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/assignments", handler: s.handleAssignments,
+			tag: "drivers", summary: "Vehicle assignments of a driver or vehicle, newest first",
+			params: []parameter{
+				queryParam("driverId", "string", "Only assignments of this driver"),
+				queryParam("vehicleId", "string", "Only assignments of this vehicle"),
+				queryParam("from", "integer", "Assignment start lower bound in milliseconds (inclusive)"),
+				queryParam("to", "integer", "Assignment start upper bound in milliseconds (exclusive)"),
+				queryParam("limit", "integer", "Maximum number of assignments, capped at the configured maximum"),
+			},
+			response: types.AssignmentPage{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/drivers/{id}/odometer", handler: s.handleDriverOdometer,
+			tag: "drivers", summary: "Distance a driver covered per day, oldest first, and in total",
+			params: []parameter{
+				pathParam("id", "Driver ID"),
+				queryParam("from", "string", "First day, YYYY-MM-DD (inclusive)"),
+				queryParam("to", "string", "Last day, YYYY-MM-DD (inclusive)"),
+			},
+			response: types.OdometerReport{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/vehicles/{id}/odometer", handler: s.handleVehicleOdometer,
+			tag: "drivers", summary: "Distance a vehicle covered per day, oldest first, and in total",
+			params: []parameter{
+				pathParam("id", "Vehicle ID"),
+				queryParam("from", "string", "First day, YYYY-MM-DD (inclusive)"),
+				queryParam("to", "string", "Last day, YYYY-MM-DD (inclusive)"),
+			},
+			response: types.OdometerReport{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/live/drivers/{id}", handler: s.handleDriverPosition,
+			tag: "live", summary: "Latest position of a driver",
+			params:   []parameter{pathParam("id", "Driver ID")},
+			response: types.LivePosition{},
+			errors:   []int{http.StatusNotFound, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/live/routes/{id}", handler: s.handleRoutePositions,
+			tag: "live", summary: "Latest positions of the drivers on a route",
+			params:   []parameter{pathParam("id", "Route ID")},
+			response: routePositionsResponse{},
+			errors:   []int{http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/live/routes/{id}/etas", handler: s.handleRouteETAs,
+			tag: "live", summary: "Estimated arrival of the vehicles on a route at their upcoming stops",
+			params: []parameter{
+				pathParam("id", "Route ID"),
+				queryParam("stopId", "string", "Only vehicles heading to this stop, soonest first"),
+			},
+			response: routeETAsResponse{},
+			errors:   []int{http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/live/routes/{id}/headways", handler: s.handleRouteHeadways,
+			tag: "live", summary: "Headway of every vehicle on a route behind the vehicle ahead, from the front of the route to the back",
+			params:   []parameter{pathParam("id", "Route ID")},
+			response: routeHeadwaysResponse{},
+			errors:   []int{http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/live/fleet", handler: s.handleFleetSnapshot,
+			tag: "live", summary: "Latest position, status, route, and staleness of every active driver",
+			response: types.FleetSnapshot{},
+			errors:   []int{http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/live/nearest", handler: s.handleNearestVehicles,
+			tag: "live", summary: "Active drivers nearest to a location, nearest first, with their distance and bearing from it",
+			params: []parameter{
+				queryParam("lat", "number", "Latitude of the location"),
+				queryParam("lon", "number", "Longitude of the location"),
+				queryParam("n", "integer", "Number of drivers, 5 by default, capped at the configured maximum page size"),
+				queryParam("radius", "number", "Only drivers within this many meters"),
+			},
+			response: nearestVehiclesResponse{},
+			errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+		},
+		{
+			method: http.MethodGet, pattern: "/ws/live", handler: s.handleLiveWebSocket,
+			tag: "live", summary: "WebSocket stream of every processed location matching the subscribed routes, drivers, and geofence",
+			params:    streamFilterParameters("Routes to subscribe to", "Drivers to subscribe to"),
+			errors:    []int{http.StatusBadRequest},
+			websocket: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/v1/events", handler: s.handleEvents,
+			tag: "live", summary: "Server-Sent Events stream of trip_started, location_update, and trip_finished events",
+			params:   streamFilterParameters("Only events of these routes", "Only events of these drivers"),
+			produces: []string{"text/event-stream"},
+			errors:   []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodPost, pattern: "/graphql", handler: s.handleGraphQL,
+			tag: "graphql", summary: "Query trips, stops, stats, drivers, routes, and live positions in one request",
+			body: graphQLRequest{}, response: graphQLResponse{},
+			errors: []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodGet, pattern: "/graphql", handler: s.handleGraphQL,
+			tag: "graphql", summary: "Run a GraphQL query given as URL parameters",
+			params: []parameter{
+				queryParam("query", "string", "GraphQL query document"),
+				queryParam("operationName", "string", "Operation to run when the document has several"),
+				queryParam("variables", "string", "JSON object of variable values"),
+			},
+			response: graphQLResponse{},
+			errors:   []int{http.StatusBadRequest},
+		},
+		{
+			method: http.MethodGet, pattern: "/graphql/schema", handler: s.handleGraphQLSchema,
+			tag: "graphql", summary: "GraphQL schema in SDL",
+			produces: []string{"text/plain"},
+		},
+	}
+
+	// Admin endpoints are only served when an admin token or JWT
+	// authentication is configured
+	if s.adminEnabled() {
+		routes = append(routes,
+			route{
+				method: http.MethodGet, pattern: "/admin/simplification", handler: s.handleGetSimplification,
+				tag: "admin", summary: "Simplification settings in effect", admin: true,
+				response: types.SimplificationSettings{},
+			},
+			route{
+				method: http.MethodPut, pattern: "/admin/simplification", handler: s.handleUpdateSimplification,
+				tag: "admin", summary: "Change the simplification tolerance and algorithm", admin: true,
+				body: simplificationRequest{}, response: types.SimplificationSettings{},
+				errors: []int{http.StatusBadRequest},
+			},
+			route{
+				method: http.MethodGet, pattern: "/admin/runtime-settings", handler: s.handleGetRuntimeSettings,
+				tag: "admin", summary: "Runtime settings shared by every instance", admin: true,
+				response: types.RuntimeSettings{},
+			},
+			route{
+				method: http.MethodPut, pattern: "/admin/runtime-settings", handler: s.handleUpdateRuntimeSettings,
+				tag: "admin", summary: "Replace the tolerance, rate limits, and paused topics of every instance", admin: true,
+				body: types.RuntimeSettings{}, response: types.RuntimeSettings{},
+				errors: []int{http.StatusBadRequest, http.StatusNotImplemented},
+			},
+			route{
+				method: http.MethodDelete, pattern: "/v1/drivers/{id}/data", handler: s.handleDeleteDriverData,
+				tag: "admin", summary: "Delete every trip, raw trace, route buffer, and live position of a driver", admin: true,
+				scope:    auth.ScopeDeleteData,
+				params:   []parameter{pathParam("id", "Driver ID")},
+				response: types.DriverDeletionReport{},
+				errors:   []int{http.StatusNotImplemented},
+			},
+			route{
+				method: http.MethodGet, pattern: "/admin/failures", handler: s.handleFailures,
+				tag: "admin", summary: "Processing failure counts by class and recent sampled failures", admin: true,
+				response: types.FailureReport{},
+			},
+			route{
+				method: http.MethodGet, pattern: "/admin/cluster", handler: s.handleClusterStatus,
+				tag: "admin", summary: "Live instances with their share of the drivers, leader jobs, and throughput", admin: true,
+				response: types.ClusterStatus{}, errors: []int{http.StatusInternalServerError},
+			},
+			route{
+				method: http.MethodGet, pattern: "/admin/audit", handler: s.handleAuditLog,
+				tag: "admin", summary: "Recorded administrative actions, newest first", admin: true,
+				params: []parameter{
+					queryParam("action", "string", "Only entries of this action, e.g. simplification.update"),
+					queryParam("actor", "string", "Only entries by this actor"),
+					queryParam("target", "string", "Only entries about this target, e.g. a driver ID"),
+					queryParam("from", "integer", "Entry time lower bound in milliseconds (inclusive)"),
+					queryParam("to", "integer", "Entry time upper bound in milliseconds (exclusive)"),
+					queryParam("limit", "integer", "Page size, capped at the configured maximum"),
+				},
+				response: types.AuditLogPage{},
+				errors:   []int{http.StatusBadRequest, http.StatusNotImplemented},
+			},
+		)
+	}
+
+	return routes
+}
+
+// adminEnabled reports whether admins can authenticate, with the admin
+// token or a JWT
+func (s *Server) adminEnabled() bool {
+	return s.config.AdminToken != "" || s.auth != nil
+}
+
+// Start serves requests in the background until the server is shut down
+func (s *Server) Start() {
+	go func() {
+		slog.Info("HTTP API listening", "address", s.config.Address)
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server error", "error", err)
+		}
+	}()
+}
+
+// Shutdown stops accepting requests and waits for active ones to complete
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// handleLiveness reports that the process is up and serving requests
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// handleReadiness reports whether every storage and broker connection is up
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	components := s.service.ComponentHealth(ctx)
+
+	status := http.StatusOK
+	for _, healthy := range components {
+		if !healthy {
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	state := "ready"
+	if status != http.StatusOK {
+		state = "not_ready"
+	}
+
+	writeJSON(w, status, readinessResponse{Status: state, Components: components})
+}
+
+// handleHealth returns the detailed health status of the service
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	writeJSON(w, http.StatusOK, s.service.GetHealthStatus(ctx))
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error writing HTTP response", "error", err)
+	}
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// fakeService is an in-memory Service used by the handler tests
+type fakeService struct {
+	components     map[string]bool
+	healthDeadline bool
+	simplification types.SimplificationSettings
+	updateErr      error
+	tripQuery      types.TripQuery
+	tripPage       types.TripPage
+	tripErr        error
+	trip           *types.StoredTrip
+	stops          []types.Stop
+	points         []types.TrackPoint
+	geoQuery       types.TripGeoQuery
+	statsQuery     types.TripStatsQuery
+	live           []types.LivePosition
+	etas           []types.VehicleETA
+	headways       []types.VehicleHeadway
+	nearestN       int
+	nearestRadius  float64
+	shiftQuery     types.ShiftQuery
+	shifts         []types.Shift
+	assignQuery    types.AssignmentQuery
+	assignments    []types.VehicleAssignment
+	odometerQuery  types.OdometerQuery
+	odometer       []types.DailyDistance
+	heatmapQuery   types.HeatmapQuery
+	heatmap        []types.HeatmapCell
+	deletedDriver  string
+	deleteActor    string
+	stream         *service.LiveStream
+	events         *service.FleetEvents
+	failures       types.FailureReport
+	auditQuery     types.AuditQuery
+	audit          []types.AuditEntry
+	cluster        types.ClusterStatus
+	runtime        types.RuntimeSettings
+}
+
+func (f *fakeService) GetHealthStatus(ctx context.Context) map[string]interface{} {
+	return map[string]interface{}{
+		"service":   "running",
+		"databases": f.components,
+	}
+}
+
+func (f *fakeService) ComponentHealth(ctx context.Context) map[string]bool {
+	_, f.healthDeadline = ctx.Deadline()
+	return f.components
+}
+
+func (f *fakeService) SimplificationSettings() types.SimplificationSettings {
+	return f.simplification
+}
+
+func (f *fakeService) UpdateSimplification(ctx context.Context, update types.SimplificationSettings, actor string) (types.SimplificationSettings, error) {
+	if f.updateErr != nil {
+		return types.SimplificationSettings{}, f.updateErr
+	}
+	if update.Tolerance != 0 {
+		f.simplification.Tolerance = update.Tolerance
+	}
+	if update.Algorithm != "" {
+		f.simplification.Algorithm = update.Algorithm
+	}
+	return f.simplification, nil
+}
+
+func (f *fakeService) RuntimeSettings() types.RuntimeSettings {
+	return f.runtime
+}
+
+func (f *fakeService) UpdateRuntimeSettings(ctx context.Context, settings types.RuntimeSettings, actor string) (types.RuntimeSettings, error) {
+	if f.updateErr != nil {
+		return types.RuntimeSettings{}, f.updateErr
+	}
+	f.runtime = settings
+	return f.runtime, nil
+}
+
+func (f *fakeService) FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error) {
+	f.tripQuery = query
+	return f.tripPage, f.tripErr
+}
+
+func (f *fakeService) TripWithStops(ctx context.Context, id string) (*types.StoredTrip, []types.Stop, error) {
+	if f.trip == nil || f.trip.ID != id {
+		return nil, nil, service.ErrTripNotFound
+	}
+	return f.trip, f.stops, nil
+}
+
+func (f *fakeService) TripPoints(ctx context.Context, id string) (*types.StoredTrip, []types.TrackPoint, error) {
+	if f.trip == nil || f.trip.ID != id {
+		return nil, nil, service.ErrTripNotFound
+	}
+	if len(f.points) == 0 {
+		return nil, nil, service.ErrRawPointsUnavailable
+	}
+	return f.trip, f.points, nil
+}
+
+func (f *fakeService) SearchTrips(ctx context.Context, query types.TripGeoQuery) ([]types.StoredTrip, error) {
+	f.geoQuery = query
+	return f.tripPage.Trips, f.tripErr
+}
+
+func (f *fakeService) TripStats(ctx context.Context, query types.TripStatsQuery) (types.TripStatsReport, error) {
+	f.statsQuery = query
+	return types.TripStatsReport{GroupBy: query.GroupBy}, f.tripErr
+}
+
+func (f *fakeService) IdleReport(ctx context.Context, query types.TripStatsQuery) (types.IdleReport, error) {
+	f.statsQuery = query
+	return types.IdleReport{GroupBy: query.GroupBy}, f.tripErr
+}
+
+func (f *fakeService) ZoneTimeReport(ctx context.Context, query types.TripStatsQuery) (types.ZoneTimeReport, error) {
+	f.statsQuery = query
+	return types.ZoneTimeReport{GroupBy: query.GroupBy}, f.tripErr
+}
+
+func (f *fakeService) DeleteDriverData(ctx context.Context, driverID, actor string) (types.DriverDeletionReport, error) {
+	f.deletedDriver, f.deleteActor = driverID, actor
+	return types.DriverDeletionReport{DriverID: driverID, Trips: 2}, f.tripErr
+}
+
+func (f *fakeService) AuditLog(ctx context.Context, query types.AuditQuery) (types.AuditLogPage, error) {
+	f.auditQuery = query
+	return types.AuditLogPage{Entries: f.audit}, f.tripErr
+}
+
+func (f *fakeService) RecentFailures() types.FailureReport {
+	return f.failures
+}
+
+func (f *fakeService) ClusterStatus(ctx context.Context) (types.ClusterStatus, error) {
+	return f.cluster, f.tripErr
+}
+
+func (f *fakeService) SubscribeLive(filter service.StreamFilter) *service.LiveSubscription {
+	if f.stream == nil {
+		f.stream = service.NewLiveStream(0)
+	}
+	return f.stream.Subscribe(filter)
+}
+
+func (f *fakeService) SubscribeEvents(filter service.StreamFilter) *service.EventSubscription {
+	if f.events == nil {
+		f.events = service.NewFleetEvents(0, 0)
+	}
+	return f.events.Subscribe(filter)
+}
+
+func (f *fakeService) LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error) {
+	for _, position := range f.live {
+		if position.DriverID == driverID {
+			return &position, nil
+		}
+	}
+	return nil, service.ErrDriverNotFound
+}
+
+func (f *fakeService) RouteETAs(ctx context.Context, routeID, stopID string) ([]types.VehicleETA, error) {
+	if f.etas == nil {
+		return nil, service.ErrETADisabled
+	}
+	etas := []types.VehicleETA{}
+	for _, eta := range f.etas {
+		if eta.CurrentRouteID == routeID {
+			etas = append(etas, eta)
+		}
+	}
+	return etas, nil
+}
+
+func (f *fakeService) RouteHeadways(ctx context.Context, routeID string) ([]types.VehicleHeadway, error) {
+	if f.headways == nil {
+		return nil, service.ErrHeadwaysDisabled
+	}
+	headways := []types.VehicleHeadway{}
+	for _, headway := range f.headways {
+		if headway.CurrentRouteID == routeID {
+			headways = append(headways, headway)
+		}
+	}
+	return headways, nil
+}
+
+func (f *fakeService) Shifts(ctx context.Context, query types.ShiftQuery) (types.ShiftPage, error) {
+	if f.shifts == nil {
+		return types.ShiftPage{}, service.ErrShiftsDisabled
+	}
+	f.shiftQuery = query
+	return types.ShiftPage{DriverID: query.DriverID, Shifts: f.shifts}, nil
+}
+
+func (f *fakeService) Assignments(ctx context.Context, query types.AssignmentQuery) (types.AssignmentPage, error) {
+	if f.assignments == nil {
+		return types.AssignmentPage{}, service.ErrAssignmentsUnsupported
+	}
+	f.assignQuery = query
+	return types.AssignmentPage{Assignments: f.assignments}, nil
+}
+
+func (f *fakeService) Odometer(ctx context.Context, query types.OdometerQuery) (types.OdometerReport, error) {
+	if f.odometer == nil {
+		return types.OdometerReport{}, service.ErrOdometerDisabled
+	}
+	f.odometerQuery = query
+	report := types.OdometerReport{DriverID: query.DriverID, VehicleID: query.VehicleID, Days: f.odometer}
+	for _, day := range f.odometer {
+		report.DistanceMeters += day.DistanceMeters
+	}
+	return report, nil
+}
+
+func (f *fakeService) Heatmap(ctx context.Context, query types.HeatmapQuery) (types.HeatmapReport, error) {
+	if f.heatmap == nil {
+		return types.HeatmapReport{}, service.ErrHeatmapDisabled
+	}
+	f.heatmapQuery = query
+	return types.HeatmapReport{Period: query.Period, Start: query.Date, Precision: 7, Cells: f.heatmap}, nil
+}
+
+func (f *fakeService) RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error) {
+	positions := []types.LivePosition{}
+	for _, position := range f.live {
+		if position.CurrentRouteID == routeID {
+			positions = append(positions, position)
+		}
+	}
+	return positions, nil
+}
+
+func (f *fakeService) FleetSnapshot(ctx context.Context) (types.FleetSnapshot, error) {
+	snapshot := types.FleetSnapshot{Vehicles: []types.LivePosition{}}
+	for _, position := range f.live {
+		if position.Status != "finished" {
+			snapshot.Vehicles = append(snapshot.Vehicles, position)
+			if position.Stale {
+				snapshot.Stale++
+			}
+		}
+	}
+	snapshot.Total = len(snapshot.Vehicles)
+	return snapshot, nil
+}
+
+func (f *fakeService) NearestVehicles(ctx context.Context, location types.Location, n int, radiusMeters float64) ([]types.LivePosition, error) {
+	if f.live == nil {
+		return nil, service.ErrLiveTrackingDisabled
+	}
+	f.nearestN, f.nearestRadius = n, radiusMeters
+	return f.live[:min(n, len(f.live))], nil
+}
+
+func serve(t *testing.T, svc Service, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	NewServer(types.HTTPConfig{}, svc).Handler().ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+func TestLiveness(t *testing.T) {
+	svc := &fakeService{components: map[string]bool{"redis": false}}
+
+	recorder := serve(t, svc, http.MethodGet, "/healthz")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected liveness to succeed while dependencies are down, got %d", recorder.Code)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		components map[string]bool
+		want       int
+	}{
+		{"all healthy", map[string]bool{"redis": true, "mongodb": true, "mqtt": true}, http.StatusOK},
+		{"mqtt down", map[string]bool{"redis": true, "mongodb": true, "mqtt": false}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(t, &fakeService{components: tt.components}, http.MethodGet, "/readyz")
+			if recorder.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d", tt.want, recorder.Code)
+			}
+
+			var body struct {
+				Components map[string]bool `json:"components"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode readiness response: %v", err)
+			}
+			if len(body.Components) != len(tt.components) {
+				t.Errorf("Expected %d components, got %d", len(tt.components), len(body.Components))
+			}
+		})
+	}
+}
+
+func TestReadiness_BoundsPings(t *testing.T) {
+	svc := &fakeService{components: map[string]bool{"redis": true}}
+	serve(t, svc, http.MethodGet, "/readyz")
+
+	if !svc.healthDeadline {
+		t.Error("Expected the backend pings to run under a deadline")
+	}
+}
+
+func TestHealthRejectsOtherMethods(t *testing.T) {
+	recorder := serve(t, &fakeService{}, http.MethodPost, "/health")
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status %d, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
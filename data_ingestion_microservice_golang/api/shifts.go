@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// handleDriverShifts returns the shifts of a driver, newest first, with their
+// trips and breaks
+func (s *Server) handleDriverShifts(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := types.ShiftQuery{DriverID: r.PathValue("id")}
+
+	var err error
+	if query.From, err = parseMillis(params.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if query.To, err = parseMillis(params.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if query.Limit, err = s.parseLimit(params.Get("limit")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.service.Shifts(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrShiftsDisabled):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error reading shifts", "driverId", query.DriverID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read shifts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestDriverShifts(t *testing.T) {
+	svc := &fakeService{shifts: []types.Shift{{ID: "driver_001:2022-01-01", DriverID: "driver_001", Inferred: true, TripCount: 2}}}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/drivers/driver_001/shifts?from=1000&to=2000&limit=5")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.ShiftQuery{DriverID: "driver_001", From: 1000, To: 2000, Limit: 5}
+	if svc.shiftQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.shiftQuery)
+	}
+
+	var page types.ShiftPage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if page.DriverID != "driver_001" || len(page.Shifts) != 1 || page.Shifts[0].TripCount != 2 {
+		t.Errorf("Unexpected shifts %+v", page)
+	}
+}
+
+func TestDriverShifts_Errors(t *testing.T) {
+	recorder := serve(t, &fakeService{shifts: []types.Shift{}}, http.MethodGet, "/v1/drivers/driver_001/shifts?from=yesterday")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = serve(t, &fakeService{}, http.MethodGet, "/v1/drivers/driver_001/shifts")
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d when shifts are disabled, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// handleTripStats returns trip counts, distance, average compression ratio,
+// and average duration per day, driver, or route
+func (s *Server) handleTripStats(w http.ResponseWriter, r *http.Request) {
+	query, err := parseStatsQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.service.TripStats(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error aggregating trips", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to aggregate trips")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleIdleReport returns the idle time of trips per day, driver, or route
+func (s *Server) handleIdleReport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseStatsQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.service.IdleReport(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error aggregating idle time", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to aggregate idle time")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleZoneTimeReport returns the time trips spent in each zone per day,
+// driver, or route
+func (s *Server) handleZoneTimeReport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseStatsQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.service.ZoneTimeReport(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error aggregating zone time", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to aggregate zone time")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseStatsQuery reads the grouping and time range of a stats request,
+// grouping by day by default
+func parseStatsQuery(r *http.Request) (types.TripStatsQuery, error) {
+	params := r.URL.Query()
+	query := types.TripStatsQuery{GroupBy: params.Get("groupBy")}
+	if query.GroupBy == "" {
+		query.GroupBy = database.StatsGroupByDay
+	}
+	if !database.ValidStatsGroupBy(query.GroupBy) {
+		return query, fmt.Errorf("invalid groupBy %q, expected day, driver, or route", query.GroupBy)
+	}
+
+	var err error
+	if query.From, err = parseMillis(params.Get("from")); err != nil {
+		return query, fmt.Errorf("invalid from: %w", err)
+	}
+	if query.To, err = parseMillis(params.Get("to")); err != nil {
+		return query, fmt.Errorf("invalid to: %w", err)
+	}
+	return query, nil
+}
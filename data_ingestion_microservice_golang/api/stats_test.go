@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+func TestTripStats(t *testing.T) {
+	svc := &fakeService{}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/stats?groupBy=driver&from=1000&to=2000")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.TripStatsQuery{GroupBy: "driver", From: 1000, To: 2000}
+	if svc.statsQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.statsQuery)
+	}
+
+	recorder = serve(t, svc, http.MethodGet, "/v1/stats")
+	if recorder.Code != http.StatusOK || svc.statsQuery.GroupBy != "day" {
+		t.Errorf("Expected daily stats by default, got %d %+v", recorder.Code, svc.statsQuery)
+	}
+}
+
+func TestTripStats_BadRequests(t *testing.T) {
+	for _, target := range []string{"/v1/stats?groupBy=week", "/v1/stats?from=-5"} {
+		recorder := serve(t, &fakeService{}, http.MethodGet, target)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
+
+func TestIdleReport(t *testing.T) {
+	svc := &fakeService{}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/idle?groupBy=route&from=1000")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.TripStatsQuery{GroupBy: "route", From: 1000}
+	if svc.statsQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.statsQuery)
+	}
+
+	recorder = serve(t, &fakeService{}, http.MethodGet, "/v1/idle?groupBy=vehicle")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = serve(t, &fakeService{tripErr: service.ErrTripQueriesUnsupported}, http.MethodGet, "/v1/idle")
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+}
+
+func TestZoneTimeReport(t *testing.T) {
+	svc := &fakeService{}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/zone-time?groupBy=driver&to=2000")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	want := types.TripStatsQuery{GroupBy: "driver", To: 2000}
+	if svc.statsQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.statsQuery)
+	}
+
+	recorder = serve(t, &fakeService{}, http.MethodGet, "/v1/zone-time?from=yesterday")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = serve(t, &fakeService{tripErr: service.ErrTripQueriesUnsupported}, http.MethodGet, "/v1/zone-time")
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+}
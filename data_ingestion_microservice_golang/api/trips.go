@@ -0,0 +1,250 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/export"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+)
+
+// handleListTrips returns one page of stored trips. Results are ordered by
+// trip timestamp and paged with the opaque nextCursor of the previous page.
+func (s *Server) handleListTrips(w http.ResponseWriter, r *http.Request) {
+	query, err := s.parseTripQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.service.FindTrips(r.Context(), query)
+	switch {
+	case errors.Is(err, database.ErrInvalidCursor):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error querying trips", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to query trips")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleExportTrip renders a trip's simplified route and stops as GPX,
+// GeoJSON, or KML
+func (s *Server) handleExportTrip(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatGeoJSON
+	}
+	if export.ContentType(format) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid format %q, expected gpx, geojson, or kml", format))
+		return
+	}
+
+	trip, stops, err := s.service.TripWithStops(r.Context(), id)
+	switch {
+	case errors.Is(err, service.ErrTripNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error loading trip", "tripId", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to load trip")
+		return
+	}
+
+	data, err := export.RenderTrip(format, *trip, stops)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rendering trip", "tripId", id, "format", format, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to render trip")
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, id, format))
+	w.Write(data)
+}
+
+// handleSearchTrips returns trips whose route intersects a bounding box
+// (bbox=minLon,minLat,maxLon,maxLat) or passes within radius meters of a
+// point (near=lat,lon)
+func (s *Server) handleSearchTrips(w http.ResponseWriter, r *http.Request) {
+	query, err := s.parseTripGeoQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trips, err := s.service.SearchTrips(r.Context(), query)
+	switch {
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error searching trips", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to search trips")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tripSearchResponse{Trips: trips})
+}
+
+// parseTripGeoQuery reads the search area, time range, and limit of a request
+func (s *Server) parseTripGeoQuery(r *http.Request) (types.TripGeoQuery, error) {
+	params := r.URL.Query()
+	query := types.TripGeoQuery{Limit: s.config.DefaultPageSize}
+
+	bbox, near := params.Get("bbox"), params.Get("near")
+	switch {
+	case bbox != "" && near != "":
+		return query, fmt.Errorf("bbox and near cannot be combined")
+	case bbox != "":
+		box, err := parseBoundingBox(bbox)
+		if err != nil {
+			return query, err
+		}
+		query.BoundingBox = box
+	case near != "":
+		values, err := parseFloats(near, 2)
+		if err != nil || !validLocation(values[0], values[1]) {
+			return query, fmt.Errorf("invalid near %q, expected lat,lon", near)
+		}
+		query.Near = &types.Location{Latitude: values[0], Longitude: values[1]}
+		query.RadiusMeters, err = strconv.ParseFloat(params.Get("radius"), 64)
+		if err != nil || query.RadiusMeters <= 0 {
+			return query, fmt.Errorf("near requires a positive radius in meters")
+		}
+	default:
+		return query, fmt.Errorf("either bbox or near is required")
+	}
+
+	var err error
+	if query.From, err = parseMillis(params.Get("from")); err != nil {
+		return query, fmt.Errorf("invalid from: %w", err)
+	}
+	if query.To, err = parseMillis(params.Get("to")); err != nil {
+		return query, fmt.Errorf("invalid to: %w", err)
+	}
+	if query.Limit, err = s.parseLimit(params.Get("limit")); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// parseTripQuery reads the trip filters and paging parameters of a request
+func (s *Server) parseTripQuery(r *http.Request) (types.TripQuery, error) {
+	params := r.URL.Query()
+	query := types.TripQuery{
+		DriverID:  params.Get("driverId"),
+		VehicleID: params.Get("vehicleId"),
+		RouteID:   params.Get("routeId"),
+		Cursor:    params.Get("cursor"),
+	}
+
+	var err error
+	if query.From, err = parseMillis(params.Get("from")); err != nil {
+		return query, fmt.Errorf("invalid from: %w", err)
+	}
+	if query.To, err = parseMillis(params.Get("to")); err != nil {
+		return query, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if value := params.Get("minQuality"); value != "" {
+		if query.MinQuality, err = strconv.ParseFloat(value, 64); err != nil || query.MinQuality < 0 || query.MinQuality > 100 {
+			return query, fmt.Errorf("invalid minQuality %q, expected 0 to 100", value)
+		}
+	}
+	query.WithoutFlag = params.Get("withoutFlag")
+
+	if query.Limit, err = s.parseLimit(params.Get("limit")); err != nil {
+		return query, err
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "desc":
+		query.Descending = true
+	case "asc":
+	default:
+		return query, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+
+	return query, nil
+}
+
+// parseLimit parses an optional page size, capped at the configured maximum
+func (s *Server) parseLimit(value string) (int, error) {
+	limit := s.config.DefaultPageSize
+	if value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return 0, fmt.Errorf("invalid limit %q", value)
+		}
+	}
+	if s.config.MaxPageSize > 0 && limit > s.config.MaxPageSize {
+		limit = s.config.MaxPageSize
+	}
+	return limit, nil
+}
+
+// parseFloats parses exactly n comma-separated numbers
+func parseFloats(value string, n int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated numbers", n)
+	}
+	values := make([]float64, n)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// validLocation reports whether a latitude and longitude are in range
+func validLocation(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// parseBoundingBox parses a minLon,minLat,maxLon,maxLat bounding box
+func parseBoundingBox(value string) (*types.BoundingBox, error) {
+	values, err := parseFloats(value, 4)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bbox, expected minLon,minLat,maxLon,maxLat: %w", err)
+	}
+	box := types.BoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if box.MinLon >= box.MaxLon || box.MinLat >= box.MaxLat || !validLocation(box.MinLat, box.MinLon) || !validLocation(box.MaxLat, box.MaxLon) {
+		return nil, fmt.Errorf("invalid bbox %q", value)
+	}
+	return &box, nil
+}
+
+// parseMillis parses an optional millisecond timestamp
+func parseMillis(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || millis < 0 {
+		return 0, fmt.Errorf("expected milliseconds since the epoch, got %q", value)
+	}
+	return millis, nil
+}
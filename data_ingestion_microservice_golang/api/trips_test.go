@@ -0,0 +1,148 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestListTrips_ParsesQuery(t *testing.T) {
+	svc := &fakeService{tripPage: types.TripPage{Trips: []types.StoredTrip{{ID: "trip-1"}}, NextCursor: "next"}}
+	server := NewServer(types.HTTPConfig{DefaultPageSize: 100, MaxPageSize: 500}, svc)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/trips?driverId=driver_001&routeId=route_123&from=1000&to=2000&limit=1000&order=asc&cursor=abc", nil)
+	server.Handler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+
+	want := types.TripQuery{DriverID: "driver_001", RouteID: "route_123", From: 1000, To: 2000, Limit: 500, Cursor: "abc"}
+	if svc.tripQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, svc.tripQuery)
+	}
+}
+
+func TestListTrips_QualityFilters(t *testing.T) {
+	svc := &fakeService{}
+
+	recorder := serve(t, svc, http.MethodGet, "/v1/trips?minQuality=75.5&withoutFlag=has_gaps")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if svc.tripQuery.MinQuality != 75.5 || svc.tripQuery.WithoutFlag != "has_gaps" {
+		t.Errorf("Expected the quality filters, got %+v", svc.tripQuery)
+	}
+}
+
+func TestListTrips_Defaults(t *testing.T) {
+	svc := &fakeService{}
+	server := NewServer(types.HTTPConfig{DefaultPageSize: 100, MaxPageSize: 500}, svc)
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/trips", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if svc.tripQuery.Limit != 100 || !svc.tripQuery.Descending {
+		t.Errorf("Expected newest first with 100 trips per page, got %+v", svc.tripQuery)
+	}
+}
+
+func TestListTrips_BadRequests(t *testing.T) {
+	targets := []string{
+		"/v1/trips?limit=0",
+		"/v1/trips?limit=many",
+		"/v1/trips?from=yesterday",
+		"/v1/trips?order=sideways",
+		"/v1/trips?minQuality=101",
+		"/v1/trips?minQuality=good",
+	}
+	for _, target := range targets {
+		recorder := serve(t, &fakeService{}, http.MethodGet, target)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, recorder.Code)
+		}
+	}
+
+	recorder := serve(t, &fakeService{tripErr: database.ErrInvalidCursor}, http.MethodGet, "/v1/trips?cursor=bogus")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid cursor, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestExportTrip(t *testing.T) {
+	svc := &fakeService{trip: &types.StoredTrip{
+		ID:              "trip-1",
+		SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}}, {Location: types.Location{Latitude: 6.25, Longitude: -75.59}}},
+	}}
+
+	tests := []struct {
+		target      string
+		status      int
+		contentType string
+	}{
+		{"/v1/trips/trip-1/export?format=gpx", http.StatusOK, "application/gpx+xml"},
+		{"/v1/trips/trip-1/export?format=kml", http.StatusOK, "application/vnd.google-earth.kml+xml"},
+		{"/v1/trips/trip-1/export", http.StatusOK, "application/geo+json"},
+		{"/v1/trips/trip-1/export?format=shp", http.StatusBadRequest, "application/json"},
+		{"/v1/trips/missing/export?format=gpx", http.StatusNotFound, "application/json"},
+	}
+
+	for _, tt := range tests {
+		recorder := serve(t, svc, http.MethodGet, tt.target)
+		if recorder.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.status, recorder.Code)
+		}
+		if got := recorder.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: expected content type %s, got %s", tt.target, tt.contentType, got)
+		}
+	}
+}
+
+func TestSearchTrips_ParsesArea(t *testing.T) {
+	svc := &fakeService{}
+	server := NewServer(types.HTTPConfig{DefaultPageSize: 100, MaxPageSize: 500}, svc)
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/trips/search?bbox=-75.59,6.24,-75.58,6.25&from=1000&to=2000", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	wantBox := types.BoundingBox{MinLon: -75.59, MinLat: 6.24, MaxLon: -75.58, MaxLat: 6.25}
+	if svc.geoQuery.BoundingBox == nil || *svc.geoQuery.BoundingBox != wantBox || svc.geoQuery.From != 1000 || svc.geoQuery.To != 2000 {
+		t.Errorf("Unexpected query %+v", svc.geoQuery)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/trips/search?near=6.2442,-75.5812&radius=150&limit=10", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	wantNear := types.Location{Latitude: 6.2442, Longitude: -75.5812}
+	if svc.geoQuery.Near == nil || *svc.geoQuery.Near != wantNear || svc.geoQuery.RadiusMeters != 150 || svc.geoQuery.Limit != 10 {
+		t.Errorf("Unexpected query %+v", svc.geoQuery)
+	}
+}
+
+func TestSearchTrips_BadRequests(t *testing.T) {
+	targets := []string{
+		"/v1/trips/search",
+		"/v1/trips/search?bbox=1,2,3",
+		"/v1/trips/search?bbox=-75.58,6.24,-75.59,6.25",
+		"/v1/trips/search?near=6.2442,-75.5812",
+		"/v1/trips/search?near=96,-75.5812&radius=100",
+		"/v1/trips/search?near=6.2442,-75.5812&radius=100&bbox=-75.59,6.24,-75.58,6.25",
+	}
+	for _, target := range targets {
+		recorder := serve(t, &fakeService{}, http.MethodGet, target)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/auth"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket keepalive: clients must answer a ping within pongWait
+const (
+	pongWait     = 60 * time.Second
+	pingInterval = pongWait * 9 / 10
+)
+
+// liveCommand is a message sent by WebSocket clients to change their
+// subscriptions
+type liveCommand struct {
+	Action    string           `json:"action"`
+	RouteIDs  []string         `json:"routeIds"`
+	DriverIDs []string         `json:"driverIds"`
+	Geofence  []types.Location `json:"geofence"`
+}
+
+// liveMessage is a message sent to WebSocket clients: a location, the
+// current subscriptions, or an error
+type liveMessage struct {
+	Type      string            `json:"type"`
+	Location  *types.BusMessage `json:"location,omitempty"`
+	RouteIDs  []string          `json:"routeIds,omitempty"`
+	DriverIDs []string          `json:"driverIds,omitempty"`
+	Geofence  []types.Location  `json:"geofence,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// handleLiveWebSocket streams the locations matching the routeId, driverId,
+// and geofence parameters. Clients change their subscriptions by sending
+// {"action":"subscribe"|"unsubscribe","routeIds":[...],"driverIds":[...]}
+// or {"action":"geofence","geofence":[...]}; every change is answered with
+// the full subscriptions. Clients that fall too far behind, or whose token
+// expires, are disconnected with a policy violation close code.
+func (s *Server) handleLiveWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := streamFilterParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already wrote an error response
+		return
+	}
+	defer conn.Close()
+
+	sub := s.service.SubscribeLive(filter)
+	defer sub.Close()
+
+	// Only this goroutine writes to the connection; the reader hands
+	// subscription changes over through replies
+	replies := make(chan liveMessage, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		for {
+			var command liveCommand
+			if err := conn.ReadJSON(&command); err != nil {
+				return
+			}
+
+			reply := s.applyLiveCommand(sub, command)
+
+			select {
+			case replies <- reply:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	if err := s.writeLive(conn, subscriptionsMessage(sub.Filter())); err != nil {
+		return
+	}
+	for {
+		select {
+		case busMsg, ok := <-sub.Updates():
+			if !ok {
+				if sub.Dropped() {
+					s.closeLive(conn, websocket.ClosePolicyViolation, "client too slow")
+				}
+				return
+			}
+			if err := s.writeLive(conn, liveMessage{Type: "location", Location: &busMsg}); err != nil {
+				return
+			}
+		case reply := <-replies:
+			if err := s.writeLive(conn, reply); err != nil {
+				return
+			}
+		case <-ping.C:
+			deadline := time.Now().Add(s.config.StreamWriteTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			if errors.Is(context.Cause(r.Context()), auth.ErrTokenExpired) {
+				s.closeLive(conn, websocket.ClosePolicyViolation, "token expired")
+			}
+			return
+		}
+	}
+}
+
+// applyLiveCommand changes a subscription and returns the reply: the full
+// subscriptions, or an error
+func (s *Server) applyLiveCommand(sub *service.LiveSubscription, command liveCommand) liveMessage {
+	change := service.StreamFilter{RouteIDs: command.RouteIDs, DriverIDs: command.DriverIDs, Geofence: command.Geofence}
+	if err := change.Validate(); err != nil {
+		return liveMessage{Type: "error", Error: err.Error()}
+	}
+
+	switch command.Action {
+	case "subscribe":
+		sub.Add(change)
+	case "unsubscribe":
+		sub.Remove(change)
+	case "geofence":
+		sub.SetGeofence(command.Geofence)
+	default:
+		return liveMessage{Type: "error", Error: "unknown action " + command.Action}
+	}
+	return subscriptionsMessage(sub.Filter())
+}
+
+// writeLive writes a message, giving up on clients that stop reading
+func (s *Server) writeLive(conn *websocket.Conn, message liveMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(s.config.StreamWriteTimeout))
+	if err := conn.WriteJSON(message); err != nil {
+		slog.Info("Closing live WebSocket", "remoteAddr", conn.RemoteAddr().String(), "error", err)
+		return err
+	}
+	return nil
+}
+
+// closeLive sends a close frame with the given code and reason
+func (s *Server) closeLive(conn *websocket.Conn, code int, reason string) {
+	deadline := time.Now().Add(s.config.StreamWriteTimeout)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
+
+// checkOrigin accepts same-origin requests, requests without an Origin
+// header, and origins listed in the configuration ("*" allows any origin)
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(s.config.AllowedOrigins, "*") || slices.Contains(s.config.AllowedOrigins, origin) {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// subscriptionsMessage describes the filter of a live subscription
+func subscriptionsMessage(filter service.StreamFilter) liveMessage {
+	return liveMessage{Type: "subscriptions", RouteIDs: filter.RouteIDs, DriverIDs: filter.DriverIDs, Geofence: filter.Geofence}
+}
+
+// streamFilterParams reads a stream filter from repeated or comma-separated
+// routeId and driverId parameters and a geofence of comma-separated lat,lon
+// vertex pairs
+func streamFilterParams(params url.Values) (service.StreamFilter, error) {
+	filter := service.StreamFilter{
+		RouteIDs:  listParam(params, "routeId"),
+		DriverIDs: listParam(params, "driverId"),
+	}
+
+	if geofence := params.Get("geofence"); geofence != "" {
+		n := strings.Count(geofence, ",") + 1
+		values, err := parseFloats(geofence, n)
+		if err != nil || n%2 != 0 {
+			return filter, fmt.Errorf("invalid geofence %q, expected lat,lon pairs", geofence)
+		}
+		for i := 0; i < n; i += 2 {
+			filter.Geofence = append(filter.Geofence, types.Location{Latitude: values[i], Longitude: values[i+1]})
+		}
+	}
+	return filter, filter.Validate()
+}
+
+// streamFilterParameters documents the parameters read by streamFilterParams
+func streamFilterParameters(routes, drivers string) []parameter {
+	return []parameter{
+		queryParam("routeId", "string", routes+", repeated or comma-separated"),
+		queryParam("driverId", "string", drivers+", repeated or comma-separated"),
+		queryParam("geofence", "string", "Polygon as comma-separated lat,lon vertex pairs; only locations inside it"),
+	}
+}
+
+// listParam returns the values of a repeated or comma-separated parameter
+func listParam(params url.Values, name string) []string {
+	var values []string
+	for _, value := range params[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
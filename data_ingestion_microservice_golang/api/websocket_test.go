@@ -0,0 +1,152 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/types"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialLive(t *testing.T, svc Service, query string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(NewServer(types.HTTPConfig{}, svc).Handler())
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/live"+query, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readLive(t *testing.T, conn *websocket.Conn) liveMessage {
+	t.Helper()
+	var message liveMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return message
+}
+
+func TestLiveWebSocket_StreamsSubscribedRoutes(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	conn := dialLive(t, svc, "?routeId=route_1,route_2")
+
+	if message := readLive(t, conn); message.Type != "subscriptions" || len(message.RouteIDs) != 2 {
+		t.Fatalf("Expected both routes to be subscribed, got %+v", message)
+	}
+
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_001", CurrentRouteID: "route_3"})
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_002", CurrentRouteID: "route_1"})
+	message := readLive(t, conn)
+	if message.Type != "location" || message.Location.DriverID != "driver_002" {
+		t.Fatalf("Expected the route_1 location, got %+v", message)
+	}
+
+	if err := conn.WriteJSON(liveCommand{Action: "unsubscribe", RouteIDs: []string{"route_1"}}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	if message := readLive(t, conn); message.Type != "subscriptions" || len(message.RouteIDs) != 1 || message.RouteIDs[0] != "route_2" {
+		t.Fatalf("Expected only route_2 to remain subscribed, got %+v", message)
+	}
+
+	if err := conn.WriteJSON(liveCommand{Action: "bogus"}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	if message := readLive(t, conn); message.Type != "error" {
+		t.Errorf("Expected an error for an unknown action, got %+v", message)
+	}
+}
+
+func TestLiveWebSocket_FiltersDriversAndGeofence(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	conn := dialLive(t, svc, "?driverId=driver_001,driver_002")
+
+	if message := readLive(t, conn); message.Type != "subscriptions" || len(message.DriverIDs) != 2 {
+		t.Fatalf("Expected both drivers to be subscribed, got %+v", message)
+	}
+
+	geofence := []types.Location{{Latitude: 6.0, Longitude: -76.0}, {Latitude: 6.0, Longitude: -75.0}, {Latitude: 7.0, Longitude: -75.0}, {Latitude: 7.0, Longitude: -76.0}}
+	if err := conn.WriteJSON(liveCommand{Action: "geofence", Geofence: geofence}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	if message := readLive(t, conn); message.Type != "subscriptions" || len(message.Geofence) != 4 {
+		t.Fatalf("Expected the geofence to be set, got %+v", message)
+	}
+
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_001", DriverLocation: types.Location{Latitude: 4.6, Longitude: -74.1}})
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_003", DriverLocation: types.Location{Latitude: 6.2, Longitude: -75.5}})
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_002", DriverLocation: types.Location{Latitude: 6.2, Longitude: -75.5}})
+	if message := readLive(t, conn); message.Type != "location" || message.Location.DriverID != "driver_002" {
+		t.Fatalf("Expected the driver_002 location inside the geofence, got %+v", message)
+	}
+
+	if err := conn.WriteJSON(liveCommand{Action: "geofence", Geofence: geofence[:2]}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	if message := readLive(t, conn); message.Type != "error" {
+		t.Errorf("Expected an error for a two-vertex geofence, got %+v", message)
+	}
+}
+
+func TestLiveWebSocket_RejectsInvalidFilters(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	for _, target := range []string{"/ws/live", "/ws/live?geofence=6,-76,7,-75", "/ws/live?geofence=6,-76,7,-75,7"} {
+		if recorder := serve(t, svc, http.MethodGet, target); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, recorder.Code)
+		}
+	}
+}
+
+func TestLiveWebSocket_ClosesSlowClients(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(1)}
+	conn := dialLive(t, svc, "?routeId=route_1")
+	readLive(t, conn)
+
+	// Without reading, the second location overflows the buffer
+	for i := 0; i < 3; i++ {
+		svc.stream.Publish(types.BusMessage{CurrentRouteID: "route_1"})
+	}
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("Expected a policy violation close, got %v", err)
+		}
+		return
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	server := NewServer(types.HTTPConfig{AllowedOrigins: []string{"https://dashboard.example.com"}}, &fakeService{})
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://api.example.com", true},
+		{"https://dashboard.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/ws/live", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := server.checkOrigin(req); got != tt.want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
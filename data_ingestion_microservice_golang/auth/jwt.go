@@ -0,0 +1,324 @@
+// Package auth validates the JWT bearer tokens of API clients against the
+// keys of a JWKS endpoint and checks the scopes they grant
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"data-ingestion-microservice/types"
+)
+
+// Scopes granted to API clients
+const (
+	ScopeReadTrips   = "read:trips"
+	ScopeAdminConfig = "admin:config"
+	ScopeDeleteData  = "delete:data"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, not
+// yet valid, for another issuer or audience, or not signed by a JWKS key
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrTokenExpired is the cause of the context of a stream opened with a
+// token that has since expired
+var ErrTokenExpired = errors.New("token expired")
+
+// jwksTimeout bounds a JWKS download, and minJWKSRefetch is how long a token
+// with an unknown key ID waits before the keys are downloaded again, so
+// forged key IDs cannot flood the endpoint
+const (
+	jwksTimeout    = 10 * time.Second
+	minJWKSRefetch = time.Minute
+)
+
+// Claims are the validated claims of a token. ExpiresAt is when the token
+// stops being accepted, its exp claim plus the clock skew; streams opened
+// with it are closed then.
+type Claims struct {
+	Subject   string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the token grants a scope
+func (c Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// Verifier validates JWTs signed with RS256, RS384, RS512, ES256, or ES384
+// by a key of the configured JWKS endpoint. Keys are downloaded on first
+// use, again every JWKSRefresh, and when a token names an unknown key ID.
+// Downloads happen outside mu, so tokens with known keys are validated
+// while the keys are downloaded, and concurrent requests share one
+// download.
+type Verifier struct {
+	config types.AuthConfig
+	client *http.Client
+	now    func() time.Time
+	group  singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]publicKey
+	fetched   time.Time
+	attempted time.Time
+	err       error
+}
+
+// NewVerifier creates a verifier that has not downloaded its keys yet
+func NewVerifier(config types.AuthConfig) *Verifier {
+	return &Verifier{
+		config: config,
+		client: &http.Client{Timeout: jwksTimeout},
+		now:    time.Now,
+	}
+}
+
+// Refresh downloads the keys of the JWKS endpoint
+func (v *Verifier) Refresh(ctx context.Context) error {
+	return v.fetch(ctx)
+}
+
+// fetch replaces the keys with those of the JWKS endpoint, keeping them on
+// failure. Concurrent calls wait for the download in progress, which is
+// bounded by jwksTimeout rather than the context of the request that
+// started it, so one cancelled request does not fail the others.
+func (v *Verifier) fetch(ctx context.Context) error {
+	_, err, _ := v.group.Do("jwks", func() (interface{}, error) {
+		v.mu.Lock()
+		v.attempted = v.now()
+		v.mu.Unlock()
+
+		keys, err := fetchJWKS(context.WithoutCancel(ctx), v.client, v.config.JWKSURL)
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if err != nil {
+			v.err = err
+			return nil, err
+		}
+		v.keys, v.fetched, v.err = keys, v.attempted, nil
+		return nil, nil
+	})
+	return err
+}
+
+// key returns the key with an ID, downloading the keys first when they are
+// stale or the ID is unknown, at most once per minJWKSRefetch. Stale keys
+// are used while the endpoint cannot be reached.
+func (v *Verifier) key(ctx context.Context, kid string) (publicKey, error) {
+	v.mu.Lock()
+	key, ok := v.lookup(kid)
+	fresh := ok && v.now().Sub(v.fetched) < v.config.JWKSRefresh
+	due := v.now().Sub(v.attempted) >= minJWKSRefetch
+	v.mu.Unlock()
+	if fresh {
+		return key, nil
+	}
+	if due {
+		v.fetch(ctx)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok = v.lookup(kid)
+	switch {
+	case ok:
+		return key, nil
+	case v.keys == nil:
+		return publicKey{}, fmt.Errorf("no signing keys: %w", v.err)
+	}
+	return publicKey{}, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// lookup finds a key by ID. Tokens without a key ID use the only key of a
+// JWKS with a single key. v.mu must be held.
+func (v *Verifier) lookup(kid string) (publicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// tokenHeader is the JOSE header of a token
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// tokenClaims are the registered and scope claims of a token. Scopes are
+// read from the space-separated scope claim, or the scp claim as a list or
+// a string.
+type tokenClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+}
+
+// Authenticate validates a token and returns its claims. Failures that are
+// the token's fault wrap ErrInvalidToken; others, such as an unreachable
+// JWKS endpoint, do not.
+func (v *Verifier) Authenticate(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: expected three segments", ErrInvalidToken)
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	hash, ok := algorithmHashes[header.Alg]
+	if !ok {
+		return Claims{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return Claims{}, fmt.Errorf("%w: key %q is for %s, not %s", ErrInvalidToken, header.Kid, key.alg, header.Alg)
+	}
+	digest := hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(key.key, header.Alg, hash, digest.Sum(nil), signature) {
+		return Claims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.validate(claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return Claims{
+		Subject:   claims.Subject,
+		Scopes:    claims.scopes(),
+		ExpiresAt: unixTime(*claims.ExpiresAt).Add(v.config.ClockSkew),
+	}, nil
+}
+
+// validate checks the time, issuer, and audience claims of a token
+func (v *Verifier) validate(claims tokenClaims) error {
+	now := v.now()
+	skew := v.config.ClockSkew
+	switch {
+	case claims.ExpiresAt == nil:
+		return errors.New("no expiry")
+	case now.Add(-skew).After(unixTime(*claims.ExpiresAt)):
+		return errors.New("expired")
+	case claims.NotBefore != nil && now.Add(skew).Before(unixTime(*claims.NotBefore)):
+		return errors.New("not valid yet")
+	case claims.Issuer != v.config.Issuer:
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case !slices.Contains(stringOrList(claims.Audience), v.config.Audience):
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+// scopes returns the scopes a token grants
+func (c tokenClaims) scopes() []string {
+	if c.Scope != "" {
+		return strings.Fields(c.Scope)
+	}
+	var scopes []string
+	for _, scope := range stringOrList(c.Scp) {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+	return scopes
+}
+
+// algorithmHashes maps the supported signing algorithms to their hashes
+var algorithmHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+}
+
+// algorithmCurves maps the ECDSA signing algorithms to the only curve
+// each may be used with
+var algorithmCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+}
+
+// verifySignature checks a signature over a digest with an RSA key, or an
+// EC key on the curve of the algorithm
+func verifySignature(key crypto.PublicKey, alg string, hash crypto.Hash, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS ECDSA signatures are r and s, each the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if algorithmCurves[alg] != key.Curve.Params().Name || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// stringOrList decodes a claim that is either a string or a list of strings
+func stringOrList(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return []string{value}
+	}
+	return nil
+}
+
+// unixTime converts a NumericDate claim to a time
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
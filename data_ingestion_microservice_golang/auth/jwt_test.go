@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+var (
+	rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384, _  = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	ec521, _  = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	testNow   = time.Unix(1700000000, 0)
+)
+
+// serveJWKS serves the public test keys and counts the downloads
+func serveJWKS(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	keys := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		{"kty": "EC", "kid": "ec-384", "crv": "P-384", "x": encode(ec384.X), "y": encode(ec384.Y)},
+		{"kty": "EC", "kid": "ec-521", "crv": "P-521", "x": encode(ec521.X), "y": encode(ec521.Y)},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": encode(rsaKey.N), "e": "AQAB"},
+	}}
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func newTestVerifier(url string) *Verifier {
+	v := NewVerifier(types.AuthConfig{
+		JWKSURL:     url,
+		Issuer:      "https://issuer.example.com/",
+		Audience:    "gps-api",
+		JWKSRefresh: time.Hour,
+		ClockSkew:   time.Minute,
+	})
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+// sign builds a token with the given header and claims
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to encode token: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := segment(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + segment(claims)
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signingInput))
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest.Sum(nil))
+	case "ES256", "ES384":
+		key := ecKey
+		if kid == "ec-384" {
+			key = ec384
+		}
+		digest := algorithmHashes[alg].New()
+		digest.Write([]byte(signingInput))
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns the claims of a token valid at testNow
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "dashboard",
+		"iss":   "https://issuer.example.com/",
+		"aud":   []string{"gps-api", "billing"},
+		"exp":   testNow.Add(time.Hour).Unix(),
+		"nbf":   testNow.Add(-time.Minute).Unix(),
+		"scope": "read:trips admin:config",
+	}
+}
+
+func TestAuthenticate_AcceptsValidTokens(t *testing.T) {
+	server, _ := serveJWKS(t)
+	v := newTestVerifier(server.URL)
+
+	// The P-521 key of the JWKS is unsupported and skipped
+	for _, alg := range []string{"RS256", "ES256", "ES384"} {
+		kid := map[string]string{"RS256": "rsa-1", "ES256": "ec-1", "ES384": "ec-384"}[alg]
+		claims, err := v.Authenticate(context.Background(), sign(t, alg, kid, validClaims()))
+		if err != nil {
+			t.Fatalf("Expected the %s token to be valid, got %v", alg, err)
+		}
+		if claims.Subject != "dashboard" || !slices.Equal(claims.Scopes, []string{ScopeReadTrips, ScopeAdminConfig}) {
+			t.Errorf("Unexpected %s claims %+v", alg, claims)
+		}
+		if expiry := testNow.Add(time.Hour + time.Minute); !claims.ExpiresAt.Equal(expiry) {
+			t.Errorf("Expected the %s token to expire at %v with the clock skew, got %v", alg, expiry, claims.ExpiresAt)
+		}
+		if claims.HasScope(ScopeDeleteData) {
+			t.Errorf("Expected the %s token not to grant %s", alg, ScopeDeleteData)
+		}
+	}
+}
+
+func TestAuthenticate_ReadsScpClaim(t *testing.T) {
+	server, _ := serveJWKS(t)
+	v := newTestVerifier(server.URL)
+
+	claims := validClaims()
+	delete(claims, "scope")
+	claims["scp"] = []string{ScopeReadTrips, ScopeDeleteData}
+	authenticated, err := v.Authenticate(context.Background(), sign(t, "RS256", "rsa-1", claims))
+	if err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+	if !authenticated.HasScope(ScopeDeleteData) {
+		t.Errorf("Expected the scp claim to grant %s, got %v", ScopeDeleteData, authenticated.Scopes)
+	}
+}
+
+func TestAuthenticate_RejectsInvalidTokens(t *testing.T) {
+	server, _ := serveJWKS(t)
+	v := newTestVerifier(server.URL)
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	tampered := sign(t, "RS256", "rsa-1", validClaims())
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-token"},
+		{"unsigned", sign(t, "none", "rsa-1", validClaims())},
+		{"HMAC", sign(t, "HS256", "rsa-1", validClaims())},
+		{"bad signature", tampered},
+		{"unknown key", sign(t, "RS256", "rsa-2", validClaims())},
+		{"encryption key", sign(t, "RS256", "enc-1", validClaims())},
+		{"algorithm of another key", sign(t, "ES256", "rsa-1", validClaims())},
+		{"algorithm of another curve", sign(t, "ES256", "ec-384", validClaims())},
+		{"unsupported curve", sign(t, "ES256", "ec-521", validClaims())},
+		{"expired", sign(t, "RS256", "rsa-1", with("exp", testNow.Add(-2*time.Minute).Unix()))},
+		{"no expiry", sign(t, "RS256", "rsa-1", with("exp", nil))},
+		{"not valid yet", sign(t, "RS256", "rsa-1", with("nbf", testNow.Add(2*time.Minute).Unix()))},
+		{"other issuer", sign(t, "RS256", "rsa-1", with("iss", "https://evil.example.com/"))},
+		{"other audience", sign(t, "RS256", "rsa-1", with("aud", "billing"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Authenticate(context.Background(), tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_ToleratesClockSkew(t *testing.T) {
+	server, _ := serveJWKS(t)
+	v := newTestVerifier(server.URL)
+
+	claims := validClaims()
+	claims["exp"] = testNow.Add(-30 * time.Second).Unix()
+	if _, err := v.Authenticate(context.Background(), sign(t, "RS256", "rsa-1", claims)); err != nil {
+		t.Errorf("Expected a token expired within the clock skew to be valid, got %v", err)
+	}
+}
+
+func TestAuthenticate_RefetchesKeys(t *testing.T) {
+	server, fetches := serveJWKS(t)
+	v := newTestVerifier(server.URL)
+	token := sign(t, "RS256", "rsa-1", validClaims())
+
+	for range 3 {
+		if _, err := v.Authenticate(context.Background(), token); err != nil {
+			t.Fatalf("Expected a valid token, got %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("Expected the keys to be downloaded once, got %d", n)
+	}
+
+	// Unknown key IDs download the keys at most once a minute
+	unknown := sign(t, "RS256", "rsa-2", validClaims())
+	v.now = func() time.Time { return testNow.Add(2 * time.Minute) }
+	v.Authenticate(context.Background(), unknown)
+	v.Authenticate(context.Background(), unknown)
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("Expected one download for unknown keys, got %d", n)
+	}
+
+	// Stale keys are still used while the endpoint is down
+	server.Close()
+	claims := validClaims()
+	claims["exp"] = testNow.Add(24 * time.Hour).Unix()
+	v.now = func() time.Time { return testNow.Add(2 * time.Hour) }
+	if _, err := v.Authenticate(context.Background(), sign(t, "RS256", "rsa-1", claims)); err != nil {
+		t.Errorf("Expected stale keys to be used, got %v", err)
+	}
+}
+
+// roundTripFunc is an HTTP transport implemented by a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestAuthenticate_KnownKeysDuringDownload(t *testing.T) {
+	server, _ := serveJWKS(t)
+	v := newTestVerifier(server.URL)
+	token := sign(t, "RS256", "rsa-1", validClaims())
+	if _, err := v.Authenticate(context.Background(), token); err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+
+	// An unknown key ID starts a download that hangs
+	started, release := make(chan struct{}), make(chan struct{})
+	v.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		close(started)
+		<-release
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	v.now = func() time.Time { return testNow.Add(2 * time.Minute) }
+	unknown := make(chan error)
+	go func() {
+		_, err := v.Authenticate(context.Background(), sign(t, "RS256", "rsa-2", validClaims()))
+		unknown <- err
+	}()
+	<-started
+
+	known := make(chan error)
+	go func() {
+		_, err := v.Authenticate(context.Background(), token)
+		known <- err
+	}()
+	select {
+	case err := <-known:
+		if err != nil {
+			t.Errorf("Expected a valid token, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the known key to be used without waiting for the download")
+	}
+
+	close(release)
+	if err := <-unknown; !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for the unknown key, got %v", err)
+	}
+}
+
+func TestAuthenticate_UnreachableJWKS(t *testing.T) {
+	server, _ := serveJWKS(t)
+	server.Close()
+	v := newTestVerifier(server.URL)
+
+	_, err := v.Authenticate(context.Background(), sign(t, "RS256", "rsa-1", validClaims()))
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an error that is not the token's fault, got %v", err)
+	}
+}
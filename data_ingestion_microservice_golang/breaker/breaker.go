@@ -0,0 +1,166 @@
+// Package breaker stops calling a dependency that is hard down. After a run
+// of consecutive failures the breaker opens and fails calls immediately;
+// once the open timeout passes, a single probe call is let through, and its
+// outcome closes the breaker again or keeps it open for another timeout.
+package breaker
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// ErrOpen is returned for calls rejected by an open breaker
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a breaker, published as its metric value
+type State int
+
+// Breaker states
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker guarding one dependency
+type Breaker struct {
+	name      string
+	config    types.CircuitBreakerConfig
+	isFailure func(err error) bool
+	now       func() time.Time
+	gauge     *expvar.Int
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New creates a closed breaker for the named dependency, counting the
+// errors for which isFailure returns true. A zero failure threshold
+// disables the breaker.
+func New(name string, config types.CircuitBreakerConfig, isFailure func(err error) bool) *Breaker {
+	gauge := new(expvar.Int)
+	metrics.CircuitBreakerState.Set(name, gauge)
+	return &Breaker{
+		name:      name,
+		config:    config,
+		isFailure: isFailure,
+		now:       time.Now,
+		gauge:     gauge,
+	}
+}
+
+// Do calls op unless the breaker is open, and records its outcome
+func (b *Breaker) Do(op func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := op()
+	b.record(err)
+	return err
+}
+
+// Ready reports whether a call would be let through: the breaker is closed,
+// or open long enough to probe the dependency
+func (b *Breaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		return b.now().Sub(b.openedAt) >= b.config.OpenTimeout
+	case HalfOpen:
+		return !b.probing
+	default:
+		return true
+	}
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow admits a call, moving an open breaker whose timeout has passed to
+// half-open for a single probe
+func (b *Breaker) allow() error {
+	if b.config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return fmt.Errorf("%s %w", b.name, ErrOpen)
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+	case HalfOpen:
+		if b.probing {
+			return fmt.Errorf("%s %w", b.name, ErrOpen)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a call
+func (b *Breaker) record(err error) {
+	if b.config.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil && b.isFailure(err) {
+		b.failures++
+		if b.state == HalfOpen || b.failures >= b.config.FailureThreshold {
+			if b.state != Open {
+				metrics.CircuitBreakerOpened.Add(b.name, 1)
+				slog.Warn("Circuit breaker opened", "dependency", b.name, "failures", b.failures, "openTimeout", b.config.OpenTimeout, "error", err)
+			}
+			b.setState(Open)
+			b.openedAt = b.now()
+		}
+	} else {
+		if b.state != Closed {
+			slog.Info("Circuit breaker closed", "dependency", b.name)
+		}
+		b.setState(Closed)
+		b.failures = 0
+	}
+	b.probing = false
+}
+
+// setState changes the state and its metric
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.gauge.Set(int64(state))
+}
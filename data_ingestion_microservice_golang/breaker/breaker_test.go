@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+var (
+	errDown     = errors.New("connection refused")
+	errRejected = errors.New("WRONGTYPE")
+)
+
+// newTestBreaker returns a breaker with a clock advanced by the returned
+// function
+func newTestBreaker(threshold int) (*Breaker, func(time.Duration)) {
+	b := New("test", types.CircuitBreakerConfig{FailureThreshold: threshold, OpenTimeout: 10 * time.Second}, func(err error) bool {
+		return errors.Is(err, errDown)
+	})
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func fail() error {
+	return errDown
+}
+
+func succeed() error {
+	return nil
+}
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	b, advance := newTestBreaker(2)
+
+	b.Do(fail)
+	if b.State() != Closed {
+		t.Fatalf("Expected the breaker to stay closed after 1 failure, got %v", b.State())
+	}
+	b.Do(fail)
+	if b.State() != Open || b.Ready() {
+		t.Fatalf("Expected the breaker to open after 2 failures, got %v", b.State())
+	}
+	if got := metrics.CircuitBreakerState.Get("test").String(); got != "2" {
+		t.Errorf("Expected the state metric to be 2, got %s", got)
+	}
+
+	calls := 0
+	err := b.Do(func() error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, ErrOpen) || calls != 0 {
+		t.Errorf("Expected an open breaker to fail fast, got %v after %d calls", err, calls)
+	}
+
+	// A failed probe keeps the breaker open for another timeout
+	advance(10 * time.Second)
+	if !b.Ready() {
+		t.Fatalf("Expected the breaker to be ready to probe")
+	}
+	b.Do(fail)
+	if b.State() != Open || b.Ready() {
+		t.Fatalf("Expected a failed probe to reopen the breaker, got %v", b.State())
+	}
+
+	advance(10 * time.Second)
+	if err := b.Do(succeed); err != nil || b.State() != Closed {
+		t.Errorf("Expected a successful probe to close the breaker, got %v, %v", err, b.State())
+	}
+}
+
+func TestBreaker_SingleProbe(t *testing.T) {
+	b, advance := newTestBreaker(1)
+	b.Do(fail)
+	advance(10 * time.Second)
+
+	err := b.Do(func() error {
+		// Calls made while the probe is in flight are rejected
+		if err := b.Do(succeed); !errors.Is(err, ErrOpen) {
+			t.Errorf("Expected a concurrent call to be rejected, got %v", err)
+		}
+		return nil
+	})
+	if err != nil || b.State() != Closed {
+		t.Errorf("Expected the probe to close the breaker, got %v, %v", err, b.State())
+	}
+}
+
+func TestBreaker_IgnoresOtherErrors(t *testing.T) {
+	b, _ := newTestBreaker(2)
+	b.Do(fail)
+	b.Do(func() error { return errRejected })
+	b.Do(fail)
+	if b.State() != Closed {
+		t.Errorf("Expected errors that are not failures to reset the count, got %v", b.State())
+	}
+
+	disabled, _ := newTestBreaker(0)
+	for i := 0; i < 10; i++ {
+		disabled.Do(fail)
+	}
+	if disabled.State() != Closed || !disabled.Ready() {
+		t.Errorf("Expected a disabled breaker to stay closed, got %v", disabled.State())
+	}
+}
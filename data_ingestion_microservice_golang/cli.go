@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"data-ingestion-microservice/config"
+	"data-ingestion-microservice/types"
+)
+
+// version is the release version, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+const usageText = `Usage: data-ingestion-service [command] [flags]
+
+Commands:
+  serve             run the service (the default)
+  version           print the version and exit
+  validate-config   check the configuration and exit
+
+Run "data-ingestion-service <command> -h" for the flags of a command. Every
+setting has a flag named after its environment variable, so --mqtt-broker
+overrides MQTT_BROKER.
+`
+
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "version":
+		fmt.Println(versionString())
+	case "validate-config":
+		os.Exit(validateConfig(args))
+	case "help":
+		fmt.Print(usageText)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usageText)
+		os.Exit(2)
+	}
+}
+
+// loadConfig parses the flags of a command, loads and validates the
+// configuration, and reads the secrets. Flags take precedence over
+// environment variables, which take precedence over the config file.
+func loadConfig(command string, args []string) (types.Config, error) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file")
+	flags := config.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: data-ingestion-service %s [flags]\n\nFlags:\n", command)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return types.Config{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg, err := config.Load(config.WithFile(*configFile), config.WithFlags(flags))
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	// Report every problem at once rather than failing on the first one
+	if err := cfg.Validate(); err != nil {
+		return types.Config{}, err
+	}
+
+	// Read the credentials from the secrets provider
+	if err := config.LoadSecrets(context.Background(), &cfg); err != nil {
+		return types.Config{}, err
+	}
+	return cfg, nil
+}
+
+// reportConfigError logs why the configuration could not be loaded, one
+// line per problem
+func reportConfigError(err error) {
+	var problems types.ConfigErrors
+	if !errors.As(err, &problems) {
+		slog.Error("Failed to load configuration", "error", err)
+		return
+	}
+	for _, problem := range problems {
+		slog.Error("Invalid configuration", "problem", problem)
+	}
+}
+
+// validateConfig loads the configuration and reports whether it is valid,
+// returning the exit code
+func validateConfig(args []string) int {
+	if _, err := loadConfig("validate-config", args); err != nil {
+		var problems types.ConfigErrors
+		if !errors.As(err, &problems) {
+			fmt.Fprintf(os.Stderr, "❌ Failed to load configuration: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "❌ Invalid configuration, %d problem(s):\n", len(problems))
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		return 1
+	}
+	fmt.Println("✅ Configuration is valid")
+	return 0
+}
+
+// versionString describes the build: its version, commit, and Go version
+func versionString() string {
+	commit := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				commit = setting.Value
+			}
+		}
+	}
+	return fmt.Sprintf("data-ingestion-service %s (commit %s, %s)", version, commit, runtime.Version())
+}
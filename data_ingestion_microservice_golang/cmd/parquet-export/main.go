@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"data-ingestion-microservice/config"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/export"
+)
+
+func main() {
+	from := flag.String("from", "", "first trip date to export (YYYY-MM-DD, UTC)")
+	to := flag.String("to", "", "last trip date to export, inclusive (YYYY-MM-DD, UTC)")
+	out := flag.String("out", "parquet", "output directory for the date-partitioned Parquet files")
+	flag.Parse()
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if *from == "" || *to == "" {
+		log.Fatal("Both -from and -to are required")
+	}
+
+	start, err := time.Parse(export.PartitionDateLayout, *from)
+	if err != nil {
+		log.Fatalf("Invalid -from date: %v", err)
+	}
+	end, err := time.Parse(export.PartitionDateLayout, *to)
+	if err != nil {
+		log.Fatalf("Invalid -to date: %v", err)
+	}
+
+	ctx := context.Background()
+	cfg := config.LoadConfig()
+
+	client, err := database.ConnectMongo(ctx, cfg.MongoDB)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer client.Disconnect(ctx)
+
+	db := client.Database(cfg.MongoDB.Database)
+	reader := database.NewTripReader(db.Collection(cfg.MongoDB.Collection), db.Collection(cfg.RawRoutes.Collection))
+
+	summary, err := export.ExportTrips(ctx, reader, start, end.AddDate(0, 0, 1), *out)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	log.Printf("Exported %d trips (%d points) into %d files under %s", summary.Trips, summary.Points, len(summary.Files), *out)
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads the settings of a YAML or TOML file, keyed by
+// environment variable name. Every key is the name of an environment
+// variable, lowercase or not, and nested sections are joined with
+// underscores, so
+//
+//	redis:
+//	  address: redis:6379
+//
+// sets REDIS_ADDRESS.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, expected .yaml, .yml, .json, or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// settingKey turns a nested file key into the environment variable name
+func settingKey(path []string) string {
+	key := strings.Join(path, "_")
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// parseYAML flattens a YAML (or JSON) document into settings. Lists become
+// comma-separated values.
+func parseYAML(data []byte) (map[string]string, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err := flatten(values, nil, document); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flatten adds the scalar values of a nested document to values
+func flatten(values map[string]string, path []string, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for key, child := range v {
+			if err := flatten(values, append(slices.Clone(path), key), child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Errorf("%s: lists may only hold plain values", settingKey(path))
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		values[settingKey(path)] = strings.Join(items, ",")
+	case []map[string]interface{}:
+		return fmt.Errorf("%s: lists may only hold plain values", settingKey(path))
+	default:
+		values[settingKey(path)] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// parseTOML flattens a TOML document into settings like parseYAML
+func parseTOML(data []byte) (map[string]string, error) {
+	var document map[string]interface{}
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err := flatten(values, nil, document); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
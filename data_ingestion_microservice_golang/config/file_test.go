@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file for a test
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_YAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+mqtt:
+  broker: broker.internal
+  port: 8883
+redis:
+  address: redis:6379
+  route_ttl: 2h
+route_janitor:
+  enabled: true
+WEBHOOK_URLS:
+  - https://a.example.com/hook
+  - https://b.example.com/hook
+`)
+	t.Setenv("MQTT_PORT", "1884")
+
+	cfg, err := Load(WithFile(path))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.MQTT.Broker != "broker.internal" || cfg.Redis.Address != "redis:6379" || !cfg.Janitor.Enabled {
+		t.Errorf("Expected the file values, got broker %q, redis %q, janitor %v", cfg.MQTT.Broker, cfg.Redis.Address, cfg.Janitor.Enabled)
+	}
+	if cfg.Redis.RouteTTL != 2*time.Hour {
+		t.Errorf("Expected a route TTL of 2h, got %v", cfg.Redis.RouteTTL)
+	}
+	if cfg.MQTT.Port != 1884 {
+		t.Errorf("Expected the environment to override the file, got port %d", cfg.MQTT.Port)
+	}
+	if !slices.Equal(cfg.Webhooks.URLs, []string{"https://a.example.com/hook", "https://b.example.com/hook"}) {
+		t.Errorf("Expected the webhook list, got %v", cfg.Webhooks.URLs)
+	}
+	if cfg.MongoDB.Database != LoadConfig().MongoDB.Database {
+		t.Errorf("Expected defaults for settings missing from the file, got %q", cfg.MongoDB.Database)
+	}
+}
+
+func TestLoadConfigFile_TOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+redis.address = "redis.internal:6379"
+
+# Broker settings
+[mqtt]
+broker = "broker.internal" # inline comment
+topic = 'drivers_location/#'
+
+[route]
+tolerance = 0.0005
+
+[webhook]
+urls = [
+  "https://a.example.com/hook", # primary
+  "https://b.example.com/hook",
+]
+`)
+
+	cfg, err := Load(WithFile(path))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.MQTT.Broker != "broker.internal" || cfg.MQTT.Topic != "drivers_location/#" {
+		t.Errorf("Expected the file values, got broker %q and topic %q", cfg.MQTT.Broker, cfg.MQTT.Topic)
+	}
+	if cfg.RouteSimplification.Tolerance != 0.0005 {
+		t.Errorf("Expected tolerance 0.0005, got %v", cfg.RouteSimplification.Tolerance)
+	}
+	if len(cfg.Webhooks.URLs) != 2 {
+		t.Errorf("Expected 2 webhook URLs, got %v", cfg.Webhooks.URLs)
+	}
+	if cfg.Redis.Address != "redis.internal:6379" {
+		t.Errorf("Expected the dotted key to set the Redis address, got %q", cfg.Redis.Address)
+	}
+}
+
+func TestLoadConfigFile_RejectsInvalidTOML(t *testing.T) {
+	for _, content := range []string{
+		"[mqtt]\nbroker = \"broker.internal\"\nbroker = \"other\"\n",
+		"[[webhook.urls]]\nurl = \"https://a.example.com/hook\"\n",
+	} {
+		path := writeConfigFile(t, "config.toml", content)
+		if _, err := Load(WithFile(path)); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+func TestLoadConfigFile_RejectsUnknownSettings(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "mqtt:\n  brokr: broker.internal\nredis:\n  adress: redis:6379\n")
+
+	_, err := Load(WithFile(path))
+	if err == nil || !strings.Contains(err.Error(), "MQTT_BROKR, REDIS_ADRESS") {
+		t.Errorf("Expected both unknown settings to be reported, got %v", err)
+	}
+}
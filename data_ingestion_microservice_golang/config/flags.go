@@ -0,0 +1,46 @@
+package config
+
+import (
+	"flag"
+	"strings"
+)
+
+// Flags holds the settings given as command-line flags, keyed by
+// environment variable name. They take precedence over the environment.
+type Flags struct {
+	values map[string]string
+}
+
+// RegisterFlags adds a flag for every setting to fs, named after its
+// environment variable in lowercase with dashes, so --mqtt-broker overrides
+// MQTT_BROKER. Pass the result to Load with WithFlags.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{values: make(map[string]string)}
+	for _, key := range Settings() {
+		fs.Var(settingFlag{flags, key}, FlagName(key), "overrides "+key)
+	}
+	return flags
+}
+
+// FlagName returns the command-line flag for a setting
+func FlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// settingFlag is a flag.Value storing a setting in Flags
+type settingFlag struct {
+	flags *Flags
+	key   string
+}
+
+func (f settingFlag) String() string {
+	if f.flags == nil {
+		return ""
+	}
+	return f.flags.values[f.key]
+}
+
+func (f settingFlag) Set(value string) error {
+	f.flags.values[f.key] = value
+	return nil
+}
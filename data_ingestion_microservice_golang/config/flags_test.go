@@ -0,0 +1,42 @@
+package config
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestRegisterFlags_OverrideEnvironmentAndFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "mqtt:\n  broker: file.internal\n  topic: file/#\n")
+	t.Setenv("MQTT_BROKER", "env.internal")
+	t.Setenv("MQTT_PORT", "1884")
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse([]string{"--mqtt-broker", "flag.internal", "--route-tolerance=0.0005"}); err != nil {
+		t.Fatalf("Expected the setting flags to parse, got %v", err)
+	}
+
+	cfg, err := Load(WithFile(path), WithFlags(flags))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.MQTT.Broker != "flag.internal" || cfg.RouteSimplification.Tolerance != 0.0005 {
+		t.Errorf("Expected the flags to win, got broker %q and tolerance %v", cfg.MQTT.Broker, cfg.RouteSimplification.Tolerance)
+	}
+	if cfg.MQTT.Port != 1884 || cfg.MQTT.Topic != "file/#" {
+		t.Errorf("Expected the environment and file for settings without flags, got port %d and topic %q", cfg.MQTT.Port, cfg.MQTT.Topic)
+	}
+}
+
+func TestSettings_ListsEveryEnvironmentVariable(t *testing.T) {
+	settings := Settings()
+	for _, key := range []string{"MQTT_BROKER", "REDIS_ADDRESS", "STARTUP_MQTT_ATTEMPTS", "STANDBY_ENABLED"} {
+		if !slices.Contains(settings, key) {
+			t.Errorf("Expected %s among the settings", key)
+		}
+	}
+	if FlagName("REDIS_ROUTE_TTL") != "redis-route-ttl" {
+		t.Errorf("Expected flag redis-route-ttl, got %s", FlagName("REDIS_ROUTE_TTL"))
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// Defaults holds default settings keyed by environment variable name
+type Defaults map[string]string
+
+// Option configures where Load reads settings from
+type Option func(*Loader)
+
+// WithEnvPrefix reads every setting from the environment variable named
+// after it with the prefix, e.g. INGEST_MQTT_BROKER for MQTT_BROKER with
+// the prefix INGEST_. Flags and config file keys keep the plain names.
+func WithEnvPrefix(prefix string) Option {
+	return func(l *Loader) {
+		l.prefix = prefix
+	}
+}
+
+// WithFile reads settings from a YAML or TOML configuration file. An empty
+// path reads no file.
+func WithFile(path string) Option {
+	return func(l *Loader) {
+		l.file = path
+	}
+}
+
+// WithDefaults replaces the built-in defaults of some settings
+func WithDefaults(defaults Defaults) Option {
+	return func(l *Loader) {
+		l.defaults = defaults
+	}
+}
+
+// WithFlags reads settings from the command-line flags registered with
+// RegisterFlags
+func WithFlags(flags *Flags) Option {
+	return func(l *Loader) {
+		l.flags = flags
+	}
+}
+
+// Loader reads settings, the first one set winning, from the command-line
+// flags, the environment, the config file, the defaults of the selected
+// profile, and the defaults given with WithDefaults
+type Loader struct {
+	prefix   string
+	file     string
+	defaults Defaults
+	flags    *Flags
+
+	fileValues map[string]string
+	// read records the settings looked up, to find unknown keys in the
+	// config file
+	read map[string]bool
+	// malformed records the settings whose values could not be parsed
+	malformed []string
+}
+
+// NewLoader creates a loader with the given options. The config file is
+// only read by Load.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{read: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load loads the configuration with the given options. Keys of the config
+// file that match no setting are rejected, so typos do not go unnoticed.
+func Load(opts ...Option) (types.Config, error) {
+	l := NewLoader(opts...)
+	if l.file != "" {
+		values, err := readConfigFile(l.file)
+		if err != nil {
+			return types.Config{}, err
+		}
+		l.fileValues = values
+	}
+
+	cfg := l.Config()
+
+	var unknown []string
+	for key := range l.fileValues {
+		if !l.read[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return types.Config{}, fmt.Errorf("unknown settings in config file %s: %s", l.file, strings.Join(unknown, ", "))
+	}
+	return cfg, nil
+}
+
+// LoadConfig loads the configuration from the environment, with defaults
+func LoadConfig() types.Config {
+	return NewLoader().Config()
+}
+
+// Settings lists the names of the environment variables read by Load
+func Settings() []string {
+	l := NewLoader()
+	l.Config()
+	return slices.Sorted(maps.Keys(l.read))
+}
+
+// Lookup returns a setting, or "" if it is set nowhere
+func (l *Loader) Lookup(key string) string {
+	l.read[key] = true
+	if l.flags != nil {
+		if value := l.flags.values[key]; value != "" {
+			return value
+		}
+	}
+	if value := os.Getenv(l.prefix + key); value != "" {
+		return value
+	}
+	if value := l.fileValues[key]; value != "" {
+		return value
+	}
+	if key != "PROFILE" {
+		if value := profileDefaults[l.Lookup("PROFILE")][key]; value != "" {
+			return value
+		}
+	}
+	return l.defaults[key]
+}
+
+// String returns a setting, or defaultValue if it is unset
+func (l *Loader) String(key, defaultValue string) string {
+	if value := l.Lookup(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Malformed returns a problem for every setting read so far whose value
+// could not be parsed
+func (l *Loader) Malformed() []string {
+	return l.malformed
+}
+
+// parse converts a setting with convert, or returns defaultValue if it is
+// unset. A value that does not parse is recorded as malformed, with kind
+// describing the expected value, and also yields defaultValue.
+func parse[T any](l *Loader, key, kind string, defaultValue T, convert func(string) (T, error)) T {
+	value := l.Lookup(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := convert(value)
+	if err != nil {
+		l.malformed = append(l.malformed, fmt.Sprintf("%s must be %s, got %q", key, kind, value))
+		return defaultValue
+	}
+	return parsed
+}
+
+// Int returns a setting as an integer, or defaultValue if it is unset or
+// not an integer
+func (l *Loader) Int(key string, defaultValue int) int {
+	return parse(l, key, "an integer", defaultValue, strconv.Atoi)
+}
+
+// Float returns a setting as a float64, or defaultValue if it is unset or
+// not a number
+func (l *Loader) Float(key string, defaultValue float64) float64 {
+	return parse(l, key, "a number", defaultValue, func(value string) (float64, error) {
+		return strconv.ParseFloat(value, 64)
+	})
+}
+
+// Bool returns a setting as a bool, or defaultValue if it is unset or not
+// a bool
+func (l *Loader) Bool(key string, defaultValue bool) bool {
+	return parse(l, key, "true or false", defaultValue, strconv.ParseBool)
+}
+
+// Duration returns a setting as a time.Duration, or defaultValue if it is
+// unset or not a duration
+func (l *Loader) Duration(key string, defaultValue time.Duration) time.Duration {
+	return parse(l, key, "a duration such as 30s", defaultValue, time.ParseDuration)
+}
+
+// Strings returns a comma-separated setting as a string slice, or
+// defaultValue if it is unset
+func (l *Loader) Strings(key string, defaultValue []string) []string {
+	if value := l.Lookup(key); value != "" {
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return defaultValue
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestLoad_EnvPrefixAndDefaults(t *testing.T) {
+	t.Setenv("MQTT_BROKER", "unprefixed.internal")
+	t.Setenv("INGEST_MQTT_BROKER", "prefixed.internal")
+	t.Setenv("INGEST_PROFILE", "dev")
+
+	cfg, err := Load(WithEnvPrefix("INGEST_"), WithDefaults(Defaults{"MQTT_TOPIC": "fleet/#", "LOG_LEVEL": "warn"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.MQTT.Broker != "prefixed.internal" {
+		t.Errorf("Expected the prefixed variable, got broker %q", cfg.MQTT.Broker)
+	}
+	if cfg.MQTT.Topic != "fleet/#" {
+		t.Errorf("Expected the given default, got topic %q", cfg.MQTT.Topic)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("Expected the profile to override the given defaults, got log level %q", cfg.Log.Level)
+	}
+}
+
+func TestLoader_TypedGetters(t *testing.T) {
+	t.Setenv("APP_RETRIES", "3")
+	t.Setenv("APP_TIMEOUT", "soon")
+	t.Setenv("APP_HOSTS", "a, b,,c")
+
+	l := NewLoader(WithEnvPrefix("APP_"), WithDefaults(Defaults{"RATIO": "0.5"}))
+	if got := l.Int("RETRIES", 1); got != 3 {
+		t.Errorf("Expected 3 retries, got %d", got)
+	}
+	if got := l.Duration("TIMEOUT", 5); got != 5 {
+		t.Errorf("Expected the fallback for an invalid duration, got %v", got)
+	}
+	if got := l.Malformed(); len(got) != 1 || got[0] != `TIMEOUT must be a duration such as 30s, got "soon"` {
+		t.Errorf("Expected the invalid duration to be reported, got %q", got)
+	}
+	if got := l.Float("RATIO", 1); got != 0.5 {
+		t.Errorf("Expected the default ratio, got %v", got)
+	}
+	if got := l.Strings("HOSTS", nil); len(got) != 3 || got[2] != "c" {
+		t.Errorf("Expected three hosts, got %v", got)
+	}
+}
+
+func TestLoad_ReportsMalformedSettings(t *testing.T) {
+	t.Setenv("MQTT_PORT", "88x3")
+	t.Setenv("AUTH_ENABLED", "yes")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var problems types.ConfigErrors
+	if err := cfg.Validate(); !errors.As(err, &problems) {
+		t.Fatalf("Expected ConfigErrors, got %v", err)
+	}
+	for _, expected := range []string{
+		`MQTT_PORT must be an integer, got "88x3"`,
+		`AUTH_ENABLED must be true or false, got "yes"`,
+	} {
+		if !slices.Contains(problems, expected) {
+			t.Errorf("Expected %q among %q", expected, problems)
+		}
+	}
+}
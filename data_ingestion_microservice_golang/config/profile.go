@@ -0,0 +1,37 @@
+package config
+
+// Profiles lists the environment profiles selected with PROFILE
+var Profiles = []string{"dev", "staging", "prod"}
+
+// profileDefaults holds the default settings of every profile, keyed by
+// environment variable name. They apply to settings left unset by the
+// flags, the environment, and the config file, and take precedence over
+// the defaults given with WithDefaults.
+var profileDefaults = map[string]Defaults{
+	// Run everything locally with verbose, readable logs
+	"dev": {
+		"STORAGE_MODE":       "embedded",
+		"LOG_LEVEL":          "debug",
+		"LOG_FORMAT":         "text",
+		"LOG_SOURCE":         "true",
+		"HTTP_DEBUG_ENABLED": "true",
+		"SENTRY_ENVIRONMENT": "development",
+	},
+	"staging": {
+		"LOG_LEVEL":          "info",
+		"LOG_FORMAT":         "json",
+		"SENTRY_ENVIRONMENT": "staging",
+	},
+	// Encrypt every connection and expose no internals
+	"prod": {
+		"MQTT_PORT":          "8883",
+		"MQTT_TLS":           "true",
+		"REDIS_TLS":          "true",
+		"ARCHIVE_S3_USE_SSL": "true",
+		"LOG_LEVEL":          "info",
+		"LOG_FORMAT":         "json",
+		"HTTP_DEBUG_ENABLED": "false",
+		"GRPC_REFLECTION":    "false",
+		"SENTRY_ENVIRONMENT": "production",
+	},
+}
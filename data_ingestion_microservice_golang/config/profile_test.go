@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestLoadConfig_AppliesProfileDefaults(t *testing.T) {
+	t.Setenv("PROFILE", "dev")
+	t.Setenv("LOG_FORMAT", "json")
+
+	cfg := LoadConfig()
+	if cfg.Storage.Mode != "embedded" || cfg.Log.Level != "debug" || !cfg.HTTP.DebugEnabled {
+		t.Errorf("Expected the dev defaults, got storage %q, log level %q, and debug %v", cfg.Storage.Mode, cfg.Log.Level, cfg.HTTP.DebugEnabled)
+	}
+	if cfg.Log.Format != "json" {
+		t.Errorf("Expected the environment to override the profile, got log format %q", cfg.Log.Format)
+	}
+
+	t.Setenv("PROFILE", "prod")
+	cfg = LoadConfig()
+	if cfg.Storage.Mode != "external" || !cfg.MQTT.TLS || !cfg.Redis.TLS || cfg.MQTT.Port != 8883 {
+		t.Errorf("Expected the prod defaults, got storage %q, MQTT TLS %v on port %d, and Redis TLS %v", cfg.Storage.Mode, cfg.MQTT.TLS, cfg.MQTT.Port, cfg.Redis.TLS)
+	}
+}
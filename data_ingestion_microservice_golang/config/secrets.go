@@ -0,0 +1,28 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"data-ingestion-microservice/secrets"
+	"data-ingestion-microservice/types"
+)
+
+// LoadSecrets replaces the Redis, MongoDB, and MQTT credentials in cfg with
+// those held by the configured secrets provider. With the env provider the
+// credentials were already read along with the other settings.
+func LoadSecrets(ctx context.Context, cfg *types.Config) error {
+	if cfg.Secrets.Provider == "" || cfg.Secrets.Provider == "env" {
+		return nil
+	}
+	provider, err := secrets.NewProvider(cfg.Secrets)
+	if err != nil {
+		return err
+	}
+	values, err := provider.Fetch(ctx, secrets.Names)
+	if err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	secrets.Apply(cfg, values)
+	return nil
+}
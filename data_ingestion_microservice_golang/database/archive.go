@@ -0,0 +1,135 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ArchiveContentType is the content type of archived raw traces
+const ArchiveContentType = "application/x-ndjson"
+
+// ArchiveContentEncoding is the content encoding of archived raw traces
+const ArchiveContentEncoding = "zstd"
+
+// S3Archiver uploads the raw points of finalized trips to S3-compatible
+// object storage such as AWS S3 or MinIO
+type S3Archiver struct {
+	client *minio.Client
+	bucket string
+	tenant string
+}
+
+// NewS3Archiver connects to S3-compatible storage and verifies that the
+// archive bucket exists
+func NewS3Archiver(ctx context.Context, config types.ArchiveConfig) (*S3Archiver, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check S3 bucket %s: %w", config.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("S3 bucket %s does not exist", config.Bucket)
+	}
+
+	return &S3Archiver{
+		client: client,
+		bucket: config.Bucket,
+		tenant: config.Tenant,
+	}, nil
+}
+
+// ArchiveRoute uploads the trip's raw points as zstd compressed NDJSON and
+// returns the object URL. The object key is derived from the trip start, so
+// retried finalizations overwrite the same object.
+func (a *S3Archiver) ArchiveRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) (string, error) {
+	body, err := EncodeArchive(points)
+	if err != nil {
+		return "", err
+	}
+
+	key := ArchiveKey(a.tenant, trip, points)
+	_, err = a.client.PutObject(ctx, a.bucket, key, bytes.NewReader(body), int64(len(body)), minio.PutObjectOptions{
+		ContentType:     ArchiveContentType,
+		ContentEncoding: ArchiveContentEncoding,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload raw trace %s: %w", key, err)
+	}
+
+	return fmt.Sprintf("s3://%s/%s", a.bucket, key), nil
+}
+
+// ArchiveKey returns the object key of a trip's raw trace:
+// tenant/driver/route/date.json.zst, where date is the UTC start of the trip
+func ArchiveKey(tenant string, trip types.Trip, points []types.TrackPoint) string {
+	start := time.UnixMilli(trip.Timestamp)
+	if len(points) > 0 && points[0].Timestamp > 0 {
+		start = time.UnixMilli(int64(points[0].Timestamp))
+	}
+	date := start.UTC().Format("2006-01-02T150405.000Z")
+	return fmt.Sprintf("%s/%s/%s/%s.json.zst", tenant, trip.DriverID, trip.CurrentRouteID, date)
+}
+
+// EncodeArchive serializes points as newline-delimited JSON and compresses
+// them with zstd
+func EncodeArchive(points []types.TrackPoint) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := zstd.NewWriter(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+	}
+
+	encoder := json.NewEncoder(writer)
+	for _, point := range points {
+		if err := encoder.Encode(point); err != nil {
+			writer.Close()
+			return nil, fmt.Errorf("failed to encode archived point: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archived points: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeArchive restores points encoded by EncodeArchive
+func DecodeArchive(data []byte) ([]types.TrackPoint, error) {
+	reader, err := zstd.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+	}
+	defer reader.Close()
+
+	var points []types.TrackPoint
+	decoder := json.NewDecoder(reader)
+	for {
+		var point types.TrackPoint
+		err := decoder.Decode(&point)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode archived point: %w", err)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestEncodeArchive_RoundTrip(t *testing.T) {
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995201000},
+	}
+
+	encoded, err := EncodeArchive(points)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	decoded, err := DecodeArchive(encoded)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(decoded) != len(points) || decoded[1] != points[1] {
+		t.Errorf("Expected %v, got %v", points, decoded)
+	}
+}
+
+func TestArchiveKey(t *testing.T) {
+	trip := types.Trip{DriverID: "driver_001", CurrentRouteID: "route_123", Timestamp: 1640997000000}
+	points := []types.TrackPoint{{Timestamp: 1640995200000}}
+
+	expected := "default/driver_001/route_123/2022-01-01T000000.000Z.json.zst"
+	if key := ArchiveKey("default", trip, points); key != expected {
+		t.Errorf("Expected key %s, got %s", expected, key)
+	}
+}
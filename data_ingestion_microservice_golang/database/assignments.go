@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultAssignmentPageSize is the number of assignments returned when a
+// query has no limit
+const defaultAssignmentPageSize = 100
+
+// AssignmentID identifies the assignment of a driver to a vehicle starting
+// at the given time, so a retried message records the same assignment
+func AssignmentID(driverID, vehicleID string, startedAt time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", driverID, vehicleID, startedAt.UnixMilli())
+}
+
+// assignmentLimit returns the page size of an assignment query
+func assignmentLimit(query types.AssignmentQuery) int {
+	if query.Limit <= 0 {
+		return defaultAssignmentPageSize
+	}
+	return query.Limit
+}
+
+// assignmentMatches reports whether an assignment is selected by a query
+func assignmentMatches(assignment types.VehicleAssignment, query types.AssignmentQuery) bool {
+	started := assignment.StartedAt.UnixMilli()
+	return (query.DriverID == "" || assignment.DriverID == query.DriverID) &&
+		(query.VehicleID == "" || assignment.VehicleID == query.VehicleID) &&
+		(query.From <= 0 || started >= query.From) &&
+		(query.To <= 0 || started < query.To)
+}
+
+// StartAssignment records that a driver drives a vehicle from startedAt on.
+// The driver's open assignments to other vehicles and the vehicle's open
+// assignments to other drivers end then, and an assignment already open
+// between the two is kept.
+func (dm *DatabaseManager) StartAssignment(ctx context.Context, driverID, vehicleID string, startedAt time.Time) error {
+	_, err := dm.collections().Assignments.UpdateMany(ctx,
+		bson.M{
+			"endedAt":   nil,
+			"startedAt": bson.M{"$lte": startedAt},
+			"$or": bson.A{
+				bson.M{"driverId": driverID, "vehicleId": bson.M{"$ne": vehicleID}},
+				bson.M{"vehicleId": vehicleID, "driverId": bson.M{"$ne": driverID}},
+			},
+		},
+		bson.M{"$set": bson.M{"endedAt": startedAt}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to end assignments of driver %s and vehicle %s: %w", driverID, vehicleID, err)
+	}
+
+	_, err = dm.collections().Assignments.UpdateOne(ctx,
+		bson.M{"driverId": driverID, "vehicleId": vehicleID, "endedAt": nil},
+		bson.M{"$setOnInsert": bson.M{
+			"_id":       AssignmentID(driverID, vehicleID, startedAt),
+			"startedAt": startedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to assign vehicle %s to driver %s: %w", vehicleID, driverID, err)
+	}
+	return nil
+}
+
+// FindAssignments returns the assignments of a driver or vehicle, newest
+// first
+func (dm *DatabaseManager) FindAssignments(ctx context.Context, query types.AssignmentQuery) ([]types.VehicleAssignment, error) {
+	filter := bson.M{}
+	if query.DriverID != "" {
+		filter["driverId"] = query.DriverID
+	}
+	if query.VehicleID != "" {
+		filter["vehicleId"] = query.VehicleID
+	}
+	startedAt := bson.M{}
+	if query.From > 0 {
+		startedAt["$gte"] = time.UnixMilli(query.From)
+	}
+	if query.To > 0 {
+		startedAt["$lt"] = time.UnixMilli(query.To)
+	}
+	if len(startedAt) > 0 {
+		filter["startedAt"] = startedAt
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: -1}}).
+		SetLimit(int64(assignmentLimit(query)))
+	cursor, err := dm.collections().Assignments.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vehicle assignments: %w", err)
+	}
+	assignments := []types.VehicleAssignment{}
+	if err := cursor.All(ctx, &assignments); err != nil {
+		return nil, fmt.Errorf("failed to decode vehicle assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// StartAssignment records that a driver drives a vehicle from startedAt on,
+// ending the assignments it replaces
+func (s *BoltTripStore) StartAssignment(ctx context.Context, driverID, vehicleID string, startedAt time.Time) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltAssignmentsBucket)
+
+		open := false
+		var ended []types.VehicleAssignment
+		err := forEachAssignment(bucket, func(assignment types.VehicleAssignment) {
+			if assignment.EndedAt != nil || assignment.StartedAt.After(startedAt) {
+				return
+			}
+			switch {
+			case assignment.DriverID == driverID && assignment.VehicleID == vehicleID:
+				open = true
+			case assignment.DriverID == driverID, assignment.VehicleID == vehicleID:
+				assignment.EndedAt = &startedAt
+				ended = append(ended, assignment)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if !open {
+			ended = append(ended, types.VehicleAssignment{
+				ID:        AssignmentID(driverID, vehicleID, startedAt),
+				DriverID:  driverID,
+				VehicleID: vehicleID,
+				StartedAt: startedAt,
+			})
+		}
+		for _, assignment := range ended {
+			data, err := json.Marshal(assignment)
+			if err != nil {
+				return fmt.Errorf("failed to marshal assignment %s: %w", assignment.ID, err)
+			}
+			if err := bucket.Put([]byte(assignment.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to assign vehicle %s to driver %s: %w", vehicleID, driverID, err)
+	}
+	return nil
+}
+
+// FindAssignments returns the assignments of a driver or vehicle, newest
+// first
+func (s *BoltTripStore) FindAssignments(ctx context.Context, query types.AssignmentQuery) ([]types.VehicleAssignment, error) {
+	assignments := []types.VehicleAssignment{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return forEachAssignment(tx.Bucket(boltAssignmentsBucket), func(assignment types.VehicleAssignment) {
+			if assignmentMatches(assignment, query) {
+				assignments = append(assignments, assignment)
+			}
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vehicle assignments: %w", err)
+	}
+
+	slices.SortFunc(assignments, func(a, b types.VehicleAssignment) int { return b.StartedAt.Compare(a.StartedAt) })
+	if limit := assignmentLimit(query); len(assignments) > limit {
+		assignments = assignments[:limit]
+	}
+	return assignments, nil
+}
+
+// forEachAssignment calls fn with every stored assignment
+func forEachAssignment(bucket *bbolt.Bucket, fn func(assignment types.VehicleAssignment)) error {
+	return bucket.ForEach(func(id, data []byte) error {
+		var assignment types.VehicleAssignment
+		if err := json.Unmarshal(data, &assignment); err != nil {
+			return fmt.Errorf("assignment %s: %w", id, err)
+		}
+		fn(assignment)
+		return nil
+	})
+}
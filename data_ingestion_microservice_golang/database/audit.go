@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultAuditPageSize is the number of audit entries returned when a query
+// has no limit
+const defaultAuditPageSize = 100
+
+// RecordAudit appends an entry to the audit log collection
+func (dm *DatabaseManager) RecordAudit(ctx context.Context, entry types.AuditEntry) error {
+	if _, err := dm.collections().AuditLog.InsertOne(ctx, entry); err != nil {
+		return fmt.Errorf("failed to record audit entry %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// AuditEntries returns the audit entries matching the query, newest first
+func (dm *DatabaseManager) AuditEntries(ctx context.Context, query types.AuditQuery) ([]types.AuditEntry, error) {
+	filter := bson.M{}
+	if query.Action != "" {
+		filter["action"] = query.Action
+	}
+	if query.Actor != "" {
+		filter["actor"] = query.Actor
+	}
+	if query.Target != "" {
+		filter["target"] = query.Target
+	}
+	timestamp := bson.M{}
+	if query.From > 0 {
+		timestamp["$gte"] = time.UnixMilli(query.From)
+	}
+	if query.To > 0 {
+		timestamp["$lt"] = time.UnixMilli(query.To)
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(auditLimit(query)))
+
+	cursor, err := dm.collections().AuditLog.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	entries := []types.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// RecordAudit appends an entry to the audit log bucket, keyed by a sequence
+// number so entries keep their insertion order
+func (s *BoltTripStore) RecordAudit(ctx context.Context, entry types.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry %s: %w", entry.Action, err)
+	}
+
+	if err := s.appendToBucket(boltAuditBucket, data); err != nil {
+		return fmt.Errorf("failed to record audit entry %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// appendToBucket stores a value under the bucket's next sequence number
+func (s *BoltTripStore) appendToBucket(name, data []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(name)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return bucket.Put(key, data)
+	})
+}
+
+// AuditEntries returns the audit entries matching the query, newest first,
+// walking the bucket backwards from the latest entry
+func (s *BoltTripStore) AuditEntries(ctx context.Context, query types.AuditQuery) ([]types.AuditEntry, error) {
+	limit := auditLimit(query)
+	entries := []types.AuditEntry{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(boltAuditBucket).Cursor()
+		for k, v := cursor.Last(); k != nil && len(entries) < limit; k, v = cursor.Prev() {
+			var entry types.AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("failed to decode audit entry %x: %w", k, err)
+			}
+			if matchesAuditQuery(entry, query) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// auditLimit returns the page size of an audit query
+func auditLimit(query types.AuditQuery) int {
+	if query.Limit <= 0 {
+		return defaultAuditPageSize
+	}
+	return query.Limit
+}
+
+// matchesAuditQuery reports whether an audit entry passes the query filters
+func matchesAuditQuery(entry types.AuditEntry, query types.AuditQuery) bool {
+	switch {
+	case query.Action != "" && entry.Action != query.Action:
+		return false
+	case query.Actor != "" && entry.Actor != query.Actor:
+		return false
+	case query.Target != "" && entry.Target != query.Target:
+		return false
+	case query.From > 0 && entry.Timestamp.UnixMilli() < query.From:
+		return false
+	case query.To > 0 && entry.Timestamp.UnixMilli() >= query.To:
+		return false
+	}
+	return true
+}
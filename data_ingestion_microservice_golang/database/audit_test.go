@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_AuditEntries(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	start := time.UnixMilli(1700000000000).UTC()
+	for i, entry := range []types.AuditEntry{
+		{Action: "simplification.update", Actor: "admin"},
+		{Action: "driver_data.delete", Actor: "admin", Target: "driver-1"},
+		{Action: "simplification.update", Actor: "ops"},
+	} {
+		entry.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if err := store.RecordAudit(ctx, entry); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   types.AuditQuery
+		targets []string
+		actors  []string
+	}{
+		{"newest first", types.AuditQuery{}, []string{"", "driver-1", ""}, []string{"ops", "admin", "admin"}},
+		{"by action", types.AuditQuery{Action: "simplification.update"}, []string{"", ""}, []string{"ops", "admin"}},
+		{"by target", types.AuditQuery{Target: "driver-1"}, []string{"driver-1"}, []string{"admin"}},
+		{"time range", types.AuditQuery{From: start.Add(time.Minute).UnixMilli(), To: start.Add(2 * time.Minute).UnixMilli()}, []string{"driver-1"}, []string{"admin"}},
+		{"limit", types.AuditQuery{Limit: 1}, []string{""}, []string{"ops"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := store.AuditEntries(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(entries) != len(tt.actors) {
+				t.Fatalf("Expected %d entries, got %+v", len(tt.actors), entries)
+			}
+			for i, entry := range entries {
+				if entry.Actor != tt.actors[i] || entry.Target != tt.targets[i] {
+					t.Errorf("Expected entry %d by %s on %q, got %+v", i, tt.actors[i], tt.targets[i], entry)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BoltDB buckets used by the embedded trip store
+var (
+	boltTripsBucket         = []byte("trips")
+	boltFinalizedBucket     = []byte("finalized_trips")
+	boltRawRoutesBucket     = []byte("trips_raw")
+	boltPlannedRoutesBucket = []byte("planned_routes")
+	boltGeofencesBucket     = []byte("geofences")
+	boltDevicesBucket       = []byte("devices")
+	boltShiftsBucket        = []byte("shifts")
+	boltAssignmentsBucket   = []byte("vehicle_assignments")
+	boltOdometerBucket      = []byte("daily_distances")
+	boltHeatmapBucket       = []byte("heatmap_cells")
+	boltSettingsBucket      = []byte("settings")
+	boltAuditBucket         = []byte("audit_log")
+	boltWebhookDLQBucket    = []byte("webhook_dlq")
+)
+
+// boltOpenTimeout bounds how long opening waits for another process's lock
+const boltOpenTimeout = 5 * time.Second
+
+// BoltTripStore is a single-file trip store used by the embedded storage
+// mode. Trip documents are stored in the same BSON layout as in MongoDB, and
+// a trip and its finalization marker are committed in one transaction.
+type BoltTripStore struct {
+	db                *bbolt.DB
+	tripSchemaVersion int
+}
+
+// NewBoltTripStore opens (or creates) the BoltDB file at path
+func NewBoltTripStore(path string, tripSchemaVersion int) (*BoltTripStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open BoltDB %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{boltTripsBucket, boltFinalizedBucket, boltRawRoutesBucket, boltPlannedRoutesBucket, boltGeofencesBucket, boltDevicesBucket, boltShiftsBucket, boltAssignmentsBucket, boltOdometerBucket, boltHeatmapBucket, boltSettingsBucket, boltAuditBucket, boltWebhookDLQBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create BoltDB buckets: %w", err)
+	}
+
+	return &BoltTripStore{db: db, tripSchemaVersion: tripSchemaVersion}, nil
+}
+
+// IsFinalized reports whether a trip was already stored
+func (s *BoltTripStore) IsFinalized(ctx context.Context, id string) (bool, error) {
+	var finalized bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		finalized = tx.Bucket(boltFinalizedBucket).Get([]byte(id)) != nil
+		return nil
+	})
+	return finalized, err
+}
+
+// SaveTrip stores a trip document and its finalization marker atomically
+func (s *BoltTripStore) SaveTrip(ctx context.Context, key string, trip types.Trip) error {
+	doc, err := EncodeTrip(trip, s.tripSchemaVersion)
+	if err != nil {
+		return fmt.Errorf("failed to encode trip: %w", err)
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trip %s: %w", trip.ID, err)
+	}
+	marker, err := json.Marshal(map[string]interface{}{
+		"routeKey":  key,
+		"createdAt": time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal finalization marker: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(boltTripsBucket).Put([]byte(trip.ID), data); err != nil {
+			return err
+		}
+		return tx.Bucket(boltFinalizedBucket).Put([]byte(trip.ID), marker)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store trip %s in BoltDB: %w", trip.ID, err)
+	}
+	return nil
+}
+
+// SaveRawRoute stores the compressed raw points of a trip
+func (s *BoltTripStore) SaveRawRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	compressed, err := CompressPoints(points)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltRawRoutesBucket).Put([]byte(trip.ID), compressed)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store raw route for trip %s: %w", trip.ID, err)
+	}
+	return nil
+}
+
+// Trip returns a stored trip document, or nil if it does not exist
+func (s *BoltTripStore) Trip(id string) (bson.M, error) {
+	var doc bson.M
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(boltTripsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		return bson.Unmarshal(data, &doc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trip %s: %w", id, err)
+	}
+	return doc, nil
+}
+
+// FindPlannedRoute returns the planned route stored as JSON under its route
+// ID, or nil if there is none
+func (s *BoltTripStore) FindPlannedRoute(ctx context.Context, routeID string) (*types.PlannedRoute, error) {
+	var route *types.PlannedRoute
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(boltPlannedRoutesBucket).Get([]byte(routeID))
+		if data == nil {
+			return nil
+		}
+		route = &types.PlannedRoute{}
+		return json.Unmarshal(data, route)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find planned route %s: %w", routeID, err)
+	}
+	return route, nil
+}
+
+// SavePlannedRoute stores a planned route under its route ID
+func (s *BoltTripStore) SavePlannedRoute(route types.PlannedRoute) error {
+	data, err := json.Marshal(route)
+	if err != nil {
+		return fmt.Errorf("failed to marshal planned route %s: %w", route.RouteID, err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltPlannedRoutesBucket).Put([]byte(route.RouteID), data)
+	})
+}
+
+// Close closes the BoltDB file
+func (s *BoltTripStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_SaveTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	trip := types.Trip{
+		ID:              "trip-1",
+		DriverID:        "driver_001",
+		CurrentRouteID:  "route_123",
+		SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000}},
+	}
+
+	if finalized, _ := store.IsFinalized(ctx, trip.ID); finalized {
+		t.Fatalf("Expected trip not to be finalized before saving")
+	}
+	if err := store.SaveTrip(ctx, "route:driver_001:route_123", trip); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if finalized, _ := store.IsFinalized(ctx, trip.ID); !finalized {
+		t.Errorf("Expected trip to be finalized after saving")
+	}
+
+	doc, err := store.Trip(trip.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if doc["driverId"] != "driver_001" {
+		t.Errorf("Expected driverId driver_001, got %v", doc["driverId"])
+	}
+
+	stored, err := store.FindTrip(ctx, trip.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(stored.SimplifiedRoute) != 1 || stored.SimplifiedRoute[0] != trip.SimplifiedRoute[0] {
+		t.Errorf("Expected the stamped route point, got %+v", stored.SimplifiedRoute)
+	}
+}
+
+func TestBoltTripStore_SimplificationSettings(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	settings, err := store.LoadSimplificationSettings(ctx)
+	if err != nil || settings != nil {
+		t.Fatalf("Expected no saved settings, got %v (%v)", settings, err)
+	}
+
+	saved := types.SimplificationSettings{Tolerance: 0.0005, Algorithm: "visvalingam-whyatt"}
+	if err := store.SaveSimplificationSettings(ctx, saved); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	settings, err = store.LoadSimplificationSettings(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if settings == nil || settings.Tolerance != saved.Tolerance || settings.Algorithm != saved.Algorithm {
+		t.Errorf("Expected %+v, got %+v", saved, settings)
+	}
+}
+
+func TestBoltTripStore_DeleteDriverData(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	trips := []types.Trip{
+		{ID: "trip-1", DriverID: "driver_001", CurrentRouteID: "route_1"},
+		{ID: "trip-2", DriverID: "driver_001", CurrentRouteID: "route_2"},
+		{ID: "trip-3", DriverID: "driver_002", CurrentRouteID: "route_3"},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := store.SaveRawRoute(ctx, trips[0], []types.TrackPoint{{Timestamp: 1}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	distances := []types.DailyDistance{{DriverID: "driver_001", Day: "2022-01-01"}, {VehicleID: "bus_7", Day: "2022-01-01"}}
+	for i := range distances {
+		distances[i].ID = DailyDistanceID(distances[i])
+	}
+	if err := store.SaveDailyDistances(ctx, distances); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	report, err := store.DeleteDriverData(ctx, "driver_001")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Trips != 2 || report.RawRoutes != 1 || report.DailyDistances != 1 {
+		t.Errorf("Expected 2 trips, 1 raw route, and 1 daily distance to be deleted, got %+v", report)
+	}
+
+	if finalized, _ := store.IsFinalized(ctx, "trip-1"); finalized {
+		t.Error("Expected the finalization marker to be deleted")
+	}
+	if points, _ := store.RawPoints(ctx, "trip-1"); points != nil {
+		t.Errorf("Expected the raw route to be deleted, got %v", points)
+	}
+	if doc, _ := store.Trip("trip-3"); doc == nil {
+		t.Error("Expected trips of other drivers to be kept")
+	}
+}
+
+func TestBoltTripStore_Geofences(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	depot := types.Geofence{ID: "depot", Name: "Depot", Center: &types.Location{Latitude: 6.25, Longitude: -75.56}, RadiusMeters: 200}
+	if err := store.SaveGeofence(depot); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	geofences, err := store.LoadGeofences(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(geofences) != 1 || geofences[0].ID != "depot" || geofences[0].RadiusMeters != 200 {
+		t.Errorf("Expected the saved geofence, got %+v", geofences)
+	}
+}
+
+func TestBoltTripStore_DeviceKeys(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	if err := store.SaveDeviceKey(types.DeviceKey{DeviceID: "driver_001", Key: "secret"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	keys, err := store.LoadDeviceKeys(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keys) != 1 || keys[0].DeviceID != "driver_001" || keys[0].Key != "secret" {
+		t.Errorf("Expected the saved device key, got %+v", keys)
+	}
+}
+
+func TestBoltTripStore_Shifts(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	start := time.Date(2022, 1, 1, 6, 0, 0, 0, time.UTC)
+	if err := store.StartShift(ctx, "driver_001", start); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	trip := types.ShiftTrip{TripID: "trip-1", StartedAt: start.Add(time.Hour), EndedAt: start.Add(2 * time.Hour), DistanceMeters: 1000}
+	for i := 0; i < 2; i++ {
+		if err := store.AddShiftTrip(ctx, "driver_001", "2022-01-01", trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if ended, err := store.EndShift(ctx, "driver_001", start.Add(8*time.Hour)); err != nil || !ended {
+		t.Fatalf("Expected the shift to end, got %v %v", ended, err)
+	}
+
+	// A trip after the shift ended goes to the inferred shift of its day
+	late := types.ShiftTrip{TripID: "trip-2", StartedAt: start.Add(10 * time.Hour), EndedAt: start.Add(11 * time.Hour)}
+	if err := store.AddShiftTrip(ctx, "driver_001", "2022-01-01", late); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	shifts, err := store.FindShifts(ctx, types.ShiftQuery{DriverID: "driver_001"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(shifts) != 2 {
+		t.Fatalf("Expected 2 shifts, got %+v", shifts)
+	}
+	inferred, explicit := shifts[0], shifts[1]
+	if !inferred.Inferred || inferred.ID != InferredShiftID("driver_001", "2022-01-01") || inferred.TripCount != 1 {
+		t.Errorf("Expected the late trip in the inferred shift, got %+v", inferred)
+	}
+	if explicit.TripCount != 1 || explicit.DistanceMeters != 1000 || explicit.EndedAt == nil {
+		t.Errorf("Expected the ended shift to count the trip once, got %+v", explicit)
+	}
+}
+
+func TestBoltTripStore_Assignments(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	start := time.Date(2022, 1, 1, 6, 0, 0, 0, time.UTC)
+	steps := []struct {
+		driverID, vehicleID string
+		at                  time.Time
+	}{
+		{"driver_001", "bus_7", start},
+		{"driver_001", "bus_7", start.Add(time.Minute)},
+		{"driver_002", "bus_7", start.Add(time.Hour)},
+		{"driver_001", "bus_9", start.Add(2 * time.Hour)},
+	}
+	for _, step := range steps {
+		if err := store.StartAssignment(ctx, step.driverID, step.vehicleID, step.at); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	bus7, err := store.FindAssignments(ctx, types.AssignmentQuery{VehicleID: "bus_7"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(bus7) != 2 {
+		t.Fatalf("Expected 2 assignments of bus_7, got %+v", bus7)
+	}
+	if bus7[0].DriverID != "driver_002" || bus7[0].EndedAt != nil {
+		t.Errorf("Expected driver_002 to still drive bus_7, got %+v", bus7[0])
+	}
+	if bus7[1].DriverID != "driver_001" || bus7[1].EndedAt == nil || !bus7[1].EndedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected driver_001's assignment to end when driver_002 took over, got %+v", bus7[1])
+	}
+
+	driver1, err := store.FindAssignments(ctx, types.AssignmentQuery{DriverID: "driver_001", From: start.Add(time.Hour).UnixMilli()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(driver1) != 1 || driver1[0].VehicleID != "bus_9" {
+		t.Errorf("Expected only the bus_9 assignment, got %+v", driver1)
+	}
+}
+
+func TestBoltTripStore_DailyDistances(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	var distances []types.DailyDistance
+	for _, distance := range []types.DailyDistance{
+		{VehicleID: "bus_7", Day: "2022-01-03", DistanceMeters: 300},
+		{VehicleID: "bus_7", Day: "2022-01-01", DistanceMeters: 100},
+		{VehicleID: "bus_7", Day: "2022-01-02", DistanceMeters: 200},
+		{DriverID: "driver_001", Day: "2022-01-02", DistanceMeters: 200},
+	} {
+		distance.ID = DailyDistanceID(distance)
+		distances = append(distances, distance)
+	}
+	if err := store.SaveDailyDistances(ctx, distances); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A later rollup replaces the day's distance
+	distances[2].DistanceMeters = 250
+	if err := store.SaveDailyDistances(ctx, distances[2:3]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	found, err := store.FindDailyDistances(ctx, types.OdometerQuery{VehicleID: "bus_7", From: "2022-01-02"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(found) != 2 || found[0].Day != "2022-01-02" || found[0].DistanceMeters != 250 || found[1].Day != "2022-01-03" {
+		t.Errorf("Expected the bus_7 days from 2022-01-02 in order, got %+v", found)
+	}
+	if found[0].ID != "vehicle:bus_7:2022-01-02" {
+		t.Errorf("Expected the summary ID to be kept, got %q", found[0].ID)
+	}
+}
+
+func TestBoltTripStore_HeatmapCells(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	cell := func(start, geohash string, points int64) types.HeatmapCell {
+		return types.HeatmapCell{ID: HeatmapCellID(types.HeatmapPeriodDay, start, geohash), Period: types.HeatmapPeriodDay, Start: start, Geohash: geohash, Points: points}
+	}
+	if err := store.SaveHeatmapCells(ctx, types.HeatmapPeriodDay, "2022-01-01", []types.HeatmapCell{cell("2022-01-01", "d34780e", 3), cell("2022-01-01", "d34780f", 1)}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := store.SaveHeatmapCells(ctx, types.HeatmapPeriodDay, "2022-01-02", []types.HeatmapCell{cell("2022-01-02", "d34780e", 5)}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Recounting a day replaces its cells
+	if err := store.SaveHeatmapCells(ctx, types.HeatmapPeriodDay, "2022-01-01", []types.HeatmapCell{cell("2022-01-01", "d34780e", 4)}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cells, err := store.FindHeatmapCells(ctx, types.HeatmapPeriodDay, "2022-01-01")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cells) != 1 || cells[0].Geohash != "d34780e" || cells[0].Points != 4 || cells[0].Start != "2022-01-01" {
+		t.Errorf("Expected only the recounted cell, got %+v", cells)
+	}
+}
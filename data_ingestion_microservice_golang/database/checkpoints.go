@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"data-ingestion-microservice/types"
+)
+
+// FinalizationCheckpointsKey is the Redis hash of the finalizations in
+// progress, keyed by route key
+const FinalizationCheckpointsKey = "finalizing"
+
+// BeginFinalization records that a route is being finalized
+func (dm *DatabaseManager) BeginFinalization(ctx context.Context, checkpoint types.FinalizationCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal finalization checkpoint: %w", err)
+	}
+	if err := dm.RedisClient.HSet(ctx, FinalizationCheckpointsKey, checkpoint.Key, data).Err(); err != nil {
+		return fmt.Errorf("failed to record finalization of %s: %w", checkpoint.Key, err)
+	}
+	return nil
+}
+
+// EndFinalization removes the checkpoint of a route once its finalization
+// has completed or failed
+func (dm *DatabaseManager) EndFinalization(ctx context.Context, key string) error {
+	if err := dm.RedisClient.HDel(ctx, FinalizationCheckpointsKey, key).Err(); err != nil {
+		return fmt.Errorf("failed to remove finalization checkpoint of %s: %w", key, err)
+	}
+	return nil
+}
+
+// PendingFinalizations returns the finalizations that were begun but never
+// ended, skipping checkpoints that cannot be decoded
+func (dm *DatabaseManager) PendingFinalizations(ctx context.Context) ([]types.FinalizationCheckpoint, error) {
+	values, err := dm.RedisClient.HGetAll(ctx, FinalizationCheckpointsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read finalization checkpoints: %w", err)
+	}
+
+	checkpoints := make([]types.FinalizationCheckpoint, 0, len(values))
+	for key, value := range values {
+		var checkpoint types.FinalizationCheckpoint
+		if err := json.Unmarshal([]byte(value), &checkpoint); err != nil {
+			slog.WarnContext(ctx, "Skipping undecodable finalization checkpoint", "key", key, "error", err)
+			continue
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// clickHouseTimeout bounds a single ClickHouse HTTP request
+const clickHouseTimeout = 30 * time.Second
+
+// clickHouseTimeLayout formats DateTime64(3) values
+const clickHouseTimeLayout = "2006-01-02 15:04:05.000"
+
+// clickHouseRow is a raw point in the ClickHouse JSONEachRow insert format
+type clickHouseRow struct {
+	TripID   string  `json:"trip_id"`
+	DriverID string  `json:"driver_id"`
+	RouteID  string  `json:"route_id"`
+	Ts       string  `json:"ts"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+}
+
+// pendingPoints are the rows of one trip waiting for the next batch insert
+type pendingPoints struct {
+	rows []clickHouseRow
+	done chan error
+}
+
+// ClickHouseSink batches the raw points of finalized trips into a ClickHouse
+// MergeTree table over the HTTP interface, flushing when enough points are
+// pending or the flush interval elapses. WriteTrip blocks until the trip's
+// points are inserted.
+type ClickHouseSink struct {
+	client        *http.Client
+	config        types.ClickHouseConfig
+	table         string
+	batchSize     int
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	pending []pendingPoints
+	count   int
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+// NewClickHouseSink creates the point table if needed and starts the
+// periodic flusher
+func NewClickHouseSink(ctx context.Context, config types.ClickHouseConfig) (*ClickHouseSink, error) {
+	sink := &ClickHouseSink{
+		client:        &http.Client{Timeout: clickHouseTimeout},
+		config:        config,
+		table:         fmt.Sprintf("`%s`.`%s`", config.Database, config.Table),
+		batchSize:     config.BatchSize,
+		flushInterval: config.BatchInterval,
+		stop:          make(chan struct{}),
+	}
+
+	// ReplacingMergeTree collapses rows re-inserted by retried finalizations
+	ddl := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	trip_id String,
+	driver_id LowCardinality(String),
+	route_id LowCardinality(String),
+	ts DateTime64(3, 'UTC'),
+	lat Float64,
+	lon Float64
+) ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(ts)
+ORDER BY (driver_id, route_id, ts)`, sink.table)
+	if err := sink.exec(ctx, ddl, nil); err != nil {
+		return nil, fmt.Errorf("failed to create ClickHouse table %s: %w", sink.table, err)
+	}
+
+	sink.stopped.Add(1)
+	go sink.run()
+
+	return sink, nil
+}
+
+// WriteTrip queues the trip's timestamped points and waits for their batch
+// to be inserted
+func (s *ClickHouseSink) WriteTrip(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	rows := make([]clickHouseRow, 0, len(points))
+	for _, point := range points {
+		if point.Timestamp == 0 {
+			continue
+		}
+		rows = append(rows, clickHouseRow{
+			TripID:   trip.ID,
+			DriverID: trip.DriverID,
+			RouteID:  trip.CurrentRouteID,
+			Ts:       time.UnixMilli(int64(point.Timestamp)).UTC().Format(clickHouseTimeLayout),
+			Lat:      point.Latitude,
+			Lon:      point.Longitude,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	done := make(chan error, 1)
+
+	s.mu.Lock()
+	s.pending = append(s.pending, pendingPoints{rows: rows, done: done})
+	s.count += len(rows)
+	full := s.count >= s.batchSize
+	s.mu.Unlock()
+
+	// The batch holds the points of other trips too, so canceling this
+	// one must not fail the whole insert
+	if full {
+		s.Flush(context.WithoutCancel(ctx))
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush inserts all pending points in a single request, within
+// clickHouseTimeout
+func (s *ClickHouseSink) Flush(ctx context.Context) {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.count = 0
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, clickHouseTimeout)
+	defer cancel()
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, trip := range batch {
+		for _, row := range trip.rows {
+			encoder.Encode(row)
+		}
+	}
+
+	metrics.ClickHouseBatchFlushes.Add(1)
+	err := s.exec(ctx, fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", s.table), &body)
+	if err != nil {
+		err = fmt.Errorf("failed to insert points into ClickHouse: %w", err)
+	}
+	for _, trip := range batch {
+		trip.done <- err
+	}
+}
+
+// exec runs a query, sending body as the query's input data
+func (s *ClickHouseSink) exec(ctx context.Context, query string, body io.Reader) error {
+	endpoint, err := url.Parse(s.config.URL)
+	if err != nil {
+		return fmt.Errorf("invalid ClickHouse URL: %w", err)
+	}
+	params := endpoint.Query()
+	params.Set("query", query)
+	endpoint.RawQuery = params.Encode()
+
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), body)
+	if err != nil {
+		return err
+	}
+	if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("ClickHouse returned %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// Close stops the periodic flusher and inserts any pending points
+func (s *ClickHouseSink) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	s.stopped.Wait()
+	s.Flush(context.Background())
+	return nil
+}
+
+// run flushes pending points every flush interval until closed
+func (s *ClickHouseSink) run() {
+	defer s.stopped.Done()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
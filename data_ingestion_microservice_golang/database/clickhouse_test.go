@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestClickHouseSink_WriteTrip(t *testing.T) {
+	var mu sync.Mutex
+	var inserts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if strings.HasPrefix(query, "INSERT") {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			inserts = append(inserts, string(body))
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouseSink(context.Background(), types.ClickHouseConfig{
+		URL:           server.URL,
+		Database:      "default",
+		Table:         "raw_points",
+		BatchSize:     2,
+		BatchInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer sink.Close()
+
+	trip := types.Trip{ID: "trip-1", DriverID: "driver_001", CurrentRouteID: "route_123"}
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995201500},
+		{Location: types.Location{Latitude: 6.2460, Longitude: -75.5830}},
+	}
+
+	if err := sink.WriteTrip(context.Background(), trip, points); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(inserts) != 1 {
+		t.Fatalf("Expected 1 insert, got %d", len(inserts))
+	}
+	lines := strings.Split(strings.TrimSpace(inserts[0]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 rows without the untimestamped point, got %d", len(lines))
+	}
+	if !strings.Contains(lines[1], `"ts":"2022-01-01 00:00:01.500"`) {
+		t.Errorf("Unexpected row %s", lines[1])
+	}
+}
+
+func TestClickHouseSink_CanceledCallerKeepsBatch(t *testing.T) {
+	var mu sync.Mutex
+	var rows int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Query().Get("query"), "INSERT") {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			rows += strings.Count(string(body), "\n")
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouseSink(context.Background(), types.ClickHouseConfig{
+		URL:           server.URL,
+		Database:      "default",
+		Table:         "raw_points",
+		BatchSize:     2,
+		BatchInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer sink.Close()
+
+	// The first trip waits for the batch; the second fills it from a
+	// request that was already canceled
+	first := make(chan error, 1)
+	go func() {
+		first <- sink.WriteTrip(context.Background(), types.Trip{ID: "trip-1"}, []types.TrackPoint{{Timestamp: 1640995200000}})
+	}()
+	for {
+		sink.mu.Lock()
+		queued := len(sink.pending)
+		sink.mu.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink.WriteTrip(canceled, types.Trip{ID: "trip-2"}, []types.TrackPoint{{Timestamp: 1640995201000}})
+
+	if err := <-first; err != nil {
+		t.Fatalf("Expected the first trip to be inserted, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if rows != 2 {
+		t.Errorf("Expected both trips to be inserted, got %d rows", rows)
+	}
+}
@@ -2,24 +2,62 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"data-ingestion-microservice/secrets"
 	"data-ingestion-microservice/types"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
+// GeoRouteField is the trip document field holding the GeoJSON route geometry
+const GeoRouteField = "simplifiedRouteGeo"
+
+// mqttUnsubscribeTimeout bounds how long unsubscribing waits for the broker
+const mqttUnsubscribeTimeout = 5 * time.Second
+
 // DatabaseManager handles all database connections
 type DatabaseManager struct {
-	RedisClient     *redis.Client
-	MongoClient     *mongo.Client
-	MongoCollection *mongo.Collection
-	MQTTClient      mqtt.Client
-	ctx             context.Context
+	RedisClient       *redis.Client
+	TripWriter        *TripWriter
+	TripReader        *TripReader
+	PointWriter       *PointWriter
+	Sinks             []TripSink
+	Archiver          *S3Archiver
+	History           *RedisMessageHistory
+	MQTTClient        mqtt.Client
+	mqttRouter        *mqttRouter
+	redisConfig       types.RedisConfig
+	tripSchemaVersion int
+	ctx               context.Context
+
+	// The MongoDB client and its collections, replaced together when the
+	// MongoDB credentials rotate
+	mongo       atomic.Pointer[mongoCollections]
+	mongoConfig types.Config
+
+	// Connecting to backends that were down at startup stops on close
+	startupCtx  context.Context
+	stopStartup context.CancelFunc
+	startupDone sync.WaitGroup
+
+	// Credentials refreshed from the secrets provider, nil unless they rotate
+	credentials *secrets.Store
+	stopSecrets context.CancelFunc
+	secretsDone sync.WaitGroup
 }
 
 // NewDatabaseManager creates and initializes all database connections
@@ -27,100 +65,496 @@ func NewDatabaseManager(ctx context.Context, config types.Config) (*DatabaseMana
 	manager := &DatabaseManager{
 		ctx: ctx,
 	}
+	manager.startupCtx, manager.stopStartup = context.WithCancel(ctx)
+
+	// Pick up rotated credentials for new connections
+	if secrets.Rotates(config.Secrets) {
+		if err := manager.watchSecrets(config); err != nil {
+			return nil, fmt.Errorf("failed to setup secrets: %w", err)
+		}
+	}
 
 	// Setup Redis connection
-	if err := manager.setupRedis(config.Redis); err != nil {
+	if err := manager.setupRedis(config.Redis, config.Startup); err != nil {
 		return nil, fmt.Errorf("failed to setup Redis: %w", err)
 	}
 
+	// Remember the latest messages of every driver to drop retransmissions
+	if config.Dedup.Enabled {
+		manager.History = NewRedisMessageHistory(manager.RedisClient, config.Dedup)
+	}
+
 	// Setup MongoDB connection
-	if err := manager.setupMongoDB(ctx, config.MongoDB); err != nil {
+	if err := manager.setupMongoDB(ctx, config); err != nil {
 		return nil, fmt.Errorf("failed to setup MongoDB: %w", err)
 	}
 
+	// Setup the optional TimescaleDB point sink
+	if config.Timescale.Enabled {
+		sink, err := NewTimescaleSink(ctx, config.Timescale)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup TimescaleDB: %w", err)
+		}
+		manager.Sinks = append(manager.Sinks, sink)
+	}
+
+	// Setup the optional ClickHouse point sink
+	if config.ClickHouse.Enabled {
+		sink, err := NewClickHouseSink(ctx, config.ClickHouse)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup ClickHouse: %w", err)
+		}
+		manager.Sinks = append(manager.Sinks, sink)
+	}
+
+	// Setup the optional OpenSearch trip index
+	if config.OpenSearch.Enabled {
+		sink, err := NewOpenSearchSink(ctx, config.OpenSearch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup OpenSearch: %w", err)
+		}
+		manager.Sinks = append(manager.Sinks, sink)
+	}
+
+	// Setup the optional Kafka trip stream
+	if config.Kafka.Enabled {
+		manager.Sinks = append(manager.Sinks, NewKafkaSink(config.Kafka, config.MongoDB.TripSchemaVersion))
+	}
+
+	// Setup the optional S3 raw trace archive
+	if config.Archive.Enabled {
+		archiver, err := NewS3Archiver(ctx, config.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup S3 archive: %w", err)
+		}
+		manager.Archiver = archiver
+	}
+
 	// Setup MQTT connection
-	if err := manager.setupMQTT(config.MQTT); err != nil {
+	if err := manager.setupMQTT(config.MQTT, config.Startup.MQTT); err != nil {
 		return nil, fmt.Errorf("failed to setup MQTT: %w", err)
 	}
 
 	return manager, nil
 }
 
-// setupRedis initializes Redis connection
-func (dm *DatabaseManager) setupRedis(config types.RedisConfig) error {
-	dm.RedisClient = redis.NewClient(&redis.Options{
-		Addr:     config.Address,
-		Password: config.Password,
-		DB:       config.DB,
-	})
+// setupRedis initializes Redis connection, waiting for Redis to come up
+func (dm *DatabaseManager) setupRedis(config types.RedisConfig, startup types.StartupConfig) error {
+	dm.redisConfig = config
+	if config.SentinelMasterName != "" {
+		// Discover the primary through Sentinel so failovers are followed
+		dm.RedisClient = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       config.SentinelMasterName,
+			SentinelAddrs:    config.SentinelAddresses,
+			SentinelUsername: config.SentinelUsername,
+			SentinelPassword: config.SentinelPassword,
+			Password:         config.Password,
+			DB:               config.DB,
+			PoolSize:         config.PoolSize,
+			MinIdleConns:     config.MinIdleConns,
+			DialTimeout:      config.DialTimeout,
+			ReadTimeout:      config.ReadTimeout,
+			WriteTimeout:     config.WriteTimeout,
+			MaxRetries:       config.MaxRetries,
+			TLSConfig:        redisTLS(config),
+		})
+	} else {
+		dm.RedisClient = redis.NewClient(&redis.Options{
+			Addr:         config.Address,
+			Password:     config.Password,
+			DB:           config.DB,
+			PoolSize:     config.PoolSize,
+			MinIdleConns: config.MinIdleConns,
+			DialTimeout:  config.DialTimeout,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+			MaxRetries:   config.MaxRetries,
+			TLSConfig:    redisTLS(config),
+		})
+	}
+
+	// Authenticate new connections with the latest rotated password. The
+	// options are read whenever a connection is opened, and none is yet.
+	if dm.credentials != nil {
+		dm.RedisClient.Options().CredentialsProvider = dm.redisCredentials(dm.RedisClient.Options().Username)
+	}
+
+	dm.PointWriter = NewPointWriter(dm.RedisClient, config)
 
 	// Test connection
-	_, err := dm.RedisClient.Ping(dm.ctx).Result()
+	ping := func(ctx context.Context) error {
+		return dm.RedisClient.Ping(ctx).Err()
+	}
+	ready := func(ctx context.Context) error {
+		// Create the consumer group used for multi-instance finalization
+		if config.FinalizeConsumerGroup {
+			return dm.setupFinalizationGroup(ctx)
+		}
+		return nil
+	}
+	if err := waitFor(dm.ctx, "redis", startup.Redis, ping); err != nil {
+		if !startDegraded(startup, "redis", err) {
+			return fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		dm.connectInBackground("redis", startup.Redis, ping, ready)
+		return nil
+	}
+	return ready(dm.ctx)
+}
+
+// setupMongoDB initializes MongoDB connection, waiting for MongoDB to come
+// up
+func (dm *DatabaseManager) setupMongoDB(ctx context.Context, appConfig types.Config) error {
+	config := appConfig.MongoDB
+
+	clientOptions, err := mongoClientOptions(config)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %w", err)
+		return err
+	}
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 
-	return nil
+	dm.mongoConfig = appConfig
+	dm.tripSchemaVersion = config.TripSchemaVersion
+	dm.mongo.Store(openMongoCollections(client, appConfig))
+	dm.TripWriter = newTripWriter(&dm.mongo, config)
+	dm.TripReader = &TripReader{handles: &dm.mongo}
+
+	// Test connection
+	ping := func(ctx context.Context) error {
+		return dm.collections().Client.Ping(ctx, nil)
+	}
+	ready := func(ctx context.Context) error {
+		// Create query and geospatial indexes unless DDL is restricted
+		if config.EnsureIndexes {
+			if err := dm.EnsureIndexes(ctx, config.RetentionDays); err != nil {
+				return err
+			}
+		}
+
+		// Upgrade trips written with older schema versions
+		if config.MigrateOnStartup {
+			if _, err := dm.MigrateTrips(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := waitFor(ctx, "mongodb", appConfig.Startup.MongoDB, ping); err != nil {
+		if !startDegraded(appConfig.Startup, "mongodb", err) {
+			return fmt.Errorf("failed to ping MongoDB: %w", err)
+		}
+		dm.connectInBackground("mongodb", appConfig.Startup.MongoDB, ping, ready)
+		return nil
+	}
+	return ready(ctx)
 }
 
-// setupMongoDB initializes MongoDB connection
-func (dm *DatabaseManager) setupMongoDB(ctx context.Context, config types.MongoDBConfig) error {
-	clientOptions := options.Client().ApplyURI(config.URI)
+// mongoCollections are a MongoDB client and the collections of the service
+type mongoCollections struct {
+	Client         *mongo.Client
+	Trips          *mongo.Collection
+	PlannedRoutes  *mongo.Collection
+	Geofences      *mongo.Collection
+	Devices        *mongo.Collection
+	Shifts         *mongo.Collection
+	Assignments    *mongo.Collection
+	Odometer       *mongo.Collection
+	Heatmap        *mongo.Collection
+	RawRoutes      *mongo.Collection
+	FinalizedTrips *mongo.Collection
+	Settings       *mongo.Collection
+	RouteSettings  *mongo.Collection
+	AuditLog       *mongo.Collection
+	WebhookDLQ     *mongo.Collection
+}
+
+// openMongoCollections opens the configured collections on client
+func openMongoCollections(client *mongo.Client, appConfig types.Config) *mongoCollections {
+	config := appConfig.MongoDB
+	db := client.Database(config.Database)
+	return &mongoCollections{
+		Client:         client,
+		Trips:          db.Collection(config.Collection),
+		PlannedRoutes:  db.Collection(appConfig.RouteDeviation.Collection),
+		Geofences:      db.Collection(appConfig.Geofence.Collection),
+		Devices:        db.Collection(appConfig.Signatures.Collection),
+		Shifts:         db.Collection(appConfig.Shifts.Collection),
+		Assignments:    db.Collection(config.AssignmentCollection),
+		Odometer:       db.Collection(appConfig.Odometer.Collection),
+		Heatmap:        db.Collection(appConfig.Heatmap.Collection),
+		RawRoutes:      db.Collection(appConfig.RawRoutes.Collection),
+		FinalizedTrips: db.Collection(config.FinalizedCollection),
+		Settings:       db.Collection(config.SettingsCollection),
+		RouteSettings:  db.Collection(config.RouteSettingsCollection),
+		AuditLog:       db.Collection(config.AuditCollection),
+		WebhookDLQ:     db.Collection(config.WebhookDLQCollection),
+	}
+}
+
+// collections returns the current MongoDB client and collections
+func (dm *DatabaseManager) collections() *mongoCollections {
+	return dm.mongo.Load()
+}
+
+// ConnectMongo connects to MongoDB with the configured client options and
+// verifies the connection
+func ConnectMongo(ctx context.Context, config types.MongoDBConfig) (*mongo.Client, error) {
+	clientOptions, err := mongoClientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 
 	// Test connection
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to ping MongoDB: %w", err)
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	dm.MongoClient = client
-	db := client.Database(config.Database)
-	dm.MongoCollection = db.Collection(config.Collection)
+	return client, nil
+}
 
-	return nil
+// mongoClientOptions builds the MongoDB client options, applying the
+// configured write concern, read preference, and retryable writes on top of
+// the connection URI
+func mongoClientOptions(config types.MongoDBConfig) (*options.ClientOptions, error) {
+	clientOptions := options.Client().ApplyURI(config.URI)
+
+	// Write concern "w" is either a tag set name such as "majority" or a node count
+	var w interface{} = config.WriteConcern
+	if nodes, err := strconv.Atoi(config.WriteConcern); err == nil {
+		w = nodes
+	}
+	journal := config.WriteJournal
+	clientOptions.SetWriteConcern(&writeconcern.WriteConcern{
+		W:        w,
+		Journal:  &journal,
+		WTimeout: config.WriteTimeout,
+	})
+
+	mode, err := readpref.ModeFromString(config.ReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB read preference %q: %w", config.ReadPreference, err)
+	}
+	readPreference, err := readpref.New(mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB read preference %q: %w", config.ReadPreference, err)
+	}
+	clientOptions.SetReadPreference(readPreference)
+
+	clientOptions.SetRetryWrites(config.RetryWrites)
+
+	// Credentials from the secrets provider replace those in the URI
+	if config.Username != "" || config.Password != "" {
+		var credential options.Credential
+		if clientOptions.Auth != nil {
+			credential = *clientOptions.Auth
+		}
+		if config.Username != "" {
+			credential.Username = config.Username
+		}
+		if config.Password != "" {
+			credential.Password = config.Password
+			credential.PasswordSet = true
+		}
+		clientOptions.SetAuth(credential)
+	}
+
+	return clientOptions, nil
+}
+
+// redisTLS returns the TLS configuration of Redis connections, or nil for
+// plain connections
+func redisTLS(config types.RedisConfig) *tls.Config {
+	if !config.TLS {
+		return nil
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12}
 }
 
-// setupMQTT initializes MQTT connection
-func (dm *DatabaseManager) setupMQTT(config types.MQTTConfig) error {
+// setupMQTT initializes MQTT connection, waiting for the broker to come up.
+// Without the broker no messages arrive to buffer, so the service never
+// starts without it.
+func (dm *DatabaseManager) setupMQTT(config types.MQTTConfig, startup types.RetryConfig) error {
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("tcp://%s:%d", config.Broker, config.Port))
+	scheme := "tcp"
+	if config.TLS {
+		scheme = "ssl"
+	}
+	opts.AddBroker(fmt.Sprintf("%s://%s:%d", scheme, config.Broker, config.Port))
 	opts.SetClientID(config.ClientID)
 	opts.SetKeepAlive(5 * time.Second)
+	// Messages are acknowledged once processed, and a persistent session
+	// makes the broker redeliver unacknowledged ones after a crash
+	opts.SetAutoAckDisabled(true)
+	opts.SetCleanSession(config.CleanSession)
+	// Messages of the resumed session can arrive before the service
+	// subscribes, so the router is in place before connecting
+	dm.mqttRouter = newMQTTRouter()
+	opts.SetDefaultPublishHandler(dm.mqttRouter.route)
+	if dm.credentials != nil {
+		opts.SetCredentialsProvider(dm.mqttCredentials)
+	} else if config.Username != "" {
+		opts.SetUsername(config.Username)
+		opts.SetPassword(config.Password)
+	}
 	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
 		// Connection lost handler can be set externally if needed
 	})
 
 	dm.MQTTClient = mqtt.NewClient(opts)
-	token := dm.MQTTClient.Connect()
-	if token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+	err := waitFor(dm.ctx, "mqtt", startup, func(ctx context.Context) error {
+		token := dm.MQTTClient.Connect()
+		if !token.WaitTimeout(startupPingTimeout) {
+			return errors.New("timed out connecting to MQTT broker")
+		}
+		return token.Error()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
 	}
 
 	return nil
 }
 
-// SubscribeToTopic subscribes to an MQTT topic with a message handler
+// SubscribeToTopic subscribes to an MQTT topic with a message handler,
+// first passing it the messages of the topic that the resumed session
+// delivered before. Messages are not acknowledged automatically; the
+// handler acknowledges each one, from any goroutine, once it is safely
+// processed.
+//
+// Acknowledgments are therefore sent in the order processing completes,
+// not the order messages arrived in, which MQTT 3.1.1 §4.6 asks for. The
+// broker matches each PUBACK to its message by packet identifier, so an
+// out-of-order acknowledgment only releases that message; messages left
+// unacknowledged after a failure are redelivered when the session resumes.
 func (dm *DatabaseManager) SubscribeToTopic(topic string, handler mqtt.MessageHandler) error {
-	token := dm.MQTTClient.Subscribe(topic, 1, handler)
+	dm.mqttRouter.handle(dm.MQTTClient, topic, func(client mqtt.Client, msg mqtt.Message) {
+		handler(client, asyncAckMessage{msg})
+	})
+	token := dm.MQTTClient.Subscribe(topic, 1, nil)
 	if token.Wait() && token.Error() != nil {
+		dm.mqttRouter.remove(topic)
 		return fmt.Errorf("failed to subscribe to MQTT topic %s: %w", topic, token.Error())
 	}
 	return nil
 }
 
+// asyncAckMessage is an MQTT message that may be acknowledged after its
+// handler returned. The client closes the acknowledgment channel of a lost
+// connection, so late acknowledgments are dropped instead of panicking; the
+// broker redelivers those messages when the session resumes.
+type asyncAckMessage struct {
+	mqtt.Message
+}
+
+// Ack acknowledges the message if its connection is still up
+func (m asyncAckMessage) Ack() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.Debug("Dropped acknowledgment of a message from a lost connection", "topic", m.Topic(), "messageId", m.MessageID())
+		}
+	}()
+	m.Message.Ack()
+}
+
+// UnsubscribeFromTopic stops the delivery of messages from an MQTT topic.
+// The wait is bounded because the acknowledgment can queue behind messages
+// still being handed to a blocked handler.
+func (dm *DatabaseManager) UnsubscribeFromTopic(topic string) error {
+	dm.mqttRouter.remove(topic)
+	if !dm.MQTTClient.IsConnected() {
+		return nil
+	}
+	token := dm.MQTTClient.Unsubscribe(topic)
+	if !token.WaitTimeout(mqttUnsubscribeTimeout) {
+		return fmt.Errorf("timed out unsubscribing from MQTT topic %s", topic)
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("failed to unsubscribe from MQTT topic %s: %w", topic, token.Error())
+	}
+	return nil
+}
+
+// Redeliver reconnects to the MQTT broker, which redelivers the messages
+// the session left unacknowledged. Topics are subscribed to again when the
+// broker did not keep the session.
+func (dm *DatabaseManager) Redeliver() error {
+	dm.MQTTClient.Disconnect(250)
+	token := dm.MQTTClient.Connect()
+	if !token.WaitTimeout(startupPingTimeout) {
+		return errors.New("timed out reconnecting to MQTT broker")
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("failed to reconnect to MQTT broker: %w", token.Error())
+	}
+	if connect, ok := token.(*mqtt.ConnectToken); ok && connect.SessionPresent() {
+		return nil
+	}
+	for _, topic := range dm.mqttRouter.filters() {
+		token := dm.MQTTClient.Subscribe(topic, 1, nil)
+		if token.Wait() && token.Error() != nil {
+			return fmt.Errorf("failed to subscribe to MQTT topic %s: %w", topic, token.Error())
+		}
+	}
+	return nil
+}
+
+// PublishMessage publishes a payload to an MQTT topic
+func (dm *DatabaseManager) PublishMessage(topic string, payload []byte) error {
+	token := dm.MQTTClient.Publish(topic, 1, false, payload)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("failed to publish to MQTT topic %s: %w", topic, token.Error())
+	}
+	return nil
+}
+
+// FindPlannedRoute retrieves the planned route geometry for a route ID
+func (dm *DatabaseManager) FindPlannedRoute(ctx context.Context, routeID string) (*types.PlannedRoute, error) {
+	var route types.PlannedRoute
+	err := dm.collections().PlannedRoutes.FindOne(ctx, bson.M{"routeId": routeID}).Decode(&route)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find planned route %s: %w", routeID, err)
+	}
+	return &route, nil
+}
+
 // Close gracefully closes all database connections
 func (dm *DatabaseManager) Close() error {
 	var errs []error
 
+	// Stop connecting to backends that were down at startup
+	if dm.stopStartup != nil {
+		dm.stopStartup()
+		dm.startupDone.Wait()
+	}
+
+	// Stop refreshing credentials
+	if dm.stopSecrets != nil {
+		dm.stopSecrets()
+		dm.secretsDone.Wait()
+	}
+
 	// Close MQTT connection
 	if dm.MQTTClient != nil && dm.MQTTClient.IsConnected() {
 		dm.MQTTClient.Disconnect(250)
 	}
 
+	// Flush pipelined points before closing Redis
+	if dm.PointWriter != nil {
+		dm.PointWriter.Close(dm.ctx)
+	}
+
 	// Close Redis connection
 	if dm.RedisClient != nil {
 		if err := dm.RedisClient.Close(); err != nil {
@@ -128,13 +562,25 @@ func (dm *DatabaseManager) Close() error {
 		}
 	}
 
+	// Flush buffered trips before closing MongoDB
+	if dm.TripWriter != nil {
+		dm.TripWriter.Close(dm.ctx)
+	}
+
 	// Close MongoDB connection
-	if dm.MongoClient != nil {
-		if err := dm.MongoClient.Disconnect(dm.ctx); err != nil {
+	if current := dm.collections(); current != nil {
+		if err := current.Client.Disconnect(dm.ctx); err != nil {
 			errs = append(errs, fmt.Errorf("failed to close MongoDB: %w", err))
 		}
 	}
 
+	// Close trip sinks
+	for _, sink := range dm.Sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close trip sink: %w", err))
+		}
+	}
+
 	// Return combined errors if any
 	if len(errs) > 0 {
 		return fmt.Errorf("errors during close: %v", errs)
@@ -143,20 +589,21 @@ func (dm *DatabaseManager) Close() error {
 	return nil
 }
 
-// IsHealthy checks if all database connections are healthy
-func (dm *DatabaseManager) IsHealthy() map[string]bool {
+// IsHealthy checks if all database connections are healthy, pinging them
+// under ctx
+func (dm *DatabaseManager) IsHealthy(ctx context.Context) map[string]bool {
 	health := make(map[string]bool)
 
 	// Check Redis
-	_, err := dm.RedisClient.Ping(dm.ctx).Result()
+	_, err := dm.RedisClient.Ping(ctx).Result()
 	health["redis"] = err == nil
 
 	// Check MongoDB
-	err = dm.MongoClient.Ping(dm.ctx, nil)
+	err = dm.collections().Client.Ping(ctx, nil)
 	health["mongodb"] = err == nil
 
 	// Check MQTT
 	health["mqtt"] = dm.MQTTClient != nil && dm.MQTTClient.IsConnected()
 
 	return health
-} 
\ No newline at end of file
+}
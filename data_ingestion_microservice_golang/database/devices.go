@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LoadDeviceKeys returns every device key of the device registry collection
+func (dm *DatabaseManager) LoadDeviceKeys(ctx context.Context) ([]types.DeviceKey, error) {
+	cursor, err := dm.collections().Devices.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device keys: %w", err)
+	}
+	var keys []types.DeviceKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode device keys: %w", err)
+	}
+	return keys, nil
+}
+
+// LoadDeviceKeys returns every device key stored as JSON in the devices
+// bucket
+func (s *BoltTripStore) LoadDeviceKeys(ctx context.Context) ([]types.DeviceKey, error) {
+	var keys []types.DeviceKey
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltDevicesBucket).ForEach(func(id, data []byte) error {
+			var key types.DeviceKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				return fmt.Errorf("device %s: %w", id, err)
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device keys: %w", err)
+	}
+	return keys, nil
+}
+
+// SaveDeviceKey stores a device key under its device ID
+func (s *BoltTripStore) SaveDeviceKey(key types.DeviceKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal device key %s: %w", key.DeviceID, err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltDevicesBucket).Put([]byte(key.DeviceID), data)
+	})
+}
@@ -0,0 +1,385 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// redisGlobEscaper escapes the characters Redis treats as glob patterns
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// DeleteDriverData removes a driver's buffered routes, points in the routes
+// buffered per vehicle, live position, and stop ETAs from Redis, their trips,
+// finalization markers, raw routes, shifts, vehicle assignments, and daily
+// distances from MongoDB, and their raw traces from the S3 archive
+func (dm *DatabaseManager) DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error) {
+	report := types.DriverDeletionReport{DriverID: driverID}
+
+	var err error
+	if report.RouteBuffers, err = dm.deleteRouteBuffers(ctx, driverID); err != nil {
+		return report, err
+	}
+	if report.VehiclePoints, err = dm.scrubVehicleBuffers(ctx, driverID); err != nil {
+		return report, err
+	}
+	if report.LivePositions, err = dm.deleteLivePosition(ctx, driverID); err != nil {
+		return report, err
+	}
+	if report.ETAs, err = dm.deleteETAs(ctx, driverID); err != nil {
+		return report, err
+	}
+	if err := dm.deleteDistanceCounters(ctx, driverID); err != nil {
+		return report, err
+	}
+
+	// Collect the trip IDs first, the finalization markers only hold IDs
+	var ids []string
+	cursor, err := dm.collections().Trips.Find(ctx, bson.M{"driverId": driverID})
+	if err != nil {
+		return report, fmt.Errorf("failed to find trips of driver %s: %w", driverID, err)
+	}
+	var trips []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &trips); err != nil {
+		return report, fmt.Errorf("failed to read trips of driver %s: %w", driverID, err)
+	}
+	for _, trip := range trips {
+		ids = append(ids, trip.ID)
+	}
+
+	deleted, err := dm.collections().Trips.DeleteMany(ctx, bson.M{"driverId": driverID})
+	if err != nil {
+		return report, fmt.Errorf("failed to delete trips of driver %s: %w", driverID, err)
+	}
+	report.Trips = deleted.DeletedCount
+
+	if len(ids) > 0 {
+		if _, err := dm.collections().FinalizedTrips.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return report, fmt.Errorf("failed to delete finalization markers of driver %s: %w", driverID, err)
+		}
+	}
+
+	deleted, err = dm.collections().RawRoutes.DeleteMany(ctx, bson.M{"driverId": driverID})
+	if err != nil {
+		return report, fmt.Errorf("failed to delete raw routes of driver %s: %w", driverID, err)
+	}
+	report.RawRoutes = deleted.DeletedCount
+
+	deleted, err = dm.collections().Shifts.DeleteMany(ctx, bson.M{"driverId": driverID})
+	if err != nil {
+		return report, fmt.Errorf("failed to delete shifts of driver %s: %w", driverID, err)
+	}
+	report.Shifts = deleted.DeletedCount
+
+	deleted, err = dm.collections().Assignments.DeleteMany(ctx, bson.M{"driverId": driverID})
+	if err != nil {
+		return report, fmt.Errorf("failed to delete vehicle assignments of driver %s: %w", driverID, err)
+	}
+	report.Assignments = deleted.DeletedCount
+
+	deleted, err = dm.collections().Odometer.DeleteMany(ctx, bson.M{"driverId": driverID})
+	if err != nil {
+		return report, fmt.Errorf("failed to delete daily distances of driver %s: %w", driverID, err)
+	}
+	report.DailyDistances = deleted.DeletedCount
+
+	if dm.Archiver != nil {
+		if report.ArchivedTraces, err = dm.Archiver.DeleteDriverArchives(ctx, driverID); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// deleteRouteBuffers deletes every route stream buffered for a driver, and
+// the timestamps appended to them
+func (dm *DatabaseManager) deleteRouteBuffers(ctx context.Context, driverID string) (int64, error) {
+	pattern := RouteKey(redisGlobEscaper.Replace(driverID), "*")
+	deleted, err := dm.deleteKeys(ctx, pattern)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete route buffers of driver %s: %w", driverID, err)
+	}
+	if _, err := dm.deleteKeys(ctx, seenKey(pattern)); err != nil {
+		return deleted, fmt.Errorf("failed to delete appended timestamps of driver %s: %w", driverID, err)
+	}
+	return deleted, nil
+}
+
+// scrubVehicleBuffers removes a driver's points from the routes buffered per
+// vehicle, which hold the points of every driver of the vehicle, and returns
+// how many were removed
+func (dm *DatabaseManager) scrubVehicleBuffers(ctx context.Context, driverID string) (int64, error) {
+	var scrubbed int64
+	iter := dm.RedisClient.ScanType(ctx, 0, RouteKeyPrefix+vehicleKeyMarker+"*", 100, "stream").Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		entries, err := dm.RedisClient.XRange(ctx, key, "-", "+").Result()
+		if err != nil {
+			return scrubbed, fmt.Errorf("failed to read route buffer %s: %w", key, err)
+		}
+
+		var ids []string
+		for _, entry := range entries {
+			if point, err := decodePoint(entry); err == nil && point.DriverID == driverID {
+				ids = append(ids, entry.ID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		n, err := dm.RedisClient.XDel(ctx, key, ids...).Result()
+		if err != nil {
+			return scrubbed, fmt.Errorf("failed to remove points of driver %s from %s: %w", driverID, key, err)
+		}
+		scrubbed += n
+	}
+	if err := iter.Err(); err != nil {
+		return scrubbed, fmt.Errorf("failed to scan vehicle route buffers: %w", err)
+	}
+	return scrubbed, nil
+}
+
+// deleteETAs removes a driver's stop ETAs from the hash of every route and
+// returns how many were removed
+func (dm *DatabaseManager) deleteETAs(ctx context.Context, driverID string) (int64, error) {
+	var deleted int64
+	pattern := redisGlobEscaper.Replace(dm.redisConfig.ETAKeyPrefix) + "*"
+	iter := dm.RedisClient.ScanType(ctx, 0, pattern, 100, "hash").Iterator()
+	for iter.Next(ctx) {
+		n, err := dm.RedisClient.HDel(ctx, iter.Val(), driverID).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete stop ETAs of driver %s from %s: %w", driverID, iter.Val(), err)
+		}
+		deleted += n
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, fmt.Errorf("failed to scan stop ETAs: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteKeys deletes every key matching a pattern and returns how many
+func (dm *DatabaseManager) deleteKeys(ctx context.Context, pattern string) (int64, error) {
+	var deleted int64
+	iter := dm.RedisClient.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := dm.RedisClient.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", iter.Val(), err)
+		}
+		deleted += n
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, fmt.Errorf("failed to scan %s: %w", pattern, err)
+	}
+	return deleted, nil
+}
+
+// deleteLivePosition removes a driver from the live geo set, its route's
+// driver set, and deletes its live hash
+func (dm *DatabaseManager) deleteLivePosition(ctx context.Context, driverID string) (int64, error) {
+	hashKey := LivePositionKey(driverID)
+
+	routeID, err := dm.RedisClient.HGet(ctx, hashKey, "currentRouteId").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to read live position of driver %s: %w", driverID, err)
+	}
+
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.ZRem(ctx, dm.redisConfig.LivePositionsKey, driverID)
+	if routeID != "" {
+		pipe.SRem(ctx, LiveRouteKey(routeID), driverID)
+	}
+	del := pipe.Del(ctx, hashKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to delete live position of driver %s: %w", driverID, err)
+	}
+	return del.Val(), nil
+}
+
+// DeleteDriverArchives deletes every raw trace archived for a driver
+func (a *S3Archiver) DeleteDriverArchives(ctx context.Context, driverID string) (int64, error) {
+	prefix := fmt.Sprintf("%s/%s/", a.tenant, driverID)
+
+	var keys []string
+	for object := range a.client.ListObjects(ctx, a.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return 0, fmt.Errorf("failed to list raw traces of driver %s: %w", driverID, object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+
+	objects := make(chan minio.ObjectInfo, len(keys))
+	for _, key := range keys {
+		objects <- minio.ObjectInfo{Key: key}
+	}
+	close(objects)
+
+	deleted := int64(len(keys))
+	var removeErr error
+	for result := range a.client.RemoveObjects(ctx, a.bucket, objects, minio.RemoveObjectsOptions{}) {
+		deleted--
+		removeErr = result.Err
+	}
+	if removeErr != nil {
+		return deleted, fmt.Errorf("failed to delete raw traces of driver %s: %w", driverID, removeErr)
+	}
+	return deleted, nil
+}
+
+// DeleteDriverData removes a driver's trips, finalization markers, raw
+// routes, shifts, vehicle assignments, and daily distances in one transaction
+func (s *BoltTripStore) DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error) {
+	report := types.DriverDeletionReport{DriverID: driverID}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		trips := tx.Bucket(boltTripsBucket)
+
+		var ids [][]byte
+		err := trips.ForEach(func(k, v []byte) error {
+			var trip types.StoredTrip
+			if err := bson.Unmarshal(v, &trip); err != nil {
+				return fmt.Errorf("failed to decode trip %s: %w", k, err)
+			}
+			if trip.DriverID == driverID {
+				ids = append(ids, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Keys are deleted after the scan since a bucket must not be
+		// modified while iterating over it
+		raw := tx.Bucket(boltRawRoutesBucket)
+		for _, id := range ids {
+			if raw.Get(id) != nil {
+				report.RawRoutes++
+			}
+			if err := trips.Delete(id); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltFinalizedBucket).Delete(id); err != nil {
+				return err
+			}
+			if err := raw.Delete(id); err != nil {
+				return err
+			}
+		}
+		report.Trips = int64(len(ids))
+
+		shifts := tx.Bucket(boltShiftsBucket)
+		var shiftIDs [][]byte
+		err = forEachDriverShift(shifts, driverID, func(shift types.Shift, data []byte) {
+			shiftIDs = append(shiftIDs, []byte(shift.ID))
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range shiftIDs {
+			if err := shifts.Delete(id); err != nil {
+				return err
+			}
+		}
+		report.Shifts = int64(len(shiftIDs))
+
+		assignments := tx.Bucket(boltAssignmentsBucket)
+		var assignmentIDs [][]byte
+		err = forEachAssignment(assignments, func(assignment types.VehicleAssignment) {
+			if assignment.DriverID == driverID {
+				assignmentIDs = append(assignmentIDs, []byte(assignment.ID))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range assignmentIDs {
+			if err := assignments.Delete(id); err != nil {
+				return err
+			}
+		}
+		report.Assignments = int64(len(assignmentIDs))
+
+		distances := tx.Bucket(boltOdometerBucket)
+		var distanceIDs [][]byte
+		err = forEachDailyDistance(distances, func(distance types.DailyDistance) {
+			if distance.DriverID == driverID {
+				distanceIDs = append(distanceIDs, []byte(distance.ID))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range distanceIDs {
+			if err := distances.Delete(id); err != nil {
+				return err
+			}
+		}
+		report.DailyDistances = int64(len(distanceIDs))
+		return nil
+	})
+	if err != nil {
+		return types.DriverDeletionReport{DriverID: driverID}, fmt.Errorf("failed to delete trips of driver %s: %w", driverID, err)
+	}
+	return report, nil
+}
+
+// DeleteDriverData removes a driver's buffered routes, points in the routes
+// buffered per vehicle, live position, stop ETAs, and distance counters
+func (m *MemoryBuffer) DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := types.DriverDeletionReport{DriverID: driverID}
+	prefix := RouteKey(driverID, "")
+	for key := range m.routes {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.routes, key)
+			report.RouteBuffers++
+		}
+	}
+	for key, route := range m.routes {
+		if !strings.HasPrefix(key, RouteKeyPrefix+vehicleKeyMarker) {
+			continue
+		}
+		kept := route.points[:0]
+		for _, point := range route.points {
+			if point.Point.DriverID == driverID {
+				report.VehiclePoints++
+				continue
+			}
+			kept = append(kept, point)
+		}
+		route.points = kept
+	}
+	if _, ok := m.live[driverID]; ok {
+		delete(m.live, driverID)
+		report.LivePositions++
+	}
+	for routeID, etas := range m.etas {
+		if _, ok := etas[driverID]; ok {
+			delete(etas, driverID)
+			report.ETAs++
+		}
+		if len(etas) == 0 {
+			delete(m.etas, routeID)
+		}
+	}
+	field := odometerFields(driverID, "")[0]
+	for _, counters := range m.odometer {
+		delete(counters, field)
+	}
+	return report, nil
+}
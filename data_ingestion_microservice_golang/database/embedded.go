@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+)
+
+// Storage modes selecting the service backends
+const (
+	StorageModeExternal = "external"
+	StorageModeEmbedded = "embedded"
+)
+
+// NewEmbeddedBackends creates backends that keep route buffers in memory and
+// trips in a local BoltDB file, so the pipeline runs without Redis or
+// MongoDB. Device messages are still received over MQTT.
+func NewEmbeddedBackends(ctx context.Context, config types.Config) (Backends, error) {
+	store, err := NewBoltTripStore(config.Storage.EmbeddedPath, config.MongoDB.TripSchemaVersion)
+	if err != nil {
+		return Backends{}, err
+	}
+
+	broker := &DatabaseManager{ctx: ctx}
+	if err := broker.setupMQTT(config.MQTT, config.Startup.MQTT); err != nil {
+		store.Close()
+		return Backends{}, fmt.Errorf("failed to setup MQTT: %w", err)
+	}
+
+	buffer := NewMemoryBuffer(config.Redis)
+	backends := Backends{
+		Buffer:        buffer,
+		Trips:         store,
+		TripQueries:   store,
+		Finalizations: buffer,
+		Live:          buffer,
+		ETAs:          buffer,
+		PlannedRoutes: store,
+		Geofences:     store,
+		Devices:       store,
+		Shifts:        store,
+		Assignments:   store,
+		Counters:      buffer,
+		Odometer:      store,
+		Heatmap:       store,
+		Settings:      store,
+		Erasers:       []DriverDataEraser{store, buffer},
+		Audit:         store,
+		DeadLetters:   store,
+		Broker:        broker,
+		Health: func(ctx context.Context) map[string]bool {
+			return map[string]bool{
+				"memory": true,
+				"boltdb": true,
+				"mqtt":   broker.MQTTClient.IsConnected(),
+			}
+		},
+		Close: func() error {
+			if broker.MQTTClient.IsConnected() {
+				broker.MQTTClient.Disconnect(250)
+			}
+			return store.Close()
+		},
+	}
+	if config.Dedup.Enabled {
+		history := NewMemoryMessageHistory(config.Dedup)
+		backends.History = history
+		backends.Erasers = append(backends.Erasers, history)
+	}
+	return backends, nil
+}
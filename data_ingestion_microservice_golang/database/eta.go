@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// ETAKey returns the Redis hash key holding the stop ETAs of the vehicles
+// on a route, one field per driver
+func (dm *DatabaseManager) ETAKey(routeID string) string {
+	return dm.redisConfig.ETAKeyPrefix + routeID
+}
+
+// SaveETA records the stop ETAs of a vehicle and extends the life of its
+// route's hash by ttl. Readers skip vehicles not updated within the TTL,
+// since the hash lives as long as any vehicle on the route updates it.
+func (dm *DatabaseManager) SaveETA(ctx context.Context, eta types.VehicleETA, ttl time.Duration) error {
+	payload, err := json.Marshal(eta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stop ETAs: %w", err)
+	}
+
+	key := dm.ETAKey(eta.CurrentRouteID)
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key, eta.DriverID, payload)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store stop ETAs: %w", err)
+	}
+	return nil
+}
+
+// RemoveETA forgets the stop ETAs of a vehicle on a route
+func (dm *DatabaseManager) RemoveETA(ctx context.Context, routeID, driverID string) error {
+	if err := dm.RedisClient.HDel(ctx, dm.ETAKey(routeID), driverID).Err(); err != nil {
+		return fmt.Errorf("failed to remove stop ETAs: %w", err)
+	}
+	return nil
+}
+
+// RouteETAs returns the stop ETAs of every vehicle on a route, ordered by
+// driver ID
+func (dm *DatabaseManager) RouteETAs(ctx context.Context, routeID string) ([]types.VehicleETA, error) {
+	fields, err := dm.RedisClient.HGetAll(ctx, dm.ETAKey(routeID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stop ETAs of route %s: %w", routeID, err)
+	}
+
+	etas := make([]types.VehicleETA, 0, len(fields))
+	for driverID, payload := range fields {
+		var eta types.VehicleETA
+		if err := json.Unmarshal([]byte(payload), &eta); err != nil {
+			return nil, fmt.Errorf("failed to decode stop ETAs of driver %s: %w", driverID, err)
+		}
+		etas = append(etas, eta)
+	}
+	sort.Slice(etas, func(i, j int) bool {
+		return etas[i].DriverID < etas[j].DriverID
+	})
+	return etas, nil
+}
@@ -0,0 +1,16 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// LoadFeatureFlags returns the feature flags stored in the Redis hash, one
+// field per flag, e.g. HSET feature_flags acme:live_republish false
+func (dm *DatabaseManager) LoadFeatureFlags(ctx context.Context) (map[string]string, error) {
+	flags, err := dm.RedisClient.HGetAll(ctx, dm.redisConfig.FeatureFlagsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature flags: %w", err)
+	}
+	return flags, nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FleetRouteKeyPrefix is the prefix of the keys marking routes with a
+// started trip in the fleet event stream
+const FleetRouteKeyPrefix = "fleet_route:"
+
+// FleetRouteKey returns the Redis key marking a route buffer's trip as started
+func FleetRouteKey(key string) string {
+	return FleetRouteKeyPrefix + key
+}
+
+// PublishFleetEvent announces a fleet event to every instance
+func (dm *DatabaseManager) PublishFleetEvent(ctx context.Context, event types.FleetEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fleet event: %w", err)
+	}
+
+	channel := dm.redisConfig.EventsChannel
+	if err := dm.RedisClient.Publish(ctx, channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish fleet event to %s: %w", channel, err)
+	}
+	return nil
+}
+
+// WatchFleetEvents calls handler with every fleet event announced by any
+// instance, until ctx is done
+func (dm *DatabaseManager) WatchFleetEvents(ctx context.Context, handler func(types.FleetEvent)) error {
+	channel := dm.redisConfig.EventsChannel
+	pubsub := dm.RedisClient.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var event types.FleetEvent
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				slog.WarnContext(ctx, "Skipping malformed fleet event", "channel", channel, "error", err)
+				continue
+			}
+			handler(event)
+		}
+	}
+}
+
+// TrackFleetRoute marks the route's trip as started for idleAfter and
+// reports whether it was not started yet, so the instance that takes over a
+// driver does not announce the trip a second time
+func (dm *DatabaseManager) TrackFleetRoute(ctx context.Context, key string, idleAfter time.Duration) (bool, error) {
+	err := dm.RedisClient.SetArgs(ctx, FleetRouteKey(key), time.Now().UnixMilli(), redis.SetArgs{TTL: idleAfter, Get: true}).Err()
+	if errors.Is(err, redis.Nil) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to track fleet route %s: %w", key, err)
+	}
+	return false, nil
+}
+
+// ForgetFleetRoute clears the started mark of a finished route
+func (dm *DatabaseManager) ForgetFleetRoute(ctx context.Context, key string) error {
+	if err := dm.RedisClient.Del(ctx, FleetRouteKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to forget fleet route %s: %w", key, err)
+	}
+	return nil
+}
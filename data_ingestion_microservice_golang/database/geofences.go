@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LoadGeofences returns every geofence of the geofence collection
+func (dm *DatabaseManager) LoadGeofences(ctx context.Context) ([]types.Geofence, error) {
+	cursor, err := dm.collections().Geofences.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load geofences: %w", err)
+	}
+	var geofences []types.Geofence
+	if err := cursor.All(ctx, &geofences); err != nil {
+		return nil, fmt.Errorf("failed to decode geofences: %w", err)
+	}
+	return geofences, nil
+}
+
+// LoadGeofences returns every geofence stored as JSON in the geofences bucket
+func (s *BoltTripStore) LoadGeofences(ctx context.Context) ([]types.Geofence, error) {
+	var geofences []types.Geofence
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltGeofencesBucket).ForEach(func(id, data []byte) error {
+			var geofence types.Geofence
+			if err := json.Unmarshal(data, &geofence); err != nil {
+				return fmt.Errorf("geofence %s: %w", id, err)
+			}
+			geofences = append(geofences, geofence)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load geofences: %w", err)
+	}
+	return geofences, nil
+}
+
+// SaveGeofence stores a geofence under its ID
+func (s *BoltTripStore) SaveGeofence(geofence types.Geofence) error {
+	data, err := json.Marshal(geofence)
+	if err != nil {
+		return fmt.Errorf("failed to marshal geofence %s: %w", geofence.ID, err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltGeofencesBucket).Put([]byte(geofence.ID), data)
+	})
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HeatmapCellID identifies the count of a cell in a day or week
+func HeatmapCellID(period, start, geohash string) string {
+	return period + ":" + start + ":" + geohash
+}
+
+// SaveHeatmapCells replaces the cells of a day or week with a new count.
+// Cells are upserted by ID before the cells no longer counted are deleted,
+// so the period is never empty: a failure in between leaves stale cells
+// that the next save removes.
+func (dm *DatabaseManager) SaveHeatmapCells(ctx context.Context, period, start string, cells []types.HeatmapCell) error {
+	ids := make([]string, len(cells))
+	if len(cells) > 0 {
+		models := make([]mongo.WriteModel, len(cells))
+		for i, cell := range cells {
+			ids[i] = cell.ID
+			models[i] = mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": cell.ID}).
+				SetReplacement(cell).
+				SetUpsert(true)
+		}
+		if _, err := dm.collections().Heatmap.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save %s heatmap of %s: %w", period, start, err)
+		}
+	}
+
+	stale := bson.M{"period": period, "start": start, "_id": bson.M{"$nin": ids}}
+	if _, err := dm.collections().Heatmap.DeleteMany(ctx, stale); err != nil {
+		return fmt.Errorf("failed to remove stale cells of %s heatmap of %s: %w", period, start, err)
+	}
+	return nil
+}
+
+// FindHeatmapCells returns the cells counted in a day or week
+func (dm *DatabaseManager) FindHeatmapCells(ctx context.Context, period, start string) ([]types.HeatmapCell, error) {
+	cursor, err := dm.collections().Heatmap.Find(ctx, bson.M{"period": period, "start": start})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s heatmap of %s: %w", period, start, err)
+	}
+	cells := []types.HeatmapCell{}
+	if err := cursor.All(ctx, &cells); err != nil {
+		return nil, fmt.Errorf("failed to decode %s heatmap of %s: %w", period, start, err)
+	}
+	return cells, nil
+}
+
+// SaveHeatmapCells replaces the cells of a day or week with a new count in
+// one transaction
+func (s *BoltTripStore) SaveHeatmapCells(ctx context.Context, period, start string, cells []types.HeatmapCell) error {
+	prefix := []byte(HeatmapCellID(period, start, ""))
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltHeatmapBucket)
+
+		// Keys are deleted after the scan since a bucket must not be
+		// modified while iterating over it
+		var stale [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		for _, cell := range cells {
+			data, err := json.Marshal(cell)
+			if err != nil {
+				return fmt.Errorf("failed to marshal heatmap cell %s: %w", cell.ID, err)
+			}
+			if err := bucket.Put([]byte(cell.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save %s heatmap of %s: %w", period, start, err)
+	}
+	return nil
+}
+
+// FindHeatmapCells returns the cells counted in a day or week
+func (s *BoltTripStore) FindHeatmapCells(ctx context.Context, period, start string) ([]types.HeatmapCell, error) {
+	prefix := []byte(HeatmapCellID(period, start, ""))
+	cells := []types.HeatmapCell{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(boltHeatmapBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var cell types.HeatmapCell
+			if err := json.Unmarshal(v, &cell); err != nil {
+				return fmt.Errorf("heatmap cell %s: %w", k, err)
+			}
+			// The ID, period, and start are not part of the JSON encoding
+			cell.ID, cell.Period, cell.Start = string(k), period, start
+			cells = append(cells, cell)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s heatmap of %s: %w", period, start, err)
+	}
+	return cells, nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// idleAggregateStage sums the idling of the trips of a $group stage
+func idleAggregateStage(key interface{}) bson.M {
+	return bson.M{"$group": bson.M{
+		"_id":             key,
+		"trips":           bson.M{"$sum": 1},
+		"idlePeriods":     bson.M{"$sum": bson.M{"$size": bson.M{"$ifNull": bson.A{"$idle.periods", bson.A{}}}}},
+		"idleSeconds":     bson.M{"$sum": "$idle.idleSeconds"},
+		"durationSeconds": bson.M{"$sum": "$stats.durationSeconds"},
+	}}
+}
+
+// idleRow is an idle $group result
+type idleRow struct {
+	Key             string  `bson:"_id"`
+	Trips           int     `bson:"trips"`
+	IdlePeriods     int     `bson:"idlePeriods"`
+	IdleSeconds     float64 `bson:"idleSeconds"`
+	DurationSeconds float64 `bson:"durationSeconds"`
+}
+
+// aggregate converts an idle $group result to an idle aggregate
+func (row idleRow) aggregate() types.IdleAggregate {
+	return idleAggregate(row.Key, row.Trips, row.IdlePeriods, row.IdleSeconds, row.DurationSeconds)
+}
+
+// idleAggregate builds an idle aggregate from the sums of a group
+func idleAggregate(key string, trips, periods int, idleSeconds, durationSeconds float64) types.IdleAggregate {
+	aggregate := types.IdleAggregate{
+		Key:         key,
+		Trips:       trips,
+		IdlePeriods: periods,
+		IdleMinutes: idleSeconds / 60,
+	}
+	if durationSeconds > 0 {
+		aggregate.IdlePercent = idleSeconds / durationSeconds * 100
+	}
+	return aggregate
+}
+
+// AggregateIdle sums the idle time of the trips checked for idling per group
+// and overall in a single aggregation pipeline
+func (r *TripReader) AggregateIdle(ctx context.Context, query types.TripStatsQuery) (types.IdleReport, error) {
+	key, ok := statsGroupKeys[query.GroupBy]
+	if !ok {
+		return types.IdleReport{}, fmt.Errorf("unknown stats grouping %q", query.GroupBy)
+	}
+
+	match := bson.M{"idle": bson.M{"$exists": true}}
+	if timestamp := timestampRange(query.From, query.To); len(timestamp) > 0 {
+		match["timestamp"] = timestamp
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$facet": bson.M{
+			"groups": bson.A{idleAggregateStage(key), bson.M{"$sort": bson.M{"_id": 1}}},
+			"totals": bson.A{idleAggregateStage(nil)},
+		}},
+	}
+
+	cursor, err := r.trips().Aggregate(ctx, pipeline)
+	if err != nil {
+		return types.IdleReport{}, fmt.Errorf("failed to aggregate idle time: %w", err)
+	}
+
+	var results []struct {
+		Groups []idleRow `bson:"groups"`
+		Totals []idleRow `bson:"totals"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return types.IdleReport{}, fmt.Errorf("failed to decode idle aggregates: %w", err)
+	}
+
+	report := types.IdleReport{GroupBy: query.GroupBy, Groups: []types.IdleAggregate{}}
+	if len(results) == 0 {
+		return report, nil
+	}
+	for _, row := range results[0].Groups {
+		report.Groups = append(report.Groups, row.aggregate())
+	}
+	if len(results[0].Totals) > 0 {
+		report.Totals = results[0].Totals[0].aggregate()
+		report.Totals.Key = ""
+	}
+	return report, nil
+}
+
+// AggregateIdle sums the idle time of the trips checked for idling per group
+// and overall by scanning every trip
+func (s *BoltTripStore) AggregateIdle(ctx context.Context, query types.TripStatsQuery) (types.IdleReport, error) {
+	if !ValidStatsGroupBy(query.GroupBy) {
+		return types.IdleReport{}, fmt.Errorf("unknown stats grouping %q", query.GroupBy)
+	}
+
+	groups := make(map[string]*idleAccumulator)
+	var totals idleAccumulator
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltTripsBucket).ForEach(func(k, v []byte) error {
+			var trip types.StoredTrip
+			if err := bson.Unmarshal(v, &trip); err != nil {
+				return fmt.Errorf("failed to decode trip %s: %w", k, err)
+			}
+			if trip.Idle == nil || !matchesTripQuery(trip, types.TripQuery{From: query.From, To: query.To}, nil) {
+				return nil
+			}
+
+			key := statsGroupKey(trip, query.GroupBy)
+			if groups[key] == nil {
+				groups[key] = &idleAccumulator{}
+			}
+			groups[key].add(trip)
+			totals.add(trip)
+			return nil
+		})
+	})
+	if err != nil {
+		return types.IdleReport{}, fmt.Errorf("failed to aggregate idle time: %w", err)
+	}
+
+	report := types.IdleReport{
+		GroupBy: query.GroupBy,
+		Groups:  make([]types.IdleAggregate, 0, len(groups)),
+		Totals:  totals.aggregate(""),
+	}
+	for key, group := range groups {
+		report.Groups = append(report.Groups, group.aggregate(key))
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	return report, nil
+}
+
+// idleAccumulator sums the idling of the trips of a group
+type idleAccumulator struct {
+	trips           int
+	periods         int
+	idleSeconds     float64
+	durationSeconds float64
+}
+
+func (a *idleAccumulator) add(trip types.StoredTrip) {
+	a.trips++
+	a.periods += len(trip.Idle.Periods)
+	a.idleSeconds += trip.Idle.IdleSeconds
+	a.durationSeconds += trip.Stats.DurationSeconds
+}
+
+func (a *idleAccumulator) aggregate(key string) types.IdleAggregate {
+	return idleAggregate(key, a.trips, a.periods, a.idleSeconds, a.durationSeconds)
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_AggregateIdle(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	period := types.IdlePeriod{DurationSeconds: 300}
+	// 2022-01-01 and 2022-01-02 UTC; trip d was stored before idle analysis
+	trips := []types.Trip{
+		{ID: "a", DriverID: "driver_001", CurrentRouteID: "route_1", Timestamp: 1640995200000,
+			Stats: types.TripStats{DurationSeconds: 1800}, Idle: &types.TripIdle{IdleSeconds: 600, Periods: []types.IdlePeriod{period, period}}},
+		{ID: "b", DriverID: "driver_002", CurrentRouteID: "route_1", Timestamp: 1641000000000,
+			Stats: types.TripStats{DurationSeconds: 1200}, Idle: &types.TripIdle{Periods: []types.IdlePeriod{}}},
+		{ID: "c", DriverID: "driver_001", CurrentRouteID: "route_2", Timestamp: 1641081600000,
+			Stats: types.TripStats{DurationSeconds: 3000}, Idle: &types.TripIdle{IdleSeconds: 300, Periods: []types.IdlePeriod{period}}},
+		{ID: "d", DriverID: "driver_001", CurrentRouteID: "route_2", Timestamp: 1641081700000,
+			Stats: types.TripStats{DurationSeconds: 6000}},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	report, err := store.AggregateIdle(ctx, types.TripStatsQuery{GroupBy: StatsGroupByDriver})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []types.IdleAggregate{
+		{Key: "driver_001", Trips: 2, IdlePeriods: 3, IdleMinutes: 15, IdlePercent: 18.75},
+		{Key: "driver_002", Trips: 1},
+	}
+	if len(report.Groups) != len(want) || report.Groups[0] != want[0] || report.Groups[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, report.Groups)
+	}
+	if totals := report.Totals; totals.Trips != 3 || totals.IdleMinutes != 15 || totals.IdlePercent != 15 {
+		t.Errorf("Expected the totals of the analyzed trips, got %+v", totals)
+	}
+
+	report, err = store.AggregateIdle(ctx, types.TripStatsQuery{GroupBy: StatsGroupByDay, To: 1641081600000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Key != "2022-01-01" || report.Groups[0].IdlePeriods != 2 || report.Groups[0].IdlePercent != 20 {
+		t.Errorf("Expected the first day only, got %+v", report.Groups)
+	}
+}
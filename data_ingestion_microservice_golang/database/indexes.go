@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ttlIndexName is the name of the retention index on createdAt
+const ttlIndexName = "createdAt_ttl"
+
+// indexOptionsConflictCode is returned when an index exists with other options
+const indexOptionsConflictCode = 85
+
+// tripIndexes lists the indexes maintained on the trips collection
+func tripIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "driverId", Value: 1}},
+			Options: options.Index().SetName("driverId_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "currentRouteId", Value: 1}},
+			Options: options.Index().SetName("currentRouteId_1"),
+		},
+		{
+			Keys:    bson.D{{Key: "timestamp", Value: -1}},
+			Options: options.Index().SetName("timestamp_-1"),
+		},
+		{
+			Keys:    bson.D{{Key: "driverId", Value: 1}, {Key: "timestamp", Value: -1}},
+			Options: options.Index().SetName("driverId_1_timestamp_-1"),
+		},
+		{
+			Keys:    bson.D{{Key: "vehicleId", Value: 1}, {Key: "timestamp", Value: -1}},
+			Options: options.Index().SetName("vehicleId_1_timestamp_-1").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: GeoRouteField, Value: "2dsphere"}},
+			Options: options.Index().SetName(GeoRouteField + "_2dsphere"),
+		},
+	}
+}
+
+// EnsureIndexes creates the indexes required by the trips, audit log,
+// shifts, and vehicle assignment collections and, when a retention period is
+// configured, TTL indexes that expire trips, raw routes, and finalization
+// markers. Creating an index that already exists with the same specification
+// is a no-op, so this is safe to run on every startup.
+func (dm *DatabaseManager) EnsureIndexes(ctx context.Context, retentionDays int) error {
+	names, err := dm.collections().Trips.Indexes().CreateMany(ctx, tripIndexes())
+	if err != nil {
+		return fmt.Errorf("failed to create indexes on %s: %w", dm.collections().Trips.Name(), err)
+	}
+
+	slog.InfoContext(ctx, "Ensured MongoDB indexes", "collection", dm.collections().Trips.Name(), "indexes", names)
+
+	// The audit log is read newest first
+	_, err = dm.collections().AuditLog.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "timestamp", Value: -1}},
+		Options: options.Index().SetName("timestamp_-1"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes on %s: %w", dm.collections().AuditLog.Name(), err)
+	}
+
+	// Shifts are looked up per driver by start time
+	_, err = dm.collections().Shifts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "driverId", Value: 1}, {Key: "startedAt", Value: -1}},
+		Options: options.Index().SetName("driverId_1_startedAt_-1"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes on %s: %w", dm.collections().Shifts.Name(), err)
+	}
+
+	// Assignments are looked up per driver or per vehicle by start time
+	_, err = dm.collections().Assignments.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "driverId", Value: 1}, {Key: "startedAt", Value: -1}},
+			Options: options.Index().SetName("driverId_1_startedAt_-1"),
+		},
+		{
+			Keys:    bson.D{{Key: "vehicleId", Value: 1}, {Key: "startedAt", Value: -1}},
+			Options: options.Index().SetName("vehicleId_1_startedAt_-1"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes on %s: %w", dm.collections().Assignments.Name(), err)
+	}
+
+	// Heatmaps are read and replaced one day or week at a time
+	_, err = dm.collections().Heatmap.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "period", Value: 1}, {Key: "start", Value: 1}},
+		Options: options.Index().SetName("period_1_start_1"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create indexes on %s: %w", dm.collections().Heatmap.Name(), err)
+	}
+
+	if retentionDays <= 0 {
+		return nil
+	}
+
+	expireAfter := int32(retentionDays * 24 * 60 * 60)
+	for _, collection := range []*mongo.Collection{dm.collections().Trips, dm.collections().RawRoutes, dm.collections().FinalizedTrips} {
+		if err := ensureTTLIndex(ctx, collection, expireAfter); err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "Ensured trip retention", "days", retentionDays)
+	return nil
+}
+
+// ensureTTLIndex creates the createdAt TTL index, updating its expiry in
+// place when the index already exists with a different retention period
+func ensureTTLIndex(ctx context.Context, collection *mongo.Collection, expireAfterSeconds int32) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(expireAfterSeconds),
+	})
+	if err == nil {
+		return nil
+	}
+
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != indexOptionsConflictCode {
+		return fmt.Errorf("failed to create TTL index on %s: %w", collection.Name(), err)
+	}
+
+	err = collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: ttlIndexName},
+			{Key: "expireAfterSeconds", Value: expireAfterSeconds},
+		}},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update TTL index on %s: %w", collection.Name(), err)
+	}
+
+	return nil
+}
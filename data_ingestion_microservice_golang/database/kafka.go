@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaWriter is the part of kafka.Writer the sink uses
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// KafkaSink publishes every finalized trip document as JSON to a Kafka topic
+type KafkaSink struct {
+	writer            kafkaWriter
+	tripSchemaVersion int
+}
+
+// NewKafkaSink creates a producer for the configured trip topic
+func NewKafkaSink(config types.KafkaConfig, tripSchemaVersion int) *KafkaSink {
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers...),
+			Topic:        config.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: config.BatchTimeout,
+		},
+		tripSchemaVersion: tripSchemaVersion,
+	}
+}
+
+// WriteTrip publishes the trip document, encoded like the MongoDB document,
+// keyed by driver ID so each driver's trips stay ordered within a partition.
+// It waits for all in-sync replicas to acknowledge the message.
+func (s *KafkaSink) WriteTrip(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	doc, err := EncodeTrip(trip, s.tripSchemaVersion)
+	if err != nil {
+		return fmt.Errorf("failed to encode trip: %w", err)
+	}
+
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trip %s: %w", trip.ID, err)
+	}
+
+	err = s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(trip.DriverID),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "tripId", Value: []byte(trip.ID)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish trip %s to Kafka: %w", trip.ID, err)
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the producer
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
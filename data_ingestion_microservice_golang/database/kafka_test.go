@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafkaWriter records published messages, failing with err when set
+type fakeKafkaWriter struct {
+	messages []kafka.Message
+	err      error
+	closed   bool
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, messages...)
+	return nil
+}
+
+func (w *fakeKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestKafkaSink_PublishesTripDocument(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	sink := &KafkaSink{writer: writer, tripSchemaVersion: 1}
+
+	trip := types.Trip{ID: "trip-1", DriverID: "driver_001", CurrentRouteID: "route_123"}
+	if err := sink.WriteTrip(context.Background(), trip, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(writer.messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(writer.messages))
+	}
+	message := writer.messages[0]
+	if string(message.Key) != "driver_001" {
+		t.Errorf("Expected the message to be keyed by driver, got %q", message.Key)
+	}
+	if len(message.Headers) != 1 || message.Headers[0].Key != "tripId" || string(message.Headers[0].Value) != "trip-1" {
+		t.Errorf("Expected a tripId header, got %v", message.Headers)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(message.Value, &doc); err != nil {
+		t.Fatalf("Expected a JSON document, got %v", err)
+	}
+	if doc["_id"] != "trip-1" || doc["driverId"] != "driver_001" || doc["schemaVersion"] != float64(1) {
+		t.Errorf("Expected the trip document, got %v", doc)
+	}
+
+	sink.Close()
+	if !writer.closed {
+		t.Error("Expected Close to close the producer")
+	}
+}
+
+func TestKafkaSink_Errors(t *testing.T) {
+	writer := &fakeKafkaWriter{err: errors.New("leader not available")}
+	sink := &KafkaSink{writer: writer, tripSchemaVersion: 1}
+
+	err := sink.WriteTrip(context.Background(), types.Trip{ID: "trip-1"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "trip-1") || !errors.Is(err, writer.err) {
+		t.Errorf("Expected the publish error of trip-1, got %v", err)
+	}
+
+	sink = &KafkaSink{writer: &fakeKafkaWriter{}, tripSchemaVersion: 99}
+	if err := sink.WriteTrip(context.Background(), types.Trip{ID: "trip-1"}, nil); err == nil {
+		t.Error("Expected an unsupported schema version to be rejected")
+	}
+}
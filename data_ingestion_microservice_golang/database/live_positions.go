@@ -0,0 +1,297 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LivePositionKeyPrefix prefixes the hashes holding each driver's latest state
+const LivePositionKeyPrefix = "live:"
+
+// LiveRouteKeyPrefix prefixes the sets of drivers currently on each route
+const LiveRouteKeyPrefix = "live_route:"
+
+// fleetReadBatchSize bounds the number of hashes read per pipeline when
+// loading the whole fleet
+const fleetReadBatchSize = 1000
+
+// minHeadingDistanceMeters is how far a driver must move before its heading
+// is recomputed, so GPS jitter while stationary does not spin it around
+const minHeadingDistanceMeters = 1.0
+
+// LivePositionKey returns the Redis hash key holding a driver's latest state
+func LivePositionKey(driverID string) string {
+	return LivePositionKeyPrefix + driverID
+}
+
+// LiveRouteKey returns the Redis set key holding the drivers on a route
+func LiveRouteKey(routeID string) string {
+	return LiveRouteKeyPrefix + routeID
+}
+
+// UpdateLivePosition records a driver's latest position in the live geo set
+// and its status, heading, timestamp, and telemetry in the driver's hash, and
+// keeps the driver in the set of its current route until the route is
+// finished. Messages older than the stored position are ignored.
+func (dm *DatabaseManager) UpdateLivePosition(ctx context.Context, busMsg types.BusMessage) error {
+	hashKey := LivePositionKey(busMsg.DriverID)
+
+	previous, err := dm.RedisClient.HMGet(ctx, hashKey, "latitude", "longitude", "heading", "timestamp", "currentRouteId").Result()
+	if err != nil {
+		return fmt.Errorf("failed to read live position: %w", err)
+	}
+
+	if ts, ok := parseHashUint(previous[3]); ok && busMsg.Timestamp < ts {
+		return nil
+	}
+
+	heading, _ := parseHashFloat(previous[2])
+	lat, latOK := parseHashFloat(previous[0])
+	lon, lonOK := parseHashFloat(previous[1])
+	if latOK && lonOK {
+		heading = nextHeading(types.Location{Latitude: lat, Longitude: lon}, heading, busMsg.DriverLocation)
+	}
+	if busMsg.Heading != nil {
+		heading = *busMsg.Heading
+	}
+
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.GeoAdd(ctx, dm.redisConfig.LivePositionsKey, &redis.GeoLocation{
+		Name:      busMsg.DriverID,
+		Longitude: busMsg.DriverLocation.Longitude,
+		Latitude:  busMsg.DriverLocation.Latitude,
+	})
+	pipe.HSet(ctx, hashKey, map[string]interface{}{
+		"vehicleId":      busMsg.VehicleID,
+		"currentRouteId": busMsg.CurrentRouteID,
+		"status":         busMsg.Status,
+		"latitude":       busMsg.DriverLocation.Latitude,
+		"longitude":      busMsg.DriverLocation.Longitude,
+		"heading":        heading,
+		"timestamp":      busMsg.Timestamp,
+		"updatedAt":      time.Now().UnixMilli(),
+	})
+	// Readings are only shown with the position they were sent with
+	if busMsg.Telemetry.IsZero() {
+		pipe.HDel(ctx, hashKey, "telemetry")
+	} else {
+		telemetry, err := json.Marshal(busMsg.Telemetry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal telemetry: %w", err)
+		}
+		pipe.HSet(ctx, hashKey, "telemetry", telemetry)
+	}
+	if previousRoute, ok := previous[4].(string); ok && previousRoute != busMsg.CurrentRouteID {
+		pipe.SRem(ctx, LiveRouteKey(previousRoute), busMsg.DriverID)
+	}
+	if busMsg.Status == "finished" {
+		pipe.SRem(ctx, LiveRouteKey(busMsg.CurrentRouteID), busMsg.DriverID)
+	} else {
+		pipe.SAdd(ctx, LiveRouteKey(busMsg.CurrentRouteID), busMsg.DriverID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update live position: %w", err)
+	}
+	return nil
+}
+
+// PublishLiveLocation fans a processed location out to the route's live
+// Redis channel
+func (dm *DatabaseManager) PublishLiveLocation(ctx context.Context, busMsg types.BusMessage) error {
+	payload, err := json.Marshal(busMsg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal live location: %w", err)
+	}
+
+	channel := dm.redisConfig.LiveChannelPrefix + busMsg.CurrentRouteID
+	if err := dm.RedisClient.Publish(ctx, channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish live location to %s: %w", channel, err)
+	}
+	return nil
+}
+
+// LivePosition returns a driver's latest known state, or nil if none is stored
+func (dm *DatabaseManager) LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error) {
+	fields, err := dm.RedisClient.HGetAll(ctx, LivePositionKey(driverID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read live position: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	position := decodeLivePosition(driverID, fields)
+	return &position, nil
+}
+
+// RoutePositions returns the latest state of every driver currently on a
+// route, ordered by driver ID
+func (dm *DatabaseManager) RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error) {
+	drivers, err := dm.RedisClient.SMembers(ctx, LiveRouteKey(routeID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drivers on route %s: %w", routeID, err)
+	}
+	sort.Strings(drivers)
+
+	pipe := dm.RedisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(drivers))
+	for i, driverID := range drivers {
+		cmds[i] = pipe.HGetAll(ctx, LivePositionKey(driverID))
+	}
+	if len(drivers) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read live positions: %w", err)
+		}
+	}
+
+	positions := make([]types.LivePosition, 0, len(drivers))
+	for i, driverID := range drivers {
+		fields := cmds[i].Val()
+		// Skip drivers whose hash was removed or moved on concurrently
+		if len(fields) == 0 || fields["currentRouteId"] != routeID {
+			continue
+		}
+		positions = append(positions, decodeLivePosition(driverID, fields))
+	}
+	return positions, nil
+}
+
+// FleetPositions returns the latest state of every driver in the live geo
+// set that has not finished its route, ordered by driver ID
+func (dm *DatabaseManager) FleetPositions(ctx context.Context) ([]types.LivePosition, error) {
+	drivers, err := dm.RedisClient.ZRange(ctx, dm.redisConfig.LivePositionsKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read live fleet: %w", err)
+	}
+	sort.Strings(drivers)
+
+	positions := make([]types.LivePosition, 0, len(drivers))
+	for start := 0; start < len(drivers); start += fleetReadBatchSize {
+		batch := drivers[start:min(start+fleetReadBatchSize, len(drivers))]
+
+		pipe := dm.RedisClient.Pipeline()
+		cmds := make([]*redis.MapStringStringCmd, len(batch))
+		for i, driverID := range batch {
+			cmds[i] = pipe.HGetAll(ctx, LivePositionKey(driverID))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read live positions: %w", err)
+		}
+
+		for i, driverID := range batch {
+			fields := cmds[i].Val()
+			// Skip drivers whose hash was removed concurrently
+			if len(fields) == 0 || fields["status"] == "finished" {
+				continue
+			}
+			positions = append(positions, decodeLivePosition(driverID, fields))
+		}
+	}
+	return positions, nil
+}
+
+// NearbyVehicles returns the count drivers in the live geo set nearest to a
+// location within radiusMeters, nearest first, finished or not
+func (dm *DatabaseManager) NearbyVehicles(ctx context.Context, location types.Location, radiusMeters float64, count int) ([]types.LivePosition, error) {
+	matches, err := dm.RedisClient.GeoSearchLocation(ctx, dm.redisConfig.LivePositionsKey, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Longitude:  location.Longitude,
+			Latitude:   location.Latitude,
+			Radius:     radiusMeters,
+			RadiusUnit: "m",
+			Sort:       "ASC",
+			Count:      count,
+		},
+		WithDist: true,
+	}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to search live positions: %w", err)
+	}
+
+	pipe := dm.RedisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(matches))
+	for i, match := range matches {
+		cmds[i] = pipe.HGetAll(ctx, LivePositionKey(match.Name))
+	}
+	if len(matches) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read live positions: %w", err)
+		}
+	}
+
+	positions := make([]types.LivePosition, 0, len(matches))
+	for i, match := range matches {
+		fields := cmds[i].Val()
+		// Skip drivers whose hash was removed concurrently
+		if len(fields) == 0 {
+			continue
+		}
+		position := decodeLivePosition(match.Name, fields)
+		// The distance is in meters since the search radius unit is meters
+		position.DistanceMeters = match.Dist
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+// decodeLivePosition builds a live position from a driver's hash fields
+func decodeLivePosition(driverID string, fields map[string]string) types.LivePosition {
+	position := types.LivePosition{
+		DriverID:       driverID,
+		VehicleID:      fields["vehicleId"],
+		CurrentRouteID: fields["currentRouteId"],
+		Status:         fields["status"],
+	}
+	position.Location.Latitude, _ = strconv.ParseFloat(fields["latitude"], 64)
+	position.Location.Longitude, _ = strconv.ParseFloat(fields["longitude"], 64)
+	position.Heading, _ = strconv.ParseFloat(fields["heading"], 64)
+	position.Timestamp, _ = strconv.ParseUint(fields["timestamp"], 10, 64)
+	position.UpdatedAt, _ = strconv.ParseInt(fields["updatedAt"], 10, 64)
+	if raw := fields["telemetry"]; raw != "" {
+		var telemetry types.Telemetry
+		if json.Unmarshal([]byte(raw), &telemetry) == nil {
+			position.Telemetry = &telemetry
+		}
+	}
+	return position
+}
+
+// nextHeading returns the bearing from the previous to the current location,
+// keeping the previous heading when the driver barely moved
+func nextHeading(previous types.Location, heading float64, current types.Location) float64 {
+	if algorithm.HaversineDistance(previous, current) < minHeadingDistanceMeters {
+		return heading
+	}
+	return algorithm.InitialBearing(previous, current)
+}
+
+// parseHashFloat parses a float returned by HMGET, reporting whether it was set
+func parseHashFloat(value interface{}) (float64, bool) {
+	raw, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	parsed, err := strconv.ParseFloat(raw, 64)
+	return parsed, err == nil
+}
+
+// parseHashUint parses an unsigned integer returned by HMGET, reporting
+// whether it was set
+func parseHashUint(value interface{}) (uint64, bool) {
+	raw, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(raw, 10, 64)
+	return parsed, err == nil
+}
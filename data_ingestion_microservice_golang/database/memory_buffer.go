@@ -0,0 +1,436 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/tracing"
+	"data-ingestion-microservice/types"
+)
+
+// memoryQueueSize bounds the in-memory finalization queue
+const memoryQueueSize = 1024
+
+// memoryRoute is a route buffered in memory
+type memoryRoute struct {
+	points  []BufferedPoint
+	seen    map[string]bool
+	touched time.Time
+}
+
+// MemoryBuffer is an in-process replacement for the Redis route buffer,
+// finalization queue, and live positions used by the embedded storage mode.
+// Buffered points are lost when the process exits.
+type MemoryBuffer struct {
+	config types.RedisConfig
+
+	mu     sync.Mutex
+	nextID uint64
+	routes map[string]*memoryRoute
+	live   map[string]types.LivePosition
+	etas   map[string]map[string]types.VehicleETA
+
+	// odometer holds the distance counters of every day, and
+	// odometerTouched when each day was last counted on
+	odometer        map[string]map[string]float64
+	odometerTouched map[string]time.Time
+
+	queue chan FinalizationEntry
+}
+
+// NewMemoryBuffer creates an empty in-memory route buffer
+func NewMemoryBuffer(config types.RedisConfig) *MemoryBuffer {
+	return &MemoryBuffer{
+		config: config,
+		routes: make(map[string]*memoryRoute),
+		live:   make(map[string]types.LivePosition),
+		etas:   make(map[string]map[string]types.VehicleETA),
+		queue:  make(chan FinalizationEntry, memoryQueueSize),
+
+		odometer:        make(map[string]map[string]float64),
+		odometerTouched: make(map[string]time.Time),
+	}
+}
+
+// AppendPoint adds a point to a route buffer, unless a point with the same
+// timestamp is already buffered for the route
+func (m *MemoryBuffer) AppendPoint(ctx context.Context, key string, point types.TrackPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, ok := m.routes[key]
+	if !ok {
+		route = &memoryRoute{seen: make(map[string]bool)}
+		m.routes[key] = route
+	}
+	route.touched = time.Now()
+
+	// Store redelivered points once, like the Redis buffer
+	if point.Timestamp != 0 {
+		member := seenMember(point)
+		if route.seen[member] {
+			metrics.DuplicatePoints.Add(1)
+			return nil
+		}
+		route.seen[member] = true
+	}
+
+	m.nextID++
+	route.points = append(route.points, BufferedPoint{ID: memoryEntryID(m.nextID), Point: point})
+
+	// Downsample runaway buffers like the Redis point writer
+	if limit := routePointCap(m.config); limit > 0 && len(route.points) > limit {
+		route.downsample(limit / 2)
+		metrics.RouteBuffersDownsampled.Add(1)
+	}
+	return nil
+}
+
+// downsample thins the points down to about target, keeping the first and
+// last points and the passenger counts
+func (r *memoryRoute) downsample(target int) {
+	ids := make([]string, len(r.points))
+	counts := make([]bool, len(r.points))
+	for i, point := range r.points {
+		ids[i] = point.ID
+		counts[i] = point.Point.Passengers != nil
+	}
+
+	drop := make(map[string]bool)
+	for _, id := range downsampleIDs(ids, counts, target) {
+		drop[id] = true
+	}
+	kept := r.points[:0]
+	for _, point := range r.points {
+		if !drop[point.ID] {
+			kept = append(kept, point)
+		}
+	}
+	r.points = kept
+}
+
+// FlushPoints is a no-op since points are appended immediately
+func (m *MemoryBuffer) FlushPoints(ctx context.Context) {}
+
+// ReadPoints returns every point buffered for a route
+func (m *MemoryBuffer) ReadPoints(ctx context.Context, key string) ([]BufferedPoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, ok := m.routes[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]BufferedPoint(nil), route.points...), nil
+}
+
+// ClearPoints removes the points up to and including lastID and returns the
+// number of points left in the buffer
+func (m *MemoryBuffer) ClearPoints(ctx context.Context, key, lastID string) (int64, error) {
+	last, err := parseMemoryEntryID(lastID)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, ok := m.routes[key]
+	if !ok {
+		return 0, nil
+	}
+
+	remaining := route.points[:0]
+	for _, point := range route.points {
+		id, _ := parseMemoryEntryID(point.ID)
+		if id > last {
+			remaining = append(remaining, point)
+		}
+	}
+	route.points = remaining
+
+	if len(remaining) == 0 {
+		delete(m.routes, key)
+	}
+	return int64(len(remaining)), nil
+}
+
+// IdleRoutes returns the route buffers that have not received points for
+// idleFor
+func (m *MemoryBuffer) IdleRoutes(ctx context.Context, idleFor time.Duration) ([]IdleRoute, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var idle []IdleRoute
+	for key, route := range m.routes {
+		if len(route.points) == 0 || time.Since(route.touched) < idleFor {
+			continue
+		}
+		last := route.points[len(route.points)-1]
+		idle = append(idle, IdleRoute{Key: key, LastID: last.ID, Last: last.Point, LastSeen: route.touched})
+	}
+	return idle, nil
+}
+
+// WatchExpiredRoutes drops route buffers that have not received points for
+// the route TTL and calls the handler for each of them. It blocks until the
+// context is cancelled.
+func (m *MemoryBuffer) WatchExpiredRoutes(ctx context.Context, handler func(key string)) error {
+	ticker := time.NewTicker(max(m.config.RouteTTL/10, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			for _, key := range m.expireRoutes(time.Now()) {
+				handler(key)
+			}
+		}
+	}
+}
+
+// expireRoutes removes the routes idle for longer than the route TTL
+func (m *MemoryBuffer) expireRoutes(now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []string
+	for key, route := range m.routes {
+		if now.Sub(route.touched) >= m.config.RouteTTL {
+			delete(m.routes, key)
+			expired = append(expired, key)
+		}
+	}
+	return expired
+}
+
+// EnqueueFinalization queues a finished route for the local consumer
+func (m *MemoryBuffer) EnqueueFinalization(ctx context.Context, busMsg types.BusMessage) error {
+	m.mu.Lock()
+	m.nextID++
+	id := memoryEntryID(m.nextID)
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- FinalizationEntry{ID: id, BusMsg: busMsg, Trace: tracing.Inject(ctx)}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReadFinalizations waits up to block for queued finalizations
+func (m *MemoryBuffer) ReadFinalizations(ctx context.Context, consumer string, count int64, block time.Duration) ([]FinalizationEntry, error) {
+	timer := time.NewTimer(block)
+	defer timer.Stop()
+
+	var entries []FinalizationEntry
+	select {
+	case entry := <-m.queue:
+		entries = append(entries, entry)
+	case <-timer.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for int64(len(entries)) < count {
+		select {
+		case entry := <-m.queue:
+			entries = append(entries, entry)
+		default:
+			return entries, nil
+		}
+	}
+	return entries, nil
+}
+
+// ClaimStaleFinalizations returns nothing since a single process owns every job
+func (m *MemoryBuffer) ClaimStaleFinalizations(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]FinalizationEntry, error) {
+	return nil, nil
+}
+
+// AckFinalization is a no-op since jobs leave the queue when they are read
+func (m *MemoryBuffer) AckFinalization(ctx context.Context, id string) error {
+	return nil
+}
+
+// FinalizationBacklog returns the number of queued finalizations
+func (m *MemoryBuffer) FinalizationBacklog(ctx context.Context) (int64, error) {
+	return int64(len(m.queue)), nil
+}
+
+// UpdateLivePosition records a driver's latest position, ignoring messages
+// older than the stored one
+func (m *MemoryBuffer) UpdateLivePosition(ctx context.Context, busMsg types.BusMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, ok := m.live[busMsg.DriverID]
+	if ok && busMsg.Timestamp < previous.Timestamp {
+		return nil
+	}
+
+	var heading float64
+	if ok {
+		heading = nextHeading(previous.Location, previous.Heading, busMsg.DriverLocation)
+	}
+	if busMsg.Heading != nil {
+		heading = *busMsg.Heading
+	}
+	var telemetry *types.Telemetry
+	if !busMsg.Telemetry.IsZero() {
+		telemetry = &busMsg.Telemetry
+	}
+
+	m.live[busMsg.DriverID] = types.LivePosition{
+		DriverID:       busMsg.DriverID,
+		VehicleID:      busMsg.VehicleID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Status:         busMsg.Status,
+		Location:       busMsg.DriverLocation,
+		Heading:        heading,
+		Timestamp:      busMsg.Timestamp,
+		UpdatedAt:      time.Now().UnixMilli(),
+		Telemetry:      telemetry,
+	}
+	return nil
+}
+
+// PublishLiveLocation is a no-op since there are no external subscribers
+func (m *MemoryBuffer) PublishLiveLocation(ctx context.Context, busMsg types.BusMessage) error {
+	return nil
+}
+
+// LivePosition returns a driver's latest known state, or nil if none is stored
+func (m *MemoryBuffer) LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	position, ok := m.live[driverID]
+	if !ok {
+		return nil, nil
+	}
+	return &position, nil
+}
+
+// RoutePositions returns the latest state of every driver currently on a
+// route, ordered by driver ID
+func (m *MemoryBuffer) RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := []types.LivePosition{}
+	for _, position := range m.live {
+		if position.CurrentRouteID == routeID && position.Status != "finished" {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].DriverID < positions[j].DriverID
+	})
+	return positions, nil
+}
+
+// FleetPositions returns the latest state of every driver that has not
+// finished its route, ordered by driver ID
+func (m *MemoryBuffer) FleetPositions(ctx context.Context) ([]types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := []types.LivePosition{}
+	for _, position := range m.live {
+		if position.Status != "finished" {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].DriverID < positions[j].DriverID
+	})
+	return positions, nil
+}
+
+// NearbyVehicles returns the count drivers nearest to a location within
+// radiusMeters, nearest first, finished or not
+func (m *MemoryBuffer) NearbyVehicles(ctx context.Context, location types.Location, radiusMeters float64, count int) ([]types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := []types.LivePosition{}
+	for _, position := range m.live {
+		position.DistanceMeters = algorithm.HaversineDistance(location, position.Location)
+		if position.DistanceMeters <= radiusMeters {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].DistanceMeters < positions[j].DistanceMeters
+	})
+	return positions[:min(count, len(positions))], nil
+}
+
+// SaveETA records the stop ETAs of a vehicle. They are kept until removed,
+// and readers skip the ones older than the TTL.
+func (m *MemoryBuffer) SaveETA(ctx context.Context, eta types.VehicleETA, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	route, ok := m.etas[eta.CurrentRouteID]
+	if !ok {
+		route = make(map[string]types.VehicleETA)
+		m.etas[eta.CurrentRouteID] = route
+	}
+	route[eta.DriverID] = eta
+	return nil
+}
+
+// RemoveETA forgets the stop ETAs of a vehicle on a route
+func (m *MemoryBuffer) RemoveETA(ctx context.Context, routeID, driverID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.etas[routeID], driverID)
+	if len(m.etas[routeID]) == 0 {
+		delete(m.etas, routeID)
+	}
+	return nil
+}
+
+// RouteETAs returns the stop ETAs of every vehicle on a route, ordered by
+// driver ID
+func (m *MemoryBuffer) RouteETAs(ctx context.Context, routeID string) ([]types.VehicleETA, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	etas := []types.VehicleETA{}
+	for _, eta := range m.etas[routeID] {
+		etas = append(etas, eta)
+	}
+	sort.Slice(etas, func(i, j int) bool {
+		return etas[i].DriverID < etas[j].DriverID
+	})
+	return etas, nil
+}
+
+// memoryEntryID formats a sequence number like a Redis stream entry ID
+func memoryEntryID(seq uint64) string {
+	return fmt.Sprintf("%d-0", seq)
+}
+
+// parseMemoryEntryID returns the sequence number of an in-memory entry ID
+func parseMemoryEntryID(id string) (uint64, error) {
+	seq, err := strconv.ParseUint(strings.TrimSuffix(id, "-0"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid entry ID %q: %w", id, err)
+	}
+	return seq, nil
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestMemoryBuffer_ClearKeepsLaterPoints(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+	point := types.TrackPoint{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000}
+
+	buffer.AppendPoint(ctx, "route:driver-1:route-1", point)
+	point.Timestamp++
+	buffer.AppendPoint(ctx, "route:driver-1:route-1", point)
+
+	read, err := buffer.ReadPoints(ctx, "route:driver-1:route-1")
+	if err != nil || len(read) != 2 {
+		t.Fatalf("Expected 2 points, got %d (%v)", len(read), err)
+	}
+
+	point.Timestamp++
+	buffer.AppendPoint(ctx, "route:driver-1:route-1", point)
+
+	remaining, err := buffer.ClearPoints(ctx, "route:driver-1:route-1", read[len(read)-1].ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if remaining != 1 {
+		t.Errorf("Expected 1 remaining point, got %d", remaining)
+	}
+}
+
+func TestMemoryBuffer_SkipsDuplicatePoints(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+	point := types.TrackPoint{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000}
+	count := point
+	count.Passengers = &types.PassengerCount{Boardings: 2}
+
+	// A redelivered point, a redelivered count sharing its timestamp, and
+	// points without a timestamp
+	for _, p := range []types.TrackPoint{point, point, count, count, {}, {}} {
+		if err := buffer.AppendPoint(ctx, "route:driver-1:route-1", p); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	read, err := buffer.ReadPoints(ctx, "route:driver-1:route-1")
+	if err != nil || len(read) != 4 {
+		t.Errorf("Expected 4 points, got %d (%v)", len(read), err)
+	}
+}
+
+func TestMemoryBuffer_DownsampleKeepsPassengerCounts(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{StreamMaxLen: 20})
+
+	for i := range 200 {
+		point := types.TrackPoint{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000 + uint64(i)*1000}
+		if i%25 == 10 {
+			point.Passengers = &types.PassengerCount{Boardings: i}
+		}
+		if err := buffer.AppendPoint(ctx, "route:driver-1:route-1", point); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	read, err := buffer.ReadPoints(ctx, "route:driver-1:route-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(read) > 20 {
+		t.Errorf("Expected the buffer to be capped at 20 points, got %d", len(read))
+	}
+	if read[0].Point.Timestamp != 1640995200000 {
+		t.Errorf("Expected the first point to be kept, got %d", read[0].Point.Timestamp)
+	}
+	var counts []int
+	for _, point := range read {
+		if point.Point.Passengers != nil {
+			counts = append(counts, point.Point.Passengers.Boardings)
+		}
+	}
+	if len(counts) != 8 {
+		t.Errorf("Expected every passenger count to be kept, got %v", counts)
+	}
+}
+
+func TestMemoryBuffer_ExpireRoutes(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{RouteTTL: time.Minute})
+
+	buffer.AppendPoint(ctx, "route:driver-1:route-1", types.TrackPoint{})
+
+	if expired := buffer.expireRoutes(time.Now()); len(expired) != 0 {
+		t.Errorf("Expected no expired routes, got %v", expired)
+	}
+	if expired := buffer.expireRoutes(time.Now().Add(2 * time.Minute)); len(expired) != 1 {
+		t.Errorf("Expected 1 expired route, got %v", expired)
+	}
+}
+
+func TestMemoryBuffer_RoutePositions(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+
+	messages := []types.BusMessage{
+		{DriverID: "driver_002", CurrentRouteID: "route_1", Status: "in_route", Timestamp: 1000},
+		{DriverID: "driver_001", CurrentRouteID: "route_1", Status: "in_route", Timestamp: 1000},
+		{DriverID: "driver_003", CurrentRouteID: "route_1", Status: "finished", Timestamp: 1000},
+		{DriverID: "driver_004", CurrentRouteID: "route_2", Status: "in_route", Timestamp: 1000},
+	}
+	for _, message := range messages {
+		if err := buffer.UpdateLivePosition(ctx, message); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	positions, err := buffer.RoutePositions(ctx, "route_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(positions) != 2 || positions[0].DriverID != "driver_001" || positions[1].DriverID != "driver_002" {
+		t.Errorf("Expected driver_001 and driver_002, got %+v", positions)
+	}
+	if positions[0].UpdatedAt == 0 {
+		t.Errorf("Expected the update time to be recorded")
+	}
+
+	fleet, err := buffer.FleetPositions(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(fleet) != 3 || fleet[0].DriverID != "driver_001" || fleet[2].DriverID != "driver_004" {
+		t.Errorf("Expected every driver but the finished one, got %+v", fleet)
+	}
+}
+
+func TestMemoryBuffer_NearbyVehicles(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+
+	messages := []types.BusMessage{
+		{DriverID: "driver_far", CurrentRouteID: "route_1", Status: "in_route", Timestamp: 1000, DriverLocation: types.Location{Latitude: 6.3000, Longitude: -75.5812}},
+		{DriverID: "driver_near", CurrentRouteID: "route_1", Status: "in_route", Timestamp: 1000, DriverLocation: types.Location{Latitude: 6.2450, Longitude: -75.5812}},
+		{DriverID: "driver_mid", CurrentRouteID: "route_2", Status: "finished", Timestamp: 1000, DriverLocation: types.Location{Latitude: 6.2500, Longitude: -75.5812}},
+	}
+	for _, message := range messages {
+		if err := buffer.UpdateLivePosition(ctx, message); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	origin := types.Location{Latitude: 6.2442, Longitude: -75.5812}
+	positions, err := buffer.NearbyVehicles(ctx, origin, 10_000, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(positions) != 2 || positions[0].DriverID != "driver_near" || positions[1].DriverID != "driver_mid" {
+		t.Fatalf("Expected the 2 nearest drivers, got %+v", positions)
+	}
+	if positions[0].DistanceMeters < 80 || positions[0].DistanceMeters > 100 {
+		t.Errorf("Expected about 89m to the nearest driver, got %v", positions[0].DistanceMeters)
+	}
+
+	positions, err = buffer.NearbyVehicles(ctx, origin, 500, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(positions) != 1 || positions[0].DriverID != "driver_near" {
+		t.Errorf("Expected only the driver within 500m, got %+v", positions)
+	}
+}
+
+func TestMemoryBuffer_DeleteDriverData(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+
+	for _, key := range []string{RouteKey("driver_001", "route_1"), RouteKey("driver_001", "route_2"), RouteKey("driver_0010", "route_1")} {
+		if err := buffer.AppendPoint(ctx, key, types.TrackPoint{Timestamp: 1000}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := buffer.UpdateLivePosition(ctx, types.BusMessage{DriverID: "driver_001", CurrentRouteID: "route_2", Status: "in_route"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The vehicle changed hands, so its buffer holds both drivers' points
+	vehicleKey := VehicleRouteKey("bus_7", "route_1")
+	for i, driverID := range []string{"driver_001", "driver_001", "driver_002"} {
+		if err := buffer.AppendPoint(ctx, vehicleKey, types.TrackPoint{Timestamp: uint64(1000 + i), DriverID: driverID}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	for _, eta := range []types.VehicleETA{{DriverID: "driver_001", CurrentRouteID: "route_2"}, {DriverID: "driver_002", CurrentRouteID: "route_2"}} {
+		if err := buffer.SaveETA(ctx, eta, time.Minute); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	report, err := buffer.DeleteDriverData(ctx, "driver_001")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.RouteBuffers != 2 || report.VehiclePoints != 2 || report.LivePositions != 1 || report.ETAs != 1 {
+		t.Errorf("Expected 2 route buffers, 2 vehicle points, 1 live position, and 1 ETA to be deleted, got %+v", report)
+	}
+
+	if points, _ := buffer.ReadPoints(ctx, vehicleKey); len(points) != 1 || points[0].Point.DriverID != "driver_002" {
+		t.Errorf("Expected only the other driver's point to be kept in the vehicle buffer, got %+v", points)
+	}
+	if etas, _ := buffer.RouteETAs(ctx, "route_2"); len(etas) != 1 || etas[0].DriverID != "driver_002" {
+		t.Errorf("Expected only the other driver's ETAs to be kept, got %+v", etas)
+	}
+
+	if points, _ := buffer.ReadPoints(ctx, RouteKey("driver_0010", "route_1")); len(points) != 1 {
+		t.Errorf("Expected routes of other drivers to be kept, got %d points", len(points))
+	}
+	if position, _ := buffer.LivePosition(ctx, "driver_001"); position != nil {
+		t.Errorf("Expected the live position to be deleted, got %+v", position)
+	}
+}
+
+func TestMemoryBuffer_IdleRoutes(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+	key := RouteKey("driver_001", "route_123")
+	buffer.AppendPoint(ctx, key, types.TrackPoint{Timestamp: 1000})
+	buffer.AppendPoint(ctx, key, types.TrackPoint{Timestamp: 2000})
+
+	if idle, _ := buffer.IdleRoutes(ctx, time.Hour); len(idle) != 0 {
+		t.Errorf("Expected no idle routes, got %v", idle)
+	}
+	idle, err := buffer.IdleRoutes(ctx, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(idle) != 1 || idle[0].Key != key || idle[0].Last.Timestamp != 2000 {
+		t.Fatalf("Expected the route with its last point, got %v", idle)
+	}
+
+	driverID, routeID, ok := ParseRouteKey(idle[0].Key)
+	if !ok || driverID != "driver_001" || routeID != "route_123" {
+		t.Errorf("Expected driver_001 and route_123, got %q and %q", driverID, routeID)
+	}
+
+	vehicleKey := VehicleRouteKey("bus_7", "route_123")
+	if _, _, ok := ParseRouteKey(vehicleKey); ok {
+		t.Errorf("Expected %s not to parse as a driver route key", vehicleKey)
+	}
+	vehicleID, routeID, ok := ParseVehicleRouteKey(vehicleKey)
+	if !ok || vehicleID != "bus_7" || routeID != "route_123" {
+		t.Errorf("Expected bus_7 and route_123, got %q and %q", vehicleID, routeID)
+	}
+}
+
+func TestMemoryBuffer_DistanceCounters(t *testing.T) {
+	ctx := context.Background()
+	buffer := NewMemoryBuffer(types.RedisConfig{})
+
+	buffer.AddDistance(ctx, "2022-01-01", "driver-1", "bus-7", 100, time.Hour)
+	buffer.AddDistance(ctx, "2022-01-01", "driver-2", "bus-7", 50, time.Hour)
+	buffer.AddDistance(ctx, "2022-01-02", "driver-1", "", 10, time.Hour)
+
+	distances, err := buffer.DayDistances(ctx, "2022-01-01")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got := make(map[string]float64)
+	for _, distance := range distances {
+		got[distance.ID] = distance.DistanceMeters
+	}
+	want := map[string]float64{"driver:driver-1:2022-01-01": 100, "driver:driver-2:2022-01-01": 50, "vehicle:bus-7:2022-01-01": 150}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for id, meters := range want {
+		if got[id] != meters {
+			t.Errorf("Expected %v meters for %s, got %v", meters, id, got[id])
+		}
+	}
+
+	buffer.DeleteDriverData(ctx, "driver-1")
+	if distances, _ := buffer.DayDistances(ctx, "2022-01-02"); len(distances) != 0 {
+		t.Errorf("Expected the driver's counters to be deleted, got %+v", distances)
+	}
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MessageHistoryKeyPrefix prefixes the Redis sorted sets holding the latest
+// message hashes of every driver, scored by message timestamp
+const MessageHistoryKeyPrefix = "dedup:"
+
+// MessageHistoryKey returns the Redis sorted set of a driver's latest messages
+func MessageHistoryKey(driverID string) string {
+	return MessageHistoryKeyPrefix + driverID
+}
+
+// RedisMessageHistory keeps the latest message hashes of every driver in
+// Redis, so retransmissions are recognized by any instance
+type RedisMessageHistory struct {
+	client *redis.Client
+	config types.DedupConfig
+}
+
+// NewRedisMessageHistory creates a message history on a Redis client,
+// keeping at least one message per driver
+func NewRedisMessageHistory(client *redis.Client, config types.DedupConfig) *RedisMessageHistory {
+	config.History = max(config.History, 1)
+	return &RedisMessageHistory{client: client, config: config}
+}
+
+// LookupMessage reports whether a message hash is among the driver's latest
+// messages, and the newest timestamp recorded for the driver
+func (h *RedisMessageHistory) LookupMessage(ctx context.Context, driverID, hash string) (bool, uint64, error) {
+	key := MessageHistoryKey(driverID)
+
+	pipe := h.client.Pipeline()
+	score := pipe.ZScore(ctx, key, hash)
+	newest := pipe.ZRevRangeWithScores(ctx, key, 0, 0)
+	// Every command carries its own error; a missing hash is not one
+	_, _ = pipe.Exec(ctx)
+	if err := score.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return false, 0, fmt.Errorf("failed to read message history of driver %s: %w", driverID, err)
+	}
+	if err := newest.Err(); err != nil {
+		return false, 0, fmt.Errorf("failed to read message history of driver %s: %w", driverID, err)
+	}
+
+	var latest uint64
+	if entries := newest.Val(); len(entries) > 0 {
+		latest = uint64(entries[0].Score)
+	}
+	return score.Err() == nil, latest, nil
+}
+
+// RecordMessage adds a message hash to the driver's history, keeping the
+// configured number of latest messages and refreshing the history's expiry
+func (h *RedisMessageHistory) RecordMessage(ctx context.Context, driverID, hash string, timestamp uint64) error {
+	key := MessageHistoryKey(driverID)
+
+	pipe := h.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(timestamp), Member: hash})
+	pipe.ZRemRangeByRank(ctx, key, 0, int64(-h.config.History-1))
+	if h.config.TTL > 0 {
+		pipe.Expire(ctx, key, h.config.TTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record message of driver %s: %w", driverID, err)
+	}
+	return nil
+}
+
+// DeleteDriverData removes a driver's message history
+func (h *RedisMessageHistory) DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error) {
+	report := types.DriverDeletionReport{DriverID: driverID}
+	if err := h.client.Del(ctx, MessageHistoryKey(driverID)).Err(); err != nil {
+		return report, fmt.Errorf("failed to delete message history of driver %s: %w", driverID, err)
+	}
+	return report, nil
+}
+
+// memoryMessage is a message recorded in a driver's in-memory history
+type memoryMessage struct {
+	hash      string
+	timestamp uint64
+}
+
+// memoryDriverHistory is the in-memory message history of a driver
+type memoryDriverHistory struct {
+	messages []memoryMessage
+	touched  time.Time
+}
+
+// MemoryMessageHistory is an in-process replacement for the Redis message
+// history used by the embedded storage mode
+type MemoryMessageHistory struct {
+	config types.DedupConfig
+
+	mu      sync.Mutex
+	drivers map[string]*memoryDriverHistory
+}
+
+// NewMemoryMessageHistory creates an empty in-memory message history,
+// keeping at least one message per driver
+func NewMemoryMessageHistory(config types.DedupConfig) *MemoryMessageHistory {
+	config.History = max(config.History, 1)
+	return &MemoryMessageHistory{config: config, drivers: make(map[string]*memoryDriverHistory)}
+}
+
+// LookupMessage reports whether a message hash is among the driver's latest
+// messages, and the newest timestamp recorded for the driver
+func (h *MemoryMessageHistory) LookupMessage(ctx context.Context, driverID, hash string) (bool, uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	history := h.driver(driverID, time.Now())
+	if history == nil {
+		return false, 0, nil
+	}
+
+	var seen bool
+	var latest uint64
+	for _, message := range history.messages {
+		seen = seen || message.hash == hash
+		latest = max(latest, message.timestamp)
+	}
+	return seen, latest, nil
+}
+
+// RecordMessage adds a message hash to the driver's history, keeping the
+// configured number of latest messages
+func (h *MemoryMessageHistory) RecordMessage(ctx context.Context, driverID, hash string, timestamp uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	history := h.driver(driverID, now)
+	if history == nil {
+		history = &memoryDriverHistory{}
+		h.drivers[driverID] = history
+	}
+	history.touched = now
+
+	// A hash is recorded once, with its latest timestamp
+	for i, message := range history.messages {
+		if message.hash == hash {
+			history.messages = append(history.messages[:i], history.messages[i+1:]...)
+			break
+		}
+	}
+
+	// Keep messages ordered by timestamp and drop the oldest, like the
+	// Redis sorted set
+	i := len(history.messages)
+	for i > 0 && history.messages[i-1].timestamp > timestamp {
+		i--
+	}
+	history.messages = append(history.messages, memoryMessage{})
+	copy(history.messages[i+1:], history.messages[i:])
+	history.messages[i] = memoryMessage{hash: hash, timestamp: timestamp}
+	if extra := len(history.messages) - h.config.History; extra > 0 {
+		history.messages = history.messages[extra:]
+	}
+	return nil
+}
+
+// DeleteDriverData removes a driver's message history
+func (h *MemoryMessageHistory) DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.drivers, driverID)
+	return types.DriverDeletionReport{DriverID: driverID}, nil
+}
+
+// driver returns the history of a driver, dropping it once it is older than
+// the configured TTL
+func (h *MemoryMessageHistory) driver(driverID string, now time.Time) *memoryDriverHistory {
+	history, ok := h.drivers[driverID]
+	if !ok {
+		return nil
+	}
+	if h.config.TTL > 0 && now.Sub(history.touched) >= h.config.TTL {
+		delete(h.drivers, driverID)
+		return nil
+	}
+	return history
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestMemoryMessageHistory_KeepsLatestMessages(t *testing.T) {
+	ctx := context.Background()
+	history := NewMemoryMessageHistory(types.DedupConfig{History: 2, TTL: time.Minute})
+
+	// Recorded out of order, the oldest timestamp is dropped first
+	history.RecordMessage(ctx, "driver_001", "b", 2000)
+	history.RecordMessage(ctx, "driver_001", "a", 1000)
+	history.RecordMessage(ctx, "driver_001", "c", 3000)
+
+	tests := []struct {
+		hash string
+		seen bool
+	}{
+		{"a", false},
+		{"b", true},
+		{"c", true},
+	}
+	for _, tt := range tests {
+		seen, latest, err := history.LookupMessage(ctx, "driver_001", tt.hash)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if seen != tt.seen || latest != 3000 {
+			t.Errorf("Expected %q seen=%v with latest 3000, got %v with %d", tt.hash, tt.seen, seen, latest)
+		}
+	}
+
+	if seen, latest, _ := history.LookupMessage(ctx, "driver_002", "b"); seen || latest != 0 {
+		t.Errorf("Expected other drivers to have no history, got %v with %d", seen, latest)
+	}
+}
+
+func TestMemoryMessageHistory_Expires(t *testing.T) {
+	ctx := context.Background()
+	history := NewMemoryMessageHistory(types.DedupConfig{History: 2, TTL: time.Minute})
+	history.RecordMessage(ctx, "driver_001", "a", 1000)
+
+	history.drivers["driver_001"].touched = time.Now().Add(-2 * time.Minute)
+	if seen, _, _ := history.LookupMessage(ctx, "driver_001", "a"); seen {
+		t.Error("Expected the history to expire")
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttHeldLimit bounds the messages held for topics without a handler.
+// Messages beyond it stay unacknowledged and are redelivered by the broker
+// on the next reconnect.
+const mqttHeldLimit = 1000
+
+// mqttRouter hands the messages of every subscription to their handler. It
+// is installed on the client before it connects, because a persistent
+// session makes the broker deliver the messages it kept as soon as the
+// connection is up, before the service subscribes; those are held until
+// their topic's handler is registered instead of being dropped unacknowledged.
+type mqttRouter struct {
+	mu       sync.Mutex
+	handlers map[string]mqtt.MessageHandler
+	held     []mqtt.Message
+}
+
+// newMQTTRouter creates a router without handlers
+func newMQTTRouter() *mqttRouter {
+	return &mqttRouter{handlers: make(map[string]mqtt.MessageHandler)}
+}
+
+// route calls the handler of the message's topic, or holds the message
+// until one is registered
+func (r *mqttRouter) route(client mqtt.Client, msg mqtt.Message) {
+	r.mu.Lock()
+	handler := r.handler(msg.Topic())
+	if handler == nil {
+		if len(r.held) < mqttHeldLimit {
+			r.held = append(r.held, msg)
+		} else {
+			slog.Warn("Dropped MQTT message without a handler", "topic", msg.Topic(), "messageId", msg.MessageID())
+		}
+	}
+	r.mu.Unlock()
+
+	if handler != nil {
+		handler(client, msg)
+	}
+}
+
+// handle registers the handler of a topic filter and passes it the held
+// messages it matches, in the order they arrived
+func (r *mqttRouter) handle(client mqtt.Client, filter string, handler mqtt.MessageHandler) {
+	r.mu.Lock()
+	r.handlers[filter] = handler
+	var matched []mqtt.Message
+	kept := r.held[:0]
+	for _, msg := range r.held {
+		if TopicMatches(filter, msg.Topic()) {
+			matched = append(matched, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	r.held = kept
+	r.mu.Unlock()
+
+	for _, msg := range matched {
+		handler(client, msg)
+	}
+}
+
+// remove unregisters the handler of a topic filter
+func (r *mqttRouter) remove(filter string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.handlers, filter)
+}
+
+// filters returns the topic filters with a handler
+func (r *mqttRouter) filters() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	filters := make([]string, 0, len(r.handlers))
+	for filter := range r.handlers {
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// handler returns the handler of the first filter matching topic
+func (r *mqttRouter) handler(topic string) mqtt.MessageHandler {
+	for filter, handler := range r.handlers {
+		if TopicMatches(filter, topic) {
+			return handler
+		}
+	}
+	return nil
+}
+
+// TopicMatches reports whether an MQTT topic matches a topic filter. The
+// "$share/{group}/" prefix of a shared subscription is ignored.
+func TopicMatches(filter, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		if parts := strings.SplitN(filter, "/", 3); len(parts) == 3 {
+			filter = parts[2]
+		}
+	}
+
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(topicLevels):
+			return false
+		case level != "+" && level != topicLevels[i]:
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"slices"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// testMessage is an MQTT message on a topic
+type testMessage struct {
+	mqtt.Message
+	topic string
+	id    uint16
+}
+
+func (m testMessage) Topic() string     { return m.topic }
+func (m testMessage) MessageID() uint16 { return m.id }
+
+func TestMQTTRouter_HoldsMessagesUntilSubscribed(t *testing.T) {
+	router := newMQTTRouter()
+	var handled []uint16
+	record := func(client mqtt.Client, msg mqtt.Message) { handled = append(handled, msg.MessageID()) }
+
+	// The resumed session delivers before the service subscribes
+	router.route(nil, testMessage{topic: "drivers_location/driver-1", id: 1})
+	router.route(nil, testMessage{topic: "settings/runtime", id: 2})
+	router.route(nil, testMessage{topic: "drivers_location/driver-2", id: 3})
+
+	router.handle(nil, "drivers_location/+", record)
+	router.route(nil, testMessage{topic: "drivers_location/driver-1", id: 4})
+	if !slices.Equal(handled, []uint16{1, 3, 4}) {
+		t.Errorf("Expected the held messages first, got %v", handled)
+	}
+	if len(router.held) != 1 || router.held[0].MessageID() != 2 {
+		t.Errorf("Expected the other topic's message to stay held, got %v", router.held)
+	}
+
+	// Messages that arrive after unsubscribing are not handled
+	router.remove("drivers_location/+")
+	router.route(nil, testMessage{topic: "drivers_location/driver-1", id: 5})
+	if len(handled) != 3 {
+		t.Errorf("Expected no messages after removing the handler, got %v", handled)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"drivers_location/#", "drivers_location/driver-1/route-1", true},
+		{"drivers_location/+/route-1", "drivers_location/driver-1/route-1", true},
+		{"drivers_location/+", "drivers_location/driver-1/route-1", false},
+		{"drivers_location/driver-1", "drivers_location/driver-1", true},
+		{"drivers_location/driver-1/#", "drivers_location/driver-1", true},
+		{"drivers_location/driver-2", "drivers_location/driver-1", false},
+		{"$share/ingestion/drivers_location/+", "drivers_location/driver-1", true},
+	}
+	for _, test := range tests {
+		if got := TopicMatches(test.filter, test.topic); got != test.want {
+			t.Errorf("%s on %s: expected %v, got %v", test.filter, test.topic, test.want, got)
+		}
+	}
+}
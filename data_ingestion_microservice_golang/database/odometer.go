@@ -0,0 +1,270 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OdometerKeyPrefix prefixes the Redis hashes of the daily distance
+// counters, one field per driver and vehicle
+const OdometerKeyPrefix = "odometer:"
+
+// Prefixes of the counter fields of drivers and vehicles
+const (
+	odometerDriverField  = "driver:"
+	odometerVehicleField = "vehicle:"
+)
+
+// OdometerKey returns the Redis hash holding the distance counters of a day
+func OdometerKey(day string) string {
+	return OdometerKeyPrefix + day
+}
+
+// DailyDistanceID identifies the summary of a driver's or vehicle's day
+func DailyDistanceID(distance types.DailyDistance) string {
+	if distance.VehicleID != "" {
+		return odometerVehicleField + distance.VehicleID + ":" + distance.Day
+	}
+	return odometerDriverField + distance.DriverID + ":" + distance.Day
+}
+
+// odometerFields returns the counter fields a step of a driver, and of the
+// vehicle if any, adds to
+func odometerFields(driverID, vehicleID string) []string {
+	fields := []string{odometerDriverField + driverID}
+	if vehicleID != "" {
+		fields = append(fields, odometerVehicleField+vehicleID)
+	}
+	return fields
+}
+
+// dailyDistance returns the distance of a counter field on a day
+func dailyDistance(day, field string, meters float64) (types.DailyDistance, bool) {
+	distance := types.DailyDistance{Day: day, DistanceMeters: meters}
+	if id, ok := strings.CutPrefix(field, odometerVehicleField); ok {
+		distance.VehicleID = id
+	} else if id, ok := strings.CutPrefix(field, odometerDriverField); ok {
+		distance.DriverID = id
+	} else {
+		return distance, false
+	}
+	distance.ID = DailyDistanceID(distance)
+	return distance, true
+}
+
+// dailyDistanceMatches reports whether a summary is selected by a query
+func dailyDistanceMatches(distance types.DailyDistance, query types.OdometerQuery) bool {
+	return (query.DriverID == "" || distance.DriverID == query.DriverID) &&
+		(query.VehicleID == "" || distance.VehicleID == query.VehicleID) &&
+		(query.From == "" || distance.Day >= query.From) &&
+		(query.To == "" || distance.Day <= query.To)
+}
+
+// AddDistance adds a step of a driver, and of the vehicle if any, to their
+// counters of a day and keeps the day's counters for ttl after the last
+// step
+func (dm *DatabaseManager) AddDistance(ctx context.Context, day, driverID, vehicleID string, meters float64, ttl time.Duration) error {
+	key := OdometerKey(day)
+	pipe := dm.RedisClient.TxPipeline()
+	for _, field := range odometerFields(driverID, vehicleID) {
+		pipe.HIncrByFloat(ctx, key, field, meters)
+	}
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count distance of driver %s: %w", driverID, err)
+	}
+	return nil
+}
+
+// DayDistances returns the counters of every driver and vehicle on a day
+func (dm *DatabaseManager) DayDistances(ctx context.Context, day string) ([]types.DailyDistance, error) {
+	fields, err := dm.RedisClient.HGetAll(ctx, OdometerKey(day)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read distance counters of %s: %w", day, err)
+	}
+
+	distances := make([]types.DailyDistance, 0, len(fields))
+	for field, value := range fields {
+		meters, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid distance counter %s of %s: %w", field, day, err)
+		}
+		if distance, ok := dailyDistance(day, field, meters); ok {
+			distances = append(distances, distance)
+		}
+	}
+	return distances, nil
+}
+
+// deleteDistanceCounters removes a driver from the counters of every day
+func (dm *DatabaseManager) deleteDistanceCounters(ctx context.Context, driverID string) error {
+	field := odometerFields(driverID, "")[0]
+	iter := dm.RedisClient.Scan(ctx, 0, OdometerKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := dm.RedisClient.HDel(ctx, iter.Val(), field).Err(); err != nil {
+			return fmt.Errorf("failed to delete distance counters of driver %s: %w", driverID, err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan distance counters: %w", err)
+	}
+	return nil
+}
+
+// SaveDailyDistances stores the summaries rolled up from the counters,
+// replacing the distances saved earlier for the same days
+func (dm *DatabaseManager) SaveDailyDistances(ctx context.Context, distances []types.DailyDistance) error {
+	if len(distances) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(distances))
+	for _, distance := range distances {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": distance.ID}).
+			SetReplacement(distance).
+			SetUpsert(true))
+	}
+	if _, err := dm.collections().Odometer.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to save daily distances: %w", err)
+	}
+	return nil
+}
+
+// FindDailyDistances returns the daily distances of a driver or vehicle,
+// oldest first
+func (dm *DatabaseManager) FindDailyDistances(ctx context.Context, query types.OdometerQuery) ([]types.DailyDistance, error) {
+	filter := bson.M{}
+	if query.DriverID != "" {
+		filter["driverId"] = query.DriverID
+	}
+	if query.VehicleID != "" {
+		filter["vehicleId"] = query.VehicleID
+	}
+	days := bson.M{}
+	if query.From != "" {
+		days["$gte"] = query.From
+	}
+	if query.To != "" {
+		days["$lte"] = query.To
+	}
+	if len(days) > 0 {
+		filter["day"] = days
+	}
+
+	cursor, err := dm.collections().Odometer.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "day", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily distances: %w", err)
+	}
+	distances := []types.DailyDistance{}
+	if err := cursor.All(ctx, &distances); err != nil {
+		return nil, fmt.Errorf("failed to decode daily distances: %w", err)
+	}
+	return distances, nil
+}
+
+// AddDistance adds a step of a driver, and of the vehicle if any, to their
+// counters of a day. Days not counted on for ttl are forgotten.
+func (m *MemoryBuffer) AddDistance(ctx context.Context, day, driverID, vehicleID string, meters float64, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for counted, touched := range m.odometerTouched {
+		if now.Sub(touched) > ttl {
+			delete(m.odometer, counted)
+			delete(m.odometerTouched, counted)
+		}
+	}
+
+	counters, ok := m.odometer[day]
+	if !ok {
+		counters = make(map[string]float64)
+		m.odometer[day] = counters
+	}
+	for _, field := range odometerFields(driverID, vehicleID) {
+		counters[field] += meters
+	}
+	m.odometerTouched[day] = now
+	return nil
+}
+
+// DayDistances returns the counters of every driver and vehicle on a day
+func (m *MemoryBuffer) DayDistances(ctx context.Context, day string) ([]types.DailyDistance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	distances := make([]types.DailyDistance, 0, len(m.odometer[day]))
+	for field, meters := range m.odometer[day] {
+		if distance, ok := dailyDistance(day, field, meters); ok {
+			distances = append(distances, distance)
+		}
+	}
+	return distances, nil
+}
+
+// SaveDailyDistances stores the summaries rolled up from the counters,
+// replacing the distances saved earlier for the same days
+func (s *BoltTripStore) SaveDailyDistances(ctx context.Context, distances []types.DailyDistance) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltOdometerBucket)
+		for _, distance := range distances {
+			data, err := json.Marshal(distance)
+			if err != nil {
+				return fmt.Errorf("failed to marshal daily distance %s: %w", distance.ID, err)
+			}
+			if err := bucket.Put([]byte(distance.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save daily distances: %w", err)
+	}
+	return nil
+}
+
+// FindDailyDistances returns the daily distances of a driver or vehicle,
+// oldest first
+func (s *BoltTripStore) FindDailyDistances(ctx context.Context, query types.OdometerQuery) ([]types.DailyDistance, error) {
+	distances := []types.DailyDistance{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return forEachDailyDistance(tx.Bucket(boltOdometerBucket), func(distance types.DailyDistance) {
+			if dailyDistanceMatches(distance, query) {
+				distances = append(distances, distance)
+			}
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily distances: %w", err)
+	}
+
+	slices.SortFunc(distances, func(a, b types.DailyDistance) int { return strings.Compare(a.Day, b.Day) })
+	return distances, nil
+}
+
+// forEachDailyDistance calls fn with every stored daily distance
+func forEachDailyDistance(bucket *bbolt.Bucket, fn func(distance types.DailyDistance)) error {
+	return bucket.ForEach(func(id, data []byte) error {
+		var distance types.DailyDistance
+		if err := json.Unmarshal(data, &distance); err != nil {
+			return fmt.Errorf("daily distance %s: %w", id, err)
+		}
+		// The ID is not part of the JSON encoding
+		distance.ID = string(id)
+		fn(distance)
+		return nil
+	})
+}
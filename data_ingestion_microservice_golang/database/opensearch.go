@@ -0,0 +1,164 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// openSearchTimeout bounds a single OpenSearch HTTP request
+const openSearchTimeout = 10 * time.Second
+
+// openSearchTripMapping maps trip summary fields for free-text and geo search
+const openSearchTripMapping = `{
+	"mappings": {
+		"properties": {
+			"tripId": {"type": "keyword"},
+			"driverId": {"type": "keyword", "fields": {"text": {"type": "text"}}},
+			"routeId": {"type": "keyword", "fields": {"text": {"type": "text"}}},
+			"startTime": {"type": "date", "format": "epoch_millis"},
+			"endTime": {"type": "date", "format": "epoch_millis"},
+			"durationSeconds": {"type": "double"},
+			"distanceMeters": {"type": "double"},
+			"averageSpeedKmh": {"type": "double"},
+			"maxSpeedKmh": {"type": "double"},
+			"stops": {"type": "integer"},
+			"pointsCount": {"type": "integer"},
+			"boundingBox": {"type": "geo_shape"},
+			"createdAt": {"type": "date"}
+		}
+	}
+}`
+
+// openSearchTrip is the trip summary document indexed in OpenSearch
+type openSearchTrip struct {
+	TripID          string                 `json:"tripId"`
+	DriverID        string                 `json:"driverId"`
+	RouteID         string                 `json:"routeId"`
+	StartTime       uint64                 `json:"startTime,omitempty"`
+	EndTime         uint64                 `json:"endTime,omitempty"`
+	DurationSeconds float64                `json:"durationSeconds"`
+	DistanceMeters  float64                `json:"distanceMeters"`
+	AverageSpeedKmh float64                `json:"averageSpeedKmh"`
+	MaxSpeedKmh     float64                `json:"maxSpeedKmh"`
+	Stops           int                    `json:"stops"`
+	PointsCount     int                    `json:"pointsCount"`
+	BoundingBox     *types.GeoJSONGeometry `json:"boundingBox,omitempty"`
+	CreatedAt       time.Time              `json:"createdAt"`
+}
+
+// OpenSearchSink indexes a summary of every finalized trip into an
+// Elasticsearch/OpenSearch index
+type OpenSearchSink struct {
+	client *http.Client
+	config types.OpenSearchConfig
+}
+
+// NewOpenSearchSink creates the trip index with its mapping if it does not
+// exist yet
+func NewOpenSearchSink(ctx context.Context, config types.OpenSearchConfig) (*OpenSearchSink, error) {
+	sink := &OpenSearchSink{
+		client: &http.Client{Timeout: openSearchTimeout},
+		config: config,
+	}
+
+	status, body, err := sink.do(ctx, http.MethodPut, url.PathEscape(config.Index), []byte(openSearchTripMapping))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenSearch index %s: %w", config.Index, err)
+	}
+	if status != http.StatusOK && !strings.Contains(string(body), "resource_already_exists_exception") {
+		return nil, fmt.Errorf("failed to create OpenSearch index %s: status %d: %s", config.Index, status, body)
+	}
+
+	return sink, nil
+}
+
+// WriteTrip indexes the trip summary under the trip ID, so retried
+// finalizations overwrite the same document
+func (s *OpenSearchSink) WriteTrip(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	payload, err := json.Marshal(newOpenSearchTrip(trip, points))
+	if err != nil {
+		return fmt.Errorf("failed to marshal OpenSearch trip: %w", err)
+	}
+
+	path := fmt.Sprintf("%s/_doc/%s", url.PathEscape(s.config.Index), url.PathEscape(trip.ID))
+	status, body, err := s.do(ctx, http.MethodPut, path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to index trip %s: %w", trip.ID, err)
+	}
+	if status != http.StatusOK && status != http.StatusCreated {
+		return fmt.Errorf("failed to index trip %s: status %d: %s", trip.ID, status, body)
+	}
+	return nil
+}
+
+// newOpenSearchTrip summarizes a trip for indexing
+func newOpenSearchTrip(trip types.Trip, points []types.TrackPoint) openSearchTrip {
+	doc := openSearchTrip{
+		TripID:          trip.ID,
+		DriverID:        trip.DriverID,
+		RouteID:         trip.CurrentRouteID,
+		DurationSeconds: trip.Stats.DurationSeconds,
+		DistanceMeters:  trip.Stats.DistanceMeters,
+		AverageSpeedKmh: trip.Stats.AverageSpeedKmh,
+		MaxSpeedKmh:     trip.Stats.MaxSpeedKmh,
+		Stops:           trip.Stats.Stops,
+		PointsCount:     len(points),
+		CreatedAt:       trip.CreatedAt,
+	}
+
+	locations := make([]types.Location, 0, len(points))
+	for _, point := range points {
+		locations = append(locations, point.Location)
+		if point.Timestamp == 0 {
+			continue
+		}
+		if doc.StartTime == 0 {
+			doc.StartTime = point.Timestamp
+		}
+		doc.EndTime = point.Timestamp
+	}
+	doc.BoundingBox = algorithm.BoundingBoxGeoJSON(locations)
+
+	return doc
+}
+
+// do sends a JSON request to OpenSearch and returns the status and body
+func (s *OpenSearchSink) do(ctx context.Context, method, path string, payload []byte) (int, []byte, error) {
+	endpoint := strings.TrimRight(s.config.URL, "/") + "/" + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, bytes.TrimSpace(body), nil
+}
+
+// Close releases idle connections
+func (s *OpenSearchSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestNewOpenSearchTrip(t *testing.T) {
+	trip := types.Trip{
+		ID:             "trip-1",
+		DriverID:       "driver_001",
+		CurrentRouteID: "route_123",
+		Stats:          types.TripStats{DistanceMeters: 1200},
+	}
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 6.2460, Longitude: -75.5830}, Timestamp: 1640995260000},
+	}
+
+	doc := newOpenSearchTrip(trip, points)
+
+	if doc.StartTime != 1640995200000 || doc.EndTime != 1640995260000 {
+		t.Errorf("Expected start/end 1640995200000/1640995260000, got %d/%d", doc.StartTime, doc.EndTime)
+	}
+	if doc.DistanceMeters != 1200 || doc.PointsCount != 3 {
+		t.Errorf("Unexpected summary %+v", doc)
+	}
+	if doc.BoundingBox == nil || doc.BoundingBox.Type != "envelope" {
+		t.Errorf("Expected envelope bounding box, got %+v", doc.BoundingBox)
+	}
+}
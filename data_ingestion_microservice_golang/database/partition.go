@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"data-ingestion-microservice/types"
+)
+
+// ClusterMembersKey is the Redis sorted set of live service instances,
+// scored by the Unix time in milliseconds of their last heartbeat
+const ClusterMembersKey = "cluster:members"
+
+// ClusterLeaderKey holds the instance that runs the cluster-wide jobs
+const ClusterLeaderKey = "cluster:leader"
+
+// ClusterStatusKey is the Redis hash of the status every instance published
+// with its last heartbeat
+const ClusterStatusKey = "cluster:status"
+
+// DriverOwnerKeyPrefix prefixes the Redis keys holding the instance that
+// currently processes a driver's messages
+const DriverOwnerKeyPrefix = "owner:"
+
+// DriverOwnerKey returns the Redis key holding the owner of a driver
+func DriverOwnerKey(driverID string) string {
+	return DriverOwnerKeyPrefix + driverID
+}
+
+// acquireDriverScript takes or renews the lease of an instance on a driver,
+// or on the cluster leadership, unless another instance holds it. It returns
+// 1 when the lease is held.
+var acquireDriverScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner and owner ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// takeDriverScript takes or renews the lease of an instance on a driver
+// unless another live instance holds it. The lease of an instance missing
+// from the cluster members, which crashed or left, is taken over right away
+// instead of once it expires. It returns 1 when the lease is held.
+var takeDriverScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner and owner ~= ARGV[1] and redis.call('ZSCORE', KEYS[2], owner) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// releaseDriverScript deletes the lease of an instance on a driver, leaving
+// leases taken over by other instances alone
+var releaseDriverScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Heartbeat records that an instance is alive, removes the instances that
+// missed heartbeats for ttl along with their status, and returns the live
+// instances
+func (dm *DatabaseManager) Heartbeat(ctx context.Context, instanceID string, ttl time.Duration) ([]string, error) {
+	now := time.Now()
+	expired := "(" + strconv.FormatInt(now.Add(-ttl).UnixMilli(), 10)
+
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.ZAdd(ctx, ClusterMembersKey, redis.Z{Score: float64(now.UnixMilli()), Member: instanceID})
+	dead := pipe.ZRangeByScore(ctx, ClusterMembersKey, &redis.ZRangeBy{Min: "-inf", Max: expired})
+	pipe.ZRemRangeByScore(ctx, ClusterMembersKey, "-inf", expired)
+	members := pipe.ZRange(ctx, ClusterMembersKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to record heartbeat of instance %s: %w", instanceID, err)
+	}
+
+	if len(dead.Val()) > 0 {
+		if err := dm.RedisClient.HDel(ctx, ClusterStatusKey, dead.Val()...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove status of dead instances: %w", err)
+		}
+	}
+	return members.Val(), nil
+}
+
+// LeaveCluster removes an instance and its status from the live instances
+func (dm *DatabaseManager) LeaveCluster(ctx context.Context, instanceID string) error {
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.ZRem(ctx, ClusterMembersKey, instanceID)
+	pipe.HDel(ctx, ClusterStatusKey, instanceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove instance %s from the cluster: %w", instanceID, err)
+	}
+	return nil
+}
+
+// AcquireDriver takes or renews the lease of an instance on a driver for
+// ttl, and reports false if another live instance holds it
+func (dm *DatabaseManager) AcquireDriver(ctx context.Context, driverID, instanceID string, ttl time.Duration) (bool, error) {
+	held, err := takeDriverScript.Run(ctx, dm.RedisClient, []string{DriverOwnerKey(driverID), ClusterMembersKey}, instanceID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire driver %s: %w", driverID, err)
+	}
+	return held == 1, nil
+}
+
+// ReleaseDriver gives up the lease of an instance on a driver
+func (dm *DatabaseManager) ReleaseDriver(ctx context.Context, driverID, instanceID string) error {
+	if err := releaseDriverScript.Run(ctx, dm.RedisClient, []string{DriverOwnerKey(driverID)}, instanceID).Err(); err != nil {
+		return fmt.Errorf("failed to release driver %s: %w", driverID, err)
+	}
+	return nil
+}
+
+// AcquireLeadership takes or renews the leadership of an instance for ttl,
+// and reports false if another instance leads the cluster
+func (dm *DatabaseManager) AcquireLeadership(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	held, err := acquireDriverScript.Run(ctx, dm.RedisClient, []string{ClusterLeaderKey}, instanceID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire cluster leadership: %w", err)
+	}
+	return held == 1, nil
+}
+
+// ReleaseLeadership gives up the leadership of an instance
+func (dm *DatabaseManager) ReleaseLeadership(ctx context.Context, instanceID string) error {
+	if err := releaseDriverScript.Run(ctx, dm.RedisClient, []string{ClusterLeaderKey}, instanceID).Err(); err != nil {
+		return fmt.Errorf("failed to release cluster leadership: %w", err)
+	}
+	return nil
+}
+
+// PublishStatus stores the status of an instance for the other instances
+func (dm *DatabaseManager) PublishStatus(ctx context.Context, status types.InstanceStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal instance status: %w", err)
+	}
+	if err := dm.RedisClient.HSet(ctx, ClusterStatusKey, status.InstanceID, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish status of instance %s: %w", status.InstanceID, err)
+	}
+	return nil
+}
+
+// InstanceStatuses returns the last status published by every instance,
+// including instances that have since died, skipping statuses that cannot
+// be decoded
+func (dm *DatabaseManager) InstanceStatuses(ctx context.Context) ([]types.InstanceStatus, error) {
+	values, err := dm.RedisClient.HGetAll(ctx, ClusterStatusKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance statuses: %w", err)
+	}
+
+	statuses := make([]types.InstanceStatus, 0, len(values))
+	for instanceID, value := range values {
+		var status types.InstanceStatus
+		if err := json.Unmarshal([]byte(value), &status); err != nil {
+			slog.WarnContext(ctx, "Skipping undecodable instance status", "instance", instanceID, "error", err)
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
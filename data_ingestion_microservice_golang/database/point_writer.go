@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pointFlushTimeout bounds the write of one pipeline of points
+const pointFlushTimeout = 10 * time.Second
+
+// pendingPoint is a point waiting for the next pipeline flush
+type pendingPoint struct {
+	key   string
+	point types.TrackPoint
+	done  chan error
+}
+
+// PointWriter coalesces route stream appends into Redis pipelines, flushing
+// when enough points are pending or the flush interval elapses. Points of
+// the same route keep their arrival order and share a single expiry refresh
+// per flush, and a point is appended once however often it is delivered.
+// Append blocks until its pipeline executes so callers still see write
+// errors.
+type PointWriter struct {
+	client        *redis.Client
+	config        types.RedisConfig
+	batchSize     int
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	pending []pendingPoint
+
+	// downsampling tracks routes with a downsampling pass in progress
+	downsampling sync.Map
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+// NewPointWriter creates a point writer and starts its periodic flusher.
+// A pipeline size of one or less writes every point immediately.
+func NewPointWriter(client *redis.Client, config types.RedisConfig) *PointWriter {
+	writer := &PointWriter{
+		client:        client,
+		config:        config,
+		batchSize:     config.PipelineSize,
+		flushInterval: config.PipelineInterval,
+		stop:          make(chan struct{}),
+	}
+
+	if writer.batching() {
+		writer.stopped.Add(1)
+		go writer.run()
+	}
+
+	return writer
+}
+
+// Append adds a point to a route stream, waiting for its pipeline to execute
+func (w *PointWriter) Append(ctx context.Context, key string, point types.TrackPoint) error {
+	if !w.batching() {
+		return w.write(ctx, []pendingPoint{{key: key, point: point}})[0]
+	}
+
+	done := make(chan error, 1)
+
+	w.mu.Lock()
+	w.pending = append(w.pending, pendingPoint{key: key, point: point, done: done})
+	full := len(w.pending) >= w.batchSize
+	w.mu.Unlock()
+
+	// The pipeline holds the points of other callers too, so canceling this
+	// one must not fail the whole write
+	if full {
+		w.Flush(context.WithoutCancel(ctx))
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush writes all pending points in a single pipeline, within pointFlushTimeout
+func (w *PointWriter) Flush(ctx context.Context) {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pointFlushTimeout)
+	defer cancel()
+
+	metrics.RedisPipelineFlushes.Add(1)
+	for i, err := range w.write(ctx, batch) {
+		batch[i].done <- err
+	}
+}
+
+// write appends points through one pipeline and returns the error of each
+func (w *PointWriter) write(ctx context.Context, batch []pendingPoint) []error {
+	errs := make([]error, len(batch))
+	cmds := make([]*redis.Cmd, len(batch))
+	routes := make(map[string]bool)
+	ttl := int64(w.config.RouteTTL.Seconds())
+
+	pipe := w.client.Pipeline()
+	for i, pending := range batch {
+		pointJSON, err := json.Marshal(pending.point)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal location: %w", err)
+			continue
+		}
+
+		keys := []string{pending.key, seenKey(pending.key)}
+		cmds[i] = appendPointScript.Eval(ctx, pipe, keys, seenMember(pending.point), string(pointJSON), ttl)
+
+		routes[pending.key] = true
+	}
+
+	// Refresh the expiry once per route so abandoned buffers are reclaimed
+	if w.config.RouteTTL > 0 {
+		for key := range routes {
+			pipe.Expire(ctx, key, w.config.RouteTTL)
+		}
+	}
+
+	// Check the length of every route once its points are appended
+	lengths := make(map[string]*redis.IntCmd)
+	limit := routePointCap(w.config)
+	if limit > 0 {
+		for key := range routes {
+			lengths[key] = pipe.XLen(ctx, key)
+		}
+	}
+
+	// Failed commands carry their own error, so the pipeline's is not needed
+	_, _ = pipe.Exec(ctx)
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		appended, err := cmd.Int64()
+		switch {
+		case err != nil:
+			errs[i] = fmt.Errorf("failed to append location to Redis stream: %w", err)
+		case appended == 0:
+			metrics.DuplicatePoints.Add(1)
+			slog.DebugContext(ctx, "Skipped point already in the route stream", "key", batch[i].key, "timestamp", batch[i].point.Timestamp)
+		}
+	}
+
+	// Downsample runaway buffers so a misbehaving device cannot exhaust memory
+	for key, length := range lengths {
+		if length.Err() != nil || length.Val() <= int64(limit) {
+			continue
+		}
+		if err := w.downsample(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "Failed to downsample route buffer", "key", key, "error", err)
+		}
+	}
+
+	return errs
+}
+
+// Close stops the periodic flusher and flushes any pending points
+func (w *PointWriter) Close(ctx context.Context) {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	w.stopped.Wait()
+	w.Flush(ctx)
+}
+
+// batching reports whether points are coalesced into pipelines
+func (w *PointWriter) batching() bool {
+	return w.batchSize > 1
+}
+
+// run flushes pending points every flush interval until closed
+func (w *PointWriter) run() {
+	defer w.stopped.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush(context.Background())
+		case <-w.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scriptedPipeline answers pipelined append scripts by route key without a
+// Redis server: an error for keys in failing, 0 (a duplicate) for keys in
+// duplicates, and 1 otherwise. Other commands succeed, and pipelines fail
+// once their context is done.
+type scriptedPipeline struct {
+	failing    map[string]error
+	duplicates map[string]bool
+	executed   [][]string
+}
+
+func (h *scriptedPipeline) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("no Redis server in tests")
+	}
+}
+
+func (h *scriptedPipeline) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.answer(cmd)
+	}
+}
+
+func (h *scriptedPipeline) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := ctx.Err(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		var names []string
+		for _, cmd := range cmds {
+			names = append(names, cmd.Name())
+			h.answer(cmd)
+		}
+		h.executed = append(h.executed, names)
+		return nil
+	}
+}
+
+func (h *scriptedPipeline) answer(cmd redis.Cmder) error {
+	switch cmd := cmd.(type) {
+	case *redis.Cmd:
+		// EVAL script numkeys key seenKey ...
+		key, _ := cmd.Args()[3].(string)
+		switch {
+		case h.failing[key] != nil:
+			cmd.SetErr(h.failing[key])
+		case h.duplicates[key]:
+			cmd.SetVal(int64(0))
+		default:
+			cmd.SetVal(int64(1))
+		}
+		return cmd.Err()
+	case *redis.BoolCmd:
+		cmd.SetVal(true)
+	case *redis.IntCmd:
+		cmd.SetVal(1)
+	}
+	return nil
+}
+
+func newScriptedWriter(hook *scriptedPipeline, config types.RedisConfig) *PointWriter {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})
+	client.AddHook(hook)
+	return NewPointWriter(client, config)
+}
+
+func TestPointWriter_MapsErrorsToPoints(t *testing.T) {
+	hook := &scriptedPipeline{
+		failing:    map[string]error{"route:driver_002:route_1": errors.New("OOM command not allowed")},
+		duplicates: map[string]bool{"route:driver_003:route_1": true},
+	}
+	writer := newScriptedWriter(hook, types.RedisConfig{RouteTTL: time.Hour})
+	duplicatesBefore := metrics.DuplicatePoints.Value()
+
+	batch := []pendingPoint{
+		{key: "route:driver_001:route_1", point: types.TrackPoint{Timestamp: 1}},
+		{key: "route:driver_002:route_1", point: types.TrackPoint{Timestamp: 2}},
+		{key: "route:driver_003:route_1", point: types.TrackPoint{Timestamp: 3}},
+		{key: "route:driver_004:route_1", point: types.TrackPoint{Location: types.Location{Latitude: math.NaN()}, Timestamp: 4}},
+	}
+	errs := writer.write(context.Background(), batch)
+
+	if errs[0] != nil {
+		t.Errorf("Expected the first point to be appended, got %v", errs[0])
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "OOM") {
+		t.Errorf("Expected the second point to carry its command error, got %v", errs[1])
+	}
+	if errs[2] != nil {
+		t.Errorf("Expected a duplicate not to be an error, got %v", errs[2])
+	}
+	if errs[3] == nil || !strings.Contains(errs[3].Error(), "marshal") {
+		t.Errorf("Expected the unencodable point to fail without a command, got %v", errs[3])
+	}
+	if n := metrics.DuplicatePoints.Value() - duplicatesBefore; n != 1 {
+		t.Errorf("Expected 1 duplicate to be counted, got %d", n)
+	}
+
+	// Three scripts and one expiry per appended route, in one pipeline
+	if len(hook.executed) != 1 || len(hook.executed[0]) != 6 {
+		t.Errorf("Expected a single pipeline of 3 scripts and 3 expiries, got %v", hook.executed)
+	}
+}
+
+func TestPointWriter_AppendWaitsForItsFlush(t *testing.T) {
+	hook := &scriptedPipeline{failing: map[string]error{"route:driver_002:route_1": errors.New("READONLY")}}
+	writer := newScriptedWriter(hook, types.RedisConfig{PipelineSize: 2, PipelineInterval: time.Hour})
+	defer writer.Close(context.Background())
+
+	first := make(chan error, 1)
+	go func() {
+		first <- writer.Append(context.Background(), "route:driver_001:route_1", types.TrackPoint{Timestamp: 1})
+	}()
+	for {
+		writer.mu.Lock()
+		queued := len(writer.pending)
+		writer.mu.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := writer.Append(context.Background(), "route:driver_002:route_1", types.TrackPoint{Timestamp: 2}); err == nil || !strings.Contains(err.Error(), "READONLY") {
+		t.Errorf("Expected the second point to fail, got %v", err)
+	}
+	if err := <-first; err != nil {
+		t.Errorf("Expected the first point of the batch to succeed, got %v", err)
+	}
+}
+
+func TestPointWriter_CanceledCallerKeepsBatch(t *testing.T) {
+	hook := &scriptedPipeline{}
+	writer := newScriptedWriter(hook, types.RedisConfig{PipelineSize: 2, PipelineInterval: time.Hour})
+	defer writer.Close(context.Background())
+
+	// The first point waits for the pipeline; the second fills it from a
+	// request that was already canceled
+	first := make(chan error, 1)
+	go func() {
+		first <- writer.Append(context.Background(), "route:driver_001:route_1", types.TrackPoint{Timestamp: 1})
+	}()
+	for {
+		writer.mu.Lock()
+		queued := len(writer.pending)
+		writer.mu.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	writer.Append(canceled, "route:driver_002:route_1", types.TrackPoint{Timestamp: 2})
+
+	if err := <-first; err != nil {
+		t.Fatalf("Expected the first point to be appended, got %v", err)
+	}
+	if len(hook.executed) != 1 || len(hook.executed[0]) != 2 {
+		t.Errorf("Expected both points to be appended in one pipeline, got %v", hook.executed)
+	}
+}
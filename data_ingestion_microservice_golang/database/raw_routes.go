@@ -0,0 +1,74 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RawRouteEncoding identifies how raw route points are serialized
+const RawRouteEncoding = "json+gzip"
+
+// SaveRawRoute stores the full, unsimplified point list of a trip, gzip
+// compressed, keyed by the trip ID so it can be re-simplified later
+func (dm *DatabaseManager) SaveRawRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	compressed, err := CompressPoints(points)
+	if err != nil {
+		return err
+	}
+
+	doc := bson.M{
+		"_id":            trip.ID,
+		"driverId":       trip.DriverID,
+		"currentRouteId": trip.CurrentRouteID,
+		"pointsCount":    len(points),
+		"encoding":       RawRouteEncoding,
+		"points":         primitive.Binary{Data: compressed},
+		"createdAt":      time.Now().UTC(),
+	}
+
+	_, err = dm.collections().RawRoutes.ReplaceOne(ctx, bson.M{"_id": trip.ID}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to store raw route for trip %s: %w", trip.ID, err)
+	}
+	return nil
+}
+
+// CompressPoints serializes points as JSON and gzip compresses them
+func CompressPoints(points []types.TrackPoint) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+
+	if err := json.NewEncoder(writer).Encode(points); err != nil {
+		return nil, fmt.Errorf("failed to encode raw points: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress raw points: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// DecompressPoints reverses CompressPoints
+func DecompressPoints(data []byte) ([]types.TrackPoint, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress raw points: %w", err)
+	}
+	defer reader.Close()
+
+	var points []types.TrackPoint
+	if err := json.NewDecoder(reader).Decode(&points); err != nil {
+		return nil, fmt.Errorf("failed to decode raw points: %w", err)
+	}
+	return points, nil
+}
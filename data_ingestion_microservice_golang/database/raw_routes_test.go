@@ -0,0 +1,28 @@
+package database
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestCompressPoints_RoundTrip(t *testing.T) {
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995201000},
+	}
+
+	compressed, err := CompressPoints(points)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	decompressed, err := DecompressPoints(compressed)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(decompressed) != len(points) || decompressed[1] != points[1] {
+		t.Errorf("Expected %v, got %v", points, decompressed)
+	}
+}
@@ -0,0 +1,384 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/tracing"
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RouteKeyPrefix prefixes the Redis streams buffering in-route points
+const RouteKeyPrefix = "route:"
+
+// seenKeyPrefix prefixes the Redis sets holding the timestamps already
+// appended to each route stream
+const seenKeyPrefix = "seen:"
+
+// pointField is the stream entry field holding a JSON encoded TrackPoint
+const pointField = "point"
+
+// jobField is the stream entry field holding a JSON encoded finalization job
+const jobField = "job"
+
+// traceField is the stream entry field holding the JSON encoded trace
+// context of the message that finished the route
+const traceField = "trace"
+
+// RouteKey returns the Redis stream key buffering a driver's route
+func RouteKey(driverID, routeID string) string {
+	return fmt.Sprintf("%s%s:%s", RouteKeyPrefix, driverID, routeID)
+}
+
+// vehicleKeyMarker follows RouteKeyPrefix in the keys of routes buffered
+// per vehicle rather than per driver
+const vehicleKeyMarker = "vehicle:"
+
+// VehicleRouteKey returns the Redis stream key buffering a vehicle's route,
+// whoever drives it
+func VehicleRouteKey(vehicleID, routeID string) string {
+	return RouteKey(vehicleKeyMarker+vehicleID, routeID)
+}
+
+// ParseRouteKey returns the driver and route IDs of a route buffer key.
+// Driver IDs containing a colon cannot be told apart from the route ID and
+// are split at their first colon. Vehicle route keys are not driver keys.
+func ParseRouteKey(key string) (driverID, routeID string, ok bool) {
+	rest, ok := strings.CutPrefix(key, RouteKeyPrefix)
+	if !ok || strings.HasPrefix(rest, vehicleKeyMarker) {
+		return "", "", false
+	}
+	driverID, routeID, ok = strings.Cut(rest, ":")
+	return driverID, routeID, ok && driverID != "" && routeID != ""
+}
+
+// ParseVehicleRouteKey returns the vehicle and route IDs of a vehicle route
+// buffer key
+func ParseVehicleRouteKey(key string) (vehicleID, routeID string, ok bool) {
+	rest, ok := strings.CutPrefix(key, RouteKeyPrefix+vehicleKeyMarker)
+	if !ok {
+		return "", "", false
+	}
+	vehicleID, routeID, ok = strings.Cut(rest, ":")
+	return vehicleID, routeID, ok && vehicleID != "" && routeID != ""
+}
+
+// seenKey returns the Redis set of timestamps appended to a route stream,
+// outside RouteKeyPrefix so its expiry is not mistaken for the route's
+func seenKey(key string) string {
+	return seenKeyPrefix + key
+}
+
+// seenMember returns the member recording a point's timestamp in the set of
+// its route, or "0" for a point without one. Passenger counts are recorded
+// apart from locations, so a count sharing a location's timestamp is kept.
+func seenMember(point types.TrackPoint) string {
+	member := strconv.FormatUint(point.Timestamp, 10)
+	if point.Timestamp != 0 && point.Passengers != nil {
+		member = "passengers:" + member
+	}
+	return member
+}
+
+// BufferedPoint is a track point read back from a route stream
+type BufferedPoint struct {
+	ID    string
+	Point types.TrackPoint
+}
+
+// IdleRoute is a route buffer that has not received points for a while,
+// with its newest point
+type IdleRoute struct {
+	Key      string
+	LastID   string
+	Last     types.TrackPoint
+	LastSeen time.Time
+}
+
+// FinalizationEntry is a finalization job read from the consumer group
+// stream, with the trace context it was enqueued under
+type FinalizationEntry struct {
+	ID     string
+	BusMsg types.BusMessage
+	Trace  map[string]string
+}
+
+// AppendPoint adds a point to a route stream through the pipelined point
+// writer, which trims the stream and refreshes its expiry. Appending a point
+// with the timestamp of one already appended does nothing.
+func (dm *DatabaseManager) AppendPoint(ctx context.Context, key string, point types.TrackPoint) error {
+	return dm.PointWriter.Append(ctx, key, point)
+}
+
+// ReadPoints returns every point buffered in a route stream, skipping
+// entries that cannot be decoded
+func (dm *DatabaseManager) ReadPoints(ctx context.Context, key string) ([]BufferedPoint, error) {
+	entries, err := dm.RedisClient.XRange(ctx, key, "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Redis stream: %w", err)
+	}
+
+	points := make([]BufferedPoint, 0, len(entries))
+	for _, entry := range entries {
+		point, err := decodePoint(entry)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping undecodable route point", "key", key, "error", err)
+			continue
+		}
+		points = append(points, BufferedPoint{ID: entry.ID, Point: point})
+	}
+	return points, nil
+}
+
+// appendPointScript appends a point to a route stream unless a point with the
+// same timestamp member was appended before, so redelivered messages are
+// stored once. Points without a timestamp are always appended. Streams are
+// not trimmed here, since trimming would drop the first point and passenger
+// counts; the point writer downsamples them instead. It returns 1 when the
+// point was appended and 0 for a duplicate.
+var appendPointScript = redis.NewScript(`
+if ARGV[1] ~= '0' then
+	if redis.call('SADD', KEYS[2], ARGV[1]) == 0 then
+		return 0
+	end
+	if tonumber(ARGV[3]) > 0 then
+		redis.call('EXPIRE', KEYS[2], ARGV[3])
+	end
+end
+redis.call('XADD', KEYS[1], '*', 'point', ARGV[2])
+return 1
+`)
+
+// clearPointsScript atomically removes every entry up to and including the
+// last finalized entry ID and deletes the stream once nothing is left. The
+// appended timestamps are kept until they expire so late redeliveries are
+// still recognized, unless routes never expire. It returns the number of
+// entries that arrived after the finalized ones.
+var clearPointsScript = redis.NewScript(`
+redis.call('XTRIM', KEYS[1], 'MINID', ARGV[1])
+redis.call('XDEL', KEYS[1], ARGV[1])
+local remaining = redis.call('XLEN', KEYS[1])
+if remaining == 0 then
+	redis.call('DEL', KEYS[1])
+	if ARGV[2] == '0' then
+		redis.call('DEL', KEYS[2])
+	end
+end
+return remaining
+`)
+
+// ClearPoints removes the finalized points of a route stream, up to and
+// including lastID, in a single atomic step. Points appended after they were
+// read are kept so they are never silently dropped; the number left in the
+// stream is returned.
+func (dm *DatabaseManager) ClearPoints(ctx context.Context, key, lastID string) (int64, error) {
+	ttl := int64(dm.redisConfig.RouteTTL.Seconds())
+	remaining, err := clearPointsScript.Run(ctx, dm.RedisClient, []string{key, seenKey(key)}, lastID, ttl).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear Redis stream: %w", err)
+	}
+	return remaining, nil
+}
+
+// IdleRoutes returns the route buffers whose newest point was appended
+// more than idleFor ago, going by the time in its stream entry ID
+func (dm *DatabaseManager) IdleRoutes(ctx context.Context, idleFor time.Duration) ([]IdleRoute, error) {
+	cutoff := time.Now().Add(-idleFor)
+
+	var idle []IdleRoute
+	var cursor uint64
+	for {
+		keys, next, err := dm.RedisClient.ScanType(ctx, cursor, RouteKeyPrefix+"*", 100, "stream").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan route buffers: %w", err)
+		}
+
+		pipe := dm.RedisClient.Pipeline()
+		newest := make([]*redis.XMessageSliceCmd, len(keys))
+		for i, key := range keys {
+			newest[i] = pipe.XRevRangeN(ctx, key, "+", "-", 1)
+		}
+		if len(keys) > 0 {
+			// Every command carries its own error
+			_, _ = pipe.Exec(ctx)
+		}
+
+		for i, cmd := range newest {
+			entries, err := cmd.Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read route buffer %s: %w", keys[i], err)
+			}
+			if len(entries) == 0 {
+				continue
+			}
+			appended, err := streamEntryTime(entries[0].ID)
+			if err != nil || appended.After(cutoff) {
+				continue
+			}
+			point, err := decodePoint(entries[0])
+			if err != nil {
+				slog.WarnContext(ctx, "Skipping undecodable route point", "key", keys[i], "error", err)
+			}
+			idle = append(idle, IdleRoute{Key: keys[i], LastID: entries[0].ID, Last: point, LastSeen: appended})
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return idle, nil
+		}
+	}
+}
+
+// streamEntryTime returns when a stream entry was added, from the
+// millisecond part of its ID
+func streamEntryTime(id string) (time.Time, error) {
+	millis, _, _ := strings.Cut(id, "-")
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stream entry ID %q: %w", id, err)
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// decodePoint decodes the track point stored in a stream entry
+func decodePoint(entry redis.XMessage) (types.TrackPoint, error) {
+	var point types.TrackPoint
+	raw, ok := entry.Values[pointField].(string)
+	if !ok {
+		return point, fmt.Errorf("stream entry %s has no point", entry.ID)
+	}
+	if err := json.Unmarshal([]byte(raw), &point); err != nil {
+		return point, fmt.Errorf("failed to unmarshal stream entry %s: %w", entry.ID, err)
+	}
+	return point, nil
+}
+
+// setupFinalizationGroup creates the finalization stream consumer group if it
+// does not exist yet
+func (dm *DatabaseManager) setupFinalizationGroup(ctx context.Context) error {
+	err := dm.RedisClient.XGroupCreateMkStream(ctx, dm.redisConfig.FinalizeStream, dm.redisConfig.FinalizeGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", dm.redisConfig.FinalizeGroup, err)
+	}
+	return nil
+}
+
+// EnqueueFinalization publishes a finished route to the finalization stream
+// so any instance in the consumer group can finalize it
+func (dm *DatabaseManager) EnqueueFinalization(ctx context.Context, busMsg types.BusMessage) error {
+	jobJSON, err := json.Marshal(busMsg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal finalization job: %w", err)
+	}
+
+	values := map[string]interface{}{jobField: string(jobJSON)}
+	if carrier := tracing.Inject(ctx); carrier != nil {
+		traceJSON, err := json.Marshal(carrier)
+		if err != nil {
+			return fmt.Errorf("failed to marshal trace context: %w", err)
+		}
+		values[traceField] = string(traceJSON)
+	}
+
+	err = dm.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: dm.redisConfig.FinalizeStream,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue finalization: %w", err)
+	}
+	return nil
+}
+
+// ReadFinalizations blocks until new finalization jobs are delivered to this
+// consumer, returning nothing when the block timeout expires
+func (dm *DatabaseManager) ReadFinalizations(ctx context.Context, consumer string, count int64, block time.Duration) ([]FinalizationEntry, error) {
+	streams, err := dm.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    dm.redisConfig.FinalizeGroup,
+		Consumer: consumer,
+		Streams:  []string{dm.redisConfig.FinalizeStream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read finalization stream: %w", err)
+	}
+
+	var entries []FinalizationEntry
+	for _, stream := range streams {
+		entries = append(entries, dm.decodeFinalizations(ctx, stream.Messages)...)
+	}
+	return entries, nil
+}
+
+// ClaimStaleFinalizations takes over jobs that another consumer received but
+// did not acknowledge within minIdle, e.g. because its instance crashed
+func (dm *DatabaseManager) ClaimStaleFinalizations(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]FinalizationEntry, error) {
+	messages, _, err := dm.RedisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   dm.redisConfig.FinalizeStream,
+		Group:    dm.redisConfig.FinalizeGroup,
+		MinIdle:  minIdle,
+		Start:    "0",
+		Count:    count,
+		Consumer: consumer,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim stale finalizations: %w", err)
+	}
+	return dm.decodeFinalizations(ctx, messages), nil
+}
+
+// AckFinalization acknowledges and removes a processed finalization job
+func (dm *DatabaseManager) AckFinalization(ctx context.Context, id string) error {
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.XAck(ctx, dm.redisConfig.FinalizeStream, dm.redisConfig.FinalizeGroup, id)
+	pipe.XDel(ctx, dm.redisConfig.FinalizeStream, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to acknowledge finalization %s: %w", id, err)
+	}
+	return nil
+}
+
+// FinalizationBacklog returns the number of finalization jobs that are
+// queued or pending acknowledgement. Acknowledged jobs are deleted, so this
+// is the length of the stream.
+func (dm *DatabaseManager) FinalizationBacklog(ctx context.Context) (int64, error) {
+	backlog, err := dm.RedisClient.XLen(ctx, dm.redisConfig.FinalizeStream).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read finalization backlog: %w", err)
+	}
+	return backlog, nil
+}
+
+// decodeFinalizations decodes finalization jobs, acknowledging entries that
+// can never be processed so they do not stay pending forever
+func (dm *DatabaseManager) decodeFinalizations(ctx context.Context, messages []redis.XMessage) []FinalizationEntry {
+	entries := make([]FinalizationEntry, 0, len(messages))
+	for _, message := range messages {
+		var busMsg types.BusMessage
+		raw, ok := message.Values[jobField].(string)
+		if !ok || json.Unmarshal([]byte(raw), &busMsg) != nil {
+			_ = dm.AckFinalization(ctx, message.ID)
+			continue
+		}
+
+		// A missing or malformed trace only starts a new trace
+		var carrier map[string]string
+		if raw, ok := message.Values[traceField].(string); ok {
+			_ = json.Unmarshal([]byte(raw), &carrier)
+		}
+		entries = append(entries, FinalizationEntry{ID: message.ID, BusMsg: busMsg, Trace: carrier})
+	}
+	return entries
+}
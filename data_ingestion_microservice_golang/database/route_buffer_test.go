@@ -0,0 +1,89 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDecodePoint(t *testing.T) {
+	entry := redis.XMessage{ID: "1640995200000-0", Values: map[string]interface{}{
+		pointField: `{"latitude":6.2442,"longitude":-75.5812,"timestamp":1640995200000}`,
+	}}
+
+	point, err := decodePoint(entry)
+	if err != nil {
+		t.Fatalf("Expected the point to decode, got %v", err)
+	}
+	if point.Timestamp != 1640995200000 || point.Location.Latitude != 6.2442 || point.Location.Longitude != -75.5812 {
+		t.Errorf("Unexpected point %+v", point)
+	}
+}
+
+func TestDecodePoint_RejectsBadEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+	}{
+		{"missing point", map[string]interface{}{jobField: "{}"}},
+		{"point not a string", map[string]interface{}{pointField: 42}},
+		{"malformed JSON", map[string]interface{}{pointField: `{"timestamp":`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodePoint(redis.XMessage{ID: "1-0", Values: tt.values}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestStreamEntryTime(t *testing.T) {
+	appended, err := streamEntryTime("1640995200123-7")
+	if err != nil {
+		t.Fatalf("Expected a valid entry ID, got %v", err)
+	}
+	if !appended.Equal(time.UnixMilli(1640995200123)) {
+		t.Errorf("Expected 1640995200123 ms, got %v", appended)
+	}
+
+	if _, err := streamEntryTime("not-an-id"); err == nil {
+		t.Error("Expected an invalid entry ID to be rejected")
+	}
+}
+
+func TestParseRouteKey(t *testing.T) {
+	if driverID, routeID, ok := ParseRouteKey(RouteKey("driver_001", "route_123")); !ok || driverID != "driver_001" || routeID != "route_123" {
+		t.Errorf("Expected driver_001 on route_123, got %q %q %v", driverID, routeID, ok)
+	}
+	for _, key := range []string{"seen:route:driver_001:route_123", "route:driver_001", "route::route_123", VehicleRouteKey("bus-7", "route_123")} {
+		if _, _, ok := ParseRouteKey(key); ok {
+			t.Errorf("Expected %q not to be a driver route key", key)
+		}
+	}
+
+	if vehicleID, routeID, ok := ParseVehicleRouteKey(VehicleRouteKey("bus-7", "route_123")); !ok || vehicleID != "bus-7" || routeID != "route_123" {
+		t.Errorf("Expected bus-7 on route_123, got %q %q %v", vehicleID, routeID, ok)
+	}
+	if _, _, ok := ParseVehicleRouteKey(RouteKey("driver_001", "route_123")); ok {
+		t.Error("Expected a driver route key not to be a vehicle route key")
+	}
+}
+
+func TestSeenMember(t *testing.T) {
+	location := types.TrackPoint{Timestamp: 1640995200000}
+	count := types.TrackPoint{Timestamp: 1640995200000, Passengers: &types.PassengerCount{}}
+
+	if member := seenMember(location); member != "1640995200000" {
+		t.Errorf("Expected the timestamp, got %q", member)
+	}
+	if member := seenMember(count); member != "passengers:1640995200000" {
+		t.Errorf("Expected passenger counts to be recorded apart, got %q", member)
+	}
+	if member := seenMember(types.TrackPoint{}); member != "0" {
+		t.Errorf("Expected points without a timestamp to use 0, got %q", member)
+	}
+}
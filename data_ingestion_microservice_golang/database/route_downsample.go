@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// downsampleDeleteChunk caps the number of entry IDs removed per XDEL call
+const downsampleDeleteChunk = 1000
+
+// routePointCap returns the number of points past which a route buffer is
+// downsampled: the lower of the stream length cap and the route point cap,
+// or 0 when neither is set
+func routePointCap(config types.RedisConfig) int {
+	limit := config.RouteMaxPoints
+	if maxLen := int(config.StreamMaxLen); maxLen > 0 && (limit <= 0 || maxLen < limit) {
+		limit = maxLen
+	}
+	return max(limit, 0)
+}
+
+// downsample thins a route stream that grew past the point cap down to half
+// the cap by keeping every k-th point, always keeping the first and last
+// points and the passenger counts. Points appended while the pass runs are
+// never removed.
+func (w *PointWriter) downsample(ctx context.Context, key string) error {
+	// Skip routes that are already being downsampled by another flush
+	if _, running := w.downsampling.LoadOrStore(key, struct{}{}); running {
+		return nil
+	}
+	defer w.downsampling.Delete(key)
+
+	entries, err := w.client.XRange(ctx, key, "-", "+").Result()
+	if err != nil {
+		return fmt.Errorf("failed to read Redis stream for downsampling: %w", err)
+	}
+
+	ids := make([]string, len(entries))
+	counts := make([]bool, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+		if point, err := decodePoint(entry); err == nil {
+			counts[i] = point.Passengers != nil
+		}
+	}
+
+	drop := downsampleIDs(ids, counts, routePointCap(w.config)/2)
+	for start := 0; start < len(drop); start += downsampleDeleteChunk {
+		end := min(start+downsampleDeleteChunk, len(drop))
+		if err := w.client.XDel(ctx, key, drop[start:end]...).Err(); err != nil {
+			return fmt.Errorf("failed to downsample Redis stream: %w", err)
+		}
+	}
+
+	metrics.RouteBuffersDownsampled.Add(1)
+	slog.InfoContext(ctx, "Downsampled route buffer", "key", key, "originalPoints", len(ids), "points", len(ids)-len(drop))
+	return nil
+}
+
+// downsampleIDs returns the entry IDs to remove so that roughly target
+// entries remain, keeping every k-th entry plus the first and last ones and
+// those marked in keep, which may be nil
+func downsampleIDs(ids []string, keep []bool, target int) []string {
+	if target < 2 || len(ids) <= target {
+		return nil
+	}
+
+	step := (len(ids) + target - 1) / target
+	drop := make([]string, 0, len(ids)-target)
+	for i := 1; i < len(ids)-1; i++ {
+		if i%step != 0 && (keep == nil || !keep[i]) {
+			drop = append(drop, ids[i])
+		}
+	}
+	return drop
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDownsampleIDs(t *testing.T) {
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+
+	drop := downsampleIDs(ids, nil, 25)
+	dropped := make(map[string]bool, len(drop))
+	for _, id := range drop {
+		dropped[id] = true
+	}
+
+	if dropped["0"] || dropped["99"] {
+		t.Errorf("Expected first and last points to be kept")
+	}
+
+	if kept := len(ids) - len(drop); kept > 26 || kept < 20 {
+		t.Errorf("Expected about 25 points to be kept, got %d", kept)
+	}
+}
+
+func TestDownsampleIDs_BelowTarget(t *testing.T) {
+	ids := []string{"1", "2", "3"}
+
+	if drop := downsampleIDs(ids, nil, 10); len(drop) != 0 {
+		t.Errorf("Expected no points to be dropped, got %v", drop)
+	}
+}
+
+func TestDownsampleIDs_KeepsMarkedEntries(t *testing.T) {
+	ids := make([]string, 100)
+	keep := make([]bool, len(ids))
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+		keep[i] = i%7 == 3
+	}
+
+	for _, id := range downsampleIDs(ids, keep, 25) {
+		if i, _ := strconv.Atoi(id); keep[i] {
+			t.Errorf("Expected marked entry %s to be kept", id)
+		}
+	}
+}
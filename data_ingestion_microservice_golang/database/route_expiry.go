@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// expiredEventsConfig enables keyspace notifications for expired keys ("Ex")
+const expiredEventsConfig = "Ex"
+
+// WatchExpiredRoutes subscribes to Redis expiry notifications and calls the
+// handler for every route buffer that expired before it was finalized. It
+// blocks until the context is cancelled.
+func (dm *DatabaseManager) WatchExpiredRoutes(ctx context.Context, handler func(key string)) error {
+	// Keyspace notifications are disabled by default. Managed Redis offerings
+	// often block CONFIG, in which case they must be enabled out of band.
+	if err := dm.enableExpiredEvents(ctx); err != nil {
+		slog.WarnContext(ctx, "Could not enable Redis expiry notifications, expecting them to be configured", "error", err)
+	}
+
+	channel := fmt.Sprintf("__keyevent@%d__:expired", dm.redisConfig.DB)
+	pubsub := dm.RedisClient.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			if strings.HasPrefix(message.Payload, RouteKeyPrefix) {
+				handler(message.Payload)
+			}
+		}
+	}
+}
+
+// enableExpiredEvents adds expired key events to the notify-keyspace-events
+// setting without dropping flags that are already enabled
+func (dm *DatabaseManager) enableExpiredEvents(ctx context.Context) error {
+	current, err := dm.RedisClient.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+
+	flags := current["notify-keyspace-events"]
+	if strings.Contains(flags, "x") && (strings.Contains(flags, "E") || strings.Contains(flags, "A")) {
+		return nil
+	}
+
+	return dm.RedisClient.ConfigSet(ctx, "notify-keyspace-events", flags+expiredEventsConfig).Err()
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoadRuntimeSettings returns the runtime settings stored in Redis, or nil
+// if none were saved
+func (dm *DatabaseManager) LoadRuntimeSettings(ctx context.Context) (*types.RuntimeSettings, error) {
+	data, err := dm.RedisClient.Get(ctx, dm.redisConfig.SettingsKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runtime settings: %w", err)
+	}
+	var settings types.RuntimeSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode runtime settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SaveRuntimeSettings stores the runtime settings and announces the change
+// to every instance
+func (dm *DatabaseManager) SaveRuntimeSettings(ctx context.Context, settings types.RuntimeSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode runtime settings: %w", err)
+	}
+	pipe := dm.RedisClient.TxPipeline()
+	pipe.Set(ctx, dm.redisConfig.SettingsKey, data, 0)
+	pipe.Publish(ctx, dm.redisConfig.SettingsChannel, settings.UpdatedAt.UnixMilli())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save runtime settings: %w", err)
+	}
+	return nil
+}
+
+// WatchRuntimeSettings calls handler whenever an instance announces changed
+// runtime settings, until ctx is done
+func (dm *DatabaseManager) WatchRuntimeSettings(ctx context.Context, handler func()) error {
+	channel := dm.redisConfig.SettingsChannel
+	pubsub := dm.RedisClient.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handler()
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CurrentTripSchemaVersion is the latest trip document schema version
+const CurrentTripSchemaVersion = 5
+
+// TripEncoder converts a trip into the document layout of a schema version
+type TripEncoder func(trip types.Trip) bson.M
+
+// TripMigration upgrades a document from one schema version to the next
+type TripMigration func(doc bson.M) (bson.M, error)
+
+// tripEncoders registers a document encoder for every supported schema
+// version, so a rollout can keep writing an older layout while readers catch up
+var tripEncoders = map[int]TripEncoder{
+	1: encodeTripV1,
+	2: encodeTripV2,
+	3: encodeTripV3,
+	4: encodeTripV4,
+	5: encodeTripV5,
+}
+
+// tripMigrations upgrades documents from the keyed version to the next one
+var tripMigrations = map[int]TripMigration{
+	1: migrateTripV1ToV2,
+	2: migrateTripV2ToV3,
+	3: migrateTripV3ToV4,
+	4: migrateTripV4ToV5,
+}
+
+// EncodeTrip converts a trip into a document using the given schema version
+func EncodeTrip(trip types.Trip, version int) (bson.M, error) {
+	encoder, ok := tripEncoders[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported trip schema version %d", version)
+	}
+	doc := encoder(trip)
+	if trip.VehicleID != "" {
+		doc["vehicleId"] = trip.VehicleID
+	}
+	// The vehicle, archive location, visited zones, speeding violations,
+	// stop visits, telemetry summary, adherence score, load profile, safety
+	// events, idle periods, quality score, and zone time are optional in
+	// every schema version
+	if trip.RawArchiveURL != "" {
+		doc["rawArchiveUrl"] = trip.RawArchiveURL
+	}
+	if len(trip.Zones) > 0 {
+		doc["zones"] = trip.Zones
+	}
+	if len(trip.SpeedingViolations) > 0 {
+		doc["speedingViolations"] = trip.SpeedingViolations
+	}
+	if len(trip.StopVisits) > 0 {
+		doc["stopVisits"] = trip.StopVisits
+	}
+	if trip.Telemetry != nil {
+		doc["telemetry"] = trip.Telemetry
+	}
+	if trip.Adherence != nil {
+		doc["adherence"] = trip.Adherence
+	}
+	if trip.LoadProfile != nil {
+		doc["loadProfile"] = trip.LoadProfile
+	}
+	if trip.Safety != nil {
+		doc["safety"] = trip.Safety
+	}
+	if trip.Idle != nil {
+		doc["idle"] = trip.Idle
+	}
+	if trip.Quality != nil {
+		doc["quality"] = trip.Quality
+	}
+	if len(trip.ZoneTime) > 0 {
+		doc["zoneTime"] = trip.ZoneTime
+	}
+	return doc, nil
+}
+
+// encodeTripV1 writes the original trip layout with a plain point list
+func encodeTripV1(trip types.Trip) bson.M {
+	simplifiedRoute := make([]bson.M, 0, len(trip.SimplifiedRoute))
+	for _, location := range trip.SimplifiedRoute {
+		simplifiedRoute = append(simplifiedRoute, bson.M{
+			"latitude":  location.Latitude,
+			"longitude": location.Longitude,
+		})
+	}
+
+	return bson.M{
+		"_id":                   trip.ID,
+		"schemaVersion":         1,
+		"driverId":              trip.DriverID,
+		"currentRouteId":        trip.CurrentRouteID,
+		"simplifiedRoute":       simplifiedRoute,
+		"timestamp":             trip.Timestamp,
+		"originalPointsCount":   trip.OriginalPointsCount,
+		"simplifiedPointsCount": trip.SimplifiedPointsCount,
+		"compressionRatio":      trip.CompressionRatio,
+		"reductionPercent":      trip.ReductionPercent,
+	}
+}
+
+// encodeTripV2 adds the GeoJSON route geometry and trip statistics
+func encodeTripV2(trip types.Trip) bson.M {
+	doc := encodeTripV1(trip)
+	doc["schemaVersion"] = 2
+	doc[GeoRouteField] = algorithm.ToGeoJSON(types.RouteLocations(trip.SimplifiedRoute))
+	doc["stats"] = trip.Stats
+	return doc
+}
+
+// encodeTripV3 adds the createdAt date used for retention
+func encodeTripV3(trip types.Trip) bson.M {
+	doc := encodeTripV2(trip)
+	doc["schemaVersion"] = 3
+	doc["createdAt"] = trip.CreatedAt
+	return doc
+}
+
+// encodeTripV4 adds the status telling finished trips from auto-closed ones
+func encodeTripV4(trip types.Trip) bson.M {
+	doc := encodeTripV3(trip)
+	doc["schemaVersion"] = 4
+	doc["status"] = trip.Status
+	return doc
+}
+
+// encodeTripV5 stamps the simplified route points with the device timestamps
+// of the raw points they were kept from
+func encodeTripV5(trip types.Trip) bson.M {
+	doc := encodeTripV4(trip)
+	doc["schemaVersion"] = 5
+	simplifiedRoute := make([]bson.M, 0, len(trip.SimplifiedRoute))
+	for _, point := range trip.SimplifiedRoute {
+		encoded := bson.M{
+			"latitude":  point.Latitude,
+			"longitude": point.Longitude,
+		}
+		if point.Timestamp > 0 {
+			encoded["timestamp"] = int64(point.Timestamp)
+		}
+		simplifiedRoute = append(simplifiedRoute, encoded)
+	}
+	doc["simplifiedRoute"] = simplifiedRoute
+	return doc
+}
+
+// migrateTripV1ToV2 derives the GeoJSON geometry from the point list.
+// Statistics cannot be recomputed from a simplified route and are left unset.
+func migrateTripV1ToV2(doc bson.M) (bson.M, error) {
+	if _, ok := doc[GeoRouteField]; !ok {
+		points, _ := doc["simplifiedRoute"].(bson.A)
+		locations := make([]types.Location, 0, len(points))
+		for _, raw := range points {
+			point, ok := raw.(bson.M)
+			if !ok {
+				return nil, fmt.Errorf("unexpected simplifiedRoute point %v", raw)
+			}
+			latitude, latOK := point["latitude"].(float64)
+			longitude, lonOK := point["longitude"].(float64)
+			if !latOK || !lonOK {
+				return nil, fmt.Errorf("invalid simplifiedRoute point %v", point)
+			}
+			locations = append(locations, types.Location{Latitude: latitude, Longitude: longitude})
+		}
+		if geometry := algorithm.ToGeoJSON(locations); geometry != nil {
+			doc[GeoRouteField] = geometry
+		}
+	}
+
+	doc["schemaVersion"] = 2
+	return doc, nil
+}
+
+// migrateTripV2ToV3 backfills createdAt from the trip's millisecond timestamp
+func migrateTripV2ToV3(doc bson.M) (bson.M, error) {
+	if _, ok := doc["createdAt"]; !ok {
+		var millis int64
+		switch timestamp := doc["timestamp"].(type) {
+		case int64:
+			millis = timestamp
+		case int32:
+			millis = int64(timestamp)
+		case float64:
+			millis = int64(timestamp)
+		}
+
+		createdAt := time.Now().UTC()
+		if millis > 0 {
+			createdAt = time.UnixMilli(millis).UTC()
+		}
+		doc["createdAt"] = createdAt
+	}
+
+	doc["schemaVersion"] = 3
+	return doc, nil
+}
+
+// migrateTripV3ToV4 marks older trips as finished, since trips were only
+// stored on a "finished" message before the janitor existed
+func migrateTripV3ToV4(doc bson.M) (bson.M, error) {
+	if _, ok := doc["status"]; !ok {
+		doc["status"] = "finished"
+	}
+
+	doc["schemaVersion"] = 4
+	return doc, nil
+}
+
+// migrateTripV4ToV5 only bumps the version: the raw point timestamps are
+// gone once a trip is stored, so older routes stay unstamped
+func migrateTripV4ToV5(doc bson.M) (bson.M, error) {
+	doc["schemaVersion"] = 5
+	return doc, nil
+}
+
+// documentSchemaVersion returns the schema version of a stored document.
+// Documents written before versioning was introduced are version 1.
+func documentSchemaVersion(doc bson.M) int {
+	switch version := doc["schemaVersion"].(type) {
+	case int:
+		return version
+	case int32:
+		return int(version)
+	case int64:
+		return int(version)
+	case float64:
+		return int(version)
+	default:
+		return 1
+	}
+}
+
+// MigrateTrip upgrades a document to the current schema version
+func MigrateTrip(doc bson.M) (bson.M, error) {
+	for version := documentSchemaVersion(doc); version < CurrentTripSchemaVersion; version++ {
+		migration, ok := tripMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration registered from trip schema version %d", version)
+		}
+
+		var err error
+		doc, err = migration(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate trip from schema version %d: %w", version, err)
+		}
+	}
+	return doc, nil
+}
+
+// MigrateTrips upgrades every stored trip older than the current schema
+// version in place and returns the number of migrated documents
+func (dm *DatabaseManager) MigrateTrips(ctx context.Context) (int, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"schemaVersion": bson.M{"$exists": false}},
+		bson.M{"schemaVersion": bson.M{"$lt": CurrentTripSchemaVersion}},
+	}}
+
+	cursor, err := dm.collections().Trips.Find(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find trips to migrate: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return migrated, fmt.Errorf("failed to decode trip: %w", err)
+		}
+
+		// Only replace the document if no one upgraded it concurrently
+		replaceFilter := bson.M{"_id": doc["_id"], "schemaVersion": bson.M{"$exists": false}}
+		if version, ok := doc["schemaVersion"]; ok {
+			replaceFilter["schemaVersion"] = version
+		}
+
+		upgraded, err := MigrateTrip(doc)
+		if err != nil {
+			return migrated, fmt.Errorf("trip %v: %w", replaceFilter["_id"], err)
+		}
+
+		if _, err := dm.collections().Trips.ReplaceOne(ctx, replaceFilter, upgraded); err != nil {
+			return migrated, fmt.Errorf("failed to replace trip %v: %w", replaceFilter["_id"], err)
+		}
+		migrated++
+	}
+
+	if err := cursor.Err(); err != nil {
+		return migrated, fmt.Errorf("failed to iterate trips: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Migrated trips", "trips", migrated, "schemaVersion", CurrentTripSchemaVersion)
+	return migrated, nil
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEncodeTrip_CurrentVersion(t *testing.T) {
+	trip := types.Trip{
+		ID:       "trip_001",
+		DriverID: "driver_001",
+		SimplifiedRoute: []types.RoutePoint{
+			{Location: types.Location{Latitude: 0.0, Longitude: 0.0}},
+			{Location: types.Location{Latitude: 1.0, Longitude: 1.0}},
+		},
+	}
+
+	doc, err := EncodeTrip(trip, CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if doc["schemaVersion"] != CurrentTripSchemaVersion {
+		t.Errorf("Expected schema version %d, got %v", CurrentTripSchemaVersion, doc["schemaVersion"])
+	}
+	if doc[GeoRouteField] == nil {
+		t.Errorf("Expected %s to be set", GeoRouteField)
+	}
+}
+
+func TestEncodeTrip_StampsRoutePoints(t *testing.T) {
+	trip := types.Trip{
+		SimplifiedRoute: []types.RoutePoint{
+			{Location: types.Location{Latitude: 0.0, Longitude: 0.0}, Timestamp: 1640995200000},
+			{Location: types.Location{Latitude: 1.0, Longitude: 1.0}},
+		},
+	}
+
+	current, err := EncodeTrip(trip, 5)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	route := current["simplifiedRoute"].([]bson.M)
+	if route[0]["timestamp"] != int64(1640995200000) {
+		t.Errorf("Expected the first point stamped, got %v", route[0])
+	}
+	if _, ok := route[1]["timestamp"]; ok {
+		t.Errorf("Expected no timestamp on an unstamped point, got %v", route[1])
+	}
+
+	older, err := EncodeTrip(trip, 4)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := older["simplifiedRoute"].([]bson.M)[0]["timestamp"]; ok {
+		t.Error("Expected schema version 4 to keep unstamped points")
+	}
+}
+
+func TestEncodeTrip_UnsupportedVersion(t *testing.T) {
+	if _, err := EncodeTrip(types.Trip{}, 99); err == nil {
+		t.Errorf("Expected error for unsupported schema version")
+	}
+}
+
+func TestMigrateTrip_LegacyDocument(t *testing.T) {
+	doc := bson.M{
+		"_id":      "trip_001",
+		"driverId": "driver_001",
+		"simplifiedRoute": bson.A{
+			bson.M{"latitude": 0.0, "longitude": 0.0},
+			bson.M{"latitude": 1.0, "longitude": 1.0},
+		},
+	}
+
+	migrated, err := MigrateTrip(doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if documentSchemaVersion(migrated) != CurrentTripSchemaVersion {
+		t.Errorf("Expected schema version %d, got %v", CurrentTripSchemaVersion, migrated["schemaVersion"])
+	}
+
+	geometry, ok := migrated[GeoRouteField].(*types.GeoJSONGeometry)
+	if !ok || geometry.Type != "LineString" {
+		t.Errorf("Expected LineString geometry, got %v", migrated[GeoRouteField])
+	}
+}
+
+func TestMigrateTrip_InvalidPoint(t *testing.T) {
+	doc := bson.M{
+		"simplifiedRoute": bson.A{bson.M{"latitude": "north"}},
+	}
+
+	if _, err := MigrateTrip(doc); err == nil {
+		t.Errorf("Expected error for invalid point")
+	}
+}
+
+func TestMigrateTrip_BackfillsCreatedAt(t *testing.T) {
+	doc := bson.M{
+		"schemaVersion": int32(2),
+		"timestamp":     int64(1640995200000),
+	}
+
+	migrated, err := MigrateTrip(doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	createdAt, ok := migrated["createdAt"].(time.Time)
+	if !ok || createdAt.UnixMilli() != 1640995200000 {
+		t.Errorf("Expected createdAt from timestamp, got %v", migrated["createdAt"])
+	}
+}
+
+func TestMigrateTrip_BackfillsStatus(t *testing.T) {
+	doc := bson.M{"schemaVersion": int32(3)}
+
+	migrated, err := MigrateTrip(doc)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if migrated["status"] != "finished" {
+		t.Errorf("Expected older trips to be finished, got %v", migrated["status"])
+	}
+
+	encoded, _ := EncodeTrip(types.Trip{Status: "auto_closed"}, CurrentTripSchemaVersion)
+	if encoded["status"] != "auto_closed" {
+		t.Errorf("Expected the status to be encoded, got %v", encoded["status"])
+	}
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"data-ingestion-microservice/secrets"
+	"data-ingestion-microservice/types"
+)
+
+// mongoConnectTimeout bounds connecting to MongoDB with rotated credentials
+const mongoConnectTimeout = 10 * time.Second
+
+// mongoDrainTimeout bounds how long the MongoDB client replaced after a
+// rotation waits for its operations in flight before closing
+const mongoDrainTimeout = 30 * time.Second
+
+// watchSecrets keeps refreshing the credentials from the secrets provider,
+// starting from the ones already loaded into config, so that new Redis and
+// MQTT connections authenticate with rotated credentials and MongoDB is
+// reconnected with them
+func (dm *DatabaseManager) watchSecrets(config types.Config) error {
+	provider, err := secrets.NewProvider(config.Secrets)
+	if err != nil {
+		return err
+	}
+	dm.credentials = secrets.NewStore(provider, map[string]string{
+		secrets.RedisPassword:   config.Redis.Password,
+		secrets.MongoDBUsername: config.MongoDB.Username,
+		secrets.MongoDBPassword: config.MongoDB.Password,
+		secrets.MQTTUsername:    config.MQTT.Username,
+		secrets.MQTTPassword:    config.MQTT.Password,
+	})
+
+	ctx, cancel := context.WithCancel(dm.ctx)
+	dm.stopSecrets = cancel
+	dm.secretsDone.Add(1)
+	go func() {
+		defer dm.secretsDone.Done()
+		// The MongoDB driver authenticates new connections with the
+		// credentials it was created with, so rotating them takes a new
+		// client. A failed reconnect is retried after the next refresh.
+		reconnect := false
+		dm.credentials.Watch(ctx, config.Secrets.RefreshInterval, func(changed []string) {
+			if slices.Contains(changed, secrets.MongoDBUsername) || slices.Contains(changed, secrets.MongoDBPassword) {
+				reconnect = true
+			}
+			if !reconnect {
+				return
+			}
+			if err := dm.reconnectMongo(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to reconnect to MongoDB with rotated credentials, keeping the current client", "error", err)
+				return
+			}
+			reconnect = false
+		})
+	}()
+	return nil
+}
+
+// reconnectMongo connects a new MongoDB client with the latest credentials
+// and swaps it in. The previous client is disconnected once its operations
+// in flight have finished, or after mongoDrainTimeout.
+func (dm *DatabaseManager) reconnectMongo(ctx context.Context) error {
+	config := dm.mongoConfig.MongoDB
+	config.Username = dm.credentials.Get(secrets.MongoDBUsername)
+	config.Password = dm.credentials.Get(secrets.MongoDBPassword)
+
+	connectCtx, cancel := context.WithTimeout(ctx, mongoConnectTimeout)
+	defer cancel()
+	client, err := ConnectMongo(connectCtx, config)
+	if err != nil {
+		return err
+	}
+
+	appConfig := dm.mongoConfig
+	appConfig.MongoDB = config
+	previous := dm.mongo.Swap(openMongoCollections(client, appConfig))
+	slog.InfoContext(ctx, "Reconnected to MongoDB with rotated credentials")
+
+	dm.secretsDone.Add(1)
+	go func() {
+		defer dm.secretsDone.Done()
+		drainCtx, cancel := context.WithTimeout(context.Background(), mongoDrainTimeout)
+		defer cancel()
+		if err := previous.Client.Disconnect(drainCtx); err != nil {
+			slog.Warn("Failed to disconnect the previous MongoDB client", "error", err)
+		}
+	}()
+	return nil
+}
+
+// redisCredentials returns the Redis username and latest password
+func (dm *DatabaseManager) redisCredentials(username string) func() (string, string) {
+	return func() (string, string) {
+		return username, dm.credentials.Get(secrets.RedisPassword)
+	}
+}
+
+// mqttCredentials returns the latest MQTT username and password
+func (dm *DatabaseManager) mqttCredentials() (string, string) {
+	return dm.credentials.Get(secrets.MQTTUsername), dm.credentials.Get(secrets.MQTTPassword)
+}
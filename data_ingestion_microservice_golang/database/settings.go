@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// simplificationSettingsID is the settings document holding the route
+// simplification override
+const simplificationSettingsID = "simplification"
+
+// LoadSimplificationSettings returns the persisted simplification override,
+// or nil if none was saved
+func (dm *DatabaseManager) LoadSimplificationSettings(ctx context.Context) (*types.SimplificationSettings, error) {
+	var settings types.SimplificationSettings
+	err := dm.collections().Settings.FindOne(ctx, bson.M{"_id": simplificationSettingsID}).Decode(&settings)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load simplification settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SaveSimplificationSettings persists the simplification override
+func (dm *DatabaseManager) SaveSimplificationSettings(ctx context.Context, settings types.SimplificationSettings) error {
+	_, err := dm.collections().Settings.ReplaceOne(ctx,
+		bson.M{"_id": simplificationSettingsID},
+		settings,
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save simplification settings: %w", err)
+	}
+	return nil
+}
+
+// LoadRouteOverrides returns the per-route simplification overrides stored
+// in the route settings collection, one document per pattern
+func (dm *DatabaseManager) LoadRouteOverrides(ctx context.Context) ([]types.RouteSimplificationOverride, error) {
+	cursor, err := dm.collections().RouteSettings.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load route settings: %w", err)
+	}
+	var overrides []types.RouteSimplificationOverride
+	if err := cursor.All(ctx, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to decode route settings: %w", err)
+	}
+	return overrides, nil
+}
+
+// LoadSimplificationSettings returns the persisted simplification override,
+// or nil if none was saved
+func (s *BoltTripStore) LoadSimplificationSettings(ctx context.Context) (*types.SimplificationSettings, error) {
+	var settings *types.SimplificationSettings
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(boltSettingsBucket).Get([]byte(simplificationSettingsID))
+		if data == nil {
+			return nil
+		}
+		settings = &types.SimplificationSettings{}
+		return json.Unmarshal(data, settings)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load simplification settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SaveSimplificationSettings persists the simplification override
+func (s *BoltTripStore) SaveSimplificationSettings(ctx context.Context, settings types.SimplificationSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal simplification settings: %w", err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltSettingsBucket).Put([]byte(simplificationSettingsID), data)
+	})
+}
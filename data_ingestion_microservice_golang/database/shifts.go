@@ -0,0 +1,335 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultShiftPageSize is the number of shifts returned when a query has no
+// limit
+const defaultShiftPageSize = 100
+
+// ShiftID identifies the shift a driver started at the given time, so a
+// redelivered shift_start opens the same shift
+func ShiftID(driverID string, startedAt time.Time) string {
+	return fmt.Sprintf("%s:%d", driverID, startedAt.UnixMilli())
+}
+
+// InferredShiftID identifies the inferred shift of a driver on a day
+func InferredShiftID(driverID, day string) string {
+	return fmt.Sprintf("%s:%s", driverID, day)
+}
+
+// shiftLimit returns the page size of a shift query
+func shiftLimit(query types.ShiftQuery) int {
+	if query.Limit <= 0 {
+		return defaultShiftPageSize
+	}
+	return query.Limit
+}
+
+// shiftCovers reports whether a trip starting at t belongs to a started
+// shift
+func shiftCovers(shift types.Shift, t time.Time) bool {
+	return !shift.Inferred && !shift.StartedAt.After(t) && (shift.EndedAt == nil || !shift.EndedAt.Before(t))
+}
+
+// addShiftTrip counts a trip in a shift unless it already is, widening
+// inferred shifts to span it
+func addShiftTrip(shift *types.Shift, trip types.ShiftTrip) {
+	for _, counted := range shift.Trips {
+		if counted.TripID == trip.TripID {
+			return
+		}
+	}
+	shift.Trips = append(shift.Trips, trip)
+	shift.TripCount++
+	shift.DistanceMeters += trip.DistanceMeters
+	shift.DrivingSeconds += trip.DrivingSeconds
+
+	if shift.Inferred {
+		if shift.StartedAt.IsZero() || trip.StartedAt.Before(shift.StartedAt) {
+			shift.StartedAt = trip.StartedAt
+		}
+		if shift.EndedAt == nil || trip.EndedAt.After(*shift.EndedAt) {
+			endedAt := trip.EndedAt
+			shift.EndedAt = &endedAt
+		}
+	}
+}
+
+// StartShift opens a shift for a driver, closing the shifts they left open
+// at its start
+func (dm *DatabaseManager) StartShift(ctx context.Context, driverID string, startedAt time.Time) error {
+	_, err := dm.collections().Shifts.UpdateMany(ctx,
+		bson.M{"driverId": driverID, "inferred": false, "endedAt": nil, "startedAt": bson.M{"$lt": startedAt}},
+		bson.M{"$set": bson.M{"endedAt": startedAt}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close open shifts of driver %s: %w", driverID, err)
+	}
+
+	_, err = dm.collections().Shifts.UpdateOne(ctx,
+		bson.M{"_id": ShiftID(driverID, startedAt)},
+		bson.M{"$setOnInsert": bson.M{
+			"driverId":       driverID,
+			"inferred":       false,
+			"startedAt":      startedAt,
+			"trips":          []types.ShiftTrip{},
+			"tripCount":      0,
+			"distanceMeters": 0.0,
+			"drivingSeconds": 0.0,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start shift of driver %s: %w", driverID, err)
+	}
+	return nil
+}
+
+// EndShift closes the latest open shift of a driver, reporting whether one
+// was open
+func (dm *DatabaseManager) EndShift(ctx context.Context, driverID string, endedAt time.Time) (bool, error) {
+	err := dm.collections().Shifts.FindOneAndUpdate(ctx,
+		bson.M{"driverId": driverID, "inferred": false, "endedAt": nil, "startedAt": bson.M{"$lte": endedAt}},
+		bson.M{"$set": bson.M{"endedAt": endedAt}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "startedAt", Value: -1}}),
+	).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to end shift of driver %s: %w", driverID, err)
+	}
+	return true, nil
+}
+
+// AddShiftTrip counts a trip in the driver's shift that was open when it
+// started, or else in their inferred shift of the day. A trip is counted
+// once however often it is added.
+func (dm *DatabaseManager) AddShiftTrip(ctx context.Context, driverID, day string, trip types.ShiftTrip) error {
+	var started struct {
+		ID string `bson:"_id"`
+	}
+	err := dm.collections().Shifts.FindOne(ctx,
+		bson.M{
+			"driverId":  driverID,
+			"inferred":  false,
+			"startedAt": bson.M{"$lte": trip.StartedAt},
+			"$or":       bson.A{bson.M{"endedAt": nil}, bson.M{"endedAt": bson.M{"$gte": trip.StartedAt}}},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "startedAt", Value: -1}}).SetProjection(bson.M{"_id": 1}),
+	).Decode(&started)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("failed to find shift of trip %s: %w", trip.TripID, err)
+	}
+
+	count := bson.M{
+		"$push": bson.M{"trips": trip},
+		"$inc":  bson.M{"tripCount": 1, "distanceMeters": trip.DistanceMeters, "drivingSeconds": trip.DrivingSeconds},
+	}
+	if err == nil {
+		_, err = dm.collections().Shifts.UpdateOne(ctx, bson.M{"_id": started.ID, "trips.tripId": bson.M{"$ne": trip.TripID}}, count)
+	} else {
+		count["$setOnInsert"] = bson.M{"driverId": driverID, "inferred": true, "day": day}
+		count["$min"] = bson.M{"startedAt": trip.StartedAt}
+		count["$max"] = bson.M{"endedAt": trip.EndedAt}
+		_, err = dm.collections().Shifts.UpdateOne(ctx,
+			bson.M{"_id": InferredShiftID(driverID, day), "trips.tripId": bson.M{"$ne": trip.TripID}},
+			count,
+			options.Update().SetUpsert(true),
+		)
+		// The upsert collides with the shift when it already counts the trip
+		if mongo.IsDuplicateKeyError(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add trip %s to shift: %w", trip.TripID, err)
+	}
+	return nil
+}
+
+// FindShifts returns the shifts of a driver, newest first
+func (dm *DatabaseManager) FindShifts(ctx context.Context, query types.ShiftQuery) ([]types.Shift, error) {
+	filter := bson.M{"driverId": query.DriverID}
+	startedAt := bson.M{}
+	if query.From > 0 {
+		startedAt["$gte"] = time.UnixMilli(query.From)
+	}
+	if query.To > 0 {
+		startedAt["$lt"] = time.UnixMilli(query.To)
+	}
+	if len(startedAt) > 0 {
+		filter["startedAt"] = startedAt
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: -1}}).
+		SetLimit(int64(shiftLimit(query)))
+	cursor, err := dm.collections().Shifts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shifts of driver %s: %w", query.DriverID, err)
+	}
+	shifts := []types.Shift{}
+	if err := cursor.All(ctx, &shifts); err != nil {
+		return nil, fmt.Errorf("failed to decode shifts: %w", err)
+	}
+	return shifts, nil
+}
+
+// StartShift opens a shift for a driver, closing the shifts they left open
+// at its start
+func (s *BoltTripStore) StartShift(ctx context.Context, driverID string, startedAt time.Time) error {
+	err := s.updateShifts(driverID, func(shifts map[string]*types.Shift) {
+		for _, shift := range shifts {
+			if !shift.Inferred && shift.EndedAt == nil && shift.StartedAt.Before(startedAt) {
+				endedAt := startedAt
+				shift.EndedAt = &endedAt
+			}
+		}
+		id := ShiftID(driverID, startedAt)
+		if _, ok := shifts[id]; !ok {
+			shifts[id] = &types.Shift{ID: id, DriverID: driverID, StartedAt: startedAt, Trips: []types.ShiftTrip{}}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start shift of driver %s: %w", driverID, err)
+	}
+	return nil
+}
+
+// EndShift closes the latest open shift of a driver, reporting whether one
+// was open
+func (s *BoltTripStore) EndShift(ctx context.Context, driverID string, endedAt time.Time) (bool, error) {
+	ended := false
+	err := s.updateShifts(driverID, func(shifts map[string]*types.Shift) {
+		var latest *types.Shift
+		for _, shift := range shifts {
+			if !shift.Inferred && shift.EndedAt == nil && !shift.StartedAt.After(endedAt) &&
+				(latest == nil || shift.StartedAt.After(latest.StartedAt)) {
+				latest = shift
+			}
+		}
+		if latest != nil {
+			latest.EndedAt = &endedAt
+			ended = true
+		}
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to end shift of driver %s: %w", driverID, err)
+	}
+	return ended, nil
+}
+
+// AddShiftTrip counts a trip in the driver's shift that was open when it
+// started, or else in their inferred shift of the day
+func (s *BoltTripStore) AddShiftTrip(ctx context.Context, driverID, day string, trip types.ShiftTrip) error {
+	err := s.updateShifts(driverID, func(shifts map[string]*types.Shift) {
+		var covering *types.Shift
+		for _, shift := range shifts {
+			if shiftCovers(*shift, trip.StartedAt) && (covering == nil || shift.StartedAt.After(covering.StartedAt)) {
+				covering = shift
+			}
+		}
+		if covering == nil {
+			id := InferredShiftID(driverID, day)
+			if covering = shifts[id]; covering == nil {
+				covering = &types.Shift{ID: id, DriverID: driverID, Inferred: true, Day: day}
+				shifts[id] = covering
+			}
+		}
+		addShiftTrip(covering, trip)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add trip %s to shift: %w", trip.TripID, err)
+	}
+	return nil
+}
+
+// FindShifts returns the shifts of a driver, newest first
+func (s *BoltTripStore) FindShifts(ctx context.Context, query types.ShiftQuery) ([]types.Shift, error) {
+	shifts := []types.Shift{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return forEachDriverShift(tx.Bucket(boltShiftsBucket), query.DriverID, func(shift types.Shift, data []byte) {
+			started := shift.StartedAt.UnixMilli()
+			if (query.From <= 0 || started >= query.From) && (query.To <= 0 || started < query.To) {
+				shifts = append(shifts, shift)
+			}
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shifts of driver %s: %w", query.DriverID, err)
+	}
+
+	slices.SortFunc(shifts, func(a, b types.Shift) int { return b.StartedAt.Compare(a.StartedAt) })
+	if limit := shiftLimit(query); len(shifts) > limit {
+		shifts = shifts[:limit]
+	}
+	return shifts, nil
+}
+
+// updateShifts applies a change to the shifts of a driver in one
+// transaction and stores the shifts it changed or added
+func (s *BoltTripStore) updateShifts(driverID string, update func(shifts map[string]*types.Shift)) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltShiftsBucket)
+
+		shifts := make(map[string]*types.Shift)
+		before := make(map[string]string)
+		err := forEachDriverShift(bucket, driverID, func(shift types.Shift, data []byte) {
+			shifts[shift.ID] = &shift
+			before[shift.ID] = string(data)
+		})
+		if err != nil {
+			return err
+		}
+
+		update(shifts)
+
+		for id, shift := range shifts {
+			data, err := json.Marshal(shift)
+			if err != nil {
+				return fmt.Errorf("failed to marshal shift %s: %w", id, err)
+			}
+			if before[id] == string(data) {
+				continue
+			}
+			if err := bucket.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// forEachDriverShift calls fn with every shift of a driver. Shift IDs start
+// with the driver ID, so only the keys with that prefix are read.
+func forEachDriverShift(bucket *bbolt.Bucket, driverID string, fn func(shift types.Shift, data []byte)) error {
+	prefix := []byte(driverID + ":")
+	cursor := bucket.Cursor()
+	for id, data := cursor.Seek(prefix); id != nil && bytes.HasPrefix(id, prefix); id, data = cursor.Next() {
+		var shift types.Shift
+		if err := json.Unmarshal(data, &shift); err != nil {
+			return fmt.Errorf("shift %s: %w", id, err)
+		}
+		// Other drivers' IDs may start with this one and a colon
+		if shift.DriverID == driverID {
+			fn(shift, data)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"go.etcd.io/bbolt"
+)
+
+// boltSpillBucket holds the messages spilled by the ingest queue
+var boltSpillBucket = []byte("spilled_messages")
+
+// ErrSpillBufferFull is returned when the spill buffer holds its maximum
+// number of messages
+var ErrSpillBufferFull = errors.New("spill buffer is full")
+
+// SpilledMessage is an MQTT message kept on disk until it can be processed
+type SpilledMessage struct {
+	Key     []byte `json:"-"`
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+}
+
+// SpillBuffer is a FIFO queue of MQTT messages in a BoltDB file. It absorbs
+// bursts the in-memory ingest queue cannot hold, and messages left in it at
+// shutdown are processed after the next start.
+type SpillBuffer struct {
+	db          *bbolt.DB
+	maxMessages int64
+	length      atomic.Int64
+}
+
+// OpenSpillBuffer opens (or creates) the spill buffer at path, holding at
+// most maxMessages messages, or any number if maxMessages is zero
+func OpenSpillBuffer(path string, maxMessages int) (*SpillBuffer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spill buffer %s: %w", path, err)
+	}
+
+	buffer := &SpillBuffer{db: db, maxMessages: int64(maxMessages)}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltSpillBucket)
+		if err != nil {
+			return err
+		}
+		buffer.length.Store(int64(bucket.Stats().KeyN))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create spill buffer bucket: %w", err)
+	}
+	return buffer, nil
+}
+
+// Push appends a message to the end of the buffer
+func (b *SpillBuffer) Push(topic string, payload []byte) error {
+	if b.maxMessages > 0 && b.length.Load() >= b.maxMessages {
+		return ErrSpillBufferFull
+	}
+	data, err := json.Marshal(SpilledMessage{Topic: topic, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spilled message: %w", err)
+	}
+
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltSpillBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return bucket.Put(key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to spill message: %w", err)
+	}
+	b.length.Add(1)
+	return nil
+}
+
+// Oldest returns the message at the front of the buffer without removing
+// it, or false if the buffer is empty
+func (b *SpillBuffer) Oldest() (SpilledMessage, bool, error) {
+	return b.OldestWhere(nil)
+}
+
+// OldestWhere returns the oldest message that eligible accepts without
+// removing it, or false if there is none. A nil eligible accepts every
+// message.
+func (b *SpillBuffer) OldestWhere(eligible func(SpilledMessage) bool) (SpilledMessage, bool, error) {
+	var message SpilledMessage
+	var found bool
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(boltSpillBucket).Cursor()
+		for key, data := cursor.First(); key != nil; key, data = cursor.Next() {
+			var candidate SpilledMessage
+			if err := json.Unmarshal(data, &candidate); err != nil {
+				return fmt.Errorf("failed to unmarshal spilled message: %w", err)
+			}
+			if eligible != nil && !eligible(candidate) {
+				continue
+			}
+			candidate.Key = append([]byte(nil), key...)
+			message, found = candidate, true
+			return nil
+		}
+		return nil
+	})
+	return message, found, err
+}
+
+// Remove deletes a message returned by Oldest
+func (b *SpillBuffer) Remove(message SpilledMessage) error {
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltSpillBucket).Delete(message.Key)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove spilled message: %w", err)
+	}
+	b.length.Add(-1)
+	return nil
+}
+
+// Len returns the number of messages in the buffer
+func (b *SpillBuffer) Len() int64 {
+	return b.length.Load()
+}
+
+// Close closes the BoltDB file
+func (b *SpillBuffer) Close() error {
+	return b.db.Close()
+}
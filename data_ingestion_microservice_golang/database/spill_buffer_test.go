@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpillBuffer_FIFOAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spill.db")
+	buffer, err := OpenSpillBuffer(path, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, payload := range []string{"first", "second"} {
+		if err := buffer.Push("drivers_location/1", []byte(payload)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := buffer.Push("drivers_location/1", []byte("third")); !errors.Is(err, ErrSpillBufferFull) {
+		t.Errorf("Expected ErrSpillBufferFull, got %v", err)
+	}
+	buffer.Close()
+
+	// Spilled messages survive a restart
+	buffer, err = OpenSpillBuffer(path, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer buffer.Close()
+	if buffer.Len() != 2 {
+		t.Fatalf("Expected 2 spilled messages, got %d", buffer.Len())
+	}
+
+	for _, want := range []string{"first", "second"} {
+		message, ok, err := buffer.Oldest()
+		if err != nil || !ok {
+			t.Fatalf("Expected a message, got %v, %v", ok, err)
+		}
+		if string(message.Payload) != want || message.Topic != "drivers_location/1" {
+			t.Errorf("Expected %q, got %q on %q", want, message.Payload, message.Topic)
+		}
+		if err := buffer.Remove(message); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if _, ok, _ := buffer.Oldest(); ok || buffer.Len() != 0 {
+		t.Errorf("Expected an empty buffer, got %d messages", buffer.Len())
+	}
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ActiveInstanceKey holds the instance processing messages when the
+// instances run active-passive
+const ActiveInstanceKey = "cluster:active"
+
+// AcquireActive takes or renews the active lease of an instance for ttl, and
+// reports false if another instance is active
+func (dm *DatabaseManager) AcquireActive(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	held, err := acquireDriverScript.Run(ctx, dm.RedisClient, []string{ActiveInstanceKey}, instanceID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire active lease: %w", err)
+	}
+	return held == 1, nil
+}
+
+// ReleaseActive gives up the active lease of an instance, so a standby takes
+// over on its next heartbeat
+func (dm *DatabaseManager) ReleaseActive(ctx context.Context, instanceID string) error {
+	if err := releaseDriverScript.Run(ctx, dm.RedisClient, []string{ActiveInstanceKey}, instanceID).Err(); err != nil {
+		return fmt.Errorf("failed to release active lease: %w", err)
+	}
+	return nil
+}
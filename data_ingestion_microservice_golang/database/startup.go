@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/retry"
+	"data-ingestion-microservice/types"
+)
+
+// startupPingTimeout bounds each attempt to reach a backend at startup
+const startupPingTimeout = 5 * time.Second
+
+// maxBackoffAttempt caps the attempt number passed to the backoff, which
+// doubles the delay once per attempt
+const maxBackoffAttempt = 30
+
+// waitFor pings a backend until it answers or the startup retries of the
+// dependency run out, and returns the last error
+func waitFor(ctx context.Context, dependency string, config types.RetryConfig, ping func(ctx context.Context) error) error {
+	policy := retry.NewPolicy(config, func(error) bool { return true })
+	return policy.Do(ctx, "startup."+dependency, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, startupPingTimeout)
+		defer cancel()
+		return ping(attemptCtx)
+	})
+}
+
+// connectInBackground keeps pinging a backend that was still down after its
+// startup retries, waiting up to the maximum backoff between attempts, and
+// finishes its setup with ready once it answers
+func (dm *DatabaseManager) connectInBackground(dependency string, config types.RetryConfig, ping, ready func(ctx context.Context) error) {
+	policy := retry.NewPolicy(config, nil)
+
+	dm.startupDone.Add(1)
+	go func() {
+		defer dm.startupDone.Done()
+
+		for attempt := 1; ; attempt++ {
+			timer := time.NewTimer(policy.Backoff(min(attempt, maxBackoffAttempt)))
+			select {
+			case <-dm.startupCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			attemptCtx, cancel := context.WithTimeout(dm.startupCtx, startupPingTimeout)
+			err := ping(attemptCtx)
+			cancel()
+			if err == nil {
+				break
+			}
+		}
+
+		slog.Info("Connected to backend after startup", "dependency", dependency)
+		if err := ready(dm.startupCtx); err != nil && dm.startupCtx.Err() == nil {
+			slog.Error("Error setting up backend after startup", "dependency", dependency, "error", err)
+		}
+	}()
+}
+
+// startDegraded reports whether the service may start without a dependency
+// that is still down, continuing to connect to it in the background
+func startDegraded(config types.StartupConfig, dependency string, err error) bool {
+	if !config.Degraded {
+		return false
+	}
+	slog.Warn("Starting degraded while a backend is down", "dependency", dependency, "error", err)
+	return true
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+var testStartupRetry = types.RetryConfig{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestWaitFor_RetriesUntilBackendAnswers(t *testing.T) {
+	pings := 0
+	err := waitFor(context.Background(), "redis", testStartupRetry, func(ctx context.Context) error {
+		pings++
+		if pings < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || pings != 3 {
+		t.Fatalf("Expected success on the third ping, got %v after %d pings", err, pings)
+	}
+
+	pings = 0
+	err = waitFor(context.Background(), "redis", testStartupRetry, func(ctx context.Context) error {
+		pings++
+		return errors.New("connection refused")
+	})
+	if err == nil || pings != 3 {
+		t.Errorf("Expected an error after 3 pings, got %v after %d pings", err, pings)
+	}
+}
+
+func TestConnectInBackground_FinishesSetupOnceUp(t *testing.T) {
+	dm := &DatabaseManager{ctx: context.Background()}
+	dm.startupCtx, dm.stopStartup = context.WithCancel(context.Background())
+	defer dm.stopStartup()
+
+	pings := 0
+	ready := make(chan struct{})
+	dm.connectInBackground("mongodb", testStartupRetry, func(ctx context.Context) error {
+		pings++
+		if pings < 5 {
+			return errors.New("server selection timeout")
+		}
+		return nil
+	}, func(ctx context.Context) error {
+		close(ready)
+		return nil
+	})
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the setup to finish once the backend answers")
+	}
+	dm.startupDone.Wait()
+}
+
+func TestConnectInBackground_StopsOnClose(t *testing.T) {
+	dm := &DatabaseManager{ctx: context.Background()}
+	dm.startupCtx, dm.stopStartup = context.WithCancel(context.Background())
+
+	dm.connectInBackground("redis", testStartupRetry, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}, func(ctx context.Context) error {
+		t.Error("Expected no setup while the backend is down")
+		return nil
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	dm.stopStartup()
+	dm.startupDone.Wait()
+}
@@ -0,0 +1,339 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// RouteBuffer buffers in-route points until their route is finalized
+type RouteBuffer interface {
+	AppendPoint(ctx context.Context, key string, point types.TrackPoint) error
+	FlushPoints(ctx context.Context)
+	ReadPoints(ctx context.Context, key string) ([]BufferedPoint, error)
+	ClearPoints(ctx context.Context, key, lastID string) (int64, error)
+	WatchExpiredRoutes(ctx context.Context, handler func(key string)) error
+	IdleRoutes(ctx context.Context, idleFor time.Duration) ([]IdleRoute, error)
+}
+
+// TripStore persists finalized trips and their raw points
+type TripStore interface {
+	IsFinalized(ctx context.Context, id string) (bool, error)
+	SaveTrip(ctx context.Context, key string, trip types.Trip) error
+	SaveRawRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) error
+}
+
+// TripSink receives every finalized trip along with its raw points, e.g. to
+// feed an analytics store. Writes must be idempotent since finalization may
+// be retried.
+type TripSink interface {
+	WriteTrip(ctx context.Context, trip types.Trip, points []types.TrackPoint) error
+	Close() error
+}
+
+// RawArchive stores the raw points of finalized trips outside the trip
+// store and returns where they were stored
+type RawArchive interface {
+	ArchiveRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) (string, error)
+}
+
+// FinalizationQueue distributes finished routes between service instances
+type FinalizationQueue interface {
+	EnqueueFinalization(ctx context.Context, busMsg types.BusMessage) error
+	ReadFinalizations(ctx context.Context, consumer string, count int64, block time.Duration) ([]FinalizationEntry, error)
+	ClaimStaleFinalizations(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]FinalizationEntry, error)
+	AckFinalization(ctx context.Context, id string) error
+	FinalizationBacklog(ctx context.Context) (int64, error)
+}
+
+// FinalizationCheckpoints records the finalizations in progress, so ones
+// interrupted by a crash can be found and resumed after a restart
+type FinalizationCheckpoints interface {
+	BeginFinalization(ctx context.Context, checkpoint types.FinalizationCheckpoint) error
+	EndFinalization(ctx context.Context, key string) error
+	PendingFinalizations(ctx context.Context) ([]types.FinalizationCheckpoint, error)
+}
+
+// LiveTracker publishes and serves the latest position of every driver
+type LiveTracker interface {
+	UpdateLivePosition(ctx context.Context, busMsg types.BusMessage) error
+	PublishLiveLocation(ctx context.Context, busMsg types.BusMessage) error
+	LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error)
+	RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error)
+	FleetPositions(ctx context.Context) ([]types.LivePosition, error)
+	NearbyVehicles(ctx context.Context, location types.Location, radiusMeters float64, count int) ([]types.LivePosition, error)
+}
+
+// ETAStore keeps the latest stop ETAs of every vehicle on a route
+type ETAStore interface {
+	SaveETA(ctx context.Context, eta types.VehicleETA, ttl time.Duration) error
+	RemoveETA(ctx context.Context, routeID, driverID string) error
+	RouteETAs(ctx context.Context, routeID string) ([]types.VehicleETA, error)
+}
+
+// PlannedRouteStore looks up planned route geometries
+type PlannedRouteStore interface {
+	FindPlannedRoute(ctx context.Context, routeID string) (*types.PlannedRoute, error)
+}
+
+// GeofenceStore loads the geofences evaluated against live locations
+type GeofenceStore interface {
+	LoadGeofences(ctx context.Context) ([]types.Geofence, error)
+}
+
+// DeviceRegistry loads the keys devices sign their messages with
+type DeviceRegistry interface {
+	LoadDeviceKeys(ctx context.Context) ([]types.DeviceKey, error)
+}
+
+// TripQueryStore reads back stored trips and their raw points
+type TripQueryStore interface {
+	FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error)
+	FindTrip(ctx context.Context, id string) (*types.StoredTrip, error)
+	SearchTrips(ctx context.Context, query types.TripGeoQuery) ([]types.StoredTrip, error)
+	AggregateTrips(ctx context.Context, query types.TripStatsQuery) (types.TripStatsReport, error)
+	AggregateIdle(ctx context.Context, query types.TripStatsQuery) (types.IdleReport, error)
+	AggregateZoneTime(ctx context.Context, query types.TripStatsQuery) (types.ZoneTimeReport, error)
+	RawPoints(ctx context.Context, id string) ([]types.TrackPoint, error)
+}
+
+// RouteSettingsStore holds per-route simplification overrides managed
+// outside the service
+type RouteSettingsStore interface {
+	LoadRouteOverrides(ctx context.Context) ([]types.RouteSimplificationOverride, error)
+}
+
+// FeatureFlagStore holds the feature flags toggled at runtime, keyed like
+// types.FeatureFlagKey
+type FeatureFlagStore interface {
+	LoadFeatureFlags(ctx context.Context) (map[string]string, error)
+}
+
+// RuntimeSettingsStore shares runtime settings between instances and
+// notifies them of changes
+type RuntimeSettingsStore interface {
+	LoadRuntimeSettings(ctx context.Context) (*types.RuntimeSettings, error)
+	SaveRuntimeSettings(ctx context.Context, settings types.RuntimeSettings) error
+	WatchRuntimeSettings(ctx context.Context, handler func()) error
+}
+
+// FleetEventBus fans fleet events out to every instance and remembers which
+// routes have a started trip, so each trip is announced once across instances
+type FleetEventBus interface {
+	PublishFleetEvent(ctx context.Context, event types.FleetEvent) error
+	WatchFleetEvents(ctx context.Context, handler func(types.FleetEvent)) error
+	TrackFleetRoute(ctx context.Context, key string, idleAfter time.Duration) (bool, error)
+	ForgetFleetRoute(ctx context.Context, key string) error
+}
+
+// SettingsStore persists runtime setting overrides across restarts
+type SettingsStore interface {
+	LoadSimplificationSettings(ctx context.Context) (*types.SimplificationSettings, error)
+	SaveSimplificationSettings(ctx context.Context, settings types.SimplificationSettings) error
+}
+
+// MessageHistory remembers the latest messages of every driver so that
+// retransmitted messages can be recognized
+type MessageHistory interface {
+	LookupMessage(ctx context.Context, driverID, hash string) (bool, uint64, error)
+	RecordMessage(ctx context.Context, driverID, hash string, timestamp uint64) error
+}
+
+// PartitionCoordinator tracks the live service instances, the leases that
+// give one instance at a time the messages of a driver, and the lease of the
+// cluster leader
+type PartitionCoordinator interface {
+	Heartbeat(ctx context.Context, instanceID string, ttl time.Duration) ([]string, error)
+	LeaveCluster(ctx context.Context, instanceID string) error
+	AcquireDriver(ctx context.Context, driverID, instanceID string, ttl time.Duration) (bool, error)
+	ReleaseDriver(ctx context.Context, driverID, instanceID string) error
+	AcquireLeadership(ctx context.Context, instanceID string, ttl time.Duration) (bool, error)
+	ReleaseLeadership(ctx context.Context, instanceID string) error
+	PublishStatus(ctx context.Context, status types.InstanceStatus) error
+	InstanceStatuses(ctx context.Context) ([]types.InstanceStatus, error)
+}
+
+// StandbyCoordinator holds the lease of the active instance when the
+// instances run active-passive
+type StandbyCoordinator interface {
+	AcquireActive(ctx context.Context, instanceID string, ttl time.Duration) (bool, error)
+	ReleaseActive(ctx context.Context, instanceID string) error
+}
+
+// DriverDataEraser deletes everything a backend stores about a driver and
+// reports what was removed
+type DriverDataEraser interface {
+	DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error)
+}
+
+// AuditLog records administrative actions in an append-only log
+type AuditLog interface {
+	RecordAudit(ctx context.Context, entry types.AuditEntry) error
+	AuditEntries(ctx context.Context, query types.AuditQuery) ([]types.AuditEntry, error)
+}
+
+// ShiftStore keeps the shifts of drivers and the trips counted in them
+type ShiftStore interface {
+	StartShift(ctx context.Context, driverID string, startedAt time.Time) error
+	EndShift(ctx context.Context, driverID string, endedAt time.Time) (bool, error)
+	AddShiftTrip(ctx context.Context, driverID, day string, trip types.ShiftTrip) error
+	FindShifts(ctx context.Context, query types.ShiftQuery) ([]types.Shift, error)
+}
+
+// AssignmentStore keeps the log of which driver drove which vehicle when
+type AssignmentStore interface {
+	StartAssignment(ctx context.Context, driverID, vehicleID string, startedAt time.Time) error
+	FindAssignments(ctx context.Context, query types.AssignmentQuery) ([]types.VehicleAssignment, error)
+}
+
+// OdometerCounters keeps running distance counters of drivers and vehicles
+// per day
+type OdometerCounters interface {
+	AddDistance(ctx context.Context, day, driverID, vehicleID string, meters float64, ttl time.Duration) error
+	DayDistances(ctx context.Context, day string) ([]types.DailyDistance, error)
+}
+
+// OdometerStore keeps the daily distance summaries rolled up from the
+// counters
+type OdometerStore interface {
+	SaveDailyDistances(ctx context.Context, distances []types.DailyDistance) error
+	FindDailyDistances(ctx context.Context, query types.OdometerQuery) ([]types.DailyDistance, error)
+}
+
+// HeatmapStore keeps the trip point counts of geohash cells per day and week
+type HeatmapStore interface {
+	SaveHeatmapCells(ctx context.Context, period, start string, cells []types.HeatmapCell) error
+	FindHeatmapCells(ctx context.Context, period, start string) ([]types.HeatmapCell, error)
+}
+
+// WebhookDeadLetters keeps webhook deliveries that failed every attempt
+type WebhookDeadLetters interface {
+	SaveDeadLetter(ctx context.Context, letter types.WebhookDeadLetter) error
+}
+
+// MessageBroker delivers device messages and publishes events. Redeliver
+// makes the broker deliver the messages left unacknowledged again.
+type MessageBroker interface {
+	SubscribeToTopic(topic string, handler mqtt.MessageHandler) error
+	UnsubscribeFromTopic(topic string) error
+	PublishMessage(topic string, payload []byte) error
+	Redeliver() error
+}
+
+// Backends groups the storage and messaging implementations used by the
+// ingestion service, along with health checks and shutdown for all of them
+type Backends struct {
+	Buffer        RouteBuffer
+	Trips         TripStore
+	TripQueries   TripQueryStore
+	Finalizations FinalizationQueue
+	Checkpoints   FinalizationCheckpoints
+	Live          LiveTracker
+	ETAs          ETAStore
+	History       MessageHistory
+	Partitions    PartitionCoordinator
+	Standby       StandbyCoordinator
+	PlannedRoutes PlannedRouteStore
+	Geofences     GeofenceStore
+	Devices       DeviceRegistry
+	Shifts        ShiftStore
+	Assignments   AssignmentStore
+	Counters      OdometerCounters
+	Odometer      OdometerStore
+	Heatmap       HeatmapStore
+	Settings      SettingsStore
+	RouteSettings RouteSettingsStore
+	FeatureFlags  FeatureFlagStore
+	Runtime       RuntimeSettingsStore
+	FleetEvents   FleetEventBus
+	Erasers       []DriverDataEraser
+	Audit         AuditLog
+	DeadLetters   WebhookDeadLetters
+	Broker        MessageBroker
+	Sinks         []TripSink
+	Archive       RawArchive
+	Health        func(ctx context.Context) map[string]bool
+	Close         func() error
+}
+
+// Backends returns the manager's Redis, MongoDB, and MQTT implementations
+func (dm *DatabaseManager) Backends() Backends {
+	backends := Backends{
+		Buffer:        dm,
+		Trips:         dm,
+		TripQueries:   dm.TripReader,
+		Finalizations: dm,
+		Checkpoints:   dm,
+		Live:          dm,
+		ETAs:          dm,
+		Partitions:    dm,
+		Standby:       dm,
+		PlannedRoutes: dm,
+		Geofences:     dm,
+		Devices:       dm,
+		Shifts:        dm,
+		Assignments:   dm,
+		Counters:      dm,
+		Odometer:      dm,
+		Heatmap:       dm,
+		Settings:      dm,
+		RouteSettings: dm,
+		FeatureFlags:  dm,
+		Runtime:       dm,
+		FleetEvents:   dm,
+		Erasers:       []DriverDataEraser{dm},
+		Audit:         dm,
+		DeadLetters:   dm,
+		Broker:        dm,
+		Sinks:         dm.Sinks,
+		Archive:       dm.archive(),
+		Health:        dm.IsHealthy,
+		Close:         dm.Close,
+	}
+	if dm.History != nil {
+		backends.History = dm.History
+		backends.Erasers = append(backends.Erasers, dm.History)
+	}
+	return backends
+}
+
+// FlushPoints writes points still waiting in the Redis pipeline
+func (dm *DatabaseManager) FlushPoints(ctx context.Context) {
+	dm.PointWriter.Flush(ctx)
+}
+
+// IsFinalized reports whether a trip was already stored
+func (dm *DatabaseManager) IsFinalized(ctx context.Context, id string) (bool, error) {
+	return dm.TripWriter.IsFinalized(ctx, id)
+}
+
+// SaveTrip encodes a trip with the configured schema version and upserts it
+// together with its finalization marker
+func (dm *DatabaseManager) SaveTrip(ctx context.Context, key string, trip types.Trip) error {
+	doc, err := EncodeTrip(trip, dm.tripSchemaVersion)
+	if err != nil {
+		return fmt.Errorf("failed to encode trip: %w", err)
+	}
+
+	err = dm.TripWriter.Write(ctx, TripRecord{
+		ID:       trip.ID,
+		RouteKey: key,
+		Document: doc,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store trip in MongoDB: %w", err)
+	}
+	return nil
+}
+
+// archive returns the configured raw archive, or nil when archival is disabled
+func (dm *DatabaseManager) archive() RawArchive {
+	if dm.Archiver == nil {
+		return nil
+	}
+	return dm.Archiver
+}
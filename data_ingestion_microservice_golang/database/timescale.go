@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TimescaleSink writes the raw points of every finalized trip into a
+// TimescaleDB hypertable for time-series analytics
+type TimescaleSink struct {
+	pool  *pgxpool.Pool
+	table string
+}
+
+// NewTimescaleSink connects to TimescaleDB and creates the point hypertable
+// if it does not exist yet
+func NewTimescaleSink(ctx context.Context, config types.TimescaleConfig) (*TimescaleSink, error) {
+	pool, err := pgxpool.New(ctx, config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to TimescaleDB: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping TimescaleDB: %w", err)
+	}
+
+	sink := &TimescaleSink{
+		pool:  pool,
+		table: pgx.Identifier{config.Table}.Sanitize(),
+	}
+	if err := sink.ensureHypertable(ctx, config.Table); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+// ensureHypertable creates the point table and turns it into a hypertable
+// partitioned by timestamp
+func (s *TimescaleSink) ensureHypertable(ctx context.Context, table string) error {
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	driver_id TEXT NOT NULL,
+	route_id TEXT NOT NULL,
+	ts TIMESTAMPTZ NOT NULL,
+	lat DOUBLE PRECISION NOT NULL,
+	lon DOUBLE PRECISION NOT NULL,
+	speed DOUBLE PRECISION,
+	PRIMARY KEY (driver_id, route_id, ts)
+)`, s.table))
+	if err != nil {
+		return fmt.Errorf("failed to create TimescaleDB table %s: %w", table, err)
+	}
+
+	_, err = s.pool.Exec(ctx, "SELECT create_hypertable($1::regclass, 'ts', if_not_exists => TRUE)", s.table)
+	if err != nil {
+		return fmt.Errorf("failed to create TimescaleDB hypertable %s: %w", table, err)
+	}
+	return nil
+}
+
+// WriteTrip inserts the trip's timestamped points with the speed in km/h
+// since the previous point. Points already stored are skipped, so
+// finalization retries do not duplicate rows.
+func (s *TimescaleSink) WriteTrip(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	query := fmt.Sprintf(`INSERT INTO %s (driver_id, route_id, ts, lat, lon, speed)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT DO NOTHING`, s.table)
+
+	batch := &pgx.Batch{}
+	var previous *types.TrackPoint
+	for i := range points {
+		point := points[i]
+		if point.Timestamp == 0 {
+			continue
+		}
+
+		var speed *float64
+		if previous != nil && point.Timestamp > previous.Timestamp {
+			seconds := float64(point.Timestamp-previous.Timestamp) / 1000
+			kmh := algorithm.HaversineDistance(previous.Location, point.Location) / seconds * 3.6
+			speed = &kmh
+		}
+
+		batch.Queue(query,
+			trip.DriverID,
+			trip.CurrentRouteID,
+			time.UnixMilli(int64(point.Timestamp)).UTC(),
+			point.Latitude,
+			point.Longitude,
+			speed,
+		)
+		previous = &points[i]
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to write points to TimescaleDB: %w", err)
+	}
+	return nil
+}
+
+// Close closes the TimescaleDB connection pool
+func (s *TimescaleSink) Close() error {
+	s.pool.Close()
+	return nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// transientRedisPrefixes are the Redis error replies of a server that is
+// loading, failing over, or briefly unable to serve the command
+var transientRedisPrefixes = []string{"LOADING", "READONLY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN", "BUSY "}
+
+// transientMongoLabels are the MongoDB error labels of failures that are
+// safe to retry
+var transientMongoLabels = []string{"RetryableWriteError", "TransientTransactionError", "NetworkError"}
+
+// IsTransient reports whether err is a brief Redis or MongoDB failure, such
+// as a dropped connection, a timeout, or a failover, that may succeed when
+// retried. Canceled operations and rejected commands are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, redis.Nil) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) {
+		for _, label := range transientMongoLabels {
+			if labeled.HasErrorLabel(label) {
+				return true
+			}
+		}
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range transientRedisPrefixes {
+			if strings.HasPrefix(redisErr.Error(), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// redisReply is a Redis error reply
+type redisReply string
+
+func (e redisReply) Error() string {
+	return string(e)
+}
+
+func (e redisReply) RedisError() {}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("xadd: %w", context.Canceled), false},
+		{"deadline", fmt.Errorf("xadd: %w", context.DeadlineExceeded), true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"redis nil", redis.Nil, false},
+		{"redis loading", redisReply("LOADING Redis is loading the dataset in memory"), true},
+		{"redis failover", fmt.Errorf("xadd: %w", redisReply("READONLY You can't write against a read only replica.")), true},
+		{"redis wrong type", redisReply("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{"mongo retryable write", mongo.CommandError{Code: 91, Labels: []string{"RetryableWriteError"}}, true},
+		{"mongo duplicate key", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}, false},
+		{"other", errors.New("failed to encode trip"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsTransient(test.err); got != test.want {
+				t.Errorf("Expected IsTransient(%v) = %v, got %v", test.err, test.want, got)
+			}
+		})
+	}
+}
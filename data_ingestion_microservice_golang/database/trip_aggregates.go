@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Trip stats groupings
+const (
+	StatsGroupByDay    = "day"
+	StatsGroupByDriver = "driver"
+	StatsGroupByRoute  = "route"
+)
+
+// ValidStatsGroupBy reports whether groupBy is a supported stats grouping
+func ValidStatsGroupBy(groupBy string) bool {
+	return groupBy == StatsGroupByDay || groupBy == StatsGroupByDriver || groupBy == StatsGroupByRoute
+}
+
+// statsGroupKeys maps each grouping to its aggregation expression. Days are
+// UTC dates of the trip timestamp.
+var statsGroupKeys = map[string]interface{}{
+	StatsGroupByDay: bson.M{"$dateToString": bson.M{
+		"format": "%Y-%m-%d",
+		"date":   bson.M{"$toDate": "$timestamp"},
+	}},
+	StatsGroupByDriver: "$driverId",
+	StatsGroupByRoute:  "$currentRouteId",
+}
+
+// tripAggregateStage computes the aggregate fields of a $group stage
+func tripAggregateStage(key interface{}) bson.M {
+	return bson.M{"$group": bson.M{
+		"_id":                 key,
+		"trips":               bson.M{"$sum": 1},
+		"distanceMeters":      bson.M{"$sum": "$stats.distanceMeters"},
+		"avgCompressionRatio": bson.M{"$avg": "$compressionRatio"},
+		"avgDurationSeconds":  bson.M{"$avg": "$stats.durationSeconds"},
+		// $avg skips trips without a score, and is null when none has one
+		"avgAdherenceScore": bson.M{"$avg": "$adherence.score"},
+		// The safety score is the rate of harsh events over the distance of
+		// the trips checked for them, so it is computed from the sums
+		"harshEvents": bson.M{"$sum": bson.M{"$add": bson.A{
+			"$safety.harshBraking", "$safety.harshAcceleration", "$safety.harshCornering",
+		}}},
+		"safetyTrips": bson.M{"$sum": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$safety", nil}}, 1, 0,
+		}}},
+		"safetyDistanceMeters": bson.M{"$sum": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$safety", nil}}, "$stats.distanceMeters", 0,
+		}}},
+	}}
+}
+
+// aggregateRow is a $group result
+type aggregateRow struct {
+	Key                 string   `bson:"_id"`
+	Trips               int      `bson:"trips"`
+	DistanceMeters      float64  `bson:"distanceMeters"`
+	AvgCompressionRatio float64  `bson:"avgCompressionRatio"`
+	AvgDurationSeconds  float64  `bson:"avgDurationSeconds"`
+	AvgAdherenceScore   *float64 `bson:"avgAdherenceScore"`
+	HarshEvents         int      `bson:"harshEvents"`
+	SafetyTrips         int      `bson:"safetyTrips"`
+	SafetyDistance      float64  `bson:"safetyDistanceMeters"`
+}
+
+// aggregate converts a $group result to a trip aggregate
+func (row aggregateRow) aggregate() types.TripAggregate {
+	aggregate := types.TripAggregate{
+		Key:                 row.Key,
+		Trips:               row.Trips,
+		DistanceKm:          row.DistanceMeters / 1000,
+		AvgCompressionRatio: row.AvgCompressionRatio,
+		AvgDurationSeconds:  row.AvgDurationSeconds,
+		AvgAdherenceScore:   row.AvgAdherenceScore,
+		HarshEvents:         row.HarshEvents,
+	}
+	if row.SafetyTrips > 0 {
+		score := algorithm.SafetyScore(algorithm.EventsPer100Km(row.HarshEvents, row.SafetyDistance))
+		aggregate.SafetyScore = &score
+	}
+	return aggregate
+}
+
+// AggregateTrips computes per-group and overall trip statistics in a single
+// aggregation pipeline
+func (r *TripReader) AggregateTrips(ctx context.Context, query types.TripStatsQuery) (types.TripStatsReport, error) {
+	key, ok := statsGroupKeys[query.GroupBy]
+	if !ok {
+		return types.TripStatsReport{}, fmt.Errorf("unknown stats grouping %q", query.GroupBy)
+	}
+
+	match := bson.M{}
+	if timestamp := timestampRange(query.From, query.To); len(timestamp) > 0 {
+		match["timestamp"] = timestamp
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$facet": bson.M{
+			"groups": bson.A{tripAggregateStage(key), bson.M{"$sort": bson.M{"_id": 1}}},
+			"totals": bson.A{tripAggregateStage(nil)},
+		}},
+	}
+
+	cursor, err := r.trips().Aggregate(ctx, pipeline)
+	if err != nil {
+		return types.TripStatsReport{}, fmt.Errorf("failed to aggregate trips: %w", err)
+	}
+
+	var results []struct {
+		Groups []aggregateRow `bson:"groups"`
+		Totals []aggregateRow `bson:"totals"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return types.TripStatsReport{}, fmt.Errorf("failed to decode trip aggregates: %w", err)
+	}
+
+	report := types.TripStatsReport{GroupBy: query.GroupBy, Groups: []types.TripAggregate{}}
+	if len(results) == 0 {
+		return report, nil
+	}
+	for _, row := range results[0].Groups {
+		report.Groups = append(report.Groups, row.aggregate())
+	}
+	if len(results[0].Totals) > 0 {
+		report.Totals = results[0].Totals[0].aggregate()
+		report.Totals.Key = ""
+	}
+	return report, nil
+}
+
+// AggregateTrips computes per-group and overall trip statistics by scanning
+// every trip
+func (s *BoltTripStore) AggregateTrips(ctx context.Context, query types.TripStatsQuery) (types.TripStatsReport, error) {
+	if !ValidStatsGroupBy(query.GroupBy) {
+		return types.TripStatsReport{}, fmt.Errorf("unknown stats grouping %q", query.GroupBy)
+	}
+
+	groups := make(map[string]*tripAccumulator)
+	var totals tripAccumulator
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltTripsBucket).ForEach(func(k, v []byte) error {
+			var trip types.StoredTrip
+			if err := bson.Unmarshal(v, &trip); err != nil {
+				return fmt.Errorf("failed to decode trip %s: %w", k, err)
+			}
+			if !matchesTripQuery(trip, types.TripQuery{From: query.From, To: query.To}, nil) {
+				return nil
+			}
+
+			key := statsGroupKey(trip, query.GroupBy)
+			if groups[key] == nil {
+				groups[key] = &tripAccumulator{}
+			}
+			groups[key].add(trip)
+			totals.add(trip)
+			return nil
+		})
+	})
+	if err != nil {
+		return types.TripStatsReport{}, fmt.Errorf("failed to aggregate trips: %w", err)
+	}
+
+	report := types.TripStatsReport{
+		GroupBy: query.GroupBy,
+		Groups:  make([]types.TripAggregate, 0, len(groups)),
+		Totals:  totals.aggregate(""),
+	}
+	for key, group := range groups {
+		report.Groups = append(report.Groups, group.aggregate(key))
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	return report, nil
+}
+
+// statsGroupKey returns the group of a trip
+func statsGroupKey(trip types.StoredTrip, groupBy string) string {
+	switch groupBy {
+	case StatsGroupByDriver:
+		return trip.DriverID
+	case StatsGroupByRoute:
+		return trip.CurrentRouteID
+	default:
+		return time.UnixMilli(trip.Timestamp).UTC().Format("2006-01-02")
+	}
+}
+
+// tripAccumulator sums the trips of a group
+type tripAccumulator struct {
+	trips            int
+	distanceMeters   float64
+	compressionRatio float64
+	durationSeconds  float64
+	scored           int
+	adherenceScore   float64
+	safetyTrips      int
+	safetyDistance   float64
+	harshEvents      int
+}
+
+func (a *tripAccumulator) add(trip types.StoredTrip) {
+	a.trips++
+	a.distanceMeters += trip.Stats.DistanceMeters
+	a.compressionRatio += trip.CompressionRatio
+	a.durationSeconds += trip.Stats.DurationSeconds
+	if trip.Adherence != nil {
+		a.scored++
+		a.adherenceScore += trip.Adherence.Score
+	}
+	if trip.Safety != nil {
+		a.safetyTrips++
+		a.safetyDistance += trip.Stats.DistanceMeters
+		a.harshEvents += trip.Safety.EventCount()
+	}
+}
+
+func (a *tripAccumulator) aggregate(key string) types.TripAggregate {
+	aggregate := types.TripAggregate{
+		Key:        key,
+		Trips:      a.trips,
+		DistanceKm: a.distanceMeters / 1000,
+	}
+	if a.trips > 0 {
+		aggregate.AvgCompressionRatio = a.compressionRatio / float64(a.trips)
+		aggregate.AvgDurationSeconds = a.durationSeconds / float64(a.trips)
+	}
+	if a.scored > 0 {
+		score := a.adherenceScore / float64(a.scored)
+		aggregate.AvgAdherenceScore = &score
+	}
+	if a.safetyTrips > 0 {
+		aggregate.HarshEvents = a.harshEvents
+		score := algorithm.SafetyScore(algorithm.EventsPer100Km(a.harshEvents, a.safetyDistance))
+		aggregate.SafetyScore = &score
+	}
+	return aggregate
+}
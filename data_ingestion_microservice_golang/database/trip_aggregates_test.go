@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_AggregateTrips(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	// 2022-01-01 and 2022-01-02 UTC
+	trips := []types.Trip{
+		{ID: "a", DriverID: "driver_001", CurrentRouteID: "route_1", Timestamp: 1640995200000, CompressionRatio: 0.2,
+			Stats: types.TripStats{DistanceMeters: 1000, DurationSeconds: 600}, Adherence: &types.TripAdherence{Score: 80}},
+		{ID: "b", DriverID: "driver_002", CurrentRouteID: "route_1", Timestamp: 1641000000000, CompressionRatio: 0.4,
+			Stats: types.TripStats{DistanceMeters: 3000, DurationSeconds: 1200}, Adherence: &types.TripAdherence{Score: 60}},
+		{ID: "c", DriverID: "driver_001", CurrentRouteID: "route_2", Timestamp: 1641081600000, CompressionRatio: 0.3,
+			Stats: types.TripStats{DistanceMeters: 2000, DurationSeconds: 900}, Safety: &types.TripSafety{HarshBraking: 1}},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	report, err := store.AggregateTrips(ctx, types.TripStatsQuery{GroupBy: StatsGroupByDay})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Key != "2022-01-01" || report.Groups[0].Trips != 2 {
+		t.Fatalf("Unexpected daily groups %+v", report.Groups)
+	}
+	if report.Groups[0].DistanceKm != 4 || report.Groups[0].AvgDurationSeconds != 900 {
+		t.Errorf("Unexpected first day %+v", report.Groups[0])
+	}
+	if report.Totals.Trips != 3 || report.Totals.DistanceKm != 6 || report.Totals.AvgDurationSeconds != 900 {
+		t.Errorf("Unexpected totals %+v", report.Totals)
+	}
+
+	report, err = store.AggregateTrips(ctx, types.TripStatsQuery{GroupBy: StatsGroupByDriver, From: 1641000000000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []types.TripAggregate{
+		{Key: "driver_001", Trips: 1, DistanceKm: 2, AvgCompressionRatio: 0.3, AvgDurationSeconds: 900, HarshEvents: 1},
+		{Key: "driver_002", Trips: 1, DistanceKm: 3, AvgCompressionRatio: 0.4, AvgDurationSeconds: 1200},
+	}
+	if len(report.Groups) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, report.Groups)
+	}
+	if score := report.Groups[1].AvgAdherenceScore; report.Groups[0].AvgAdherenceScore != nil || score == nil || *score != 60 {
+		t.Errorf("Expected only driver_002 to have an adherence score, got %+v", report.Groups)
+	}
+	report.Groups[1].AvgAdherenceScore = nil
+	// One harsh event in 2 km is 50 per 100 km, halving the score five times
+	if score := report.Groups[0].SafetyScore; report.Groups[1].SafetyScore != nil || score == nil || *score != 3.125 {
+		t.Errorf("Expected only driver_001 to have a safety score, got %+v", report.Groups)
+	}
+	report.Groups[0].SafetyScore = nil
+	if report.Groups[0] != want[0] || report.Groups[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, report.Groups)
+	}
+
+	report, err = store.AggregateTrips(ctx, types.TripStatsQuery{GroupBy: StatsGroupByRoute})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].AvgAdherenceScore == nil || *report.Groups[0].AvgAdherenceScore != 70 {
+		t.Fatalf("Expected route_1 to average its scored trips, got %+v", report.Groups)
+	}
+	if report.Groups[1].AvgAdherenceScore != nil {
+		t.Errorf("Expected no score for route_2 without scored trips, got %v", *report.Groups[1].AvgAdherenceScore)
+	}
+	if report.Totals.AvgAdherenceScore == nil || *report.Totals.AvgAdherenceScore != 70 {
+		t.Errorf("Expected the totals to average the scored trips only, got %+v", report.Totals)
+	}
+	if report.Totals.HarshEvents != 1 || report.Totals.SafetyScore == nil || *report.Totals.SafetyScore != 3.125 {
+		t.Errorf("Expected the safety score over the distance of checked trips only, got %+v", report.Totals)
+	}
+}
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultTripPageSize is used for trip queries without a limit
+const defaultTripPageSize = 100
+
+// ErrInvalidCursor is returned for trip cursors that were not issued by
+// FindTrips
+var ErrInvalidCursor = errors.New("invalid trip cursor")
+
+// tripCursor is the position of the last trip of a page. Trips are ordered
+// by timestamp and then by ID, so pages stay stable while new trips arrive.
+type tripCursor struct {
+	Timestamp int64
+	ID        string
+}
+
+// EncodeTripCursor returns the opaque cursor of the page after a trip
+func EncodeTripCursor(timestamp int64, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(timestamp, 10) + ":" + id))
+}
+
+// decodeTripCursor parses a cursor returned by EncodeTripCursor
+func decodeTripCursor(cursor string) (tripCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return tripCursor{}, ErrInvalidCursor
+	}
+	timestamp, id, ok := strings.Cut(string(data), ":")
+	if !ok || id == "" {
+		return tripCursor{}, ErrInvalidCursor
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return tripCursor{}, ErrInvalidCursor
+	}
+	return tripCursor{Timestamp: ts, ID: id}, nil
+}
+
+// FindTrips returns one page of trips matching the query using keyset
+// pagination on (timestamp, _id), so deep pages cost the same as the first
+func (r *TripReader) FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultTripPageSize
+	}
+
+	filter := bson.M{}
+	if query.DriverID != "" {
+		filter["driverId"] = query.DriverID
+	}
+	if query.VehicleID != "" {
+		filter["vehicleId"] = query.VehicleID
+	}
+	if query.RouteID != "" {
+		filter["currentRouteId"] = query.RouteID
+	}
+	if timestamp := timestampRange(query.From, query.To); len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+	if query.MinQuality > 0 {
+		filter["quality.score"] = bson.M{"$gte": query.MinQuality}
+	}
+	if query.WithoutFlag != "" {
+		filter["quality.flags"] = bson.M{"$ne": query.WithoutFlag}
+	}
+
+	direction, after := 1, "$gt"
+	if query.Descending {
+		direction, after = -1, "$lt"
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeTripCursor(query.Cursor)
+		if err != nil {
+			return types.TripPage{}, err
+		}
+		filter["$or"] = bson.A{
+			bson.M{"timestamp": bson.M{after: cursor.Timestamp}},
+			bson.M{"timestamp": cursor.Timestamp, "_id": bson.M{after: cursor.ID}},
+		}
+	}
+
+	// Fetch one extra trip to learn whether another page follows
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit) + 1)
+
+	cursor, err := r.trips().Find(ctx, filter, opts)
+	if err != nil {
+		return types.TripPage{}, fmt.Errorf("failed to query trips: %w", err)
+	}
+
+	var trips []types.StoredTrip
+	if err := cursor.All(ctx, &trips); err != nil {
+		return types.TripPage{}, fmt.Errorf("failed to decode trips: %w", err)
+	}
+
+	return newTripPage(trips, limit), nil
+}
+
+// FindTrip returns a stored trip, or nil if it does not exist
+func (r *TripReader) FindTrip(ctx context.Context, id string) (*types.StoredTrip, error) {
+	var trip types.StoredTrip
+	err := r.trips().FindOne(ctx, bson.M{"_id": id}).Decode(&trip)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trip %s: %w", id, err)
+	}
+	return &trip, nil
+}
+
+// FindTrip returns a stored trip, or nil if it does not exist
+func (s *BoltTripStore) FindTrip(ctx context.Context, id string) (*types.StoredTrip, error) {
+	var trip *types.StoredTrip
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(boltTripsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		trip = &types.StoredTrip{}
+		return bson.Unmarshal(data, trip)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trip %s: %w", id, err)
+	}
+	return trip, nil
+}
+
+// RawPoints returns the raw points stored for a trip, or nil if the trip has
+// no raw route
+func (s *BoltTripStore) RawPoints(ctx context.Context, id string) ([]types.TrackPoint, error) {
+	var compressed []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		// Copy the value since it is only valid during the transaction
+		compressed = append([]byte(nil), tx.Bucket(boltRawRoutesBucket).Get([]byte(id))...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find raw route for trip %s: %w", id, err)
+	}
+	if len(compressed) == 0 {
+		return nil, nil
+	}
+	return DecompressPoints(compressed)
+}
+
+// FindTrips returns one page of trips matching the query. The embedded store
+// has no secondary indexes, so every query scans all trips.
+func (s *BoltTripStore) FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultTripPageSize
+	}
+
+	var after *tripCursor
+	if query.Cursor != "" {
+		cursor, err := decodeTripCursor(query.Cursor)
+		if err != nil {
+			return types.TripPage{}, err
+		}
+		after = &cursor
+	}
+
+	var trips []types.StoredTrip
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltTripsBucket).ForEach(func(k, v []byte) error {
+			var trip types.StoredTrip
+			if err := bson.Unmarshal(v, &trip); err != nil {
+				return fmt.Errorf("failed to decode trip %s: %w", k, err)
+			}
+			if matchesTripQuery(trip, query, after) {
+				trips = append(trips, trip)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return types.TripPage{}, fmt.Errorf("failed to query trips: %w", err)
+	}
+
+	sort.Slice(trips, func(i, j int) bool {
+		if query.Descending {
+			return tripBefore(trips[j], trips[i])
+		}
+		return tripBefore(trips[i], trips[j])
+	})
+	if len(trips) > limit+1 {
+		trips = trips[:limit+1]
+	}
+
+	return newTripPage(trips, limit), nil
+}
+
+// matchesTripQuery reports whether a trip passes the query filters and lies
+// beyond the cursor
+func matchesTripQuery(trip types.StoredTrip, query types.TripQuery, after *tripCursor) bool {
+	if query.DriverID != "" && trip.DriverID != query.DriverID {
+		return false
+	}
+	if query.VehicleID != "" && trip.VehicleID != query.VehicleID {
+		return false
+	}
+	if query.RouteID != "" && trip.CurrentRouteID != query.RouteID {
+		return false
+	}
+	if query.From > 0 && trip.Timestamp < query.From {
+		return false
+	}
+	if query.To > 0 && trip.Timestamp >= query.To {
+		return false
+	}
+	if query.MinQuality > 0 && (trip.Quality == nil || trip.Quality.Score < query.MinQuality) {
+		return false
+	}
+	if query.WithoutFlag != "" && trip.Quality != nil && slices.Contains(trip.Quality.Flags, query.WithoutFlag) {
+		return false
+	}
+	if after == nil {
+		return true
+	}
+
+	last := types.StoredTrip{ID: after.ID, Timestamp: after.Timestamp}
+	if query.Descending {
+		return tripBefore(trip, last)
+	}
+	return tripBefore(last, trip)
+}
+
+// timestampRange returns the filter on trip timestamps in [from, to), where
+// zero bounds are unbounded
+func timestampRange(from, to int64) bson.M {
+	timestamp := bson.M{}
+	if from > 0 {
+		timestamp["$gte"] = from
+	}
+	if to > 0 {
+		timestamp["$lt"] = to
+	}
+	return timestamp
+}
+
+// tripBefore orders trips by timestamp and then by ID
+func tripBefore(a, b types.StoredTrip) bool {
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	return a.ID < b.ID
+}
+
+// newTripPage trims a result fetched with one extra trip to the page size
+// and sets the next cursor when more trips follow
+func newTripPage(trips []types.StoredTrip, limit int) types.TripPage {
+	page := types.TripPage{Trips: trips}
+	if page.Trips == nil {
+		page.Trips = []types.StoredTrip{}
+	}
+	if len(trips) > limit {
+		page.Trips = trips[:limit]
+		last := page.Trips[limit-1]
+		page.NextCursor = EncodeTripCursor(last.Timestamp, last.ID)
+	}
+	return page
+}
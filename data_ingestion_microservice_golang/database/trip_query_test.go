@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_FindTripsPagination(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	// Two trips share a timestamp to exercise the ID tie-break
+	timestamps := []int64{1000, 2000, 2000, 3000, 4000}
+	for i, ts := range timestamps {
+		trip := types.Trip{
+			ID:             fmt.Sprintf("trip-%d", i),
+			DriverID:       "driver_001",
+			CurrentRouteID: "route_123",
+			Timestamp:      ts,
+		}
+		if err := store.SaveTrip(ctx, "route:driver_001:route_123", trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	for _, descending := range []bool{false, true} {
+		var ids []string
+		query := types.TripQuery{DriverID: "driver_001", Limit: 2, Descending: descending}
+		for pages := 0; ; pages++ {
+			if pages > len(timestamps) {
+				t.Fatalf("Pagination did not terminate")
+			}
+			page, err := store.FindTrips(ctx, query)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			for _, trip := range page.Trips {
+				ids = append(ids, trip.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		want := []string{"trip-0", "trip-1", "trip-2", "trip-3", "trip-4"}
+		if descending {
+			want = []string{"trip-4", "trip-3", "trip-2", "trip-1", "trip-0"}
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("Descending %v: expected %v, got %v", descending, want, ids)
+		}
+	}
+}
+
+func TestBoltTripStore_FindTripsFilters(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	trips := []types.Trip{
+		{ID: "a", DriverID: "driver_001", CurrentRouteID: "route_1", Timestamp: 1000, Quality: &types.TripQuality{Score: 90, Flags: []string{}}},
+		{ID: "b", DriverID: "driver_002", CurrentRouteID: "route_1", Timestamp: 2000, Quality: &types.TripQuality{Score: 60, Flags: []string{"has_gaps"}}},
+		{ID: "c", DriverID: "driver_001", CurrentRouteID: "route_2", Timestamp: 3000},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	page, err := store.FindTrips(ctx, types.TripQuery{RouteID: "route_1", From: 1500, To: 3000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Trips) != 1 || page.Trips[0].ID != "b" {
+		t.Errorf("Expected only trip b, got %+v", page.Trips)
+	}
+	if page.NextCursor != "" {
+		t.Errorf("Expected no next cursor, got %q", page.NextCursor)
+	}
+
+	// Trips without a quality score have no score to reach, but no flags either
+	page, err = store.FindTrips(ctx, types.TripQuery{MinQuality: 70})
+	if err != nil || len(page.Trips) != 1 || page.Trips[0].ID != "a" {
+		t.Errorf("Expected only trip a to reach the quality, got %+v, %v", page.Trips, err)
+	}
+	page, err = store.FindTrips(ctx, types.TripQuery{WithoutFlag: "has_gaps"})
+	if err != nil || len(page.Trips) != 2 || page.Trips[0].ID != "a" || page.Trips[1].ID != "c" {
+		t.Errorf("Expected trips a and c without gaps, got %+v, %v", page.Trips, err)
+	}
+
+	if _, err := store.FindTrips(ctx, types.TripQuery{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestBoltTripStore_FindTripAndRawPoints(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	if trip, err := store.FindTrip(ctx, "missing"); err != nil || trip != nil {
+		t.Fatalf("Expected no trip, got %+v (%v)", trip, err)
+	}
+
+	trip := types.Trip{ID: "trip-1", DriverID: "driver_001", CurrentRouteID: "route_123", Timestamp: 1000}
+	points := []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1000},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 2000},
+	}
+	if err := store.SaveRawRoute(ctx, trip, points); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, err := store.FindTrip(ctx, trip.ID)
+	if err != nil || stored == nil || stored.DriverID != "driver_001" {
+		t.Fatalf("Expected stored trip, got %+v (%v)", stored, err)
+	}
+
+	raw, err := store.RawPoints(ctx, trip.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(raw) != len(points) || raw[1] != points[1] {
+		t.Errorf("Expected %v, got %v", points, raw)
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"data-ingestion-microservice/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TripReader queries stored trips and their raw routes
+type TripReader struct {
+	handles *atomic.Pointer[mongoCollections]
+}
+
+// NewTripReader creates a reader over the trips and raw routes collections
+func NewTripReader(trips, rawRoutes *mongo.Collection) *TripReader {
+	handles := &atomic.Pointer[mongoCollections]{}
+	handles.Store(&mongoCollections{Trips: trips, RawRoutes: rawRoutes})
+	return &TripReader{handles: handles}
+}
+
+// trips returns the trips collection of the current MongoDB client
+func (r *TripReader) trips() *mongo.Collection {
+	return r.handles.Load().Trips
+}
+
+// rawRoutes returns the raw routes collection of the current MongoDB client
+func (r *TripReader) rawRoutes() *mongo.Collection {
+	return r.handles.Load().RawRoutes
+}
+
+// EachTrip calls fn for every trip whose timestamp (milliseconds) falls in
+// [from, to), oldest first, stopping at the first error
+func (r *TripReader) EachTrip(ctx context.Context, from, to int64, fn func(trip types.StoredTrip) error) error {
+	filter := bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}}
+	cursor, err := r.trips().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("failed to query trips: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var trip types.StoredTrip
+		if err := cursor.Decode(&trip); err != nil {
+			return fmt.Errorf("failed to decode trip: %w", err)
+		}
+		if err := fn(trip); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to iterate trips: %w", err)
+	}
+	return nil
+}
+
+// RawPoints returns the raw points stored for a trip, or nil if the trip has
+// no raw route
+func (r *TripReader) RawPoints(ctx context.Context, id string) ([]types.TrackPoint, error) {
+	var doc struct {
+		Encoding string           `bson:"encoding"`
+		Points   primitive.Binary `bson:"points"`
+	}
+	err := r.rawRoutes().FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find raw route for trip %s: %w", id, err)
+	}
+	if doc.Encoding != RawRouteEncoding {
+		return nil, fmt.Errorf("unsupported raw route encoding %q for trip %s", doc.Encoding, id)
+	}
+	return DecompressPoints(doc.Points.Data)
+}
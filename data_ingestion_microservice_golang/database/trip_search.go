@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchTrips returns trips whose route geometry intersects the query's
+// bounding box, newest first, or passes near its point, nearest first. Both
+// searches use the 2dsphere index on the route geometry.
+func (r *TripReader) SearchTrips(ctx context.Context, query types.TripGeoQuery) ([]types.StoredTrip, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultTripPageSize
+	}
+
+	filter := bson.M{}
+	if timestamp := timestampRange(query.From, query.To); len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	opts := options.Find().SetLimit(int64(limit))
+	switch {
+	case query.BoundingBox != nil:
+		box := query.BoundingBox
+		filter[GeoRouteField] = bson.M{"$geoIntersects": bson.M{"$geometry": bson.M{
+			"type": "Polygon",
+			"coordinates": bson.A{bson.A{
+				bson.A{box.MinLon, box.MinLat},
+				bson.A{box.MaxLon, box.MinLat},
+				bson.A{box.MaxLon, box.MaxLat},
+				bson.A{box.MinLon, box.MaxLat},
+				bson.A{box.MinLon, box.MinLat},
+			}},
+		}}}
+		opts.SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	case query.Near != nil:
+		// $near orders the results by distance
+		filter[GeoRouteField] = bson.M{"$near": bson.M{
+			"$geometry": bson.M{
+				"type":        "Point",
+				"coordinates": bson.A{query.Near.Longitude, query.Near.Latitude},
+			},
+			"$maxDistance": query.RadiusMeters,
+		}}
+	default:
+		return nil, fmt.Errorf("trip search requires a bounding box or a point")
+	}
+
+	cursor, err := r.trips().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search trips: %w", err)
+	}
+
+	trips := []types.StoredTrip{}
+	if err := cursor.All(ctx, &trips); err != nil {
+		return nil, fmt.Errorf("failed to decode trips: %w", err)
+	}
+	return trips, nil
+}
+
+// SearchTrips returns trips whose simplified route intersects the query's
+// bounding box, newest first, or passes near its point, nearest first. The
+// embedded store scans every trip.
+func (s *BoltTripStore) SearchTrips(ctx context.Context, query types.TripGeoQuery) ([]types.StoredTrip, error) {
+	if query.BoundingBox == nil && query.Near == nil {
+		return nil, fmt.Errorf("trip search requires a bounding box or a point")
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultTripPageSize
+	}
+
+	trips := []types.StoredTrip{}
+	distances := make(map[string]float64)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltTripsBucket).ForEach(func(k, v []byte) error {
+			var trip types.StoredTrip
+			if err := bson.Unmarshal(v, &trip); err != nil {
+				return fmt.Errorf("failed to decode trip %s: %w", k, err)
+			}
+			if !matchesTripQuery(trip, types.TripQuery{From: query.From, To: query.To}, nil) {
+				return nil
+			}
+
+			route := types.RouteLocations(trip.SimplifiedRoute)
+			if query.BoundingBox != nil {
+				if algorithm.PathIntersectsBoundingBox(route, *query.BoundingBox) {
+					trips = append(trips, trip)
+				}
+				return nil
+			}
+
+			distance := algorithm.CrossTrackDistance(*query.Near, route)
+			if distance <= query.RadiusMeters {
+				distances[trip.ID] = distance
+				trips = append(trips, trip)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search trips: %w", err)
+	}
+
+	sort.Slice(trips, func(i, j int) bool {
+		if query.BoundingBox != nil {
+			return tripBefore(trips[j], trips[i])
+		}
+		return distances[trips[i].ID] < distances[trips[j].ID]
+	})
+	if len(trips) > limit {
+		trips = trips[:limit]
+	}
+	return trips, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_SearchTrips(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	trips := []types.Trip{
+		{
+			ID: "crosses", DriverID: "driver_001", CurrentRouteID: "route_1", Timestamp: 1000,
+			SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.245, Longitude: -75.60}}, {Location: types.Location{Latitude: 6.245, Longitude: -75.57}}},
+		},
+		{
+			ID: "later", DriverID: "driver_002", CurrentRouteID: "route_1", Timestamp: 2000,
+			SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.2455, Longitude: -75.60}}, {Location: types.Location{Latitude: 6.2455, Longitude: -75.57}}},
+		},
+		{
+			ID: "elsewhere", DriverID: "driver_003", CurrentRouteID: "route_2", Timestamp: 1500,
+			SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.30, Longitude: -75.60}}, {Location: types.Location{Latitude: 6.31, Longitude: -75.57}}},
+		},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	box := &types.BoundingBox{MinLon: -75.59, MinLat: 6.24, MaxLon: -75.58, MaxLat: 6.25}
+	found, err := store.SearchTrips(ctx, types.TripGeoQuery{BoundingBox: box})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(found) != 2 || found[0].ID != "later" || found[1].ID != "crosses" {
+		t.Errorf("Expected later and crosses, newest first, got %+v", found)
+	}
+
+	found, err = store.SearchTrips(ctx, types.TripGeoQuery{BoundingBox: box, To: 2000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "crosses" {
+		t.Errorf("Expected only crosses before 2000, got %+v", found)
+	}
+
+	// The first route runs 0.0005 degrees (about 55 meters) closer to the point
+	near := &types.Location{Latitude: 6.2445, Longitude: -75.585}
+	found, err = store.SearchTrips(ctx, types.TripGeoQuery{Near: near, RadiusMeters: 200})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(found) != 2 || found[0].ID != "crosses" || found[1].ID != "later" {
+		t.Errorf("Expected crosses and later, nearest first, got %+v", found)
+	}
+}
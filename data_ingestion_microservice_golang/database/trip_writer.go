@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tripFlushTimeout bounds the write of one batch of trips
+const tripFlushTimeout = 30 * time.Second
+
+// TripRecord is a trip document to persist along with its finalization marker
+type TripRecord struct {
+	ID       string
+	RouteKey string
+	Document interface{}
+}
+
+// pendingTrip is a trip record waiting for the next batch flush
+type pendingTrip struct {
+	record TripRecord
+	done   chan error
+}
+
+// TripWriter batches trip document upserts into BulkWrite calls, flushing
+// when the batch is full or the flush interval elapses. Write blocks until the
+// document's batch is flushed so callers still know whether it was persisted.
+// Documents are upserted by their trip ID, so writing a trip twice is safe.
+//
+// Every trip is written together with a marker in the finalized collection.
+// When transactions are enabled both writes commit atomically, so a marker
+// proves the trip was stored.
+type TripWriter struct {
+	handles       *atomic.Pointer[mongoCollections]
+	transactional bool
+	batchSize     int
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	pending []pendingTrip
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+// newTripWriter creates a trip writer over the trips and finalized
+// collections of the current MongoDB client and starts its periodic flusher.
+// A batch size of one or less disables batching.
+func newTripWriter(handles *atomic.Pointer[mongoCollections], config types.MongoDBConfig) *TripWriter {
+	writer := &TripWriter{
+		handles:       handles,
+		transactional: config.Transactions,
+		batchSize:     config.BatchSize,
+		flushInterval: config.BatchInterval,
+		stop:          make(chan struct{}),
+	}
+
+	if writer.batching() {
+		writer.stopped.Add(1)
+		go writer.run()
+	}
+
+	return writer
+}
+
+// client returns the current MongoDB client
+func (w *TripWriter) client() *mongo.Client {
+	return w.handles.Load().Client
+}
+
+// collection returns the trips collection of the current MongoDB client
+func (w *TripWriter) collection() *mongo.Collection {
+	return w.handles.Load().Trips
+}
+
+// markers returns the finalized collection of the current MongoDB client
+func (w *TripWriter) markers() *mongo.Collection {
+	return w.handles.Load().FinalizedTrips
+}
+
+// Write upserts a trip document by ID, waiting for the batch containing it to flush
+func (w *TripWriter) Write(ctx context.Context, record TripRecord) error {
+	if !w.batching() {
+		return w.writeOne(ctx, record)
+	}
+
+	done := make(chan error, 1)
+
+	w.mu.Lock()
+	w.pending = append(w.pending, pendingTrip{record: record, done: done})
+	full := len(w.pending) >= w.batchSize
+	w.mu.Unlock()
+
+	// The batch holds the trips of other callers too, so canceling this
+	// one must not fail the whole write
+	if full {
+		w.Flush(context.WithoutCancel(ctx))
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush writes all pending trip documents, within tripFlushTimeout
+func (w *TripWriter) Flush(ctx context.Context) {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tripFlushTimeout)
+	defer cancel()
+
+	records := make([]TripRecord, len(batch))
+	for i, trip := range batch {
+		records[i] = trip.record
+	}
+
+	metrics.TripBatchFlushes.Add(1)
+	err := w.writeBatch(ctx, records)
+	if err == nil {
+		for _, trip := range batch {
+			trip.done <- nil
+		}
+		return
+	}
+
+	// Retry only the documents that failed when the server reports them
+	// individually, otherwise fall back to writing every document. Aborted
+	// transactions roll back the whole batch, so every document is retried.
+	failed := make(map[int]bool)
+	var bulkErr mongo.BulkWriteException
+	if !w.transactional && errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0 {
+		for _, writeErr := range bulkErr.WriteErrors {
+			failed[writeErr.Index] = true
+		}
+	} else {
+		for i := range batch {
+			failed[i] = true
+		}
+	}
+
+	slog.WarnContext(ctx, "Batch upsert of trips failed, retrying individually", "trips", len(batch), "retried", len(failed), "error", err)
+	metrics.TripBatchFallbacks.Add(1)
+
+	for i, trip := range batch {
+		if !failed[i] {
+			// Markers are written after trips outside transactions, so
+			// make sure the successful trips still get theirs
+			trip.done <- w.writeMarkers(ctx, []TripRecord{trip.record})
+			continue
+		}
+		if err := w.writeOne(ctx, trip.record); err != nil {
+			trip.done <- fmt.Errorf("failed to upsert trip after batch failure: %w", err)
+			continue
+		}
+		trip.done <- nil
+	}
+}
+
+// writeOne persists a single trip record
+func (w *TripWriter) writeOne(ctx context.Context, record TripRecord) error {
+	return w.writeBatch(ctx, []TripRecord{record})
+}
+
+// writeBatch upserts trips and their finalization markers, inside a
+// transaction when transactions are enabled
+func (w *TripWriter) writeBatch(ctx context.Context, records []TripRecord) error {
+	if !w.transactional {
+		if err := w.upsertTrips(ctx, records); err != nil {
+			return err
+		}
+		return w.writeMarkers(ctx, records)
+	}
+
+	session, err := w.client().StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start MongoDB session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		if err := w.upsertTrips(sessionCtx, records); err != nil {
+			return nil, err
+		}
+		return nil, w.writeMarkers(sessionCtx, records)
+	})
+	if err != nil {
+		return fmt.Errorf("trip finalization transaction failed: %w", err)
+	}
+	return nil
+}
+
+// upsertTrips replaces or inserts trip documents by ID
+func (w *TripWriter) upsertTrips(ctx context.Context, records []TripRecord) error {
+	models := make([]mongo.WriteModel, len(records))
+	for i, record := range records {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": record.ID}).
+			SetReplacement(record.Document).
+			SetUpsert(true)
+	}
+
+	_, err := w.collection().BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// writeMarkers records that the trips were finalized
+func (w *TripWriter) writeMarkers(ctx context.Context, records []TripRecord) error {
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, len(records))
+	for i, record := range records {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": record.ID}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{
+				"routeKey":  record.RouteKey,
+				"createdAt": now,
+			}}).
+			SetUpsert(true)
+	}
+
+	_, err := w.markers().BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("failed to write finalization markers: %w", err)
+	}
+	return nil
+}
+
+// IsFinalized reports whether a finalization marker exists for a trip
+func (w *TripWriter) IsFinalized(ctx context.Context, id string) (bool, error) {
+	err := w.markers().FindOne(ctx, bson.M{"_id": id}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check finalization marker for trip %s: %w", id, err)
+	}
+	return true, nil
+}
+
+// Close stops the periodic flusher and flushes any pending documents
+func (w *TripWriter) Close(ctx context.Context) {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	w.stopped.Wait()
+	w.Flush(ctx)
+}
+
+// batching reports whether documents are buffered into batches
+func (w *TripWriter) batching() bool {
+	return w.batchSize > 1
+}
+
+// run flushes pending documents every flush interval until closed
+func (w *TripWriter) run() {
+	defer w.stopped.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush(context.Background())
+		case <-w.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+)
+
+// SaveDeadLetter stores a failed webhook delivery in the dead letter
+// collection
+func (dm *DatabaseManager) SaveDeadLetter(ctx context.Context, letter types.WebhookDeadLetter) error {
+	if _, err := dm.collections().WebhookDLQ.InsertOne(ctx, letter); err != nil {
+		return fmt.Errorf("failed to store dead letter for webhook event %s: %w", letter.Event.ID, err)
+	}
+	return nil
+}
+
+// SaveDeadLetter appends a failed webhook delivery to the dead letter bucket
+func (s *BoltTripStore) SaveDeadLetter(ctx context.Context, letter types.WebhookDeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter for webhook event %s: %w", letter.Event.ID, err)
+	}
+	if err := s.appendToBucket(boltWebhookDLQBucket, data); err != nil {
+		return fmt.Errorf("failed to store dead letter for webhook event %s: %w", letter.Event.ID, err)
+	}
+	return nil
+}
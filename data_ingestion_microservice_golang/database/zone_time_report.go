@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"data-ingestion-microservice/types"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// zoneTimeAggregateStage sums the zone time of the unwound trips of a $group
+// stage per group key and zone
+func zoneTimeAggregateStage(key interface{}) bson.M {
+	return bson.M{"$group": bson.M{
+		"_id":     bson.M{"key": key, "zoneId": "$zoneTime.zoneId"},
+		"trips":   bson.M{"$sum": 1},
+		"visits":  bson.M{"$sum": "$zoneTime.visits"},
+		"seconds": bson.M{"$sum": "$zoneTime.seconds"},
+	}}
+}
+
+// zoneTimeRow is a zone time $group result
+type zoneTimeRow struct {
+	ID struct {
+		Key    string `bson:"key"`
+		ZoneID string `bson:"zoneId"`
+	} `bson:"_id"`
+	Trips   int     `bson:"trips"`
+	Visits  int     `bson:"visits"`
+	Seconds float64 `bson:"seconds"`
+}
+
+// aggregate converts a zone time $group result to a zone time aggregate
+func (row zoneTimeRow) aggregate() types.ZoneTimeAggregate {
+	return types.ZoneTimeAggregate{
+		Key:     row.ID.Key,
+		ZoneID:  row.ID.ZoneID,
+		Trips:   row.Trips,
+		Visits:  row.Visits,
+		Minutes: row.Seconds / 60,
+	}
+}
+
+// AggregateZoneTime sums the time trips spent in each zone per group and
+// overall in a single aggregation pipeline
+func (r *TripReader) AggregateZoneTime(ctx context.Context, query types.TripStatsQuery) (types.ZoneTimeReport, error) {
+	key, ok := statsGroupKeys[query.GroupBy]
+	if !ok {
+		return types.ZoneTimeReport{}, fmt.Errorf("unknown stats grouping %q", query.GroupBy)
+	}
+
+	match := bson.M{"zoneTime": bson.M{"$exists": true}}
+	if timestamp := timestampRange(query.From, query.To); len(timestamp) > 0 {
+		match["timestamp"] = timestamp
+	}
+
+	sortByZone := bson.M{"$sort": bson.M{"_id.key": 1, "_id.zoneId": 1}}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$unwind": "$zoneTime"},
+		bson.M{"$facet": bson.M{
+			"groups": bson.A{zoneTimeAggregateStage(key), sortByZone},
+			"totals": bson.A{zoneTimeAggregateStage(nil), sortByZone},
+		}},
+	}
+
+	cursor, err := r.trips().Aggregate(ctx, pipeline)
+	if err != nil {
+		return types.ZoneTimeReport{}, fmt.Errorf("failed to aggregate zone time: %w", err)
+	}
+
+	var results []struct {
+		Groups []zoneTimeRow `bson:"groups"`
+		Totals []zoneTimeRow `bson:"totals"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return types.ZoneTimeReport{}, fmt.Errorf("failed to decode zone time aggregates: %w", err)
+	}
+
+	report := types.ZoneTimeReport{GroupBy: query.GroupBy, Groups: []types.ZoneTimeAggregate{}, Totals: []types.ZoneTimeAggregate{}}
+	if len(results) == 0 {
+		return report, nil
+	}
+	for _, row := range results[0].Groups {
+		report.Groups = append(report.Groups, row.aggregate())
+	}
+	for _, row := range results[0].Totals {
+		total := row.aggregate()
+		total.Key = ""
+		report.Totals = append(report.Totals, total)
+	}
+	return report, nil
+}
+
+// AggregateZoneTime sums the time trips spent in each zone per group and
+// overall by scanning every trip
+func (s *BoltTripStore) AggregateZoneTime(ctx context.Context, query types.TripStatsQuery) (types.ZoneTimeReport, error) {
+	if !ValidStatsGroupBy(query.GroupBy) {
+		return types.ZoneTimeReport{}, fmt.Errorf("unknown stats grouping %q", query.GroupBy)
+	}
+
+	groups := make(map[zoneTimeGroup]*types.ZoneTimeAggregate)
+	totals := make(map[string]*types.ZoneTimeAggregate)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltTripsBucket).ForEach(func(k, v []byte) error {
+			var trip types.StoredTrip
+			if err := bson.Unmarshal(v, &trip); err != nil {
+				return fmt.Errorf("failed to decode trip %s: %w", k, err)
+			}
+			if len(trip.ZoneTime) == 0 || !matchesTripQuery(trip, types.TripQuery{From: query.From, To: query.To}, nil) {
+				return nil
+			}
+
+			key := statsGroupKey(trip, query.GroupBy)
+			for _, zone := range trip.ZoneTime {
+				group := zoneTimeGroup{key: key, zoneID: zone.ZoneID}
+				if groups[group] == nil {
+					groups[group] = &types.ZoneTimeAggregate{Key: key, ZoneID: zone.ZoneID}
+				}
+				if totals[zone.ZoneID] == nil {
+					totals[zone.ZoneID] = &types.ZoneTimeAggregate{ZoneID: zone.ZoneID}
+				}
+				addZoneTime(groups[group], zone)
+				addZoneTime(totals[zone.ZoneID], zone)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return types.ZoneTimeReport{}, fmt.Errorf("failed to aggregate zone time: %w", err)
+	}
+
+	report := types.ZoneTimeReport{
+		GroupBy: query.GroupBy,
+		Groups:  make([]types.ZoneTimeAggregate, 0, len(groups)),
+		Totals:  make([]types.ZoneTimeAggregate, 0, len(totals)),
+	}
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+	for _, total := range totals {
+		report.Totals = append(report.Totals, *total)
+	}
+	sortZoneTime(report.Groups)
+	sortZoneTime(report.Totals)
+	return report, nil
+}
+
+// zoneTimeGroup identifies the zone time of a zone in a group
+type zoneTimeGroup struct {
+	key    string
+	zoneID string
+}
+
+// addZoneTime adds the time a trip spent in a zone to its aggregate
+func addZoneTime(aggregate *types.ZoneTimeAggregate, zone types.ZoneTime) {
+	aggregate.Trips++
+	aggregate.Visits += zone.Visits
+	aggregate.Minutes += zone.Seconds / 60
+}
+
+// sortZoneTime orders zone time aggregates by group key, then zone
+func sortZoneTime(aggregates []types.ZoneTimeAggregate) {
+	sort.Slice(aggregates, func(i, j int) bool {
+		if aggregates[i].Key != aggregates[j].Key {
+			return aggregates[i].Key < aggregates[j].Key
+		}
+		return aggregates[i].ZoneID < aggregates[j].ZoneID
+	})
+}
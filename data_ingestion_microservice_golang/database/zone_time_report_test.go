@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestBoltTripStore_AggregateZoneTime(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer store.Close()
+
+	// 2022-01-01 and 2022-01-02 UTC; trip c never entered a zone
+	trips := []types.Trip{
+		{ID: "a", DriverID: "driver_001", CurrentRouteID: "route_1", Timestamp: 1640995200000, ZoneTime: []types.ZoneTime{
+			{ZoneID: "depot", Seconds: 600, Visits: 1}, {ZoneID: "downtown", Seconds: 1200, Visits: 2},
+		}},
+		{ID: "b", DriverID: "driver_002", CurrentRouteID: "route_1", Timestamp: 1641000000000, ZoneTime: []types.ZoneTime{
+			{ZoneID: "downtown", Seconds: 300, Visits: 1},
+		}},
+		{ID: "c", DriverID: "driver_002", CurrentRouteID: "route_2", Timestamp: 1641081600000},
+		{ID: "d", DriverID: "driver_001", CurrentRouteID: "route_2", Timestamp: 1641081700000, ZoneTime: []types.ZoneTime{
+			{ZoneID: "depot", Seconds: 120, Visits: 1},
+		}},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	report, err := store.AggregateZoneTime(ctx, types.TripStatsQuery{GroupBy: StatsGroupByDriver})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []types.ZoneTimeAggregate{
+		{Key: "driver_001", ZoneID: "depot", Trips: 2, Visits: 2, Minutes: 12},
+		{Key: "driver_001", ZoneID: "downtown", Trips: 1, Visits: 2, Minutes: 20},
+		{Key: "driver_002", ZoneID: "downtown", Trips: 1, Visits: 1, Minutes: 5},
+	}
+	if !reflect.DeepEqual(report.Groups, want) {
+		t.Errorf("Expected %+v, got %+v", want, report.Groups)
+	}
+	wantTotals := []types.ZoneTimeAggregate{
+		{ZoneID: "depot", Trips: 2, Visits: 2, Minutes: 12},
+		{ZoneID: "downtown", Trips: 2, Visits: 3, Minutes: 25},
+	}
+	if !reflect.DeepEqual(report.Totals, wantTotals) {
+		t.Errorf("Expected totals %+v, got %+v", wantTotals, report.Totals)
+	}
+
+	report, err = store.AggregateZoneTime(ctx, types.TripStatsQuery{GroupBy: StatsGroupByDay, From: 1641081600000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want = []types.ZoneTimeAggregate{{Key: "2022-01-02", ZoneID: "depot", Trips: 1, Visits: 1, Minutes: 2}}
+	if !reflect.DeepEqual(report.Groups, want) {
+		t.Errorf("Expected the second day only, got %+v", report.Groups)
+	}
+}
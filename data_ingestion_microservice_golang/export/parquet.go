@@ -0,0 +1,162 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// PartitionDateLayout formats the date partition of exported files
+const PartitionDateLayout = "2006-01-02"
+
+// PointRow is a single trip point in the columnar export layout. Ts holds
+// the device timestamp in milliseconds and is written as null when zero.
+type PointRow struct {
+	TripID     string  `parquet:"tripId"`
+	DriverID   string  `parquet:"driverId"`
+	RouteID    string  `parquet:"routeId"`
+	PointIndex int32   `parquet:"pointIndex"`
+	Lat        float64 `parquet:"lat"`
+	Lon        float64 `parquet:"lon"`
+	Ts         int64   `parquet:"ts,optional,timestamp(millisecond)"`
+}
+
+// Summary reports what an export wrote
+type Summary struct {
+	Trips  int
+	Points int
+	Files  []string
+}
+
+// TripRows converts a trip into point rows. Raw points are exported when
+// available, otherwise the simplified route is, with the timestamps it
+// carries since trip schema version 5.
+func TripRows(trip types.StoredTrip, raw []types.TrackPoint) []PointRow {
+	if len(raw) > 0 {
+		rows := make([]PointRow, len(raw))
+		for i, point := range raw {
+			rows[i] = newPointRow(trip, i, point.Location)
+			rows[i].Ts = int64(point.Timestamp)
+		}
+		return rows
+	}
+
+	rows := make([]PointRow, len(trip.SimplifiedRoute))
+	for i, point := range trip.SimplifiedRoute {
+		rows[i] = newPointRow(trip, i, point.Location)
+		rows[i].Ts = int64(point.Timestamp)
+	}
+	return rows
+}
+
+// newPointRow creates the row of a trip point
+func newPointRow(trip types.StoredTrip, index int, location types.Location) PointRow {
+	return PointRow{
+		TripID:     trip.ID,
+		DriverID:   trip.DriverID,
+		RouteID:    trip.CurrentRouteID,
+		PointIndex: int32(index),
+		Lat:        location.Latitude,
+		Lon:        location.Longitude,
+	}
+}
+
+// partition is an open Parquet file of a single date partition
+type partition struct {
+	file   *os.File
+	writer *parquet.GenericWriter[PointRow]
+}
+
+// PartitionedWriter writes point rows into Hive-style date partitions,
+// one file per partition: {dir}/date=YYYY-MM-DD/part-00000.parquet
+type PartitionedWriter struct {
+	dir        string
+	partitions map[string]*partition
+	files      []string
+}
+
+// NewPartitionedWriter creates a writer rooted at dir
+func NewPartitionedWriter(dir string) *PartitionedWriter {
+	return &PartitionedWriter{
+		dir:        dir,
+		partitions: make(map[string]*partition),
+	}
+}
+
+// Write appends rows to the partition of the given date
+func (w *PartitionedWriter) Write(date time.Time, rows []PointRow) error {
+	key := date.UTC().Format(PartitionDateLayout)
+	part, ok := w.partitions[key]
+	if !ok {
+		dir := filepath.Join(w.dir, "date="+key)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", dir, err)
+		}
+
+		path := filepath.Join(dir, "part-00000.parquet")
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+
+		part = &partition{file: file, writer: parquet.NewGenericWriter[PointRow](file)}
+		w.partitions[key] = part
+		w.files = append(w.files, path)
+	}
+
+	if _, err := part.writer.Write(rows); err != nil {
+		return fmt.Errorf("failed to write partition %s: %w", key, err)
+	}
+	return nil
+}
+
+// Close flushes and closes every partition file, returning their paths
+func (w *PartitionedWriter) Close() ([]string, error) {
+	var firstErr error
+	for key, part := range w.partitions {
+		if err := part.writer.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to finish partition %s: %w", key, err)
+		}
+		if err := part.file.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close partition %s: %w", key, err)
+		}
+	}
+	return w.files, firstErr
+}
+
+// ExportTrips dumps trips whose timestamp falls in [from, to) into
+// date-partitioned Parquet files under dir
+func ExportTrips(ctx context.Context, reader *database.TripReader, from, to time.Time, dir string) (Summary, error) {
+	var summary Summary
+	writer := NewPartitionedWriter(dir)
+
+	err := reader.EachTrip(ctx, from.UnixMilli(), to.UnixMilli(), func(trip types.StoredTrip) error {
+		raw, err := reader.RawPoints(ctx, trip.ID)
+		if err != nil {
+			return err
+		}
+
+		rows := TripRows(trip, raw)
+		if err := writer.Write(time.UnixMilli(trip.Timestamp), rows); err != nil {
+			return err
+		}
+
+		summary.Trips++
+		summary.Points += len(rows)
+		return nil
+	})
+
+	files, closeErr := writer.Close()
+	summary.Files = files
+	if err != nil {
+		return summary, err
+	}
+	return summary, closeErr
+}
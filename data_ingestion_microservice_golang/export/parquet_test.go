@@ -0,0 +1,69 @@
+package export
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestTripRows_PrefersRawPoints(t *testing.T) {
+	trip := types.StoredTrip{
+		ID:              "trip-1",
+		DriverID:        "driver_001",
+		CurrentRouteID:  "route_123",
+		SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}}},
+	}
+	raw := []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995201000},
+	}
+
+	rows := TripRows(trip, raw)
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+	if rows[1].PointIndex != 1 || rows[1].Ts != 1640995201000 {
+		t.Errorf("Unexpected row %+v", rows[1])
+	}
+
+	simplified := TripRows(trip, nil)
+	if len(simplified) != 1 || simplified[0].Ts != 0 {
+		t.Errorf("Expected 1 simplified row without timestamp, got %+v", simplified)
+	}
+}
+
+func TestPartitionedWriter_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewPartitionedWriter(dir)
+
+	ts := int64(1640995200000)
+	rows := []PointRow{
+		{TripID: "trip-1", DriverID: "driver_001", RouteID: "route_123", PointIndex: 0, Lat: 6.2442, Lon: -75.5812, Ts: ts},
+		{TripID: "trip-1", DriverID: "driver_001", RouteID: "route_123", PointIndex: 1, Lat: 6.2450, Lon: -75.5820},
+	}
+	if err := writer.Write(time.UnixMilli(ts), rows); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	files, err := writer.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := filepath.Join(dir, "date=2022-01-01", "part-00000.parquet")
+	if len(files) != 1 || files[0] != expected {
+		t.Fatalf("Expected file %s, got %v", expected, files)
+	}
+
+	read, err := parquet.ReadFile[PointRow](expected)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(read) != 2 || read[0].Ts != ts || read[1].Ts != 0 {
+		t.Errorf("Expected %+v, got %+v", rows, read)
+	}
+}
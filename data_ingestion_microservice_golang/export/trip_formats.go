@@ -0,0 +1,230 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// Trip export formats
+const (
+	FormatGPX     = "gpx"
+	FormatGeoJSON = "geojson"
+	FormatKML     = "kml"
+)
+
+// ErrUnknownFormat is returned by RenderTrip for unsupported formats
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// formatContentTypes maps each export format to its media type
+var formatContentTypes = map[string]string{
+	FormatGPX:     "application/gpx+xml",
+	FormatGeoJSON: "application/geo+json",
+	FormatKML:     "application/vnd.google-earth.kml+xml",
+}
+
+// ContentType returns the media type of an export format
+func ContentType(format string) string {
+	return formatContentTypes[format]
+}
+
+// RenderTrip renders the simplified route and stops of a trip in the given
+// format
+func RenderTrip(format string, trip types.StoredTrip, stops []types.Stop) ([]byte, error) {
+	switch format {
+	case FormatGPX:
+		return GPX(trip, stops)
+	case FormatGeoJSON:
+		return GeoJSON(trip, stops)
+	case FormatKML:
+		return KML(trip, stops)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+// gpxDocument is a GPX 1.1 document with stop waypoints and one track
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Name      string        `xml:"metadata>name"`
+	Time      string        `xml:"metadata>time,omitempty"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Track     gpxTrack      `xml:"trk"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+	Name string  `xml:"name"`
+	Desc string  `xml:"desc,omitempty"`
+}
+
+type gpxTrack struct {
+	Name   string     `xml:"name"`
+	Points []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+}
+
+// GPX renders a trip as a GPX 1.1 track with a waypoint per stop
+func GPX(trip types.StoredTrip, stops []types.Stop) ([]byte, error) {
+	doc := gpxDocument{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "data-ingestion-microservice",
+		Name:    tripName(trip),
+		Time:    formatMillis(trip.Timestamp),
+		Track:   gpxTrack{Name: tripName(trip)},
+	}
+
+	for i, stop := range stops {
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+			Lat:  stop.Location.Latitude,
+			Lon:  stop.Location.Longitude,
+			Time: formatMillis(int64(stop.StartTimestamp)),
+			Name: stopName(i),
+			Desc: fmt.Sprintf("Stopped for %.0f seconds", stop.DurationSeconds),
+		})
+	}
+	for _, point := range trip.SimplifiedRoute {
+		doc.Track.Points = append(doc.Track.Points, gpxPoint{
+			Lat:  point.Latitude,
+			Lon:  point.Longitude,
+			Time: formatMillis(int64(point.Timestamp)),
+		})
+	}
+
+	return marshalXML(doc)
+}
+
+// geoJSONFeature is a GeoJSON feature
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *types.GeoJSONGeometry `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSON renders a trip as a FeatureCollection holding the route and a
+// point feature per stop
+func GeoJSON(trip types.StoredTrip, stops []types.Stop) ([]byte, error) {
+	features := []geoJSONFeature{{
+		Type:     "Feature",
+		Geometry: algorithm.ToGeoJSON(types.RouteLocations(trip.SimplifiedRoute)),
+		Properties: map[string]interface{}{
+			"kind":           "route",
+			"tripId":         trip.ID,
+			"driverId":       trip.DriverID,
+			"currentRouteId": trip.CurrentRouteID,
+			"timestamp":      trip.Timestamp,
+			"stats":          trip.Stats,
+		},
+	}}
+
+	for i, stop := range stops {
+		features = append(features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: algorithm.ToGeoJSON([]types.Location{stop.Location}),
+			Properties: map[string]interface{}{
+				"kind":            "stop",
+				"name":            stopName(i),
+				"startTimestamp":  stop.StartTimestamp,
+				"endTimestamp":    stop.EndTimestamp,
+				"durationSeconds": stop.DurationSeconds,
+			},
+		})
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}, "", "  ")
+}
+
+// kmlDocument is a KML document with one placemark per route and stop
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description,omitempty"`
+	LineString  string `xml:"LineString>coordinates,omitempty"`
+	Point       string `xml:"Point>coordinates,omitempty"`
+}
+
+// KML renders a trip as a KML document for Google Earth
+func KML(trip types.StoredTrip, stops []types.Stop) ([]byte, error) {
+	doc := kmlDocument{
+		Xmlns: "http://www.opengis.net/kml/2.2",
+		Name:  tripName(trip),
+	}
+
+	coordinates := make([]string, len(trip.SimplifiedRoute))
+	for i, point := range trip.SimplifiedRoute {
+		coordinates[i] = kmlCoordinate(point.Location)
+	}
+	doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+		Name:        tripName(trip),
+		Description: fmt.Sprintf("%.0f m, %d stops", trip.Stats.DistanceMeters, trip.Stats.Stops),
+		LineString:  strings.Join(coordinates, " "),
+	})
+
+	for i, stop := range stops {
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:        stopName(i),
+			Description: fmt.Sprintf("Stopped for %.0f seconds", stop.DurationSeconds),
+			Point:       kmlCoordinate(stop.Location),
+		})
+	}
+
+	return marshalXML(doc)
+}
+
+// kmlCoordinate formats a location as a KML lon,lat tuple
+func kmlCoordinate(location types.Location) string {
+	return fmt.Sprintf("%g,%g", location.Longitude, location.Latitude)
+}
+
+// marshalXML encodes an XML document with its declaration
+func marshalXML(doc interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// tripName is the display name of a trip in exported files
+func tripName(trip types.StoredTrip) string {
+	return fmt.Sprintf("%s %s", trip.DriverID, trip.CurrentRouteID)
+}
+
+// stopName is the display name of the i-th stop
+func stopName(i int) string {
+	return fmt.Sprintf("Stop %d", i+1)
+}
+
+// formatMillis formats a millisecond timestamp as RFC 3339, or "" when unset
+func formatMillis(millis int64) string {
+	if millis <= 0 {
+		return ""
+	}
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339)
+}
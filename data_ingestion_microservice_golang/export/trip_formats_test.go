@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+var formatTestTrip = types.StoredTrip{
+	ID:             "trip-1",
+	DriverID:       "driver_001",
+	CurrentRouteID: "route_123",
+	Timestamp:      1640995200000,
+	SimplifiedRoute: []types.RoutePoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}},
+		{Location: types.Location{Latitude: 6.2500, Longitude: -75.5900}},
+	},
+}
+
+var formatTestStops = []types.Stop{{
+	Location:        types.Location{Latitude: 6.2442, Longitude: -75.5812},
+	StartTimestamp:  1640995210000,
+	EndTimestamp:    1640995270000,
+	DurationSeconds: 60,
+}}
+
+func TestGPX(t *testing.T) {
+	data, err := RenderTrip(FormatGPX, formatTestTrip, formatTestStops)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var doc gpxDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to parse GPX: %v", err)
+	}
+	if len(doc.Track.Points) != 2 || doc.Track.Points[1].Lon != -75.59 {
+		t.Errorf("Unexpected track points %+v", doc.Track.Points)
+	}
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Time != "2022-01-01T00:00:10Z" {
+		t.Errorf("Unexpected waypoints %+v", doc.Waypoints)
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	data, err := RenderTrip(FormatGeoJSON, formatTestTrip, formatTestStops)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type string `json:"type"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("Failed to parse GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("Unexpected feature collection %+v", collection)
+	}
+	if collection.Features[0].Geometry.Type != "LineString" || collection.Features[1].Geometry.Type != "Point" {
+		t.Errorf("Expected a LineString route and a Point stop, got %+v", collection.Features)
+	}
+}
+
+func TestKML(t *testing.T) {
+	data, err := RenderTrip(FormatKML, formatTestTrip, formatTestStops)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var doc kmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to parse KML: %v", err)
+	}
+	if len(doc.Placemarks) != 2 {
+		t.Fatalf("Expected 2 placemarks, got %d", len(doc.Placemarks))
+	}
+	if doc.Placemarks[0].LineString != "-75.5812,6.2442 -75.59,6.25" {
+		t.Errorf("Unexpected route coordinates %q", doc.Placemarks[0].LineString)
+	}
+	if !strings.HasPrefix(string(data), "<?xml") {
+		t.Errorf("Expected an XML declaration")
+	}
+}
+
+func TestRenderTrip_UnknownFormat(t *testing.T) {
+	if _, err := RenderTrip("shp", formatTestTrip, nil); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
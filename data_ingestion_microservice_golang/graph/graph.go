@@ -0,0 +1,88 @@
+// Package graph is the GraphQL schema of trips, drivers, routes, and live
+// positions, executed by gqlgen. schema.graphqls defines it; generated.go
+// and models_gen.go are generated from it with
+//
+//	go tool gqlgen generate --config graph/gqlgen.yml
+//
+// and the resolvers are implemented by the api package.
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// SDL is the schema in the GraphQL schema definition language
+//
+//go:embed schema.graphqls
+var SDL string
+
+// errDepthLimit is the error code of queries nested too deeply
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+// NewExecutor returns an executor of the schema that supports introspection
+// and rejects queries nested deeper than maxDepth or estimated to cost more
+// than maxComplexity
+func NewExecutor(config Config, maxDepth, maxComplexity int) *executor.Executor {
+	exec := executor.New(NewExecutableSchema(config))
+	exec.Use(extension.Introspection{})
+	exec.Use(DepthLimit(maxDepth))
+	exec.Use(extension.FixedComplexityLimit(maxComplexity))
+	return exec
+}
+
+// DepthLimit rejects queries whose selections nest deeper than limit.
+// Introspection fields do not count, since introspection queries are deep
+// by nature and only read the schema.
+func DepthLimit(limit int) graphql.HandlerExtension {
+	return depthLimit(limit)
+}
+
+type depthLimit int
+
+func (l depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (l depthLimit) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (l depthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(opCtx.Operation.SelectionSet); depth > int(l) {
+		err := gqlerror.Errorf("query depth %d exceeds the maximum of %d", depth, int(l))
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns how deeply fields nest in a validated selection
+// set, following fragments
+func selectionDepth(selections ast.SelectionSet) int {
+	deepest := 0
+	for _, selection := range selections {
+		var depth int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionDepth(selection.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selectionDepth(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			depth = selectionDepth(selection.Definition.SelectionSet)
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
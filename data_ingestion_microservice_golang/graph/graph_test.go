@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestSelectionDepth(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "schema.graphqls", Input: SDL})
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{`{ trip(id: "t") { id } }`, 2},
+		{`{ trip(id: "t") { id driver { trips { trips { id } } } } }`, 5},
+		{`{ trip(id: "t") { ...TripDriver } } fragment TripDriver on Trip { driver { id } }`, 3},
+		{`{ trip(id: "t") { ... on Trip { route { id } } } }`, 3},
+		{`{ __schema { types { fields { type { name } } } } trip(id: "t") { id } }`, 2},
+	}
+	for _, tt := range tests {
+		doc, errs := gqlparser.LoadQuery(schema, tt.query)
+		if errs != nil {
+			t.Fatalf("failed to load %s: %v", tt.query, errs)
+		}
+		if got := selectionDepth(doc.Operations[0].SelectionSet); got != tt.want {
+			t.Errorf("selectionDepth(%s) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
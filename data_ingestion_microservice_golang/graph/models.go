@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Trip is a trip in the graph. Stops are loaded on demand unless the trip
+// was read together with them.
+type Trip struct {
+	types.StoredTrip
+	LoadedStops []types.Stop
+	StopsLoaded bool
+}
+
+// Driver and Route are the drivers and routes in the graph, known by ID
+type (
+	Driver struct{ ID string }
+	Route  struct{ ID string }
+)
+
+// MarshalLong writes a millisecond timestamp as a JSON number
+func MarshalLong(millis int64) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.FormatInt(millis, 10))
+	})
+}
+
+// UnmarshalLong accepts a non-negative millisecond timestamp
+func UnmarshalLong(value any) (int64, error) {
+	millis, err := graphql.UnmarshalInt64(value)
+	if err != nil || millis < 0 {
+		return 0, errors.New("expected milliseconds since the epoch")
+	}
+	return millis, nil
+}
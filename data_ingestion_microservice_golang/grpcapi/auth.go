@@ -0,0 +1,103 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"data-ingestion-microservice/auth"
+	"data-ingestion-microservice/tripquerypb"
+)
+
+// Authenticator validates the JWT bearer tokens of API clients
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (auth.Claims, error)
+}
+
+// methodScope returns the JWT scope a method requires. TripQueryService
+// methods require read:trips; the health and reflection services stay
+// public so probes and tooling work without a token.
+func methodScope(fullMethod string) string {
+	if strings.HasPrefix(fullMethod, "/"+tripquerypb.TripQueryService_ServiceDesc.ServiceName+"/") {
+		return auth.ScopeReadTrips
+	}
+	return ""
+}
+
+// authUnary rejects unary calls without a token granting the method scope
+func (s *Server) authUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if _, err := s.authenticate(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStream rejects streams without a token granting the method scope, and
+// ends them with Unauthenticated when the token expires
+func (s *Server) authStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	claims, err := s.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	if claims.ExpiresAt.IsZero() {
+		return handler(srv, stream)
+	}
+
+	ctx, cancel := context.WithDeadlineCause(stream.Context(), claims.ExpiresAt, auth.ErrTokenExpired)
+	defer cancel()
+	err = handler(srv, expiringStream{ServerStream: stream, ctx: ctx})
+	if errors.Is(context.Cause(ctx), auth.ErrTokenExpired) {
+		return status.Error(codes.Unauthenticated, "token expired")
+	}
+	return err
+}
+
+// expiringStream is a server stream whose context ends when its token
+// expires
+type expiringStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s expiringStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate checks the bearer token of the authorization metadata,
+// failing with Unauthenticated for missing and invalid tokens,
+// PermissionDenied for tokens without the scope, and Unavailable when the
+// signing keys cannot be fetched, matching the 401, 403, and 503 of the
+// HTTP API. Public methods return no claims.
+func (s *Server) authenticate(ctx context.Context, fullMethod string) (auth.Claims, error) {
+	scope := methodScope(fullMethod)
+	if scope == "" {
+		return auth.Claims{}, nil
+	}
+
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	if token == "" {
+		return auth.Claims{}, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	claims, err := s.auth.Authenticate(ctx, token)
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		slog.DebugContext(ctx, "Rejected bearer token", "method", fullMethod, "error", err)
+		return auth.Claims{}, status.Error(codes.Unauthenticated, "invalid bearer token")
+	case err != nil:
+		slog.ErrorContext(ctx, "Error authenticating request", "method", fullMethod, "error", err)
+		return auth.Claims{}, status.Error(codes.Unavailable, "authentication is unavailable")
+	case !claims.HasScope(scope):
+		return auth.Claims{}, status.Errorf(codes.PermissionDenied, "token lacks the %s scope", scope)
+	}
+	return claims, nil
+}
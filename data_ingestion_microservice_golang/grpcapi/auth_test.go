@@ -0,0 +1,115 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"data-ingestion-microservice/auth"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/tripquerypb"
+	"data-ingestion-microservice/types"
+)
+
+// fakeAuthenticator accepts the tokens it knows, and fails every token
+// with err when it is set
+type fakeAuthenticator struct {
+	tokens map[string]auth.Claims
+	err    error
+}
+
+func (f fakeAuthenticator) Authenticate(ctx context.Context, token string) (auth.Claims, error) {
+	if f.err != nil {
+		return auth.Claims{}, f.err
+	}
+	claims, ok := f.tokens[token]
+	if !ok {
+		return auth.Claims{}, fmt.Errorf("%w: unknown token", auth.ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+var testAuthenticator = fakeAuthenticator{tokens: map[string]auth.Claims{
+	"reader": {Subject: "dispatch", Scopes: []string{auth.ScopeReadTrips}},
+	"admin":  {Subject: "ops@example.com", Scopes: []string{auth.ScopeAdminConfig}},
+}}
+
+// withToken attaches a bearer token to the outgoing metadata
+func withToken(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAuthRequiresReadScope(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	conn := dialServer(t, NewServerWithAuth(types.GRPCConfig{DefaultPageSize: 20, MaxPageSize: 50}, svc, testAuthenticator))
+	client := tripquerypb.NewTripQueryServiceClient(conn)
+
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{"missing token", "", codes.Unauthenticated},
+		{"invalid token", "forged", codes.Unauthenticated},
+		{"missing scope", "admin", codes.PermissionDenied},
+		{"read scope", "reader", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ListTrips(withToken(tt.token), &tripquerypb.ListTripsRequest{}); status.Code(err) != tt.code {
+				t.Errorf("expected %v from ListTrips, got %v", tt.code, err)
+			}
+
+			stream, err := client.StreamLivePositions(withToken(tt.token), &tripquerypb.StreamLivePositionsRequest{RouteIds: []string{"route_1"}})
+			if err == nil && tt.code != codes.OK {
+				_, err = stream.Recv()
+				if status.Code(err) != tt.code {
+					t.Errorf("expected %v from StreamLivePositions, got %v", tt.code, err)
+				}
+			}
+		})
+	}
+
+	// Health checks stay public for probes and load balancers
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected the health check to work without a token, got %v", err)
+	}
+}
+
+func TestAuthUnavailableKeys(t *testing.T) {
+	authenticator := fakeAuthenticator{err: errors.New("no signing keys: connection refused")}
+	conn := dialServer(t, NewServerWithAuth(types.GRPCConfig{DefaultPageSize: 20, MaxPageSize: 50}, &fakeService{}, authenticator))
+
+	_, err := tripquerypb.NewTripQueryServiceClient(conn).ListTrips(withToken("reader"), &tripquerypb.ListTripsRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+}
+
+func TestAuthEndsStreamsWhenTokenExpires(t *testing.T) {
+	authenticator := fakeAuthenticator{tokens: map[string]auth.Claims{
+		"expiring": {Subject: "dispatch", Scopes: []string{auth.ScopeReadTrips}, ExpiresAt: time.Now().Add(200 * time.Millisecond)},
+	}}
+	svc := &fakeService{stream: service.NewLiveStream(8)}
+	conn := dialServer(t, NewServerWithAuth(types.GRPCConfig{DefaultPageSize: 20, MaxPageSize: 50}, svc, authenticator))
+
+	ctx, cancel := context.WithTimeout(withToken("expiring"), 5*time.Second)
+	defer cancel()
+	stream, err := tripquerypb.NewTripQueryServiceClient(conn).StreamLivePositions(ctx, &tripquerypb.StreamLivePositionsRequest{RouteIds: []string{"route_1"}})
+	if err != nil {
+		t.Fatalf("Expected the stream to open, got %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated once the token expires, got %v", err)
+	}
+}
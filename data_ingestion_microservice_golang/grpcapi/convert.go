@@ -0,0 +1,66 @@
+package grpcapi
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"data-ingestion-microservice/tripquerypb"
+	"data-ingestion-microservice/types"
+)
+
+// tripToProto converts a stored trip to its gRPC message, without stops
+func tripToProto(trip types.StoredTrip) *tripquerypb.Trip {
+	result := &tripquerypb.Trip{
+		Id:                    trip.ID,
+		SchemaVersion:         int32(trip.SchemaVersion),
+		DriverId:              trip.DriverID,
+		RouteId:               trip.CurrentRouteID,
+		Timestamp:             trip.Timestamp,
+		OriginalPointsCount:   int32(trip.OriginalPointsCount),
+		SimplifiedPointsCount: int32(trip.SimplifiedPointsCount),
+		CompressionRatio:      trip.CompressionRatio,
+		ReductionPercent:      trip.ReductionPercent,
+		Stats: &tripquerypb.TripStats{
+			DurationSeconds: trip.Stats.DurationSeconds,
+			MovingSeconds:   trip.Stats.MovingSeconds,
+			IdleSeconds:     trip.Stats.IdleSeconds,
+			DistanceMeters:  trip.Stats.DistanceMeters,
+			AverageSpeedKmh: trip.Stats.AverageSpeedKmh,
+			MaxSpeedKmh:     trip.Stats.MaxSpeedKmh,
+			Stops:           int32(trip.Stats.Stops),
+		},
+		RawArchiveUrl: trip.RawArchiveURL,
+	}
+	if !trip.CreatedAt.IsZero() {
+		result.CreatedAt = timestamppb.New(trip.CreatedAt)
+	}
+	for _, point := range trip.SimplifiedRoute {
+		result.SimplifiedRoute = append(result.SimplifiedRoute, locationToProto(point.Location))
+	}
+	return result
+}
+
+// stopToProto converts a detected stop to its gRPC message
+func stopToProto(stop types.Stop) *tripquerypb.Stop {
+	return &tripquerypb.Stop{
+		Location:        locationToProto(stop.Location),
+		StartTimestamp:  stop.StartTimestamp,
+		EndTimestamp:    stop.EndTimestamp,
+		DurationSeconds: stop.DurationSeconds,
+	}
+}
+
+// livePositionToProto converts a processed location to a live position
+func livePositionToProto(busMsg types.BusMessage) *tripquerypb.LivePosition {
+	return &tripquerypb.LivePosition{
+		DriverId:  busMsg.DriverID,
+		RouteId:   busMsg.CurrentRouteID,
+		Status:    busMsg.Status,
+		Location:  locationToProto(busMsg.DriverLocation),
+		Timestamp: busMsg.Timestamp,
+	}
+}
+
+// locationToProto converts a GPS coordinate to its gRPC message
+func locationToProto(location types.Location) *tripquerypb.Location {
+	return &tripquerypb.Location{Latitude: location.Latitude, Longitude: location.Longitude}
+}
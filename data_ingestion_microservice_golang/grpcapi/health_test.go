@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+
+	"data-ingestion-microservice/types"
+)
+
+// healthService reports component health that the test can change
+type healthService struct {
+	fakeService
+	mu      sync.Mutex
+	healthy bool
+}
+
+func (h *healthService) ComponentHealth(ctx context.Context) map[string]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return map[string]bool{"redis": true, "mongodb": h.healthy}
+}
+
+func (h *healthService) setHealthy(healthy bool) {
+	h.mu.Lock()
+	h.healthy = healthy
+	h.mu.Unlock()
+}
+
+// waitForStatus polls the health service until a service reports want
+func waitForStatus(t *testing.T, client healthpb.HealthClient, service string, want healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err == nil && resp.GetStatus() == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q to become %s, got %v (%v)", service, want, resp.GetStatus(), err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHealthFollowsComponents(t *testing.T) {
+	svc := &healthService{healthy: true}
+	conn := dialConn(t, svc, types.GRPCConfig{HealthInterval: 10 * time.Millisecond})
+	client := healthpb.NewHealthClient(conn)
+
+	waitForStatus(t, client, "", healthpb.HealthCheckResponse_SERVING)
+	waitForStatus(t, client, "tracking.v1.TripQueryService", healthpb.HealthCheckResponse_SERVING)
+
+	svc.setHealthy(false)
+	waitForStatus(t, client, "", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	svc.setHealthy(true)
+	waitForStatus(t, client, "tracking.v1.TripQueryService", healthpb.HealthCheckResponse_SERVING)
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown.Service"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown service, got %v", err)
+	}
+}
+
+func TestReflectionListsServices(t *testing.T) {
+	conn := dialConn(t, &fakeService{}, types.GRPCConfig{Reflection: true})
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open reflection stream: %v", err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("failed to send reflection request: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive reflection response: %v", err)
+	}
+
+	services := map[string]bool{}
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services[service.GetName()] = true
+	}
+	for _, want := range []string{"tracking.v1.TripQueryService", "grpc.health.v1.Health"} {
+		if !services[want] {
+			t.Errorf("expected reflection to list %s, got %v", want, services)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+
+	"data-ingestion-microservice/reporting"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoverUnary reports panicking unary handlers and fails the call with
+// Internal instead of crashing the process
+func recoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = recoverPanic(ctx, info.FullMethod, recovered)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoverStream reports panicking streaming handlers and fails the stream
+// with Internal instead of crashing the process
+func recoverStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = recoverPanic(stream.Context(), info.FullMethod, recovered)
+		}
+	}()
+	return handler(srv, stream)
+}
+
+// recoverPanic reports the value recovered from a panicking handler
+func recoverPanic(ctx context.Context, method string, recovered any) error {
+	slog.ErrorContext(ctx, "Recovered from panic in gRPC handler", "method", method, "panic", recovered)
+	reporting.Panic(ctx, recovered, "method", method)
+	return status.Error(codes.Internal, "internal error")
+}
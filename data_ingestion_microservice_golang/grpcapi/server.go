@@ -0,0 +1,299 @@
+// Package grpcapi serves the TripQueryService gRPC API so internal services
+// can read stored trips and stream live positions with typed contracts
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/tripquerypb"
+	"data-ingestion-microservice/types"
+)
+
+// Service is the part of the data ingestion service exposed over gRPC
+type Service interface {
+	FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error)
+	TripWithStops(ctx context.Context, id string) (*types.StoredTrip, []types.Stop, error)
+	SubscribeLive(filter service.StreamFilter) *service.LiveSubscription
+	ComponentHealth(ctx context.Context) map[string]bool
+}
+
+// defaultHealthInterval is how often backend health is polled when no
+// interval is configured
+const defaultHealthInterval = 5 * time.Second
+
+// Server serves the gRPC trip query API of the data ingestion service
+type Server struct {
+	tripquerypb.UnimplementedTripQueryServiceServer
+
+	config     types.GRPCConfig
+	service    Service
+	auth       Authenticator
+	server     *grpc.Server
+	health     *health.Server
+	healthCtx  context.Context
+	stopHealth context.CancelFunc
+}
+
+// NewServer creates a gRPC server for the given service, along with the
+// standard health service and, when enabled, server reflection
+func NewServer(config types.GRPCConfig, service Service) *Server {
+	return NewServerWithAuth(config, service, nil)
+}
+
+// NewServerWithAuth creates a gRPC server whose TripQueryService requires
+// JWTs validated by authenticator. A nil authenticator disables JWT
+// authentication.
+func NewServerWithAuth(config types.GRPCConfig, service Service, authenticator Authenticator) *Server {
+	if config.HealthInterval <= 0 {
+		config.HealthInterval = defaultHealthInterval
+	}
+
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	s := &Server{
+		config:     config,
+		service:    service,
+		auth:       authenticator,
+		health:     health.NewServer(),
+		healthCtx:  healthCtx,
+		stopHealth: stopHealth,
+	}
+
+	unary := []grpc.UnaryServerInterceptor{recoverUnary}
+	stream := []grpc.StreamServerInterceptor{recoverStream}
+	if authenticator != nil {
+		unary = append(unary, s.authUnary)
+		stream = append(stream, s.authStream)
+	}
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	tripquerypb.RegisterTripQueryServiceServer(s.server, s)
+
+	// Report not serving until the backends are checked
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	s.health.SetServingStatus(tripquerypb.TripQueryService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s.server, s.health)
+
+	if config.Reflection {
+		reflection.Register(s.server)
+	}
+	return s
+}
+
+// Start serves requests in the background until the server is stopped
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return err
+	}
+
+	go s.Serve(listener)
+	return nil
+}
+
+// Serve accepts connections on the listener until the server is stopped
+func (s *Server) Serve(listener net.Listener) {
+	go s.watchHealth(s.healthCtx)
+
+	slog.Info("gRPC API listening", "address", listener.Addr().String())
+	if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		slog.Error("gRPC server error", "error", err)
+	}
+}
+
+// Shutdown stops accepting requests and waits for active ones to complete.
+// Streams still open when ctx is done are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	// Tell health watchers and load balancers to drain first
+	s.stopHealth()
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// watchHealth polls the backends every interval until ctx is cancelled and
+// reports the server as serving only while all of them are healthy
+func (s *Server) watchHealth(ctx context.Context) {
+	ticker := time.NewTicker(s.config.HealthInterval)
+	defer ticker.Stop()
+
+	serving := healthpb.HealthCheckResponse_NOT_SERVING
+	for {
+		if next := s.updateHealth(ctx); next != serving {
+			slog.Info("gRPC health status changed", "status", next.String())
+			serving = next
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateHealth sets the serving status of the server and TripQueryService
+// from the backend health and returns it. The pings must finish within the
+// poll interval.
+func (s *Server) updateHealth(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	ctx, cancel := context.WithTimeout(ctx, s.config.HealthInterval)
+	defer cancel()
+
+	serving := healthpb.HealthCheckResponse_SERVING
+	for _, healthy := range s.service.ComponentHealth(ctx) {
+		if !healthy {
+			serving = healthpb.HealthCheckResponse_NOT_SERVING
+			break
+		}
+	}
+
+	// After Shutdown the status stays NOT_SERVING, so a late poll cannot
+	// revive it
+	s.health.SetServingStatus("", serving)
+	s.health.SetServingStatus(tripquerypb.TripQueryService_ServiceDesc.ServiceName, serving)
+	return serving
+}
+
+// GetTrip returns a stored trip with its detected stops
+func (s *Server) GetTrip(ctx context.Context, req *tripquerypb.GetTripRequest) (*tripquerypb.Trip, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	trip, stops, err := s.service.TripWithStops(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(err, "failed to load trip")
+	}
+
+	result := tripToProto(*trip)
+	for _, stop := range stops {
+		result.Stops = append(result.Stops, stopToProto(stop))
+	}
+	return result, nil
+}
+
+// ListTrips returns one page of stored trips
+func (s *Server) ListTrips(ctx context.Context, req *tripquerypb.ListTripsRequest) (*tripquerypb.ListTripsResponse, error) {
+	query := types.TripQuery{
+		DriverID:   req.GetDriverId(),
+		RouteID:    req.GetRouteId(),
+		From:       req.GetFrom(),
+		To:         req.GetTo(),
+		Descending: !req.GetAscending(),
+		Cursor:     req.GetPageToken(),
+	}
+	if query.From < 0 || query.To < 0 {
+		return nil, status.Error(codes.InvalidArgument, "from and to must be milliseconds since the epoch")
+	}
+
+	var err error
+	if query.Limit, err = s.pageSize(req.GetPageSize()); err != nil {
+		return nil, err
+	}
+
+	page, err := s.service.FindTrips(ctx, query)
+	if err != nil {
+		return nil, statusError(err, "failed to query trips")
+	}
+
+	resp := &tripquerypb.ListTripsResponse{NextPageToken: page.NextCursor}
+	for _, trip := range page.Trips {
+		resp.Trips = append(resp.Trips, tripToProto(trip))
+	}
+	return resp, nil
+}
+
+// StreamLivePositions streams every location processed that matches the
+// requested routes, drivers, and geofence until the client cancels or falls
+// too far behind
+func (s *Server) StreamLivePositions(req *tripquerypb.StreamLivePositionsRequest, stream tripquerypb.TripQueryService_StreamLivePositionsServer) error {
+	filter := service.StreamFilter{RouteIDs: req.GetRouteIds(), DriverIDs: req.GetDriverIds()}
+	for _, vertex := range req.GetGeofence() {
+		filter.Geofence = append(filter.Geofence, types.Location{Latitude: vertex.GetLatitude(), Longitude: vertex.GetLongitude()})
+	}
+	if len(filter.RouteIDs) == 0 && len(filter.DriverIDs) == 0 && len(filter.Geofence) == 0 {
+		return status.Error(codes.InvalidArgument, "at least one of route_ids, driver_ids, or geofence is required")
+	}
+	if err := filter.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := s.service.SubscribeLive(filter)
+	defer sub.Close()
+
+	for {
+		select {
+		case busMsg, ok := <-sub.Updates():
+			if !ok {
+				if sub.Dropped() {
+					return status.Error(codes.ResourceExhausted, "client fell too far behind the live stream")
+				}
+				return status.Error(codes.Unavailable, "live stream closed")
+			}
+			if err := stream.Send(livePositionToProto(busMsg)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// pageSize applies the configured default and maximum to a requested page
+// size
+func (s *Server) pageSize(requested int32) (int, error) {
+	if requested < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid page_size %d", requested)
+	}
+
+	size := int(requested)
+	if size == 0 {
+		size = s.config.DefaultPageSize
+	}
+	if s.config.MaxPageSize > 0 && size > s.config.MaxPageSize {
+		size = s.config.MaxPageSize
+	}
+	return size, nil
+}
+
+// statusError maps a service error to a gRPC status, hiding the details of
+// unexpected errors from clients
+func statusError(err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrTripNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, database.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrTripQueriesUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		slog.Error("gRPC request failed", "message", message, "error", err)
+		return status.Error(codes.Internal, message)
+	}
+}
@@ -0,0 +1,252 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/tripquerypb"
+	"data-ingestion-microservice/types"
+)
+
+// fakeService is an in-memory Service used by the server tests
+type fakeService struct {
+	tripQuery  types.TripQuery
+	tripPage   types.TripPage
+	tripErr    error
+	trip       *types.StoredTrip
+	stops      []types.Stop
+	stream     *service.LiveStream
+	subscribed chan struct{}
+	components map[string]bool
+}
+
+func (f *fakeService) FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error) {
+	f.tripQuery = query
+	return f.tripPage, f.tripErr
+}
+
+func (f *fakeService) TripWithStops(ctx context.Context, id string) (*types.StoredTrip, []types.Stop, error) {
+	if f.trip == nil || f.trip.ID != id {
+		return nil, nil, service.ErrTripNotFound
+	}
+	return f.trip, f.stops, nil
+}
+
+func (f *fakeService) SubscribeLive(filter service.StreamFilter) *service.LiveSubscription {
+	sub := f.stream.Subscribe(filter)
+	if f.subscribed != nil {
+		f.subscribed <- struct{}{}
+	}
+	return sub
+}
+
+func (f *fakeService) ComponentHealth(ctx context.Context) map[string]bool {
+	return f.components
+}
+
+// dial starts the server on an in-memory listener and returns a client
+func dial(t *testing.T, svc Service) tripquerypb.TripQueryServiceClient {
+	t.Helper()
+	return tripquerypb.NewTripQueryServiceClient(dialConn(t, svc, types.GRPCConfig{DefaultPageSize: 20, MaxPageSize: 50}))
+}
+
+// dialConn starts a server with the given config on an in-memory listener
+// and returns a connection to it
+func dialConn(t *testing.T, svc Service, config types.GRPCConfig) *grpc.ClientConn {
+	t.Helper()
+	return dialServer(t, NewServer(config, svc))
+}
+
+// dialServer starts a server on an in-memory listener and returns a
+// connection to it
+func dialServer(t *testing.T, server *Server) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestGetTrip(t *testing.T) {
+	svc := &fakeService{
+		trip: &types.StoredTrip{
+			ID:              "trip_1",
+			DriverID:        "driver_001",
+			CurrentRouteID:  "route_1",
+			SimplifiedRoute: []types.RoutePoint{{Location: types.Location{Latitude: 6.2, Longitude: -75.5}}, {Location: types.Location{Latitude: 6.3, Longitude: -75.6}}},
+			Stats:           types.TripStats{DistanceMeters: 1200, Stops: 1},
+			CreatedAt:       time.UnixMilli(1700000000000),
+		},
+		stops: []types.Stop{{Location: types.Location{Latitude: 6.25, Longitude: -75.55}, DurationSeconds: 90}},
+	}
+	client := dial(t, svc)
+
+	trip, err := client.GetTrip(context.Background(), &tripquerypb.GetTripRequest{Id: "trip_1"})
+	if err != nil {
+		t.Fatalf("GetTrip failed: %v", err)
+	}
+	if trip.GetRouteId() != "route_1" || len(trip.GetSimplifiedRoute()) != 2 || len(trip.GetStops()) != 1 {
+		t.Errorf("unexpected trip: %v", trip)
+	}
+	if trip.GetStats().GetDistanceMeters() != 1200 || !trip.GetCreatedAt().AsTime().Equal(svc.trip.CreatedAt) {
+		t.Errorf("unexpected stats or creation time: %v", trip)
+	}
+
+	_, err = client.GetTrip(context.Background(), &tripquerypb.GetTripRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	_, err = client.GetTrip(context.Background(), &tripquerypb.GetTripRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestListTrips(t *testing.T) {
+	svc := &fakeService{
+		tripPage: types.TripPage{Trips: []types.StoredTrip{{ID: "trip_1"}}, NextCursor: "next"},
+	}
+	client := dial(t, svc)
+
+	resp, err := client.ListTrips(context.Background(), &tripquerypb.ListTripsRequest{
+		DriverId:  "driver_001",
+		From:      1000,
+		PageSize:  500,
+		PageToken: "cursor",
+	})
+	if err != nil {
+		t.Fatalf("ListTrips failed: %v", err)
+	}
+	if len(resp.GetTrips()) != 1 || resp.GetNextPageToken() != "next" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	query := svc.tripQuery
+	if query.DriverID != "driver_001" || query.From != 1000 || query.Cursor != "cursor" || !query.Descending {
+		t.Errorf("unexpected query: %+v", query)
+	}
+	if query.Limit != 50 {
+		t.Errorf("expected page size capped at 50, got %d", query.Limit)
+	}
+
+	if _, err := client.ListTrips(context.Background(), &tripquerypb.ListTripsRequest{Ascending: true}); err != nil {
+		t.Fatalf("ListTrips failed: %v", err)
+	}
+	if svc.tripQuery.Limit != 20 || svc.tripQuery.Descending {
+		t.Errorf("expected default page size in ascending order, got %+v", svc.tripQuery)
+	}
+}
+
+func TestListTripsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		req  *tripquerypb.ListTripsRequest
+		code codes.Code
+	}{
+		{"negative page size", nil, &tripquerypb.ListTripsRequest{PageSize: -1}, codes.InvalidArgument},
+		{"invalid cursor", database.ErrInvalidCursor, &tripquerypb.ListTripsRequest{}, codes.InvalidArgument},
+		{"unsupported", service.ErrTripQueriesUnsupported, &tripquerypb.ListTripsRequest{}, codes.Unimplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dial(t, &fakeService{tripErr: tt.err})
+			_, err := client.ListTrips(context.Background(), tt.req)
+			if status.Code(err) != tt.code {
+				t.Errorf("expected %v, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestStreamLivePositions(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8), subscribed: make(chan struct{}, 1)}
+	client := dial(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.StreamLivePositions(ctx, &tripquerypb.StreamLivePositionsRequest{RouteIds: []string{"route_1"}})
+	if err != nil {
+		t.Fatalf("StreamLivePositions failed: %v", err)
+	}
+	<-svc.subscribed
+
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_002", CurrentRouteID: "route_2"})
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_001", CurrentRouteID: "route_1", Status: "in_route", Timestamp: 42})
+
+	position, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if position.GetDriverId() != "driver_001" || position.GetRouteId() != "route_1" || position.GetTimestamp() != 42 {
+		t.Errorf("unexpected position: %v", position)
+	}
+}
+
+func TestStreamLivePositionsFiltersDrivers(t *testing.T) {
+	svc := &fakeService{stream: service.NewLiveStream(8), subscribed: make(chan struct{}, 1)}
+	client := dial(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.StreamLivePositions(ctx, &tripquerypb.StreamLivePositionsRequest{DriverIds: []string{"driver_002"}})
+	if err != nil {
+		t.Fatalf("StreamLivePositions failed: %v", err)
+	}
+	<-svc.subscribed
+
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_001", CurrentRouteID: "route_1"})
+	svc.stream.Publish(types.BusMessage{DriverID: "driver_002", CurrentRouteID: "route_2"})
+
+	position, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if position.GetDriverId() != "driver_002" {
+		t.Errorf("unexpected position: %v", position)
+	}
+}
+
+func TestStreamLivePositionsRequiresFilter(t *testing.T) {
+	client := dial(t, &fakeService{stream: service.NewLiveStream(8)})
+
+	for _, req := range []*tripquerypb.StreamLivePositionsRequest{
+		{},
+		{Geofence: []*tripquerypb.Location{{Latitude: 1, Longitude: 1}, {Latitude: 2, Longitude: 2}}},
+	} {
+		stream, err := client.StreamLivePositions(context.Background(), req)
+		if err != nil {
+			t.Fatalf("StreamLivePositions failed: %v", err)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected InvalidArgument for %v, got %v", req, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package logging configures the structured, leveled logger of the service.
+// Records are written as JSON or text and carry the correlation fields
+// attached to their context, such as the driver, route, trip, and request,
+// along with the IDs of the current trace and span.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Setup installs the configured logger as the slog default. Output of the
+// standard log package, used by some dependencies, goes through it as well.
+func Setup(config types.LogConfig) (*slog.Logger, error) {
+	logger, err := New(config, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// New creates a logger writing to w
+func New(config types.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q, expected debug, info, warn, or error", config.Level)
+	}
+
+	options := &slog.HandlerOptions{Level: level, AddSource: config.Source}
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected %s or %s", config.Format, FormatJSON, FormatText)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// fieldsKey is the context key of the correlation fields
+type fieldsKey struct{}
+
+// With returns ctx with correlation fields, given as alternating keys and
+// values, that every record logged with the context includes. Fields
+// replace earlier fields with the same key.
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+	added := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		added[attr.Key] = true
+		return true
+	})
+
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	next := make([]slog.Attr, 0, len(fields)+len(added))
+	for _, field := range fields {
+		if !added[field.Key] {
+			next = append(next, field)
+		}
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		next = append(next, attr)
+		return true
+	})
+	return context.WithValue(ctx, fieldsKey{}, next)
+}
+
+// Fields returns the correlation fields of ctx
+func Fields(ctx context.Context) []slog.Attr {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return slices.Clone(fields)
+}
+
+// Duration returns a field with a duration in milliseconds
+func Duration(key string, d time.Duration) slog.Attr {
+	return slog.Float64(key, float64(d.Microseconds())/1000)
+}
+
+// contextHandler adds the correlation fields and trace of the record's
+// context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context fields before passing the record on
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields, ok := ctx.Value(fieldsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(fields...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("traceId", spanContext.TraceID().String()),
+			slog.String("spanId", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the context handler around handlers with added fields
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handler around grouped handlers
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew_RejectsInvalidConfig(t *testing.T) {
+	for _, config := range []types.LogConfig{
+		{Level: "loud", Format: FormatJSON},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := New(config, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestLogger_WritesContextFields(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(types.LogConfig{Level: "info", Format: FormatJSON}, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx := With(context.Background(), "driverId", "driver-1", "routeId", "route-1")
+	ctx = With(ctx, "routeId", "route-2", "tripId", "trip-1")
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	}))
+
+	logger.DebugContext(ctx, "Hidden below the level")
+	logger.InfoContext(ctx, "Stored trip", Duration("durationMs", 1500*time.Microsecond))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 record, got %q", out.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q", lines[0])
+	}
+
+	want := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "Stored trip",
+		"driverId":   "driver-1",
+		"routeId":    "route-2",
+		"tripId":     "trip-1",
+		"durationMs": 1.5,
+		"traceId":    "01000000000000000000000000000000",
+		"spanId":     "0200000000000000",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+	if strings.Count(lines[0], `"routeId"`) != 1 {
+		t.Errorf("Expected the later routeId to replace the earlier one, got %s", lines[0])
+	}
+}
@@ -2,55 +2,142 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	"data-ingestion-microservice/config"
+	"data-ingestion-microservice/api"
+	"data-ingestion-microservice/auth"
+	"data-ingestion-microservice/grpcapi"
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/reporting"
 	"data-ingestion-microservice/service"
+	"data-ingestion-microservice/tracing"
 )
 
-func main() {
-	// Initialize logging
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("🚀 Starting Distributed GPS Route Tracking System - Data Ingestion Microservice (Go)")
-
+// serve runs the service until it receives SIGINT or SIGTERM
+func serve(args []string) {
 	// Create context for the application
 	ctx := context.Background()
 
-	// Load configuration from environment variables
-	cfg := config.LoadConfig()
-	log.Printf("Configuration loaded:")
-	log.Printf("  MQTT: %s:%d (topic: %s)", cfg.MQTT.Broker, cfg.MQTT.Port, cfg.MQTT.Topic)
-	log.Printf("  Redis: %s", cfg.Redis.Address)
-	log.Printf("  MongoDB: %s (database: %s)", cfg.MongoDB.URI, cfg.MongoDB.Database)
-	log.Printf("  Route tolerance: %f", cfg.RouteSimplification.Tolerance)
+	// Load configuration from flags, environment variables, and the config file
+	cfg, err := loadConfig("serve", args)
+	if err != nil {
+		reportConfigError(err)
+		os.Exit(1)
+	}
+
+	// Initialize structured logging
+	if _, err := logging.Setup(cfg.Log); err != nil {
+		slog.Error("Failed to initialize logging", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("🚀 Starting Distributed GPS Route Tracking System - Data Ingestion Microservice (Go)")
+	slog.Info("Configuration loaded",
+		"mqttBroker", cfg.MQTT.Broker,
+		"mqttPort", cfg.MQTT.Port,
+		"mqttTopic", cfg.MQTT.Topic,
+		"redisAddress", cfg.Redis.Address,
+		"mongoDatabase", cfg.MongoDB.Database,
+		"routeTolerance", cfg.RouteSimplification.Tolerance,
+	)
+
+	// Export pipeline traces over OTLP if enabled
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+
+	// Report panics and repeated processing errors to Sentry if configured
+	flushReports, err := reporting.Setup(cfg.ErrorReporting)
+	if err != nil {
+		slog.Error("Failed to initialize error reporting", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize the data ingestion service
 	dataService, err := service.NewDataIngestionService(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize data ingestion service: %v", err)
+		slog.Error("Failed to initialize data ingestion service", "error", err)
+		os.Exit(1)
 	}
 	defer dataService.Close()
 
+	// Validate the JWTs of API clients if enabled
+	var httpAuth api.Authenticator
+	var grpcAuth grpcapi.Authenticator
+	if cfg.Auth.Enabled {
+		verifier := auth.NewVerifier(cfg.Auth)
+		if err := verifier.Refresh(ctx); err != nil {
+			slog.Warn("Failed to fetch the JWT signing keys, retrying on the next request", "url", cfg.Auth.JWKSURL, "error", err)
+		}
+		httpAuth, grpcAuth = verifier, verifier
+	}
+
+	// Start the HTTP API for health checks
+	var httpServer *api.Server
+	if cfg.HTTP.Enabled {
+		httpServer = api.NewServerWithAuth(cfg.HTTP, dataService, httpAuth)
+		httpServer.Start()
+	}
+
+	// Start the gRPC query API for internal services
+	var grpcServer *grpcapi.Server
+	if cfg.GRPC.Enabled {
+		grpcServer = grpcapi.NewServerWithAuth(cfg.GRPC, dataService, grpcAuth)
+		if err := grpcServer.Start(); err != nil {
+			slog.Error("Failed to start gRPC server", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("✅ Data ingestion microservice started successfully!")
-	log.Println("📊 Health status:", dataService.GetHealthStatus())
-	log.Println("🔄 Processing MQTT messages... Press Ctrl+C to exit.")
+	slog.Info("✅ Data ingestion microservice started successfully!")
+	slog.Info("📊 Health status", "health", dataService.GetHealthStatus(ctx))
+	slog.Info("🔄 Processing MQTT messages... Press Ctrl+C to exit.")
 
 	// Wait for shutdown signal
 	<-sigChan
-	log.Println("🛑 Shutdown signal received, cleaning up...")
+	slog.Info("🛑 Shutdown signal received, cleaning up...")
 
 	// Graceful shutdown
+	if httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("❌ Error shutting down HTTP server", "error", err)
+		}
+		cancel()
+	}
+	if grpcServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("❌ Error shutting down gRPC server", "error", err)
+		}
+		cancel()
+	}
+
 	if err := dataService.Close(); err != nil {
-		log.Printf("❌ Error during shutdown: %v", err)
+		slog.Error("❌ Error during shutdown", "error", err)
 		os.Exit(1)
 	}
 
-	log.Println("✅ Data ingestion microservice shut down gracefully")
-} 
\ No newline at end of file
+	// Flush the spans of the last finalizations
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("❌ Error flushing traces", "error", err)
+	}
+	cancel()
+
+	// Deliver the error reports still queued
+	if !flushReports(5 * time.Second) {
+		slog.Error("❌ Error reports were not delivered before shutdown")
+	}
+
+	slog.Info("✅ Data ingestion microservice shut down gracefully")
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// recentWeight is the weight of each new observation in the moving average
+// of recent observations
+const recentWeight = 0.2
+
+// Histogram is an expvar variable counting observations in cumulative
+// buckets, like a Prometheus histogram. It also keeps an exponentially
+// weighted moving average of recent observations for consumers that need a
+// single current value, such as an autoscaler.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+	recent float64
+}
+
+// NewHistogram creates and publishes a histogram with the given ascending
+// bucket upper bounds. Observations above the last bound only count toward
+// the implicit +Inf bucket.
+func NewHistogram(name string, bounds []float64) *Histogram {
+	h := &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+	expvar.Publish(name, h)
+	return h
+}
+
+// Observe records a value
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	if h.count == 0 {
+		h.recent = value
+	} else {
+		h.recent += recentWeight * (value - h.recent)
+	}
+	h.count++
+	h.sum += value
+}
+
+// Recent returns the moving average of recent observations, or 0 before
+// the first one
+func (h *Histogram) Recent() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.recent
+}
+
+// bucket is a cumulative histogram bucket
+type bucket struct {
+	UpperBound string `json:"le"`
+	Count      uint64 `json:"count"`
+}
+
+// String renders the histogram as JSON for expvar
+func (h *Histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]bucket, 0, len(h.bounds)+1)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		buckets = append(buckets, bucket{UpperBound: strconv.FormatFloat(bound, 'g', -1, 64), Count: cumulative})
+	}
+	buckets = append(buckets, bucket{UpperBound: "+Inf", Count: h.count})
+
+	data, _ := json.Marshal(struct {
+		Count   uint64   `json:"count"`
+		Sum     float64  `json:"sum"`
+		Recent  float64  `json:"recent"`
+		Buckets []bucket `json:"buckets"`
+	}{h.count, round(h.sum), round(h.recent), buckets})
+	return string(data)
+}
+
+// round keeps millisecond precision for values in seconds
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := &Histogram{bounds: []float64{1, 5}, counts: make([]uint64, 2)}
+	if h.Recent() != 0 {
+		t.Errorf("Expected no recent value before the first observation, got %v", h.Recent())
+	}
+	for _, value := range []float64{0.5, 1, 3, 10} {
+		h.Observe(value)
+	}
+
+	var rendered struct {
+		Count   uint64  `json:"count"`
+		Sum     float64 `json:"sum"`
+		Buckets []struct {
+			UpperBound string `json:"le"`
+			Count      uint64 `json:"count"`
+		} `json:"buckets"`
+	}
+	if err := json.Unmarshal([]byte(h.String()), &rendered); err != nil {
+		t.Fatalf("Expected JSON, got %q", h.String())
+	}
+	if rendered.Count != 4 || rendered.Sum != 14.5 {
+		t.Errorf("Expected count 4 and sum 14.5, got %d and %v", rendered.Count, rendered.Sum)
+	}
+
+	want := []struct {
+		upperBound string
+		count      uint64
+	}{{"1", 2}, {"5", 3}, {"+Inf", 4}}
+	if len(rendered.Buckets) != len(want) {
+		t.Fatalf("Expected %d buckets, got %+v", len(want), rendered.Buckets)
+	}
+	for i, bucket := range want {
+		if rendered.Buckets[i].UpperBound != bucket.upperBound || rendered.Buckets[i].Count != bucket.count {
+			t.Errorf("Expected bucket %d to be le=%s count=%d, got %+v", i, bucket.upperBound, bucket.count, rendered.Buckets[i])
+		}
+	}
+
+	// The moving average follows recent observations
+	if recent := h.Recent(); recent <= 1 || recent >= 10 {
+		t.Errorf("Expected a recent value between the observations, got %v", recent)
+	}
+}
@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"expvar"
+	"runtime"
+	"time"
+)
+
+// Finalization worker pool metrics, published through expvar
+var (
+	FinalizationQueueDepth = expvar.NewInt("finalization_queue_depth")
+	FinalizationQueueSize  = expvar.NewInt("finalization_queue_size")
+	FinalizationBusy       = expvar.NewInt("finalization_workers_busy")
+	FinalizationProcessed  = expvar.NewInt("finalization_processed_total")
+	FinalizationFailed     = expvar.NewInt("finalization_failed_total")
+	FinalizationResumed    = expvar.NewInt("finalization_resumed_total")
+)
+
+// MongoDB trip batch writer metrics
+var (
+	TripBatchFlushes   = expvar.NewInt("trip_batch_flushes_total")
+	TripBatchFallbacks = expvar.NewInt("trip_batch_fallbacks_total")
+)
+
+// Trip sink metrics
+var (
+	ClickHouseBatchFlushes = expvar.NewInt("clickhouse_batch_flushes_total")
+)
+
+// Redis route buffer metrics
+var (
+	RouteBuffersExpired     = expvar.NewInt("route_buffers_expired_total")
+	RedisPipelineFlushes    = expvar.NewInt("redis_pipeline_flushes_total")
+	RouteBuffersDownsampled = expvar.NewInt("route_buffers_downsampled_total")
+	DuplicatePoints         = expvar.NewInt("route_points_duplicate_total")
+	RoutesAutoClosed        = expvar.NewInt("routes_auto_closed_total")
+	RoutesSegmented         = expvar.NewMap("routes_segmented_total")
+)
+
+// Live location stream metrics
+var (
+	LiveStreamSubscribers = expvar.NewInt("live_stream_subscribers")
+	LiveStreamDropped     = expvar.NewInt("live_stream_dropped_total")
+	FleetEventSubscribers = expvar.NewInt("fleet_event_subscribers")
+	FleetEventsDropped    = expvar.NewInt("fleet_event_subscribers_dropped_total")
+)
+
+// Webhook dispatcher metrics
+var (
+	WebhookQueueDepth = expvar.NewInt("webhook_queue_depth")
+	WebhookDelivered  = expvar.NewInt("webhook_delivered_total")
+	WebhookRetries    = expvar.NewInt("webhook_retries_total")
+	WebhookFailed     = expvar.NewInt("webhook_dead_letters_total")
+)
+
+// Public position feed metrics
+var (
+	PublicFeedPublished = expvar.NewInt("public_feed_published_total")
+	PublicFeedFailed    = expvar.NewInt("public_feed_failed_total")
+)
+
+// Geofencing: geofences evaluated, and entries and exits by event type
+var (
+	GeofencesLoaded = expvar.NewInt("geofences_loaded")
+	GeofenceEvents  = expvar.NewMap("geofence_events_total")
+)
+
+// Device message signatures: device keys loaded, and messages dropped by
+// the reason their signature was rejected
+var (
+	DeviceKeysLoaded   = expvar.NewInt("device_keys_loaded")
+	SignaturesRejected = expvar.NewMap("signatures_rejected_total")
+)
+
+// Speeding violations reported while they happen
+var SpeedingEvents = expvar.NewInt("speeding_events_total")
+
+// Stop arrivals and departures by event type
+var StopEvents = expvar.NewMap("stop_events_total")
+
+// Bunching and headway gaps by event type
+var HeadwayEvents = expvar.NewMap("headway_events_total")
+
+// Retries of transient failures by operation
+var Retries = expvar.NewMap("retries_total")
+
+// Circuit breakers by dependency: the state (0 closed, 1 half-open, 2 open)
+// and how often each breaker opened
+var (
+	CircuitBreakerState  = expvar.NewMap("circuit_breaker_state")
+	CircuitBreakerOpened = expvar.NewMap("circuit_breaker_opened_total")
+)
+
+// Retransmitted messages dropped by reason: duplicate or stale
+var DuplicatesDropped = expvar.NewMap("duplicates_dropped_total")
+
+// Locations dropped by the per-driver rate limit
+var RateLimited = expvar.NewInt("rate_limited_total")
+
+// Work partitioning between service instances: live cluster members, drivers
+// leased by this instance, messages left to the owning instance, messages
+// deferred until the previous owner hands a driver over, changes of the
+// ring, and whether this instance leads the cluster
+var (
+	PartitionLeader     = expvar.NewInt("partition_leader")
+	PartitionMembers    = expvar.NewInt("partition_members")
+	PartitionDrivers    = expvar.NewInt("partition_owned_drivers")
+	PartitionSkipped    = expvar.NewInt("partition_skipped_total")
+	PartitionDeferred   = expvar.NewInt("partition_deferred_total")
+	PartitionRebalances = expvar.NewInt("partition_rebalances_total")
+)
+
+// Active-passive failover: whether this instance is the active one, and the
+// number of times it became active
+var (
+	StandbyActive      = expvar.NewInt("standby_active")
+	StandbyActivations = expvar.NewInt("standby_activations_total")
+)
+
+// Credential refreshes from the secrets provider: secrets that changed, and
+// refreshes that failed
+var (
+	SecretRotations       = expvar.NewInt("secret_rotations_total")
+	SecretRefreshFailures = expvar.NewInt("secret_refresh_failures_total")
+)
+
+// Processing failures by class: decode, validation, redis, mongo, simplify,
+// export, or other
+var ProcessingErrors = expvar.NewMap("processing_errors_total")
+
+// Ingestion lag metrics. The lag is the delay between the timestamp a
+// device stamped on a message and the moment it was processed; the backlog
+// is the number of messages and finalizations waiting to be processed.
+var (
+	IngestLag = NewHistogram("ingest_lag_seconds", []float64{
+		0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300,
+	})
+	IngestInFlight      = expvar.NewInt("ingest_messages_in_flight")
+	FinalizationBacklog = expvar.NewInt("finalization_stream_backlog")
+)
+
+// Ingest throughput: messages processed, and the rate over the last sample
+// interval
+var (
+	IngestProcessed  = expvar.NewInt("ingest_messages_processed_total")
+	IngestThroughput = expvar.NewFloat("ingest_messages_per_second")
+)
+
+// Ingest queue metrics. Messages that overflow the queue are dropped or
+// spilled to disk depending on the overflow policy. Messages that failed on
+// a backend are retried through the spill buffer, or redelivered by the
+// broker after a reconnect without one.
+var (
+	IngestQueueDepth   = expvar.NewInt("ingest_queue_depth")
+	IngestQueueSize    = expvar.NewInt("ingest_queue_size")
+	IngestDropped      = expvar.NewInt("ingest_dropped_total")
+	IngestSpilled      = expvar.NewInt("ingest_spilled_total")
+	IngestSpillBacklog = expvar.NewInt("ingest_spill_backlog")
+	IngestPaused       = expvar.NewInt("ingest_paused_total")
+	IngestRetried      = expvar.NewInt("ingest_retried_total")
+	IngestRedeliveries = expvar.NewInt("ingest_redeliveries_total")
+)
+
+// Processing latency by stage, in seconds. Messages are decoded and
+// written to the route buffer; finalizations read the buffer back, simplify
+// the route, and insert the trip.
+var (
+	DecodeLatency       = NewHistogram("stage_decode_seconds", latencyBounds)
+	RedisWriteLatency   = NewHistogram("stage_redis_write_seconds", latencyBounds)
+	RedisReadLatency    = NewHistogram("stage_redis_read_seconds", latencyBounds)
+	SimplifyLatency     = NewHistogram("stage_simplify_seconds", latencyBounds)
+	MongoInsertLatency  = NewHistogram("stage_mongo_insert_seconds", latencyBounds)
+	MessageLatency      = NewHistogram("message_processing_seconds", latencyBounds)
+	FinalizationLatency = NewHistogram("finalization_seconds", latencyBounds)
+)
+
+// latencyBounds are the bucket bounds of the latency histograms, in seconds
+var latencyBounds = []float64{
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// startTime is when the process started
+var startTime = time.Now()
+
+// Runtime gauges, computed whenever the metrics are read
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("heap_inuse_bytes", expvar.Func(func() any {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapInuse
+	}))
+	expvar.Publish("uptime_seconds", expvar.Func(func() any {
+		return int64(time.Since(startTime).Seconds())
+	}))
+}
+
+// Snapshot returns the current value of every published metric
+func Snapshot() map[string]string {
+	snapshot := make(map[string]string)
+	expvar.Do(func(kv expvar.KeyValue) {
+		// Skip the large runtime variables registered by the expvar package
+		if kv.Key == "memstats" || kv.Key == "cmdline" {
+			return
+		}
+		snapshot[kv.Key] = kv.Value.String()
+	})
+	return snapshot
+}
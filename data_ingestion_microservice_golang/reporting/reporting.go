@@ -0,0 +1,149 @@
+// Package reporting sends panics and repeated processing errors to an error
+// tracker such as Sentry. Reports are tagged with the correlation fields of
+// their context, such as the driver, route, trip, and request, and with the
+// current trace. While no tracker is configured, reports are discarded.
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/types"
+
+	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reporter delivers error reports to an error tracker
+type Reporter interface {
+	// CaptureError reports an error
+	CaptureError(ctx context.Context, err error, tags map[string]string)
+	// CapturePanic reports the value recovered from a panic
+	CapturePanic(ctx context.Context, recovered any, tags map[string]string)
+	// Flush waits up to timeout for queued reports to be delivered
+	Flush(timeout time.Duration) bool
+}
+
+// reporterBox lets the default reporter be swapped atomically
+type reporterBox struct {
+	Reporter
+}
+
+// current is the reporter used by Error and Panic
+var current atomic.Pointer[reporterBox]
+
+func init() {
+	SetDefault(nopReporter{})
+}
+
+// SetDefault makes r the reporter used by Error and Panic
+func SetDefault(r Reporter) {
+	current.Store(&reporterBox{r})
+}
+
+// Default returns the reporter used by Error and Panic
+func Default() Reporter {
+	return current.Load().Reporter
+}
+
+// Setup installs a Sentry reporter as the default when a DSN is configured.
+// The returned function delivers queued reports and must be called on
+// shutdown.
+func Setup(config types.ErrorReportingConfig) (func(time.Duration) bool, error) {
+	if config.DSN == "" {
+		return func(time.Duration) bool { return true }, nil
+	}
+
+	reporter, err := NewSentry(config)
+	if err != nil {
+		return nil, err
+	}
+	SetDefault(reporter)
+	slog.Info("Reporting errors to Sentry", "environment", config.Environment)
+	return reporter.Flush, nil
+}
+
+// Error reports an error with the correlation fields of ctx and the given
+// tags, as alternating keys and values
+func Error(ctx context.Context, err error, args ...any) {
+	Default().CaptureError(ctx, err, tags(ctx, args))
+}
+
+// Panic reports a recovered panic with the correlation fields of ctx and
+// the given tags, as alternating keys and values
+func Panic(ctx context.Context, recovered any, args ...any) {
+	Default().CapturePanic(ctx, recovered, tags(ctx, args))
+}
+
+// tags merges the correlation fields and trace of ctx with args
+func tags(ctx context.Context, args []any) map[string]string {
+	tags := make(map[string]string)
+	for _, field := range logging.Fields(ctx) {
+		tags[field.Key] = field.Value.String()
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		tags["traceId"] = spanContext.TraceID().String()
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		tags[fmt.Sprint(args[i])] = fmt.Sprint(args[i+1])
+	}
+	return tags
+}
+
+// nopReporter discards every report
+type nopReporter struct{}
+
+// CaptureError discards the error
+func (nopReporter) CaptureError(context.Context, error, map[string]string) {}
+
+// CapturePanic discards the panic
+func (nopReporter) CapturePanic(context.Context, any, map[string]string) {}
+
+// Flush returns immediately since nothing is queued
+func (nopReporter) Flush(time.Duration) bool {
+	return true
+}
+
+// SentryReporter reports to Sentry
+type SentryReporter struct {
+	hub *sentry.Hub
+}
+
+// NewSentry creates a reporter for the configured Sentry DSN
+func NewSentry(config types.ErrorReportingConfig) (*SentryReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              config.DSN,
+		Environment:      config.Environment,
+		Release:          config.Release,
+		SampleRate:       config.SampleRate,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sentry client: %w", err)
+	}
+	return &SentryReporter{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+// CaptureError reports an error with its tags
+func (r *SentryReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	hub := r.hub.Clone()
+	hub.Scope().SetTags(tags)
+	hub.CaptureException(err)
+}
+
+// CapturePanic reports a recovered panic with its tags and stack trace
+func (r *SentryReporter) CapturePanic(ctx context.Context, recovered any, tags map[string]string) {
+	hub := r.hub.Clone()
+	hub.Scope().SetTags(tags)
+	hub.Scope().SetLevel(sentry.LevelFatal)
+	hub.RecoverWithContext(ctx, recovered)
+}
+
+// Flush waits up to timeout for queued reports to be delivered
+func (r *SentryReporter) Flush(timeout time.Duration) bool {
+	return r.hub.Flush(timeout)
+}
@@ -0,0 +1,108 @@
+package reporting
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/types"
+)
+
+// recordingReporter keeps the tags of every report
+type recordingReporter struct {
+	mu     sync.Mutex
+	errors []map[string]string
+	panics []map[string]string
+}
+
+func (r *recordingReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, tags)
+}
+
+func (r *recordingReporter) CapturePanic(ctx context.Context, recovered any, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panics = append(r.panics, tags)
+}
+
+func (r *recordingReporter) Flush(timeout time.Duration) bool {
+	return true
+}
+
+func TestError_TagsContextFields(t *testing.T) {
+	reporter := &recordingReporter{}
+	previous := Default()
+	SetDefault(reporter)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	ctx := logging.With(context.Background(), "driverId", "driver-1", "routeId", "route-1")
+	Error(ctx, errors.New("redis unavailable"), "class", "redis", "occurrences", 5)
+	Panic(ctx, "boom")
+
+	if len(reporter.errors) != 1 || len(reporter.panics) != 1 {
+		t.Fatalf("Expected one error and one panic, got %d and %d", len(reporter.errors), len(reporter.panics))
+	}
+	want := map[string]string{"driverId": "driver-1", "routeId": "route-1", "class": "redis", "occurrences": "5"}
+	for key, value := range want {
+		if reporter.errors[0][key] != value {
+			t.Errorf("Expected tag %s=%s, got %v", key, value, reporter.errors[0])
+		}
+	}
+	if reporter.panics[0]["driverId"] != "driver-1" {
+		t.Errorf("Expected the panic to carry the context fields, got %v", reporter.panics[0])
+	}
+}
+
+func TestSentryReporter_SendsEvents(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		data, _ := io.ReadAll(body)
+		mu.Lock()
+		received = append(received, string(data))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "http://", "http://public@", 1) + "/1"
+	reporter, err := NewSentry(types.ErrorReportingConfig{DSN: dsn, Environment: "test", SampleRate: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	reporter.CaptureError(context.Background(), errors.New("mongo unavailable"), map[string]string{"driverId": "driver-1"})
+	if !reporter.Flush(5 * time.Second) {
+		t.Fatalf("Expected the event to be delivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(received))
+	}
+	for _, want := range []string{"mongo unavailable", `"driverId":"driver-1"`, `"environment":"test"`} {
+		if !strings.Contains(received[0], want) {
+			t.Errorf("Expected the event to contain %s, got %s", want, received[0])
+		}
+	}
+}
+
+func TestNewSentry_RejectsInvalidDSN(t *testing.T) {
+	if _, err := NewSentry(types.ErrorReportingConfig{DSN: "not a dsn"}); err == nil {
+		t.Errorf("Expected an error for an invalid DSN")
+	}
+}
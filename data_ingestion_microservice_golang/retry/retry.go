@@ -0,0 +1,74 @@
+// Package retry retries operations that fail transiently, waiting an
+// exponentially growing, jittered delay between attempts so that a brief
+// Redis or MongoDB outage does not turn into lost locations or trips.
+package retry
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Policy decides how often and how fast failed operations are retried
+type Policy struct {
+	config    types.RetryConfig
+	retryable func(err error) bool
+}
+
+// NewPolicy creates a policy retrying the errors for which retryable
+// returns true
+func NewPolicy(config types.RetryConfig, retryable func(err error) bool) *Policy {
+	return &Policy{config: config, retryable: retryable}
+}
+
+// Do calls op until it succeeds, fails with an error that is not retryable,
+// runs out of attempts, or ctx is done, and returns its last error. The
+// operation name labels the retry logs and the retries_total metric.
+func (p *Policy) Do(ctx context.Context, operation string, op func() error) error {
+	attempts := max(p.config.Attempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= attempts || !p.retryable(err) {
+			return err
+		}
+
+		delay := p.Backoff(attempt)
+		metrics.Retries.Add(operation, 1)
+		slog.WarnContext(ctx, "Retrying operation",
+			"operation", operation,
+			"attempt", attempt,
+			"attempts", attempts,
+			"delayMs", delay.Milliseconds(),
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns the delay after a failed attempt: the initial backoff
+// doubled per attempt and capped at the maximum, then moved randomly by up
+// to the jitter fraction so that instances do not retry in lockstep
+func (p *Policy) Backoff(attempt int) time.Duration {
+	delay := p.config.InitialBackoff
+	for i := 1; i < attempt && (p.config.MaxBackoff <= 0 || delay < p.config.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.config.MaxBackoff > 0 && delay > p.config.MaxBackoff {
+		delay = p.config.MaxBackoff
+	}
+	if p.config.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.config.Jitter * (2*rand.Float64() - 1))
+	}
+	return max(delay, 0)
+}
@@ -0,0 +1,86 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+var errTransient = errors.New("connection reset")
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func TestDo_RetriesTransientErrors(t *testing.T) {
+	policy := NewPolicy(types.RetryConfig{Attempts: 3, InitialBackoff: time.Millisecond}, isTransient)
+
+	calls := 0
+	err := policy.Do(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third call, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = policy.Do(context.Background(), "test", func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 3 {
+		t.Errorf("Expected the last error after 3 calls, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDo_StopsOnPermanentErrors(t *testing.T) {
+	policy := NewPolicy(types.RetryConfig{Attempts: 5, InitialBackoff: time.Millisecond}, isTransient)
+	permanent := errors.New("duplicate key")
+
+	calls := 0
+	err := policy.Do(context.Background(), "test", func() error {
+		calls++
+		return permanent
+	})
+	if !errors.Is(err, permanent) || calls != 1 {
+		t.Errorf("Expected a single call, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDo_StopsWhenContextIsDone(t *testing.T) {
+	policy := NewPolicy(types.RetryConfig{Attempts: 5, InitialBackoff: time.Hour}, isTransient)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := policy.Do(ctx, "test", func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 1 {
+		t.Errorf("Expected to give up after 1 call, got %v after %d calls", err, calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := NewPolicy(types.RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, isTransient)
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		if got := policy.Backoff(attempt); got != want {
+			t.Errorf("Expected a %v backoff after attempt %d, got %v", want, attempt, got)
+		}
+	}
+
+	jittered := NewPolicy(types.RetryConfig{InitialBackoff: 100 * time.Millisecond, Jitter: 0.2}, isTransient)
+	for i := 0; i < 100; i++ {
+		if got := jittered.Backoff(1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("Expected a backoff within 20%% of 100ms, got %v", got)
+		}
+	}
+}
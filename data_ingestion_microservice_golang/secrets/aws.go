@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// awsProvider reads secrets from the keys of a JSON AWS Secrets Manager
+// secret
+type awsProvider struct {
+	secretID string
+	client   *secretsmanager.Client
+}
+
+// newAWSProvider creates a Secrets Manager client for the configured region.
+// Credentials come from the static access key when one is configured, and
+// from the SDK's default chain otherwise: environment, shared config, web
+// identity (IRSA), ECS task role, or EC2 instance profile. A non-empty
+// endpoint replaces the regional Secrets Manager endpoint.
+func newAWSProvider(config types.SecretsConfig, endpoint string) (*awsProvider, error) {
+	loadOptions := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(config.AWSRegion),
+		awsconfig.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(fetchTimeout)),
+	}
+	if config.AWSAccessKeyID != "" {
+		loadOptions = append(loadOptions, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			config.AWSAccessKeyID, config.AWSSecretAccessKey, config.AWSSessionToken,
+		)))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	secretsClient := secretsmanager.NewFromConfig(awsConfig, func(o *secretsmanager.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &awsProvider{secretID: config.AWSSecretID, client: secretsClient}, nil
+}
+
+// Fetch reads the secret, a JSON object of credentials, and returns the
+// requested ones among its fields
+func (p *awsProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	output, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(p.secretID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AWS secret %s: %w", p.secretID, err)
+	}
+	if output.SecretString == nil {
+		return nil, errors.New("AWS secret " + p.secretID + " is binary, expected a JSON object of credentials")
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*output.SecretString), &fields); err != nil {
+		return nil, fmt.Errorf("AWS secret %s is not a JSON object of credentials: %w", p.secretID, err)
+	}
+	return pick(fields, names), nil
+}
@@ -0,0 +1,197 @@
+// Package secrets reads the Redis, MongoDB, and MQTT credentials from the
+// environment, a directory of files, HashiCorp Vault, or AWS Secrets
+// Manager, and refreshes them so that rotated credentials are picked up
+// without a restart.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Names of the secrets, matching the environment variables they replace
+const (
+	RedisPassword   = "REDIS_PASSWORD"
+	MongoDBUsername = "MONGODB_USERNAME"
+	MongoDBPassword = "MONGODB_PASSWORD"
+	MQTTUsername    = "MQTT_USERNAME"
+	MQTTPassword    = "MQTT_PASSWORD"
+)
+
+// Names lists every secret read from the provider
+var Names = []string{RedisPassword, MongoDBUsername, MongoDBPassword, MQTTUsername, MQTTPassword}
+
+// fetchTimeout bounds a single request to Vault or AWS Secrets Manager
+const fetchTimeout = 10 * time.Second
+
+// Provider reads secrets by name
+type Provider interface {
+	// Fetch returns the secrets among names that the provider holds.
+	// Missing secrets are left out rather than returned empty.
+	Fetch(ctx context.Context, names []string) (map[string]string, error)
+}
+
+// NewProvider creates the provider selected by config.Provider
+func NewProvider(config types.SecretsConfig) (Provider, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	switch config.Provider {
+	case "", "env":
+		return envProvider{}, nil
+	case "file":
+		return fileProvider{dir: config.Dir}, nil
+	case "vault":
+		if config.VaultToken == "" {
+			return nil, errors.New("VAULT_TOKEN is required by the vault secrets provider")
+		}
+		return &vaultProvider{config: config, client: client}, nil
+	case "aws":
+		if config.AWSAccessKeyID != "" && config.AWSSecretAccessKey == "" {
+			return nil, errors.New("AWS_SECRET_ACCESS_KEY is required with AWS_ACCESS_KEY_ID by the aws secrets provider")
+		}
+		provider, err := newAWSProvider(config, "")
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q, expected env, file, vault, or aws", config.Provider)
+	}
+}
+
+// Rotates reports whether the provider's secrets can change while the
+// service runs, and so are worth refreshing
+func Rotates(config types.SecretsConfig) bool {
+	return config.Provider != "" && config.Provider != "env" && config.RefreshInterval > 0
+}
+
+// Apply copies the fetched secrets over the credentials in cfg
+func Apply(cfg *types.Config, values map[string]string) {
+	fields := map[string]*string{
+		RedisPassword:   &cfg.Redis.Password,
+		MongoDBUsername: &cfg.MongoDB.Username,
+		MongoDBPassword: &cfg.MongoDB.Password,
+		MQTTUsername:    &cfg.MQTT.Username,
+		MQTTPassword:    &cfg.MQTT.Password,
+	}
+	for name, field := range fields {
+		if value, ok := values[name]; ok {
+			*field = value
+		}
+	}
+}
+
+// envProvider reads secrets from the environment variables of the same
+// name, which the configuration already did
+type envProvider struct{}
+
+func (envProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// fileProvider reads each secret from a file in dir named after it in
+// lowercase, such as /run/secrets/redis_password, the layout of Docker and
+// Kubernetes secret mounts
+type fileProvider struct {
+	dir string
+}
+
+func (p fileProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(p.dir, strings.ToLower(name)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		values[name] = strings.TrimRight(string(data), "\r\n")
+	}
+	return values, nil
+}
+
+// Store holds the current credentials and refreshes them from the provider
+type Store struct {
+	provider Provider
+
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewStore creates a store starting with the given credentials
+func NewStore(provider Provider, values map[string]string) *Store {
+	return &Store{provider: provider, values: values}
+}
+
+// Get returns the current value of a secret
+func (s *Store) Get(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[name]
+}
+
+// Refresh fetches the secrets again and returns the names of those that
+// changed
+func (s *Store) Refresh(ctx context.Context) ([]string, error) {
+	values, err := s.provider.Fetch(ctx, Names)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changed []string
+	for _, name := range Names {
+		value, ok := values[name]
+		if ok && value != s.values[name] {
+			s.values[name] = value
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+// Watch refreshes the secrets every interval until ctx is done, calling
+// onRefresh after every successful refresh with the names of the secrets
+// that were rotated, if any
+func (s *Store) Watch(ctx context.Context, interval time.Duration, onRefresh func(changed []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := s.Refresh(ctx)
+		if err != nil {
+			metrics.SecretRefreshFailures.Add(1)
+			slog.WarnContext(ctx, "Failed to refresh secrets, keeping the current credentials", "error", err)
+			continue
+		}
+		if len(changed) > 0 {
+			metrics.SecretRotations.Add(int64(len(changed)))
+			slog.InfoContext(ctx, "Secrets rotated", "secrets", changed)
+		}
+		onRefresh(changed)
+	}
+}
@@ -0,0 +1,190 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestFileProvider_ReadsLowercaseFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "redis_password"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewProvider(types.SecretsConfig{Provider: "file", Dir: dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	values, err := provider.Fetch(context.Background(), Names)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(values) != 1 || values[RedisPassword] != "s3cret" {
+		t.Errorf("Expected only the Redis password without the newline, got %v", values)
+	}
+}
+
+func TestVaultProvider_ReadsKVSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/data-ingestion" || r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"MONGODB_PASSWORD":"mongo-pass","MQTT_USERNAME":"ingest","OTHER":"x"}}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(types.SecretsConfig{
+		Provider:     "vault",
+		VaultAddress: server.URL,
+		VaultToken:   "token",
+		VaultMount:   "secret",
+		VaultPath:    "data-ingestion",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	values, err := provider.Fetch(context.Background(), Names)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(values) != 2 || values[MongoDBPassword] != "mongo-pass" || values[MQTTUsername] != "ingest" {
+		t.Errorf("Expected the MongoDB password and MQTT username, got %v", values)
+	}
+}
+
+func TestVaultProvider_RenewsTokenPastHalfItsTTL(t *testing.T) {
+	var lookups, renewals int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			lookups++
+			w.Write([]byte(`{"data":{"renewable":true,"ttl":3600}}`))
+		case "/v1/auth/token/renew-self":
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			renewals++
+			w.Write([]byte(`{"auth":{"renewable":true,"lease_duration":3600}}`))
+		default:
+			w.Write([]byte(`{"data":{"data":{"REDIS_PASSWORD":"redis-pass"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := &vaultProvider{
+		config: types.SecretsConfig{VaultAddress: server.URL, VaultToken: "token", VaultMount: "secret", VaultPath: "data-ingestion"},
+		client: server.Client(),
+	}
+	if _, err := provider.Fetch(context.Background(), Names); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if lookups != 1 || renewals != 0 {
+		t.Fatalf("Expected a lookup and no renewal before half the TTL, got %d and %d", lookups, renewals)
+	}
+
+	provider.renewAt = time.Now().Add(-time.Second)
+	if _, err := provider.Fetch(context.Background(), Names); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if lookups != 1 || renewals != 1 {
+		t.Errorf("Expected one renewal past half the TTL, got %d lookups and %d renewals", lookups, renewals)
+	}
+	if until := time.Until(provider.renewAt); until < 29*time.Minute || until > 30*time.Minute {
+		t.Errorf("Expected the next renewal in half the new TTL, got %v", until)
+	}
+}
+
+func TestAWSProvider_SignsAndReadsJSONSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") ||
+			r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || r.Header.Get("X-Amz-Security-Token") != "session" {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		var body struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.SecretId != "prod/ingest" {
+			http.Error(w, "not found", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"REDIS_PASSWORD":"redis-pass"}`})
+	}))
+	defer server.Close()
+
+	provider, err := newAWSProvider(types.SecretsConfig{
+		AWSRegion:          "eu-west-1",
+		AWSSecretID:        "prod/ingest",
+		AWSAccessKeyID:     "AKID",
+		AWSSecretAccessKey: "secret",
+		AWSSessionToken:    "session",
+	}, server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	values, err := provider.Fetch(context.Background(), Names)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if values[RedisPassword] != "redis-pass" {
+		t.Errorf("Expected the Redis password, got %v", values)
+	}
+}
+
+func TestNewProvider_RejectsMissingCredentials(t *testing.T) {
+	for _, config := range []types.SecretsConfig{
+		{Provider: "vault"},
+		{Provider: "aws", AWSAccessKeyID: "AKID"},
+		{Provider: "keychain"},
+	} {
+		if _, err := NewProvider(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestStore_RefreshReportsRotatedSecrets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, value string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("mqtt_password", "old")
+	write("mqtt_username", "ingest")
+
+	store := NewStore(fileProvider{dir: dir}, map[string]string{MQTTUsername: "ingest", MQTTPassword: "old"})
+	if changed, err := store.Refresh(context.Background()); err != nil || len(changed) != 0 {
+		t.Fatalf("Expected nothing to change, got %v and %v", changed, err)
+	}
+
+	write("mqtt_password", "new")
+	changed, err := store.Refresh(context.Background())
+	if err != nil || !slices.Equal(changed, []string{MQTTPassword}) {
+		t.Fatalf("Expected the MQTT password to rotate, got %v and %v", changed, err)
+	}
+	if store.Get(MQTTPassword) != "new" || store.Get(MQTTUsername) != "ingest" {
+		t.Errorf("Expected the new password, got %q", store.Get(MQTTPassword))
+	}
+}
+
+func TestApply_OverridesOnlyFetchedCredentials(t *testing.T) {
+	cfg := types.Config{Redis: types.RedisConfig{Password: "env"}, MQTT: types.MQTTConfig{Username: "env"}}
+	Apply(&cfg, map[string]string{RedisPassword: "vault", MongoDBPassword: "vault"})
+	if cfg.Redis.Password != "vault" || cfg.MongoDB.Password != "vault" || cfg.MQTT.Username != "env" {
+		t.Errorf("Expected fetched secrets to win and others to stay, got %+v %+v %+v", cfg.Redis.Password, cfg.MongoDB.Password, cfg.MQTT.Username)
+	}
+}
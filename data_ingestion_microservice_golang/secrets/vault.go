@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// vaultProvider reads secrets from the fields of a Vault KV version 2
+// secret at VaultMount/VaultPath. A renewable token is renewed once half of
+// its TTL has passed, checked before every fetch, so it outlives the service
+// as long as SECRETS_REFRESH_INTERVAL is shorter than half its TTL and its
+// max TTL allows.
+type vaultProvider struct {
+	config types.SecretsConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	looked    bool
+	renewable bool
+	renewAt   time.Time
+}
+
+// vaultToken is the part of a token lookup or renewal response about the
+// token's lifetime
+type vaultToken struct {
+	Data struct {
+		Renewable bool `json:"renewable"`
+		TTL       int  `json:"ttl"`
+	} `json:"data"`
+	Auth struct {
+		Renewable     bool `json:"renewable"`
+		LeaseDuration int  `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+// Fetch reads the KV version 2 secret, renewing the token first when it is
+// due, and returns the requested credentials among its fields
+func (p *vaultProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	if err := p.renewToken(ctx); err != nil {
+		// The token may still be valid, so try reading the secret anyway
+		slog.WarnContext(ctx, "Failed to renew the Vault token", "error", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s",
+		strings.TrimRight(p.config.VaultAddress, "/"),
+		strings.Trim(p.config.VaultMount, "/"),
+		strings.Trim(p.config.VaultPath, "/"),
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.config.VaultToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to read Vault secret %s: status %d: %s", p.config.VaultPath, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed to decode Vault secret: %w", err)
+	}
+	return pick(secret.Data.Data, names), nil
+}
+
+// renewToken looks the token up on the first call to learn whether it is
+// renewable, and renews it once half of its TTL has passed. Tokens without a
+// TTL, such as root tokens, are never renewed.
+func (p *vaultProvider) renewToken(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.looked {
+		var token vaultToken
+		if err := p.tokenRequest(ctx, http.MethodGet, "lookup-self", &token); err != nil {
+			return err
+		}
+		p.looked = true
+		p.renewable = token.Data.Renewable && token.Data.TTL > 0
+		p.renewAt = time.Now().Add(time.Duration(token.Data.TTL) * time.Second / 2)
+	}
+	if !p.renewable || time.Now().Before(p.renewAt) {
+		return nil
+	}
+
+	var token vaultToken
+	if err := p.tokenRequest(ctx, http.MethodPost, "renew-self", &token); err != nil {
+		return err
+	}
+	p.renewable = token.Auth.Renewable && token.Auth.LeaseDuration > 0
+	p.renewAt = time.Now().Add(time.Duration(token.Auth.LeaseDuration) * time.Second / 2)
+	slog.InfoContext(ctx, "Renewed the Vault token", "ttl", time.Duration(token.Auth.LeaseDuration)*time.Second)
+	return nil
+}
+
+// tokenRequest calls the auth/token/{operation} endpoint of the provider's
+// own token and decodes the response into token
+func (p *vaultProvider) tokenRequest(ctx context.Context, method, operation string, token *vaultToken) error {
+	url := fmt.Sprintf("%s/v1/auth/token/%s", strings.TrimRight(p.config.VaultAddress, "/"), operation)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create Vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.config.VaultToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s Vault token: %w", operation, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to %s Vault token: status %d: %s", operation, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(token); err != nil {
+		return fmt.Errorf("failed to decode Vault token %s: %w", operation, err)
+	}
+	return nil
+}
+
+// pick returns the string values of names among the fields of a secret
+func pick(fields map[string]interface{}, names []string) map[string]string {
+	values := make(map[string]string)
+	for _, name := range names {
+		if value, ok := fields[name]; ok && value != nil {
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// AdherenceScorer scores finished trips against the planned route and
+// timetable of their route
+type AdherenceScorer struct {
+	config types.AdherenceConfig
+	routes *plannedRouteCache
+}
+
+// NewAdherenceScorer creates a new route adherence scorer
+func NewAdherenceScorer(config types.AdherenceConfig, routes database.PlannedRouteStore) *AdherenceScorer {
+	return &AdherenceScorer{config: config, routes: newPlannedRouteCache(routes)}
+}
+
+// Score compares the raw points of a trip with the planned route of its
+// route, or returns nil when the route has no planned path
+func (a *AdherenceScorer) Score(ctx context.Context, routeID string, points []types.TrackPoint) (*types.TripAdherence, error) {
+	route, err := a.routes.get(ctx, routeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read planned route %s: %w", routeID, err)
+	}
+	if route == nil {
+		return nil, nil
+	}
+	return algorithm.ScoreAdherence(points, *route, a.config), nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_ScoresAdherence(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Adherence.Enabled = true
+		backends.PlannedRoutes = memoryPlannedRoutes{"route-1": etaTestRoute}
+	})
+
+	// driver-1 passes every stop of route-1 with one point about 1.1km off
+	// the path; route-2 has no planned route
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.009}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":0.01,"longitude":0.013}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995320000,"driverLocation":{"latitude":0,"longitude":0.018}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995380000,"driverLocation":{"latitude":0,"longitude":0.027}}`,
+		`{"driverId":"driver-2","currentRouteId":"route-2","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.009}}`,
+		`{"driverId":"driver-2","currentRouteId":"route-2","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":0,"longitude":0.018}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	for _, finished := range []types.BusMessage{
+		{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995400000},
+		{DriverID: "driver-2", CurrentRouteID: "route-2", Status: "finished", Timestamp: 1640995400000},
+	} {
+		key := database.RouteKey(finished.DriverID, finished.CurrentRouteID)
+		if err := service.handleFinished(context.Background(), key, finished); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	var scored, unscored *types.Trip
+	for _, trip := range backend.trips {
+		trip := trip
+		if trip.CurrentRouteID == "route-1" {
+			scored = &trip
+		} else {
+			unscored = &trip
+		}
+	}
+	if scored == nil || unscored == nil {
+		t.Fatalf("Expected a trip on each route, got %+v", backend.trips)
+	}
+	adherence := scored.Adherence
+	if adherence == nil || adherence.OnRoutePercent != 75 || adherence.StopsVisited != 3 || adherence.StopsOnTime != 3 {
+		t.Fatalf("Expected 3 of 4 points on route and every stop visited, got %+v", adherence)
+	}
+	if math.Abs(adherence.Score-87.5) > 0.01 {
+		t.Errorf("Expected a score of 87.5, got %f", adherence.Score)
+	}
+	if unscored.Adherence != nil {
+		t.Errorf("Expected no score without a planned route, got %+v", unscored.Adherence)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// ErrAuditLogUnsupported is returned when the storage backend keeps no
+// audit log
+var ErrAuditLogUnsupported = errors.New("the audit log is not supported by the storage backend")
+
+// recordAudit appends an administrative action to the audit log, if the
+// storage backend keeps one
+func (s *DataIngestionService) recordAudit(ctx context.Context, entry types.AuditEntry) error {
+	if s.backends.Audit == nil {
+		return nil
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	if err := s.backends.Audit.RecordAudit(ctx, entry); err != nil {
+		return fmt.Errorf("failed to audit %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// AuditLog returns the recorded administrative actions matching the query,
+// newest first
+func (s *DataIngestionService) AuditLog(ctx context.Context, query types.AuditQuery) (types.AuditLogPage, error) {
+	if s.backends.Audit == nil {
+		return types.AuditLogPage{}, ErrAuditLogUnsupported
+	}
+	entries, err := s.backends.Audit.AuditEntries(ctx, query)
+	if err != nil {
+		return types.AuditLogPage{}, err
+	}
+	return types.AuditLogPage{Entries: entries}, nil
+}
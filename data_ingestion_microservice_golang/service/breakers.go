@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+)
+
+// redisCall runs a Redis operation through the Redis circuit breaker,
+// retrying transient failures while the breaker lets calls through
+func (s *DataIngestionService) redisCall(ctx context.Context, operation string, op func() error) error {
+	return s.redisBreaker.Do(func() error {
+		return s.retries.Do(ctx, operation, op)
+	})
+}
+
+// mongoCall runs a MongoDB operation through the MongoDB circuit breaker,
+// retrying transient failures while the breaker lets calls through
+func (s *DataIngestionService) mongoCall(ctx context.Context, operation string, op func() error) error {
+	return s.mongoBreaker.Do(func() error {
+		return s.retries.Do(ctx, operation, op)
+	})
+}
+
+// breakerStatus reports the state of each circuit breaker for the health
+// status
+func (s *DataIngestionService) breakerStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"redis":   s.redisBreaker.State().String(),
+		"mongodb": s.mongoBreaker.State().String(),
+	}
+}
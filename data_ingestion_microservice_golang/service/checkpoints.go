@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// checkpointing reports whether finalizations in progress are recorded
+func (s *DataIngestionService) checkpointing() bool {
+	return s.config.Finalization.Checkpoints && s.backends.Checkpoints != nil
+}
+
+// beginFinalization records that a route is being finalized, so the
+// finalization is resumed if the process dies before it ends
+func (s *DataIngestionService) beginFinalization(ctx context.Context, key, tripID string, busMsg types.BusMessage) error {
+	checkpoint := types.FinalizationCheckpoint{
+		Key:       key,
+		TripID:    tripID,
+		Instance:  s.config.InstanceID,
+		StartedAt: time.Now().UTC(),
+		Message:   busMsg,
+	}
+	err := s.redisCall(ctx, "redis.checkpoint", func() error {
+		return s.backends.Checkpoints.BeginFinalization(ctx, checkpoint)
+	})
+	if err != nil {
+		return classify(FailureRedis, err)
+	}
+	return nil
+}
+
+// endFinalization removes the checkpoint of a finalization that completed
+// or failed. Failures are recorded in the failure log rather than resumed.
+func (s *DataIngestionService) endFinalization(ctx context.Context, key string) {
+	err := s.redisCall(ctx, "redis.checkpoint", func() error {
+		return s.backends.Checkpoints.EndFinalization(ctx, key)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Error removing finalization checkpoint", "key", key, "error", err)
+	}
+}
+
+// resumeFinalizations resubmits the finalizations interrupted by a crash or
+// an unfinished shutdown of this instance, and those abandoned by any other
+// instance for longer than the resume timeout, or right away with all
+func (s *DataIngestionService) resumeFinalizations(ctx context.Context, all bool) {
+	defer s.backgroundDone.Done()
+
+	var pending []types.FinalizationCheckpoint
+	err := s.redisCall(ctx, "redis.checkpoint", func() (err error) {
+		pending, err = s.backends.Checkpoints.PendingFinalizations(ctx)
+		return err
+	})
+	if err != nil {
+		slog.Error("Error reading finalization checkpoints", "error", err)
+		return
+	}
+
+	for _, checkpoint := range pending {
+		if !all && checkpoint.Instance != s.config.InstanceID && time.Since(checkpoint.StartedAt) < s.config.Finalization.ResumeAfter {
+			continue
+		}
+		slog.Warn("Resuming interrupted finalization",
+			"key", checkpoint.Key,
+			"tripId", checkpoint.TripID,
+			"instance", checkpoint.Instance,
+			"startedAt", checkpoint.StartedAt,
+		)
+		metrics.FinalizationResumed.Add(1)
+		s.finalizer.Submit(ctx, checkpoint.Key, checkpoint.Message, nil)
+	}
+}
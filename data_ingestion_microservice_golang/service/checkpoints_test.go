@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/config"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_ClearsCheckpoint(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"finished","timestamp":1640995210000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := service.finalizer.Drain(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	if len(backend.checkpointed) != 1 || backend.checkpointed[0] != "route:driver-1:route-1" {
+		t.Errorf("Expected the finalization to be checkpointed, got %v", backend.checkpointed)
+	}
+	if len(backend.checkpoints) != 0 {
+		t.Errorf("Expected the checkpoint to be removed, got %v", backend.checkpoints)
+	}
+}
+
+func TestResumeFinalizations_CompletesInterruptedTrip(t *testing.T) {
+	backend := newMemoryBackend()
+	instanceID := config.LoadConfig().InstanceID
+	busMsg := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995210000}
+	for _, point := range []types.TrackPoint{
+		{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000},
+		{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995210000},
+	} {
+		backend.AppendPoint(context.Background(), "route:driver-1:route-1", point)
+		backend.AppendPoint(context.Background(), "route:driver-2:route-2", point)
+	}
+
+	// This instance crashed while finalizing driver-1, and another live
+	// instance has just started finalizing driver-2
+	backend.checkpoints["route:driver-1:route-1"] = types.FinalizationCheckpoint{
+		Key: "route:driver-1:route-1", Instance: instanceID, StartedAt: time.Now().Add(-time.Second), Message: busMsg,
+	}
+	other := busMsg
+	other.DriverID, other.CurrentRouteID = "driver-2", "route-2"
+	backend.checkpoints["route:driver-2:route-2"] = types.FinalizationCheckpoint{
+		Key: "route:driver-2:route-2", Instance: "other_instance", StartedAt: time.Now(), Message: other,
+	}
+
+	service := newTestService(t, backend)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend.mu.Lock()
+		trips := len(backend.trips)
+		_, pending := backend.checkpoints["route:driver-1:route-1"]
+		backend.mu.Unlock()
+		if trips == 1 && !pending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the interrupted finalization to complete, got %d trips", trips)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := service.finalizer.Drain(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if _, ok := backend.checkpoints["route:driver-2:route-2"]; !ok {
+		t.Error("Expected the recent checkpoint of another instance to be left to it")
+	}
+	if points := backend.routes["route:driver-2:route-2"]; len(points) != 2 {
+		t.Errorf("Expected the route of the other instance to stay buffered, got %d points", len(points))
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// throughputSampleInterval is how often the ingest throughput is computed
+const throughputSampleInterval = 10 * time.Second
+
+// Cluster-wide jobs: closing abandoned routes, rolling the distance
+// counters up into daily summaries, and counting trip points in heatmaps
+const (
+	jobRouteJanitor       = "route_janitor"
+	jobOdometerRollup     = "odometer_rollup"
+	jobHeatmapAggregation = "heatmap_aggregation"
+)
+
+// sampleThroughput publishes the rate of messages processed over every
+// sample interval until ctx is done
+func (s *DataIngestionService) sampleThroughput(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(throughputSampleInterval)
+	defer ticker.Stop()
+
+	last, lastAt := metrics.IngestProcessed.Value(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			processed := metrics.IngestProcessed.Value()
+			metrics.IngestThroughput.Set(float64(processed-last) / now.Sub(lastAt).Seconds())
+			last, lastAt = processed, now
+		}
+	}
+}
+
+// leaderJobs lists the enabled jobs that only the cluster leader runs
+func (s *DataIngestionService) leaderJobs() []string {
+	var jobs []string
+	if s.config.Janitor.Enabled {
+		jobs = append(jobs, jobRouteJanitor)
+	}
+	if s.config.Odometer.Enabled {
+		jobs = append(jobs, jobOdometerRollup)
+	}
+	if s.config.Heatmap.Enabled {
+		jobs = append(jobs, jobHeatmapAggregation)
+	}
+	return jobs
+}
+
+// leads reports whether this instance runs the cluster-wide jobs, which a
+// single active instance always does
+func (s *DataIngestionService) leads() bool {
+	return s.active.Load() && (s.partitioner == nil || s.partitioner.IsLeader())
+}
+
+// ClusterStatus returns the live instances with the share of the drivers
+// each one owns, the jobs it leads, and its throughput. Without
+// partitioning, this instance is the whole cluster.
+func (s *DataIngestionService) ClusterStatus(ctx context.Context) (types.ClusterStatus, error) {
+	cluster := types.ClusterStatus{Instance: s.config.InstanceID}
+	if s.partitioner == nil {
+		cluster.Leader = s.config.InstanceID
+		cluster.Instances = []types.InstanceStatus{{
+			InstanceID:        s.config.InstanceID,
+			StartedAt:         s.startedAt,
+			Leader:            true,
+			Jobs:              append([]string{}, s.leaderJobs()...),
+			RingShare:         1,
+			MessagesTotal:     metrics.IngestProcessed.Value(),
+			MessagesPerSecond: metrics.IngestThroughput.Value(),
+		}}
+		return cluster, nil
+	}
+	cluster.Partitioning = true
+
+	var statuses []types.InstanceStatus
+	err := s.redisCall(ctx, "redis.cluster", func() (err error) {
+		statuses, err = s.backends.Partitions.InstanceStatuses(ctx)
+		return err
+	})
+	if err != nil {
+		return types.ClusterStatus{}, classify(FailureRedis, err)
+	}
+
+	// Report the live instances only, with this one's status up to date
+	members := s.partitioner.Members()
+	cluster.Instances = []types.InstanceStatus{s.partitioner.Status()}
+	for _, status := range statuses {
+		if status.InstanceID != s.config.InstanceID && slices.Contains(members, status.InstanceID) {
+			cluster.Instances = append(cluster.Instances, status)
+		}
+	}
+	slices.SortFunc(cluster.Instances, func(a, b types.InstanceStatus) int {
+		return strings.Compare(a.InstanceID, b.InstanceID)
+	})
+	for _, status := range cluster.Instances {
+		if status.Leader {
+			cluster.Leader = status.InstanceID
+		}
+	}
+	return cluster, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Reasons a retransmitted message is dropped
+const (
+	// duplicateExact is a message identical to one of the driver's latest
+	duplicateExact = "duplicate"
+	// duplicateStale is a message timestamped more than the dedup window
+	// before the driver's newest message
+	duplicateStale = "stale"
+)
+
+// messageHash identifies a message by its payload, so only exact
+// retransmissions match
+func messageHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:16])
+}
+
+// dropDuplicate reports whether a message is a retransmission to drop,
+// counting it by reason
+func (s *DataIngestionService) dropDuplicate(ctx context.Context, busMsg types.BusMessage, hash string) (bool, error) {
+	if s.backends.History == nil {
+		return false, nil
+	}
+
+	var seen bool
+	var latest uint64
+	err := s.redisCall(ctx, "redis.dedup", func() (err error) {
+		seen, latest, err = s.backends.History.LookupMessage(ctx, busMsg.DriverID, hash)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	reason := duplicateReason(seen, busMsg.Timestamp, latest, s.config.Dedup.Window)
+	if reason == "" {
+		return false, nil
+	}
+	metrics.DuplicatesDropped.Add(reason, 1)
+	slog.DebugContext(ctx, "Dropped retransmitted message", "reason", reason, "timestamp", busMsg.Timestamp, "latestTimestamp", latest)
+	return true, nil
+}
+
+// duplicateReason returns why a message is a retransmission, or an empty
+// string if it is not. Timestamps are in milliseconds; messages without one
+// are only matched by hash.
+func duplicateReason(seen bool, timestamp, latest uint64, window time.Duration) string {
+	switch {
+	case seen:
+		return duplicateExact
+	case window > 0 && timestamp > 0 && timestamp+uint64(window.Milliseconds()) < latest:
+		return duplicateStale
+	}
+	return ""
+}
+
+// recordMessage remembers a processed message so its retransmissions are
+// dropped. A message that cannot be recorded was still processed, so the
+// error is only logged.
+func (s *DataIngestionService) recordMessage(ctx context.Context, busMsg types.BusMessage, hash string) {
+	if s.backends.History == nil {
+		return
+	}
+
+	err := s.redisCall(ctx, "redis.dedup", func() error {
+		return s.backends.History.RecordMessage(ctx, busMsg.DriverID, hash, busMsg.Timestamp)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to record message for duplicate detection", "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+func TestDuplicateReason(t *testing.T) {
+	tests := []struct {
+		name      string
+		seen      bool
+		timestamp uint64
+		latest    uint64
+		want      string
+	}{
+		{"new message", false, 1640995200000, 1640995200000, ""},
+		{"exact retransmission", true, 1640995200000, 1640995200000, duplicateExact},
+		{"late within the window", false, 1640995200000, 1640995200000 + 60000, ""},
+		{"late beyond the window", false, 1640995200000, 1640995200000 + 60001, duplicateStale},
+		{"no timestamp", false, 0, 1640995200000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := duplicateReason(tt.seen, tt.timestamp, tt.latest, time.Minute); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProcessMessage_DropsRetransmissions(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Dedup.History = 8
+		cfg.Dedup.Window = time.Minute
+		backends.History = database.NewMemoryMessageHistory(cfg.Dedup)
+	})
+	duplicates, stale := droppedCount(duplicateExact), droppedCount(duplicateStale)
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995300000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		// Retransmitted
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995300000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		// Retransmitted long after newer messages
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2400,"longitude":-75.5800}}`,
+		// Late but within the window
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995290000,"driverLocation":{"latitude":6.2440,"longitude":-75.5810}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if points := backend.routes[database.RouteKey("driver-1", "route-1")]; len(points) != 2 {
+		t.Errorf("Expected 2 buffered points, got %d", len(points))
+	}
+	if got := droppedCount(duplicateExact) - duplicates; got != 1 {
+		t.Errorf("Expected 1 duplicate dropped, got %d", got)
+	}
+	if got := droppedCount(duplicateStale) - stale; got != 1 {
+		t.Errorf("Expected 1 stale message dropped, got %d", got)
+	}
+}
+
+// droppedCount returns the number of messages dropped for a reason
+func droppedCount(reason string) int64 {
+	if count, ok := metrics.DuplicatesDropped.Get(reason).(*expvar.Int); ok {
+		return count.Value()
+	}
+	return 0
+}
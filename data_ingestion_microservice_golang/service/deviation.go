@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// deviationState tracks off-route progress for a single driver route
+type deviationState struct {
+	consecutive int
+	reported    bool
+}
+
+// DeviationDetector compares live points against planned routes and emits
+// deviation events when a driver stays off-route for too long
+type DeviationDetector struct {
+	config types.RouteDeviationConfig
+	routes *plannedRouteCache
+	broker database.MessageBroker
+
+	mu     sync.Mutex
+	states map[string]*deviationState
+}
+
+// NewDeviationDetector creates a new route deviation detector
+func NewDeviationDetector(config types.RouteDeviationConfig, routes database.PlannedRouteStore, broker database.MessageBroker) *DeviationDetector {
+	return &DeviationDetector{
+		config: config,
+		routes: newPlannedRouteCache(routes),
+		broker: broker,
+		states: make(map[string]*deviationState),
+	}
+}
+
+// Check evaluates a live point and publishes a deviation event when the
+// driver has been off-route for the configured number of consecutive points.
+// The published event is returned, or nil when nothing was reported.
+func (d *DeviationDetector) Check(ctx context.Context, key string, busMsg types.BusMessage) (*types.DeviationEvent, error) {
+	route, err := d.routes.get(ctx, busMsg.CurrentRouteID)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil || len(route.Path) == 0 {
+		return nil, nil
+	}
+
+	distance := algorithm.CrossTrackDistance(busMsg.DriverLocation, route.Path)
+
+	d.mu.Lock()
+	state, ok := d.states[key]
+	if !ok {
+		state = &deviationState{}
+		d.states[key] = state
+	}
+
+	if distance <= d.config.ThresholdMeters {
+		state.consecutive = 0
+		state.reported = false
+		d.mu.Unlock()
+		return nil, nil
+	}
+
+	state.consecutive++
+	shouldReport := !state.reported && state.consecutive >= d.config.ConsecutivePoints
+	if shouldReport {
+		state.reported = true
+	}
+	consecutive := state.consecutive
+	d.mu.Unlock()
+
+	if !shouldReport {
+		return nil, nil
+	}
+
+	event := types.DeviationEvent{
+		Type:              "route_deviation",
+		DriverID:          busMsg.DriverID,
+		CurrentRouteID:    busMsg.CurrentRouteID,
+		Location:          busMsg.DriverLocation,
+		DistanceMeters:    distance,
+		ConsecutivePoints: consecutive,
+		Timestamp:         busMsg.Timestamp,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal deviation event: %w", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s", d.config.EventTopic, busMsg.CurrentRouteID)
+	if err := d.broker.PublishMessage(topic, payload); err != nil {
+		return nil, fmt.Errorf("failed to publish deviation event: %w", err)
+	}
+
+	slog.WarnContext(ctx, "Driver deviated from planned route",
+		"distanceMeters", distance,
+		"consecutivePoints", consecutive,
+	)
+	return &event, nil
+}
+
+// Reset discards the deviation state for a finished route
+func (d *DeviationDetector) Reset(key string) {
+	d.mu.Lock()
+	delete(d.states, key)
+	d.mu.Unlock()
+}
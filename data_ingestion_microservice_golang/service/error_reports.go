@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/reporting"
+	"data-ingestion-microservice/types"
+)
+
+// repeatDetector decides which failures are reported to the error tracker.
+// A failure class is reported once it fails threshold times within a
+// window, and then at most once per window, so an outage produces one
+// report per class instead of one per message.
+type repeatDetector struct {
+	threshold int
+	window    time.Duration
+
+	mu      sync.Mutex
+	classes map[string]*repeatWindow
+}
+
+// repeatWindow counts the failures of a class in the current window
+type repeatWindow struct {
+	start    time.Time
+	count    int
+	reported bool
+}
+
+// newRepeatDetector creates a detector reporting classes that fail
+// threshold times within window
+func newRepeatDetector(threshold int, window time.Duration) *repeatDetector {
+	return &repeatDetector{
+		threshold: max(threshold, 1),
+		window:    window,
+		classes:   make(map[string]*repeatWindow),
+	}
+}
+
+// observe counts a failure and returns the failures in the current window
+// and whether they should be reported now
+func (rd *repeatDetector) observe(class string, now time.Time) (int, bool) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	current, ok := rd.classes[class]
+	if !ok || now.Sub(current.start) >= rd.window {
+		current = &repeatWindow{start: now}
+		rd.classes[class] = current
+	}
+	current.count++
+	if current.reported || current.count < rd.threshold {
+		return current.count, false
+	}
+	current.reported = true
+	return current.count, true
+}
+
+// recordFailure records a failed message or finalization and reports it to
+// the error tracker once its class keeps failing. Panics are reported when
+// they are recovered.
+func (s *DataIngestionService) recordFailure(ctx context.Context, err error, busMsg types.BusMessage, payload []byte) {
+	s.failures.Record(err, busMsg, payload)
+
+	class := failureClass(err)
+	if class == FailurePanic {
+		return
+	}
+	if count, report := s.repeats.observe(class, time.Now()); report {
+		reporting.Error(ctx, err,
+			"class", class,
+			"driverId", busMsg.DriverID,
+			"routeId", busMsg.CurrentRouteID,
+			"occurrences", count,
+		)
+	}
+}
+
+// recoverPanic turns the value recovered from a panic in the pipeline into a
+// failure, reporting it with its stack trace
+func recoverPanic(ctx context.Context, recovered any, busMsg types.BusMessage) error {
+	slog.ErrorContext(ctx, "Recovered from panic", "panic", recovered)
+	reporting.Panic(ctx, recovered, "driverId", busMsg.DriverID, "routeId", busMsg.CurrentRouteID)
+	return classify(FailurePanic, fmt.Errorf("panic: %v", recovered))
+}
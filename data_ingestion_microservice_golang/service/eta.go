@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// ErrETADisabled is returned for ETA reads when ETA estimation is disabled
+var ErrETADisabled = errors.New("stop ETAs are disabled")
+
+// progressSample is how far along its planned route a vehicle was at a
+// device timestamp (milliseconds)
+type progressSample struct {
+	meters    float64
+	timestamp uint64
+}
+
+// stopOffsets holds how far along a planned route each of its stops lies
+type stopOffsets struct {
+	route   *types.PlannedRoute
+	offsets []float64
+}
+
+// ETAEstimator estimates when vehicles reach the stops ahead of them from
+// their progress along the planned route and their recent speed
+type ETAEstimator struct {
+	config types.ETAConfig
+	routes *plannedRouteCache
+
+	mu       sync.Mutex
+	stops    map[string]stopOffsets
+	progress map[string][]progressSample
+}
+
+// NewETAEstimator creates a new stop ETA estimator
+func NewETAEstimator(config types.ETAConfig, routes database.PlannedRouteStore) *ETAEstimator {
+	return &ETAEstimator{
+		config:   config,
+		routes:   newPlannedRouteCache(routes),
+		stops:    make(map[string]stopOffsets),
+		progress: make(map[string][]progressSample),
+	}
+}
+
+// Estimate returns the ETAs of a vehicle at the stops ahead of it, or nil
+// when its route has no planned path with stops
+func (e *ETAEstimator) Estimate(ctx context.Context, key string, busMsg types.BusMessage) (*types.VehicleETA, error) {
+	route, err := e.routes.get(ctx, busMsg.CurrentRouteID)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil || len(route.Path) < 2 || len(route.Stops) == 0 {
+		return nil, nil
+	}
+
+	progress := algorithm.PathProgress(busMsg.DriverLocation, route.Path)
+
+	e.mu.Lock()
+	offsets := e.stopOffsets(route)
+	speedKmh := e.speed(key, progressSample{meters: progress, timestamp: busMsg.Timestamp})
+	e.mu.Unlock()
+
+	eta := &types.VehicleETA{
+		DriverID:       busMsg.DriverID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		SpeedKmh:       speedKmh,
+		Timestamp:      busMsg.Timestamp,
+		UpdatedAt:      time.Now().UnixMilli(),
+		Stops:          []types.StopETA{},
+	}
+	metersPerSecond := speedKmh / 3.6
+	for i, stop := range route.Stops {
+		distance := offsets[i] - progress
+		if distance <= 0 {
+			continue
+		}
+		seconds := distance / metersPerSecond
+		eta.Stops = append(eta.Stops, types.StopETA{
+			StopID:         stop.ID,
+			Name:           stop.Name,
+			Location:       stop.Location,
+			DistanceMeters: distance,
+			ETASeconds:     seconds,
+			ArrivalAt:      int64(busMsg.Timestamp) + int64(seconds*1000),
+		})
+	}
+	return eta, nil
+}
+
+// Reset discards the progress of a finished route
+func (e *ETAEstimator) Reset(key string) {
+	e.mu.Lock()
+	delete(e.progress, key)
+	e.mu.Unlock()
+}
+
+// stopOffsets returns how far along the route each stop lies, computed once
+// per loaded route. Callers must hold e.mu.
+func (e *ETAEstimator) stopOffsets(route *types.PlannedRoute) []float64 {
+	cached, ok := e.stops[route.RouteID]
+	if ok && cached.route == route {
+		return cached.offsets
+	}
+	offsets := make([]float64, len(route.Stops))
+	for i, stop := range route.Stops {
+		offsets[i] = algorithm.PathProgress(stop.Location, route.Path)
+	}
+	e.stops[route.RouteID] = stopOffsets{route: route, offsets: offsets}
+	return offsets
+}
+
+// speed records a progress sample and returns the speed in km/h over the
+// last SpeedWindow, or the default speed until the route has been followed
+// for that long. Samples are kept back to the newest one at least a window
+// old. Callers must hold e.mu.
+func (e *ETAEstimator) speed(key string, sample progressSample) float64 {
+	samples := e.progress[key]
+	// Start over when messages go back in time
+	if len(samples) > 0 && sample.timestamp < samples[len(samples)-1].timestamp {
+		samples = nil
+	}
+	samples = append(samples, sample)
+
+	window := uint64(e.config.SpeedWindow.Milliseconds())
+	oldest := 0
+	for oldest+1 < len(samples) && sample.timestamp-samples[oldest+1].timestamp >= window {
+		oldest++
+	}
+	samples = samples[oldest:]
+	e.progress[key] = samples
+
+	first := samples[0]
+	elapsed := sample.timestamp - first.timestamp
+	if len(samples) < 2 || elapsed < window {
+		return e.config.DefaultSpeedKmh
+	}
+	speedKmh := (sample.meters - first.meters) / (float64(elapsed) / 1000) * 3.6
+	return max(speedKmh, e.config.MinSpeedKmh)
+}
+
+// RouteETAs returns the stop ETAs of the vehicles on a route updated within
+// the ETA TTL. With a stop ID, only the vehicles still heading to that stop
+// are returned, soonest first, with that stop alone.
+func (s *DataIngestionService) RouteETAs(ctx context.Context, routeID, stopID string) ([]types.VehicleETA, error) {
+	if s.eta == nil {
+		return nil, ErrETADisabled
+	}
+
+	var stored []types.VehicleETA
+	err := s.redisCall(ctx, "redis.eta", func() (err error) {
+		stored, err = s.backends.ETAs.RouteETAs(ctx, routeID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	etas := []types.VehicleETA{}
+	for _, eta := range stored {
+		if now.Sub(time.UnixMilli(eta.UpdatedAt)) > s.config.ETA.TTL {
+			continue
+		}
+		if stopID != "" {
+			i := slices.IndexFunc(eta.Stops, func(stop types.StopETA) bool { return stop.StopID == stopID })
+			if i < 0 {
+				continue
+			}
+			eta.Stops = eta.Stops[i : i+1]
+		}
+		etas = append(etas, eta)
+	}
+	if stopID != "" {
+		sort.SliceStable(etas, func(i, j int) bool {
+			return etas[i].Stops[0].ETASeconds < etas[j].Stops[0].ETASeconds
+		})
+	}
+	return etas, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryPlannedRoutes serves planned routes by route ID
+type memoryPlannedRoutes map[string]*types.PlannedRoute
+
+func (m memoryPlannedRoutes) FindPlannedRoute(ctx context.Context, routeID string) (*types.PlannedRoute, error) {
+	return m[routeID], nil
+}
+
+// etaTestRoute runs east along the equator with a stop every kilometer
+var etaTestRoute = &types.PlannedRoute{
+	RouteID: "route-1",
+	Path:    []types.Location{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 0.05}},
+	Stops: []types.PlannedStop{
+		{ID: "stop-1", Location: types.Location{Latitude: 0, Longitude: 0.009}},
+		{ID: "stop-2", Location: types.Location{Latitude: 0, Longitude: 0.018}},
+		{ID: "stop-3", Location: types.Location{Latitude: 0, Longitude: 0.027}},
+	},
+}
+
+func TestETAEstimator_UsesRecentSpeed(t *testing.T) {
+	estimator := NewETAEstimator(types.ETAConfig{
+		SpeedWindow:     time.Minute,
+		DefaultSpeedKmh: 20,
+		MinSpeedKmh:     5,
+	}, memoryPlannedRoutes{"route-1": etaTestRoute})
+	key := database.RouteKey("driver-1", "route-1")
+
+	// 0.0054 degrees of longitude is 600 m, covered in one minute: 36 km/h
+	estimate := func(longitude float64, timestamp uint64) *types.VehicleETA {
+		t.Helper()
+		eta, err := estimator.Estimate(context.Background(), key, types.BusMessage{
+			DriverID:       "driver-1",
+			CurrentRouteID: "route-1",
+			DriverLocation: types.Location{Latitude: 0.0001, Longitude: longitude},
+			Timestamp:      timestamp,
+		})
+		if err != nil || eta == nil {
+			t.Fatalf("Expected an estimate, got %v (%v)", eta, err)
+		}
+		return eta
+	}
+
+	if eta := estimate(0.0036, 1_000_000); eta.SpeedKmh != 20 || len(eta.Stops) != 3 {
+		t.Errorf("Expected the default speed and 3 stops ahead, got %v km/h and %d stops", eta.SpeedKmh, len(eta.Stops))
+	}
+	estimate(0.0063, 1_030_000)
+	eta := estimate(0.0090, 1_060_000)
+	if math.Abs(eta.SpeedKmh-36) > 0.5 {
+		t.Errorf("Expected a speed of 36 km/h over the last minute, got %.1f", eta.SpeedKmh)
+	}
+	if len(eta.Stops) != 2 || eta.Stops[0].StopID != "stop-2" {
+		t.Fatalf("Expected stop-1 to be passed, got %+v", eta.Stops)
+	}
+	// stop-2 is 1 km ahead at 10 m/s
+	if math.Abs(eta.Stops[0].ETASeconds-100) > 2 || eta.Stops[0].ArrivalAt != 1_060_000+int64(eta.Stops[0].ETASeconds*1000) {
+		t.Errorf("Expected stop-2 in about 100s, got %+v", eta.Stops[0])
+	}
+
+	// A stopped vehicle is assumed to move at the minimum speed
+	estimate(0.0090, 1_120_000)
+	if eta := estimate(0.0090, 1_125_000); eta.SpeedKmh != 5 {
+		t.Errorf("Expected the minimum speed while stopped, got %v km/h", eta.SpeedKmh)
+	}
+}
+
+func TestRouteETAs_StoresAndFiltersByStop(t *testing.T) {
+	service := newTestService(t, newMemoryBackend(), func(cfg *types.Config, backends *database.Backends) {
+		cfg.ETA.Enabled = true
+		backends.PlannedRoutes = memoryPlannedRoutes{"route-1": etaTestRoute}
+		backends.ETAs = database.NewMemoryBuffer(cfg.Redis)
+	})
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.010}}`,
+		`{"driverId":"driver-2","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.001}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	etas, err := service.RouteETAs(context.Background(), "route-1", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(etas) != 2 || len(etas[0].Stops) != 2 || len(etas[1].Stops) != 3 {
+		t.Fatalf("Expected the stops ahead of both vehicles, got %+v", etas)
+	}
+
+	etas, err = service.RouteETAs(context.Background(), "route-1", "stop-2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(etas) != 2 || etas[0].DriverID != "driver-1" || len(etas[0].Stops) != 1 || etas[0].Stops[0].StopID != "stop-2" {
+		t.Errorf("Expected driver-1 to reach stop-2 first, got %+v", etas)
+	}
+
+	finished := `{"driverId":"driver-1","currentRouteId":"route-1","status":"finished","timestamp":1640995260000,"driverLocation":{"latitude":0,"longitude":0.05}}`
+	if err := service.processMessage(context.Background(), []byte(finished), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if etas, _ := service.RouteETAs(context.Background(), "route-1", ""); len(etas) != 1 || etas[0].DriverID != "driver-2" {
+		t.Errorf("Expected the finished trip to be removed, got %+v", etas)
+	}
+}
+
+func TestClearRoute_ResetsETAProgress(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.ETA.Enabled = true
+		backends.PlannedRoutes = memoryPlannedRoutes{"route-1": etaTestRoute}
+		backends.ETAs = database.NewMemoryBuffer(cfg.Redis)
+	})
+
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.010}}`
+	if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	service.eta.mu.Lock()
+	_, tracked := service.eta.progress[key]
+	service.eta.mu.Unlock()
+	if !tracked {
+		t.Fatalf("Expected the progress of the route to be tracked")
+	}
+
+	buffered := backend.routes[key]
+	if err := service.clearRoute(context.Background(), key, buffered[len(buffered)-1].ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	service.eta.mu.Lock()
+	defer service.eta.mu.Unlock()
+	if _, ok := service.eta.progress[key]; ok {
+		t.Errorf("Expected the progress of the cleared route to be reset")
+	}
+}
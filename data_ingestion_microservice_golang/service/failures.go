@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Failure classes of the processing pipeline
+const (
+	FailureDecode     = "decode"
+	FailureValidation = "validation"
+	FailureRedis      = "redis"
+	FailureMongo      = "mongo"
+	FailureSimplify   = "simplify"
+	FailureExport     = "export"
+	FailurePartition  = "partition"
+	FailurePanic      = "panic"
+	FailureOther      = "other"
+)
+
+// maxFailurePayload bounds the message payload kept with a sampled failure
+const maxFailurePayload = 1024
+
+// classifiedError tags an error with the class of the stage that failed
+type classifiedError struct {
+	class string
+	err   error
+}
+
+// Error returns the message of the wrapped error
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// classify tags err with a failure class, keeping nil errors nil and the
+// innermost class of errors that are already classified
+func classify(class string, err error) error {
+	var classified *classifiedError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// failureClass returns the class of err, or FailureOther if it has none
+func failureClass(err error) string {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	return FailureOther
+}
+
+// permanentFailure reports whether err would fail the message again on
+// redelivery: it could not be decoded, validated, or simplified, or
+// processing it panicked. Other failures depend on a backend that may
+// recover.
+func permanentFailure(err error) bool {
+	switch failureClass(err) {
+	case FailureDecode, FailureValidation, FailureSimplify, FailurePanic:
+		return true
+	default:
+		return false
+	}
+}
+
+// FailureLog counts processing failures by class and keeps a sampled ring
+// buffer of the most recent ones for the admin API
+type FailureLog struct {
+	rate float64
+
+	mu      sync.Mutex
+	counts  map[string]int64
+	samples []types.ProcessingFailure
+	next    int
+	full    bool
+}
+
+// NewFailureLog creates a log keeping up to size failures, each sampled
+// with probability rate
+func NewFailureLog(size int, rate float64) *FailureLog {
+	return &FailureLog{
+		rate:    rate,
+		counts:  make(map[string]int64),
+		samples: make([]types.ProcessingFailure, max(size, 0)),
+	}
+}
+
+// Record counts a failure and samples it into the ring buffer
+func (fl *FailureLog) Record(err error, busMsg types.BusMessage, payload []byte) {
+	class := failureClass(err)
+	metrics.ProcessingErrors.Add(class, 1)
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	fl.counts[class]++
+	if len(fl.samples) == 0 || (fl.rate < 1 && rand.Float64() >= fl.rate) {
+		return
+	}
+	if len(payload) > maxFailurePayload {
+		payload = payload[:maxFailurePayload]
+	}
+	fl.samples[fl.next] = types.ProcessingFailure{
+		Time:           time.Now().UTC(),
+		Class:          class,
+		DriverID:       busMsg.DriverID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Error:          err.Error(),
+		Payload:        string(payload),
+	}
+	fl.next = (fl.next + 1) % len(fl.samples)
+	if fl.next == 0 {
+		fl.full = true
+	}
+}
+
+// Report returns the failure counts and the sampled failures, newest first
+func (fl *FailureLog) Report() types.FailureReport {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	report := types.FailureReport{
+		Counts:   make(map[string]int64, len(fl.counts)),
+		Failures: []types.ProcessingFailure{},
+	}
+	for class, count := range fl.counts {
+		report.Counts[class] = count
+	}
+
+	stored := fl.next
+	if fl.full {
+		stored = len(fl.samples)
+	}
+	for i := 1; i <= stored; i++ {
+		index := (fl.next - i + len(fl.samples)) % len(fl.samples)
+		report.Failures = append(report.Failures, fl.samples[index])
+	}
+	return report
+}
+
+// RecentFailures returns the processing failure counts by class and the
+// most recent sampled failures
+func (s *DataIngestionService) RecentFailures() types.FailureReport {
+	return s.failures.Report()
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestFailureLog_KeepsMostRecentFailures(t *testing.T) {
+	log := NewFailureLog(2, 1)
+	for i := 1; i <= 3; i++ {
+		log.Record(classify(FailureRedis, fmt.Errorf("failure %d", i)), types.BusMessage{DriverID: "driver-1"}, nil)
+	}
+	log.Record(errors.New("unclassified"), types.BusMessage{}, nil)
+
+	report := log.Report()
+	if report.Counts[FailureRedis] != 3 || report.Counts[FailureOther] != 1 {
+		t.Errorf("Expected 3 redis and 1 other failure, got %v", report.Counts)
+	}
+	if len(report.Failures) != 2 {
+		t.Fatalf("Expected 2 sampled failures, got %+v", report.Failures)
+	}
+	if report.Failures[0].Error != "unclassified" || report.Failures[1].Error != "failure 3" {
+		t.Errorf("Expected the newest failures first, got %+v", report.Failures)
+	}
+}
+
+func TestFailureLog_SamplesFailures(t *testing.T) {
+	log := NewFailureLog(10, 0)
+	log.Record(classify(FailureMongo, errors.New("timeout")), types.BusMessage{}, nil)
+
+	report := log.Report()
+	if report.Counts[FailureMongo] != 1 {
+		t.Errorf("Expected unsampled failures to be counted, got %v", report.Counts)
+	}
+	if len(report.Failures) != 0 {
+		t.Errorf("Expected no sampled failures, got %+v", report.Failures)
+	}
+}
+
+func TestClassify_KeepsInnermostClass(t *testing.T) {
+	err := classify(FailureExport, fmt.Errorf("wrapped: %w", classify(FailureRedis, errors.New("down"))))
+	if class := failureClass(err); class != FailureRedis {
+		t.Errorf("Expected class %s, got %s", FailureRedis, class)
+	}
+	if classify(FailureRedis, nil) != nil {
+		t.Errorf("Expected a nil error to stay nil")
+	}
+}
+
+func TestProcessMessage_ClassifiesFailures(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	tests := []struct {
+		name    string
+		message string
+		class   string
+	}{
+		{"malformed JSON", `{"driverId":`, FailureDecode},
+		{"missing driver", `{"currentRouteId":"route-1","status":"in_route"}`, FailureValidation},
+		{"unknown status", `{"driverId":"driver-1","currentRouteId":"route-1","status":"parked"}`, FailureValidation},
+		{"invalid location", `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","driverLocation":{"latitude":91,"longitude":0}}`, FailureValidation},
+		{"count without passengers", `{"driverId":"driver-1","currentRouteId":"route-1","status":"passenger_count","driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`, FailureValidation},
+		{"passengers on a location", `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","driverLocation":{"latitude":6.2442,"longitude":-75.5812},"passengers":{"boardings":1,"alightings":0}}`, FailureValidation},
+		{"invalid telemetry", `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","driverLocation":{"latitude":6.2442,"longitude":-75.5812},"battery":120}`, FailureValidation},
+		{"buffer error", `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`, FailureRedis},
+	}
+	backend.appendErr = errors.New("connection refused")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.processMessage(context.Background(), []byte(tt.message), nil)
+			if class := failureClass(err); err == nil || class != tt.class {
+				t.Errorf("Expected a %s failure, got %v (%s)", tt.class, err, class)
+			}
+		})
+	}
+
+	report := service.RecentFailures()
+	if report.Counts[FailureValidation] != 6 || report.Counts[FailureDecode] != 1 || report.Counts[FailureRedis] != 1 {
+		t.Errorf("Unexpected failure counts %v", report.Counts)
+	}
+	if len(report.Failures) != len(tests) || report.Failures[0].DriverID != "driver-1" || report.Failures[0].Payload == "" {
+		t.Errorf("Expected every failure to be sampled with its payload, got %+v", report.Failures)
+	}
+}
+
+func TestRepeatDetector_ReportsOncePerWindow(t *testing.T) {
+	detector := newRepeatDetector(3, time.Minute)
+	start := time.Now()
+
+	var reported []int
+	for i := 0; i < 5; i++ {
+		if count, report := detector.observe(FailureRedis, start.Add(time.Duration(i)*time.Second)); report {
+			reported = append(reported, count)
+		}
+	}
+	if len(reported) != 1 || reported[0] != 3 {
+		t.Errorf("Expected one report at the third failure, got %v", reported)
+	}
+	if _, report := detector.observe(FailureMongo, start); report {
+		t.Errorf("Expected classes to be counted separately")
+	}
+
+	// A new window starts counting again
+	for i := 0; i < 3; i++ {
+		if _, report := detector.observe(FailureRedis, start.Add(time.Minute+time.Duration(i)*time.Second)); report != (i == 2) {
+			t.Errorf("Unexpected report decision %v at failure %d of the new window", report, i+1)
+		}
+	}
+}
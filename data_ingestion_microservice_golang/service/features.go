@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// featureFlags holds the feature flags from the configuration and from the
+// Redis feature flag hash. Flags that are set nowhere are enabled.
+type featureFlags struct {
+	tenant     string
+	configured map[string]bool
+
+	mu     sync.RWMutex
+	stored map[string]bool
+}
+
+// enabled reports whether a flag is on for the tenant: a flag scoped to the
+// tenant wins over an unscoped one, and a stored flag wins over a
+// configured one with the same key
+func (f *featureFlags) enabled(flag string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	keys := []string{types.FeatureFlagKey("", flag)}
+	if f.tenant != "" {
+		keys = append([]string{types.FeatureFlagKey(f.tenant, flag)}, keys...)
+	}
+	for _, key := range keys {
+		for _, flags := range []map[string]bool{f.stored, f.configured} {
+			if enabled, ok := flags[key]; ok {
+				return enabled
+			}
+		}
+	}
+	return true
+}
+
+// setStored replaces the flags loaded from Redis
+func (f *featureFlags) setStored(flags map[string]bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stored = flags
+}
+
+// FeatureEnabled reports whether a feature flag is on for this instance's
+// tenant
+func (s *DataIngestionService) FeatureEnabled(flag string) bool {
+	return s.features.enabled(flag)
+}
+
+// FeatureFlags returns the state of every known feature flag
+func (s *DataIngestionService) FeatureFlags() map[string]bool {
+	flags := make(map[string]bool, len(types.FeatureFlags))
+	for _, flag := range types.FeatureFlags {
+		flags[flag] = s.features.enabled(flag)
+	}
+	return flags
+}
+
+// loadFeatureFlags reads the feature flags stored in Redis, skipping
+// unknown flags and invalid values
+func (s *DataIngestionService) loadFeatureFlags(ctx context.Context) error {
+	var stored map[string]string
+	err := s.redisCall(ctx, "redis.feature_flags", func() (err error) {
+		stored, err = s.backends.FeatureFlags.LoadFeatureFlags(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	flags := make(map[string]bool, len(stored))
+	for key, value := range stored {
+		enabled, err := types.ParseFeatureFlag(key, value)
+		if err != nil {
+			slog.WarnContext(ctx, "Ignoring invalid feature flag", "error", err)
+			continue
+		}
+		flags[key] = enabled
+	}
+	s.features.setStored(flags)
+	return nil
+}
+
+// refreshFeatureFlags reloads the stored feature flags every interval until
+// ctx is done, keeping the previous flags on failure
+func (s *DataIngestionService) refreshFeatureFlags(ctx context.Context, interval time.Duration) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.loadFeatureFlags(ctx); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh feature flags", "error", err)
+			}
+		}
+	}
+}
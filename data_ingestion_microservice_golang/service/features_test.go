@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryFeatureFlags is a feature flag hash in memory
+type memoryFeatureFlags map[string]string
+
+func (m memoryFeatureFlags) LoadFeatureFlags(ctx context.Context) (map[string]string, error) {
+	return maps.Clone(m), nil
+}
+
+// countingArchive counts the routes it archives
+type countingArchive struct {
+	archived int
+}
+
+func (a *countingArchive) ArchiveRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) (string, error) {
+	a.archived++
+	return "s3://archive/" + trip.ID, nil
+}
+
+func newFeatureFlagService(t *testing.T, tenant string, flags []string, stored memoryFeatureFlags, archive *countingArchive) *DataIngestionService {
+	t.Helper()
+	return newTestService(t, newMemoryBackend(), func(cfg *types.Config, backends *database.Backends) {
+		cfg.FeatureFlags.Flags = flags
+		cfg.FeatureFlags.Tenant = tenant
+		backends.FeatureFlags = stored
+		if archive != nil {
+			backends.Archive = archive
+		}
+	})
+}
+
+func TestFeatureEnabled_PrefersTenantAndStoredFlags(t *testing.T) {
+	service := newFeatureFlagService(t, "acme",
+		[]string{"live_republish=false", "acme:raw_route_archival=false"},
+		memoryFeatureFlags{
+			"raw_route_archival":   "false",
+			"acme:live_republish":  "true",
+			"other:live_republish": "false",
+			"map_matching":         "true",
+			"live_republish":       "sometimes",
+		}, nil)
+
+	expected := map[string]bool{
+		types.FeatureLiveRepublish:    true,
+		types.FeatureRawRouteArchival: false,
+	}
+	if flags := service.FeatureFlags(); !maps.Equal(flags, expected) {
+		t.Errorf("Expected %v, got %v", expected, flags)
+	}
+
+	// Without a tenant only unscoped flags apply, and unset flags are on
+	untenanted := newFeatureFlagService(t, "", []string{"live_republish=false"}, nil, nil)
+	if untenanted.FeatureEnabled(types.FeatureLiveRepublish) || !untenanted.FeatureEnabled(types.FeatureRawRouteArchival) {
+		t.Errorf("Expected only live republish to be off, got %v", untenanted.FeatureFlags())
+	}
+}
+
+func TestExportTrip_SkipsArchivalWhileFlagIsOff(t *testing.T) {
+	archive := &countingArchive{}
+	stored := memoryFeatureFlags{"raw_route_archival": "false"}
+	service := newFeatureFlagService(t, "", nil, stored, archive)
+
+	trip := types.Trip{ID: "trip-1"}
+	if err := service.exportTrip(context.Background(), &trip, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if archive.archived != 0 || trip.RawArchiveURL != "" {
+		t.Errorf("Expected no archived route, got %d archived at %q", archive.archived, trip.RawArchiveURL)
+	}
+
+	// Turning the flag back on takes effect on the next refresh
+	stored["raw_route_archival"] = "true"
+	if err := service.loadFeatureFlags(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := service.exportTrip(context.Background(), &trip, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if archive.archived != 1 || trip.RawArchiveURL != "s3://archive/trip-1" {
+		t.Errorf("Expected the route to be archived, got %d archived at %q", archive.archived, trip.RawArchiveURL)
+	}
+}
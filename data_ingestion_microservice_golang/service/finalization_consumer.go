@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/tracing"
+)
+
+// Finalization consumer group polling parameters
+const (
+	finalizationReadCount = 10
+	finalizationReadBlock = 5 * time.Second
+)
+
+// runFinalizationConsumer reads finished routes from the Redis finalization
+// stream as a member of the consumer group and feeds them to the worker pool.
+// Jobs are acknowledged only after they are finalized, so jobs held by a
+// crashed instance are claimed by another one once they have been idle for
+// the configured claim timeout.
+func (s *DataIngestionService) runFinalizationConsumer(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	consumer := s.config.InstanceID
+	claimIdle := s.config.Redis.FinalizeClaimIdle
+	lastClaim := time.Time{}
+
+	slog.Info("Consuming finalizations",
+		"stream", s.config.Redis.FinalizeStream,
+		"consumer", consumer,
+		"group", s.config.Redis.FinalizeGroup,
+	)
+
+	for ctx.Err() == nil {
+		// A standby leaves the finalizations to the active instance
+		if !s.active.Load() {
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.config.Standby.HeartbeatInterval):
+			}
+			continue
+		}
+
+		var entries []database.FinalizationEntry
+
+		if time.Since(lastClaim) >= claimIdle/2 {
+			lastClaim = time.Now()
+			claimed, err := s.backends.Finalizations.ClaimStaleFinalizations(ctx, consumer, claimIdle, finalizationReadCount)
+			if err != nil {
+				slog.Error("Error claiming stale finalizations", "error", err)
+			}
+			if len(claimed) > 0 {
+				slog.Info("Claimed stale finalizations", "count", len(claimed))
+			}
+			entries = append(entries, claimed...)
+		}
+
+		delivered, err := s.backends.Finalizations.ReadFinalizations(ctx, consumer, finalizationReadCount, finalizationReadBlock)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Error reading finalizations", "error", err)
+			time.Sleep(time.Second)
+		}
+		entries = append(entries, delivered...)
+
+		for _, entry := range entries {
+			entry := entry
+			key := routeKey(entry.BusMsg)
+			s.finalizer.Submit(tracing.Extract(s.ctx, entry.Trace), key, entry.BusMsg, func(err error) {
+				// Failed jobs stay pending and are retried once claimable
+				if err != nil {
+					return
+				}
+				if err := s.backends.Finalizations.AckFinalization(s.ctx, entry.ID); err != nil {
+					slog.Error("Error acknowledging finalization", "key", key, "error", err)
+				}
+			})
+		}
+	}
+}
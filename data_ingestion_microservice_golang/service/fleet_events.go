@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Fleet event types
+const (
+	EventTripStarted    = "trip_started"
+	EventLocationUpdate = "location_update"
+	EventTripFinished   = "trip_finished"
+)
+
+// fleetRouteIdleAfter is how long a route may go without locations before
+// it is forgotten; its next location is reported as a new trip
+const fleetRouteIdleAfter = time.Hour
+
+// FleetEvents derives trip lifecycle events from processed messages and fans
+// them out to subscribers. Location updates are throttled per route, and
+// like LiveStream a subscriber whose buffer is full is dropped. Shared
+// through a bus, events reach the subscribers of every instance and a trip
+// taken over by another instance is not announced again.
+type FleetEvents struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	bufferSize  int
+	routes      map[string]*fleetRoute
+	lastSweep   time.Time
+	subscribers map[*EventSubscription]struct{}
+	bus         database.FleetEventBus
+}
+
+// fleetRoute tracks a route seen by this instance
+type fleetRoute struct {
+	lastSeen    time.Time
+	lastEmitted time.Time
+}
+
+// EventSubscription receives fleet events, optionally limited to some routes
+type EventSubscription struct {
+	events  *FleetEvents
+	filter  streamMatcher
+	updates chan types.FleetEvent
+	closed  bool
+	dropped bool
+}
+
+// NewFleetEvents creates a fleet event stream that emits at most one
+// location update per route and interval
+func NewFleetEvents(interval time.Duration, bufferSize int) *FleetEvents {
+	if bufferSize <= 0 {
+		bufferSize = defaultStreamBufferSize
+	}
+	return &FleetEvents{
+		interval:    interval,
+		bufferSize:  bufferSize,
+		routes:      make(map[string]*fleetRoute),
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// shareThrough announces events on the bus instead of to local subscribers
+// only. Events announced by any instance are delivered to the subscribers
+// of this one by deliver.
+func (fe *FleetEvents) shareThrough(bus database.FleetEventBus) {
+	fe.bus = bus
+}
+
+// Location records an in-route location. The first location of a route
+// starts a trip; later ones become throttled location updates.
+func (fe *FleetEvents) Location(ctx context.Context, key string, busMsg types.BusMessage, now time.Time) {
+	fe.mu.Lock()
+	fe.sweep(now)
+
+	event := types.FleetEvent{
+		DriverID:  busMsg.DriverID,
+		RouteID:   busMsg.CurrentRouteID,
+		Location:  busMsg.DriverLocation,
+		Timestamp: busMsg.Timestamp,
+	}
+
+	route, ok := fe.routes[key]
+	switch {
+	case !ok:
+		fe.routes[key] = &fleetRoute{lastSeen: now, lastEmitted: now}
+		event.Type = EventTripStarted
+	case now.Sub(route.lastEmitted) >= fe.interval:
+		route.lastSeen, route.lastEmitted = now, now
+		event.Type = EventLocationUpdate
+	default:
+		route.lastSeen = now
+		fe.mu.Unlock()
+		return
+	}
+	fe.mu.Unlock()
+
+	// The bus knows whether another instance started or finished the trip
+	// while the driver was away from this one. Every emitted event keeps
+	// the mark alive.
+	if fe.bus != nil {
+		started, err := fe.bus.TrackFleetRoute(ctx, key, fleetRouteIdleAfter)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "Failed to track fleet route", "key", key, "error", err)
+		case started:
+			event.Type = EventTripStarted
+		default:
+			event.Type = EventLocationUpdate
+		}
+	}
+
+	fe.announce(ctx, event)
+}
+
+// Finished forgets a route whose finished message was processed
+func (fe *FleetEvents) Finished(ctx context.Context, key string) {
+	fe.mu.Lock()
+	delete(fe.routes, key)
+	fe.mu.Unlock()
+
+	if fe.bus != nil {
+		if err := fe.bus.ForgetFleetRoute(ctx, key); err != nil {
+			slog.WarnContext(ctx, "Failed to forget fleet route", "key", key, "error", err)
+		}
+	}
+}
+
+// TripFinished announces a finalized trip and returns the announced event
+func (fe *FleetEvents) TripFinished(ctx context.Context, trip types.Trip, busMsg types.BusMessage) types.FleetEvent {
+	stats := trip.Stats
+	event := types.FleetEvent{
+		Type:      EventTripFinished,
+		DriverID:  trip.DriverID,
+		RouteID:   trip.CurrentRouteID,
+		TripID:    trip.ID,
+		Location:  busMsg.DriverLocation,
+		Timestamp: busMsg.Timestamp,
+		Stats:     &stats,
+		Status:    trip.Status,
+	}
+	fe.announce(ctx, event)
+	return event
+}
+
+// announce shares an event on the bus, or delivers it to the local
+// subscribers without one or when the bus is unavailable
+func (fe *FleetEvents) announce(ctx context.Context, event types.FleetEvent) {
+	if fe.bus != nil {
+		err := fe.bus.PublishFleetEvent(ctx, event)
+		if err == nil {
+			return
+		}
+		slog.WarnContext(ctx, "Failed to share fleet event, delivering it locally", "type", event.Type, "error", err)
+	}
+	fe.deliver(event)
+}
+
+// deliver publishes an event to the local subscribers
+func (fe *FleetEvents) deliver(event types.FleetEvent) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.publish(event)
+}
+
+// Subscribe registers a subscriber for the events matching a validated
+// filter, or every event when the filter is empty
+func (fe *FleetEvents) Subscribe(filter StreamFilter) *EventSubscription {
+	sub := &EventSubscription{
+		events:  fe,
+		filter:  newStreamMatcher(filter),
+		updates: make(chan types.FleetEvent, fe.bufferSize),
+	}
+
+	fe.mu.Lock()
+	fe.subscribers[sub] = struct{}{}
+	fe.mu.Unlock()
+
+	metrics.FleetEventSubscribers.Add(1)
+	return sub
+}
+
+// publish delivers an event to every matching subscriber. Callers must hold
+// fe.mu.
+func (fe *FleetEvents) publish(event types.FleetEvent) {
+	for sub := range fe.subscribers {
+		if !sub.filter.matches(event.RouteID, event.DriverID, event.Location) {
+			continue
+		}
+		select {
+		case sub.updates <- event:
+		default:
+			sub.dropped = true
+			fe.remove(sub)
+			metrics.FleetEventsDropped.Add(1)
+		}
+	}
+}
+
+// sweep forgets routes that stopped sending locations without finishing.
+// Callers must hold fe.mu.
+func (fe *FleetEvents) sweep(now time.Time) {
+	if now.Sub(fe.lastSweep) < fleetRouteIdleAfter {
+		return
+	}
+	fe.lastSweep = now
+	for key, route := range fe.routes {
+		if now.Sub(route.lastSeen) > fleetRouteIdleAfter {
+			delete(fe.routes, key)
+		}
+	}
+}
+
+// remove unregisters a subscriber and closes its channel. Callers must hold
+// fe.mu.
+func (fe *FleetEvents) remove(sub *EventSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(fe.subscribers, sub)
+	close(sub.updates)
+	metrics.FleetEventSubscribers.Add(-1)
+}
+
+// Updates returns the subscriber's events. The channel is closed when the
+// subscription is closed or dropped.
+func (sub *EventSubscription) Updates() <-chan types.FleetEvent {
+	return sub.updates
+}
+
+// Dropped reports whether the subscriber was dropped for falling behind
+func (sub *EventSubscription) Dropped() bool {
+	sub.events.mu.Lock()
+	defer sub.events.mu.Unlock()
+	return sub.dropped
+}
+
+// Close stops delivering events
+func (sub *EventSubscription) Close() {
+	sub.events.mu.Lock()
+	defer sub.events.mu.Unlock()
+	sub.events.remove(sub)
+}
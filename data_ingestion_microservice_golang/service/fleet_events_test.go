@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func drainEvents(sub *EventSubscription) []string {
+	var events []string
+	for len(sub.Updates()) > 0 {
+		event := <-sub.Updates()
+		events = append(events, event.Type+":"+event.RouteID)
+	}
+	return events
+}
+
+func TestFleetEvents_LifecycleAndThrottling(t *testing.T) {
+	events := NewFleetEvents(5*time.Second, 16)
+	sub := events.Subscribe(StreamFilter{})
+	defer sub.Close()
+
+	start := time.Unix(1640995200, 0)
+	busMsg := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "in_route"}
+	key := "route:driver-1:route-1"
+
+	events.Location(context.Background(), key, busMsg, start)
+	events.Location(context.Background(), key, busMsg, start.Add(2*time.Second))
+	events.Location(context.Background(), key, busMsg, start.Add(5*time.Second))
+	events.Location(context.Background(), key, busMsg, start.Add(6*time.Second))
+	events.Finished(context.Background(), key)
+	events.TripFinished(context.Background(), types.Trip{ID: "trip-1", DriverID: "driver-1", CurrentRouteID: "route-1"}, busMsg)
+
+	// A new trip on the same route starts again after the finished message
+	events.Location(context.Background(), key, busMsg, start.Add(7*time.Second))
+
+	got := drainEvents(sub)
+	want := []string{"trip_started:route-1", "location_update:route-1", "trip_finished:route-1", "trip_started:route-1"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestFleetEvents_RouteFilterAndSlowSubscribers(t *testing.T) {
+	events := NewFleetEvents(0, 1)
+	filtered := events.Subscribe(StreamFilter{RouteIDs: []string{"route-2"}})
+	defer filtered.Close()
+	slow := events.Subscribe(StreamFilter{})
+
+	now := time.Now()
+	events.Location(context.Background(), "route:driver-1:route-1", types.BusMessage{CurrentRouteID: "route-1"}, now)
+	events.Location(context.Background(), "route:driver-1:route-1", types.BusMessage{CurrentRouteID: "route-1"}, now)
+
+	if len(filtered.Updates()) != 0 {
+		t.Errorf("Expected no events for route-2 subscribers")
+	}
+	if !slow.Dropped() {
+		t.Errorf("Expected the slow subscriber to be dropped")
+	}
+}
+
+func TestFleetEvents_ForgetsIdleRoutes(t *testing.T) {
+	events := NewFleetEvents(time.Second, 16)
+	sub := events.Subscribe(StreamFilter{})
+	defer sub.Close()
+
+	start := time.Unix(1640995200, 0)
+	events.Location(context.Background(), "route:driver-1:route-1", types.BusMessage{CurrentRouteID: "route-1"}, start)
+	events.Location(context.Background(), "route:driver-1:route-1", types.BusMessage{CurrentRouteID: "route-1"}, start.Add(2*fleetRouteIdleAfter))
+
+	got := drainEvents(sub)
+	if len(got) != 2 || got[1] != "trip_started:route-1" {
+		t.Errorf("Expected the idle route to start a new trip, got %v", got)
+	}
+}
+
+// fakeFleetBus is an in-memory FleetEventBus shared by several instances
+type fakeFleetBus struct {
+	mu        sync.Mutex
+	started   map[string]bool
+	instances []*FleetEvents
+}
+
+func (b *fakeFleetBus) PublishFleetEvent(ctx context.Context, event types.FleetEvent) error {
+	for _, instance := range b.instances {
+		instance.deliver(event)
+	}
+	return nil
+}
+
+func (b *fakeFleetBus) WatchFleetEvents(ctx context.Context, handler func(types.FleetEvent)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (b *fakeFleetBus) TrackFleetRoute(ctx context.Context, key string, idleAfter time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started[key] {
+		return false, nil
+	}
+	b.started[key] = true
+	return true, nil
+}
+
+func (b *fakeFleetBus) ForgetFleetRoute(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.started, key)
+	return nil
+}
+
+func TestFleetEvents_SharedAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	bus := &fakeFleetBus{started: map[string]bool{}}
+	first, second := NewFleetEvents(0, 16), NewFleetEvents(0, 16)
+	bus.instances = []*FleetEvents{first, second}
+	first.shareThrough(bus)
+	second.shareThrough(bus)
+	sub := second.Subscribe(StreamFilter{})
+	defer sub.Close()
+
+	busMsg := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "in_route"}
+	key := "route:driver-1:route-1"
+	first.Location(ctx, key, busMsg, time.Now())
+	// The driver moves to the second instance mid-trip
+	second.Location(ctx, key, busMsg, time.Now())
+	second.Finished(ctx, key)
+	second.TripFinished(ctx, types.Trip{ID: "trip-1", DriverID: "driver-1", CurrentRouteID: "route-1"}, busMsg)
+	first.Location(ctx, key, busMsg, time.Now())
+
+	got := drainEvents(sub)
+	want := []string{"trip_started:route-1", "location_update:route-1", "trip_finished:route-1", "trip_started:route-1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
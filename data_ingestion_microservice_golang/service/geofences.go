@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Geofence event types
+const (
+	EventGeofenceEntered = "geofence_entered"
+	EventGeofenceExited  = "geofence_exited"
+)
+
+// Geofence index layout: the size of a grid cell in degrees (about 1 km),
+// and how many cells a geofence may cover before it is checked against
+// every location instead
+const (
+	geofenceCellDegrees  = 0.01
+	maxGeofenceCells     = 10000
+	metersPerDegreeOfLat = 111320
+)
+
+// GeofenceEngine evaluates live locations against the stored geofences,
+// reporting the geofences each route enters and exits
+type GeofenceEngine struct {
+	config types.GeofenceConfig
+	store  database.GeofenceStore
+	broker database.MessageBroker
+
+	mu     sync.Mutex
+	index  *geofenceIndex
+	inside map[string][]string
+}
+
+// NewGeofenceEngine creates a geofence engine with no geofences until they
+// are loaded
+func NewGeofenceEngine(config types.GeofenceConfig, store database.GeofenceStore, broker database.MessageBroker) *GeofenceEngine {
+	return &GeofenceEngine{
+		config: config,
+		store:  store,
+		broker: broker,
+		index:  newGeofenceIndex(nil),
+		inside: make(map[string][]string),
+	}
+}
+
+// Load replaces the geofences with those of the store. Invalid geofences
+// are skipped with a warning.
+func (g *GeofenceEngine) Load(ctx context.Context) error {
+	geofences, err := g.store.LoadGeofences(ctx)
+	if err != nil {
+		return err
+	}
+
+	valid := make([]types.Geofence, 0, len(geofences))
+	for _, geofence := range geofences {
+		if err := validateGeofence(geofence); err != nil {
+			slog.WarnContext(ctx, "Ignoring invalid geofence", "geofenceId", geofence.ID, "error", err)
+			continue
+		}
+		valid = append(valid, geofence)
+	}
+
+	index := newGeofenceIndex(valid)
+	g.mu.Lock()
+	g.index = index
+	g.mu.Unlock()
+
+	metrics.GeofencesLoaded.Set(int64(len(valid)))
+	return nil
+}
+
+// Check evaluates a live point and publishes an event for every geofence
+// the route entered or exited since its previous point. The published
+// events are returned. When publishing fails the route keeps its previous
+// geofences, so a redelivered message reports them again.
+func (g *GeofenceEngine) Check(ctx context.Context, key string, busMsg types.BusMessage) ([]types.GeofenceEvent, error) {
+	g.mu.Lock()
+	index := g.index
+	previous := g.inside[key]
+	g.mu.Unlock()
+
+	current := index.containing(busMsg.DriverLocation)
+
+	var events []types.GeofenceEvent
+	for _, id := range current {
+		if !slices.Contains(previous, id) {
+			events = append(events, g.event(EventGeofenceEntered, index, id, busMsg))
+		}
+	}
+	for _, id := range previous {
+		if !slices.Contains(current, id) {
+			events = append(events, g.event(EventGeofenceExited, index, id, busMsg))
+		}
+	}
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal geofence event: %w", err)
+		}
+		topic := fmt.Sprintf("%s/%s", g.config.EventTopic, event.GeofenceID)
+		if err := g.broker.PublishMessage(topic, payload); err != nil {
+			return nil, fmt.Errorf("failed to publish geofence event: %w", err)
+		}
+		metrics.GeofenceEvents.Add(event.Type, 1)
+		slog.InfoContext(ctx, "Driver crossed a geofence", "event", event.Type, "geofenceId", event.GeofenceID)
+	}
+
+	g.mu.Lock()
+	if len(current) == 0 {
+		delete(g.inside, key)
+	} else {
+		g.inside[key] = current
+	}
+	g.mu.Unlock()
+
+	return events, nil
+}
+
+// Zones returns the IDs of the geofences the points passed through, in the
+// order they were first visited
+func (g *GeofenceEngine) Zones(points []types.TrackPoint) []string {
+	g.mu.Lock()
+	index := g.index
+	g.mu.Unlock()
+
+	var zones []string
+	for _, point := range points {
+		for _, id := range index.containing(point.Location) {
+			if !slices.Contains(zones, id) {
+				zones = append(zones, id)
+			}
+		}
+	}
+	return zones
+}
+
+// Reset discards the geofences of a finished route
+func (g *GeofenceEngine) Reset(key string) {
+	g.mu.Lock()
+	delete(g.inside, key)
+	g.mu.Unlock()
+}
+
+// event builds a geofence event for a point. Geofences removed since the
+// route entered them are reported without a name.
+func (g *GeofenceEngine) event(eventType string, index *geofenceIndex, id string, busMsg types.BusMessage) types.GeofenceEvent {
+	event := types.GeofenceEvent{
+		Type:           eventType,
+		GeofenceID:     id,
+		DriverID:       busMsg.DriverID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Location:       busMsg.DriverLocation,
+		Timestamp:      busMsg.Timestamp,
+	}
+	if geofence, ok := index.byID[id]; ok {
+		event.GeofenceName = geofence.Name
+	}
+	return event
+}
+
+// validateGeofence checks that a geofence is a usable circle or polygon
+func validateGeofence(geofence types.Geofence) error {
+	switch {
+	case geofence.ID == "":
+		return fmt.Errorf("%w: missing ID", ErrInvalidGeofence)
+	case geofence.Center != nil:
+		center := geofence.Center
+		if center.Latitude < -90 || center.Latitude > 90 || center.Longitude < -180 || center.Longitude > 180 {
+			return fmt.Errorf("%w: center %v,%v is out of range", ErrInvalidGeofence, center.Latitude, center.Longitude)
+		}
+		if geofence.RadiusMeters <= 0 {
+			return fmt.Errorf("%w: radius must be positive, got %v", ErrInvalidGeofence, geofence.RadiusMeters)
+		}
+		return nil
+	case len(geofence.Polygon) == 0:
+		return fmt.Errorf("%w: expected a polygon or a center and radius", ErrInvalidGeofence)
+	default:
+		return StreamFilter{Geofence: geofence.Polygon}.Validate()
+	}
+}
+
+// geofenceCell is a cell of the grid indexing the geofences
+type geofenceCell struct {
+	lat, lon int
+}
+
+// cellOf returns the grid cell holding a location
+func cellOf(latitude, longitude float64) geofenceCell {
+	return geofenceCell{
+		lat: int(math.Floor(latitude / geofenceCellDegrees)),
+		lon: int(math.Floor(longitude / geofenceCellDegrees)),
+	}
+}
+
+// geofenceIndex finds the geofences containing a location. Every geofence
+// is listed in the grid cells its bounding box covers, so a location is only
+// tested against the geofences of its cell; geofences covering too many
+// cells are tested against every location. It is immutable once built.
+type geofenceIndex struct {
+	geofences []types.Geofence
+	bounds    []types.BoundingBox
+	byID      map[string]*types.Geofence
+	cells     map[geofenceCell][]int
+	large     []int
+}
+
+// newGeofenceIndex indexes validated geofences
+func newGeofenceIndex(geofences []types.Geofence) *geofenceIndex {
+	index := &geofenceIndex{
+		geofences: geofences,
+		bounds:    make([]types.BoundingBox, len(geofences)),
+		byID:      make(map[string]*types.Geofence, len(geofences)),
+		cells:     make(map[geofenceCell][]int),
+	}
+	for i := range geofences {
+		geofence := &geofences[i]
+		index.byID[geofence.ID] = geofence
+		box := geofenceBounds(*geofence)
+		index.bounds[i] = box
+
+		low, high := cellOf(box.MinLat, box.MinLon), cellOf(box.MaxLat, box.MaxLon)
+		if (high.lat-low.lat+1)*(high.lon-low.lon+1) > maxGeofenceCells {
+			index.large = append(index.large, i)
+			continue
+		}
+		for lat := low.lat; lat <= high.lat; lat++ {
+			for lon := low.lon; lon <= high.lon; lon++ {
+				cell := geofenceCell{lat, lon}
+				index.cells[cell] = append(index.cells[cell], i)
+			}
+		}
+	}
+	return index
+}
+
+// containing returns the sorted IDs of the geofences containing a location
+func (index *geofenceIndex) containing(location types.Location) []string {
+	var ids []string
+	check := func(i int) {
+		if algorithm.Contains(index.bounds[i], location) && geofenceContains(index.geofences[i], location) {
+			ids = append(ids, index.geofences[i].ID)
+		}
+	}
+	for _, i := range index.cells[cellOf(location.Latitude, location.Longitude)] {
+		check(i)
+	}
+	for _, i := range index.large {
+		check(i)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// geofenceContains reports whether a location lies inside a geofence
+func geofenceContains(geofence types.Geofence, location types.Location) bool {
+	if geofence.Center != nil {
+		return algorithm.HaversineDistance(*geofence.Center, location) <= geofence.RadiusMeters
+	}
+	return algorithm.PointInPolygon(location, geofence.Polygon)
+}
+
+// geofenceBounds returns the bounding box of a geofence. The box of a
+// circle is widened in longitude away from the equator.
+func geofenceBounds(geofence types.Geofence) types.BoundingBox {
+	if geofence.Center == nil {
+		return algorithm.PolygonBounds(geofence.Polygon)
+	}
+	center := *geofence.Center
+	latDelta := geofence.RadiusMeters / metersPerDegreeOfLat
+	lonDelta := latDelta / math.Max(math.Cos(center.Latitude*math.Pi/180), 1e-6)
+	return types.BoundingBox{
+		MinLat: math.Max(center.Latitude-latDelta, -90),
+		MaxLat: math.Min(center.Latitude+latDelta, 90),
+		MinLon: math.Max(center.Longitude-lonDelta, -180),
+		MaxLon: math.Min(center.Longitude+lonDelta, 180),
+	}
+}
+
+// refreshGeofences reloads the geofences every interval until ctx is done,
+// keeping the previous geofences on failure
+func (s *DataIngestionService) refreshGeofences(ctx context.Context, interval time.Duration) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.geofences.Load(ctx); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh geofences", "error", err)
+			}
+		}
+	}
+}
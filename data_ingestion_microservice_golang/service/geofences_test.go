@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryGeofences serves a fixed list of geofences
+type memoryGeofences []types.Geofence
+
+func (m memoryGeofences) LoadGeofences(ctx context.Context) ([]types.Geofence, error) {
+	return m, nil
+}
+
+// testGeofences are a depot circle and a downtown square next to each other
+var testGeofences = memoryGeofences{
+	{ID: "depot", Name: "Depot", Center: &types.Location{Latitude: 6.2442, Longitude: -75.5812}, RadiusMeters: 150},
+	{ID: "downtown", Polygon: []types.Location{
+		{Latitude: 6.246, Longitude: -75.584},
+		{Latitude: 6.246, Longitude: -75.582},
+		{Latitude: 6.248, Longitude: -75.582},
+		{Latitude: 6.248, Longitude: -75.584},
+	}},
+	{ID: "broken", Polygon: []types.Location{{Latitude: 6.2, Longitude: -75.5}}},
+}
+
+func TestGeofenceEngine_ReportsEntriesAndExits(t *testing.T) {
+	broker := &recordingBroker{}
+	engine := NewGeofenceEngine(types.GeofenceConfig{EventTopic: "events/geofence"}, testGeofences, broker)
+	if err := engine.Load(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	path := []types.Location{
+		{Latitude: 6.2442, Longitude: -75.5812}, // depot
+		{Latitude: 6.2443, Longitude: -75.5813}, // still in the depot
+		{Latitude: 6.2470, Longitude: -75.5830}, // downtown
+		{Latitude: 6.2600, Longitude: -75.6000}, // nowhere
+	}
+	want := [][]string{
+		{"geofence_entered depot"},
+		nil,
+		{"geofence_entered downtown", "geofence_exited depot"},
+		{"geofence_exited downtown"},
+	}
+	for i, location := range path {
+		events, err := engine.Check(context.Background(), key, types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", DriverLocation: location})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var got []string
+		for _, event := range events {
+			got = append(got, event.Type+" "+event.GeofenceID)
+		}
+		if !slices.Equal(got, want[i]) {
+			t.Errorf("Point %d: expected events %v, got %v", i, want[i], got)
+		}
+	}
+
+	published := broker.published["events/geofence/depot"]
+	if len(published) != 2 {
+		t.Fatalf("Expected 2 depot events published, got %d", len(published))
+	}
+	var event types.GeofenceEvent
+	if err := json.Unmarshal(published[0], &event); err != nil {
+		t.Fatalf("Failed to decode geofence event: %v", err)
+	}
+	if event.GeofenceName != "Depot" || event.DriverID != "driver-1" {
+		t.Errorf("Unexpected geofence event %+v", event)
+	}
+
+	points := make([]types.TrackPoint, len(path))
+	for i, location := range path {
+		points[i] = types.TrackPoint{Location: location}
+	}
+	if zones := engine.Zones(points); !slices.Equal(zones, []string{"depot", "downtown"}) {
+		t.Errorf("Expected zones [depot downtown], got %v", zones)
+	}
+}
+
+func TestGeofenceIndex_LargeGeofences(t *testing.T) {
+	country := types.Geofence{ID: "country", Polygon: []types.Location{
+		{Latitude: -4, Longitude: -79}, {Latitude: -4, Longitude: -67}, {Latitude: 12, Longitude: -67}, {Latitude: 12, Longitude: -79},
+	}}
+	index := newGeofenceIndex([]types.Geofence{country})
+	if len(index.large) != 1 {
+		t.Fatalf("Expected the geofence to be checked against every location, got %d large geofences", len(index.large))
+	}
+	if ids := index.containing(types.Location{Latitude: 6.2442, Longitude: -75.5812}); !slices.Equal(ids, []string{"country"}) {
+		t.Errorf("Expected the location inside the country, got %v", ids)
+	}
+}
+
+func TestHandleFinished_TagsTripWithZones(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Geofence.Enabled = true
+		backends.Geofences = testGeofences
+	})
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995210000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995220000,"driverLocation":{"latitude":6.2470,"longitude":-75.5830}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995230000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		if !slices.Equal(trip.Zones, []string{"depot", "downtown"}) {
+			t.Errorf("Expected the trip tagged with [depot downtown], got %v", trip.Zones)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_StoresSafetyEvents(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.HarshDriving.Enabled = true
+	})
+
+	// Braking from 50 to 26 km/h in a second, then a hard turn reported by
+	// the accelerometer
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0},"speed":50}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995201000,"driverLocation":{"latitude":0,"longitude":0.0001},"speed":50}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995202000,"driverLocation":{"latitude":0,"longitude":0.0002},"speed":26}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995203000,"driverLocation":{"latitude":0,"longitude":0.0003},"speed":26,"lateralAcceleration":-5.2}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995204000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		safety := trip.Safety
+		if safety == nil || safety.HarshBraking != 1 || safety.HarshCornering != 1 || len(safety.Events) != 2 {
+			t.Fatalf("Expected one braking and one cornering event, got %+v", safety)
+		}
+		if cornering := safety.Events[1]; cornering.Type != algorithm.HarshCornering || cornering.AccelerationMps2 != 5.2 || cornering.Source != "accelerometer" {
+			t.Errorf("Expected the accelerometer cornering, got %+v", cornering)
+		}
+		if safety.Score >= 100 {
+			t.Errorf("Expected harsh events to lower the score, got %v", safety.Score)
+		}
+	}
+}
+
+func TestHandleFinished_SkipsSafetyWhenDisabled(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0},"acceleration":-9}`
+	if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995204000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, trip := range backend.trips {
+		if trip.Safety != nil {
+			t.Errorf("Expected no safety summary, got %+v", trip.Safety)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Headway event types
+const (
+	EventBunching   = "bunching"
+	EventHeadwayGap = "headway_gap"
+)
+
+// ErrHeadwaysDisabled is returned for headway reads when headway monitoring
+// is disabled
+var ErrHeadwaysDisabled = errors.New("headway monitoring is disabled")
+
+// headwayVehicle is a vehicle followed along its planned route, with its
+// progress over the last gap threshold and its latest headway
+type headwayVehicle struct {
+	history []progressSample
+	seenAt  time.Time
+	headway types.VehicleHeadway
+}
+
+// HeadwayMonitor follows the vehicles on every planned route and publishes an
+// event when a vehicle gets bunched with the vehicle ahead of it, or falls
+// far enough behind it to leave a gap. A headway is how long ago the vehicle
+// ahead passed the place the vehicle is at now, so every vehicle keeps its
+// progress along the route back to the gap threshold. Only the vehicles
+// processed by this instance are compared.
+type HeadwayMonitor struct {
+	config types.HeadwayConfig
+	routes *plannedRouteCache
+	broker database.MessageBroker
+
+	mu sync.Mutex
+	// vehicles holds the followed vehicles by route ID and route key
+	vehicles map[string]map[string]*headwayVehicle
+	// routeIDs holds the route ID of every followed route key
+	routeIDs map[string]string
+}
+
+// NewHeadwayMonitor creates a new bunching and gap monitor
+func NewHeadwayMonitor(config types.HeadwayConfig, routes database.PlannedRouteStore, broker database.MessageBroker) *HeadwayMonitor {
+	return &HeadwayMonitor{
+		config:   config,
+		routes:   newPlannedRouteCache(routes),
+		broker:   broker,
+		vehicles: make(map[string]map[string]*headwayVehicle),
+		routeIDs: make(map[string]string),
+	}
+}
+
+// Check adds a live point to its route, updates the vehicle's headway to the
+// vehicle ahead of it, and publishes an event when the vehicle became
+// bunched or fell behind into a gap. The published event is returned, or nil
+// when nothing was reported.
+func (m *HeadwayMonitor) Check(ctx context.Context, key string, busMsg types.BusMessage) (*types.HeadwayEvent, error) {
+	route, err := m.routes.get(ctx, busMsg.CurrentRouteID)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil || len(route.Path) < 2 || busMsg.Timestamp == 0 {
+		return nil, nil
+	}
+
+	sample := progressSample{meters: algorithm.PathProgress(busMsg.DriverLocation, route.Path), timestamp: busMsg.Timestamp}
+
+	m.mu.Lock()
+	vehicles := m.vehicles[busMsg.CurrentRouteID]
+	if vehicles == nil {
+		vehicles = make(map[string]*headwayVehicle)
+		m.vehicles[busMsg.CurrentRouteID] = vehicles
+	}
+	vehicle, ok := vehicles[key]
+	if !ok {
+		vehicle = &headwayVehicle{}
+		vehicles[key] = vehicle
+		m.routeIDs[key] = busMsg.CurrentRouteID
+	}
+	m.record(vehicle, sample)
+	vehicle.seenAt = time.Now()
+
+	previous := vehicle.headway.Status
+	vehicle.headway = m.headway(key, vehicles, busMsg, sample)
+	headway := vehicle.headway
+	m.mu.Unlock()
+
+	if headway.Status == previous || (headway.Status != types.HeadwayBunched && headway.Status != types.HeadwayGap) {
+		return nil, nil
+	}
+
+	event := types.HeadwayEvent{
+		Type:             EventBunching,
+		DriverID:         busMsg.DriverID,
+		VehicleID:        busMsg.VehicleID,
+		CurrentRouteID:   busMsg.CurrentRouteID,
+		Location:         busMsg.DriverLocation,
+		Timestamp:        busMsg.Timestamp,
+		LeaderDriverID:   headway.LeaderDriverID,
+		DistanceMeters:   headway.DistanceMeters,
+		HeadwaySeconds:   *headway.HeadwaySeconds,
+		ThresholdSeconds: m.config.BunchingThreshold.Seconds(),
+	}
+	if headway.Status == types.HeadwayGap {
+		event.Type = EventHeadwayGap
+		event.ThresholdSeconds = m.config.GapThreshold.Seconds()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal headway event: %w", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s", m.config.EventTopic, busMsg.CurrentRouteID)
+	if err := m.broker.PublishMessage(topic, payload); err != nil {
+		return nil, fmt.Errorf("failed to publish headway event: %w", err)
+	}
+
+	metrics.HeadwayEvents.Add(event.Type, 1)
+	slog.InfoContext(ctx, "Vehicle headway out of range",
+		"type", event.Type,
+		"leaderDriverId", event.LeaderDriverID,
+		"headwaySeconds", event.HeadwaySeconds,
+	)
+	return &event, nil
+}
+
+// record adds a progress sample to a vehicle's history, keeping the samples
+// back to the newest one at least a gap threshold old. Callers must hold m.mu.
+func (m *HeadwayMonitor) record(vehicle *headwayVehicle, sample progressSample) {
+	history := vehicle.history
+	// Start over when messages go back in time
+	if len(history) > 0 && sample.timestamp < history[len(history)-1].timestamp {
+		history = nil
+	}
+	history = append(history, sample)
+
+	window := uint64(m.config.GapThreshold.Milliseconds())
+	oldest := 0
+	for oldest+1 < len(history) && sample.timestamp-history[oldest+1].timestamp >= window {
+		oldest++
+	}
+	vehicle.history = history[oldest:]
+}
+
+// headway returns the headway of a vehicle at a progress sample behind the
+// nearest vehicle ahead of it, forgetting the vehicles on the route that
+// sent nothing for a gap threshold. Callers must hold m.mu.
+func (m *HeadwayMonitor) headway(key string, vehicles map[string]*headwayVehicle, busMsg types.BusMessage, sample progressSample) types.VehicleHeadway {
+	headway := types.VehicleHeadway{
+		DriverID:       busMsg.DriverID,
+		VehicleID:      busMsg.VehicleID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		ProgressMeters: sample.meters,
+		Status:         types.HeadwayLeading,
+		Timestamp:      busMsg.Timestamp,
+	}
+
+	window := uint64(m.config.GapThreshold.Milliseconds())
+	var leader *headwayVehicle
+	for other, vehicle := range vehicles {
+		if other == key {
+			continue
+		}
+		last := vehicle.history[len(vehicle.history)-1]
+		if last.timestamp+window < sample.timestamp {
+			delete(vehicles, other)
+			delete(m.routeIDs, other)
+			continue
+		}
+		if last.meters > sample.meters && (leader == nil || last.meters < leader.headway.ProgressMeters) {
+			leader = vehicle
+		}
+	}
+	if leader == nil {
+		return headway
+	}
+
+	headway.LeaderDriverID = leader.headway.DriverID
+	headway.DistanceMeters = leader.headway.ProgressMeters - sample.meters
+	headway.Status = types.HeadwayUnknown
+
+	var seconds float64
+	if passed, ok := passedAt(leader.history, sample.meters); ok {
+		seconds = max(float64(int64(sample.timestamp)-int64(passed))/1000, 0)
+	} else if first := leader.history[0]; first.meters > sample.meters && sample.timestamp-first.timestamp >= window {
+		// The leader passed before its history starts, so the headway is
+		// at least as long as the history and already a gap
+		seconds = float64(sample.timestamp-first.timestamp) / 1000
+	} else {
+		return headway
+	}
+
+	headway.HeadwaySeconds = &seconds
+	switch {
+	case seconds < m.config.BunchingThreshold.Seconds():
+		headway.Status = types.HeadwayBunched
+	case seconds > m.config.GapThreshold.Seconds():
+		headway.Status = types.HeadwayGap
+	default:
+		headway.Status = types.HeadwayNormal
+	}
+	return headway
+}
+
+// passedAt returns when a vehicle's history last passed a progress along
+// the route, interpolated between the samples around it, or false when the
+// history does not pass it
+func passedAt(history []progressSample, meters float64) (uint64, bool) {
+	for i := len(history) - 1; i > 0; i-- {
+		before, after := history[i-1], history[i]
+		if before.meters > meters || after.meters < meters {
+			continue
+		}
+		if after.meters == before.meters {
+			return after.timestamp, true
+		}
+		fraction := (meters - before.meters) / (after.meters - before.meters)
+		return before.timestamp + uint64(fraction*float64(after.timestamp-before.timestamp)), true
+	}
+	return 0, false
+}
+
+// Headways returns the latest headways of the vehicles on a route seen within
+// the gap threshold, from the front of the route to the back
+func (m *HeadwayMonitor) Headways(routeID string) []types.VehicleHeadway {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	headways := []types.VehicleHeadway{}
+	for _, vehicle := range m.vehicles[routeID] {
+		if time.Since(vehicle.seenAt) > m.config.GapThreshold {
+			continue
+		}
+		headways = append(headways, vehicle.headway)
+	}
+	sort.Slice(headways, func(i, j int) bool {
+		return headways[i].ProgressMeters > headways[j].ProgressMeters
+	})
+	return headways
+}
+
+// Reset stops following a finished route, so it is no longer the leader of
+// the vehicles behind it
+func (m *HeadwayMonitor) Reset(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	routeID, ok := m.routeIDs[key]
+	if !ok {
+		return
+	}
+	delete(m.routeIDs, key)
+	delete(m.vehicles[routeID], key)
+	if len(m.vehicles[routeID]) == 0 {
+		delete(m.vehicles, routeID)
+	}
+}
+
+// RouteHeadways returns the live headways of the vehicles on a route, from
+// the front of the route to the back
+func (s *DataIngestionService) RouteHeadways(ctx context.Context, routeID string) ([]types.VehicleHeadway, error) {
+	if s.headways == nil {
+		return nil, ErrHeadwaysDisabled
+	}
+	return s.headways.Headways(routeID), nil
+}
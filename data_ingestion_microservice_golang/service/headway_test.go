@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHeadwayMonitor_ReportsBunchingAndGaps(t *testing.T) {
+	broker := &recordingBroker{}
+	monitor := NewHeadwayMonitor(types.HeadwayConfig{BunchingThreshold: 2 * time.Minute, GapThreshold: 10 * time.Minute, EventTopic: "events/headways"},
+		memoryPlannedRoutes{"route-1": etaTestRoute}, broker)
+
+	check := func(driverID string, longitude float64, seconds uint64) *types.HeadwayEvent {
+		t.Helper()
+		event, err := monitor.Check(context.Background(), database.RouteKey(driverID, "route-1"), types.BusMessage{
+			DriverID:       driverID,
+			CurrentRouteID: "route-1",
+			DriverLocation: types.Location{Latitude: 0, Longitude: longitude},
+			Timestamp:      1640995200000 + seconds*1000,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return event
+	}
+
+	check("driver-1", 0, 0)
+	check("driver-1", 0.005, 60)
+	check("driver-1", 0.010, 120)
+
+	// The follower is where the leader was a minute ago
+	event := check("driver-2", 0.005, 120)
+	if event == nil || event.Type != EventBunching || event.LeaderDriverID != "driver-1" || math.Abs(event.HeadwaySeconds-60) > 0.01 || event.ThresholdSeconds != 120 {
+		t.Fatalf("Expected a bunching event 60s behind driver-1, got %+v", event)
+	}
+	if event := check("driver-2", 0.005, 130); event != nil {
+		t.Errorf("Expected bunching reported once, got %+v", event)
+	}
+
+	// The leader pulls away into a normal headway, then a gap
+	check("driver-1", 0.040, 600)
+	if event := check("driver-2", 0.006, 660); event != nil {
+		t.Errorf("Expected no event for a normal headway, got %+v", event)
+	}
+	event = check("driver-2", 0.007, 900)
+	if event == nil || event.Type != EventHeadwayGap || math.Abs(event.HeadwaySeconds-816) > 0.01 || event.ThresholdSeconds != 600 {
+		t.Fatalf("Expected a gap event 816s behind driver-1, got %+v", event)
+	}
+	if published := broker.published["events/headways/route-1"]; len(published) != 2 {
+		t.Errorf("Expected 2 headway events published, got %d", len(published))
+	}
+
+	headways := monitor.Headways("route-1")
+	if len(headways) != 2 || headways[0].DriverID != "driver-1" || headways[0].Status != types.HeadwayLeading || headways[0].HeadwaySeconds != nil {
+		t.Fatalf("Expected driver-1 leading the route, got %+v", headways)
+	}
+	if follower := headways[1]; follower.Status != types.HeadwayGap || follower.LeaderDriverID != "driver-1" || math.Abs(follower.DistanceMeters-3669) > 5 {
+		t.Errorf("Expected driver-2 in a gap about 3.7km behind driver-1, got %+v", follower)
+	}
+
+	// Finished vehicles no longer lead the vehicles behind them
+	monitor.Reset(database.RouteKey("driver-1", "route-1"))
+	check("driver-2", 0.008, 910)
+	if headways := monitor.Headways("route-1"); len(headways) != 1 || headways[0].Status != types.HeadwayLeading {
+		t.Errorf("Expected driver-2 leading once driver-1 finished, got %+v", headways)
+	}
+}
+
+func TestHeadwayMonitor_UnknownUntilTheLeaderWasFollowed(t *testing.T) {
+	monitor := NewHeadwayMonitor(types.HeadwayConfig{BunchingThreshold: 2 * time.Minute, GapThreshold: 10 * time.Minute, EventTopic: "events/headways"},
+		memoryPlannedRoutes{"route-1": etaTestRoute}, &recordingBroker{})
+
+	for _, msg := range []types.BusMessage{
+		{DriverID: "driver-1", CurrentRouteID: "route-1", DriverLocation: types.Location{Longitude: 0.020}, Timestamp: 1640995200000},
+		{DriverID: "driver-2", CurrentRouteID: "route-1", DriverLocation: types.Location{Longitude: 0.010}, Timestamp: 1640995260000},
+	} {
+		if event, err := monitor.Check(context.Background(), database.RouteKey(msg.DriverID, msg.CurrentRouteID), msg); err != nil || event != nil {
+			t.Fatalf("Expected no event, got %+v (%v)", event, err)
+		}
+	}
+	headways := monitor.Headways("route-1")
+	if len(headways) != 2 || headways[1].Status != types.HeadwayUnknown || headways[1].HeadwaySeconds != nil || headways[1].LeaderDriverID != "driver-1" {
+		t.Errorf("Expected an unknown headway behind a leader first seen ahead, got %+v", headways)
+	}
+}
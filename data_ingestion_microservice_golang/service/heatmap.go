@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// heatmapPageSize is how many trips are counted per page of the trip store
+const heatmapPageSize = 500
+
+// ErrHeatmapDisabled is returned for heatmap reads when the heatmap
+// aggregation is disabled
+var ErrHeatmapDisabled = errors.New("heatmap aggregation is disabled")
+
+// runHeatmapAggregation counts the points of recent trips in the heatmap
+// cells every heatmap interval until ctx is done. With partitioning, only the
+// cluster leader counts them.
+func (s *DataIngestionService) runHeatmapAggregation(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(s.config.Heatmap.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !s.leads() {
+				continue
+			}
+			if err := s.aggregateHeatmap(ctx, now); err != nil && ctx.Err() == nil {
+				slog.Error("Error aggregating heatmap", "error", err)
+			}
+		}
+	}
+}
+
+// aggregateHeatmap recounts the days of the lookback window, and the weeks
+// they fall in from their days
+func (s *DataIngestionService) aggregateHeatmap(ctx context.Context, now time.Time) error {
+	today := startOfDay(now.In(s.heatmapLocation))
+	var weeks []time.Time
+	for i := s.config.Heatmap.LookbackDays - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		if err := s.aggregateHeatmapDay(ctx, day, now); err != nil {
+			return err
+		}
+		if week := startOfWeek(day); !slices.ContainsFunc(weeks, week.Equal) {
+			weeks = append(weeks, week)
+		}
+	}
+	for _, week := range weeks {
+		if err := s.aggregateHeatmapWeek(ctx, week, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateHeatmapDay counts the points of the trips that ended on a day.
+// The raw points are counted when they are stored, and the simplified route
+// otherwise.
+func (s *DataIngestionService) aggregateHeatmapDay(ctx context.Context, day, now time.Time) error {
+	counts := make(map[string]int64)
+	query := types.TripQuery{
+		From:  day.UnixMilli(),
+		To:    day.AddDate(0, 0, 1).UnixMilli(),
+		Limit: heatmapPageSize,
+	}
+	trips := 0
+	for {
+		page, err := s.backends.TripQueries.FindTrips(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to read trips of %s: %w", day.Format(time.DateOnly), err)
+		}
+		for _, trip := range page.Trips {
+			locations, err := s.heatmapLocations(ctx, trip)
+			if err != nil {
+				return err
+			}
+			for _, location := range locations {
+				counts[algorithm.EncodeGeohash(location, s.config.Heatmap.Precision)]++
+			}
+		}
+		trips += len(page.Trips)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	start := day.Format(time.DateOnly)
+	err := s.mongoCall(ctx, "mongo.heatmap", func() error {
+		return s.backends.Heatmap.SaveHeatmapCells(ctx, types.HeatmapPeriodDay, start, heatmapCells(types.HeatmapPeriodDay, start, counts, now))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save heatmap of %s: %w", start, err)
+	}
+	slog.Debug("Aggregated heatmap", "day", start, "trips", trips, "cells", len(counts))
+	return nil
+}
+
+// heatmapLocations returns the points of a trip counted in the heatmap
+func (s *DataIngestionService) heatmapLocations(ctx context.Context, trip types.StoredTrip) ([]types.Location, error) {
+	if s.config.RawRoutes.Enabled {
+		raw, err := s.backends.TripQueries.RawPoints(ctx, trip.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read raw points of trip %s: %w", trip.ID, err)
+		}
+		if len(raw) > 0 {
+			locations := make([]types.Location, len(raw))
+			for i, point := range raw {
+				locations[i] = point.Location
+			}
+			return locations, nil
+		}
+	}
+	return types.RouteLocations(trip.SimplifiedRoute), nil
+}
+
+// aggregateHeatmapWeek adds up the stored days of the week starting on week
+func (s *DataIngestionService) aggregateHeatmapWeek(ctx context.Context, week, now time.Time) error {
+	counts := make(map[string]int64)
+	for i := 0; i < 7; i++ {
+		day := week.AddDate(0, 0, i).Format(time.DateOnly)
+		var cells []types.HeatmapCell
+		err := s.mongoCall(ctx, "mongo.heatmap", func() (err error) {
+			cells, err = s.backends.Heatmap.FindHeatmapCells(ctx, types.HeatmapPeriodDay, day)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read heatmap of %s: %w", day, err)
+		}
+		for _, cell := range cells {
+			counts[cell.Geohash] += cell.Points
+		}
+	}
+
+	start := week.Format(time.DateOnly)
+	err := s.mongoCall(ctx, "mongo.heatmap", func() error {
+		return s.backends.Heatmap.SaveHeatmapCells(ctx, types.HeatmapPeriodWeek, start, heatmapCells(types.HeatmapPeriodWeek, start, counts, now))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save heatmap of the week of %s: %w", start, err)
+	}
+	return nil
+}
+
+// heatmapCells turns the counts of a day or week into cells
+func heatmapCells(period, start string, counts map[string]int64, now time.Time) []types.HeatmapCell {
+	cells := make([]types.HeatmapCell, 0, len(counts))
+	for geohash, points := range counts {
+		cells = append(cells, types.HeatmapCell{
+			ID:        database.HeatmapCellID(period, start, geohash),
+			Period:    period,
+			Start:     start,
+			Geohash:   geohash,
+			Points:    points,
+			UpdatedAt: now.UTC(),
+		})
+	}
+	return cells
+}
+
+// Heatmap returns the cells of the day or week containing the query date,
+// today by default, within the query's bounding box. Cells are merged into
+// the query precision when it is coarser than the stored one.
+func (s *DataIngestionService) Heatmap(ctx context.Context, query types.HeatmapQuery) (types.HeatmapReport, error) {
+	if s.heatmapLocation == nil {
+		return types.HeatmapReport{}, ErrHeatmapDisabled
+	}
+
+	date := startOfDay(time.Now().In(s.heatmapLocation))
+	if query.Date != "" {
+		var err error
+		if date, err = time.ParseInLocation(time.DateOnly, query.Date, s.heatmapLocation); err != nil {
+			return types.HeatmapReport{}, fmt.Errorf("invalid heatmap date %q: %w", query.Date, err)
+		}
+	}
+	if query.Period == "" {
+		query.Period = types.HeatmapPeriodDay
+	}
+	if query.Period == types.HeatmapPeriodWeek {
+		date = startOfWeek(date)
+	}
+	precision := s.config.Heatmap.Precision
+	if query.Precision > 0 && query.Precision < precision {
+		precision = query.Precision
+	}
+
+	report := types.HeatmapReport{Period: query.Period, Start: date.Format(time.DateOnly), Precision: precision}
+	var stored []types.HeatmapCell
+	err := s.mongoCall(ctx, "mongo.heatmap", func() (err error) {
+		stored, err = s.backends.Heatmap.FindHeatmapCells(ctx, report.Period, report.Start)
+		return err
+	})
+	if err != nil {
+		return types.HeatmapReport{}, err
+	}
+
+	merged := make(map[string]*types.HeatmapCell)
+	for _, cell := range stored {
+		geohash := cell.Geohash[:min(precision, len(cell.Geohash))]
+		if existing, ok := merged[geohash]; ok {
+			existing.Points += cell.Points
+			if cell.UpdatedAt.After(existing.UpdatedAt) {
+				existing.UpdatedAt = cell.UpdatedAt
+			}
+			continue
+		}
+		cell.Geohash = geohash
+		merged[geohash] = &cell
+	}
+
+	report.Cells = make([]types.HeatmapCell, 0, len(merged))
+	for _, cell := range merged {
+		center, ok := algorithm.GeohashCenter(cell.Geohash)
+		if !ok || (query.BoundingBox != nil && !algorithm.Contains(*query.BoundingBox, center)) {
+			continue
+		}
+		cell.Latitude, cell.Longitude = center.Latitude, center.Longitude
+		report.Cells = append(report.Cells, *cell)
+		report.MaxPoints = max(report.MaxPoints, cell.Points)
+	}
+	slices.SortFunc(report.Cells, func(a, b types.HeatmapCell) int { return strings.Compare(a.Geohash, b.Geohash) })
+	return report, nil
+}
+
+// startOfDay returns midnight of an instant's day in its location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns midnight of the Monday starting an instant's week in
+// its location
+func startOfWeek(t time.Time) time.Time {
+	return startOfDay(t).AddDate(0, 0, -(int(t.Weekday())+6)%7)
+}
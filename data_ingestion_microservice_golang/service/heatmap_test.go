@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestStartOfWeek(t *testing.T) {
+	sunday := time.Date(2022, 1, 9, 23, 30, 0, 0, time.UTC)
+	if got := startOfWeek(sunday); !got.Equal(time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Monday 2022-01-03, got %v", got)
+	}
+	monday := time.Date(2022, 1, 3, 8, 0, 0, 0, time.UTC)
+	if got := startOfWeek(monday); !got.Equal(time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Monday 2022-01-03, got %v", got)
+	}
+}
+
+func TestHeatmap_AggregatesTripPoints(t *testing.T) {
+	store, err := database.NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), database.CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ctx := context.Background()
+	medellin := types.Location{Latitude: 6.2442, Longitude: -75.5812}
+	bogota := types.Location{Latitude: 4.7110, Longitude: -74.0721}
+	trips := []types.Trip{
+		// Tuesday 2022-01-04 and Wednesday 2022-01-05
+		{ID: "trip-1", DriverID: "driver-1", CurrentRouteID: "route-1", Timestamp: 1641290400000,
+			SimplifiedRoute: []types.RoutePoint{{Location: medellin}, {Location: medellin}, {Location: bogota}}},
+		{ID: "trip-2", DriverID: "driver-2", CurrentRouteID: "route-2", Timestamp: 1641376800000,
+			SimplifiedRoute: []types.RoutePoint{{Location: medellin}}},
+	}
+	for _, trip := range trips {
+		if err := store.SaveTrip(ctx, database.RouteKey(trip.DriverID, trip.CurrentRouteID), trip); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	service := newTestService(t, newMemoryBackend(), func(cfg *types.Config, backends *database.Backends) {
+		cfg.Heatmap.Enabled = true
+		cfg.Heatmap.LookbackDays = 7
+		backends.TripQueries = store
+		backends.Heatmap = store
+	})
+
+	// Thursday 2022-01-06
+	if err := service.aggregateHeatmap(ctx, time.Date(2022, 1, 6, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	day, err := service.Heatmap(ctx, types.HeatmapQuery{Period: types.HeatmapPeriodDay, Date: "2022-01-04"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(day.Cells) != 2 || day.MaxPoints != 2 {
+		t.Errorf("Expected a cell in each city with 2 points in Medellín, got %+v", day)
+	}
+
+	week, err := service.Heatmap(ctx, types.HeatmapQuery{
+		Period:      types.HeatmapPeriodWeek,
+		Date:        "2022-01-06",
+		BoundingBox: &types.BoundingBox{MinLon: -76, MinLat: 6, MaxLon: -75, MaxLat: 7},
+		Precision:   5,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if week.Start != "2022-01-03" || week.Precision != 5 {
+		t.Errorf("Expected the week of 2022-01-03 at precision 5, got %+v", week)
+	}
+	if len(week.Cells) != 1 || week.Cells[0].Points != 3 || week.Cells[0].Geohash != algorithm.EncodeGeohash(medellin, 5) {
+		t.Errorf("Expected the Medellín cell with the points of both days, got %+v", week.Cells)
+	}
+}
+
+func TestHeatmap_Disabled(t *testing.T) {
+	service := newTestService(t, newMemoryBackend())
+	if _, err := service.Heatmap(context.Background(), types.HeatmapQuery{}); err != ErrHeatmapDisabled {
+		t.Errorf("Expected ErrHeatmapDisabled, got %v", err)
+	}
+}
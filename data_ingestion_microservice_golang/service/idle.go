@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// IdleAnalyzer finds the periods finished trips spent idling away from their
+// terminals: the first and last stops of the planned route, when there is
+// one, and the terminal geofences
+type IdleAnalyzer struct {
+	config       types.IdleConfig
+	idleSpeedKmh float64
+	routes       *plannedRouteCache // nil without planned routes
+	geofences    *GeofenceEngine    // nil without geofences
+}
+
+// NewIdleAnalyzer creates an idle analyzer. Vehicles idle below the trip
+// stats idle speed.
+func NewIdleAnalyzer(config types.IdleConfig, stats types.TripStatsConfig, routes database.PlannedRouteStore, geofences *GeofenceEngine) *IdleAnalyzer {
+	analyzer := &IdleAnalyzer{config: config, idleSpeedKmh: stats.IdleSpeedKmh, geofences: geofences}
+	if routes != nil {
+		analyzer.routes = newPlannedRouteCache(routes)
+	}
+	return analyzer
+}
+
+// Analyze returns the idle periods of a trip's raw points
+func (a *IdleAnalyzer) Analyze(ctx context.Context, routeID string, points []types.TrackPoint) (*types.TripIdle, error) {
+	var terminals []types.Location
+	if a.routes != nil {
+		route, err := a.routes.get(ctx, routeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read planned route %s: %w", routeID, err)
+		}
+		if route != nil && len(route.Stops) > 0 {
+			terminals = append(terminals, route.Stops[0].Location, route.Stops[len(route.Stops)-1].Location)
+		}
+	}
+
+	atTerminal := func(location types.Location) bool {
+		for _, terminal := range terminals {
+			if algorithm.HaversineDistance(location, terminal) <= a.config.TerminalRadiusMeters {
+				return true
+			}
+		}
+		return a.geofences != nil && a.geofences.within(location, a.config.TerminalGeofences)
+	}
+
+	idle := algorithm.DetectIdling(points, a.idleSpeedKmh, a.config.MinDuration.Seconds(), atTerminal)
+	return &idle, nil
+}
+
+// within reports whether a location lies inside any of the geofences with
+// the given IDs
+func (g *GeofenceEngine) within(location types.Location, ids []string) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	g.mu.Lock()
+	index := g.index
+	g.mu.Unlock()
+
+	return slices.ContainsFunc(index.containing(location), func(id string) bool {
+		return slices.Contains(ids, id)
+	})
+}
+
+// IdleReport sums the idle time of stored trips per day, driver, or route
+func (s *DataIngestionService) IdleReport(ctx context.Context, query types.TripStatsQuery) (types.IdleReport, error) {
+	if s.backends.TripQueries == nil {
+		return types.IdleReport{}, ErrTripQueriesUnsupported
+	}
+	return s.backends.TripQueries.AggregateIdle(ctx, query)
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_StoresIdlePeriods(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Idle.Enabled = true
+		cfg.Idle.TerminalGeofences = []string{"garage"}
+		cfg.Geofence.Enabled = true
+		backends.PlannedRoutes = memoryPlannedRoutes{"route-1": etaTestRoute}
+		backends.Geofences = memoryGeofences{
+			{ID: "garage", Center: &types.Location{Latitude: 0, Longitude: 0.04}, RadiusMeters: 100},
+		}
+	})
+
+	// Waiting at the first stop, idling on the way, then waiting in the garage
+	positions := []struct {
+		seconds   uint64
+		longitude float64
+	}{
+		{0, 0.009}, {300, 0.009},
+		{360, 0.015}, {600, 0.015},
+		{660, 0.04}, {960, 0.04},
+		{1020, 0.045},
+	}
+	for _, position := range positions {
+		message := fmt.Sprintf(`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":%d,"driverLocation":{"latitude":0,"longitude":%v}}`,
+			1640995200000+position.seconds*1000, position.longitude)
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640996300000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		idle := trip.Idle
+		if idle == nil || len(idle.Periods) != 1 || idle.IdleSeconds != 240 {
+			t.Fatalf("Expected only the 4 minutes on the way to count, got %+v", idle)
+		}
+		if period := idle.Periods[0]; period.Location.Longitude != 0.015 || period.StartTimestamp != 1640995560000 {
+			t.Errorf("Unexpected idle period %+v", period)
+		}
+	}
+}
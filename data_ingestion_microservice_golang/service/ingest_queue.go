@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Overflow policies of the ingest queue
+const (
+	// OverflowBlock stops reading from the broker until the queue has room,
+	// leaving the broker's QoS flow control to hold back publishers
+	OverflowBlock = "block"
+	// OverflowDropOldest discards the oldest queued message to make room
+	OverflowDropOldest = "drop_oldest"
+	// OverflowSpill writes messages to a local disk buffer and feeds them
+	// back in order as the queue drains
+	OverflowSpill = "spill"
+)
+
+// spillRetryInterval is how long the spill drainer waits after a disk error
+const spillRetryInterval = time.Second
+
+// Messages retried through the spill buffer wait minRetryDelay before they
+// are fed back, doubling for every retry of the same driver in a row up to
+// maxRetryDelay
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// retryBackoff is when the spilled messages of a driver are due again, and
+// the delay that led there
+type retryBackoff struct {
+	until time.Time
+	delay time.Duration
+}
+
+// ingestJob is an MQTT message waiting to be processed
+type ingestJob struct {
+	topic   string
+	payload []byte
+	// shard is the worker queue the message is kept in order on
+	shard int
+	// ack acknowledges the message to the broker
+	ack func()
+}
+
+// noAck stands in for the acknowledgment of messages that need none, like
+// the ones fed back from the spill buffer
+func noAck() {}
+
+// IngestQueue processes MQTT messages on a bounded set of workers, applying
+// its overflow policy when more messages arrive than the workers keep up with.
+// Messages are sharded between the workers by driver, and every worker has
+// its own queue, so the messages of a driver are processed one at a time in
+// arrival order while different drivers are processed in parallel.
+type IngestQueue struct {
+	policy  string
+	shards  []chan ingestJob
+	spill   *database.SpillBuffer
+	handler func(topic string, payload []byte, ack func())
+	ready   func() bool
+
+	// backoffs holds the drivers whose spilled messages wait to be retried
+	backoffMu     sync.Mutex
+	backoffs      map[string]retryBackoff
+	minRetryDelay time.Duration
+	maxRetryDelay time.Duration
+
+	// mu guards closed; submissions hold it shared so the queue cannot be
+	// closed under them
+	mu     sync.RWMutex
+	closed bool
+
+	spilled chan struct{}
+	stop    chan struct{}
+	drained sync.WaitGroup
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewIngestQueue creates an ingest queue and starts its workers, splitting
+// the queue size evenly between them. The handler acknowledges each message
+// once it is safely processed. With the spill policy, messages left on disk
+// by a previous run are processed first, and spilled messages are only fed
+// back while the optional ready function returns true.
+func NewIngestQueue(config types.IngestConfig, handler func(topic string, payload []byte, ack func()), ready func() bool) (*IngestQueue, error) {
+	workers := max(config.Workers, 1)
+	queueSize := max(config.QueueSize, 0)
+
+	queue := &IngestQueue{
+		policy:  config.Overflow,
+		shards:  make([]chan ingestJob, workers),
+		handler: handler,
+		ready:   ready,
+		spilled: make(chan struct{}, 1),
+
+		backoffs:      make(map[string]retryBackoff),
+		minRetryDelay: minRetryDelay,
+		maxRetryDelay: maxRetryDelay,
+		stop:          make(chan struct{}),
+	}
+	// A nonzero queue size leaves every worker room for at least one message
+	shardSize := queueSize / workers
+	if queueSize > 0 {
+		shardSize = max(shardSize, 1)
+	}
+	for i := range queue.shards {
+		queue.shards[i] = make(chan ingestJob, shardSize)
+	}
+
+	switch config.Overflow {
+	case OverflowBlock, OverflowDropOldest:
+	case OverflowSpill:
+		spill, err := database.OpenSpillBuffer(config.SpillPath, config.SpillMaxMessages)
+		if err != nil {
+			return nil, err
+		}
+		queue.spill = spill
+		metrics.IngestSpillBacklog.Set(spill.Len())
+		if spill.Len() > 0 {
+			slog.Info("Processing messages spilled before the last shutdown", "messages", spill.Len())
+			queue.spilled <- struct{}{}
+		}
+		queue.drained.Add(1)
+		go queue.drainSpill()
+	default:
+		return nil, fmt.Errorf("unknown ingest overflow policy %q", config.Overflow)
+	}
+	metrics.IngestQueueSize.Set(int64(queueSize))
+
+	for _, shard := range queue.shards {
+		queue.wg.Add(1)
+		go queue.worker(shard)
+	}
+	return queue, nil
+}
+
+// Submit queues a message, applying the overflow policy when the queue is
+// full. The message is acknowledged once processed, dropped, or spilled to
+// disk; messages submitted after Stop are discarded unacknowledged so the
+// broker redelivers them.
+func (q *IngestQueue) Submit(topic string, payload []byte, ack func()) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		slog.Warn("Discarding message received during shutdown", "topic", topic)
+		return
+	}
+
+	if ack == nil {
+		ack = noAck
+	}
+	job := q.newJob(topic, payload, ack)
+	switch q.policy {
+	case OverflowDropOldest:
+		q.submitDroppingOldest(job)
+	case OverflowSpill:
+		q.submitSpilling(job)
+	default:
+		q.enqueue(job)
+	}
+}
+
+// newJob creates the job of a message, assigned to the shard of its driver
+func (q *IngestQueue) newJob(topic string, payload []byte, ack func()) ingestJob {
+	hash := fnv.New32a()
+	hash.Write([]byte(orderingKey(topic, payload)))
+	return ingestJob{topic: topic, payload: payload, shard: int(hash.Sum32() % uint32(len(q.shards))), ack: ack}
+}
+
+// orderingKey returns the key messages are kept in order by: the driver ID,
+// or the topic for payloads without one
+func orderingKey(topic string, payload []byte) string {
+	var message struct {
+		DriverID string `json:"driverId"`
+	}
+	if json.Unmarshal(payload, &message) == nil && message.DriverID != "" {
+		return message.DriverID
+	}
+	return topic
+}
+
+// enqueue queues a message, blocking while its shard is full
+func (q *IngestQueue) enqueue(job ingestJob) {
+	metrics.IngestInFlight.Add(1)
+	metrics.IngestQueueDepth.Add(1)
+	q.shards[job.shard] <- job
+}
+
+// submitDroppingOldest queues a message, discarding the oldest messages
+// queued on its shard until there is room for it
+func (q *IngestQueue) submitDroppingOldest(job ingestJob) {
+	metrics.IngestInFlight.Add(1)
+	metrics.IngestQueueDepth.Add(1)
+	shard := q.shards[job.shard]
+	for {
+		select {
+		case shard <- job:
+			return
+		default:
+		}
+
+		select {
+		case dropped := <-shard:
+			metrics.IngestInFlight.Add(-1)
+			metrics.IngestQueueDepth.Add(-1)
+			metrics.IngestDropped.Add(1)
+			slog.Debug("Dropped oldest queued message", "topic", dropped.topic)
+			dropped.ack()
+		default:
+		}
+	}
+}
+
+// submitSpilling queues a message if its shard has room and nothing is
+// waiting on disk, and spills it otherwise so messages keep their order.
+// When the spill buffer is full or fails, it falls back to blocking.
+func (q *IngestQueue) submitSpilling(job ingestJob) {
+	if q.spill.Len() == 0 {
+		select {
+		case q.shards[job.shard] <- job:
+			metrics.IngestInFlight.Add(1)
+			metrics.IngestQueueDepth.Add(1)
+			return
+		default:
+		}
+	}
+
+	if err := q.pushSpill(job); err != nil {
+		slog.Warn("Cannot spill message, waiting for room in the ingest queue", "topic", job.topic, "error", err)
+		q.enqueue(job)
+	}
+}
+
+// Requeue spills a message taken from the queue back to disk, to be fed
+// again once the queue is ready, and acknowledges it. It reports false if the
+// queue does not spill or the message cannot be spilled.
+func (q *IngestQueue) Requeue(topic string, payload []byte, ack func()) bool {
+	if q.spill == nil {
+		return false
+	}
+	if err := q.pushSpill(q.newJob(topic, payload, ack)); err != nil {
+		slog.Warn("Cannot spill message back to disk", "topic", topic, "error", err)
+		return false
+	}
+	return true
+}
+
+// Retry spills a message that failed on a backend after its retries back to
+// disk and acknowledges it, so it does not hold a slot of the broker's
+// in-flight window while the backend is down. The messages of its driver are
+// fed back after a delay that grows with every retry in a row. It reports
+// false if the queue does not spill or the message cannot be spilled.
+func (q *IngestQueue) Retry(topic string, payload []byte, ack func()) bool {
+	if q.spill == nil {
+		return false
+	}
+	q.backoff(orderingKey(topic, payload), time.Now())
+	if !q.Requeue(topic, payload, ack) {
+		return false
+	}
+	metrics.IngestRetried.Add(1)
+	return true
+}
+
+// backoff delays the spilled messages of a key, doubling the delay when the
+// key is retried again within the maximum delay of its last retry
+func (q *IngestQueue) backoff(key string, now time.Time) {
+	q.backoffMu.Lock()
+	defer q.backoffMu.Unlock()
+
+	for other, backoff := range q.backoffs {
+		if now.Sub(backoff.until) > q.maxRetryDelay {
+			delete(q.backoffs, other)
+		}
+	}
+	delay := q.minRetryDelay
+	if previous, ok := q.backoffs[key]; ok {
+		delay = min(2*previous.delay, q.maxRetryDelay)
+	}
+	q.backoffs[key] = retryBackoff{until: now.Add(delay), delay: delay}
+}
+
+// due reports whether a spilled message may be fed back, which it may
+// unless its driver waits for a retry
+func (q *IngestQueue) due(message database.SpilledMessage) bool {
+	q.backoffMu.Lock()
+	defer q.backoffMu.Unlock()
+	backoff, ok := q.backoffs[orderingKey(message.Topic, message.Payload)]
+	return !ok || !time.Now().Before(backoff.until)
+}
+
+// nextRetry returns how long until the earliest waiting driver is due
+func (q *IngestQueue) nextRetry() time.Duration {
+	q.backoffMu.Lock()
+	defer q.backoffMu.Unlock()
+	wait := q.maxRetryDelay
+	for _, backoff := range q.backoffs {
+		wait = min(wait, time.Until(backoff.until))
+	}
+	return max(wait, 0)
+}
+
+// pushSpill appends a message to the spill buffer, acknowledges it now that
+// it is on disk, and wakes the drainer
+func (q *IngestQueue) pushSpill(job ingestJob) error {
+	if err := q.spill.Push(job.topic, job.payload); err != nil {
+		return err
+	}
+	if job.ack != nil {
+		job.ack()
+	}
+	metrics.IngestSpilled.Add(1)
+	metrics.IngestSpillBacklog.Set(q.spill.Len())
+
+	select {
+	case q.spilled <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// drainSpill feeds spilled messages back into the queue, oldest first,
+// skipping the drivers that wait to be retried, until the queue is stopped. Messages still on disk are kept for the next
+// start.
+func (q *IngestQueue) drainSpill() {
+	defer q.drained.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-q.spilled:
+		}
+
+		for q.spill.Len() > 0 {
+			// Hold spilled messages back until the queue is ready for them
+			if q.ready != nil && !q.ready() {
+				select {
+				case <-q.stop:
+					return
+				case <-time.After(spillRetryInterval):
+					continue
+				}
+			}
+
+			message, ok, err := q.spill.OldestWhere(q.due)
+			if err != nil {
+				slog.Error("Error reading spilled message", "error", err)
+				select {
+				case <-q.stop:
+					return
+				case <-time.After(spillRetryInterval):
+					continue
+				}
+			}
+			if !ok {
+				// Every spilled message waits for its driver's retry
+				select {
+				case <-q.stop:
+					return
+				case <-q.spilled:
+				case <-time.After(q.nextRetry()):
+				}
+				continue
+			}
+
+			job := q.newJob(message.Topic, message.Payload, noAck)
+			select {
+			case <-q.stop:
+				return
+			case q.shards[job.shard] <- job:
+				metrics.IngestInFlight.Add(1)
+				metrics.IngestQueueDepth.Add(1)
+			}
+			if err := q.spill.Remove(message); err != nil {
+				// The message would be fed again, so give the disk time to recover
+				slog.Error("Error removing spilled message", "error", err)
+				select {
+				case <-q.stop:
+					return
+				case <-time.After(spillRetryInterval):
+				}
+			}
+			metrics.IngestSpillBacklog.Set(q.spill.Len())
+		}
+	}
+}
+
+// QueueDepth returns the number of messages waiting for a worker
+func (q *IngestQueue) QueueDepth() int {
+	var depth int
+	for _, shard := range q.shards {
+		depth += len(shard)
+	}
+	return depth
+}
+
+// Stop stops accepting messages and waits for queued messages to be
+// processed. Spilled messages stay on disk.
+func (q *IngestQueue) Stop() {
+	q.Drain(context.Background())
+}
+
+// Drain stops accepting messages and waits for queued messages to be
+// processed or for ctx to be done, whichever comes first. Spilled messages
+// stay on disk, and so do the messages still queued when ctx is done if the
+// queue spills; otherwise those are left unacknowledged for the broker to
+// redeliver.
+func (q *IngestQueue) Drain(ctx context.Context) error {
+	var err error
+	q.once.Do(func() {
+		close(q.stop)
+		q.drained.Wait()
+
+		q.mu.Lock()
+		q.closed = true
+		for _, shard := range q.shards {
+			close(shard)
+		}
+		q.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			q.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+			q.abandonQueued()
+		}
+
+		if q.spill != nil {
+			if err := q.spill.Close(); err != nil {
+				slog.Warn("Error closing spill buffer", "error", err)
+			}
+		}
+	})
+	return err
+}
+
+// abandonQueued takes the messages no worker has started yet off the closed
+// queue, keeping them in the spill buffer when there is one
+func (q *IngestQueue) abandonQueued() {
+	var spilled, unacknowledged int
+	for _, shard := range q.shards {
+		for job := range shard {
+			metrics.IngestQueueDepth.Add(-1)
+			metrics.IngestInFlight.Add(-1)
+			if q.spill != nil && q.pushSpill(job) == nil {
+				spilled++
+			} else {
+				unacknowledged++
+			}
+		}
+	}
+	slog.Warn("Ingest queue did not drain in time", "spilled", spilled, "unacknowledged", unacknowledged)
+}
+
+// worker processes the messages queued on its shard until the queue is
+// stopped
+func (q *IngestQueue) worker(shard chan ingestJob) {
+	defer q.wg.Done()
+
+	for job := range shard {
+		metrics.IngestQueueDepth.Add(-1)
+		q.handler(job.topic, job.payload, job.ack)
+		metrics.IngestInFlight.Add(-1)
+	}
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// blockingHandler records processed payloads, holding the first one until
+// released
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu        sync.Mutex
+	processed []string
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) handle(topic string, payload []byte, ack func()) {
+	defer ack()
+
+	h.mu.Lock()
+	first := len(h.processed) == 0
+	h.processed = append(h.processed, string(payload))
+	h.mu.Unlock()
+
+	if first {
+		close(h.started)
+		<-h.release
+	}
+}
+
+func (h *blockingHandler) payloads() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.processed...)
+}
+
+func assertPayloads(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestIngestQueue_DropOldest(t *testing.T) {
+	handler := newBlockingHandler()
+	queue, err := NewIngestQueue(types.IngestConfig{Workers: 1, QueueSize: 1, Overflow: OverflowDropOldest}, handler.handle, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	dropped := metrics.IngestDropped.Value()
+
+	queue.Submit("t", []byte("a"), nil)
+	<-handler.started
+	queue.Submit("t", []byte("b"), nil)
+	queue.Submit("t", []byte("c"), nil)
+
+	close(handler.release)
+	queue.Stop()
+
+	assertPayloads(t, handler.payloads(), "a", "c")
+	if got := metrics.IngestDropped.Value() - dropped; got != 1 {
+		t.Errorf("Expected 1 dropped message, got %d", got)
+	}
+}
+
+func TestIngestQueue_AcknowledgesHandledMessages(t *testing.T) {
+	handler := newBlockingHandler()
+	queue, err := NewIngestQueue(types.IngestConfig{Workers: 1, QueueSize: 1, Overflow: OverflowDropOldest}, handler.handle, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var acked sync.Map
+	ack := func(payload string) func() {
+		return func() { acked.Store(payload, true) }
+	}
+
+	queue.Submit("t", []byte("a"), ack("a"))
+	<-handler.started
+	queue.Submit("t", []byte("b"), ack("b"))
+	queue.Submit("t", []byte("c"), ack("c"))
+
+	// The dropped message is acknowledged, the running one only once handled
+	if _, ok := acked.Load("b"); !ok {
+		t.Error("Expected the dropped message to be acknowledged")
+	}
+	if _, ok := acked.Load("a"); ok {
+		t.Error("Expected the message to be acknowledged after it is handled")
+	}
+
+	close(handler.release)
+	queue.Stop()
+	for _, payload := range []string{"a", "c"} {
+		if _, ok := acked.Load(payload); !ok {
+			t.Errorf("Expected %q to be acknowledged", payload)
+		}
+	}
+
+	// Messages discarded during shutdown are left for the broker to redeliver
+	queue.Submit("t", []byte("d"), ack("d"))
+	if _, ok := acked.Load("d"); ok {
+		t.Error("Expected the discarded message not to be acknowledged")
+	}
+}
+
+func TestIngestQueue_SpillKeepsOrder(t *testing.T) {
+	handler := newBlockingHandler()
+	config := types.IngestConfig{Workers: 1, QueueSize: 1, Overflow: OverflowSpill, SpillPath: filepath.Join(t.TempDir(), "spill.db")}
+	queue, err := NewIngestQueue(config, handler.handle, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer queue.Stop()
+
+	queue.Submit("t", []byte("a"), nil)
+	<-handler.started
+	for _, payload := range []string{"b", "c", "d"} {
+		queue.Submit("t", []byte(payload), nil)
+	}
+	if queue.spill.Len() != 2 {
+		t.Errorf("Expected 2 spilled messages, got %d", queue.spill.Len())
+	}
+
+	close(handler.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(handler.payloads()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertPayloads(t, handler.payloads(), "a", "b", "c", "d")
+}
+
+func TestIngestQueue_SpillSurvivesRestart(t *testing.T) {
+	handler := newBlockingHandler()
+	config := types.IngestConfig{Workers: 1, QueueSize: 1, Overflow: OverflowSpill, SpillPath: filepath.Join(t.TempDir(), "spill.db")}
+	queue, err := NewIngestQueue(config, handler.handle, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	queue.Submit("t", []byte("a"), nil)
+	<-handler.started
+	queue.Submit("t", []byte("b"), nil)
+	queue.Submit("t", []byte("c"), nil)
+
+	// Stopping keeps the spilled message on disk once the queue is drained
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(handler.release)
+	}()
+	queue.Stop()
+	assertPayloads(t, handler.payloads(), "a", "b")
+
+	restarted := newBlockingHandler()
+	close(restarted.release)
+	queue, err = NewIngestQueue(config, restarted.handle, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	<-restarted.started
+	queue.Stop()
+	assertPayloads(t, restarted.payloads(), "c")
+}
+
+func TestIngestQueue_RequeueWaitsUntilReady(t *testing.T) {
+	var ready atomic.Bool
+	processed := make(chan string, 1)
+	config := types.IngestConfig{Workers: 1, QueueSize: 1, Overflow: OverflowSpill, SpillPath: filepath.Join(t.TempDir(), "spill.db")}
+	queue, err := NewIngestQueue(config, func(topic string, payload []byte, ack func()) {
+		processed <- string(payload)
+	}, ready.Load)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer queue.Stop()
+
+	if !queue.Requeue("t", []byte("a"), nil) {
+		t.Fatalf("Expected the message to be spilled")
+	}
+	select {
+	case payload := <-processed:
+		t.Fatalf("Expected %q to be held back while the queue is not ready", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ready.Store(true)
+	select {
+	case payload := <-processed:
+		if payload != "a" {
+			t.Errorf("Expected %q, got %q", "a", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the spilled message to be processed once ready")
+	}
+}
+
+func TestIngestQueue_DrainTimeoutSpillsQueued(t *testing.T) {
+	handler := newBlockingHandler()
+	config := types.IngestConfig{Workers: 1, QueueSize: 1, Overflow: OverflowSpill, SpillPath: filepath.Join(t.TempDir(), "spill.db")}
+	queue, err := NewIngestQueue(config, handler.handle, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer close(handler.release)
+
+	queue.Submit("t", []byte("a"), nil)
+	<-handler.started
+	queue.Submit("t", []byte("b"), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := queue.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the drain to time out, got %v", err)
+	}
+
+	// The message no worker started is kept for the next start
+	spill, err := database.OpenSpillBuffer(config.SpillPath, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer spill.Close()
+	message, ok, err := spill.Oldest()
+	if err != nil || !ok || string(message.Payload) != "b" {
+		t.Errorf("Expected %q to be spilled, got %q (%v, %v)", "b", message.Payload, ok, err)
+	}
+}
+
+func TestIngestQueue_KeepsDriverOrder(t *testing.T) {
+	var mu sync.Mutex
+	processed := map[string][]int{}
+	release := make(chan struct{})
+
+	queue, err := NewIngestQueue(types.IngestConfig{Workers: 4, QueueSize: 100, Overflow: OverflowBlock}, func(topic string, payload []byte, ack func()) {
+		defer ack()
+		var message struct {
+			DriverID string `json:"driverId"`
+			Seq      int    `json:"seq"`
+		}
+		if err := json.Unmarshal(payload, &message); err != nil {
+			t.Errorf("Expected JSON, got %q", payload)
+			return
+		}
+		// The first driver waits for the second, which only finishes if
+		// the drivers are processed in parallel
+		if message.DriverID == "driver_a" && message.Seq == 0 {
+			<-release
+		}
+		mu.Lock()
+		processed[message.DriverID] = append(processed[message.DriverID], message.Seq)
+		mu.Unlock()
+		if message.DriverID == "driver_b" && message.Seq == 9 {
+			close(release)
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if queue.newJob("t", []byte(`{"driverId":"driver_a"}`), noAck).shard == queue.newJob("t", []byte(`{"driverId":"driver_b"}`), noAck).shard {
+		t.Fatal("Expected the drivers on different workers")
+	}
+
+	for seq := range 10 {
+		queue.Submit("drivers_location/a", []byte(fmt.Sprintf(`{"driverId":"driver_a","seq":%d}`, seq)), nil)
+		queue.Submit("drivers_location/b", []byte(fmt.Sprintf(`{"driverId":"driver_b","seq":%d}`, seq)), nil)
+	}
+
+	select {
+	case <-release:
+	case <-time.After(time.Second):
+		t.Fatal("Expected other drivers to be processed while one is blocked")
+	}
+	queue.Stop()
+
+	for _, driver := range []string{"driver_a", "driver_b"} {
+		if len(processed[driver]) != 10 {
+			t.Fatalf("Expected 10 messages from %s, got %v", driver, processed[driver])
+		}
+		for i, seq := range processed[driver] {
+			if seq != i {
+				t.Fatalf("Expected %s in order, got %v", driver, processed[driver])
+			}
+		}
+	}
+}
+
+func TestNewIngestQueue_RejectsUnknownPolicy(t *testing.T) {
+	if _, err := NewIngestQueue(types.IngestConfig{Overflow: "drop_newest"}, func(string, []byte, func()) {}, nil); err == nil {
+		t.Error("Expected an error for an unknown overflow policy")
+	}
+}
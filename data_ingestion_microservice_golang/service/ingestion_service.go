@@ -3,199 +3,1423 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/breaker"
 	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/retry"
+	"data-ingestion-microservice/tracing"
 	"data-ingestion-microservice/types"
+	"data-ingestion-microservice/webhook"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span for every stage of the ingestion pipeline
+var tracer = otel.Tracer("data-ingestion-microservice/service")
+
+// Webhook timing: how often silent routes are checked, and how long queued
+// deliveries may drain on shutdown
+const (
+	offlineSweepInterval = 15 * time.Second
+	webhookDrainTimeout  = 10 * time.Second
 )
 
 // DataIngestionService handles the main business logic
 type DataIngestionService struct {
 	config      types.Config
-	dbManager   *database.DatabaseManager
+	backends    database.Backends
 	simplifier  *algorithm.RouteSimplifier
+	deviation   *DeviationDetector
+	geofences   *GeofenceEngine
+	signatures  *SignatureVerifier
+	speeding    *SpeedingDetector
+	stopEvents  *StopEventDetector
+	headways    *HeadwayMonitor
+	segmenter   *TripSegmenter
+	shifts      database.ShiftStore
+	odometer    *Odometer
+	assignments *AssignmentTracker
+	eta         *ETAEstimator
+	adherence   *AdherenceScorer
+	loads       *LoadProfiler
+	idle        *IdleAnalyzer
+	ingest      *IngestQueue
+	finalizer   *FinalizationPool
+	liveStream  *LiveStream
+	events      *FleetEvents
+	publicFeed  *PublicFeed
+	webhooks    *webhook.Dispatcher
+	offline     *OfflineDetector
+	limiter     *RateLimiter
+	partitioner *Partitioner
+	failures    *FailureLog
+	repeats     *repeatDetector
+	retries     *retry.Policy
 	ctx         context.Context
+	startedAt   time.Time
+
+	// shiftLocation is the time zone of the days inferred shifts group
+	shiftLocation *time.Location
+
+	// heatmapLocation is the time zone of the heatmap days, set while the
+	// heatmap aggregation is enabled
+	heatmapLocation *time.Location
+
+	// active is set while the instance processes messages, which is always
+	// unless it waits as a standby. activeRenewed is only used by the
+	// standby heartbeat.
+	active        atomic.Bool
+	activeRenewed time.Time
+
+	// Circuit breakers of the storage dependencies
+	redisBreaker *breaker.Breaker
+	mongoBreaker *breaker.Breaker
+
+	// stranded counts the messages left unacknowledged after failing on a
+	// backend, waiting for the broker to redeliver them
+	stranded atomic.Int64
+
+	// settingsMu serializes simplification overrides
+	settingsMu        sync.Mutex
+	settingsUpdatedAt time.Time
+	routeOverrides    routeOverrides
+	features          featureFlags
+	runtime           runtimeSettings
+
+	stopBackground context.CancelFunc
+	backgroundDone sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
-// NewDataIngestionService creates a new data ingestion service
+// NewDataIngestionService creates a new data ingestion service backed by
+// Redis, MongoDB, and MQTT, or by embedded storage when configured
 func NewDataIngestionService(ctx context.Context, config types.Config) (*DataIngestionService, error) {
+	switch config.Storage.Mode {
+	case database.StorageModeExternal:
+	case database.StorageModeEmbedded:
+		// Run without Redis and MongoDB
+		backends, err := database.NewEmbeddedBackends(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize embedded storage: %w", err)
+		}
+		slog.Info("Using embedded storage", "path", config.Storage.EmbeddedPath)
+		return NewDataIngestionServiceWithBackends(ctx, config, backends)
+	default:
+		return nil, fmt.Errorf("unknown storage mode %q", config.Storage.Mode)
+	}
+
 	// Initialize database manager
 	dbManager, err := database.NewDatabaseManager(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database manager: %w", err)
 	}
 
+	return NewDataIngestionServiceWithBackends(ctx, config, dbManager.Backends())
+}
+
+// NewDataIngestionServiceWithBackends creates a data ingestion service on top
+// of the given storage and messaging backends
+func NewDataIngestionServiceWithBackends(ctx context.Context, config types.Config, backends database.Backends) (*DataIngestionService, error) {
 	// Initialize route simplifier
 	simplifier := algorithm.NewRouteSimplifier(config.RouteSimplification.Tolerance)
+	if err := simplifier.SetAlgorithm(config.RouteSimplification.Algorithm); err != nil {
+		return nil, err
+	}
 
 	service := &DataIngestionService{
 		config:     config,
-		dbManager:  dbManager,
+		backends:   backends,
 		simplifier: simplifier,
+		liveStream: NewLiveStream(config.HTTP.StreamBufferSize),
+		events:     NewFleetEvents(config.HTTP.EventsInterval, config.HTTP.StreamBufferSize),
+		failures:   NewFailureLog(config.Failures.SampleSize, config.Failures.SampleRate),
+		repeats:    newRepeatDetector(config.ErrorReporting.RepeatThreshold, config.ErrorReporting.RepeatWindow),
+		retries:    retry.NewPolicy(config.Retry, database.IsTransient),
 		ctx:        ctx,
+		startedAt:  time.Now().UTC(),
+
+		redisBreaker: breaker.New("redis", config.CircuitBreaker, database.IsTransient),
+		mongoBreaker: breaker.New("mongodb", config.CircuitBreaker, database.IsTransient),
+	}
+
+	// Apply the simplification override saved through the admin API. A
+	// service starting degraded uses the configured settings until restarted.
+	if err := service.loadSimplificationSettings(ctx); err != nil {
+		if !config.Startup.Degraded {
+			return nil, err
+		}
+		slog.Warn("Using the configured simplification while settings cannot be loaded", "error", err)
+	}
+
+	// Simplify the trips of some routes differently, as configured or
+	// stored in the route settings collection
+	overrides, err := types.ParseRouteOverrides(config.RouteSimplification.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	service.routeOverrides.configured = overrides
+	refreshRouteSettings := backends.RouteSettings != nil && config.RouteSimplification.SettingsRefresh > 0
+	if refreshRouteSettings {
+		if err := service.loadRouteOverrides(ctx); err != nil {
+			slog.Warn("Using the configured route overrides until route settings can be loaded", "error", err)
+		}
+	}
+
+	// Toggle capabilities with the configured feature flags and the ones
+	// stored in Redis
+	flags, err := types.ParseFeatureFlags(config.FeatureFlags.Flags)
+	if err != nil {
+		return nil, err
+	}
+	service.features.tenant = config.FeatureFlags.Tenant
+	service.features.configured = flags
+	refreshFeatureFlags := backends.FeatureFlags != nil && config.FeatureFlags.Refresh > 0
+	if refreshFeatureFlags {
+		if err := service.loadFeatureFlags(ctx); err != nil {
+			slog.Warn("Using the configured feature flags until stored flags can be loaded", "error", err)
+		}
+	}
+
+	// Limit the rate of locations of every driver if enabled
+	if config.RateLimit.Enabled {
+		limiter, err := NewRateLimiter(config.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		service.limiter = limiter
+	}
+
+	// Apply the settings shared by every instance through Redis
+	if config.RuntimeSettings.Enabled {
+		if backends.Runtime == nil {
+			return nil, errors.New("runtime settings require external storage")
+		}
+		if err := service.loadRuntimeSettings(ctx); err != nil {
+			slog.Warn("Using the configured settings until runtime settings can be loaded", "error", err)
+		}
+	}
+
+	// Split the drivers with the other instances if enabled
+	if config.Partition.Enabled {
+		if backends.Partitions == nil {
+			return nil, errors.New("partitioning requires external storage")
+		}
+		service.partitioner = NewPartitioner(config.Partition, config.InstanceID, backends.Partitions, service.leaderJobs())
+		// Each instance only sees its own drivers, so share the fleet events
+		if backends.FleetEvents != nil {
+			service.events.shareThrough(backends.FleetEvents)
+		}
+		if err := service.partitioner.Join(ctx); err != nil {
+			if !config.Startup.Degraded {
+				return nil, fmt.Errorf("failed to join the cluster: %w", err)
+			}
+			slog.Warn("Joining the cluster on the next heartbeat", "error", err)
+		}
+	}
+
+	// Run active-passive with the other instances if enabled
+	if config.Standby.Enabled {
+		if backends.Standby == nil {
+			return nil, errors.New("standby mode requires external storage")
+		}
+		if config.Partition.Enabled {
+			return nil, errors.New("standby mode and partitioning cannot be combined")
+		}
+	}
+
+	// Validate the public feed before any background loop starts
+	if config.PublicFeed.Enabled {
+		feed, err := NewPublicFeed(config.PublicFeed, backends.Broker)
+		if err != nil {
+			return nil, err
+		}
+		service.publicFeed = feed
+	}
+
+	// Notify webhook endpoints of trip completions and anomalies
+	if len(config.Webhooks.URLs) > 0 {
+		service.webhooks = webhook.NewDispatcher(config.Webhooks, backends.DeadLetters)
+		service.offline = NewOfflineDetector(config.Webhooks.OfflineAfter)
+	}
+
+	// Start the bounded worker pool for trip finalization
+	service.finalizer = NewFinalizationPool(config.Finalization, service.handleFinished)
+
+	// Background loops stop when the service is closed
+	backgroundCtx, cancel := context.WithCancel(ctx)
+	service.stopBackground = cancel
+
+	// Measure the rate of messages processed
+	service.backgroundDone.Add(1)
+	go service.sampleThroughput(backgroundCtx)
+
+	// Have the broker redeliver messages that failed on a backend
+	service.backgroundDone.Add(1)
+	go service.redeliverStranded(backgroundCtx)
+
+	// Pick up changes to the route settings collection
+	if refreshRouteSettings {
+		service.backgroundDone.Add(1)
+		go service.refreshRouteOverrides(backgroundCtx, config.RouteSimplification.SettingsRefresh)
+	}
+
+	// Pick up feature flags toggled in Redis
+	if refreshFeatureFlags {
+		service.backgroundDone.Add(1)
+		go service.refreshFeatureFlags(backgroundCtx, config.FeatureFlags.Refresh)
+	}
+
+	// Follow runtime settings changed by any instance
+	if service.runtimeSettingsEnabled() {
+		service.backgroundDone.Add(1)
+		go service.watchRuntimeSettings(backgroundCtx)
+		if config.RuntimeSettings.Refresh > 0 {
+			service.backgroundDone.Add(1)
+			go service.refreshRuntimeSettings(backgroundCtx, config.RuntimeSettings.Refresh)
+		}
+	}
+
+	// Resume finalizations interrupted by a crash. A standby resumes them
+	// when it takes over.
+	if service.checkpointing() && !config.Standby.Enabled {
+		service.backgroundDone.Add(1)
+		go service.resumeFinalizations(backgroundCtx, false)
+	}
+
+	// Consume finalizations from the Redis consumer group if enabled
+	if config.Redis.FinalizeConsumerGroup {
+		service.backgroundDone.Add(1)
+		go service.runFinalizationConsumer(backgroundCtx)
+
+		service.backgroundDone.Add(1)
+		go service.sampleFinalizationBacklog(backgroundCtx)
+	}
+
+	// Keep the cluster membership current
+	if service.partitioner != nil {
+		service.backgroundDone.Add(1)
+		go func() {
+			defer service.backgroundDone.Done()
+			service.partitioner.Run(backgroundCtx)
+		}()
+	}
+
+	// Stream the fleet events announced by every instance
+	if service.events.bus != nil {
+		service.backgroundDone.Add(1)
+		go service.watchFleetEvents(backgroundCtx)
+	}
+
+	// Finalize routes abandoned by their devices
+	if config.Janitor.Enabled {
+		service.backgroundDone.Add(1)
+		go service.runRouteJanitor(backgroundCtx)
+	}
+
+	// Track route buffers that expire without being finalized
+	if config.Redis.RouteTTL > 0 && config.Redis.WatchExpiredRoutes {
+		service.backgroundDone.Add(1)
+		go service.watchExpiredRoutes(backgroundCtx)
+	}
+
+	// Republish sanitized positions to public topics if enabled
+	if service.publicFeed != nil {
+		service.backgroundDone.Add(1)
+		go func() {
+			defer service.backgroundDone.Done()
+			service.publicFeed.Run(backgroundCtx)
+		}()
+	}
+
+	// Report drivers that stop sending locations mid-route
+	if service.offline != nil {
+		service.backgroundDone.Add(1)
+		go func() {
+			defer service.backgroundDone.Done()
+			service.offline.Run(backgroundCtx, offlineSweepInterval, func(event types.DeviceOfflineEvent) {
+				slog.Warn("Driver stopped reporting", "driverId", event.DriverID, "routeId", event.CurrentRouteID, "silentSeconds", event.SilentSeconds)
+				service.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp}, event)
+			})
+		}()
+	}
+
+	// Initialize route deviation detection if enabled
+	if config.RouteDeviation.Enabled {
+		service.deviation = NewDeviationDetector(config.RouteDeviation, backends.PlannedRoutes, backends.Broker)
+	}
+
+	// Split routes on long pauses or jumps if configured
+	if config.Segmentation.MaxGap > 0 || config.Segmentation.MaxJumpKm > 0 {
+		service.segmenter = NewTripSegmenter(config.Segmentation)
+	}
+
+	// Report sustained speeding if enabled
+	if config.Speeding.Enabled {
+		speeding, err := NewSpeedingDetector(config.Speeding, backends.Broker)
+		if err != nil {
+			return nil, err
+		}
+		service.speeding = speeding
+	}
+
+	// Report geofence entries and exits if enabled
+	if config.Geofence.Enabled {
+		if backends.Geofences == nil {
+			return nil, errors.New("geofencing requires a geofence store")
+		}
+		service.geofences = NewGeofenceEngine(config.Geofence, backends.Geofences, backends.Broker)
+		if err := service.geofences.Load(ctx); err != nil {
+			slog.Warn("Evaluating no geofences until they can be loaded", "error", err)
+		}
+		if config.Geofence.Refresh > 0 {
+			service.backgroundDone.Add(1)
+			go service.refreshGeofences(backgroundCtx, config.Geofence.Refresh)
+		}
+	}
+
+	// Verify the signatures of device messages if enabled
+	if config.Signatures.Enabled {
+		if backends.Devices == nil {
+			return nil, errors.New("signature verification requires a device registry")
+		}
+		service.signatures = NewSignatureVerifier(config.Signatures, backends.Devices)
+		if err := service.signatures.Load(ctx); err != nil {
+			slog.Warn("Verifying with no device keys until they can be loaded", "error", err)
+		}
+		if config.Signatures.Refresh > 0 {
+			service.backgroundDone.Add(1)
+			go service.refreshDeviceKeys(backgroundCtx, config.Signatures.Refresh)
+		}
+	}
+
+	// Track driver shifts if enabled
+	if config.Shifts.Enabled {
+		if backends.Shifts == nil {
+			return nil, errors.New("shift tracking requires a shift store")
+		}
+		location, err := time.LoadLocation(config.Shifts.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shift time zone: %w", err)
+		}
+		service.shifts = backends.Shifts
+		service.shiftLocation = location
 	}
 
-	// Subscribe to MQTT topic
-	err = dbManager.SubscribeToTopic(config.MQTT.Topic, service.messageHandler)
+	// Count the daily distance of drivers and vehicles if enabled
+	if config.Odometer.Enabled {
+		if backends.Counters == nil || backends.Odometer == nil {
+			return nil, errors.New("distance counting requires distance counters and a daily distance store")
+		}
+		odometer, err := NewOdometer(config.Odometer)
+		if err != nil {
+			return nil, err
+		}
+		service.odometer = odometer
+		service.backgroundDone.Add(1)
+		go service.runOdometerRollup(backgroundCtx)
+	}
+
+	// Count trip points in heatmap cells if enabled
+	if config.Heatmap.Enabled {
+		if backends.TripQueries == nil || backends.Heatmap == nil {
+			return nil, errors.New("heatmaps require trip queries and a heatmap store")
+		}
+		location, err := time.LoadLocation(config.Heatmap.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid heatmap time zone: %w", err)
+		}
+		service.heatmapLocation = location
+		service.backgroundDone.Add(1)
+		go service.runHeatmapAggregation(backgroundCtx)
+	}
+
+	// Log which vehicle every driver drives when the storage keeps the log
+	if backends.Assignments != nil {
+		service.assignments = NewAssignmentTracker(backends.Assignments)
+	}
+
+	// Estimate stop arrivals if enabled
+	if config.ETA.Enabled {
+		if backends.ETAs == nil || backends.PlannedRoutes == nil {
+			return nil, errors.New("stop ETAs require an ETA store and planned routes")
+		}
+		service.eta = NewETAEstimator(config.ETA, backends.PlannedRoutes)
+	}
+
+	// Report stop arrivals and departures if enabled
+	if config.StopEvents.Enabled {
+		if backends.PlannedRoutes == nil {
+			return nil, errors.New("stop events require planned routes")
+		}
+		service.stopEvents = NewStopEventDetector(config.StopEvents, backends.PlannedRoutes, backends.Broker)
+	}
+
+	// Monitor the headways between the vehicles on a route if enabled
+	if config.Headway.Enabled {
+		if backends.PlannedRoutes == nil {
+			return nil, errors.New("headway monitoring requires planned routes")
+		}
+		service.headways = NewHeadwayMonitor(config.Headway, backends.PlannedRoutes, backends.Broker)
+	}
+
+	// Score finished trips against their planned route if enabled
+	if config.Adherence.Enabled {
+		if backends.PlannedRoutes == nil {
+			return nil, errors.New("route adherence scoring requires planned routes")
+		}
+		service.adherence = NewAdherenceScorer(config.Adherence, backends.PlannedRoutes)
+	}
+
+	// Buffer passenger counts and profile the load of finished trips if enabled
+	if config.PassengerCounts.Enabled {
+		service.loads = NewLoadProfiler(config.PassengerCounts, backends.PlannedRoutes)
+	}
+
+	// Find the idle periods of finished trips if enabled
+	if config.Idle.Enabled {
+		service.idle = NewIdleAnalyzer(config.Idle, config.TripStats, backends.PlannedRoutes, service.geofences)
+	}
+
+	// Process MQTT messages on a bounded worker pool
+	ingest, err := NewIngestQueue(config.Ingest, service.handleMessage, service.redisBreaker.Ready)
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to MQTT topic: %w", err)
+		return nil, err
 	}
+	service.ingest = ingest
 
-	log.Printf("Successfully initialized data ingestion service")
-	log.Printf("Subscribed to MQTT topic: %s", config.MQTT.Topic)
+	// Subscribe to MQTT topic, unless another instance is active
+	if config.Standby.Enabled {
+		if err := service.heartbeatActive(backgroundCtx); err != nil {
+			if !config.Startup.Degraded {
+				service.ingest.Stop()
+				return nil, err
+			}
+			slog.Warn("Waiting for the active lease while Redis is down", "error", err)
+		}
+		if !service.active.Load() {
+			metrics.StandbyActive.Set(0)
+			slog.Info("Waiting as a standby", "instance", config.InstanceID)
+			service.dropSubscription()
+		}
+		service.backgroundDone.Add(1)
+		go service.runStandby(backgroundCtx)
+	} else {
+		err = backends.Broker.SubscribeToTopic(config.MQTT.Topic, service.messageHandler)
+		if err != nil {
+			service.ingest.Stop()
+			return nil, fmt.Errorf("failed to subscribe to MQTT topic: %w", err)
+		}
+		service.active.Store(true)
+	}
+
+	slog.Info("Successfully initialized data ingestion service", "mqttTopic", config.MQTT.Topic)
 
 	return service, nil
 }
 
-// messageHandler processes incoming MQTT messages
+// messageHandler hands incoming MQTT messages to the ingest queue. The MQTT
+// client delivers messages in order, so while the queue blocks, no more are
+// read from the broker.
 func (s *DataIngestionService) messageHandler(client mqtt.Client, msg mqtt.Message) {
-	go func() {
-		if err := s.processMessage(msg.Payload()); err != nil {
-			log.Printf("Error processing message: %v", err)
-		}
-	}()
+	if s.dropPaused(msg.Topic(), msg.Ack) {
+		return
+	}
+	// Signatures are checked once, on receipt, so that messages processed
+	// later from the queue or the spill buffer are not dropped for their age
+	if s.signatures != nil && !s.verifySignature(s.ctx, msg.Payload(), msg.Duplicate()) {
+		msg.Ack()
+		return
+	}
+	s.ingest.Submit(msg.Topic(), msg.Payload(), msg.Ack)
 }
 
-// processMessage processes an incoming MQTT message payload
-func (s *DataIngestionService) processMessage(payload []byte) error {
+// handleMessage processes a queued MQTT message in a trace that continues
+// the publisher's when the payload carries its trace context
+func (s *DataIngestionService) handleMessage(topic string, payload []byte, ack func()) {
+	// While Redis is down, park messages on disk instead of failing them
+	if !s.redisBreaker.Ready() && s.ingest.Requeue(topic, payload, ack) {
+		return
+	}
+
+	ctx := s.ctx
+	if s.config.Tracing.Enabled {
+		ctx = tracing.ExtractPayload(ctx, payload)
+	}
+	ctx, span := tracer.Start(ctx, "mqtt.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "mqtt"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.Int("messaging.message.body.size", len(payload)),
+		))
+
+	err := s.processMessage(ctx, payload, ack)
+	metrics.IngestProcessed.Add(1)
+	tracing.End(span, err)
+
+	// Park messages of a driver still leased by its previous owner on disk
+	// until the lease is handed over, backing off between attempts so they
+	// do not loop through Redis; without a spill buffer they wait for the
+	// broker to redeliver them
+	if errors.Is(err, errDriverLeased) && s.ingest.Retry(topic, payload, ack) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error processing message", "topic", topic, "class", failureClass(err), "error", err)
+	}
+	if err != nil && !permanentFailure(err) {
+		s.retryFailed(topic, payload, ack)
+	}
+}
+
+// processMessage processes an incoming MQTT message payload. Failures,
+// including panics, are classified by the stage that failed and recorded in
+// the failure log. The optional ack is called once the message is handled,
+// which for a finished route is after its trip is finalized, so a crash
+// before then leaves the message for the broker to redeliver. Messages that
+// failed on a backend are not acknowledged either; handleMessage retries
+// them. Only permanent failures are acknowledged and dropped.
+func (s *DataIngestionService) processMessage(ctx context.Context, payload []byte, ack func()) (err error) {
 	var busMsg types.BusMessage
-	if err := json.Unmarshal(payload, &busMsg); err != nil {
-		return fmt.Errorf("failed to unmarshal message: %w", err)
+	if ack == nil {
+		ack = noAck
+	}
+	handedOff := false
+	timer := newStageTimer()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = recoverPanic(ctx, recovered, busMsg)
+		}
+		if err != nil {
+			s.recordFailure(ctx, err, busMsg, payload)
+		}
+		if !handedOff && (err == nil || permanentFailure(err)) {
+			ack()
+		}
+		timer.finish(ctx, "message", metrics.MessageLatency, s.config.Log.SlowThreshold)
+	}()
+
+	_, decodeSpan := tracer.Start(ctx, "decode")
+	decodeStart := time.Now()
+	err = json.Unmarshal(payload, &busMsg)
+	timer.since(stageDecode, decodeStart)
+	tracing.End(decodeSpan, err)
+	if err != nil {
+		return classify(FailureDecode, fmt.Errorf("failed to unmarshal message: %w", err))
+	}
+	if err := validateMessage(busMsg); err != nil {
+		return classify(FailureValidation, err)
+	}
+
+	observeLag(busMsg, time.Now())
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("driver.id", busMsg.DriverID),
+		attribute.String("route.id", busMsg.CurrentRouteID),
+		attribute.String("status", busMsg.Status),
+	)
+	ctx = logging.With(ctx, "driverId", busMsg.DriverID, "routeId", busMsg.CurrentRouteID)
+
+	// Leave drivers owned by other instances to them
+	if s.partitioner != nil {
+		owned, err := s.ownsDriver(ctx, busMsg)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return nil
+		}
+	}
+
+	// Throttle drivers sending locations faster than their rate limit;
+	// finished messages always pass so their trips are finalized
+	if s.limiter != nil && busMsg.Status == "in_route" && !s.allowLocation(ctx, busMsg) {
+		return nil
+	}
+
+	// Drop retransmissions of messages already processed
+	hash := messageHash(payload)
+	duplicate, err := s.dropDuplicate(ctx, busMsg, hash)
+	if err != nil {
+		return classify(FailureRedis, err)
+	}
+	if duplicate {
+		return nil
+	}
+
+	// Shift messages open or close the driver's shift and carry no location
+	if isShiftMessage(busMsg) {
+		if err := s.handleShift(ctx, busMsg); err != nil {
+			return err
+		}
+		s.recordMessage(ctx, busMsg, hash)
+		return nil
+	}
+
+	// Passenger counts are buffered as points of their route, so they are
+	// processed as locations from here on
+	if isPassengerCountMessage(busMsg) {
+		if s.loads == nil {
+			slog.DebugContext(ctx, "Ignoring passenger count, passenger counting is disabled")
+			s.recordMessage(ctx, busMsg, hash)
+			return nil
+		}
+		busMsg.Status = "in_route"
+	}
+
+	key := routeKey(busMsg)
+
+	// Keep the live fleet position index current
+	if s.config.Redis.LivePositions {
+		liveCtx, span := tracer.Start(ctx, "redis.live_position")
+		err := s.redisCall(liveCtx, "redis.live_position", func() error {
+			return s.backends.Live.UpdateLivePosition(liveCtx, busMsg)
+		})
+		tracing.End(span, err)
+		if err != nil {
+			return classify(FailureRedis, err)
+		}
 	}
 
-	key := fmt.Sprintf("%s:%s", busMsg.DriverID, busMsg.CurrentRouteID)
+	// Log the driver's vehicle when they switched to another one
+	if s.assignments != nil && busMsg.VehicleID != "" {
+		if err := s.trackAssignment(ctx, busMsg); err != nil {
+			return err
+		}
+	}
 
 	switch busMsg.Status {
 	case "in_route":
-		return s.handleInRoute(key, busMsg.DriverLocation)
+		if err := s.handleInRoute(ctx, key, busMsg, timer); err != nil {
+			return err
+		}
+		s.events.Location(ctx, key, busMsg, time.Now())
+		if s.offline != nil {
+			s.offline.Seen(key, busMsg, time.Now())
+		}
 	case "finished":
-		return s.handleFinished(key, busMsg)
-	default:
-		log.Printf("Unknown status received: %s", busMsg.Status)
-		return nil
+		handedOff, err = s.finishRoute(ctx, key, busMsg, ack)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.recordMessage(ctx, busMsg, hash)
+
+	// Stream the processed location to live subscribers of the route
+	s.liveStream.Publish(busMsg)
+	if s.publicFeed != nil && s.FeatureEnabled(types.FeatureLiveRepublish) {
+		s.publicFeed.Update(busMsg, time.Now())
 	}
+	return nil
 }
 
-// handleInRoute stores location data in Redis
-func (s *DataIngestionService) handleInRoute(key string, location types.Location) error {
-	locationJSON, err := json.Marshal(location)
-	if err != nil {
-		return fmt.Errorf("failed to marshal location: %w", err)
+// finishRoute hands a finished route to finalization: through the consumer
+// group when enabled, so any instance can finalize it, and otherwise to the
+// local worker pool, which calls ack once the trip is finalized or failed
+// permanently. It reports whether ack was handed off.
+func (s *DataIngestionService) finishRoute(ctx context.Context, key string, busMsg types.BusMessage, ack func()) (bool, error) {
+	handedOff := false
+	if s.config.Redis.FinalizeConsumerGroup {
+		enqueueCtx, span := tracer.Start(ctx, "redis.enqueue_finalization")
+		err := s.redisCall(enqueueCtx, "redis.enqueue_finalization", func() error {
+			return s.backends.Finalizations.EnqueueFinalization(enqueueCtx, busMsg)
+		})
+		tracing.End(span, err)
+		if err != nil {
+			return false, classify(FailureRedis, err)
+		}
+	} else {
+		handedOff = true
+		s.finalizer.Submit(ctx, key, busMsg, func(err error) {
+			// Trips that failed on a backend are redelivered instead, and
+			// so are those the stopped pool never ran, after the restart
+			switch {
+			case err == nil || permanentFailure(err):
+				ack()
+			case !errors.Is(err, errPoolStopped):
+				s.stranded.Add(1)
+			}
+		})
 	}
 
-	err = s.dbManager.RedisClient.RPush(s.ctx, key, string(locationJSON)).Err()
+	s.events.Finished(ctx, key)
+	if s.offline != nil {
+		s.offline.Finished(key)
+	}
+	s.resetRouteDetectors(key)
+
+	// Stop announcing arrivals for the finished trip
+	if s.eta != nil {
+		err := s.redisCall(ctx, "redis.eta", func() error {
+			return s.backends.ETAs.RemoveETA(ctx, busMsg.CurrentRouteID, busMsg.DriverID)
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to remove stop ETAs of finished trip", "error", err)
+		}
+	}
+	return handedOff, nil
+}
+
+// validateMessage rejects messages that cannot belong to a route
+func validateMessage(busMsg types.BusMessage) error {
+	switch {
+	case busMsg.DriverID == "":
+		return fmt.Errorf("message without driverId")
+	case isShiftMessage(busMsg):
+		// Shifts are not tied to a route
+	case busMsg.CurrentRouteID == "":
+		return fmt.Errorf("message without currentRouteId")
+	case busMsg.Status != "in_route" && busMsg.Status != "finished" && !isPassengerCountMessage(busMsg):
+		return fmt.Errorf("unknown status %q", busMsg.Status)
+	case busMsg.DriverLocation.Latitude < -90 || busMsg.DriverLocation.Latitude > 90 ||
+		busMsg.DriverLocation.Longitude < -180 || busMsg.DriverLocation.Longitude > 180:
+		return fmt.Errorf("location %v,%v out of range", busMsg.DriverLocation.Latitude, busMsg.DriverLocation.Longitude)
+	}
+	if err := validatePassengers(busMsg); err != nil {
+		return err
+	}
+	return validateTelemetry(busMsg.Telemetry)
+}
+
+// maxAccelerationMps2 is the largest acceleration a vehicle can report,
+// well past any crash a device survives
+const maxAccelerationMps2 = 160
+
+// validateTelemetry rejects telemetry readings no device can report
+func validateTelemetry(telemetry types.Telemetry) error {
+	switch {
+	case telemetry.Heading != nil && (*telemetry.Heading < 0 || *telemetry.Heading >= 360):
+		return fmt.Errorf("heading %v out of range", *telemetry.Heading)
+	case telemetry.SpeedKmh != nil && *telemetry.SpeedKmh < 0:
+		return fmt.Errorf("negative speed %v", *telemetry.SpeedKmh)
+	case telemetry.AccuracyMeters != nil && *telemetry.AccuracyMeters < 0:
+		return fmt.Errorf("negative accuracy %v", *telemetry.AccuracyMeters)
+	case telemetry.Satellites != nil && *telemetry.Satellites < 0:
+		return fmt.Errorf("negative satellite count %d", *telemetry.Satellites)
+	case telemetry.BatteryPercent != nil && (*telemetry.BatteryPercent < 0 || *telemetry.BatteryPercent > 100):
+		return fmt.Errorf("battery level %v out of range", *telemetry.BatteryPercent)
+	case telemetry.AccelerationMps2 != nil && math.Abs(*telemetry.AccelerationMps2) > maxAccelerationMps2:
+		return fmt.Errorf("acceleration %v out of range", *telemetry.AccelerationMps2)
+	case telemetry.LateralAccelerationMps2 != nil && math.Abs(*telemetry.LateralAccelerationMps2) > maxAccelerationMps2:
+		return fmt.Errorf("lateral acceleration %v out of range", *telemetry.LateralAccelerationMps2)
+	}
+	return nil
+}
+
+// handleInRoute appends location data to the route's Redis stream
+func (s *DataIngestionService) handleInRoute(ctx context.Context, key string, busMsg types.BusMessage, timer *stageTimer) error {
+	point := types.TrackPoint{
+		Location:   busMsg.DriverLocation,
+		Timestamp:  busMsg.Timestamp,
+		Telemetry:  busMsg.Telemetry,
+		Passengers: busMsg.Passengers,
+	}
+	// Vehicles change drivers, so their points record who sent them
+	if busMsg.VehicleID != "" {
+		point.DriverID = busMsg.DriverID
+	}
+
+	// A long pause or jump since the previous point starts a new trip
+	var prev types.TrackPoint
+	var reason string
+	split := false
+	if s.segmenter != nil {
+		prev, reason, split = s.segmenter.Split(key, point)
+	}
+
+	writeCtx, span := tracer.Start(ctx, "redis.write")
+	writeStart := time.Now()
+	err := s.redisCall(writeCtx, "redis.write", func() error {
+		return s.backends.Buffer.AppendPoint(writeCtx, key, point)
+	})
+	timer.since(stageRedisWrite, writeStart)
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("failed to store location in Redis: %w", err)
+		return classify(FailureRedis, err)
+	}
+
+	slog.DebugContext(ctx, "Stored location", "key", key)
+
+	// Close the trip before the point, which is left buffered for the next
+	if split {
+		if err := s.closeSegment(ctx, key, busMsg, prev, reason); err != nil {
+			return err
+		}
+	}
+	if s.segmenter != nil {
+		s.segmenter.Advance(key, point)
+	}
+
+	// Add the step driven since the previous point to the daily distances
+	if s.odometer != nil {
+		if err := s.countDistance(ctx, key, busMsg, point); err != nil {
+			return err
+		}
+	}
+
+	// Fan the location out to live subscribers
+	if s.config.Redis.LivePubSub && s.FeatureEnabled(types.FeatureLiveRepublish) {
+		if err := s.backends.Live.PublishLiveLocation(ctx, busMsg); err != nil {
+			return classify(FailureRedis, err)
+		}
+	}
+
+	// Compare the point against the planned route
+	if s.deviation != nil {
+		event, err := s.deviation.Check(ctx, key, busMsg)
+		if err != nil {
+			return fmt.Errorf("failed to check route deviation: %w", err)
+		}
+		if event != nil && s.webhooks != nil {
+			s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp}, event)
+		}
+	}
+
+	// Report drivers who keep speeding
+	if s.speeding != nil {
+		event, err := s.speeding.Check(ctx, key, busMsg)
+		if err != nil {
+			return fmt.Errorf("failed to check speeding: %w", err)
+		}
+		if event != nil && s.webhooks != nil {
+			s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp}, event)
+		}
+	}
+
+	// Report the stops the vehicle arrived at or departed from
+	if s.stopEvents != nil {
+		events, err := s.stopEvents.Check(ctx, key, busMsg)
+		if err != nil {
+			return fmt.Errorf("failed to check stop events: %w", err)
+		}
+		if s.webhooks != nil {
+			for _, event := range events {
+				s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp, Key: event.Visit.StopID}, event)
+			}
+		}
+	}
+
+	// Report vehicles bunched with or far behind the vehicle ahead
+	if s.headways != nil {
+		event, err := s.headways.Check(ctx, key, busMsg)
+		if err != nil {
+			return fmt.Errorf("failed to check headway: %w", err)
+		}
+		if event != nil && s.webhooks != nil {
+			s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp}, event)
+		}
+	}
+
+	// Report the geofences the driver entered or exited
+	if s.geofences != nil {
+		events, err := s.geofences.Check(ctx, key, busMsg)
+		if err != nil {
+			return fmt.Errorf("failed to check geofences: %w", err)
+		}
+		if s.webhooks != nil {
+			for _, event := range events {
+				s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp, Key: event.GeofenceID}, event)
+			}
+		}
+	}
+
+	// Estimate the arrival at the stops ahead
+	if s.eta != nil {
+		eta, err := s.eta.Estimate(ctx, key, busMsg)
+		if err != nil {
+			return fmt.Errorf("failed to estimate stop arrivals: %w", err)
+		}
+		if eta != nil {
+			err := s.redisCall(ctx, "redis.eta", func() error {
+				return s.backends.ETAs.SaveETA(ctx, *eta, s.config.ETA.TTL)
+			})
+			if err != nil {
+				return classify(FailureRedis, err)
+			}
+		}
 	}
 
-	log.Printf("Stored location for key %s in Redis", key)
 	return nil
 }
 
 // handleFinished retrieves route data, simplifies it, and stores in MongoDB
-func (s *DataIngestionService) handleFinished(key string, busMsg types.BusMessage) error {
-	// Retrieve all stored points from Redis
-	pointsJSON, err := s.dbManager.RedisClient.LRange(s.ctx, key, 0, -1).Result()
+func (s *DataIngestionService) handleFinished(ctx context.Context, key string, busMsg types.BusMessage) (err error) {
+	ctx, span := tracer.Start(ctx, "finalize", trace.WithAttributes(
+		attribute.String("driver.id", busMsg.DriverID),
+		attribute.String("route.id", busMsg.CurrentRouteID),
+	))
+	timer := newStageTimer()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = recoverPanic(ctx, recovered, busMsg)
+		}
+		tracing.End(span, err)
+		if err != nil {
+			s.recordFailure(ctx, err, busMsg, nil)
+		}
+		timer.finish(ctx, "finalization", metrics.FinalizationLatency, s.config.Log.SlowThreshold)
+	}()
+	ctx = logging.With(ctx, "driverId", busMsg.DriverID, "routeId", busMsg.CurrentRouteID)
+
+	// Make sure points still waiting in the pipeline reach the stream
+	readCtx, readSpan := tracer.Start(ctx, "redis.read")
+	readStart := time.Now()
+	s.backends.Buffer.FlushPoints(readCtx)
+
+	// Retrieve all stored points from the Redis stream
+	var buffered []database.BufferedPoint
+	err = s.redisCall(readCtx, "redis.read", func() (err error) {
+		buffered, err = s.backends.Buffer.ReadPoints(readCtx, key)
+		return err
+	})
+	timer.since(stageRedisRead, readStart)
+	readSpan.SetAttributes(attribute.Int("points", len(buffered)))
+	tracing.End(readSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve points from Redis: %w", err)
+		return classify(FailureRedis, fmt.Errorf("failed to retrieve points from Redis: %w", err))
+	}
+
+	// A trip closed by segmentation ends before the points of the next one
+	if busMsg.Status == statusSegmented {
+		buffered = segmentPoints(buffered, busMsg.Timestamp)
 	}
 
-	if len(pointsJSON) == 0 {
-		log.Printf("No stored points for key %s", key)
+	if len(buffered) == 0 {
+		slog.WarnContext(ctx, "No stored points for finished route", "key", key)
 		return nil
 	}
 
-	// Parse JSON strings into Location structs
-	var locations []types.Location
-	for _, pointJSON := range pointsJSON {
-		var location types.Location
-		if err := json.Unmarshal([]byte(pointJSON), &location); err != nil {
-			log.Printf("Failed to unmarshal location: %v", err)
-			continue
+	points := make([]types.TrackPoint, 0, len(buffered))
+	for _, entry := range buffered {
+		points = append(points, entry.Point)
+	}
+
+	// Identify the trip by its first point so duplicate "finished" messages
+	// upsert the same document
+	startTimestamp := points[0].Timestamp
+	if startTimestamp == 0 {
+		startTimestamp = busMsg.Timestamp
+	}
+	id := routeTripID(busMsg, startTimestamp)
+	ctx = logging.With(ctx, "tripId", id)
+
+	// Checkpoint the finalization so a crash before it ends is recovered
+	if s.checkpointing() {
+		if err := s.beginFinalization(ctx, key, id, busMsg); err != nil {
+			return err
 		}
-		locations = append(locations, location)
+		defer s.endFinalization(ctx, key)
 	}
 
-	if len(locations) == 0 {
-		log.Printf("No valid locations found for key %s", key)
-		return nil
+	// A marker means a previous attempt stored the trip but did not finish
+	// cleaning up Redis, so only the cleanup is left to do
+	var finalized bool
+	err = s.mongoCall(ctx, "mongo.is_finalized", func() (err error) {
+		finalized, err = s.backends.Trips.IsFinalized(ctx, id)
+		return err
+	})
+	if err != nil {
+		return classify(FailureMongo, err)
+	}
+	if finalized {
+		slog.InfoContext(ctx, "Trip already finalized, clearing leftover route data", "key", key)
+		return s.clearRoute(ctx, key, buffered[len(buffered)-1].ID)
 	}
 
-	// Simplify the route using the algorithm
-	simplifiedLocations, err := s.simplifier.SimplifyRoute(locations)
+	// Score the trace and drop its GPS spikes before the route is simplified
+	// and analyzed; the raw route keeps every point
+	raw := points
+	var quality *types.TripQuality
+	if s.config.Quality.Enabled {
+		var outliers int
+		points, outliers = algorithm.RemoveOutliers(points, s.config.Quality.MaxSpeedKmh)
+		score := algorithm.ScoreQuality(points, outliers, s.config.Quality)
+		quality = &score
+	}
+
+	// Simplify the route using the algorithm and tolerance of its route
+	tolerance, simplification, override := s.simplificationFor(busMsg.CurrentRouteID)
+	_, simplifySpan := tracer.Start(ctx, "simplify", trace.WithAttributes(
+		attribute.String("algorithm", simplification),
+		attribute.Float64("tolerance", tolerance),
+		attribute.Int("points", len(points)),
+	))
+	simplifyStart := time.Now()
+	simplifiedRoute, err := s.simplifier.SimplifyTrackWith(points, tolerance, simplification)
+	timer.since(stageSimplify, simplifyStart)
+	tracing.End(simplifySpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to simplify route: %w", err)
+		return classify(FailureSimplify, fmt.Errorf("failed to simplify route: %w", err))
 	}
 
 	// Get compression statistics
-	stats := s.simplifier.GetCompressionStats(locations, simplifiedLocations)
+	stats := algorithm.NewCompressionStats(len(points), len(simplifiedRoute))
 
-	log.Printf("Route %s finished. Original: %d points, Simplified: %d points (%.2f%% reduction)",
-		key, stats.OriginalPoints, stats.SimplifiedPoints, stats.ReductionPercent)
+	slog.InfoContext(ctx, "Route simplified",
+		"originalPoints", stats.OriginalPoints,
+		"simplifiedPoints", stats.SimplifiedPoints,
+		"reductionPercent", stats.ReductionPercent,
+		"algorithm", simplification,
+		"tolerance", tolerance,
+		"routeOverride", override,
+	)
 
-	// Convert simplified points to MongoDB format
-	var simplifiedRoute []bson.M
-	for _, location := range simplifiedLocations {
-		simplifiedRoute = append(simplifiedRoute, bson.M{
-			"latitude":  location.Latitude,
-			"longitude": location.Longitude,
-		})
+	// Compute trip summary statistics from the raw points
+	tripStats := algorithm.ComputeTripStats(points, s.config.TripStats)
+
+	trip := types.Trip{
+		ID:                    id,
+		DriverID:              busMsg.DriverID,
+		VehicleID:             busMsg.VehicleID,
+		CurrentRouteID:        busMsg.CurrentRouteID,
+		SimplifiedRoute:       simplifiedRoute,
+		Timestamp:             int64(busMsg.Timestamp),
+		OriginalPointsCount:   stats.OriginalPoints,
+		SimplifiedPointsCount: stats.SimplifiedPoints,
+		CompressionRatio:      stats.CompressionRatio,
+		ReductionPercent:      stats.ReductionPercent,
+		Stats:                 tripStats,
+		Quality:               quality,
+		Status:                busMsg.Status,
+		CreatedAt:             time.Now().UTC(),
+	}
+
+	// Tag the trip with the geofences its raw points passed through
+	if s.geofences != nil {
+		trip.Zones = s.geofences.Zones(points)
+	}
+
+	// Account for the time the raw points spent in the operational zones
+	if s.geofences != nil && s.config.ZoneTime.Enabled {
+		trip.ZoneTime = s.geofences.ZoneTime(points, s.config.ZoneTime)
+	}
+
+	// Record the periods the raw points were speeding
+	if s.speeding != nil {
+		trip.SpeedingViolations = s.speeding.Violations(points)
+	}
+
+	// Record the actual and scheduled arrivals at the stops of the route
+	if s.stopEvents != nil {
+		visits, err := s.stopEvents.Visits(ctx, busMsg.CurrentRouteID, points)
+		if err != nil {
+			return classify(FailureMongo, err)
+		}
+		trip.StopVisits = visits
 	}
 
-	// Insert the simplified route into MongoDB
-	tripDoc := bson.M{
-		"driverId":              busMsg.DriverID,
-		"currentRouteId":        busMsg.CurrentRouteID,
-		"simplifiedRoute":       simplifiedRoute,
-		"timestamp":             int64(busMsg.Timestamp),
-		"originalPointsCount":   stats.OriginalPoints,
-		"simplifiedPointsCount": stats.SimplifiedPoints,
-		"compressionRatio":      stats.CompressionRatio,
-		"reductionPercent":      stats.ReductionPercent,
+	// Summarize the device telemetry of the raw points
+	trip.Telemetry = algorithm.SummarizeTelemetry(points)
+
+	// Score the raw points against the planned route and timetable
+	if s.adherence != nil {
+		adherence, err := s.adherence.Score(ctx, busMsg.CurrentRouteID, points)
+		if err != nil {
+			return classify(FailureMongo, err)
+		}
+		trip.Adherence = adherence
 	}
 
-	_, err = s.dbManager.MongoCollection.InsertOne(s.ctx, tripDoc)
+	// Record the harsh braking, acceleration, and cornering of the raw points
+	if s.config.HarshDriving.Enabled {
+		events := algorithm.DetectHarshDriving(points, s.config.HarshDriving)
+		safety := algorithm.ScoreSafety(events, tripStats.DistanceMeters)
+		trip.Safety = &safety
+	}
+
+	// Record the periods the raw points idled away from the terminals
+	if s.idle != nil {
+		idle, err := s.idle.Analyze(ctx, busMsg.CurrentRouteID, points)
+		if err != nil {
+			return classify(FailureMongo, err)
+		}
+		trip.Idle = idle
+	}
+
+	// Profile the passenger load from the counts buffered with the points
+	if s.loads != nil {
+		profile, err := s.loads.Profile(ctx, busMsg.CurrentRouteID, points)
+		if err != nil {
+			return classify(FailureMongo, err)
+		}
+		trip.LoadProfile = profile
+	}
+
+	if err := s.exportTrip(ctx, &trip, raw); err != nil {
+		return classify(FailureExport, err)
+	}
+
+	// Count the trip in the driver's shift before it is marked finalized,
+	// so a failure is retried; counting the same trip again does nothing
+	if s.shifts != nil {
+		if err := s.recordShiftTrip(ctx, trip, startTimestamp); err != nil {
+			return classify(FailureMongo, err)
+		}
+	}
+
+	// Upsert the simplified route and its finalization marker
+	insertCtx, insertSpan := tracer.Start(ctx, "mongo.insert")
+	insertStart := time.Now()
+	err = s.mongoCall(insertCtx, "mongo.insert", func() error {
+		return s.backends.Trips.SaveTrip(insertCtx, key, trip)
+	})
+	timer.since(stageMongoInsert, insertStart)
+	tracing.End(insertSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to store trip in MongoDB: %w", err)
+		return classify(FailureMongo, err)
+	}
+
+	slog.InfoContext(ctx, "Stored trip", "key", key, logging.Duration("durationMs", time.Since(timer.start)))
+	event := s.events.TripFinished(ctx, trip, busMsg)
+	if s.webhooks != nil {
+		s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.RouteID, Timestamp: event.Timestamp, Key: event.TripID}, event)
+	}
+
+	return s.clearRoute(ctx, key, buffered[len(buffered)-1].ID)
+}
+
+// exportTrip stores the raw points of a trip wherever they are kept besides
+// the trip itself, linking the archived trace from the trip
+func (s *DataIngestionService) exportTrip(ctx context.Context, trip *types.Trip, points []types.TrackPoint) (err error) {
+	ctx, span := tracer.Start(ctx, "export", trace.WithAttributes(attribute.Int("sinks", len(s.backends.Sinks))))
+	defer func() { tracing.End(span, err) }()
+
+	// Raw points are kept and archived unless the flag turns that off
+	archival := s.FeatureEnabled(types.FeatureRawRouteArchival)
+
+	// Keep the unsimplified points so the trip can be re-simplified later
+	if s.config.RawRoutes.Enabled && archival {
+		if err := s.backends.Trips.SaveRawRoute(ctx, *trip, points); err != nil {
+			return err
+		}
 	}
 
-	log.Printf("Stored trip for key %s in MongoDB", key)
+	// Archive the raw trace to object storage and link it from the trip
+	if s.backends.Archive != nil && archival {
+		url, err := s.backends.Archive.ArchiveRoute(ctx, *trip, points)
+		if err != nil {
+			return err
+		}
+		trip.RawArchiveURL = url
+	}
 
-	// Delete the Redis key
-	err = s.dbManager.RedisClient.Del(s.ctx, key).Err()
+	// Feed the raw points to the configured trip sinks
+	for _, sink := range s.backends.Sinks {
+		if err := sink.WriteTrip(ctx, *trip, points); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearRoute removes the finalized points, up to lastID, from Redis. Points
+// that arrived after they were read stay buffered instead of being dropped.
+// Clearing is idempotent, so transient failures are retried with backoff.
+func (s *DataIngestionService) clearRoute(ctx context.Context, key, lastID string) (err error) {
+	ctx, span := tracer.Start(ctx, "redis.clear")
+	defer func() { tracing.End(span, err) }()
+
+	var remaining int64
+	err = s.redisCall(ctx, "redis.clear", func() (err error) {
+		remaining, err = s.backends.Buffer.ClearPoints(ctx, key, lastID)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete key from Redis: %w", err)
+		return classify(FailureRedis, fmt.Errorf("failed to clear key from Redis: %w", err))
+	}
+
+	if remaining > 0 {
+		slog.InfoContext(ctx, "Cleared finalized route data, later points remain buffered", "key", key, "remaining", remaining)
+		return nil
 	}
 
-	log.Printf("Cleared route data for key %s from Redis", key)
+	slog.DebugContext(ctx, "Cleared route data", "key", key)
+
+	s.resetRouteDetectors(key)
 	return nil
 }
 
-// GetHealthStatus returns the health status of all components
-func (s *DataIngestionService) GetHealthStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"service":   "running",
-		"databases": s.dbManager.IsHealthy(),
+// resetRouteDetectors drops the per-route state of every detector, so the
+// next point of the route starts afresh
+func (s *DataIngestionService) resetRouteDetectors(key string) {
+	if s.deviation != nil {
+		s.deviation.Reset(key)
+	}
+	if s.geofences != nil {
+		s.geofences.Reset(key)
+	}
+	if s.speeding != nil {
+		s.speeding.Reset(key)
+	}
+	if s.stopEvents != nil {
+		s.stopEvents.Reset(key)
+	}
+	if s.headways != nil {
+		s.headways.Reset(key)
+	}
+	if s.segmenter != nil {
+		s.segmenter.Reset(key)
+	}
+	if s.odometer != nil {
+		s.odometer.Reset(key)
+	}
+	if s.eta != nil {
+		s.eta.Reset(key)
+	}
+}
+
+// watchFleetEvents delivers the fleet events announced by any instance to
+// the subscribers of this one, until ctx is done
+func (s *DataIngestionService) watchFleetEvents(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	if err := s.backends.FleetEvents.WatchFleetEvents(ctx, s.events.deliver); err != nil {
+		slog.Error("Error watching fleet events", "error", err)
+	}
+}
+
+// watchExpiredRoutes resets the state of route buffers that expired before
+// a "finished" message arrived, counting and logging them on the leader
+func (s *DataIngestionService) watchExpiredRoutes(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	err := s.backends.Buffer.WatchExpiredRoutes(ctx, func(key string) {
+		// Every instance receives every expiry, so only the leader counts
+		// it, while each one drops its own in-memory state of the route
+		if s.leads() {
+			metrics.RouteBuffersExpired.Add(1)
+			slog.Warn("Route buffer expired before it was finalized", "key", key)
+		}
+
+		s.resetRouteDetectors(key)
+	})
+	if err != nil {
+		slog.Error("Error watching expired route buffers", "error", err)
+	}
+}
+
+// GetHealthStatus returns the health status of all components, pinging the
+// backends under ctx
+func (s *DataIngestionService) GetHealthStatus(ctx context.Context) map[string]interface{} {
+	status := map[string]interface{}{
+		"service":     "running",
+		"instance_id": s.config.InstanceID,
+		"databases":   s.backends.Health(ctx),
 		"config": map[string]interface{}{
-			"tolerance": s.simplifier.GetTolerance(),
+			"tolerance":  s.simplifier.GetTolerance(),
+			"algorithm":  s.simplifier.GetAlgorithm(),
 			"mqtt_topic": s.config.MQTT.Topic,
 		},
+		"ingest": map[string]interface{}{
+			"workers":     s.config.Ingest.Workers,
+			"queue_depth": s.ingest.QueueDepth(),
+			"queue_size":  s.config.Ingest.QueueSize,
+			"overflow":    s.config.Ingest.Overflow,
+		},
+		"finalization": map[string]interface{}{
+			"workers":     s.config.Finalization.Workers,
+			"queue_depth": s.finalizer.QueueDepth(),
+			"queue_size":  s.config.Finalization.QueueSize,
+		},
+		"lag":              s.lagStatus(),
+		"circuit_breakers": s.breakerStatus(),
+		"feature_flags":    s.FeatureFlags(),
+	}
+	if s.config.Standby.Enabled {
+		status["standby"] = map[string]interface{}{
+			"active": s.active.Load(),
+		}
 	}
+	return status
+}
+
+// ComponentHealth reports the connectivity of each storage and broker
+// backend, pinging them under ctx
+func (s *DataIngestionService) ComponentHealth(ctx context.Context) map[string]bool {
+	return s.backends.Health(ctx)
 }
 
 // UpdateTolerance allows updating the route simplification tolerance
 func (s *DataIngestionService) UpdateTolerance(newTolerance float64) {
 	s.simplifier.SetTolerance(newTolerance)
-	log.Printf("Updated route simplification tolerance to: %f", newTolerance)
+	slog.Info("Updated route simplification tolerance", "tolerance", newTolerance)
 }
 
-// Close gracefully closes the service
+// Close gracefully closes the service. It stops accepting MQTT messages,
+// gives queued messages and finalizations up to the drain timeout to
+// finish, flushes the batch writers, and then closes the connections.
+// Closing more than once returns the first result.
 func (s *DataIngestionService) Close() error {
-	log.Println("Shutting down data ingestion service...")
-	return s.dbManager.Close()
-} 
\ No newline at end of file
+	s.closeOnce.Do(func() {
+		s.closeErr = s.shutdown()
+	})
+	return s.closeErr
+}
+
+// shutdown stops the service in dependency order
+func (s *DataIngestionService) shutdown() error {
+	slog.Info("Shutting down data ingestion service", "drainTimeout", s.config.Shutdown.DrainTimeout)
+
+	// Stop new messages from arriving
+	if err := s.backends.Broker.UnsubscribeFromTopic(s.config.MQTT.Topic); err != nil {
+		slog.Warn("Error unsubscribing from MQTT topic", "error", err)
+	}
+
+	s.stopBackground()
+	s.backgroundDone.Wait()
+
+	// Queued messages may still hand finished trips to the finalizer, so
+	// they drain first, within one shared deadline
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.config.Shutdown.DrainTimeout)
+	defer cancel()
+	start := time.Now()
+	ingestErr := s.ingest.Drain(drainCtx)
+	if ingestErr != nil {
+		slog.Warn("Queued messages did not drain before shutdown", "error", ingestErr)
+	}
+	finalizeErr := s.finalizer.Drain(drainCtx)
+	if finalizeErr != nil {
+		slog.Warn("Trip finalizations did not drain before shutdown", "queued", s.finalizer.QueueDepth(), "error", finalizeErr)
+	}
+	if ingestErr == nil && finalizeErr == nil {
+		slog.Info("Drained queued messages and finalizations", logging.Duration("durationMs", time.Since(start)))
+	}
+
+	// Hand this instance's drivers to the others once its messages are done
+	if s.partitioner != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), partitionLeaveTimeout)
+		if err := s.partitioner.Leave(ctx); err != nil {
+			slog.Warn("Error leaving the cluster", "error", err)
+		}
+		cancel()
+	}
+
+	// Let a standby take over without waiting for the lease to expire
+	s.releaseActive()
+
+	// Deliver queued webhooks while the dead letter store is still open
+	if s.webhooks != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDrainTimeout)
+		if err := s.webhooks.Close(ctx); err != nil {
+			slog.Warn("Webhook deliveries did not drain before shutdown", "error", err)
+		}
+		cancel()
+	}
+	return s.backends.Close()
+}
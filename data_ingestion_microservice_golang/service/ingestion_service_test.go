@@ -0,0 +1,849 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/breaker"
+	"data-ingestion-microservice/config"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// memoryBackend is an in-memory implementation of every service backend
+type memoryBackend struct {
+	mu     sync.Mutex
+	nextID int
+	routes map[string][]database.BufferedPoint
+	trips  map[string]types.Trip
+
+	simplification *types.SimplificationSettings
+	live           map[string]types.LivePosition
+	audit          []types.AuditEntry
+	appendErr      error
+	subscribed     []string
+	unsubscribed   []string
+	redeliveries   int
+	// transientAppends is the number of appends failing with a reset
+	// connection before appends succeed
+	transientAppends int
+	checkpoints      map[string]types.FinalizationCheckpoint
+	// checkpointed lists the keys of every finalization begun
+	checkpointed []string
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		routes: make(map[string][]database.BufferedPoint),
+		trips:  make(map[string]types.Trip),
+		live:   make(map[string]types.LivePosition),
+
+		checkpoints: make(map[string]types.FinalizationCheckpoint),
+	}
+}
+
+func (m *memoryBackend) backends() database.Backends {
+	return database.Backends{
+		Buffer:        m,
+		Trips:         m,
+		Finalizations: m,
+		Checkpoints:   m,
+		Live:          m,
+		PlannedRoutes: m,
+		Settings:      m,
+		Erasers:       []database.DriverDataEraser{m},
+		Audit:         m,
+		Broker:        m,
+		Health:        func(ctx context.Context) map[string]bool { return map[string]bool{"memory": true} },
+		Close:         func() error { return nil },
+	}
+}
+
+func (m *memoryBackend) AppendPoint(ctx context.Context, key string, point types.TrackPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.appendErr != nil {
+		return m.appendErr
+	}
+	if m.transientAppends > 0 {
+		m.transientAppends--
+		return fmt.Errorf("xadd: %w", syscall.ECONNRESET)
+	}
+	m.nextID++
+	m.routes[key] = append(m.routes[key], database.BufferedPoint{ID: strconv.Itoa(m.nextID), Point: point})
+	return nil
+}
+
+func (m *memoryBackend) FlushPoints(ctx context.Context) {}
+
+func (m *memoryBackend) ReadPoints(ctx context.Context, key string) ([]database.BufferedPoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]database.BufferedPoint(nil), m.routes[key]...), nil
+}
+
+func (m *memoryBackend) ClearPoints(ctx context.Context, key, lastID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	points := m.routes[key]
+	for i, point := range points {
+		if point.ID == lastID {
+			points = points[i+1:]
+			break
+		}
+	}
+	if len(points) == 0 {
+		delete(m.routes, key)
+	} else {
+		m.routes[key] = points
+	}
+	return int64(len(points)), nil
+}
+
+func (m *memoryBackend) WatchExpiredRoutes(ctx context.Context, handler func(key string)) error {
+	<-ctx.Done()
+	return nil
+}
+
+// IdleRoutes treats every buffered route as idle
+func (m *memoryBackend) IdleRoutes(ctx context.Context, idleFor time.Duration) ([]database.IdleRoute, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var idle []database.IdleRoute
+	for key, points := range m.routes {
+		if len(points) > 0 {
+			last := points[len(points)-1]
+			idle = append(idle, database.IdleRoute{Key: key, LastID: last.ID, Last: last.Point})
+		}
+	}
+	return idle, nil
+}
+
+func (m *memoryBackend) IsFinalized(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.trips[id]
+	return ok, nil
+}
+
+func (m *memoryBackend) SaveTrip(ctx context.Context, key string, trip types.Trip) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.trips[trip.ID] = trip
+	return nil
+}
+
+func (m *memoryBackend) SaveRawRoute(ctx context.Context, trip types.Trip, points []types.TrackPoint) error {
+	return nil
+}
+
+func (m *memoryBackend) EnqueueFinalization(ctx context.Context, busMsg types.BusMessage) error {
+	return nil
+}
+
+func (m *memoryBackend) ReadFinalizations(ctx context.Context, consumer string, count int64, block time.Duration) ([]database.FinalizationEntry, error) {
+	return nil, nil
+}
+
+func (m *memoryBackend) ClaimStaleFinalizations(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]database.FinalizationEntry, error) {
+	return nil, nil
+}
+
+func (m *memoryBackend) AckFinalization(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *memoryBackend) FinalizationBacklog(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryBackend) BeginFinalization(ctx context.Context, checkpoint types.FinalizationCheckpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkpoints[checkpoint.Key] = checkpoint
+	m.checkpointed = append(m.checkpointed, checkpoint.Key)
+	return nil
+}
+
+func (m *memoryBackend) EndFinalization(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.checkpoints, key)
+	return nil
+}
+
+func (m *memoryBackend) PendingFinalizations(ctx context.Context) ([]types.FinalizationCheckpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []types.FinalizationCheckpoint
+	for _, checkpoint := range m.checkpoints {
+		pending = append(pending, checkpoint)
+	}
+	return pending, nil
+}
+
+func (m *memoryBackend) UpdateLivePosition(ctx context.Context, busMsg types.BusMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live[busMsg.DriverID] = types.LivePosition{
+		DriverID:       busMsg.DriverID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Status:         busMsg.Status,
+		Location:       busMsg.DriverLocation,
+		Timestamp:      busMsg.Timestamp,
+		UpdatedAt:      time.Now().UnixMilli(),
+	}
+	return nil
+}
+
+func (m *memoryBackend) PublishLiveLocation(ctx context.Context, busMsg types.BusMessage) error {
+	return nil
+}
+
+func (m *memoryBackend) LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	position, ok := m.live[driverID]
+	if !ok {
+		return nil, nil
+	}
+	return &position, nil
+}
+
+func (m *memoryBackend) RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var positions []types.LivePosition
+	for _, position := range m.live {
+		if position.CurrentRouteID == routeID {
+			positions = append(positions, position)
+		}
+	}
+	return positions, nil
+}
+
+func (m *memoryBackend) FleetPositions(ctx context.Context) ([]types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var positions []types.LivePosition
+	for _, position := range m.live {
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+func (m *memoryBackend) NearbyVehicles(ctx context.Context, location types.Location, radiusMeters float64, count int) ([]types.LivePosition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var positions []types.LivePosition
+	for _, position := range m.live {
+		position.DistanceMeters = algorithm.HaversineDistance(location, position.Location)
+		if position.DistanceMeters <= radiusMeters {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].DistanceMeters < positions[j].DistanceMeters })
+	return positions[:min(count, len(positions))], nil
+}
+
+func (m *memoryBackend) DeleteDriverData(ctx context.Context, driverID string) (types.DriverDeletionReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report := types.DriverDeletionReport{DriverID: driverID}
+	for key := range m.routes {
+		if strings.HasPrefix(key, database.RouteKey(driverID, "")) {
+			delete(m.routes, key)
+			report.RouteBuffers++
+		}
+	}
+	for id, trip := range m.trips {
+		if trip.DriverID == driverID {
+			delete(m.trips, id)
+			report.Trips++
+		}
+	}
+	if _, ok := m.live[driverID]; ok {
+		delete(m.live, driverID)
+		report.LivePositions++
+	}
+	return report, nil
+}
+
+func (m *memoryBackend) RecordAudit(ctx context.Context, entry types.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+func (m *memoryBackend) AuditEntries(ctx context.Context, query types.AuditQuery) ([]types.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]types.AuditEntry, 0, len(m.audit))
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if query.Action == "" || m.audit[i].Action == query.Action {
+			entries = append(entries, m.audit[i])
+		}
+	}
+	return entries, nil
+}
+
+func (m *memoryBackend) FindPlannedRoute(ctx context.Context, routeID string) (*types.PlannedRoute, error) {
+	return nil, nil
+}
+
+func (m *memoryBackend) LoadSimplificationSettings(ctx context.Context) (*types.SimplificationSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.simplification, nil
+}
+
+func (m *memoryBackend) SaveSimplificationSettings(ctx context.Context, settings types.SimplificationSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.simplification = &settings
+	return nil
+}
+
+func (m *memoryBackend) SubscribeToTopic(topic string, handler mqtt.MessageHandler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribed = append(m.subscribed, topic)
+	return nil
+}
+
+func (m *memoryBackend) UnsubscribeFromTopic(topic string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unsubscribed = append(m.unsubscribed, topic)
+	return nil
+}
+
+func (m *memoryBackend) PublishMessage(topic string, payload []byte) error {
+	return nil
+}
+
+func (m *memoryBackend) Redeliver() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redeliveries++
+	return nil
+}
+
+// newTestService creates a service over the memory backend with the default
+// config, after each configure function has adjusted the config and backends
+func newTestService(t *testing.T, backend *memoryBackend, configure ...func(cfg *types.Config, backends *database.Backends)) *DataIngestionService {
+	t.Helper()
+
+	cfg, backends := config.LoadConfig(), backend.backends()
+	for _, fn := range configure {
+		fn(&cfg, &backends)
+	}
+	service, err := NewDataIngestionServiceWithBackends(context.Background(), cfg, backends)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+	return service
+}
+
+func TestHandleFinished_StoresTripAndClearsBuffer(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995210000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995220000,"driverLocation":{"latitude":6.2460,"longitude":-75.5830}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995230000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		if trip.OriginalPointsCount != 3 {
+			t.Errorf("Expected 3 original points, got %d", trip.OriginalPointsCount)
+		}
+		route := trip.SimplifiedRoute
+		if len(route) < 2 || route[0].Timestamp != 1640995200000 || route[len(route)-1].Timestamp != 1640995220000 {
+			t.Errorf("Expected the simplified route stamped with the device timestamps, got %+v", route)
+		}
+	}
+	if _, ok := backend.routes[key]; ok {
+		t.Errorf("Expected route buffer %s to be cleared", key)
+	}
+}
+
+func TestHandleFinished_CarriesTelemetryIntoTrip(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812},"altitude":1495,"speed":0,"battery":80,"satellites":9}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995210000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995220000,"driverLocation":{"latitude":6.2460,"longitude":-75.5830},"altitude":1510,"speed":32.5,"battery":79,"satellites":7,"heading":315}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	if point := backend.routes[key][0].Point; point.SpeedKmh == nil || *point.SpeedKmh != 0 || point.Heading != nil {
+		t.Errorf("Expected the buffered point to keep the telemetry sent, got %+v", point.Telemetry)
+	}
+
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995230000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, trip := range backend.trips {
+		telemetry := trip.Telemetry
+		if telemetry == nil || telemetry.Points != 2 {
+			t.Fatalf("Expected telemetry summarized from 2 points, got %+v", telemetry)
+		}
+		if *telemetry.MaxAltitudeMeters != 1510 || *telemetry.MaxSpeedKmh != 32.5 || *telemetry.MinSatellites != 7 ||
+			*telemetry.BatteryStartPercent != 80 || *telemetry.BatteryEndPercent != 79 || telemetry.AvgAccuracyMeters != nil {
+			t.Errorf("Unexpected telemetry summary %+v", telemetry)
+		}
+	}
+}
+
+func TestProcessMessage_AcknowledgesFinishedRouteOnceFinalized(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	acked := make(chan string, 4)
+	ack := func(name string) func() {
+		return func() { acked <- name }
+	}
+	process := func(name, message string) {
+		t.Helper()
+		if err := service.processMessage(context.Background(), []byte(message), ack(name)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	process("point", `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`)
+	if name := <-acked; name != "point" {
+		t.Fatalf("Expected the point to be acknowledged once stored, got %q", name)
+	}
+
+	// A redelivered "finished" message finds the trip already stored
+	for i := 0; i < 2; i++ {
+		process("finished", `{"driverId":"driver-1","currentRouteId":"route-1","status":"finished","timestamp":1640995230000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`)
+		select {
+		case <-acked:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the finished route to be acknowledged")
+		}
+
+		backend.mu.Lock()
+		trips := len(backend.trips)
+		backend.mu.Unlock()
+		if trips != 1 {
+			t.Fatalf("Expected the trip to be stored once before the acknowledgment, got %d", trips)
+		}
+	}
+}
+
+func TestHandleFinished_KeepsPointsArrivingAfterRead(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	key := database.RouteKey("driver-1", "route-1")
+	point := types.TrackPoint{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000}
+	backend.AppendPoint(context.Background(), key, point)
+	backend.AppendPoint(context.Background(), key, point)
+
+	buffered, _ := backend.ReadPoints(context.Background(), key)
+	backend.AppendPoint(context.Background(), key, point)
+
+	if err := service.clearRoute(context.Background(), key, buffered[len(buffered)-1].ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if remaining := len(backend.routes[key]); remaining != 1 {
+		t.Errorf("Expected 1 point to remain buffered, got %d", remaining)
+	}
+}
+
+func TestUpdateSimplification_PersistsOverride(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	previous := service.SimplificationSettings()
+	settings, err := service.UpdateSimplification(context.Background(), types.SimplificationSettings{
+		Tolerance: 0.0005,
+		Algorithm: algorithm.AlgorithmVisvalingamWhyatt,
+	}, "admin")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if settings.Tolerance != 0.0005 || settings.Algorithm != algorithm.AlgorithmVisvalingamWhyatt {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+
+	// The change is audited with the previous and new settings
+	page, err := service.AuditLog(context.Background(), types.AuditQuery{Action: AuditActionUpdateSimplification})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Entries) != 1 {
+		t.Fatalf("Expected one audit entry, got %+v", page.Entries)
+	}
+	entry := page.Entries[0]
+	if entry.Actor != "admin" || entry.OldValue != previous || entry.NewValue != settings {
+		t.Errorf("Unexpected audit entry %+v", entry)
+	}
+
+	// A restarted service picks up the persisted override
+	restarted := newTestService(t, backend)
+	if got := restarted.SimplificationSettings(); got.Tolerance != 0.0005 || got.Algorithm != algorithm.AlgorithmVisvalingamWhyatt {
+		t.Errorf("Expected override to be loaded on startup, got %+v", got)
+	}
+}
+
+func TestUpdateSimplification_RejectsInvalidSettings(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	updates := []types.SimplificationSettings{
+		{Tolerance: -1},
+		{Algorithm: "bogus"},
+	}
+	for _, update := range updates {
+		if _, err := service.UpdateSimplification(context.Background(), update, "admin"); !errors.Is(err, ErrInvalidSimplification) {
+			t.Errorf("Expected ErrInvalidSimplification for %+v, got %v", update, err)
+		}
+	}
+	if backend.simplification != nil || len(backend.audit) != 0 {
+		t.Errorf("Expected invalid settings not to be persisted or audited")
+	}
+}
+
+func TestLivePosition_MarksStalePositions(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`
+	if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	position, err := service.LivePosition(context.Background(), "driver-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if position.Stale {
+		t.Errorf("Expected a fresh position, got %+v", position)
+	}
+
+	backend.mu.Lock()
+	old := backend.live["driver-1"]
+	old.UpdatedAt = time.Now().Add(-time.Hour).UnixMilli()
+	backend.live["driver-1"] = old
+	backend.mu.Unlock()
+
+	positions, err := service.RoutePositions(context.Background(), "route-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(positions) != 1 || !positions[0].Stale {
+		t.Errorf("Expected one stale position, got %+v", positions)
+	}
+
+	snapshot, err := service.FleetSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if snapshot.Total != 1 || snapshot.Stale != 1 || !snapshot.Vehicles[0].Stale || snapshot.GeneratedAt == 0 {
+		t.Errorf("Expected one stale vehicle in the fleet, got %+v", snapshot)
+	}
+
+	if _, err := service.LivePosition(context.Background(), "driver-2"); !errors.Is(err, ErrDriverNotFound) {
+		t.Errorf("Expected ErrDriverNotFound, got %v", err)
+	}
+}
+
+func TestNearestVehicles_SkipsFinishedAndStaleDrivers(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	now := time.Now().UnixMilli()
+	backend.live = map[string]types.LivePosition{
+		"finished": {DriverID: "finished", Status: "finished", Location: types.Location{Latitude: 6.2443, Longitude: -75.5812}, UpdatedAt: now},
+		"stale":    {DriverID: "stale", Status: "in_route", Location: types.Location{Latitude: 6.2444, Longitude: -75.5812}, UpdatedAt: now - time.Hour.Milliseconds()},
+		"north":    {DriverID: "north", Status: "in_route", Location: types.Location{Latitude: 6.2460, Longitude: -75.5812}, UpdatedAt: now},
+		"east":     {DriverID: "east", Status: "in_route", Location: types.Location{Latitude: 6.2442, Longitude: -75.5700}, UpdatedAt: now},
+		"far":      {DriverID: "far", Status: "in_route", Location: types.Location{Latitude: 6.3000, Longitude: -75.5812}, UpdatedAt: now},
+	}
+
+	origin := types.Location{Latitude: 6.2442, Longitude: -75.5812}
+	vehicles, err := service.NearestVehicles(context.Background(), origin, 2, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(vehicles) != 2 || vehicles[0].DriverID != "north" || vehicles[1].DriverID != "east" {
+		t.Fatalf("Expected north and east, got %+v", vehicles)
+	}
+	if vehicles[0].DistanceMeters < 190 || vehicles[0].DistanceMeters > 210 {
+		t.Errorf("Expected about 200m to north, got %v", vehicles[0].DistanceMeters)
+	}
+	if vehicles[0].Bearing == nil || *vehicles[0].Bearing > 1 || vehicles[1].Bearing == nil || *vehicles[1].Bearing < 89 || *vehicles[1].Bearing > 91 {
+		t.Errorf("Expected bearings of about 0 and 90 degrees, got %+v", vehicles)
+	}
+
+	vehicles, err = service.NearestVehicles(context.Background(), origin, 5, 1000)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].DriverID != "north" {
+		t.Errorf("Expected only north within 1km, got %+v", vehicles)
+	}
+}
+
+func TestDeleteDriverData_RemovesDataAndRecordsAudit(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	for _, driverID := range []string{"driver-1", "driver-2"} {
+		message := `{"driverId":"` + driverID + `","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	backend.trips["trip-1"] = types.Trip{ID: "trip-1", DriverID: "driver-1"}
+
+	report, err := service.DeleteDriverData(context.Background(), "driver-1", "admin")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Trips != 1 || report.RouteBuffers != 1 || report.LivePositions != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	if _, err := service.LivePosition(context.Background(), "driver-1"); !errors.Is(err, ErrDriverNotFound) {
+		t.Errorf("Expected the live position to be deleted, got %v", err)
+	}
+	if _, err := service.LivePosition(context.Background(), "driver-2"); err != nil {
+		t.Errorf("Expected other drivers to be kept, got %v", err)
+	}
+
+	if len(backend.audit) != 1 {
+		t.Fatalf("Expected one audit entry, got %d", len(backend.audit))
+	}
+	entry := backend.audit[0]
+	if entry.Action != AuditActionDeleteDriverData || entry.Actor != "admin" || entry.Target != "driver-1" {
+		t.Errorf("Unexpected audit entry %+v", entry)
+	}
+}
+
+func TestProcessMessage_RetriesTransientBufferErrors(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+	retries := retryCount("redis.write")
+
+	backend.transientAppends = 2
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`
+	if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+		t.Fatalf("Expected the append to succeed on retry, got %v", err)
+	}
+
+	if points := backend.routes[database.RouteKey("driver-1", "route-1")]; len(points) != 1 {
+		t.Errorf("Expected 1 buffered point, got %d", len(points))
+	}
+	if got := retryCount("redis.write") - retries; got != 2 {
+		t.Errorf("Expected 2 retries, got %d", got)
+	}
+}
+
+// retryCount returns the number of retries of an operation
+func retryCount(operation string) int64 {
+	if count, ok := metrics.Retries.Get(operation).(*expvar.Int); ok {
+		return count.Value()
+	}
+	return 0
+}
+
+func TestProcessMessage_AcknowledgesOnlyHandledMessages(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Retry.Attempts = 1
+	})
+
+	location := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`
+	tests := []struct {
+		name      string
+		payload   string
+		transient int
+		acked     bool
+	}{
+		{"processed", location, 0, true},
+		{"malformed", `{"driverId":`, 0, true},
+		{"invalid", `{"driverId":"driver-1","status":"in_route"}`, 0, true},
+		{"failed on Redis", location, 1, false},
+	}
+	for _, test := range tests {
+		backend.transientAppends = test.transient
+		acked := false
+		service.processMessage(context.Background(), []byte(test.payload), func() { acked = true })
+		if acked != test.acked {
+			t.Errorf("%s: expected acknowledged %v, got %v", test.name, test.acked, acked)
+		}
+	}
+}
+
+func TestHandleMessage_RetriesBackendFailuresFromSpill(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Retry.Attempts = 1
+		cfg.CircuitBreaker.FailureThreshold = 1000
+		cfg.Ingest.Overflow = OverflowSpill
+		cfg.Ingest.SpillPath = filepath.Join(t.TempDir(), "spill.db")
+	})
+	service.ingest.backoffMu.Lock()
+	service.ingest.minRetryDelay, service.ingest.maxRetryDelay = 10*time.Millisecond, 50*time.Millisecond
+	service.ingest.backoffMu.Unlock()
+
+	// An outage longer than the retries leaves no message unacknowledged,
+	// so the broker's in-flight window never fills up
+	backend.mu.Lock()
+	backend.appendErr = fmt.Errorf("xadd: %w", syscall.ECONNRESET)
+	backend.mu.Unlock()
+	var acked sync.WaitGroup
+	const messages = 50
+	acked.Add(messages)
+	for i := range messages {
+		message := fmt.Sprintf(`{"driverId":"driver-%d","currentRouteId":"route-1","status":"in_route","timestamp":%d,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`, i%5, 1640995200000+i)
+		service.handleMessage("drivers_location", []byte(message), acked.Done)
+	}
+	acked.Wait()
+	if stranded := service.stranded.Load(); stranded != 0 {
+		t.Errorf("Expected no stranded messages with a spill buffer, got %d", stranded)
+	}
+
+	// The spilled messages are retried once the backend recovers
+	backend.mu.Lock()
+	backend.appendErr = nil
+	backend.mu.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend.mu.Lock()
+		var buffered int
+		for _, points := range backend.routes {
+			buffered += len(points)
+		}
+		backend.mu.Unlock()
+		if buffered == messages {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the %d retried messages to be buffered, got %d", messages, buffered)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedeliver_ReconnectsForStrandedMessages(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Retry.Attempts = 1
+		cfg.CircuitBreaker.FailureThreshold = 1000
+	})
+
+	backend.appendErr = fmt.Errorf("xadd: %w", syscall.ECONNRESET)
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`
+	acked := false
+	service.handleMessage("drivers_location", []byte(message), func() { acked = true })
+	if acked || service.stranded.Load() != 1 {
+		t.Fatalf("Expected one stranded message without a spill buffer, got acknowledged %v and %d stranded", acked, service.stranded.Load())
+	}
+
+	service.redeliver()
+	service.redeliver()
+	if backend.redeliveries != 1 || service.stranded.Load() != 0 {
+		t.Errorf("Expected one reconnect for the stranded message, got %d with %d stranded", backend.redeliveries, service.stranded.Load())
+	}
+}
+
+func TestProcessMessage_FailsFastWhileRedisIsDown(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Retry.Attempts = 1
+		cfg.CircuitBreaker.FailureThreshold = 1
+	})
+
+	backend.transientAppends = 2
+	message := []byte(`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`)
+	if err := service.processMessage(context.Background(), message, nil); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("Expected the append to fail, got %v", err)
+	}
+
+	err := service.processMessage(context.Background(), message, nil)
+	if !errors.Is(err, breaker.ErrOpen) || failureClass(err) != FailureRedis {
+		t.Errorf("Expected an open Redis breaker, got %v (%s)", err, failureClass(err))
+	}
+	if backend.transientAppends != 1 {
+		t.Errorf("Expected Redis not to be called while the breaker is open")
+	}
+	if status := service.GetHealthStatus(context.Background())["circuit_breakers"].(map[string]interface{}); status["redis"] != "open" {
+		t.Errorf("Expected the health status to report the open breaker, got %v", status)
+	}
+}
+
+func TestClose_DrainsQueuedMessages(t *testing.T) {
+	backend := newMemoryBackend()
+	service, err := NewDataIngestionServiceWithBackends(context.Background(), config.LoadConfig(), backend.backends())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		message := fmt.Sprintf(`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":%d,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`, 1640995200000+i)
+		service.ingest.Submit("drivers_location/driver-1", []byte(message), nil)
+	}
+	if err := service.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if points := backend.routes[database.RouteKey("driver-1", "route-1")]; len(points) != 50 {
+		t.Errorf("Expected every queued message to be processed, got %d points", len(points))
+	}
+	if len(backend.unsubscribed) != 1 {
+		t.Errorf("Expected to unsubscribe once before draining, got %v", backend.unsubscribed)
+	}
+
+	// Closing again is a no-op, and late messages are discarded
+	service.ingest.Submit("drivers_location/driver-1", []byte(`{}`), nil)
+	if err := service.Close(); err != nil || len(backend.unsubscribed) != 1 {
+		t.Errorf("Expected a second close to do nothing, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// statusAutoClosed is the status of trips the janitor finalized because
+// their device stopped reporting before sending a "finished" message
+const statusAutoClosed = "auto_closed"
+
+// runRouteJanitor finalizes abandoned routes every janitor interval until
+// ctx is done. With partitioning, only the cluster leader sweeps the routes.
+func (s *DataIngestionService) runRouteJanitor(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(s.config.Janitor.Interval)
+	defer ticker.Stop()
+
+	// closing maps the routes handed to finalization to their newest point,
+	// so a route still waiting to be finalized is not closed twice
+	closing := make(map[string]string)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.leads() {
+				clear(closing)
+				continue
+			}
+			if err := s.closeIdleRoutes(ctx, closing); err != nil && ctx.Err() == nil {
+				slog.Error("Error closing idle routes", "error", err)
+			}
+		}
+	}
+}
+
+// closeIdleRoutes hands the routes that have not received points for the
+// janitor's idle time to finalization as auto-closed trips
+func (s *DataIngestionService) closeIdleRoutes(ctx context.Context, closing map[string]string) error {
+	var idle []database.IdleRoute
+	err := s.redisCall(ctx, "redis.idle_routes", func() (err error) {
+		idle, err = s.backends.Buffer.IdleRoutes(ctx, s.config.Janitor.IdleAfter)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	stillIdle := make(map[string]bool, len(idle))
+	for _, route := range idle {
+		stillIdle[route.Key] = true
+		if lastID, ok := closing[route.Key]; ok && lastID == route.LastID {
+			continue
+		}
+		// Vehicle routes end with the driver of their last point
+		driverID, routeID, ok := database.ParseRouteKey(route.Key)
+		var vehicleID string
+		if !ok {
+			vehicleID, routeID, ok = database.ParseVehicleRouteKey(route.Key)
+			driverID = route.Last.DriverID
+		}
+		if !ok || driverID == "" {
+			slog.Warn("Skipping route buffer with an unexpected key", "key", route.Key)
+			continue
+		}
+		routeCtx := logging.With(ctx, "driverId", driverID, "routeId", routeID)
+
+		// The trip ends at the last point the device sent
+		timestamp := route.Last.Timestamp
+		if timestamp == 0 {
+			timestamp = uint64(route.LastSeen.UnixMilli())
+		}
+		busMsg := types.BusMessage{
+			DriverID:       driverID,
+			VehicleID:      vehicleID,
+			DriverLocation: route.Last.Location,
+			Timestamp:      timestamp,
+			CurrentRouteID: routeID,
+			Status:         statusAutoClosed,
+		}
+		slog.WarnContext(routeCtx, "Auto-closing idle route", "key", route.Key, "idleSeconds", int(time.Since(route.LastSeen).Seconds()))
+		if _, err := s.finishRoute(routeCtx, route.Key, busMsg, noAck); err != nil {
+			return err
+		}
+		closing[route.Key] = route.LastID
+		metrics.RoutesAutoClosed.Add(1)
+	}
+
+	// Forget routes that were finalized or received new points
+	for key := range closing {
+		if !stillIdle[key] {
+			delete(closing, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+func TestCloseIdleRoutes_FinalizesAutoClosedTrip(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995210000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// A second sweep before the trip is stored does not close it again
+	closed := metrics.RoutesAutoClosed.Value()
+	closing := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		if err := service.closeIdleRoutes(context.Background(), closing); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if got := metrics.RoutesAutoClosed.Value() - closed; got != 1 {
+		t.Errorf("Expected 1 auto-closed route, got %d", got)
+	}
+
+	var trip types.Trip
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend.mu.Lock()
+		trips, remaining := len(backend.trips), len(backend.routes)
+		for _, stored := range backend.trips {
+			trip = stored
+		}
+		backend.mu.Unlock()
+		if trips == 1 && remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the idle route to be finalized, got %d trips and %d buffered routes", trips, remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if trip.Status != statusAutoClosed || trip.DriverID != "driver-1" || trip.CurrentRouteID != "route-1" {
+		t.Errorf("Expected an auto-closed trip of driver-1 on route-1, got %+v", trip)
+	}
+	if trip.Timestamp != 1640995210000 || trip.OriginalPointsCount != 2 {
+		t.Errorf("Expected the trip to end at the last point, got timestamp %d with %d points", trip.Timestamp, trip.OriginalPointsCount)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// backlogSampleInterval is how often the finalization stream backlog is read
+const backlogSampleInterval = 10 * time.Second
+
+// observeLag records the delay between the device timestamp of a message
+// and now. Messages without a timestamp are skipped, and device clocks
+// running ahead of ours count as no lag.
+func observeLag(busMsg types.BusMessage, now time.Time) {
+	if busMsg.Timestamp == 0 {
+		return
+	}
+	lag := now.Sub(time.UnixMilli(int64(busMsg.Timestamp)))
+	if lag < 0 {
+		lag = 0
+	}
+	metrics.IngestLag.Observe(lag.Seconds())
+}
+
+// sampleFinalizationBacklog periodically publishes the number of
+// finalizations waiting in the consumer group stream. MQTT does not expose
+// the broker's queue, so the stream is the only backlog that can be read.
+func (s *DataIngestionService) sampleFinalizationBacklog(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(backlogSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		backlog, err := s.backends.Finalizations.FinalizationBacklog(ctx)
+		if err == nil {
+			metrics.FinalizationBacklog.Set(backlog)
+		} else if ctx.Err() == nil {
+			slog.Warn("Error reading finalization backlog", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lagStatus summarizes the ingestion lag and backlog for the health status
+func (s *DataIngestionService) lagStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"recent_seconds":     metrics.IngestLag.Recent(),
+		"messages_in_flight": metrics.IngestInFlight.Value(),
+	}
+	if s.config.Ingest.Overflow == OverflowSpill {
+		status["spill_backlog"] = metrics.IngestSpillBacklog.Value()
+	}
+	if s.config.Redis.FinalizeConsumerGroup {
+		status["finalization_backlog"] = metrics.FinalizationBacklog.Value()
+	}
+	return status
+}
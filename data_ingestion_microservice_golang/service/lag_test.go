@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// lagSummary returns the number and sum of observed lags
+func lagSummary(t *testing.T) (uint64, float64) {
+	t.Helper()
+	var summary struct {
+		Count uint64  `json:"count"`
+		Sum   float64 `json:"sum"`
+	}
+	if err := json.Unmarshal([]byte(metrics.IngestLag.String()), &summary); err != nil {
+		t.Fatalf("Expected JSON, got %q", metrics.IngestLag.String())
+	}
+	return summary.Count, summary.Sum
+}
+
+func TestObserveLag(t *testing.T) {
+	now := time.UnixMilli(1700000030000)
+	count, sum := lagSummary(t)
+
+	// Messages without a timestamp are not observed
+	observeLag(types.BusMessage{}, now)
+	observeLag(types.BusMessage{Timestamp: 1700000000000}, now)
+	// Device clocks ahead of ours count as no lag
+	observeLag(types.BusMessage{Timestamp: 1700000060000}, now)
+
+	gotCount, gotSum := lagSummary(t)
+	if gotCount-count != 2 {
+		t.Errorf("Expected 2 observations, got %d", gotCount-count)
+	}
+	if lag := gotSum - sum; lag < 29.9 || lag > 30.1 {
+		t.Errorf("Expected 30s of lag in total, got %v", lag)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/metrics"
+)
+
+// Stages timed while processing a message or finalizing a route
+const (
+	stageDecode      = "decode"
+	stageRedisWrite  = "redisWrite"
+	stageRedisRead   = "redisRead"
+	stageSimplify    = "simplify"
+	stageMongoInsert = "mongoInsert"
+)
+
+// stageHistograms maps each stage to its latency histogram
+var stageHistograms = map[string]*metrics.Histogram{
+	stageDecode:      metrics.DecodeLatency,
+	stageRedisWrite:  metrics.RedisWriteLatency,
+	stageRedisRead:   metrics.RedisReadLatency,
+	stageSimplify:    metrics.SimplifyLatency,
+	stageMongoInsert: metrics.MongoInsertLatency,
+}
+
+// stageDuration is how long one stage took
+type stageDuration struct {
+	stage    string
+	duration time.Duration
+}
+
+// stageTimer times the stages of processing a single message or
+// finalization. It is not safe for concurrent use.
+type stageTimer struct {
+	start  time.Time
+	stages []stageDuration
+}
+
+// newStageTimer starts timing a message or finalization
+func newStageTimer() *stageTimer {
+	return &stageTimer{start: time.Now()}
+}
+
+// since records the time elapsed since start as the duration of stage
+func (t *stageTimer) since(stage string, start time.Time) {
+	duration := time.Since(start)
+	stageHistograms[stage].Observe(duration.Seconds())
+	t.stages = append(t.stages, stageDuration{stage: stage, duration: duration})
+}
+
+// finish records the total duration in total, and logs a slow processing
+// warning with the per-stage breakdown when it exceeds threshold
+func (t *stageTimer) finish(ctx context.Context, operation string, total *metrics.Histogram, threshold time.Duration) {
+	elapsed := time.Since(t.start)
+	total.Observe(elapsed.Seconds())
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+
+	breakdown := make([]any, 0, len(t.stages))
+	for _, stage := range t.stages {
+		breakdown = append(breakdown, logging.Duration(stage.stage, stage.duration))
+	}
+	slog.WarnContext(ctx, "Slow processing",
+		"operation", operation,
+		logging.Duration("durationMs", elapsed),
+		slog.Group("stagesMs", breakdown...),
+	)
+}
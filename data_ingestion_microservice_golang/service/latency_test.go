@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/logging"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	logger, err := logging.New(types.LogConfig{Level: "info", Format: logging.FormatJSON}, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &out
+}
+
+func TestStageTimer_LogsSlowProcessing(t *testing.T) {
+	out := captureLogs(t)
+
+	timer := newStageTimer()
+	timer.start = time.Now().Add(-2 * time.Second)
+	timer.since(stageDecode, time.Now().Add(-time.Millisecond))
+	timer.since(stageRedisWrite, time.Now().Add(-1500*time.Millisecond))
+	timer.finish(context.Background(), "message", metrics.MessageLatency, time.Second)
+
+	var record struct {
+		Msg        string             `json:"msg"`
+		Operation  string             `json:"operation"`
+		DurationMs float64            `json:"durationMs"`
+		StagesMs   map[string]float64 `json:"stagesMs"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q", out.String())
+	}
+	if record.Msg != "Slow processing" || record.Operation != "message" || record.DurationMs < 2000 {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if record.StagesMs[stageDecode] < 1 || record.StagesMs[stageRedisWrite] < 1500 {
+		t.Errorf("Expected a per-stage breakdown, got %v", record.StagesMs)
+	}
+}
+
+func TestStageTimer_QuietUnderThreshold(t *testing.T) {
+	out := captureLogs(t)
+
+	for _, threshold := range []time.Duration{time.Minute, 0} {
+		timer := newStageTimer()
+		timer.start = time.Now().Add(-2 * time.Second)
+		timer.since(stageSimplify, time.Now())
+		timer.finish(context.Background(), "finalization", metrics.FinalizationLatency, threshold)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no slow processing warning, got %q", out.String())
+	}
+}
+
+func TestProcessMessage_ObservesStageLatency(t *testing.T) {
+	service := newTestService(t, newMemoryBackend())
+	decodes, writes := histogramCount(t, metrics.DecodeLatency), histogramCount(t, metrics.RedisWriteLatency)
+
+	payload := []byte(`{"driverId":"driver_001","currentRouteId":"route_123","status":"in_route","driverLocation":{"latitude":40.7,"longitude":-74}}`)
+	if err := service.processMessage(context.Background(), payload, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := histogramCount(t, metrics.DecodeLatency) - decodes; got != 1 {
+		t.Errorf("Expected 1 decode observation, got %d", got)
+	}
+	if got := histogramCount(t, metrics.RedisWriteLatency) - writes; got != 1 {
+		t.Errorf("Expected 1 Redis write observation, got %d", got)
+	}
+}
+
+// histogramCount returns the number of observations of a histogram
+func histogramCount(t *testing.T, histogram *metrics.Histogram) uint64 {
+	t.Helper()
+	var summary struct {
+		Count uint64 `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(histogram.String()), &summary); err != nil {
+		t.Fatalf("Expected JSON, got %q", histogram.String())
+	}
+	return summary.Count
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// earthHalfCircumferenceMeters is the search radius covering the whole earth
+const earthHalfCircumferenceMeters = 20_037_509
+
+// ErrLiveTrackingDisabled is returned for live reads when live positions are
+// not recorded
+var ErrLiveTrackingDisabled = errors.New("live positions are disabled")
+
+// ErrDriverNotFound is returned for drivers without a live position
+var ErrDriverNotFound = errors.New("no live position for driver")
+
+// LivePosition returns a driver's latest known position
+func (s *DataIngestionService) LivePosition(ctx context.Context, driverID string) (*types.LivePosition, error) {
+	if !s.config.Redis.LivePositions {
+		return nil, ErrLiveTrackingDisabled
+	}
+
+	position, err := s.backends.Live.LivePosition(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	if position == nil {
+		return nil, ErrDriverNotFound
+	}
+
+	s.markStale(position, time.Now())
+	return position, nil
+}
+
+// RoutePositions returns the latest positions of the drivers on a route
+func (s *DataIngestionService) RoutePositions(ctx context.Context, routeID string) ([]types.LivePosition, error) {
+	if !s.config.Redis.LivePositions {
+		return nil, ErrLiveTrackingDisabled
+	}
+
+	positions, err := s.backends.Live.RoutePositions(ctx, routeID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range positions {
+		s.markStale(&positions[i], now)
+	}
+	return positions, nil
+}
+
+// FleetSnapshot returns the latest positions of every active driver, with
+// the number of them that are stale
+func (s *DataIngestionService) FleetSnapshot(ctx context.Context) (types.FleetSnapshot, error) {
+	if !s.config.Redis.LivePositions {
+		return types.FleetSnapshot{}, ErrLiveTrackingDisabled
+	}
+
+	positions, err := s.backends.Live.FleetPositions(ctx)
+	if err != nil {
+		return types.FleetSnapshot{}, err
+	}
+
+	now := time.Now()
+	snapshot := types.FleetSnapshot{Vehicles: positions, GeneratedAt: now.UnixMilli()}
+	for i := range positions {
+		s.markStale(&positions[i], now)
+		if positions[i].Stale {
+			snapshot.Stale++
+		}
+	}
+	snapshot.Total = len(positions)
+	return snapshot, nil
+}
+
+// NearestVehicles returns the n active drivers nearest to a location within
+// radiusMeters, or anywhere when it is zero, with their distance and bearing
+// from it. Finished and stale drivers are skipped, searching further until n
+// active ones are found or the live set is exhausted.
+func (s *DataIngestionService) NearestVehicles(ctx context.Context, location types.Location, n int, radiusMeters float64) ([]types.LivePosition, error) {
+	if !s.config.Redis.LivePositions {
+		return nil, ErrLiveTrackingDisabled
+	}
+	if radiusMeters <= 0 {
+		radiusMeters = earthHalfCircumferenceMeters
+	}
+
+	for count := n; ; count *= 2 {
+		found, err := s.backends.Live.NearbyVehicles(ctx, location, radiusMeters, count)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		positions := make([]types.LivePosition, 0, n)
+		for _, position := range found {
+			s.markStale(&position, now)
+			if position.Status == "finished" || position.Stale {
+				continue
+			}
+			bearing := algorithm.InitialBearing(location, position.Location)
+			position.Bearing = &bearing
+			positions = append(positions, position)
+			if len(positions) == n {
+				break
+			}
+		}
+		if len(positions) == n || len(found) < count {
+			return positions, nil
+		}
+	}
+}
+
+// SubscribeLive streams every location processed by this instance that
+// matches the filter until the subscription is closed
+func (s *DataIngestionService) SubscribeLive(filter StreamFilter) *LiveSubscription {
+	return s.liveStream.Subscribe(filter)
+}
+
+// SubscribeEvents streams trip lifecycle events matching the filter, or
+// every event when it is empty, until the subscription is closed
+func (s *DataIngestionService) SubscribeEvents(filter StreamFilter) *EventSubscription {
+	return s.events.Subscribe(filter)
+}
+
+// markStale flags positions not updated within the configured staleness
+// window, judged by server receive time so device clock skew does not matter
+func (s *DataIngestionService) markStale(position *types.LivePosition, now time.Time) {
+	updated := time.UnixMilli(position.UpdatedAt)
+	position.Stale = position.UpdatedAt == 0 || now.Sub(updated) > s.config.Redis.LiveStaleAfter
+}
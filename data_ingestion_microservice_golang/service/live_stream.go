@@ -0,0 +1,141 @@
+package service
+
+import (
+	"sync"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// defaultStreamBufferSize is used when no subscriber buffer size is configured
+const defaultStreamBufferSize = 64
+
+// LiveStream fans processed locations out to in-process subscribers, such
+// as WebSocket clients, by route, driver, or geofence. Publishing never blocks: a subscriber
+// whose buffer is full is dropped, so one slow client cannot hold back
+// ingestion or other clients.
+type LiveStream struct {
+	mu          sync.Mutex
+	bufferSize  int
+	subscribers map[*LiveSubscription]struct{}
+}
+
+// LiveSubscription receives the locations matching a changing filter
+type LiveSubscription struct {
+	stream  *LiveStream
+	filter  streamMatcher
+	updates chan types.BusMessage
+	closed  bool
+	dropped bool
+}
+
+// NewLiveStream creates a stream whose subscribers buffer up to bufferSize
+// locations
+func NewLiveStream(bufferSize int) *LiveStream {
+	if bufferSize <= 0 {
+		bufferSize = defaultStreamBufferSize
+	}
+	return &LiveStream{
+		bufferSize:  bufferSize,
+		subscribers: make(map[*LiveSubscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber for the locations matching a validated
+// filter. A subscriber without any criterion receives nothing until it adds
+// one, so clients never get the whole fleet by accident.
+func (ls *LiveStream) Subscribe(filter StreamFilter) *LiveSubscription {
+	sub := &LiveSubscription{
+		stream:  ls,
+		filter:  newStreamMatcher(filter),
+		updates: make(chan types.BusMessage, ls.bufferSize),
+	}
+
+	ls.mu.Lock()
+	ls.subscribers[sub] = struct{}{}
+	ls.mu.Unlock()
+
+	metrics.LiveStreamSubscribers.Add(1)
+	return sub
+}
+
+// Publish delivers a location to every subscriber whose filter matches it
+func (ls *LiveStream) Publish(busMsg types.BusMessage) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for sub := range ls.subscribers {
+		if sub.filter.empty() || !sub.filter.matches(busMsg.CurrentRouteID, busMsg.DriverID, busMsg.DriverLocation) {
+			continue
+		}
+		select {
+		case sub.updates <- busMsg:
+		default:
+			sub.dropped = true
+			ls.remove(sub)
+			metrics.LiveStreamDropped.Add(1)
+		}
+	}
+}
+
+// remove unregisters a subscriber and closes its channel. Callers must hold
+// ls.mu.
+func (ls *LiveStream) remove(sub *LiveSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(ls.subscribers, sub)
+	close(sub.updates)
+	metrics.LiveStreamSubscribers.Add(-1)
+}
+
+// Updates returns the subscriber's locations. The channel is closed when the
+// subscription is closed or dropped.
+func (sub *LiveSubscription) Updates() <-chan types.BusMessage {
+	return sub.updates
+}
+
+// Add subscribes to more routes and drivers of a validated filter, and
+// replaces the geofence when the filter has one
+func (sub *LiveSubscription) Add(filter StreamFilter) {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.filter.add(filter)
+}
+
+// Remove unsubscribes from the routes and drivers of a filter
+func (sub *LiveSubscription) Remove(filter StreamFilter) {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.filter.remove(filter)
+}
+
+// SetGeofence replaces the geofence of a subscription, or removes it when
+// polygon is empty. The polygon must have been validated.
+func (sub *LiveSubscription) SetGeofence(polygon []types.Location) {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.filter.setGeofence(polygon)
+}
+
+// Filter returns the criteria of the subscription
+func (sub *LiveSubscription) Filter() StreamFilter {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	return sub.filter.filter()
+}
+
+// Dropped reports whether the subscriber was dropped for falling behind
+func (sub *LiveSubscription) Dropped() bool {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	return sub.dropped
+}
+
+// Close unsubscribes from every route
+func (sub *LiveSubscription) Close() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.stream.remove(sub)
+}
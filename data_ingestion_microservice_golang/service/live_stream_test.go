@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestLiveStream_DeliversSubscribedRoutes(t *testing.T) {
+	stream := NewLiveStream(4)
+	sub := stream.Subscribe(StreamFilter{RouteIDs: []string{"route-1"}})
+	defer sub.Close()
+
+	stream.Publish(types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1"})
+	stream.Publish(types.BusMessage{DriverID: "driver-2", CurrentRouteID: "route-2"})
+
+	sub.Add(StreamFilter{RouteIDs: []string{"route-2"}})
+	sub.Remove(StreamFilter{RouteIDs: []string{"route-1"}})
+	stream.Publish(types.BusMessage{DriverID: "driver-3", CurrentRouteID: "route-1"})
+	stream.Publish(types.BusMessage{DriverID: "driver-4", CurrentRouteID: "route-2"})
+
+	var drivers []string
+	for len(sub.Updates()) > 0 {
+		drivers = append(drivers, (<-sub.Updates()).DriverID)
+	}
+	if len(drivers) != 2 || drivers[0] != "driver-1" || drivers[1] != "driver-4" {
+		t.Errorf("Expected driver-1 and driver-4, got %v", drivers)
+	}
+	if routes := sub.Filter().RouteIDs; len(routes) != 1 || routes[0] != "route-2" {
+		t.Errorf("Expected route-2 subscription, got %v", routes)
+	}
+}
+
+func TestLiveStream_DropsSlowSubscribers(t *testing.T) {
+	stream := NewLiveStream(1)
+	slow := stream.Subscribe(StreamFilter{RouteIDs: []string{"route-1"}})
+	fast := stream.Subscribe(StreamFilter{RouteIDs: []string{"route-1"}})
+	defer fast.Close()
+
+	stream.Publish(types.BusMessage{CurrentRouteID: "route-1"})
+	<-fast.Updates()
+	stream.Publish(types.BusMessage{CurrentRouteID: "route-1"})
+
+	if !slow.Dropped() {
+		t.Fatal("Expected the slow subscriber to be dropped")
+	}
+	if fast.Dropped() {
+		t.Error("Expected the fast subscriber to be kept")
+	}
+
+	// The buffered location is still delivered before the channel closes
+	if _, ok := <-slow.Updates(); !ok {
+		t.Error("Expected the buffered location before the close")
+	}
+	if _, ok := <-slow.Updates(); ok {
+		t.Error("Expected the dropped subscriber's channel to be closed")
+	}
+
+	// Closing a dropped subscription is a no-op
+	slow.Close()
+}
+
+func TestLiveStream_FiltersDriversAndGeofence(t *testing.T) {
+	stream := NewLiveStream(8)
+	geofence := []types.Location{
+		{Latitude: 6.20, Longitude: -75.60},
+		{Latitude: 6.20, Longitude: -75.50},
+		{Latitude: 6.30, Longitude: -75.50},
+		{Latitude: 6.30, Longitude: -75.60},
+	}
+	inside := types.Location{Latitude: 6.25, Longitude: -75.55}
+	outside := types.Location{Latitude: 6.40, Longitude: -75.55}
+
+	drivers := stream.Subscribe(StreamFilter{DriverIDs: []string{"driver-1"}})
+	defer drivers.Close()
+	area := stream.Subscribe(StreamFilter{Geofence: geofence})
+	defer area.Close()
+	empty := stream.Subscribe(StreamFilter{})
+	defer empty.Close()
+
+	stream.Publish(types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", DriverLocation: outside})
+	stream.Publish(types.BusMessage{DriverID: "driver-2", CurrentRouteID: "route-2", DriverLocation: inside})
+
+	if len(drivers.Updates()) != 1 || (<-drivers.Updates()).DriverID != "driver-1" {
+		t.Errorf("Expected only driver-1 on the driver subscription")
+	}
+	if len(area.Updates()) != 1 || (<-area.Updates()).DriverID != "driver-2" {
+		t.Errorf("Expected only the location inside the geofence")
+	}
+	if len(empty.Updates()) != 0 {
+		t.Errorf("Expected a subscription without criteria to receive nothing")
+	}
+
+	// Criteria combine: driver-1 inside the geofence only
+	drivers.SetGeofence(geofence)
+	stream.Publish(types.BusMessage{DriverID: "driver-1", DriverLocation: outside})
+	stream.Publish(types.BusMessage{DriverID: "driver-1", DriverLocation: inside})
+	if len(drivers.Updates()) != 1 {
+		t.Errorf("Expected only the driver's location inside the geofence, got %d", len(drivers.Updates()))
+	}
+}
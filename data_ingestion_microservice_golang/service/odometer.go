@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// ErrOdometerDisabled is returned for odometer reads when distance counting
+// is disabled
+var ErrOdometerDisabled = errors.New("distance counting is disabled")
+
+// Odometer remembers the newest point of every route and measures the step
+// each live point drove from it. Points without a timestamp or going back in
+// time drove no step and do not become the newest point, so redelivered
+// points are not counted twice.
+type Odometer struct {
+	config   types.OdometerConfig
+	location *time.Location
+
+	mu   sync.Mutex
+	last map[string]types.TrackPoint
+}
+
+// NewOdometer creates an odometer counting days in the configured time zone
+func NewOdometer(config types.OdometerConfig) (*Odometer, error) {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid odometer time zone: %w", err)
+	}
+	return &Odometer{config: config, location: location, last: make(map[string]types.TrackPoint)}, nil
+}
+
+// Step returns the meters a point drove since the newest point of its route
+// and the day they count on. Steps faster than the configured maximum speed
+// are GPS jumps and drive nothing. The point is not recorded until Advance.
+func (o *Odometer) Step(key string, point types.TrackPoint) (string, float64, bool) {
+	o.mu.Lock()
+	prev, ok := o.last[key]
+	o.mu.Unlock()
+	if !ok || point.Timestamp <= prev.Timestamp {
+		return "", 0, false
+	}
+
+	meters := algorithm.HaversineDistance(prev.Location, point.Location)
+	seconds := float64(point.Timestamp-prev.Timestamp) / 1000
+	if meters == 0 || meters/seconds*3.6 > o.config.MaxSpeedKmh {
+		return "", 0, false
+	}
+	return o.Day(time.UnixMilli(int64(point.Timestamp))), meters, true
+}
+
+// Advance records a point counted in its route as the newest one
+func (o *Odometer) Advance(key string, point types.TrackPoint) {
+	if point.Timestamp == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if prev, ok := o.last[key]; !ok || point.Timestamp > prev.Timestamp {
+		o.last[key] = point
+	}
+}
+
+// Reset forgets a finished route
+func (o *Odometer) Reset(key string) {
+	o.mu.Lock()
+	delete(o.last, key)
+	o.mu.Unlock()
+}
+
+// Day returns the calendar day an instant counts on
+func (o *Odometer) Day(at time.Time) string {
+	return at.In(o.location).Format(time.DateOnly)
+}
+
+// countDistance adds the step a point drove to the counters of its driver
+// and vehicle
+func (s *DataIngestionService) countDistance(ctx context.Context, key string, busMsg types.BusMessage, point types.TrackPoint) error {
+	if day, meters, ok := s.odometer.Step(key, point); ok {
+		err := s.redisCall(ctx, "redis.odometer", func() error {
+			return s.backends.Counters.AddDistance(ctx, day, busMsg.DriverID, busMsg.VehicleID, meters, s.config.Odometer.CounterTTL)
+		})
+		if err != nil {
+			return classify(FailureRedis, err)
+		}
+	}
+	s.odometer.Advance(key, point)
+	return nil
+}
+
+// runOdometerRollup rolls the distance counters up into the daily summaries
+// every rollup interval until ctx is done. With partitioning, only the
+// cluster leader rolls them up.
+func (s *DataIngestionService) runOdometerRollup(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(s.config.Odometer.RollupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !s.leads() {
+				continue
+			}
+			if err := s.rollupDistances(ctx, now); err != nil && ctx.Err() == nil {
+				slog.Error("Error rolling up daily distances", "error", err)
+			}
+		}
+	}
+}
+
+// rollupDistances saves the counters of today and yesterday, which may
+// still have grown since the last rollup before midnight, as daily summaries
+func (s *DataIngestionService) rollupDistances(ctx context.Context, now time.Time) error {
+	var distances []types.DailyDistance
+	for _, day := range []string{s.odometer.Day(now.AddDate(0, 0, -1)), s.odometer.Day(now)} {
+		counted, err := s.dayDistances(ctx, day)
+		if err != nil {
+			return err
+		}
+		distances = append(distances, counted...)
+	}
+	for i := range distances {
+		distances[i].UpdatedAt = now.UTC()
+	}
+
+	err := s.mongoCall(ctx, "mongo.odometer", func() error {
+		return s.backends.Odometer.SaveDailyDistances(ctx, distances)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save daily distances: %w", err)
+	}
+	slog.Debug("Rolled up daily distances", "summaries", len(distances))
+	return nil
+}
+
+// dayDistances reads the counters of a day
+func (s *DataIngestionService) dayDistances(ctx context.Context, day string) ([]types.DailyDistance, error) {
+	var distances []types.DailyDistance
+	err := s.redisCall(ctx, "redis.odometer", func() (err error) {
+		distances, err = s.backends.Counters.DayDistances(ctx, day)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read distance counters of %s: %w", day, err)
+	}
+	return distances, nil
+}
+
+// Odometer returns the daily distances of a driver or vehicle and their
+// total. Today and yesterday are read from the counters, which are ahead of
+// their last rollup, unless Redis is unavailable.
+func (s *DataIngestionService) Odometer(ctx context.Context, query types.OdometerQuery) (types.OdometerReport, error) {
+	if s.odometer == nil {
+		return types.OdometerReport{}, ErrOdometerDisabled
+	}
+
+	var days []types.DailyDistance
+	err := s.mongoCall(ctx, "mongo.odometer", func() (err error) {
+		days, err = s.backends.Odometer.FindDailyDistances(ctx, query)
+		return err
+	})
+	if err != nil {
+		return types.OdometerReport{}, err
+	}
+
+	now := time.Now()
+	for _, day := range []string{s.odometer.Day(now.AddDate(0, 0, -1)), s.odometer.Day(now)} {
+		if (query.From != "" && day < query.From) || (query.To != "" && day > query.To) {
+			continue
+		}
+		counted, err := s.dayDistances(ctx, day)
+		if err != nil {
+			slog.WarnContext(ctx, "Reporting rolled up distances only", "day", day, "error", err)
+			continue
+		}
+		for _, distance := range counted {
+			if (query.DriverID == "" || distance.DriverID == query.DriverID) &&
+				(query.VehicleID == "" || distance.VehicleID == query.VehicleID) {
+				distance.UpdatedAt = now.UTC()
+				days = mergeDailyDistance(days, distance)
+			}
+		}
+	}
+
+	report := types.OdometerReport{DriverID: query.DriverID, VehicleID: query.VehicleID, Days: days}
+	for _, day := range days {
+		report.DistanceMeters += day.DistanceMeters
+	}
+	return report, nil
+}
+
+// mergeDailyDistance replaces the summary of a distance with it, or inserts
+// it in day order
+func mergeDailyDistance(days []types.DailyDistance, distance types.DailyDistance) []types.DailyDistance {
+	for i, day := range days {
+		if day.ID == distance.ID {
+			days[i] = distance
+			return days
+		}
+	}
+	i, _ := slices.BinarySearchFunc(days, distance.Day, func(day types.DailyDistance, target string) int {
+		return strings.Compare(day.Day, target)
+	})
+	return slices.Insert(days, i, distance)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestOdometer_Step(t *testing.T) {
+	odometer, err := NewOdometer(types.OdometerConfig{Timezone: "America/Bogota", MaxSpeedKmh: 200})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// 2022-01-01 04:59 UTC is still 2021-12-31 in Bogotá
+	first := types.TrackPoint{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1641013140000}
+	next := types.TrackPoint{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1641013190000}
+	jump := types.TrackPoint{Location: types.Location{Latitude: 6.3450, Longitude: -75.5820}, Timestamp: 1641013260000}
+
+	if _, _, ok := odometer.Step("key", first); ok {
+		t.Fatal("Expected the first point to drive no step")
+	}
+	odometer.Advance("key", first)
+
+	day, meters, ok := odometer.Step("key", next)
+	if !ok || day != "2021-12-31" {
+		t.Fatalf("Expected a step on 2021-12-31, got %q %v", day, ok)
+	}
+	if want := algorithm.HaversineDistance(first.Location, next.Location); math.Abs(meters-want) > 1e-9 {
+		t.Errorf("Expected %v meters, got %v", want, meters)
+	}
+	odometer.Advance("key", next)
+
+	if _, _, ok := odometer.Step("key", next); ok {
+		t.Error("Expected a redelivered point to drive no step")
+	}
+	if _, _, ok := odometer.Step("key", jump); ok {
+		t.Error("Expected a jump faster than the maximum speed to drive no step")
+	}
+}
+
+func TestOdometer_CountsAndRollsUpDailyDistances(t *testing.T) {
+	store, err := database.NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), database.CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Odometer.Enabled = true
+		backends.Counters = database.NewMemoryBuffer(cfg.Redis)
+		backends.Odometer = store
+	})
+
+	messages := []string{
+		`{"driverId":"driver-1","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		// Redelivered
+		`{"driverId":"driver-1","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-1","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995320000,"driverLocation":{"latitude":6.2460,"longitude":-75.5830}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	want := algorithm.HaversineDistance(types.Location{Latitude: 6.2442, Longitude: -75.5812}, types.Location{Latitude: 6.2450, Longitude: -75.5820}) +
+		algorithm.HaversineDistance(types.Location{Latitude: 6.2450, Longitude: -75.5820}, types.Location{Latitude: 6.2460, Longitude: -75.5830})
+
+	if err := service.rollupDistances(context.Background(), time.UnixMilli(1640995400000)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, query := range []types.OdometerQuery{
+		{DriverID: "driver-1", From: "2022-01-01", To: "2022-01-01"},
+		{VehicleID: "bus-7", From: "2022-01-01", To: "2022-01-01"},
+	} {
+		report, err := service.Odometer(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(report.Days) != 1 || report.Days[0].Day != "2022-01-01" {
+			t.Fatalf("Expected the distance of 2022-01-01 for %+v, got %+v", query, report)
+		}
+		if math.Abs(report.DistanceMeters-want) > 1e-6 {
+			t.Errorf("Expected %v meters for %+v, got %v", want, query, report.DistanceMeters)
+		}
+	}
+}
+
+func TestOdometer_Disabled(t *testing.T) {
+	service := newTestService(t, newMemoryBackend())
+	if _, err := service.Odometer(context.Background(), types.OdometerQuery{DriverID: "driver-1"}); err != ErrOdometerDisabled {
+		t.Errorf("Expected ErrOdometerDisabled, got %v", err)
+	}
+}
+
+func TestMergeDailyDistance(t *testing.T) {
+	days := []types.DailyDistance{
+		{ID: "driver:d:2022-01-01", Day: "2022-01-01", DistanceMeters: 1},
+		{ID: "driver:d:2022-01-03", Day: "2022-01-03", DistanceMeters: 3},
+	}
+	days = mergeDailyDistance(days, types.DailyDistance{ID: "driver:d:2022-01-03", Day: "2022-01-03", DistanceMeters: 4})
+	days = mergeDailyDistance(days, types.DailyDistance{ID: "driver:d:2022-01-02", Day: "2022-01-02", DistanceMeters: 2})
+	if len(days) != 3 || days[1].Day != "2022-01-02" || days[2].DistanceMeters != 4 {
+		t.Errorf("Expected the counted days merged in order, got %+v", days)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// OfflineDetector reports drivers that stop sending locations in the middle
+// of a route. Each silence is reported once; the next location re-arms it.
+type OfflineDetector struct {
+	after time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*offlineRoute
+}
+
+// offlineRoute is the last location of a route and whether its current
+// silence was reported
+type offlineRoute struct {
+	last     types.BusMessage
+	seenAt   time.Time
+	reported bool
+}
+
+// NewOfflineDetector creates a detector that reports routes silent for
+// longer than after
+func NewOfflineDetector(after time.Duration) *OfflineDetector {
+	return &OfflineDetector{
+		after:  after,
+		routes: make(map[string]*offlineRoute),
+	}
+}
+
+// Seen records a location of a route
+func (od *OfflineDetector) Seen(key string, busMsg types.BusMessage, now time.Time) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	od.routes[key] = &offlineRoute{last: busMsg, seenAt: now}
+}
+
+// Finished forgets a route whose finished message was processed
+func (od *OfflineDetector) Finished(key string) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+	delete(od.routes, key)
+}
+
+// Sweep returns an event for every route that went silent since the last
+// sweep, and forgets routes that have been idle long enough to be abandoned
+func (od *OfflineDetector) Sweep(now time.Time) []types.DeviceOfflineEvent {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	var events []types.DeviceOfflineEvent
+	for key, route := range od.routes {
+		silent := now.Sub(route.seenAt)
+		if silent > fleetRouteIdleAfter {
+			delete(od.routes, key)
+			continue
+		}
+		if route.reported || silent <= od.after {
+			continue
+		}
+
+		route.reported = true
+		events = append(events, types.DeviceOfflineEvent{
+			Type:           "device_offline",
+			DriverID:       route.last.DriverID,
+			CurrentRouteID: route.last.CurrentRouteID,
+			Location:       route.last.DriverLocation,
+			Timestamp:      route.last.Timestamp,
+			SilentSeconds:  silent.Seconds(),
+		})
+	}
+	return events
+}
+
+// Run sweeps every interval until ctx is cancelled, passing each event to
+// report
+func (od *OfflineDetector) Run(ctx context.Context, interval time.Duration, report func(types.DeviceOfflineEvent)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, event := range od.Sweep(now) {
+				report(event)
+			}
+		}
+	}
+}
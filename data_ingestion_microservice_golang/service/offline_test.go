@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestOfflineDetector(t *testing.T) {
+	detector := NewOfflineDetector(5 * time.Minute)
+	start := time.Unix(1700000000, 0)
+	busMsg := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "in_route", Timestamp: 1700000000000}
+
+	detector.Seen("route:driver-1:route-1", busMsg, start)
+	detector.Seen("route:driver-2:route-1", types.BusMessage{DriverID: "driver-2"}, start)
+	detector.Finished("route:driver-2:route-1")
+
+	if events := detector.Sweep(start.Add(time.Minute)); len(events) != 0 {
+		t.Fatalf("Expected no events before the threshold, got %+v", events)
+	}
+
+	events := detector.Sweep(start.Add(6 * time.Minute))
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %+v", events)
+	}
+	if events[0].Type != "device_offline" || events[0].DriverID != "driver-1" || events[0].SilentSeconds != 360 {
+		t.Errorf("Unexpected event %+v", events[0])
+	}
+
+	if events := detector.Sweep(start.Add(7 * time.Minute)); len(events) != 0 {
+		t.Errorf("Expected the silence to be reported once, got %+v", events)
+	}
+
+	// A new location re-arms the detector
+	detector.Seen("route:driver-1:route-1", busMsg, start.Add(8*time.Minute))
+	if events := detector.Sweep(start.Add(14 * time.Minute)); len(events) != 1 {
+		t.Errorf("Expected the next silence to be reported, got %+v", events)
+	}
+
+	if detector.Sweep(start.Add(2 * time.Hour)); len(detector.routes) != 0 {
+		t.Errorf("Expected idle routes to be forgotten, got %d", len(detector.routes))
+	}
+}
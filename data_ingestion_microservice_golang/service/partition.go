@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// partitionLeaveTimeout bounds handing this instance's drivers over on
+// shutdown
+const partitionLeaveTimeout = 5 * time.Second
+
+// errDriverLeased reports a driver assigned to this instance whose lease
+// another live instance has not handed over yet
+var errDriverLeased = errors.New("driver is still leased by another instance")
+
+// ringPoint is one of the virtual nodes of an instance on the hash ring
+type ringPoint struct {
+	hash     uint32
+	instance string
+}
+
+// Partitioner splits the drivers between the service instances. The live
+// instances are placed on a consistent-hash ring, so a change of membership
+// only moves the drivers of the instance that joined or left. An instance
+// processes a driver only while it holds the driver's lease in Redis, so the
+// route buffers of a driver are never written by two instances at once, even
+// while their views of the ring differ.
+//
+// One instance at a time also holds the cluster leadership, renewed with
+// every heartbeat, and runs the cluster-wide jobs.
+type Partitioner struct {
+	config      types.PartitionConfig
+	instanceID  string
+	coordinator database.PartitionCoordinator
+	// leaderJobs lists the jobs this instance runs while it leads
+	leaderJobs []string
+	startedAt  time.Time
+
+	mu      sync.Mutex
+	members []string
+	ring    []ringPoint
+	share   float64
+	// leases maps the drivers leased by this instance to when their lease
+	// was last renewed
+	leases        map[string]time.Time
+	leader        bool
+	lastHeartbeat time.Time
+}
+
+// NewPartitioner creates the partitioner of an instance, which owns every
+// driver until it learns about other instances
+func NewPartitioner(config types.PartitionConfig, instanceID string, coordinator database.PartitionCoordinator, leaderJobs []string) *Partitioner {
+	config.VirtualNodes = max(config.VirtualNodes, 1)
+	partitioner := &Partitioner{
+		config:      config,
+		instanceID:  instanceID,
+		coordinator: coordinator,
+		leaderJobs:  leaderJobs,
+		startedAt:   time.Now().UTC(),
+		leases:      make(map[string]time.Time),
+	}
+	partitioner.setMembers(nil)
+	return partitioner
+}
+
+// Join announces the instance and loads the live instances, so the drivers
+// of the others are left to them from the first message on
+func (p *Partitioner) Join(ctx context.Context) error {
+	return p.heartbeat(ctx)
+}
+
+// Run sends heartbeats and follows changes of the live instances until ctx
+// is done
+func (p *Partitioner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.heartbeat(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Error sending partition heartbeat", "instance", p.instanceID, "error", err)
+			}
+		}
+	}
+}
+
+// heartbeat records that the instance is alive, updates the ring, releases
+// the drivers that moved to other instances, renews the leadership, and
+// publishes the status of the instance
+func (p *Partitioner) heartbeat(ctx context.Context) error {
+	members, err := p.coordinator.Heartbeat(ctx, p.instanceID, p.config.MemberTTL)
+	if err != nil {
+		return err
+	}
+	for _, driverID := range p.setMembers(members) {
+		if err := p.coordinator.ReleaseDriver(ctx, driverID, p.instanceID); err != nil {
+			slog.Warn("Error releasing driver", "driverId", driverID, "error", err)
+		}
+	}
+
+	// An instance that cannot renew its leadership stops leading, since
+	// another one may take over once the lease expires
+	leader, err := p.coordinator.AcquireLeadership(ctx, p.instanceID, p.config.MemberTTL)
+	p.setLeader(leader && err == nil)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.lastHeartbeat = time.Now().UTC()
+	p.mu.Unlock()
+	return p.coordinator.PublishStatus(ctx, p.Status())
+}
+
+// setLeader records whether this instance leads the cluster
+func (p *Partitioner) setLeader(leader bool) {
+	p.mu.Lock()
+	changed := leader != p.leader
+	p.leader = leader
+	p.mu.Unlock()
+
+	if leader {
+		metrics.PartitionLeader.Set(1)
+	} else {
+		metrics.PartitionLeader.Set(0)
+	}
+	if changed && leader {
+		slog.Info("Acquired cluster leadership", "instance", p.instanceID, "jobs", p.leaderJobs)
+	} else if changed {
+		slog.Info("Lost cluster leadership", "instance", p.instanceID)
+	}
+}
+
+// IsLeader reports whether this instance runs the cluster-wide jobs
+func (p *Partitioner) IsLeader() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.leader
+}
+
+// Status returns the status this instance reports to the cluster
+func (p *Partitioner) Status() types.InstanceStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := types.InstanceStatus{
+		InstanceID:        p.instanceID,
+		StartedAt:         p.startedAt,
+		LastHeartbeat:     p.lastHeartbeat,
+		Leader:            p.leader,
+		Jobs:              []string{},
+		RingShare:         p.share,
+		OwnedDrivers:      len(p.leases),
+		MessagesTotal:     metrics.IngestProcessed.Value(),
+		MessagesPerSecond: metrics.IngestThroughput.Value(),
+	}
+	if p.leader {
+		status.Jobs = slices.Clone(p.leaderJobs)
+	}
+	return status
+}
+
+// setMembers rebuilds the ring when the live instances changed, always
+// counting this one, and forgets expired leases. It returns the leased
+// drivers that now belong to another instance.
+func (p *Partitioner) setMembers(members []string) []string {
+	if !slices.Contains(members, p.instanceID) {
+		members = append(members, p.instanceID)
+	}
+	slices.Sort(members)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !slices.Equal(members, p.members) {
+		if p.members != nil {
+			metrics.PartitionRebalances.Add(1)
+			slog.Info("Partition members changed", "instance", p.instanceID, "members", members)
+		}
+		p.members = members
+		p.ring = buildRing(members, p.config.VirtualNodes)
+		p.share = ringShare(p.ring, p.instanceID)
+		metrics.PartitionMembers.Set(int64(len(members)))
+	}
+
+	var moved []string
+	now := time.Now()
+	for driverID, renewed := range p.leases {
+		if now.Sub(renewed) >= p.config.LeaseTTL {
+			delete(p.leases, driverID)
+		} else if p.ownerLocked(driverID) != p.instanceID {
+			delete(p.leases, driverID)
+			moved = append(moved, driverID)
+		}
+	}
+	metrics.PartitionDrivers.Set(int64(len(p.leases)))
+	return moved
+}
+
+// buildRing places virtualNodes points of every instance on the ring
+func buildRing(members []string, virtualNodes int) []ringPoint {
+	ring := make([]ringPoint, 0, len(members)*virtualNodes)
+	for _, member := range members {
+		for i := range virtualNodes {
+			ring = append(ring, ringPoint{hash: ringHash(member + "#" + strconv.Itoa(i)), instance: member})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// ringShare returns the fraction of the ring assigned to an instance: the
+// arcs that end at one of its points
+func ringShare(ring []ringPoint, instance string) float64 {
+	var owned uint64
+	for i, point := range ring {
+		if point.instance != instance {
+			continue
+		}
+		if i == 0 {
+			// The first point also owns the arc wrapping past the last one
+			owned += uint64(point.hash) + math.MaxUint32 + 1 - uint64(ring[len(ring)-1].hash)
+		} else {
+			owned += uint64(point.hash - ring[i-1].hash)
+		}
+	}
+	return float64(owned) / (math.MaxUint32 + 1)
+}
+
+// ringHash returns the position of a key on the ring. Keys differing only
+// in their last characters, like virtual nodes, must land far apart, so it
+// takes a cryptographic hash rather than FNV.
+func ringHash(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// Owner returns the instance the ring assigns a driver to
+func (p *Partitioner) Owner(driverID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ownerLocked(driverID)
+}
+
+// ownerLocked returns the instance of the first ring point at or after the
+// driver's position; p.mu must be held
+func (p *Partitioner) ownerLocked(driverID string) string {
+	hash := ringHash(driverID)
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+	if i == len(p.ring) {
+		i = 0
+	}
+	return p.ring[i].instance
+}
+
+// Members returns the live instances, this one included
+func (p *Partitioner) Members() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.members)
+}
+
+// Acquire reports whether this instance may process a driver's messages: the
+// ring must assign the driver to it and it must hold the driver's lease. A
+// driver that moved to another instance is released. It returns
+// errDriverLeased when the ring assigns the driver here but another live
+// instance still holds its lease, so the message can be processed once the
+// lease is handed over instead of being skipped.
+func (p *Partitioner) Acquire(ctx context.Context, driverID string) (bool, error) {
+	p.mu.Lock()
+	owned := p.ownerLocked(driverID) == p.instanceID
+	renewed, held := p.leases[driverID]
+	if !owned {
+		delete(p.leases, driverID)
+		metrics.PartitionDrivers.Set(int64(len(p.leases)))
+	}
+	p.mu.Unlock()
+
+	if !owned {
+		if held {
+			return false, p.coordinator.ReleaseDriver(ctx, driverID, p.instanceID)
+		}
+		return false, nil
+	}
+
+	// A lease renewed within the first third of its TTL is still safely
+	// held, which spares a Redis call for most messages
+	if held && time.Since(renewed) < p.config.LeaseTTL/3 {
+		return true, nil
+	}
+	acquired, err := p.coordinator.AcquireDriver(ctx, driverID, p.instanceID, p.config.LeaseTTL)
+	if err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	if acquired {
+		p.leases[driverID] = time.Now()
+	} else {
+		delete(p.leases, driverID)
+	}
+	metrics.PartitionDrivers.Set(int64(len(p.leases)))
+	p.mu.Unlock()
+	if !acquired {
+		return false, errDriverLeased
+	}
+	return true, nil
+}
+
+// Leave releases every driver leased by this instance and the leadership,
+// and removes it from the live instances, so the others take its drivers and
+// jobs over right away
+func (p *Partitioner) Leave(ctx context.Context) error {
+	p.mu.Lock()
+	drivers := make([]string, 0, len(p.leases))
+	for driverID := range p.leases {
+		drivers = append(drivers, driverID)
+	}
+	clear(p.leases)
+	metrics.PartitionDrivers.Set(0)
+	p.mu.Unlock()
+
+	var errs []error
+	for _, driverID := range drivers {
+		if err := p.coordinator.ReleaseDriver(ctx, driverID, p.instanceID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.IsLeader() {
+		p.setLeader(false)
+		if err := p.coordinator.ReleaseLeadership(ctx, p.instanceID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := p.coordinator.LeaveCluster(ctx, p.instanceID); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// ownsDriver reports whether this instance processes the messages of a
+// driver, leaving those of drivers owned by other instances to them. It
+// returns errDriverLeased, outside the Redis breaker, while the previous
+// owner still holds the driver.
+func (s *DataIngestionService) ownsDriver(ctx context.Context, busMsg types.BusMessage) (bool, error) {
+	var owned, leased bool
+	err := s.redisCall(ctx, "redis.partition", func() error {
+		var err error
+		owned, err = s.partitioner.Acquire(ctx, busMsg.DriverID)
+		if errors.Is(err, errDriverLeased) {
+			leased = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return false, classify(FailureRedis, err)
+	}
+	if leased {
+		metrics.PartitionDeferred.Add(1)
+		return false, classify(FailurePartition, errDriverLeased)
+	}
+	if !owned {
+		metrics.PartitionSkipped.Add(1)
+		slog.DebugContext(ctx, "Skipping message of a driver owned by another instance")
+	}
+	return owned, nil
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// memoryCoordinator is an in-memory PartitionCoordinator shared by the
+// partitioners of a test
+type memoryCoordinator struct {
+	mu       sync.Mutex
+	members  map[string]bool
+	owners   map[string]string
+	leader   string
+	statuses map[string]types.InstanceStatus
+}
+
+func newMemoryCoordinator() *memoryCoordinator {
+	return &memoryCoordinator{
+		members:  make(map[string]bool),
+		owners:   make(map[string]string),
+		statuses: make(map[string]types.InstanceStatus),
+	}
+}
+
+func (c *memoryCoordinator) Heartbeat(ctx context.Context, instanceID string, ttl time.Duration) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.members[instanceID] = true
+	var members []string
+	for member := range c.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (c *memoryCoordinator) LeaveCluster(ctx context.Context, instanceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.members, instanceID)
+	delete(c.statuses, instanceID)
+	return nil
+}
+
+func (c *memoryCoordinator) AcquireDriver(ctx context.Context, driverID, instanceID string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if owner, ok := c.owners[driverID]; ok && owner != instanceID && c.members[owner] {
+		return false, nil
+	}
+	c.owners[driverID] = instanceID
+	return true, nil
+}
+
+func (c *memoryCoordinator) ReleaseDriver(ctx context.Context, driverID, instanceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owners[driverID] == instanceID {
+		delete(c.owners, driverID)
+	}
+	return nil
+}
+
+func (c *memoryCoordinator) AcquireLeadership(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leader != "" && c.leader != instanceID {
+		return false, nil
+	}
+	c.leader = instanceID
+	return true, nil
+}
+
+func (c *memoryCoordinator) ReleaseLeadership(ctx context.Context, instanceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leader == instanceID {
+		c.leader = ""
+	}
+	return nil
+}
+
+func (c *memoryCoordinator) PublishStatus(ctx context.Context, status types.InstanceStatus) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statuses[status.InstanceID] = status
+	return nil
+}
+
+func (c *memoryCoordinator) InstanceStatuses(ctx context.Context) ([]types.InstanceStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var statuses []types.InstanceStatus
+	for _, status := range c.statuses {
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (c *memoryCoordinator) owner(driverID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.owners[driverID]
+}
+
+var testPartitionConfig = types.PartitionConfig{HeartbeatInterval: time.Second, MemberTTL: 3 * time.Second, LeaseTTL: time.Minute, VirtualNodes: 64}
+
+func TestPartitioner_EachDriverOwnedByOneInstance(t *testing.T) {
+	ctx := context.Background()
+	coordinator := newMemoryCoordinator()
+	a := NewPartitioner(testPartitionConfig, "instance_a", coordinator, nil)
+	b := NewPartitioner(testPartitionConfig, "instance_b", coordinator, nil)
+	for _, p := range []*Partitioner{a, b, a} {
+		if err := p.Join(ctx); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if !slices.Equal(a.Members(), []string{"instance_a", "instance_b"}) {
+		t.Fatalf("Expected both instances, got %v", a.Members())
+	}
+
+	owned := map[string]int{}
+	for i := range 100 {
+		driverID := fmt.Sprintf("driver_%03d", i)
+		gotA, _ := a.Acquire(ctx, driverID)
+		gotB, _ := b.Acquire(ctx, driverID)
+		if gotA == gotB {
+			t.Fatalf("Expected %s to be owned by exactly one instance, got %v and %v", driverID, gotA, gotB)
+		}
+		owned[coordinator.owner(driverID)]++
+	}
+	if owned["instance_a"] < 20 || owned["instance_b"] < 20 {
+		t.Errorf("Expected the drivers to be split, got %v", owned)
+	}
+
+	// Once b leaves, a takes all of its drivers over
+	if err := b.Leave(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	a.Join(ctx)
+	for i := range 100 {
+		driverID := fmt.Sprintf("driver_%03d", i)
+		if got, _ := a.Acquire(ctx, driverID); !got {
+			t.Fatalf("Expected %s to move to instance_a", driverID)
+		}
+	}
+}
+
+func TestPartitioner_WaitsForLeaseOfPreviousOwner(t *testing.T) {
+	ctx := context.Background()
+	coordinator := newMemoryCoordinator()
+	a := NewPartitioner(testPartitionConfig, "instance_a", coordinator, nil)
+	a.Join(ctx)
+
+	// instance_b is alive but has not seen the ring change yet, and still
+	// holds the lease
+	coordinator.Heartbeat(ctx, "instance_b", time.Minute)
+	coordinator.AcquireDriver(ctx, "driver_001", "instance_b", time.Minute)
+	if got, err := a.Acquire(ctx, "driver_001"); got || !errors.Is(err, errDriverLeased) {
+		t.Fatalf("Expected the driver to stay with the lease holder, got %v (%v)", got, err)
+	}
+
+	coordinator.ReleaseDriver(ctx, "driver_001", "instance_b")
+	if got, _ := a.Acquire(ctx, "driver_001"); !got {
+		t.Fatal("Expected the driver once released")
+	}
+}
+
+func TestPartitioner_TakesOverLeaseOfDeadInstance(t *testing.T) {
+	ctx := context.Background()
+	coordinator := newMemoryCoordinator()
+	coordinator.Heartbeat(ctx, "instance_b", time.Minute)
+	coordinator.AcquireDriver(ctx, "driver_001", "instance_b", time.Minute)
+
+	// instance_b crashed and dropped out of the members, leaving its lease
+	coordinator.LeaveCluster(ctx, "instance_b")
+	a := NewPartitioner(testPartitionConfig, "instance_a", coordinator, nil)
+	a.Join(ctx)
+	if got, err := a.Acquire(ctx, "driver_001"); !got || err != nil {
+		t.Fatalf("Expected the lease of the dead instance to be taken over, got %v (%v)", got, err)
+	}
+	if owner := coordinator.owner("driver_001"); owner != "instance_a" {
+		t.Errorf("Expected instance_a to hold the lease, got %q", owner)
+	}
+}
+
+func TestPartitioner_ReleasesDriversMovedOnRebalance(t *testing.T) {
+	ctx := context.Background()
+	coordinator := newMemoryCoordinator()
+	a := NewPartitioner(testPartitionConfig, "instance_a", coordinator, nil)
+	a.Join(ctx)
+
+	var drivers []string
+	for i := range 50 {
+		driverID := fmt.Sprintf("driver_%03d", i)
+		if got, _ := a.Acquire(ctx, driverID); !got {
+			t.Fatalf("Expected a single instance to own %s", driverID)
+		}
+		drivers = append(drivers, driverID)
+	}
+
+	// instance_b joins; a releases its drivers on the next heartbeat
+	b := NewPartitioner(testPartitionConfig, "instance_b", coordinator, nil)
+	b.Join(ctx)
+	a.Join(ctx)
+
+	var moved int
+	for _, driverID := range drivers {
+		if a.Owner(driverID) == "instance_b" {
+			moved++
+			if owner := coordinator.owner(driverID); owner != "" {
+				t.Errorf("Expected %s to be released, held by %q", driverID, owner)
+			}
+			if got, _ := b.Acquire(ctx, driverID); !got {
+				t.Errorf("Expected instance_b to acquire %s", driverID)
+			}
+		} else if owner := coordinator.owner(driverID); owner != "instance_a" {
+			t.Errorf("Expected %s to stay with instance_a, held by %q", driverID, owner)
+		}
+	}
+	if moved == 0 {
+		t.Error("Expected some drivers to move to instance_b")
+	}
+}
+
+func TestPartitioner_ElectsOneLeader(t *testing.T) {
+	ctx := context.Background()
+	coordinator := newMemoryCoordinator()
+	a := NewPartitioner(testPartitionConfig, "instance_a", coordinator, []string{jobRouteJanitor})
+	b := NewPartitioner(testPartitionConfig, "instance_b", coordinator, []string{jobRouteJanitor})
+	a.Join(ctx)
+	b.Join(ctx)
+	a.Join(ctx)
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("Expected the first instance to lead, got %v and %v", a.IsLeader(), b.IsLeader())
+	}
+	statusA, statusB := a.Status(), b.Status()
+	if !slices.Equal(statusA.Jobs, []string{jobRouteJanitor}) || len(statusB.Jobs) != 0 {
+		t.Errorf("Expected only the leader to run jobs, got %v and %v", statusA.Jobs, statusB.Jobs)
+	}
+	if share := statusA.RingShare + statusB.RingShare; share < 0.999 || share > 1.001 {
+		t.Errorf("Expected the ring shares to add up to 1, got %f", share)
+	}
+
+	// The leader hands its jobs over when it leaves
+	if err := a.Leave(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	b.Join(ctx)
+	if a.IsLeader() || !b.IsLeader() {
+		t.Errorf("Expected instance_b to take the leadership over, got %v and %v", a.IsLeader(), b.IsLeader())
+	}
+	if share := b.Status().RingShare; share != 1 {
+		t.Errorf("Expected the remaining instance to own the whole ring, got %f", share)
+	}
+}
+
+func TestClusterStatus_ListsLiveInstances(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t, newMemoryBackend())
+	coordinator := newMemoryCoordinator()
+	service.config.InstanceID = "instance_a"
+	service.backends.Partitions = coordinator
+	service.partitioner = NewPartitioner(testPartitionConfig, service.config.InstanceID, coordinator, nil)
+	service.partitioner.Join(ctx)
+
+	other := NewPartitioner(testPartitionConfig, "instance_z", coordinator, nil)
+	other.Join(ctx)
+	service.partitioner.Join(ctx)
+
+	// A dead instance's stale status is not reported
+	coordinator.PublishStatus(ctx, types.InstanceStatus{InstanceID: "instance_dead", Leader: true})
+
+	cluster, err := service.ClusterStatus(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !cluster.Partitioning || cluster.Instance != service.config.InstanceID || cluster.Leader != service.config.InstanceID {
+		t.Errorf("Expected this instance to lead the cluster, got %+v", cluster)
+	}
+	if len(cluster.Instances) != 2 || cluster.Instances[1].InstanceID != "instance_z" {
+		t.Fatalf("Expected the two live instances, got %+v", cluster.Instances)
+	}
+}
+
+func TestProcessMessage_DefersDriversLeasedByOtherInstances(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+	coordinator := newMemoryCoordinator()
+	service.partitioner = NewPartitioner(testPartitionConfig, "instance_a", coordinator, nil)
+	service.partitioner.Join(context.Background())
+
+	coordinator.Heartbeat(context.Background(), "instance_b", time.Minute)
+	coordinator.AcquireDriver(context.Background(), "driver_001", "instance_b", time.Minute)
+	payload := []byte(`{"driverId":"driver_001","currentRouteId":"route_123","status":"in_route","driverLocation":{"latitude":40.7,"longitude":-74}}`)
+	acked := false
+	if err := service.processMessage(context.Background(), payload, func() { acked = true }); !errors.Is(err, errDriverLeased) || failureClass(err) != FailurePartition {
+		t.Fatalf("Expected the message to wait for the lease, got %v", err)
+	}
+	if acked {
+		t.Error("Expected a message waiting for the lease not to be acknowledged")
+	}
+	if points := backend.routes["route:driver_001:route_123"]; len(points) != 0 {
+		t.Errorf("Expected no points from a driver leased elsewhere, got %d", len(points))
+	}
+	if state := service.redisBreaker.State().String(); state != "closed" {
+		t.Errorf("Expected waiting for a lease not to trip the Redis breaker, got %s", state)
+	}
+
+	coordinator.ReleaseDriver(context.Background(), "driver_001", "instance_b")
+	if err := service.processMessage(context.Background(), payload, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if points := backend.routes["route:driver_001:route_123"]; len(points) != 1 {
+		t.Errorf("Expected the point once the driver is owned, got %d", len(points))
+	}
+}
+
+func TestHandleMessage_BacksOffWhileDriverIsLeased(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Ingest.Overflow = OverflowSpill
+		cfg.Ingest.SpillPath = filepath.Join(t.TempDir(), "spill.db")
+	})
+	service.ingest.backoffMu.Lock()
+	service.ingest.minRetryDelay, service.ingest.maxRetryDelay = 20*time.Millisecond, 80*time.Millisecond
+	service.ingest.backoffMu.Unlock()
+	coordinator := newMemoryCoordinator()
+	service.partitioner = NewPartitioner(testPartitionConfig, "instance_a", coordinator, nil)
+	service.partitioner.Join(context.Background())
+	coordinator.Heartbeat(context.Background(), "instance_b", time.Minute)
+	coordinator.AcquireDriver(context.Background(), "driver_001", "instance_b", time.Minute)
+
+	deferred := metrics.PartitionDeferred.Value()
+	acked := make(chan struct{})
+	payload := []byte(`{"driverId":"driver_001","currentRouteId":"route_123","status":"in_route","driverLocation":{"latitude":40.7,"longitude":-74}}`)
+	service.handleMessage("drivers_location/driver_001", payload, func() { close(acked) })
+	<-acked
+
+	// Attempts back off to one every 80ms instead of looping
+	time.Sleep(500 * time.Millisecond)
+	if attempts := metrics.PartitionDeferred.Value() - deferred; attempts < 2 || attempts > 12 {
+		t.Errorf("Expected a bounded number of attempts while the driver is leased, got %d", attempts)
+	}
+
+	coordinator.ReleaseDriver(context.Background(), "driver_001", "instance_b")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend.mu.Lock()
+		points := len(backend.routes["route:driver_001:route_123"])
+		backend.mu.Unlock()
+		if points == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the message to be processed once the lease is released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// statusPassengerCount is the status of the messages automatic passenger
+// counters send
+const statusPassengerCount = "passenger_count"
+
+// isPassengerCountMessage reports whether a message counts passengers
+// rather than only reporting a location
+func isPassengerCountMessage(busMsg types.BusMessage) bool {
+	return busMsg.Status == statusPassengerCount
+}
+
+// validatePassengers rejects counts no counter can report, and counts sent
+// with other messages
+func validatePassengers(busMsg types.BusMessage) error {
+	count := busMsg.Passengers
+	switch {
+	case !isPassengerCountMessage(busMsg):
+		if count != nil {
+			return fmt.Errorf("passengers sent with status %q", busMsg.Status)
+		}
+	case count == nil:
+		return fmt.Errorf("passenger_count message without passengers")
+	case count.Boardings < 0 || count.Alightings < 0:
+		return fmt.Errorf("negative passenger count %d boardings, %d alightings", count.Boardings, count.Alightings)
+	}
+	return nil
+}
+
+// LoadProfiler builds the passenger load profiles of finished trips from the
+// counts buffered with their points, attributing counts to the stops of the
+// planned route when there is one
+type LoadProfiler struct {
+	config types.PassengerCountConfig
+	routes *plannedRouteCache // nil without planned routes
+}
+
+// NewLoadProfiler creates a load profiler. Without planned routes, counts
+// are only attributed to the stops their devices report.
+func NewLoadProfiler(config types.PassengerCountConfig, routes database.PlannedRouteStore) *LoadProfiler {
+	profiler := &LoadProfiler{config: config}
+	if routes != nil {
+		profiler.routes = newPlannedRouteCache(routes)
+	}
+	return profiler
+}
+
+// Profile returns the load profile of a trip's raw points, or nil when no
+// passengers were counted
+func (p *LoadProfiler) Profile(ctx context.Context, routeID string, points []types.TrackPoint) (*types.LoadProfile, error) {
+	counted := slices.ContainsFunc(points, func(point types.TrackPoint) bool { return point.Passengers != nil })
+	if !counted {
+		return nil, nil
+	}
+
+	var stops []types.PlannedStop
+	if p.routes != nil {
+		route, err := p.routes.get(ctx, routeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read planned route %s: %w", routeID, err)
+		}
+		if route != nil {
+			stops = route.Stops
+		}
+	}
+	return algorithm.LoadProfile(points, stops, p.config.StopRadiusMeters), nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_StoresLoadProfile(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.PassengerCounts.Enabled = true
+		backends.PlannedRoutes = memoryPlannedRoutes{"route-1": etaTestRoute}
+	})
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.009}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"passenger_count","timestamp":1640995210000,"driverLocation":{"latitude":0,"longitude":0.009},"passengers":{"boardings":6,"alightings":0}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":0,"longitude":0.018}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"passenger_count","timestamp":1640995270000,"driverLocation":{"latitude":0,"longitude":0.0181},"passengers":{"boardings":1,"alightings":4,"stopId":"stop-2"}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	key := database.RouteKey("driver-1", "route-1")
+	if points := backend.routes[key]; len(points) != 4 || points[1].Point.Passengers == nil || points[1].Point.Passengers.Boardings != 6 {
+		t.Fatalf("Expected the counts buffered with the locations, got %+v", points)
+	}
+
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995300000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		profile := trip.LoadProfile
+		if profile == nil || len(profile.Stops) != 2 || profile.Boardings != 7 || profile.Alightings != 4 || profile.PeakLoad != 6 {
+			t.Fatalf("Expected 7 boardings and 4 alightings peaking at 6, got %+v", profile)
+		}
+		if first := profile.Stops[0]; first.StopID != "stop-1" || first.Load != 6 {
+			t.Errorf("Expected 6 on board after stop-1, got %+v", first)
+		}
+		if second := profile.Stops[1]; second.StopID != "stop-2" || second.Load != 3 {
+			t.Errorf("Expected 3 on board after stop-2, got %+v", second)
+		}
+	}
+}
+
+func TestProcessMessage_IgnoresPassengerCountsWhenDisabled(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"passenger_count","timestamp":1640995210000,"driverLocation":{"latitude":0,"longitude":0.009},"passengers":{"boardings":6,"alightings":0}}`
+	if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if points := backend.routes[database.RouteKey("driver-1", "route-1")]; len(points) != 0 {
+		t.Errorf("Expected no buffered points, got %+v", points)
+	}
+}
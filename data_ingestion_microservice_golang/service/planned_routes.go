@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// plannedRouteRefreshInterval controls how long a planned route stays cached
+const plannedRouteRefreshInterval = 5 * time.Minute
+
+// cachedPlannedRoute holds a planned route (or a known miss) loaded from MongoDB
+type cachedPlannedRoute struct {
+	route    *types.PlannedRoute
+	loadedAt time.Time
+}
+
+// plannedRouteCache caches the planned routes read from the store
+type plannedRouteCache struct {
+	store database.PlannedRouteStore
+
+	mu     sync.Mutex
+	routes map[string]cachedPlannedRoute
+}
+
+// newPlannedRouteCache creates an empty planned route cache
+func newPlannedRouteCache(store database.PlannedRouteStore) *plannedRouteCache {
+	return &plannedRouteCache{
+		store:  store,
+		routes: make(map[string]cachedPlannedRoute),
+	}
+}
+
+// get returns the planned route for a route ID, or nil if there is none,
+// loading it from the store when it is not cached or the cached copy is stale
+func (c *plannedRouteCache) get(ctx context.Context, routeID string) (*types.PlannedRoute, error) {
+	c.mu.Lock()
+	cached, ok := c.routes[routeID]
+	c.mu.Unlock()
+
+	if ok && time.Since(cached.loadedAt) < plannedRouteRefreshInterval {
+		return cached.route, nil
+	}
+
+	route, err := c.store.FindPlannedRoute(ctx, routeID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.routes[routeID] = cachedPlannedRoute{route: route, loadedAt: time.Now()}
+	c.mu.Unlock()
+
+	return route, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// AuditActionDeleteDriverData is the audit action recorded for driver data
+// deletions
+const AuditActionDeleteDriverData = "driver_data.delete"
+
+// ErrDataDeletionUnsupported is returned when no backend can delete driver
+// data
+var ErrDataDeletionUnsupported = errors.New("driver data deletion is not supported by the storage backend")
+
+// DeleteDriverData removes every trip, raw route, shift, vehicle assignment,
+// archived trace, route buffer, point buffered for a vehicle, live position,
+// and stop ETA stored for a driver and records the deletion in the audit log.
+// The audit entry is written even when a backend fails, with the counts of
+// what was removed before the failure, so the deletion can be retried and
+// accounted for.
+func (s *DataIngestionService) DeleteDriverData(ctx context.Context, driverID, actor string) (types.DriverDeletionReport, error) {
+	if len(s.backends.Erasers) == 0 {
+		return types.DriverDeletionReport{}, ErrDataDeletionUnsupported
+	}
+
+	report := types.DriverDeletionReport{DriverID: driverID}
+	var deleteErr error
+	for _, eraser := range s.backends.Erasers {
+		removed, err := eraser.DeleteDriverData(ctx, driverID)
+		report.Add(removed)
+		if err != nil {
+			deleteErr = err
+			break
+		}
+	}
+	report.DeletedAt = time.Now().UTC()
+	if s.assignments != nil {
+		s.assignments.Forget(driverID)
+	}
+
+	err := s.recordAudit(ctx, types.AuditEntry{
+		Action:    AuditActionDeleteDriverData,
+		Actor:     actor,
+		Target:    driverID,
+		NewValue:  report,
+		Timestamp: report.DeletedAt,
+	})
+	if err != nil {
+		deleteErr = errors.Join(deleteErr, err)
+	}
+
+	if deleteErr != nil {
+		return report, fmt.Errorf("failed to delete data of driver %s: %w", driverID, deleteErr)
+	}
+
+	slog.InfoContext(ctx, "Deleted driver data",
+		"driverId", driverID,
+		"trips", report.Trips,
+		"rawRoutes", report.RawRoutes,
+		"shifts", report.Shifts,
+		"assignments", report.Assignments,
+		"archivedTraces", report.ArchivedTraces,
+		"routeBuffers", report.RouteBuffers,
+		"vehiclePoints", report.VehiclePoints,
+		"livePositions", report.LivePositions,
+		"etas", report.ETAs,
+	)
+	return report, nil
+}
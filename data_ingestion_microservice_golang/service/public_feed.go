@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// PublicFeed republishes the latest position of each vehicle to public MQTT
+// topics for consumer-facing apps. Driver IDs are replaced by salted
+// pseudonymous vehicle IDs, coordinates are rounded, and each route is
+// published at most once per interval.
+type PublicFeed struct {
+	config types.PublicFeedConfig
+	broker database.MessageBroker
+	salt   []byte
+
+	mu     sync.Mutex
+	routes map[string]*publicRoute
+}
+
+// publicRoute holds the vehicles of a route since it was last published
+type publicRoute struct {
+	vehicles map[string]publicVehicle
+	dirty    bool
+}
+
+// publicVehicle is a vehicle's latest public position and when it arrived
+type publicVehicle struct {
+	vehicle types.PublicVehicle
+	seenAt  time.Time
+}
+
+// NewPublicFeed creates a public position feed. Without a configured salt,
+// vehicle IDs change on every restart.
+func NewPublicFeed(config types.PublicFeedConfig, broker database.MessageBroker) (*PublicFeed, error) {
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("public feed interval must be positive, got %s", config.Interval)
+	}
+
+	salt := []byte(config.Salt)
+	if len(salt) == 0 {
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate public feed salt: %w", err)
+		}
+	}
+
+	return &PublicFeed{
+		config: config,
+		broker: broker,
+		salt:   salt,
+		routes: make(map[string]*publicRoute),
+	}, nil
+}
+
+// Update records a processed location. A finished message removes the
+// vehicle from its route.
+func (pf *PublicFeed) Update(busMsg types.BusMessage, now time.Time) {
+	vehicleID := pf.vehicleID(busMsg.DriverID)
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	route, ok := pf.routes[busMsg.CurrentRouteID]
+	if !ok {
+		if busMsg.Status != "in_route" {
+			return
+		}
+		route = &publicRoute{vehicles: make(map[string]publicVehicle)}
+		pf.routes[busMsg.CurrentRouteID] = route
+	}
+
+	switch busMsg.Status {
+	case "in_route":
+		route.vehicles[vehicleID] = publicVehicle{
+			vehicle: types.PublicVehicle{
+				VehicleID: vehicleID,
+				Location: types.Location{
+					Latitude:  pf.round(busMsg.DriverLocation.Latitude),
+					Longitude: pf.round(busMsg.DriverLocation.Longitude),
+				},
+				Timestamp: busMsg.Timestamp,
+			},
+			seenAt: now,
+		}
+		route.dirty = true
+	case "finished":
+		if _, ok := route.vehicles[vehicleID]; ok {
+			delete(route.vehicles, vehicleID)
+			route.dirty = true
+		}
+	}
+}
+
+// Run publishes changed routes every interval until ctx is cancelled
+func (pf *PublicFeed) Run(ctx context.Context) {
+	ticker := time.NewTicker(pf.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pf.Flush(now)
+		}
+	}
+}
+
+// Flush publishes every route whose vehicles changed since it was last
+// published, after removing vehicles that stopped reporting. A route is
+// published once more with no vehicles before it is forgotten, so consumers
+// can clear it.
+func (pf *PublicFeed) Flush(now time.Time) {
+	var updates []types.PublicRouteUpdate
+
+	pf.mu.Lock()
+	for routeID, route := range pf.routes {
+		for vehicleID, v := range route.vehicles {
+			if pf.config.StaleAfter > 0 && now.Sub(v.seenAt) > pf.config.StaleAfter {
+				delete(route.vehicles, vehicleID)
+				route.dirty = true
+			}
+		}
+		if !route.dirty {
+			continue
+		}
+
+		update := types.PublicRouteUpdate{
+			RouteID:     routeID,
+			Vehicles:    make([]types.PublicVehicle, 0, len(route.vehicles)),
+			PublishedAt: now.UnixMilli(),
+		}
+		for _, v := range route.vehicles {
+			update.Vehicles = append(update.Vehicles, v.vehicle)
+		}
+		updates = append(updates, update)
+
+		route.dirty = false
+		if len(route.vehicles) == 0 {
+			delete(pf.routes, routeID)
+		}
+	}
+	pf.mu.Unlock()
+
+	for _, update := range updates {
+		if err := pf.publish(update); err != nil {
+			metrics.PublicFeedFailed.Add(1)
+			slog.Error("Error publishing public positions", "routeId", update.RouteID, "error", err)
+			continue
+		}
+		metrics.PublicFeedPublished.Add(1)
+	}
+}
+
+// publish sends a route update to {prefix}/{routeId}/vehicles
+func (pf *PublicFeed) publish(update types.PublicRouteUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public route update: %w", err)
+	}
+	topic := fmt.Sprintf("%s/%s/vehicles", pf.config.TopicPrefix, topicLevel(update.RouteID))
+	return pf.broker.PublishMessage(topic, payload)
+}
+
+// topicLevel percent-encodes the characters of a route ID that would split
+// it into several topic levels or turn it into a wildcard, so a route ID
+// like "a/b" or "#" cannot publish to another route's topic
+func topicLevel(id string) string {
+	if !strings.ContainsAny(id, "%/+#\x00") {
+		return id
+	}
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; c {
+		case '%', '/', '+', '#', 0:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// vehicleID derives a stable pseudonymous ID from a driver ID
+func (pf *PublicFeed) vehicleID(driverID string) string {
+	mac := hmac.New(sha256.New, pf.salt)
+	mac.Write([]byte(driverID))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// round reduces a coordinate to the configured number of decimals
+func (pf *PublicFeed) round(value float64) float64 {
+	if pf.config.Precision <= 0 {
+		return value
+	}
+	scale := math.Pow10(pf.config.Precision)
+	return math.Round(value*scale) / scale
+}
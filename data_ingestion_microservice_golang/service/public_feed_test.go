@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// recordingBroker records published messages by topic
+type recordingBroker struct {
+	mu        sync.Mutex
+	published map[string][][]byte
+}
+
+func (b *recordingBroker) SubscribeToTopic(topic string, handler mqtt.MessageHandler) error {
+	return nil
+}
+
+func (b *recordingBroker) UnsubscribeFromTopic(topic string) error {
+	return nil
+}
+
+func (b *recordingBroker) Redeliver() error {
+	return nil
+}
+
+func (b *recordingBroker) PublishMessage(topic string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.published == nil {
+		b.published = make(map[string][][]byte)
+	}
+	b.published[topic] = append(b.published[topic], payload)
+	return nil
+}
+
+// updates decodes the route updates published to a topic
+func (b *recordingBroker) updates(t *testing.T, topic string) []types.PublicRouteUpdate {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var updates []types.PublicRouteUpdate
+	for _, payload := range b.published[topic] {
+		var update types.PublicRouteUpdate
+		if err := json.Unmarshal(payload, &update); err != nil {
+			t.Fatalf("Failed to decode public update: %v", err)
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+func TestPublicFeed_ThrottlesAndSanitizes(t *testing.T) {
+	broker := &recordingBroker{}
+	feed, err := NewPublicFeed(types.PublicFeedConfig{
+		TopicPrefix: "public/routes",
+		Interval:    5 * time.Second,
+		Precision:   4,
+		StaleAfter:  time.Minute,
+		Salt:        "secret",
+	}, broker)
+	if err != nil {
+		t.Fatalf("Failed to create public feed: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < 3; i++ {
+		feed.Update(types.BusMessage{
+			DriverID:       "driver-1",
+			CurrentRouteID: "route-1",
+			Status:         "in_route",
+			DriverLocation: types.Location{Latitude: 6.2442031, Longitude: -75.5812119 + float64(i)*0.001},
+			Timestamp:      uint64(i),
+		}, now)
+	}
+	feed.Flush(now)
+	feed.Flush(now.Add(time.Second))
+
+	topic := "public/routes/route-1/vehicles"
+	updates := broker.updates(t, topic)
+	if len(updates) != 1 || len(updates[0].Vehicles) != 1 {
+		t.Fatalf("Expected one update with one vehicle, got %+v", updates)
+	}
+	vehicle := updates[0].Vehicles[0]
+	if vehicle.VehicleID == "" || vehicle.VehicleID == "driver-1" {
+		t.Errorf("Expected a pseudonymous vehicle ID, got %q", vehicle.VehicleID)
+	}
+	if vehicle.Location.Latitude != 6.2442 || vehicle.Location.Longitude != -75.5792 || vehicle.Timestamp != 2 {
+		t.Errorf("Expected the latest rounded position, got %+v", vehicle)
+	}
+	if payload := string(broker.published[topic][0]); strings.Contains(payload, "driver") {
+		t.Errorf("Expected no driver details in %s", payload)
+	}
+
+	// Finishing removes the vehicle, and the empty route is published once
+	feed.Update(types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished"}, now)
+	feed.Flush(now.Add(5 * time.Second))
+	feed.Flush(now.Add(10 * time.Second))
+
+	updates = broker.updates(t, topic)
+	if len(updates) != 2 || len(updates[1].Vehicles) != 0 {
+		t.Fatalf("Expected a final empty update, got %+v", updates)
+	}
+}
+
+func TestPublicFeed_ExpiresStaleVehicles(t *testing.T) {
+	broker := &recordingBroker{}
+	feed, err := NewPublicFeed(types.PublicFeedConfig{TopicPrefix: "public/routes", Interval: time.Second, StaleAfter: time.Minute}, broker)
+	if err != nil {
+		t.Fatalf("Failed to create public feed: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	feed.Update(types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "in_route"}, now)
+	feed.Update(types.BusMessage{DriverID: "driver-2", CurrentRouteID: "route-1", Status: "in_route"}, now.Add(30*time.Second))
+	feed.Flush(now.Add(30 * time.Second))
+	feed.Flush(now.Add(90 * time.Second))
+
+	updates := broker.updates(t, "public/routes/route-1/vehicles")
+	if len(updates) != 2 || len(updates[0].Vehicles) != 2 || len(updates[1].Vehicles) != 1 {
+		t.Fatalf("Expected the silent vehicle to expire, got %+v", updates)
+	}
+}
+
+func TestPublicFeed_EscapesRouteTopicLevel(t *testing.T) {
+	broker := &recordingBroker{}
+	feed, err := NewPublicFeed(types.PublicFeedConfig{TopicPrefix: "public/routes", Interval: time.Second}, broker)
+	if err != nil {
+		t.Fatalf("Failed to create public feed: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	for _, routeID := range []string{"route-1/vehicles", "#", "a+b", "100%"} {
+		feed.Update(types.BusMessage{DriverID: "driver-1", CurrentRouteID: routeID, Status: "in_route"}, now)
+	}
+	feed.Flush(now)
+
+	for _, topic := range []string{
+		"public/routes/route-1%2Fvehicles/vehicles",
+		"public/routes/%23/vehicles",
+		"public/routes/a%2Bb/vehicles",
+		"public/routes/100%25/vehicles",
+	} {
+		if updates := broker.updates(t, topic); len(updates) != 1 {
+			t.Errorf("Expected one update on %s, got %+v", topic, updates)
+		}
+	}
+	if len(broker.published) != 4 {
+		t.Errorf("Expected four topics, got %d", len(broker.published))
+	}
+}
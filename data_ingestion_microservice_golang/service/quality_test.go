@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_ScoresTripQuality(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Quality.Enabled = true
+	})
+
+	// A point every 20 seconds along the equator, with one reading jumping 100 km away
+	longitudes := []float64{0, 0.002, 0.004, 1, 0.008, 0.01}
+	for i, longitude := range longitudes {
+		message := fmt.Sprintf(`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":%d,"driverLocation":{"latitude":0,"longitude":%v}}`,
+			1640995200000+uint64(i)*20000, longitude)
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995320000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		quality := trip.Quality
+		if quality == nil || quality.OutliersRemoved != 1 {
+			t.Fatalf("Expected the jump to be removed, got %+v", quality)
+		}
+		if !slices.Contains(quality.Flags, algorithm.QualityHasOutliers) || slices.Contains(quality.Flags, algorithm.QualityHasGaps) {
+			t.Errorf("Unexpected flags %v", quality.Flags)
+		}
+		if trip.OriginalPointsCount != 5 {
+			t.Errorf("Expected the analyzed route to skip the jump, got %d points", trip.OriginalPointsCount)
+		}
+		if trip.Stats.MaxSpeedKmh > 100 {
+			t.Errorf("Expected the jump to stay out of the stats, got %v km/h", trip.Stats.MaxSpeedKmh)
+		}
+	}
+}
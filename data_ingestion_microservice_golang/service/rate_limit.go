@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+	"data-ingestion-microservice/webhook"
+)
+
+// Policies for locations sent faster than a driver's rate limit
+const (
+	// RateLimitThrottle drops every location over the limit
+	RateLimitThrottle = "throttle"
+	// RateLimitSample keeps one of every SampleEvery locations over the
+	// limit, so a runaway device still shows up on the map
+	RateLimitSample = "sample"
+)
+
+// rateLimitSweepInterval is how often buckets of drivers that stopped
+// sending are forgotten
+const rateLimitSweepInterval = time.Minute
+
+// RateLimiter limits the rate of locations of every driver with a token
+// bucket, so a runaway tracker cannot starve the other drivers
+type RateLimiter struct {
+	config types.RateLimitConfig
+
+	mu        sync.Mutex
+	drivers   map[string]*driverBucket
+	lastSweep time.Time
+}
+
+// driverBucket is the token bucket of a driver along with the locations
+// limited since its last alert
+type driverBucket struct {
+	tokens    float64
+	updated   time.Time
+	excess    int64
+	limited   int64
+	alertedAt time.Time
+}
+
+// NewRateLimiter creates a per-driver rate limiter
+func NewRateLimiter(config types.RateLimitConfig) (*RateLimiter, error) {
+	switch config.Policy {
+	case RateLimitThrottle, RateLimitSample:
+	default:
+		return nil, fmt.Errorf("unknown rate limit policy %q", config.Policy)
+	}
+	if config.Rate <= 0 {
+		return nil, fmt.Errorf("rate limit must be positive, got %v", config.Rate)
+	}
+	config.Burst = max(config.Burst, 1)
+	config.SampleEvery = max(config.SampleEvery, 1)
+
+	return &RateLimiter{config: config, drivers: make(map[string]*driverBucket)}, nil
+}
+
+// Allow reports whether a location of the driver may be processed. When the
+// driver is over its limit and was not alerted about within the alert
+// interval, it also returns the event to raise.
+func (l *RateLimiter) Allow(busMsg types.BusMessage, now time.Time) (bool, *types.DeviceRateLimitedEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.drivers[busMsg.DriverID]
+	if !ok {
+		bucket = &driverBucket{tokens: float64(l.config.Burst), updated: now}
+		l.drivers[busMsg.DriverID] = bucket
+	}
+
+	// Refill the bucket for the time since the last location
+	bucket.tokens = min(bucket.tokens+now.Sub(bucket.updated).Seconds()*l.config.Rate, float64(l.config.Burst))
+	bucket.updated = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, nil
+	}
+
+	// Sampling keeps one of every SampleEvery excess locations
+	bucket.excess++
+	if l.config.Policy == RateLimitSample && bucket.excess%int64(l.config.SampleEvery) == 0 {
+		return true, nil
+	}
+	bucket.limited++
+
+	if !bucket.alertedAt.IsZero() && now.Sub(bucket.alertedAt) < l.config.AlertInterval {
+		return false, nil
+	}
+	event := &types.DeviceRateLimitedEvent{
+		Type:           "device_rate_limited",
+		DriverID:       busMsg.DriverID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Timestamp:      busMsg.Timestamp,
+		RatePerSecond:  l.config.Rate,
+		Policy:         l.config.Policy,
+		Limited:        bucket.limited,
+	}
+	bucket.alertedAt = now
+	bucket.limited = 0
+	return false, event
+}
+
+// SetLimits changes the rate and burst of every driver. Buckets keep their
+// tokens, up to the new burst.
+func (l *RateLimiter) SetLimits(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config.Rate = rate
+	l.config.Burst = max(burst, 1)
+}
+
+// sweep forgets the buckets of drivers idle long enough for their bucket to
+// be full again, at most once per sweep interval
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.config.Burst) / l.config.Rate * float64(time.Second))
+	idle := max(refill, l.config.AlertInterval)
+	for driverID, bucket := range l.drivers {
+		if now.Sub(bucket.updated) > idle {
+			delete(l.drivers, driverID)
+		}
+	}
+}
+
+// allowLocation applies the driver's rate limit to an in-route location,
+// counting limited locations and raising the alert event when one is due
+func (s *DataIngestionService) allowLocation(ctx context.Context, busMsg types.BusMessage) bool {
+	allowed, event := s.limiter.Allow(busMsg, time.Now())
+	if !allowed {
+		metrics.RateLimited.Add(1)
+		slog.DebugContext(ctx, "Dropped rate limited location", "timestamp", busMsg.Timestamp)
+	}
+	if event != nil {
+		slog.WarnContext(ctx, "Driver rate limited", "ratePerSecond", event.RatePerSecond, "limited", event.Limited)
+		if s.webhooks != nil {
+			s.webhooks.Dispatch(event.Type, webhook.Subject{DriverID: event.DriverID, RouteID: event.CurrentRouteID, Timestamp: event.Timestamp}, event)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestRateLimiter_Throttle(t *testing.T) {
+	limiter, err := NewRateLimiter(types.RateLimitConfig{Rate: 1, Burst: 2, Policy: RateLimitThrottle, AlertInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	start := time.Unix(1700000000, 0)
+	busMsg := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "in_route"}
+
+	// The burst passes, then the excess is dropped with a single alert
+	var allowed, events int
+	for i := 0; i < 10; i++ {
+		ok, event := limiter.Allow(busMsg, start)
+		if ok {
+			allowed++
+		}
+		if event != nil {
+			events++
+			if event.Type != "device_rate_limited" || event.DriverID != "driver-1" || event.Limited != 1 {
+				t.Errorf("Unexpected event %+v", event)
+			}
+		}
+	}
+	if allowed != 2 || events != 1 {
+		t.Errorf("Expected 2 allowed locations and 1 alert, got %d and %d", allowed, events)
+	}
+
+	// Other drivers have their own bucket
+	if ok, _ := limiter.Allow(types.BusMessage{DriverID: "driver-2"}, start); !ok {
+		t.Error("Expected another driver to be allowed")
+	}
+
+	// Tokens refill over time, and the next alert reports what was limited
+	if ok, _ := limiter.Allow(busMsg, start.Add(time.Second)); !ok {
+		t.Error("Expected a refilled token to be allowed")
+	}
+	if ok, event := limiter.Allow(busMsg, start.Add(time.Second)); ok || event != nil {
+		t.Errorf("Expected a limited location without a new alert, got %v, %+v", ok, event)
+	}
+	later := start.Add(time.Minute + 500*time.Millisecond)
+	limiter.Allow(busMsg, later)
+	limiter.Allow(busMsg, later)
+	// 7 more from the burst above, 1 after the refill, and this one
+	if ok, event := limiter.Allow(busMsg, later); ok || event == nil || event.Limited != 9 {
+		t.Errorf("Expected an alert for 9 limited locations, got %v, %+v", ok, event)
+	}
+}
+
+func TestRateLimiter_Sample(t *testing.T) {
+	limiter, err := NewRateLimiter(types.RateLimitConfig{Rate: 1, Burst: 1, Policy: RateLimitSample, SampleEvery: 3, AlertInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	start := time.Unix(1700000000, 0)
+
+	var allowed int
+	for i := 0; i < 10; i++ {
+		if ok, _ := limiter.Allow(types.BusMessage{DriverID: "driver-1"}, start); ok {
+			allowed++
+		}
+	}
+	// One from the burst, then one of every 3 of the 9 excess locations
+	if allowed != 4 {
+		t.Errorf("Expected 4 allowed locations, got %d", allowed)
+	}
+}
+
+func TestNewRateLimiter_RejectsInvalidConfig(t *testing.T) {
+	configs := []types.RateLimitConfig{
+		{Rate: 1, Policy: "drop_newest"},
+		{Rate: 0, Policy: RateLimitThrottle},
+	}
+	for _, config := range configs {
+		if _, err := NewRateLimiter(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
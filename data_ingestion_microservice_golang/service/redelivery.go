@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+)
+
+// redeliveryInterval is how often the broker is asked to redeliver the
+// messages left unacknowledged after failing on a backend
+const redeliveryInterval = 30 * time.Second
+
+// retryFailed takes a message that failed on a backend after its retries
+// out of the broker's in-flight window: it is spilled to disk to be retried
+// later and acknowledged, or, without a spill buffer, counted as stranded
+// until the broker is asked to redeliver it
+func (s *DataIngestionService) retryFailed(topic string, payload []byte, ack func()) {
+	if s.ingest.Retry(topic, payload, ack) {
+		return
+	}
+	s.stranded.Add(1)
+}
+
+// redeliverStranded asks the broker to redeliver the stranded messages
+// every redeliveryInterval until ctx is done
+func (s *DataIngestionService) redeliverStranded(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(redeliveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.redeliver()
+		}
+	}
+}
+
+// redeliver reconnects to the broker, which redelivers the messages the
+// session left unacknowledged, once messages are stranded and the storage
+// breakers let requests through again. The broker stops delivering once its
+// in-flight window is full of unacknowledged messages, so without the
+// reconnect ingestion would stall until a restart.
+func (s *DataIngestionService) redeliver() {
+	if s.stranded.Load() == 0 || !s.active.Load() || !s.redisBreaker.Ready() || !s.mongoBreaker.Ready() {
+		return
+	}
+
+	stranded := s.stranded.Swap(0)
+	if err := s.backends.Broker.Redeliver(); err != nil {
+		s.stranded.Add(stranded)
+		slog.Warn("Error reconnecting to redeliver stranded messages", "messages", stranded, "error", err)
+		return
+	}
+	metrics.IngestRedeliveries.Add(1)
+	slog.Info("Reconnected to the MQTT broker to redeliver stranded messages", "messages", stranded)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// routeOverrides holds the per-route simplification overrides from the
+// configuration and from the route settings collection
+type routeOverrides struct {
+	configured []types.RouteSimplificationOverride
+
+	mu     sync.RWMutex
+	stored []types.RouteSimplificationOverride
+}
+
+// match returns the override for a route: the longest matching pattern,
+// preferring stored overrides over configured ones with the same pattern
+func (o *routeOverrides) match(routeID string) (types.RouteSimplificationOverride, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	var best types.RouteSimplificationOverride
+	found := false
+	for _, overrides := range [][]types.RouteSimplificationOverride{o.stored, o.configured} {
+		for _, override := range overrides {
+			if override.Matches(routeID) && (!found || len(override.Pattern) > len(best.Pattern)) {
+				best, found = override, true
+			}
+		}
+	}
+	return best, found
+}
+
+// setStored replaces the overrides loaded from the route settings collection
+func (o *routeOverrides) setStored(overrides []types.RouteSimplificationOverride) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stored = overrides
+}
+
+// simplificationFor returns the tolerance and algorithm of a route's trips,
+// and the pattern of the override that chose them, if any. A runtime
+// tolerance or algorithm replaces the default one, but not route overrides.
+func (s *DataIngestionService) simplificationFor(routeID string) (tolerance float64, algorithm, pattern string) {
+	tolerance, algorithm = s.simplifier.GetTolerance(), s.simplifier.GetAlgorithm()
+	runtime := s.runtime.get()
+	if runtime.Tolerance > 0 {
+		tolerance = runtime.Tolerance
+	}
+	if runtime.Algorithm != "" {
+		algorithm = runtime.Algorithm
+	}
+	override, ok := s.routeOverrides.match(routeID)
+	if !ok {
+		return tolerance, algorithm, ""
+	}
+	if override.Tolerance > 0 {
+		tolerance = override.Tolerance
+	}
+	if override.Algorithm != "" {
+		algorithm = override.Algorithm
+	}
+	return tolerance, algorithm, override.Pattern
+}
+
+// loadRouteOverrides reads the overrides of the route settings collection,
+// skipping invalid documents
+func (s *DataIngestionService) loadRouteOverrides(ctx context.Context) error {
+	var stored []types.RouteSimplificationOverride
+	err := s.mongoCall(ctx, "mongo.route_settings", func() (err error) {
+		stored, err = s.backends.RouteSettings.LoadRouteOverrides(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	valid := stored[:0]
+	for _, override := range stored {
+		if err := override.Check(); err != nil {
+			slog.WarnContext(ctx, "Ignoring invalid route setting", "error", err)
+			continue
+		}
+		valid = append(valid, override)
+	}
+	s.routeOverrides.setStored(valid)
+	return nil
+}
+
+// refreshRouteOverrides reloads the route settings collection every
+// interval until ctx is done, keeping the previous overrides on failure
+func (s *DataIngestionService) refreshRouteOverrides(ctx context.Context, interval time.Duration) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.loadRouteOverrides(ctx); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh route settings", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"data-ingestion-microservice/config"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryRouteSettings is a route settings collection in memory
+type memoryRouteSettings []types.RouteSimplificationOverride
+
+func (m memoryRouteSettings) LoadRouteOverrides(ctx context.Context) ([]types.RouteSimplificationOverride, error) {
+	return append([]types.RouteSimplificationOverride(nil), m...), nil
+}
+
+func newRouteSettingsService(t *testing.T, backend *memoryBackend, overrides []string, stored memoryRouteSettings) *DataIngestionService {
+	t.Helper()
+	return newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.RouteSimplification.Overrides = overrides
+		backends.RouteSettings = stored
+	})
+}
+
+func TestSimplificationFor_PicksLongestMatchingPattern(t *testing.T) {
+	service := newRouteSettingsService(t, newMemoryBackend(),
+		[]string{"circulator-*=0.00002", "intercity-*=0.0005:visvalingam-whyatt"},
+		memoryRouteSettings{
+			{Pattern: "circulator-7", Algorithm: "visvalingam-whyatt"},
+			{Pattern: "intercity-*", Tolerance: 0.001},
+			{Pattern: "broken-[", Tolerance: 1},
+		})
+
+	tests := []struct {
+		routeID   string
+		tolerance float64
+		algorithm string
+		pattern   string
+	}{
+		{"circulator-3", 0.00002, "douglas-peucker", "circulator-*"},
+		{"circulator-7", 0.0001, "visvalingam-whyatt", "circulator-7"},
+		{"intercity-1", 0.001, "douglas-peucker", "intercity-*"},
+		{"route-1", 0.0001, "douglas-peucker", ""},
+	}
+	for _, test := range tests {
+		tolerance, algorithm, pattern := service.simplificationFor(test.routeID)
+		if tolerance != test.tolerance || algorithm != test.algorithm || pattern != test.pattern {
+			t.Errorf("%s: expected %v %s from %q, got %v %s from %q",
+				test.routeID, test.tolerance, test.algorithm, test.pattern, tolerance, algorithm, pattern)
+		}
+	}
+}
+
+func TestHandleFinished_UsesRouteTolerance(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newRouteSettingsService(t, backend, []string{"loose-*=1", "tight-*=0.000000001"}, nil)
+
+	for _, routeID := range []string{"loose-1", "tight-1"} {
+		for i, location := range [][2]float64{{6.2442, -75.5812}, {6.2455, -75.5820}, {6.2460, -75.5830}} {
+			message := fmt.Sprintf(`{"driverId":"driver-%s","currentRouteId":"%s","status":"in_route","timestamp":%d,"driverLocation":{"latitude":%v,"longitude":%v}}`,
+				routeID, routeID, 1640995200000+i*10000, location[0], location[1])
+			if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		finished := types.BusMessage{DriverID: "driver-" + routeID, CurrentRouteID: routeID, Status: "finished", Timestamp: 1640995230000}
+		if err := service.handleFinished(context.Background(), database.RouteKey(finished.DriverID, routeID), finished); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	simplified := make(map[string]int)
+	for _, trip := range backend.trips {
+		simplified[trip.CurrentRouteID] = trip.SimplifiedPointsCount
+	}
+	if simplified["loose-1"] != 2 || simplified["tight-1"] != 3 {
+		t.Errorf("Expected 2 points on the loose route and 3 on the tight one, got %v", simplified)
+	}
+}
+
+func TestNewDataIngestionService_RejectsInvalidRouteOverrides(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.RouteSimplification.Overrides = []string{"circulator-*=fast"}
+	if _, err := NewDataIngestionServiceWithBackends(context.Background(), cfg, newMemoryBackend().backends()); err == nil {
+		t.Error("Expected an invalid tolerance to be rejected")
+	}
+}
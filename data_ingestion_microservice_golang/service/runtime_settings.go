@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// AuditActionUpdateRuntimeSettings is the audit action recorded for runtime
+// settings changes
+const AuditActionUpdateRuntimeSettings = "runtime_settings.update"
+
+// ErrInvalidRuntimeSettings is returned for runtime settings with negative
+// values, an unknown algorithm, or malformed topic filters
+var ErrInvalidRuntimeSettings = errors.New("invalid runtime settings")
+
+// ErrRuntimeSettingsUnsupported is returned when runtime settings are
+// disabled or the storage backend cannot share them
+var ErrRuntimeSettingsUnsupported = errors.New("runtime settings are not enabled")
+
+// runtimeSettings holds the settings shared through Redis
+type runtimeSettings struct {
+	mu       sync.RWMutex
+	settings types.RuntimeSettings
+}
+
+func (r *runtimeSettings) get() types.RuntimeSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.settings
+}
+
+func (r *runtimeSettings) set(settings types.RuntimeSettings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = settings
+}
+
+// paused reports whether messages on a topic are dropped
+func (r *runtimeSettings) paused(topic string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, filter := range r.settings.PausedTopics {
+		if database.TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuntimeSettings returns the runtime settings in effect
+func (s *DataIngestionService) RuntimeSettings() types.RuntimeSettings {
+	return s.runtime.get()
+}
+
+// UpdateRuntimeSettings replaces the runtime settings of every instance.
+// The settings are stored in Redis and announced to the other instances,
+// which apply them within moments. The change is recorded in the audit log
+// with the previous and new settings.
+func (s *DataIngestionService) UpdateRuntimeSettings(ctx context.Context, settings types.RuntimeSettings, actor string) (types.RuntimeSettings, error) {
+	if !s.runtimeSettingsEnabled() {
+		return types.RuntimeSettings{}, ErrRuntimeSettingsUnsupported
+	}
+	if err := checkRuntimeSettings(settings); err != nil {
+		return types.RuntimeSettings{}, err
+	}
+	settings.UpdatedAt = time.Now().UTC()
+
+	previous := s.runtime.get()
+	err := s.redisCall(ctx, "redis.save_runtime_settings", func() error {
+		return s.backends.Runtime.SaveRuntimeSettings(ctx, settings)
+	})
+	if err != nil {
+		return types.RuntimeSettings{}, err
+	}
+	s.applyRuntimeSettings(ctx, settings)
+
+	// The change is already in effect, so an audit failure is reported
+	// alongside the applied settings
+	err = s.recordAudit(ctx, types.AuditEntry{
+		Action:    AuditActionUpdateRuntimeSettings,
+		Actor:     actor,
+		OldValue:  previous,
+		NewValue:  settings,
+		Timestamp: settings.UpdatedAt,
+	})
+	return settings, err
+}
+
+// runtimeSettingsEnabled reports whether runtime settings are shared
+func (s *DataIngestionService) runtimeSettingsEnabled() bool {
+	return s.config.RuntimeSettings.Enabled && s.backends.Runtime != nil
+}
+
+// checkRuntimeSettings rejects negative values, unknown algorithms, and
+// malformed topic filters
+func checkRuntimeSettings(settings types.RuntimeSettings) error {
+	if settings.Tolerance < 0 || settings.RateLimitRate < 0 || settings.RateLimitBurst < 0 {
+		return fmt.Errorf("%w: tolerance and rate limits must not be negative", ErrInvalidRuntimeSettings)
+	}
+	if settings.Algorithm != "" && !algorithm.ValidAlgorithm(settings.Algorithm) {
+		return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidRuntimeSettings, settings.Algorithm)
+	}
+	for _, filter := range settings.PausedTopics {
+		if !validTopicFilter(filter) {
+			return fmt.Errorf("%w: invalid topic filter %q", ErrInvalidRuntimeSettings, filter)
+		}
+	}
+	return nil
+}
+
+// loadRuntimeSettings applies the runtime settings stored in Redis
+func (s *DataIngestionService) loadRuntimeSettings(ctx context.Context) error {
+	var settings *types.RuntimeSettings
+	err := s.redisCall(ctx, "redis.runtime_settings", func() (err error) {
+		settings, err = s.backends.Runtime.LoadRuntimeSettings(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		settings = &types.RuntimeSettings{}
+	}
+	if err := checkRuntimeSettings(*settings); err != nil {
+		slog.WarnContext(ctx, "Ignoring invalid runtime settings", "error", err)
+		return nil
+	}
+	if settings.UpdatedAt.Equal(s.runtime.get().UpdatedAt) {
+		return nil
+	}
+	s.applyRuntimeSettings(ctx, *settings)
+	return nil
+}
+
+// applyRuntimeSettings switches to the given runtime settings. Settings
+// left at zero fall back to the configuration.
+func (s *DataIngestionService) applyRuntimeSettings(ctx context.Context, settings types.RuntimeSettings) {
+	s.runtime.set(settings)
+
+	if s.limiter != nil {
+		rate, burst := s.config.RateLimit.Rate, s.config.RateLimit.Burst
+		if settings.RateLimitRate > 0 {
+			rate = settings.RateLimitRate
+		}
+		if settings.RateLimitBurst > 0 {
+			burst = settings.RateLimitBurst
+		}
+		s.limiter.SetLimits(rate, burst)
+	}
+
+	slog.InfoContext(ctx, "Applied runtime settings",
+		"tolerance", settings.Tolerance,
+		"algorithm", settings.Algorithm,
+		"rateLimitRate", settings.RateLimitRate,
+		"rateLimitBurst", settings.RateLimitBurst,
+		"pausedTopics", settings.PausedTopics,
+		"updatedAt", settings.UpdatedAt,
+	)
+}
+
+// dropPaused acknowledges and drops a message on a paused topic, reporting
+// whether it did
+func (s *DataIngestionService) dropPaused(topic string, ack func()) bool {
+	if !s.runtime.paused(topic) {
+		return false
+	}
+	metrics.IngestPaused.Add(1)
+	ack()
+	return true
+}
+
+// watchRuntimeSettings applies runtime settings as soon as another instance
+// announces a change, until ctx is done
+func (s *DataIngestionService) watchRuntimeSettings(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	err := s.backends.Runtime.WatchRuntimeSettings(ctx, func() {
+		if err := s.loadRuntimeSettings(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to load announced runtime settings", "error", err)
+		}
+	})
+	if err != nil {
+		slog.Error("Error watching runtime settings", "error", err)
+	}
+}
+
+// refreshRuntimeSettings reloads the runtime settings every interval until
+// ctx is done, so instances that missed an announcement catch up
+func (s *DataIngestionService) refreshRuntimeSettings(ctx context.Context, interval time.Duration) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.loadRuntimeSettings(ctx); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh runtime settings", "error", err)
+			}
+		}
+	}
+}
+
+// validTopicFilter reports whether filter is a valid MQTT topic filter:
+// + stands for a whole level and # only for the last one
+func validTopicFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.ContainsAny(level, "+#") && level != "+" && level != "#" {
+			return false
+		}
+		if level == "#" && i != len(levels)-1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryRuntimeSettings shares runtime settings between the services of a
+// test, which reload them instead of being notified
+type memoryRuntimeSettings struct {
+	settings *types.RuntimeSettings
+}
+
+func (m *memoryRuntimeSettings) LoadRuntimeSettings(ctx context.Context) (*types.RuntimeSettings, error) {
+	return m.settings, nil
+}
+
+func (m *memoryRuntimeSettings) SaveRuntimeSettings(ctx context.Context, settings types.RuntimeSettings) error {
+	m.settings = &settings
+	return nil
+}
+
+func (m *memoryRuntimeSettings) WatchRuntimeSettings(ctx context.Context, handler func()) error {
+	<-ctx.Done()
+	return nil
+}
+
+func newRuntimeSettingsService(t *testing.T, store *memoryRuntimeSettings) *DataIngestionService {
+	t.Helper()
+	return newTestService(t, newMemoryBackend(), func(cfg *types.Config, backends *database.Backends) {
+		cfg.RuntimeSettings.Enabled = true
+		cfg.RateLimit.Enabled = true
+		backends.Runtime = store
+	})
+}
+
+func TestUpdateRuntimeSettings_AppliesToEveryInstance(t *testing.T) {
+	store := &memoryRuntimeSettings{}
+	first := newRuntimeSettingsService(t, store)
+	second := newRuntimeSettingsService(t, store)
+
+	settings, err := first.UpdateRuntimeSettings(context.Background(), types.RuntimeSettings{
+		Tolerance:     0.0005,
+		RateLimitRate: 1,
+		PausedTopics:  []string{"drivers_location/+/route-7"},
+	}, "admin")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if settings.UpdatedAt.IsZero() {
+		t.Error("Expected the update time to be set")
+	}
+
+	// The other instance picks the change up on its next load
+	if err := second.loadRuntimeSettings(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, service := range []*DataIngestionService{first, second} {
+		if tolerance, _, _ := service.simplificationFor("route-1"); tolerance != 0.0005 {
+			t.Errorf("Expected the runtime tolerance, got %v", tolerance)
+		}
+		if !service.runtime.paused("drivers_location/driver-1/route-7") || service.runtime.paused("drivers_location/driver-1/route-8") {
+			t.Error("Expected only route-7 to be paused")
+		}
+	}
+
+	// One location per second passes the lowered rate limit after the burst
+	now := time.Now()
+	busMsg := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "in_route"}
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		if ok, _ := second.limiter.Allow(busMsg, now.Add(time.Duration(i)*100*time.Millisecond)); ok {
+			allowed++
+		}
+	}
+	if burst := first.config.RateLimit.Burst; allowed != burst+1 {
+		t.Errorf("Expected %d locations to pass, got %d", burst+1, allowed)
+	}
+
+	// Replacing the settings falls back to the configuration
+	if _, err := first.UpdateRuntimeSettings(context.Background(), types.RuntimeSettings{}, "admin"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tolerance, _, _ := first.simplificationFor("route-1"); tolerance != first.config.RouteSimplification.Tolerance {
+		t.Errorf("Expected the configured tolerance, got %v", tolerance)
+	}
+}
+
+func TestUpdateRuntimeSettings_RejectsInvalidSettings(t *testing.T) {
+	service := newRuntimeSettingsService(t, &memoryRuntimeSettings{})
+	for _, settings := range []types.RuntimeSettings{
+		{Tolerance: -1},
+		{RateLimitBurst: -1},
+		{Algorithm: "bogus"},
+		{PausedTopics: []string{"drivers_location/#/route-7"}},
+		{PausedTopics: []string{"drivers_location/route+"}},
+	} {
+		if _, err := service.UpdateRuntimeSettings(context.Background(), settings, "admin"); !errors.Is(err, ErrInvalidRuntimeSettings) {
+			t.Errorf("Expected %+v to be rejected, got %v", settings, err)
+		}
+	}
+}
+
+func TestUpdateSimplification_SharesThroughRuntimeSettings(t *testing.T) {
+	store := &memoryRuntimeSettings{}
+	first := newRuntimeSettingsService(t, store)
+	second := newRuntimeSettingsService(t, store)
+
+	if _, err := first.UpdateRuntimeSettings(context.Background(), types.RuntimeSettings{RateLimitRate: 1}, "admin"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	settings, err := first.UpdateSimplification(context.Background(), types.SimplificationSettings{
+		Tolerance: 0.0005,
+		Algorithm: algorithm.AlgorithmVisvalingamWhyatt,
+	}, "admin")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The other settings are kept, and the other instance applies the change
+	if store.settings.RateLimitRate != 1 || store.settings.Tolerance != 0.0005 || store.settings.Algorithm != algorithm.AlgorithmVisvalingamWhyatt {
+		t.Errorf("Expected the simplification to be added to the runtime settings, got %+v", store.settings)
+	}
+	if err := second.loadRuntimeSettings(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := second.SimplificationSettings(); got != settings {
+		t.Errorf("Expected %+v on the other instance, got %+v", settings, got)
+	}
+	if tolerance, name, _ := second.simplificationFor("route-1"); tolerance != 0.0005 || name != algorithm.AlgorithmVisvalingamWhyatt {
+		t.Errorf("Expected the shared settings for new trips, got %v and %s", tolerance, name)
+	}
+}
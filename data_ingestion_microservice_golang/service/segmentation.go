@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// statusSegmented is the status of trips closed because the next point of
+// their route came after too long a pause or too far away
+const statusSegmented = "segmented"
+
+// Reasons a route was split into a new trip
+const (
+	segmentGap  = "gap"
+	segmentJump = "jump"
+)
+
+// TripSegmenter remembers the newest point of every route and tells when a
+// live point is too far in time or distance from it to belong to the same
+// trip. Points without a timestamp or going back in time are never split on
+// and do not become the newest point.
+type TripSegmenter struct {
+	config types.SegmentationConfig
+
+	mu   sync.Mutex
+	last map[string]types.TrackPoint
+}
+
+// NewTripSegmenter creates a trip segmenter
+func NewTripSegmenter(config types.SegmentationConfig) *TripSegmenter {
+	return &TripSegmenter{config: config, last: make(map[string]types.TrackPoint)}
+}
+
+// Split reports whether a point starts a new trip, returning the newest point
+// of the current trip and the reason it ends there. The point is not
+// recorded until Advance.
+func (t *TripSegmenter) Split(key string, point types.TrackPoint) (types.TrackPoint, string, bool) {
+	t.mu.Lock()
+	prev, ok := t.last[key]
+	t.mu.Unlock()
+	if !ok || point.Timestamp <= prev.Timestamp {
+		return types.TrackPoint{}, "", false
+	}
+
+	gap := time.Duration(point.Timestamp-prev.Timestamp) * time.Millisecond
+	if t.config.MaxGap > 0 && gap > t.config.MaxGap {
+		return prev, segmentGap, true
+	}
+	if t.config.MaxJumpKm > 0 && algorithm.HaversineDistance(prev.Location, point.Location) > t.config.MaxJumpKm*1000 {
+		return prev, segmentJump, true
+	}
+	return types.TrackPoint{}, "", false
+}
+
+// Advance records a point stored in its route as the newest one
+func (t *TripSegmenter) Advance(key string, point types.TrackPoint) {
+	if point.Timestamp == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.last[key]; !ok || point.Timestamp > prev.Timestamp {
+		t.last[key] = point
+	}
+}
+
+// Reset forgets a finished route
+func (t *TripSegmenter) Reset(key string) {
+	t.mu.Lock()
+	delete(t.last, key)
+	t.mu.Unlock()
+}
+
+// closeSegment hands the trip of a route up to its newest point prev to
+// finalization, so the point that follows starts a new trip. Finishing the
+// trip resets the per-route state of the detectors, since the pause or jump
+// between the two trips is not driving.
+func (s *DataIngestionService) closeSegment(ctx context.Context, key string, busMsg types.BusMessage, prev types.TrackPoint, reason string) error {
+	closing := types.BusMessage{
+		DriverID:       busMsg.DriverID,
+		VehicleID:      busMsg.VehicleID,
+		DriverLocation: prev.Location,
+		Timestamp:      prev.Timestamp,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Status:         statusSegmented,
+	}
+	slog.InfoContext(ctx, "Splitting route into a new trip", "key", key, "reason", reason,
+		"gapSeconds", float64(busMsg.Timestamp-prev.Timestamp)/1000,
+		"jumpMeters", algorithm.HaversineDistance(prev.Location, busMsg.DriverLocation),
+	)
+	if _, err := s.finishRoute(ctx, key, closing, noAck); err != nil {
+		return err
+	}
+	metrics.RoutesSegmented.Add(reason, 1)
+	return nil
+}
+
+// segmentPoints returns the leading points of a route that belong to a trip
+// closed by segmentation, which ends at the timestamp of the closing
+// message. The points from the first one after it belong to the next trip.
+func segmentPoints(buffered []database.BufferedPoint, end uint64) []database.BufferedPoint {
+	for i, entry := range buffered {
+		if entry.Point.Timestamp > end {
+			return buffered[:i]
+		}
+	}
+	return buffered
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestTripSegmenter_Split(t *testing.T) {
+	segmenter := NewTripSegmenter(types.SegmentationConfig{MaxGap: 10 * time.Minute, MaxJumpKm: 5})
+	key := database.RouteKey("driver-1", "route-1")
+	start := types.TrackPoint{Location: types.Location{Latitude: 6.2442, Longitude: -75.5812}, Timestamp: 1640995200000}
+
+	if _, _, split := segmenter.Split(key, start); split {
+		t.Fatal("Expected the first point of a route not to split it")
+	}
+	segmenter.Advance(key, start)
+
+	tests := []struct {
+		name   string
+		point  types.TrackPoint
+		reason string
+		split  bool
+	}{
+		{"next point", types.TrackPoint{Location: types.Location{Latitude: 6.2450, Longitude: -75.5820}, Timestamp: 1640995210000}, "", false},
+		{"long pause", types.TrackPoint{Location: start.Location, Timestamp: 1640995200000 + 11*60*1000}, segmentGap, true},
+		{"far jump", types.TrackPoint{Location: types.Location{Latitude: 6.3442, Longitude: -75.5812}, Timestamp: 1640995230000}, segmentJump, true},
+		{"no timestamp", types.TrackPoint{Location: types.Location{Latitude: 6.3442, Longitude: -75.5812}}, "", false},
+		{"back in time", types.TrackPoint{Location: types.Location{Latitude: 6.3442, Longitude: -75.5812}, Timestamp: 1640995100000}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, reason, split := segmenter.Split(key, tt.point)
+			if split != tt.split || reason != tt.reason {
+				t.Fatalf("Expected split %v (%q), got %v (%q)", tt.split, tt.reason, split, reason)
+			}
+			if split && prev != start {
+				t.Errorf("Expected the trip to end at %+v, got %+v", start, prev)
+			}
+		})
+	}
+
+	segmenter.Reset(key)
+	if _, _, split := segmenter.Split(key, types.TrackPoint{Timestamp: 1641081600000}); split {
+		t.Error("Expected a reset route not to be split")
+	}
+}
+
+func TestHandleInRoute_SplitsTripAfterLongPause(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, _ *database.Backends) {
+		cfg.Segmentation.MaxGap = 10 * time.Minute
+	})
+
+	// The third point comes an hour after the second, from a device that
+	// never sends "finished"
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995210000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640998810000,"driverLocation":{"latitude":6.2460,"longitude":-75.5830}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	var trip types.Trip
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend.mu.Lock()
+		trips, remaining := len(backend.trips), len(backend.routes[key])
+		for _, stored := range backend.trips {
+			trip = stored
+		}
+		backend.mu.Unlock()
+		if trips == 1 && remaining == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the first trip stored and the last point buffered, got %d trips and %d buffered points", trips, remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if trip.Status != statusSegmented || trip.OriginalPointsCount != 2 || trip.Timestamp != 1640995210000 {
+		t.Errorf("Expected a segmented trip of 2 points ending at the second one, got %+v", trip)
+	}
+	if trip.ID != tripID("driver-1", "route-1", 1640995200000) {
+		t.Errorf("Expected the trip identified by its first point, got %s", trip.ID)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// AuditActionUpdateSimplification is the audit action recorded for
+// simplification changes
+const AuditActionUpdateSimplification = "simplification.update"
+
+// ErrInvalidSimplification is returned for simplification overrides with an
+// unknown algorithm or a non-positive tolerance
+var ErrInvalidSimplification = errors.New("invalid simplification settings")
+
+// SimplificationSettings returns the route simplification settings in effect
+func (s *DataIngestionService) SimplificationSettings() types.SimplificationSettings {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	return s.simplificationSettings()
+}
+
+// simplificationSettings returns the settings in effect, the runtime
+// settings taking precedence over the persisted override. Callers must hold
+// settingsMu.
+func (s *DataIngestionService) simplificationSettings() types.SimplificationSettings {
+	settings := types.SimplificationSettings{
+		Tolerance: s.simplifier.GetTolerance(),
+		Algorithm: s.simplifier.GetAlgorithm(),
+		UpdatedAt: s.settingsUpdatedAt,
+	}
+	runtime := s.runtime.get()
+	if runtime.Tolerance > 0 {
+		settings.Tolerance = runtime.Tolerance
+		settings.UpdatedAt = runtime.UpdatedAt
+	}
+	if runtime.Algorithm != "" {
+		settings.Algorithm = runtime.Algorithm
+		settings.UpdatedAt = runtime.UpdatedAt
+	}
+	return settings
+}
+
+// UpdateSimplification changes the tolerance and algorithm used for every
+// trip finalized from now on. Zero fields keep their current value. With
+// runtime settings enabled, the change is stored in the runtime settings so
+// that every instance applies it; otherwise the override is persisted in
+// the settings store so it survives restarts. The change is recorded in the
+// audit log with the previous and new settings.
+func (s *DataIngestionService) UpdateSimplification(ctx context.Context, update types.SimplificationSettings, actor string) (types.SimplificationSettings, error) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	previous := s.simplificationSettings()
+	settings := previous
+	settings.UpdatedAt = time.Now().UTC()
+	if update.Tolerance != 0 {
+		settings.Tolerance = update.Tolerance
+	}
+	if update.Algorithm != "" {
+		settings.Algorithm = update.Algorithm
+	}
+
+	if settings.Tolerance <= 0 {
+		return types.SimplificationSettings{}, fmt.Errorf("%w: tolerance must be positive", ErrInvalidSimplification)
+	}
+	if !algorithm.ValidAlgorithm(settings.Algorithm) {
+		return types.SimplificationSettings{}, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidSimplification, settings.Algorithm)
+	}
+
+	if s.runtimeSettingsEnabled() {
+		runtime := s.runtime.get()
+		runtime.Tolerance = settings.Tolerance
+		runtime.Algorithm = settings.Algorithm
+		runtime.UpdatedAt = settings.UpdatedAt
+		err := s.redisCall(ctx, "redis.save_runtime_settings", func() error {
+			return s.backends.Runtime.SaveRuntimeSettings(ctx, runtime)
+		})
+		if err != nil {
+			return types.SimplificationSettings{}, err
+		}
+		s.applyRuntimeSettings(ctx, runtime)
+	} else {
+		// Persist before applying so a failed save leaves the service unchanged
+		if s.backends.Settings != nil {
+			if err := s.backends.Settings.SaveSimplificationSettings(ctx, settings); err != nil {
+				return types.SimplificationSettings{}, err
+			}
+		}
+		s.applySimplification(settings)
+	}
+	slog.InfoContext(ctx, "Updated route simplification", "algorithm", settings.Algorithm, "tolerance", settings.Tolerance)
+
+	// The change is already in effect, so an audit failure is reported
+	// alongside the applied settings
+	err := s.recordAudit(ctx, types.AuditEntry{
+		Action:    AuditActionUpdateSimplification,
+		Actor:     actor,
+		OldValue:  previous,
+		NewValue:  settings,
+		Timestamp: settings.UpdatedAt,
+	})
+	return settings, err
+}
+
+// loadSimplificationSettings applies the persisted simplification override
+func (s *DataIngestionService) loadSimplificationSettings(ctx context.Context) error {
+	if s.backends.Settings == nil {
+		return nil
+	}
+
+	settings, err := s.backends.Settings.LoadSimplificationSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		return nil
+	}
+	if settings.Tolerance <= 0 || !algorithm.ValidAlgorithm(settings.Algorithm) {
+		slog.WarnContext(ctx, "Ignoring invalid simplification override", "algorithm", settings.Algorithm, "tolerance", settings.Tolerance)
+		return nil
+	}
+
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.applySimplification(*settings)
+	slog.InfoContext(ctx, "Using simplification override",
+		"algorithm", settings.Algorithm,
+		"tolerance", settings.Tolerance,
+		"updatedAt", settings.UpdatedAt,
+	)
+
+	return nil
+}
+
+// applySimplification switches the simplifier to the given settings.
+// Callers must hold settingsMu.
+func (s *DataIngestionService) applySimplification(settings types.SimplificationSettings) {
+	s.simplifier.SetTolerance(settings.Tolerance)
+	s.simplifier.SetAlgorithm(settings.Algorithm)
+	s.settingsUpdatedAt = settings.UpdatedAt
+}
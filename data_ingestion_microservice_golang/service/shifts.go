@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// Statuses of the messages opening and closing a driver's shift
+const (
+	statusShiftStart = "shift_start"
+	statusShiftEnd   = "shift_end"
+)
+
+// ErrShiftsDisabled is returned for shift reads when shift tracking is
+// disabled
+var ErrShiftsDisabled = errors.New("shift tracking is disabled")
+
+// isShiftMessage reports whether a message opens or closes a shift rather
+// than reporting a location
+func isShiftMessage(busMsg types.BusMessage) bool {
+	return busMsg.Status == statusShiftStart || busMsg.Status == statusShiftEnd
+}
+
+// handleShift opens or closes the driver's shift at the message timestamp,
+// or when it was received if it has none
+func (s *DataIngestionService) handleShift(ctx context.Context, busMsg types.BusMessage) error {
+	if s.shifts == nil {
+		slog.DebugContext(ctx, "Ignoring shift message, shift tracking is disabled", "status", busMsg.Status)
+		return nil
+	}
+
+	at := time.Now().UTC()
+	if busMsg.Timestamp > 0 {
+		at = time.UnixMilli(int64(busMsg.Timestamp)).UTC()
+	}
+
+	if busMsg.Status == statusShiftStart {
+		err := s.mongoCall(ctx, "mongo.shift", func() error {
+			return s.shifts.StartShift(ctx, busMsg.DriverID, at)
+		})
+		if err != nil {
+			return classify(FailureMongo, err)
+		}
+		slog.InfoContext(ctx, "Started shift", "startedAt", at)
+		return nil
+	}
+
+	var ended bool
+	err := s.mongoCall(ctx, "mongo.shift", func() (err error) {
+		ended, err = s.shifts.EndShift(ctx, busMsg.DriverID, at)
+		return err
+	})
+	if err != nil {
+		return classify(FailureMongo, err)
+	}
+	if !ended {
+		slog.WarnContext(ctx, "Ignoring shift end without an open shift", "endedAt", at)
+		return nil
+	}
+	slog.InfoContext(ctx, "Ended shift", "endedAt", at)
+	return nil
+}
+
+// recordShiftTrip counts a finalized trip, which started at startTimestamp,
+// in the driver's shift
+func (s *DataIngestionService) recordShiftTrip(ctx context.Context, trip types.Trip, startTimestamp uint64) error {
+	shiftTrip := types.ShiftTrip{
+		TripID:         trip.ID,
+		RouteID:        trip.CurrentRouteID,
+		StartedAt:      time.UnixMilli(int64(startTimestamp)).UTC(),
+		EndedAt:        time.UnixMilli(trip.Timestamp).UTC(),
+		DistanceMeters: trip.Stats.DistanceMeters,
+		DrivingSeconds: trip.Stats.MovingSeconds,
+	}
+	day := shiftTrip.StartedAt.In(s.shiftLocation).Format(time.DateOnly)
+
+	err := s.mongoCall(ctx, "mongo.shift", func() error {
+		return s.shifts.AddShiftTrip(ctx, trip.DriverID, day, shiftTrip)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count trip in shift: %w", err)
+	}
+	return nil
+}
+
+// Shifts returns a driver's shifts, newest first, with the breaks between
+// their trips
+func (s *DataIngestionService) Shifts(ctx context.Context, query types.ShiftQuery) (types.ShiftPage, error) {
+	if s.shifts == nil {
+		return types.ShiftPage{}, ErrShiftsDisabled
+	}
+	shifts, err := s.shifts.FindShifts(ctx, query)
+	if err != nil {
+		return types.ShiftPage{}, err
+	}
+	for i := range shifts {
+		summarizeBreaks(&shifts[i], s.config.Shifts.MinBreak)
+	}
+	return types.ShiftPage{DriverID: query.DriverID, Shifts: shifts}, nil
+}
+
+// summarizeBreaks orders the trips of a shift and lists the pauses of at
+// least minBreak between them. A pause starts when every earlier trip has
+// ended, so overlapping trips do not make one.
+func summarizeBreaks(shift *types.Shift, minBreak time.Duration) {
+	slices.SortFunc(shift.Trips, func(a, b types.ShiftTrip) int { return a.StartedAt.Compare(b.StartedAt) })
+
+	shift.Breaks = []types.ShiftBreak{}
+	shift.BreakSeconds = 0
+	var lastEnd time.Time
+	for i, trip := range shift.Trips {
+		if i > 0 && trip.StartedAt.Sub(lastEnd) >= minBreak && trip.StartedAt.After(lastEnd) {
+			pause := types.ShiftBreak{
+				Start:           lastEnd,
+				End:             trip.StartedAt,
+				DurationSeconds: trip.StartedAt.Sub(lastEnd).Seconds(),
+			}
+			shift.Breaks = append(shift.Breaks, pause)
+			shift.BreakSeconds += pause.DurationSeconds
+		}
+		if trip.EndedAt.After(lastEnd) {
+			lastEnd = trip.EndedAt
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestSummarizeBreaks(t *testing.T) {
+	start := time.Date(2022, 1, 1, 6, 0, 0, 0, time.UTC)
+	trip := func(id string, from, to time.Duration) types.ShiftTrip {
+		return types.ShiftTrip{TripID: id, StartedAt: start.Add(from), EndedAt: start.Add(to)}
+	}
+	shift := types.Shift{Trips: []types.ShiftTrip{
+		trip("trip-3", 4*time.Hour, 5*time.Hour),
+		trip("trip-1", 0, time.Hour),
+		trip("trip-2", time.Hour+5*time.Minute, 3*time.Hour),
+		// Overlaps trip-2, so only the pause after both counts
+		trip("trip-2b", 2*time.Hour, 3*time.Hour+30*time.Minute),
+	}}
+
+	summarizeBreaks(&shift, 15*time.Minute)
+	if shift.Trips[0].TripID != "trip-1" {
+		t.Errorf("Expected trips in start order, got %+v", shift.Trips)
+	}
+	if len(shift.Breaks) != 1 {
+		t.Fatalf("Expected 1 break, got %+v", shift.Breaks)
+	}
+	if !shift.Breaks[0].Start.Equal(start.Add(3*time.Hour+30*time.Minute)) || shift.BreakSeconds != 1800 {
+		t.Errorf("Expected a 30 minute break after trip-2b, got %+v", shift.Breaks[0])
+	}
+}
+
+func TestShifts_CountsTripsOfExplicitShift(t *testing.T) {
+	store, err := database.NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), database.CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Shifts.Enabled = true
+		backends.Shifts = store
+	})
+
+	messages := []string{
+		`{"driverId":"driver-1","status":"shift_start","timestamp":1640995000000}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"finished","timestamp":1640995320000,"driverLocation":{"latitude":6.2460,"longitude":-75.5830}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	var page types.ShiftPage
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err = service.Shifts(context.Background(), types.ShiftQuery{DriverID: "driver-1"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(page.Shifts) == 1 && page.Shifts[0].TripCount == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected one shift with the trip, got %+v", page.Shifts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	shift := page.Shifts[0]
+	if shift.Inferred || shift.ID != database.ShiftID("driver-1", time.UnixMilli(1640995000000)) {
+		t.Errorf("Expected the trip in the started shift, got %+v", shift)
+	}
+	if shift.Trips[0].TripID != tripID("driver-1", "route-1", 1640995200000) {
+		t.Errorf("Expected the finished trip, got %+v", shift.Trips[0])
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Reasons a message signature is rejected
+const (
+	SignatureMissing       = "missing"
+	SignatureUnknownDevice = "unknown_device"
+	SignatureInvalid       = "invalid"
+	SignatureExpired       = "expired"
+)
+
+// signatureMember matches a signature member closing a JSON object. The
+// signature covers the payload without it, byte for byte: devices serialize
+// the message, sign it, and insert "signature" as the last member before
+// the closing brace. A signature anywhere else is not recognized.
+var signatureMember = regexp.MustCompile(`,\s*"signature"\s*:\s*"([^"]*)"\s*}\s*$`)
+
+// SignatureVerifier checks the HMAC-SHA256 signatures of device messages
+// against the keys of the device registry
+type SignatureVerifier struct {
+	config types.SignatureConfig
+	store  database.DeviceRegistry
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+// NewSignatureVerifier creates a signature verifier with no device keys
+// until they are loaded
+func NewSignatureVerifier(config types.SignatureConfig, store database.DeviceRegistry) *SignatureVerifier {
+	return &SignatureVerifier{config: config, store: store, keys: make(map[string][]byte)}
+}
+
+// Load replaces the device keys with those of the registry. Devices without
+// a key are skipped with a warning.
+func (v *SignatureVerifier) Load(ctx context.Context) error {
+	devices, err := v.store.LoadDeviceKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string][]byte, len(devices))
+	for _, device := range devices {
+		if device.Key == "" {
+			slog.WarnContext(ctx, "Ignoring device without a key", "deviceId", device.DeviceID)
+			continue
+		}
+		keys[device.DeviceID] = []byte(device.Key)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+
+	metrics.DeviceKeysLoaded.Set(int64(len(keys)))
+	return nil
+}
+
+// Verify checks the signature of a device's message payload, returning the
+// reason it is rejected or an empty string. Devices with a key must sign
+// their messages, and so must the others when signatures are required. A
+// signed message must be timestamped (milliseconds) within the configured
+// window of now, so that it cannot be replayed later.
+func (v *SignatureVerifier) Verify(deviceID string, payload []byte, timestamp uint64, now time.Time) string {
+	v.mu.Lock()
+	key, registered := v.keys[deviceID]
+	v.mu.Unlock()
+
+	signed, signature, ok := splitSignature(payload)
+	switch {
+	case !ok && (registered || v.config.Required):
+		return SignatureMissing
+	case !ok:
+		return ""
+	case !registered:
+		return SignatureUnknownDevice
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return SignatureInvalid
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(signed)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return SignatureInvalid
+	}
+
+	age := now.Sub(time.UnixMilli(int64(timestamp)))
+	if timestamp == 0 || age > v.config.Window || age < -v.config.Window {
+		return SignatureExpired
+	}
+	return ""
+}
+
+// splitSignature returns the signed part of a payload and its signature, or
+// false when it has none
+func splitSignature(payload []byte) ([]byte, string, bool) {
+	match := signatureMember.FindSubmatchIndex(payload)
+	if match == nil {
+		return nil, "", false
+	}
+	signed := append(payload[:match[0]:match[0]], '}')
+	return signed, string(payload[match[2]:match[3]]), true
+}
+
+// signedFields are the members of a message its signature is checked with
+type signedFields struct {
+	DriverID  string `json:"driverId"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// verifySignature reports whether a received message may be queued,
+// counting and logging the messages dropped for their signature. Messages
+// that do not decode are let through for processMessage to record. The
+// broker redelivers unacknowledged messages long after they were signed,
+// so a redelivered message must be signed correctly but is not expired.
+func (s *DataIngestionService) verifySignature(ctx context.Context, payload []byte, redelivered bool) bool {
+	var fields signedFields
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return true
+	}
+	reason := s.signatures.Verify(fields.DriverID, payload, fields.Timestamp, time.Now())
+	if reason == "" || reason == SignatureExpired && redelivered {
+		return true
+	}
+	metrics.SignaturesRejected.Add(reason, 1)
+	slog.DebugContext(ctx, "Dropped message with a rejected signature", "driverId", fields.DriverID, "reason", reason)
+	return false
+}
+
+// refreshDeviceKeys reloads the device keys every interval until ctx is
+// done, keeping the previous keys on failure
+func (s *DataIngestionService) refreshDeviceKeys(ctx context.Context, interval time.Duration) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.signatures.Load(ctx); err != nil {
+				slog.WarnContext(ctx, "Failed to refresh device keys", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryDevices serves a fixed list of device keys
+type memoryDevices []types.DeviceKey
+
+func (m memoryDevices) LoadDeviceKeys(ctx context.Context) ([]types.DeviceKey, error) {
+	return m, nil
+}
+
+// signMessage appends the signature of a message as its last member
+func signMessage(message, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return fmt.Sprintf(`%s,"signature":"%s"}`, message[:len(message)-1], hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestSignatureVerifier_Verify(t *testing.T) {
+	verifier := NewSignatureVerifier(types.SignatureConfig{Window: 5 * time.Minute}, memoryDevices{
+		{DeviceID: "driver-1", Key: "secret"},
+		{DeviceID: "driver-3"},
+	})
+	if err := verifier.Load(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","driverLocation":{"latitude":6.25,"longitude":-75.56}}`
+	signed := signMessage(message, "secret")
+	tests := []struct {
+		name     string
+		deviceID string
+		payload  string
+		want     string
+	}{
+		{"signed", "driver-1", signed, ""},
+		{"signed with trailing whitespace", "driver-1", signed[:len(signed)-1] + " }\n", ""},
+		{"tampered", "driver-1", signed[:30] + "2" + signed[31:], SignatureInvalid},
+		{"signed with another key", "driver-1", signMessage(message, "guess"), SignatureInvalid},
+		{"not hex", "driver-1", message[:len(message)-1] + `,"signature":"xyz"}`, SignatureInvalid},
+		{"unsigned from a registered device", "driver-1", message, SignatureMissing},
+		{"unsigned from an unregistered device", "driver-2", message, ""},
+		{"signed by an unregistered device", "driver-2", signed, SignatureUnknownDevice},
+		{"device without a key", "driver-3", signed, SignatureUnknownDevice},
+	}
+	const timestamp = 1640995200000
+	now := time.UnixMilli(timestamp)
+	for _, tt := range tests {
+		if got := verifier.Verify(tt.deviceID, []byte(tt.payload), timestamp, now); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	// Signed messages replayed later, or timestamped ahead, are rejected
+	windows := []struct {
+		name      string
+		timestamp uint64
+		want      string
+	}{
+		{"within the window", timestamp - 4*60*1000, ""},
+		{"replayed after the window", timestamp - 6*60*1000, SignatureExpired},
+		{"ahead of the window", timestamp + 6*60*1000, SignatureExpired},
+		{"without a timestamp", 0, SignatureExpired},
+	}
+	for _, tt := range windows {
+		if got := verifier.Verify("driver-1", []byte(signed), tt.timestamp, now); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	verifier.config.Required = true
+	if got := verifier.Verify("driver-2", []byte(message), timestamp, now); got != SignatureMissing {
+		t.Errorf("Expected unsigned messages to be rejected when required, got %q", got)
+	}
+}
+
+// receivedMessage is an MQTT message as delivered by the broker
+type receivedMessage struct {
+	payload     []byte
+	redelivered bool
+	acked       bool
+}
+
+func (m *receivedMessage) Duplicate() bool   { return m.redelivered }
+func (m *receivedMessage) Qos() byte         { return 1 }
+func (m *receivedMessage) Retained() bool    { return false }
+func (m *receivedMessage) Topic() string     { return "drivers_location/driver-1" }
+func (m *receivedMessage) MessageID() uint16 { return 1 }
+func (m *receivedMessage) Payload() []byte   { return m.payload }
+func (m *receivedMessage) Ack()              { m.acked = true }
+
+func TestMessageHandler_DropsRejectedSignatures(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Signatures.Enabled = true
+		backends.Devices = memoryDevices{{DeviceID: "driver-1", Key: "secret"}}
+	})
+
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":%d,"driverLocation":{"latitude":6.25,"longitude":-75.56}}`
+	now := uint64(time.Now().UnixMilli())
+	const old = 1640995200000
+	received := []*receivedMessage{
+		{payload: []byte(signMessage(fmt.Sprintf(message, now), "secret"))},
+		{payload: []byte(signMessage(fmt.Sprintf(message, now+10000), "guess"))},
+		{payload: []byte(fmt.Sprintf(message, now+20000))},
+		{payload: []byte(signMessage(fmt.Sprintf(message, old), "secret"))},
+		// Redelivered by the broker long after they were signed
+		{payload: []byte(signMessage(fmt.Sprintf(message, old+10000), "secret")), redelivered: true},
+		{payload: []byte(signMessage(fmt.Sprintf(message, old+20000), "guess")), redelivered: true},
+	}
+	for _, msg := range received {
+		service.messageHandler(nil, msg)
+	}
+	if err := service.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i, msg := range received {
+		if !msg.acked {
+			t.Errorf("Expected message %d to be acknowledged", i)
+		}
+	}
+	points := backend.routes[database.RouteKey("driver-1", "route-1")]
+	if len(points) != 2 || points[0].Point.Timestamp != now || points[1].Point.Timestamp != old+10000 {
+		t.Errorf("Expected only the recently signed and the redelivered locations to be buffered, got %+v", points)
+	}
+}
+
+func TestHandleMessage_AcceptsQueuedSignedMessages(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.Signatures.Enabled = true
+		backends.Devices = memoryDevices{{DeviceID: "driver-1", Key: "secret"}}
+	})
+
+	// A message verified on receipt may be processed from the spill buffer
+	// long after the signature window, and is not dropped then
+	message := signMessage(`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.25,"longitude":-75.56}}`, "secret")
+	acked := false
+	service.handleMessage("drivers_location/driver-1", []byte(message), func() { acked = true })
+
+	if points := backend.routes[database.RouteKey("driver-1", "route-1")]; !acked || len(points) != 1 {
+		t.Errorf("Expected the queued location to be buffered and acknowledged, got %+v", points)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// kmhPerMph converts OSM maxspeed values given in miles per hour
+const kmhPerMph = 1.609344
+
+// roadSegment is a straight piece of a road with its speed limit
+type roadSegment struct {
+	from, to types.Location
+	limitKmh float64
+}
+
+// roadSpeedLimits finds the speed limit of the road nearest to a location.
+// Segments are listed in the geofence grid cells their bounding box covers,
+// so a location is only tested against the roads around its cell. It is
+// immutable once built.
+type roadSpeedLimits struct {
+	segments   []roadSegment
+	cells      map[geofenceCell][]int
+	large      []int
+	snapMeters float64
+}
+
+// roadLimitsFile is a GeoJSON FeatureCollection of roads tagged with their
+// OSM maxspeed, as exported from an OSM extract by osmium or ogr2ogr
+type roadLimitsFile struct {
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+// loadRoadSpeedLimits reads the roads of a GeoJSON file. Features that are
+// not LineStrings or MultiLineStrings, or lack a numeric maxspeed, are
+// skipped.
+func loadRoadSpeedLimits(path string, snapMeters float64) (*roadSpeedLimits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read road speed limits: %w", err)
+	}
+	var file roadLimitsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse road speed limits %s: %w", path, err)
+	}
+
+	roads := &roadSpeedLimits{cells: make(map[geofenceCell][]int), snapMeters: snapMeters}
+	for i, feature := range file.Features {
+		limitKmh, ok := parseMaxSpeed(feature.Properties["maxspeed"])
+		if !ok {
+			continue
+		}
+
+		var lines [][][]float64
+		switch feature.Geometry.Type {
+		case "LineString":
+			var line [][]float64
+			err = json.Unmarshal(feature.Geometry.Coordinates, &line)
+			lines = [][][]float64{line}
+		case "MultiLineString":
+			err = json.Unmarshal(feature.Geometry.Coordinates, &lines)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse road speed limits %s: feature %d: %w", path, i, err)
+		}
+
+		for _, line := range lines {
+			for j := 1; j < len(line); j++ {
+				if len(line[j-1]) < 2 || len(line[j]) < 2 {
+					return nil, fmt.Errorf("failed to parse road speed limits %s: feature %d: expected [longitude, latitude] positions", path, i)
+				}
+				roads.add(roadSegment{
+					from:     types.Location{Latitude: line[j-1][1], Longitude: line[j-1][0]},
+					to:       types.Location{Latitude: line[j][1], Longitude: line[j][0]},
+					limitKmh: limitKmh,
+				})
+			}
+		}
+	}
+	return roads, nil
+}
+
+// parseMaxSpeed parses an OSM maxspeed value such as 50, "50", or "30 mph".
+// The first of several values separated by semicolons is used, and values
+// such as "none" or "signals" have no limit.
+func parseMaxSpeed(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, value > 0
+	case string:
+		value = strings.TrimSpace(strings.Split(value, ";")[0])
+		factor := 1.0
+		if number, ok := strings.CutSuffix(value, "mph"); ok {
+			value, factor = strings.TrimSpace(number), kmhPerMph
+		}
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil || speed <= 0 {
+			return 0, false
+		}
+		return speed * factor, true
+	default:
+		return 0, false
+	}
+}
+
+// add indexes a road segment
+func (r *roadSpeedLimits) add(segment roadSegment) {
+	i := len(r.segments)
+	r.segments = append(r.segments, segment)
+
+	low := cellOf(min(segment.from.Latitude, segment.to.Latitude), min(segment.from.Longitude, segment.to.Longitude))
+	high := cellOf(max(segment.from.Latitude, segment.to.Latitude), max(segment.from.Longitude, segment.to.Longitude))
+	if (high.lat-low.lat+1)*(high.lon-low.lon+1) > maxGeofenceCells {
+		r.large = append(r.large, i)
+		return
+	}
+	for lat := low.lat; lat <= high.lat; lat++ {
+		for lon := low.lon; lon <= high.lon; lon++ {
+			cell := geofenceCell{lat, lon}
+			r.cells[cell] = append(r.cells[cell], i)
+		}
+	}
+}
+
+// limit returns the speed limit of the nearest road within the snap
+// distance of a location. The cells around the location's cell are checked
+// too, since a nearby road may lie across a cell border.
+func (r *roadSpeedLimits) limit(location types.Location) (float64, bool) {
+	nearest, limitKmh := r.snapMeters, 0.0
+	found := false
+	check := func(i int) {
+		segment := r.segments[i]
+		distance := algorithm.CrossTrackDistance(location, []types.Location{segment.from, segment.to})
+		if distance <= nearest {
+			nearest, limitKmh, found = distance, segment.limitKmh, true
+		}
+	}
+
+	center := cellOf(location.Latitude, location.Longitude)
+	for lat := center.lat - 1; lat <= center.lat+1; lat++ {
+		for lon := center.lon - 1; lon <= center.lon+1; lon++ {
+			for _, i := range r.cells[geofenceCell{lat, lon}] {
+				check(i)
+			}
+		}
+	}
+	for _, i := range r.large {
+		check(i)
+	}
+	return limitKmh, found
+}
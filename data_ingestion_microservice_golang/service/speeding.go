@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// EventSpeeding is the type of speeding events
+const EventSpeeding = "speeding"
+
+// speedingState tracks the speeding of a single driver route
+type speedingState struct {
+	tracker *algorithm.SpeedingTracker
+	// reportedStart is the start of the last violation reported
+	reportedStart uint64
+}
+
+// SpeedingDetector follows the speed of every route between its live points
+// and publishes an event when a driver keeps speeding for the minimum
+// duration
+type SpeedingDetector struct {
+	config types.SpeedingConfig
+	roads  *roadSpeedLimits
+	broker database.MessageBroker
+
+	mu     sync.Mutex
+	states map[string]*speedingState
+}
+
+// NewSpeedingDetector creates a speeding detector, loading the road speed
+// limits file when one is configured
+func NewSpeedingDetector(config types.SpeedingConfig, broker database.MessageBroker) (*SpeedingDetector, error) {
+	detector := &SpeedingDetector{
+		config: config,
+		broker: broker,
+		states: make(map[string]*speedingState),
+	}
+	if config.RoadLimitsFile != "" {
+		roads, err := loadRoadSpeedLimits(config.RoadLimitsFile, config.RoadSnapMeters)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Loaded road speed limits", "file", config.RoadLimitsFile, "segments", len(roads.segments))
+		detector.roads = roads
+	}
+	return detector, nil
+}
+
+// Check adds a live point to its route and publishes a speeding event when
+// the driver has been speeding for the minimum duration. Each violation is
+// reported once. The published event is returned, or nil when nothing was
+// reported.
+func (d *SpeedingDetector) Check(ctx context.Context, key string, busMsg types.BusMessage) (*types.SpeedingEvent, error) {
+	d.mu.Lock()
+	state, ok := d.states[key]
+	if !ok {
+		state = &speedingState{tracker: d.tracker()}
+		d.states[key] = state
+	}
+	ongoing, _ := state.tracker.Add(types.TrackPoint{Location: busMsg.DriverLocation, Timestamp: busMsg.Timestamp})
+	shouldReport := ongoing != nil && ongoing.StartTimestamp != state.reportedStart
+	var violation types.SpeedingViolation
+	if shouldReport {
+		state.reportedStart = ongoing.StartTimestamp
+		violation = *ongoing
+	}
+	d.mu.Unlock()
+
+	if !shouldReport {
+		return nil, nil
+	}
+
+	event := types.SpeedingEvent{
+		Type:           EventSpeeding,
+		DriverID:       busMsg.DriverID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Location:       busMsg.DriverLocation,
+		Timestamp:      busMsg.Timestamp,
+		Violation:      violation,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal speeding event: %w", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s", d.config.EventTopic, busMsg.CurrentRouteID)
+	if err := d.broker.PublishMessage(topic, payload); err != nil {
+		return nil, fmt.Errorf("failed to publish speeding event: %w", err)
+	}
+
+	metrics.SpeedingEvents.Add(1)
+	slog.WarnContext(ctx, "Driver is speeding",
+		"speedKmh", violation.MaxSpeedKmh,
+		"limitKmh", violation.LimitKmh,
+		"durationSeconds", violation.DurationSeconds,
+	)
+	return &event, nil
+}
+
+// Violations returns the speeding violations of a trip's raw points
+func (d *SpeedingDetector) Violations(points []types.TrackPoint) []types.SpeedingViolation {
+	return algorithm.DetectSpeeding(points, d.limit, d.config.ToleranceKmh, d.config.MinDuration.Seconds())
+}
+
+// Reset discards the speeding state for a finished route
+func (d *SpeedingDetector) Reset(key string) {
+	d.mu.Lock()
+	delete(d.states, key)
+	d.mu.Unlock()
+}
+
+// tracker creates a speeding tracker for a route
+func (d *SpeedingDetector) tracker() *algorithm.SpeedingTracker {
+	return algorithm.NewSpeedingTracker(d.limit, d.config.ToleranceKmh, d.config.MinDuration.Seconds())
+}
+
+// limit returns the speed limit of the road at a location, or the default
+// limit away from the known roads
+func (d *SpeedingDetector) limit(location types.Location) float64 {
+	if d.roads != nil {
+		if limitKmh, ok := d.roads.limit(location); ok {
+			return limitKmh
+		}
+	}
+	return d.config.DefaultLimitKmh
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// testRoadLimits is a 30 km/h street along the equator and an unlimited
+// road north of it
+const testRoadLimits = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"maxspeed": "30"}, "geometry": {"type": "LineString", "coordinates": [[0.0, 0.0], [0.01, 0.0]]}},
+	{"type": "Feature", "properties": {"maxspeed": "20 mph"}, "geometry": {"type": "MultiLineString", "coordinates": [[[0.0, 0.005], [0.01, 0.005]]]}},
+	{"type": "Feature", "properties": {"maxspeed": "none"}, "geometry": {"type": "LineString", "coordinates": [[0.0, 0.01], [0.01, 0.01]]}},
+	{"type": "Feature", "properties": {"name": "depot"}, "geometry": {"type": "Point", "coordinates": [0.0, 0.0]}}
+]}`
+
+func writeRoadLimits(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "roads.geojson")
+	if err := os.WriteFile(path, []byte(testRoadLimits), 0o600); err != nil {
+		t.Fatalf("Failed to write road limits: %v", err)
+	}
+	return path
+}
+
+func TestLoadRoadSpeedLimits(t *testing.T) {
+	roads, err := loadRoadSpeedLimits(writeRoadLimits(t), 25)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(roads.segments) != 2 {
+		t.Fatalf("Expected 2 road segments with a limit, got %d", len(roads.segments))
+	}
+
+	tests := []struct {
+		name     string
+		location types.Location
+		limit    float64
+		found    bool
+	}{
+		{"on the street", types.Location{Latitude: 0.0001, Longitude: 0.005}, 30, true},
+		{"on the mph road", types.Location{Latitude: 0.005, Longitude: 0.002}, 20 * kmhPerMph, true},
+		{"off every road", types.Location{Latitude: 0.002, Longitude: 0.005}, 0, false},
+		{"on the unlimited road", types.Location{Latitude: 0.01, Longitude: 0.005}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, found := roads.limit(tt.location)
+			if found != tt.found || math.Abs(limit-tt.limit) > 1e-9 {
+				t.Errorf("Expected limit %v (found %v), got %v (found %v)", tt.limit, tt.found, limit, found)
+			}
+		})
+	}
+}
+
+func TestParseMaxSpeed(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  float64
+		ok    bool
+	}{
+		{50.0, 50, true},
+		{"50", 50, true},
+		{"60;40", 60, true},
+		{"30 mph", 30 * kmhPerMph, true},
+		{"signals", 0, false},
+		{"0", 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseMaxSpeed(tt.value)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseMaxSpeed(%v): expected %v %v, got %v %v", tt.value, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestSpeedingDetector_ReportsEachViolationOnce(t *testing.T) {
+	broker := &recordingBroker{}
+	detector, err := NewSpeedingDetector(types.SpeedingConfig{
+		DefaultLimitKmh: 80,
+		ToleranceKmh:    5,
+		MinDuration:     10 * time.Second,
+		RoadLimitsFile:  writeRoadLimits(t),
+		RoadSnapMeters:  25,
+		EventTopic:      "events/speeding",
+	}, broker)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// About 80 km/h along the 30 km/h street, which is within the default
+	// limit but far above the street's
+	key := database.RouteKey("driver-1", "route-1")
+	var reported []*types.SpeedingEvent
+	for i := 0; i < 5; i++ {
+		busMsg := types.BusMessage{
+			DriverID:       "driver-1",
+			CurrentRouteID: "route-1",
+			DriverLocation: types.Location{Latitude: 0, Longitude: 0.001 * float64(i)},
+			Timestamp:      1640995200000 + uint64(i)*5000,
+		}
+		event, err := detector.Check(context.Background(), key, busMsg)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if event != nil {
+			reported = append(reported, event)
+		}
+	}
+	if len(reported) != 1 {
+		t.Fatalf("Expected the violation reported once, got %d events", len(reported))
+	}
+	if reported[0].Violation.LimitKmh != 30 || reported[0].Violation.DurationSeconds != 10 {
+		t.Errorf("Expected 10s above 30 km/h to be reported, got %+v", reported[0].Violation)
+	}
+
+	published := broker.published["events/speeding/route-1"]
+	if len(published) != 1 {
+		t.Fatalf("Expected 1 speeding event published, got %d", len(published))
+	}
+	var event types.SpeedingEvent
+	if err := json.Unmarshal(published[0], &event); err != nil {
+		t.Fatalf("Failed to decode speeding event: %v", err)
+	}
+	if event.Type != EventSpeeding || event.DriverID != "driver-1" {
+		t.Errorf("Unexpected speeding event %+v", event)
+	}
+
+	// A new route starts without the previous violation
+	detector.Reset(key)
+	if event, _ := detector.Check(context.Background(), key, types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Timestamp: 1640995300000}); event != nil {
+		t.Errorf("Expected no event after a reset, got %+v", event)
+	}
+}
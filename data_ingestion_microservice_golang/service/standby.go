@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"data-ingestion-microservice/metrics"
+)
+
+// standbyReleaseTimeout bounds handing the active lease over on shutdown
+const standbyReleaseTimeout = 5 * time.Second
+
+// runStandby renews the active lease, or waits for it as a standby, every
+// heartbeat interval until ctx is done
+func (s *DataIngestionService) runStandby(ctx context.Context) {
+	defer s.backgroundDone.Done()
+
+	ticker := time.NewTicker(s.config.Standby.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.heartbeatActive(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Error renewing active lease", "instance", s.config.InstanceID, "error", err)
+			}
+		}
+	}
+}
+
+// heartbeatActive takes or renews the active lease. A standby that gets the
+// lease takes over, and an active instance that lost it pauses.
+func (s *DataIngestionService) heartbeatActive(ctx context.Context) error {
+	var acquired bool
+	err := s.redisCall(ctx, "redis.standby", func() (err error) {
+		acquired, err = s.backends.Standby.AcquireActive(ctx, s.config.InstanceID, s.config.Standby.TakeoverAfter)
+		return err
+	})
+	if err != nil {
+		// A standby takes over once the lease expires, so stop processing
+		// before then
+		if s.active.Load() && time.Since(s.activeRenewed) >= s.config.Standby.TakeoverAfter-s.config.Standby.HeartbeatInterval {
+			s.pause(ctx, "active lease could not be renewed")
+		}
+		return err
+	}
+
+	switch active := s.active.Load(); {
+	case acquired && !active:
+		return s.takeOver(ctx)
+	case acquired:
+		s.activeRenewed = time.Now()
+	case active:
+		s.pause(ctx, "another instance holds the active lease")
+	}
+	return nil
+}
+
+// takeOver subscribes to the MQTT topic and resumes the finalizations left
+// by the previous active instance
+func (s *DataIngestionService) takeOver(ctx context.Context) error {
+	if err := s.backends.Broker.SubscribeToTopic(s.config.MQTT.Topic, s.messageHandler); err != nil {
+		// Let another standby try
+		if releaseErr := s.backends.Standby.ReleaseActive(ctx, s.config.InstanceID); releaseErr != nil {
+			slog.Warn("Error releasing active lease", "error", releaseErr)
+		}
+		return fmt.Errorf("failed to subscribe to MQTT topic: %w", err)
+	}
+
+	s.activeRenewed = time.Now()
+	s.active.Store(true)
+	metrics.StandbyActive.Set(1)
+	metrics.StandbyActivations.Add(1)
+	slog.Warn("Taking over as the active instance", "instance", s.config.InstanceID, "mqttTopic", s.config.MQTT.Topic)
+
+	// The previous active instance is gone, so its checkpoints are resumed
+	// right away
+	if s.checkpointing() {
+		s.backgroundDone.Add(1)
+		go s.resumeFinalizations(ctx, true)
+	}
+	return nil
+}
+
+// dropSubscription unsubscribes a standby from the MQTT topic. An instance
+// that restarts as a standby resumes the persistent session it had as the
+// active instance, which still holds the subscription, and the broker would
+// keep delivering messages the standby never acknowledges until its
+// in-flight window and queue fill up.
+func (s *DataIngestionService) dropSubscription() {
+	if err := s.backends.Broker.UnsubscribeFromTopic(s.config.MQTT.Topic); err != nil {
+		slog.Warn("Error unsubscribing standby from MQTT topic", "error", err)
+	}
+}
+
+// pause unsubscribes an active instance that lost its lease. Messages
+// already queued are still processed.
+func (s *DataIngestionService) pause(ctx context.Context, reason string) {
+	s.active.Store(false)
+	metrics.StandbyActive.Set(0)
+	slog.Warn("Pausing as a standby", "instance", s.config.InstanceID, "reason", reason)
+	if err := s.backends.Broker.UnsubscribeFromTopic(s.config.MQTT.Topic); err != nil {
+		slog.Warn("Error unsubscribing from MQTT topic", "error", err)
+	}
+}
+
+// releaseActive hands the active lease to a standby on shutdown
+func (s *DataIngestionService) releaseActive() {
+	if !s.config.Standby.Enabled || !s.active.Load() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), standbyReleaseTimeout)
+	defer cancel()
+	if err := s.backends.Standby.ReleaseActive(ctx, s.config.InstanceID); err != nil {
+		slog.Warn("Error releasing active lease", "error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// memoryStandby is an in-memory StandbyCoordinator shared by the instances
+// of a test
+type memoryStandby struct {
+	mu     sync.Mutex
+	active string
+}
+
+func (c *memoryStandby) AcquireActive(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != "" && c.active != instanceID {
+		return false, nil
+	}
+	c.active = instanceID
+	return true, nil
+}
+
+func (c *memoryStandby) ReleaseActive(ctx context.Context, instanceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == instanceID {
+		c.active = ""
+	}
+	return nil
+}
+
+// newStandbyService creates an instance in standby mode whose heartbeats are
+// left to the test
+func newStandbyService(t *testing.T, instanceID string, backend *memoryBackend, coordinator *memoryStandby) *DataIngestionService {
+	t.Helper()
+	return newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.InstanceID = instanceID
+		cfg.Standby.Enabled = true
+		cfg.Standby.HeartbeatInterval = time.Hour
+		backends.Standby = coordinator
+	})
+}
+
+func TestStandby_TakesOverWhenActiveLeaves(t *testing.T) {
+	coordinator := &memoryStandby{}
+	activeBackend, standbyBackend := newMemoryBackend(), newMemoryBackend()
+	active := newStandbyService(t, "instance_a", activeBackend, coordinator)
+	standby := newStandbyService(t, "instance_b", standbyBackend, coordinator)
+
+	if !active.active.Load() || len(activeBackend.subscribed) != 1 {
+		t.Fatalf("Expected the first instance to subscribe, got %v", activeBackend.subscribed)
+	}
+	if standby.active.Load() || len(standbyBackend.subscribed) != 0 || standby.leads() {
+		t.Fatalf("Expected the second instance to wait unsubscribed, got %v", standbyBackend.subscribed)
+	}
+	// A subscription left in its persistent session is dropped
+	if !slices.Equal(standbyBackend.unsubscribed, []string{standby.config.MQTT.Topic}) || len(activeBackend.unsubscribed) != 0 {
+		t.Errorf("Expected only the standby to unsubscribe, got %v and %v", standbyBackend.unsubscribed, activeBackend.unsubscribed)
+	}
+
+	// The standby keeps waiting while the active instance renews its lease
+	active.heartbeatActive(context.Background())
+	standby.heartbeatActive(context.Background())
+	if standby.active.Load() {
+		t.Fatal("Expected the standby to wait while the lease is held")
+	}
+
+	// Shutting down hands the lease over
+	active.Close()
+	if err := standby.heartbeatActive(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !standby.active.Load() || len(standbyBackend.subscribed) != 1 {
+		t.Errorf("Expected the standby to take over, got %v", standbyBackend.subscribed)
+	}
+}
+
+func TestStandby_PausesWhenLeaseIsLost(t *testing.T) {
+	coordinator := &memoryStandby{}
+	backend := newMemoryBackend()
+	service := newStandbyService(t, "instance_a", backend, coordinator)
+
+	// Another instance took the lease over, e.g. after a network partition
+	coordinator.ReleaseActive(context.Background(), "instance_a")
+	coordinator.AcquireActive(context.Background(), "instance_b", time.Minute)
+	if err := service.heartbeatActive(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if service.active.Load() {
+		t.Error("Expected the instance to pause")
+	}
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.unsubscribed) != 1 {
+		t.Errorf("Expected the instance to unsubscribe, got %v", backend.unsubscribed)
+	}
+}
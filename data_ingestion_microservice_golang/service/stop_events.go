@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Stop event types
+const (
+	EventStopArrival   = "stop_arrival"
+	EventStopDeparture = "stop_departure"
+)
+
+// StopEventDetector follows every route between its live points and
+// publishes an event when a vehicle arrives at or departs from a stop of
+// its planned route
+type StopEventDetector struct {
+	config types.StopEventsConfig
+	routes *plannedRouteCache
+	broker database.MessageBroker
+
+	mu       sync.Mutex
+	trackers map[string]*algorithm.StopVisitTracker
+}
+
+// NewStopEventDetector creates a new stop arrival and departure detector
+func NewStopEventDetector(config types.StopEventsConfig, routes database.PlannedRouteStore, broker database.MessageBroker) *StopEventDetector {
+	return &StopEventDetector{
+		config:   config,
+		routes:   newPlannedRouteCache(routes),
+		broker:   broker,
+		trackers: make(map[string]*algorithm.StopVisitTracker),
+	}
+}
+
+// Check adds a live point to its route and publishes the departure and
+// arrival it completed, if any. The published events are returned.
+func (d *StopEventDetector) Check(ctx context.Context, key string, busMsg types.BusMessage) ([]types.StopEvent, error) {
+	route, err := d.routes.get(ctx, busMsg.CurrentRouteID)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil || len(route.Stops) == 0 {
+		return nil, nil
+	}
+
+	d.mu.Lock()
+	tracker, ok := d.trackers[key]
+	if !ok {
+		tracker = d.tracker(route)
+		d.trackers[key] = tracker
+	}
+	arrived, departed := tracker.Add(types.TrackPoint{Location: busMsg.DriverLocation, Timestamp: busMsg.Timestamp})
+	d.mu.Unlock()
+
+	var events []types.StopEvent
+	if departed != nil {
+		events = append(events, stopEvent(EventStopDeparture, busMsg, *departed))
+	}
+	if arrived != nil {
+		events = append(events, stopEvent(EventStopArrival, busMsg, *arrived))
+	}
+	for i, event := range events {
+		if err := d.publish(event); err != nil {
+			return events[:i], err
+		}
+	}
+	return events, nil
+}
+
+// stopEvent builds the event of a visit reported by a live point
+func stopEvent(eventType string, busMsg types.BusMessage, visit types.StopVisit) types.StopEvent {
+	return types.StopEvent{
+		Type:           eventType,
+		DriverID:       busMsg.DriverID,
+		VehicleID:      busMsg.VehicleID,
+		CurrentRouteID: busMsg.CurrentRouteID,
+		Timestamp:      busMsg.Timestamp,
+		Visit:          visit,
+	}
+}
+
+// publish sends a stop event to the route's stop event topic
+func (d *StopEventDetector) publish(event types.StopEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stop event: %w", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s", d.config.EventTopic, event.CurrentRouteID)
+	if err := d.broker.PublishMessage(topic, payload); err != nil {
+		return fmt.Errorf("failed to publish stop event: %w", err)
+	}
+
+	metrics.StopEvents.Add(event.Type, 1)
+	slog.Debug("Published stop event", "type", event.Type, "stopId", event.Visit.StopID, "routeId", event.CurrentRouteID)
+	return nil
+}
+
+// Visits returns the visits of a trip's raw points to the stops of its
+// planned route, or nil when the route has no stops
+func (d *StopEventDetector) Visits(ctx context.Context, routeID string, points []types.TrackPoint) ([]types.StopVisit, error) {
+	route, err := d.routes.get(ctx, routeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read planned route %s: %w", routeID, err)
+	}
+	if route == nil {
+		return nil, nil
+	}
+	return algorithm.DetectStopVisits(points, route.Stops, d.config.RadiusMeters, d.config.MinDwell.Seconds()), nil
+}
+
+// Reset discards the stop visits of a finished route
+func (d *StopEventDetector) Reset(key string) {
+	d.mu.Lock()
+	delete(d.trackers, key)
+	d.mu.Unlock()
+}
+
+// tracker creates a stop visit tracker for a route
+func (d *StopEventDetector) tracker(route *types.PlannedRoute) *algorithm.StopVisitTracker {
+	return algorithm.NewStopVisitTracker(route.Stops, d.config.RadiusMeters, d.config.MinDwell.Seconds())
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestStopEventDetector_PublishesArrivalsAndDepartures(t *testing.T) {
+	broker := &recordingBroker{}
+	detector := NewStopEventDetector(types.StopEventsConfig{RadiusMeters: 30, MinDwell: 10 * time.Second, EventTopic: "events/stops"},
+		memoryPlannedRoutes{"route-1": etaTestRoute}, broker)
+
+	key := database.RouteKey("driver-1", "route-1")
+	path := []struct {
+		longitude float64
+		seconds   uint64
+	}{
+		{0.009, 0},  // at stop-1
+		{0.009, 15}, // dwelled long enough
+		{0.012, 30}, // left stop-1
+		{0.018, 40}, // at stop-2 but does not stay
+		{0.022, 45},
+	}
+	want := [][]string{nil, {"stop_arrival stop-1"}, {"stop_departure stop-1"}, nil, nil}
+	for i, point := range path {
+		events, err := detector.Check(context.Background(), key, types.BusMessage{
+			DriverID:       "driver-1",
+			VehicleID:      "bus-7",
+			CurrentRouteID: "route-1",
+			DriverLocation: types.Location{Latitude: 0, Longitude: point.longitude},
+			Timestamp:      1640995200000 + point.seconds*1000,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var got []string
+		for _, event := range events {
+			got = append(got, event.Type+" "+event.Visit.StopID)
+		}
+		if !slices.Equal(got, want[i]) {
+			t.Errorf("Point %d: expected events %v, got %v", i, want[i], got)
+		}
+	}
+
+	published := broker.published["events/stops/route-1"]
+	if len(published) != 2 {
+		t.Fatalf("Expected 2 stop events published, got %d", len(published))
+	}
+	var departure types.StopEvent
+	if err := json.Unmarshal(published[1], &departure); err != nil {
+		t.Fatalf("Failed to decode stop event: %v", err)
+	}
+	if departure.VehicleID != "bus-7" || departure.Visit.ArrivalTimestamp != 1640995200000 || departure.Visit.DepartureTimestamp != 1640995215000 || departure.Visit.DwellSeconds != 15 {
+		t.Errorf("Unexpected departure %+v", departure)
+	}
+
+	// Routes without planned stops report nothing
+	events, err := detector.Check(context.Background(), database.RouteKey("driver-2", "route-2"), types.BusMessage{DriverID: "driver-2", CurrentRouteID: "route-2", Timestamp: 1})
+	if err != nil || events != nil {
+		t.Errorf("Expected no events without a planned route, got %v (%v)", events, err)
+	}
+}
+
+func TestHandleFinished_StoresStopVisits(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.StopEvents.Enabled = true
+		backends.PlannedRoutes = memoryPlannedRoutes{"route-1": etaTestRoute}
+	})
+
+	messages := []string{
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":0,"longitude":0.009}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995220000,"driverLocation":{"latitude":0,"longitude":0.009}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":0,"longitude":0.018}}`,
+		`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995290000,"driverLocation":{"latitude":0,"longitude":0.018}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995300000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for _, trip := range backend.trips {
+		visits := trip.StopVisits
+		if len(visits) != 2 || visits[0].StopID != "stop-1" || visits[0].DwellSeconds != 20 || visits[1].StopID != "stop-2" || visits[1].DwellSeconds != 30 {
+			t.Errorf("Expected 20s at stop-1 and 30s at stop-2, got %+v", visits)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// maxGeofenceVertices bounds the cost of evaluating a geofence per location
+const maxGeofenceVertices = 1000
+
+// ErrInvalidGeofence is returned for geofences that are not a usable polygon
+var ErrInvalidGeofence = errors.New("invalid geofence")
+
+// StreamFilter selects what a live stream subscriber receives. Every
+// criterion that is set must match: the route, the driver, and a geofence
+// polygon containing the location.
+type StreamFilter struct {
+	RouteIDs  []string         `json:"routeIds,omitempty"`
+	DriverIDs []string         `json:"driverIds,omitempty"`
+	Geofence  []types.Location `json:"geofence,omitempty"`
+}
+
+// Validate checks that the geofence, if any, is a polygon of valid
+// coordinates
+func (f StreamFilter) Validate() error {
+	if len(f.Geofence) == 0 {
+		return nil
+	}
+	if len(f.Geofence) < 3 || len(f.Geofence) > maxGeofenceVertices {
+		return fmt.Errorf("%w: expected 3 to %d vertices, got %d", ErrInvalidGeofence, maxGeofenceVertices, len(f.Geofence))
+	}
+	for _, vertex := range f.Geofence {
+		if vertex.Latitude < -90 || vertex.Latitude > 90 || vertex.Longitude < -180 || vertex.Longitude > 180 {
+			return fmt.Errorf("%w: vertex %v,%v is out of range", ErrInvalidGeofence, vertex.Latitude, vertex.Longitude)
+		}
+	}
+	return nil
+}
+
+// streamMatcher is the evaluated form of a StreamFilter. It is guarded by
+// the mutex of the stream owning the subscription.
+type streamMatcher struct {
+	routes   map[string]bool
+	drivers  map[string]bool
+	geofence []types.Location
+	bounds   types.BoundingBox
+}
+
+// newStreamMatcher builds a matcher for a validated filter
+func newStreamMatcher(filter StreamFilter) streamMatcher {
+	m := streamMatcher{routes: make(map[string]bool), drivers: make(map[string]bool)}
+	m.add(filter)
+	return m
+}
+
+// add widens the matcher with more routes and drivers, and replaces the
+// geofence when the filter has one
+func (m *streamMatcher) add(filter StreamFilter) {
+	for _, routeID := range filter.RouteIDs {
+		m.routes[routeID] = true
+	}
+	for _, driverID := range filter.DriverIDs {
+		m.drivers[driverID] = true
+	}
+	if len(filter.Geofence) > 0 {
+		m.setGeofence(filter.Geofence)
+	}
+}
+
+// remove drops routes and drivers
+func (m *streamMatcher) remove(filter StreamFilter) {
+	for _, routeID := range filter.RouteIDs {
+		delete(m.routes, routeID)
+	}
+	for _, driverID := range filter.DriverIDs {
+		delete(m.drivers, driverID)
+	}
+}
+
+// setGeofence replaces the geofence, or removes it when polygon is empty
+func (m *streamMatcher) setGeofence(polygon []types.Location) {
+	m.geofence = append([]types.Location(nil), polygon...)
+	if len(polygon) > 0 {
+		m.bounds = algorithm.PolygonBounds(polygon)
+	}
+}
+
+// empty reports whether no criterion is set
+func (m *streamMatcher) empty() bool {
+	return len(m.routes) == 0 && len(m.drivers) == 0 && len(m.geofence) == 0
+}
+
+// matches reports whether a location passes every criterion that is set.
+// The bounding box rejects most locations before the polygon test.
+func (m *streamMatcher) matches(routeID, driverID string, location types.Location) bool {
+	if len(m.routes) > 0 && !m.routes[routeID] {
+		return false
+	}
+	if len(m.drivers) > 0 && !m.drivers[driverID] {
+		return false
+	}
+	if len(m.geofence) > 0 {
+		return algorithm.Contains(m.bounds, location) && algorithm.PointInPolygon(location, m.geofence)
+	}
+	return true
+}
+
+// filter returns the criteria in effect, with routes and drivers in order
+func (m *streamMatcher) filter() StreamFilter {
+	return StreamFilter{
+		RouteIDs:  sortedKeys(m.routes),
+		DriverIDs: sortedKeys(m.drivers),
+		Geofence:  append([]types.Location(nil), m.geofence...),
+	}
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
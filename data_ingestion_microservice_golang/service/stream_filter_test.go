@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestStreamFilter_Validate(t *testing.T) {
+	triangle := []types.Location{{Latitude: 1, Longitude: 1}, {Latitude: 2, Longitude: 1}, {Latitude: 1, Longitude: 2}}
+
+	tests := []struct {
+		name   string
+		filter StreamFilter
+		valid  bool
+	}{
+		{"empty", StreamFilter{}, true},
+		{"routes and drivers", StreamFilter{RouteIDs: []string{"route-1"}, DriverIDs: []string{"driver-1"}}, true},
+		{"triangle", StreamFilter{Geofence: triangle}, true},
+		{"too few vertices", StreamFilter{Geofence: triangle[:2]}, false},
+		{"out of range", StreamFilter{Geofence: append([]types.Location{{Latitude: 91}}, triangle...)}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.filter.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: expected a valid filter, got %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidGeofence) {
+			t.Errorf("%s: expected ErrInvalidGeofence, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPipeline_RecordsSpansInOneTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	backend := newMemoryBackend()
+	service := newTestService(t, backend)
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "mqtt.receive")
+	message := `{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`
+	if err := service.processMessage(ctx, []byte(message), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995230000}
+	if err := service.handleFinished(ctx, database.RouteKey("driver-1", "route-1"), finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	root.End()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("Expected span %s in the message trace", span.Name())
+		}
+		names = append(names, span.Name())
+	}
+
+	want := "decode,redis.live_position,redis.write,redis.read,simplify,export,mongo.insert,redis.clear,finalize,mqtt.receive"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Expected spans %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"data-ingestion-microservice/types"
+)
+
+// tripID derives a deterministic trip identifier from the driver, the route,
+// and the timestamp of the first point, so reprocessing a finished trip
+// resolves to the same document
+func tripID(driverID, routeID string, startTimestamp uint64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", driverID, routeID, startTimestamp)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// routeTripID identifies the trip of a finished route, by the vehicle when
+// the route was buffered per vehicle and by the driver otherwise
+func routeTripID(busMsg types.BusMessage, startTimestamp uint64) string {
+	if busMsg.VehicleID != "" {
+		return tripID("vehicle:"+busMsg.VehicleID, busMsg.CurrentRouteID, startTimestamp)
+	}
+	return tripID(busMsg.DriverID, busMsg.CurrentRouteID, startTimestamp)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestTripID_IsDeterministic(t *testing.T) {
+	id := tripID("driver_001", "route_123", 1640995200000)
+
+	if len(id) != 32 {
+		t.Errorf("Expected a 32 character hex ID, got %q", id)
+	}
+	if again := tripID("driver_001", "route_123", 1640995200000); again != id {
+		t.Errorf("Expected reprocessing to yield %q, got %q", id, again)
+	}
+}
+
+func TestTripID_DistinguishesTrips(t *testing.T) {
+	id := tripID("driver_001", "route_123", 1640995200000)
+
+	for _, other := range []string{
+		tripID("driver_002", "route_123", 1640995200000),
+		tripID("driver_001", "route_456", 1640995200000),
+		tripID("driver_001", "route_123", 1640995201000),
+		// The separator keeps shifted boundaries apart
+		tripID("driver_001|route", "_123", 1640995200000),
+	} {
+		if other == id {
+			t.Errorf("Expected a different trip to get a different ID than %q", id)
+		}
+	}
+}
+
+func TestRouteTripID_PrefersVehicle(t *testing.T) {
+	busMsg := types.BusMessage{DriverID: "driver_001", CurrentRouteID: "route_123"}
+	if id := routeTripID(busMsg, 1640995200000); id != tripID("driver_001", "route_123", 1640995200000) {
+		t.Errorf("Expected a driver trip ID, got %q", id)
+	}
+
+	busMsg.VehicleID = "bus-7"
+	if id := routeTripID(busMsg, 1640995200000); id != tripID("vehicle:bus-7", "route_123", 1640995200000) {
+		t.Errorf("Expected a vehicle trip ID, got %q", id)
+	}
+}
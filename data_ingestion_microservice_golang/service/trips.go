@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// ErrTripQueriesUnsupported is returned when the configured storage cannot
+// query stored trips
+var ErrTripQueriesUnsupported = errors.New("trip queries are not supported by the configured storage")
+
+// ErrTripNotFound is returned for trips that do not exist
+var ErrTripNotFound = errors.New("trip not found")
+
+// ErrRawPointsUnavailable is returned for trips stored without raw points
+var ErrRawPointsUnavailable = errors.New("trip has no stored raw points")
+
+// FindTrips returns one page of stored trips matching the query
+func (s *DataIngestionService) FindTrips(ctx context.Context, query types.TripQuery) (types.TripPage, error) {
+	if s.backends.TripQueries == nil {
+		return types.TripPage{}, ErrTripQueriesUnsupported
+	}
+	return s.backends.TripQueries.FindTrips(ctx, query)
+}
+
+// TripWithStops returns a stored trip along with the stops detected in its
+// raw points. Trips stored without raw points have no stops.
+func (s *DataIngestionService) TripWithStops(ctx context.Context, id string) (*types.StoredTrip, []types.Stop, error) {
+	if s.backends.TripQueries == nil {
+		return nil, nil, ErrTripQueriesUnsupported
+	}
+
+	trip, err := s.backends.TripQueries.FindTrip(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trip == nil {
+		return nil, nil, ErrTripNotFound
+	}
+
+	raw, err := s.backends.TripQueries.RawPoints(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return trip, algorithm.DetectStops(raw, s.config.TripStats), nil
+}
+
+// TripPoints returns a stored trip along with its raw points in recording
+// order
+func (s *DataIngestionService) TripPoints(ctx context.Context, id string) (*types.StoredTrip, []types.TrackPoint, error) {
+	if s.backends.TripQueries == nil {
+		return nil, nil, ErrTripQueriesUnsupported
+	}
+
+	trip, err := s.backends.TripQueries.FindTrip(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trip == nil {
+		return nil, nil, ErrTripNotFound
+	}
+
+	raw, err := s.backends.TripQueries.RawPoints(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(raw) == 0 {
+		return nil, nil, ErrRawPointsUnavailable
+	}
+
+	return trip, raw, nil
+}
+
+// SearchTrips returns stored trips whose route intersects the query area
+func (s *DataIngestionService) SearchTrips(ctx context.Context, query types.TripGeoQuery) ([]types.StoredTrip, error) {
+	if s.backends.TripQueries == nil {
+		return nil, ErrTripQueriesUnsupported
+	}
+	return s.backends.TripQueries.SearchTrips(ctx, query)
+}
+
+// TripStats aggregates stored trips per day, driver, or route
+func (s *DataIngestionService) TripStats(ctx context.Context, query types.TripStatsQuery) (types.TripStatsReport, error) {
+	if s.backends.TripQueries == nil {
+		return types.TripStatsReport{}, ErrTripQueriesUnsupported
+	}
+	return s.backends.TripQueries.AggregateTrips(ctx, query)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+// ErrAssignmentsUnsupported is returned for assignment reads when the
+// configured storage keeps no vehicle assignments
+var ErrAssignmentsUnsupported = errors.New("vehicle assignments are not supported by the configured storage")
+
+// routeKey returns the buffer key of a message's route. Messages naming a
+// vehicle are buffered per vehicle, so a driver switching vehicles starts a
+// new trip and a vehicle changing drivers keeps its trip.
+func routeKey(busMsg types.BusMessage) string {
+	if busMsg.VehicleID != "" {
+		return database.VehicleRouteKey(busMsg.VehicleID, busMsg.CurrentRouteID)
+	}
+	return database.RouteKey(busMsg.DriverID, busMsg.CurrentRouteID)
+}
+
+// AssignmentTracker records in the assignment log when a driver starts
+// sending messages from another vehicle. The vehicle last recorded for each
+// driver is remembered, so the log is only written on changes.
+type AssignmentTracker struct {
+	store database.AssignmentStore
+
+	mu       sync.Mutex
+	vehicles map[string]string
+}
+
+// NewAssignmentTracker creates an assignment tracker writing to store
+func NewAssignmentTracker(store database.AssignmentStore) *AssignmentTracker {
+	return &AssignmentTracker{store: store, vehicles: make(map[string]string)}
+}
+
+// changed reports whether a driver's vehicle differs from the one recorded
+func (a *AssignmentTracker) changed(driverID, vehicleID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.vehicles[driverID] != vehicleID
+}
+
+// recorded remembers the vehicle recorded for a driver
+func (a *AssignmentTracker) recorded(driverID, vehicleID string) {
+	a.mu.Lock()
+	a.vehicles[driverID] = vehicleID
+	a.mu.Unlock()
+}
+
+// Forget drops what is remembered about a driver
+func (a *AssignmentTracker) Forget(driverID string) {
+	a.mu.Lock()
+	delete(a.vehicles, driverID)
+	a.mu.Unlock()
+}
+
+// trackAssignment logs the vehicle of a message's driver when it changed,
+// from the message timestamp or when it was received if it has none
+func (s *DataIngestionService) trackAssignment(ctx context.Context, busMsg types.BusMessage) error {
+	if !s.assignments.changed(busMsg.DriverID, busMsg.VehicleID) {
+		return nil
+	}
+
+	at := time.Now().UTC()
+	if busMsg.Timestamp > 0 {
+		at = time.UnixMilli(int64(busMsg.Timestamp)).UTC()
+	}
+	err := s.mongoCall(ctx, "mongo.assignment", func() error {
+		return s.assignments.store.StartAssignment(ctx, busMsg.DriverID, busMsg.VehicleID, at)
+	})
+	if err != nil {
+		return classify(FailureMongo, err)
+	}
+	s.assignments.recorded(busMsg.DriverID, busMsg.VehicleID)
+	slog.InfoContext(ctx, "Driver assigned to vehicle", "vehicleId", busMsg.VehicleID, "startedAt", at)
+	return nil
+}
+
+// Assignments returns the vehicle assignments of a driver or vehicle, newest
+// first
+func (s *DataIngestionService) Assignments(ctx context.Context, query types.AssignmentQuery) (types.AssignmentPage, error) {
+	if s.assignments == nil {
+		return types.AssignmentPage{}, ErrAssignmentsUnsupported
+	}
+	assignments, err := s.assignments.store.FindAssignments(ctx, query)
+	if err != nil {
+		return types.AssignmentPage{}, err
+	}
+	return types.AssignmentPage{Assignments: assignments}, nil
+}
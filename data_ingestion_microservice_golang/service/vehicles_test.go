@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestProcessMessage_KeysVehicleRoutesAndLogsAssignments(t *testing.T) {
+	store, err := database.NewBoltTripStore(filepath.Join(t.TempDir(), "trips.db"), database.CurrentTripSchemaVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(_ *types.Config, backends *database.Backends) {
+		backends.Assignments = store
+	})
+
+	// The vehicle changes drivers mid-route and keeps its trip
+	messages := []string{
+		`{"driverId":"driver-1","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995200000,"driverLocation":{"latitude":6.2442,"longitude":-75.5812}}`,
+		`{"driverId":"driver-1","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995260000,"driverLocation":{"latitude":6.2450,"longitude":-75.5820}}`,
+		`{"driverId":"driver-2","vehicleId":"bus-7","currentRouteId":"route-1","status":"in_route","timestamp":1640995320000,"driverLocation":{"latitude":6.2460,"longitude":-75.5830}}`,
+	}
+	for _, message := range messages {
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.VehicleRouteKey("bus-7", "route-1")
+	if points := backend.routes[key]; len(points) != 3 || points[2].Point.DriverID != "driver-2" {
+		t.Fatalf("Expected 3 points buffered for the vehicle, got %+v", points)
+	}
+
+	page, err := service.Assignments(context.Background(), types.AssignmentQuery{VehicleID: "bus-7"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Assignments) != 2 || page.Assignments[0].DriverID != "driver-2" || page.Assignments[1].EndedAt == nil {
+		t.Errorf("Expected driver-2 to have taken over bus-7, got %+v", page.Assignments)
+	}
+
+	finished := types.BusMessage{DriverID: "driver-2", VehicleID: "bus-7", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995380000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	for id, trip := range backend.trips {
+		if trip.VehicleID != "bus-7" || trip.DriverID != "driver-2" {
+			t.Errorf("Expected the trip of bus-7 driven by driver-2, got %+v", trip)
+		}
+		if id != tripID("vehicle:bus-7", "route-1", 1640995200000) {
+			t.Errorf("Expected the trip to be identified by its vehicle, got %s", id)
+		}
+	}
+}
+
+func TestAssignments_Unsupported(t *testing.T) {
+	service := newTestService(t, newMemoryBackend())
+	if _, err := service.Assignments(context.Background(), types.AssignmentQuery{DriverID: "driver-1"}); err != ErrAssignmentsUnsupported {
+		t.Errorf("Expected ErrAssignmentsUnsupported, got %v", err)
+	}
+}
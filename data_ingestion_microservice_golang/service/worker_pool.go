@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// finalizationJob is a finished trip waiting to be finalized. Its context
+// carries the trace of the message that finished the trip.
+type finalizationJob struct {
+	ctx    context.Context
+	key    string
+	busMsg types.BusMessage
+	done   func(err error)
+}
+
+// errPoolStopped is passed to the done callback of trips submitted after the
+// pool was stopped
+var errPoolStopped = errors.New("finalization pool is stopped")
+
+// FinalizationPool runs trip finalization on a bounded set of workers so that
+// bursts of finished trips cannot spike CPU or memory usage
+type FinalizationPool struct {
+	jobs    chan finalizationJob
+	handler func(ctx context.Context, key string, busMsg types.BusMessage) error
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	// mu guards closed; submissions hold it shared so the queue cannot be
+	// closed under them
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewFinalizationPool creates a worker pool and starts its workers
+func NewFinalizationPool(config types.FinalizationConfig, handler func(ctx context.Context, key string, busMsg types.BusMessage) error) *FinalizationPool {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	queueSize := config.QueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	pool := &FinalizationPool{
+		jobs:    make(chan finalizationJob, queueSize),
+		handler: handler,
+	}
+	metrics.FinalizationQueueSize.Set(int64(queueSize))
+
+	for i := 0; i < workers; i++ {
+		pool.wg.Add(1)
+		go pool.worker()
+	}
+
+	return pool
+}
+
+// Submit queues a finished trip, blocking while the queue is full.
+// The optional done callback receives the finalization result. Trips
+// submitted after Stop are not finalized.
+func (p *FinalizationPool) Submit(ctx context.Context, key string, busMsg types.BusMessage, done func(err error)) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		slog.WarnContext(ctx, "Not finalizing route finished during shutdown", "key", key)
+		if done != nil {
+			done(errPoolStopped)
+		}
+		return
+	}
+	metrics.FinalizationQueueDepth.Add(1)
+	p.jobs <- finalizationJob{ctx: ctx, key: key, busMsg: busMsg, done: done}
+}
+
+// QueueDepth returns the number of trips waiting for a worker
+func (p *FinalizationPool) QueueDepth() int {
+	return len(p.jobs)
+}
+
+// Stop stops accepting jobs and waits for queued jobs to finish
+func (p *FinalizationPool) Stop() {
+	p.Drain(context.Background())
+}
+
+// Drain stops accepting jobs and waits for queued jobs to finish or for ctx
+// to be done, whichever comes first
+func (p *FinalizationPool) Drain(ctx context.Context) error {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		close(p.jobs)
+		p.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// worker processes queued finalization jobs until the pool is stopped
+func (p *FinalizationPool) worker() {
+	defer p.wg.Done()
+
+	for job := range p.jobs {
+		metrics.FinalizationQueueDepth.Add(-1)
+		metrics.FinalizationBusy.Add(1)
+
+		err := p.handler(job.ctx, job.key, job.busMsg)
+		if err != nil {
+			metrics.FinalizationFailed.Add(1)
+			slog.ErrorContext(job.ctx, "Error finalizing route", "key", job.key, "class", failureClass(err), "error", err)
+		} else {
+			metrics.FinalizationProcessed.Add(1)
+		}
+
+		if job.done != nil {
+			job.done(err)
+		}
+
+		metrics.FinalizationBusy.Add(-1)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+func TestFinalizationPool_ReportsResults(t *testing.T) {
+	failure := errors.New("mongo unavailable")
+	pool := NewFinalizationPool(types.FinalizationConfig{Workers: 2, QueueSize: 4}, func(ctx context.Context, key string, busMsg types.BusMessage) error {
+		if busMsg.DriverID == "driver_002" {
+			return failure
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	results := map[string]error{}
+	var wg sync.WaitGroup
+	for _, driverID := range []string{"driver_001", "driver_002"} {
+		wg.Add(1)
+		pool.Submit(context.Background(), "route:"+driverID, types.BusMessage{DriverID: driverID}, func(err error) {
+			mu.Lock()
+			results[driverID] = err
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	pool.Stop()
+
+	if results["driver_001"] != nil || !errors.Is(results["driver_002"], failure) {
+		t.Errorf("Expected driver_001 to succeed and driver_002 to fail, got %v", results)
+	}
+}
+
+func TestFinalizationPool_BoundsConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	release := make(chan struct{})
+	pool := NewFinalizationPool(types.FinalizationConfig{Workers: 2, QueueSize: 8}, func(ctx context.Context, key string, busMsg types.BusMessage) error {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		active.Add(-1)
+		return nil
+	})
+
+	for i := 0; i < 6; i++ {
+		pool.Submit(context.Background(), "route", types.BusMessage{}, nil)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for active.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if depth := pool.QueueDepth(); depth != 4 {
+		t.Errorf("Expected 4 queued trips behind the 2 workers, got %d", depth)
+	}
+
+	close(release)
+	pool.Stop()
+	if p := peak.Load(); p != 2 {
+		t.Errorf("Expected at most 2 concurrent finalizations, got %d", p)
+	}
+}
+
+func TestFinalizationPool_DrainFinishesQueuedTrips(t *testing.T) {
+	var finalized atomic.Int32
+	pool := NewFinalizationPool(types.FinalizationConfig{Workers: 1, QueueSize: 8}, func(ctx context.Context, key string, busMsg types.BusMessage) error {
+		time.Sleep(time.Millisecond)
+		finalized.Add(1)
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		pool.Submit(context.Background(), "route", types.BusMessage{}, nil)
+	}
+
+	if err := pool.Drain(context.Background()); err != nil {
+		t.Fatalf("Expected the drain to complete, got %v", err)
+	}
+	if n := finalized.Load(); n != 5 {
+		t.Errorf("Expected every queued trip to be finalized, got %d", n)
+	}
+}
+
+func TestFinalizationPool_DrainTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	pool := NewFinalizationPool(types.FinalizationConfig{Workers: 1}, func(ctx context.Context, key string, busMsg types.BusMessage) error {
+		close(started)
+		<-release
+		return nil
+	})
+	pool.Submit(context.Background(), "route", types.BusMessage{}, nil)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out, got %v", err)
+	}
+}
+
+func TestFinalizationPool_RejectsAfterStop(t *testing.T) {
+	var calls atomic.Int32
+	pool := NewFinalizationPool(types.FinalizationConfig{Workers: 1}, func(ctx context.Context, key string, busMsg types.BusMessage) error {
+		calls.Add(1)
+		return nil
+	})
+	pool.Stop()
+
+	var result error
+	pool.Submit(context.Background(), "route", types.BusMessage{}, func(err error) { result = err })
+	if !errors.Is(result, errPoolStopped) || calls.Load() != 0 {
+		t.Errorf("Expected the trip to be rejected without finalizing, got %v after %d calls", result, calls.Load())
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"data-ingestion-microservice/algorithm"
+	"data-ingestion-microservice/types"
+)
+
+// ZoneTime returns the time the points spent in the geofences accounted
+// for: those with the given IDs, or every geofence when there are none
+func (g *GeofenceEngine) ZoneTime(points []types.TrackPoint, config types.ZoneTimeConfig) []types.ZoneTime {
+	g.mu.Lock()
+	index := g.index
+	g.mu.Unlock()
+
+	zonesOf := func(location types.Location) []string {
+		zones := index.containing(location)
+		if len(config.Zones) == 0 {
+			return zones
+		}
+		return slices.DeleteFunc(zones, func(id string) bool {
+			return !slices.Contains(config.Zones, id)
+		})
+	}
+	return algorithm.AccountZoneTime(points, config.MaxGap.Seconds(), zonesOf)
+}
+
+// ZoneTimeReport sums the time stored trips spent in each zone per day,
+// driver, or route
+func (s *DataIngestionService) ZoneTimeReport(ctx context.Context, query types.TripStatsQuery) (types.ZoneTimeReport, error) {
+	if s.backends.TripQueries == nil {
+		return types.ZoneTimeReport{}, ErrTripQueriesUnsupported
+	}
+	return s.backends.TripQueries.AggregateZoneTime(ctx, query)
+}
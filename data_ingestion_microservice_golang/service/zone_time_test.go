@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/types"
+)
+
+func TestHandleFinished_StoresZoneTime(t *testing.T) {
+	backend := newMemoryBackend()
+	service := newTestService(t, backend, func(cfg *types.Config, backends *database.Backends) {
+		cfg.ZoneTime.Enabled = true
+		cfg.ZoneTime.Zones = []string{"depot", "downtown"}
+		cfg.Geofence.Enabled = true
+		backends.Geofences = memoryGeofences{
+			{ID: "depot", Center: &types.Location{Latitude: 0, Longitude: 0}, RadiusMeters: 200},
+			{ID: "downtown", Center: &types.Location{Latitude: 0, Longitude: 0.02}, RadiusMeters: 500},
+			{ID: "school", Center: &types.Location{Latitude: 0, Longitude: 0.02}, RadiusMeters: 100},
+		}
+	})
+
+	// 5 minutes in the depot, then 3 minutes downtown
+	positions := []struct {
+		seconds   uint64
+		longitude float64
+	}{
+		{0, 0}, {300, 0},
+		{360, 0.01},
+		{420, 0.02}, {600, 0.02},
+	}
+	for _, position := range positions {
+		message := fmt.Sprintf(`{"driverId":"driver-1","currentRouteId":"route-1","status":"in_route","timestamp":%d,"driverLocation":{"latitude":0,"longitude":%v}}`,
+			1640995200000+position.seconds*1000, position.longitude)
+		if err := service.processMessage(context.Background(), []byte(message), nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	key := database.RouteKey("driver-1", "route-1")
+	finished := types.BusMessage{DriverID: "driver-1", CurrentRouteID: "route-1", Status: "finished", Timestamp: 1640995800000}
+	if err := service.handleFinished(context.Background(), key, finished); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(backend.trips) != 1 {
+		t.Fatalf("Expected 1 stored trip, got %d", len(backend.trips))
+	}
+	want := []types.ZoneTime{
+		{ZoneID: "depot", Seconds: 330, Visits: 1},
+		{ZoneID: "downtown", Seconds: 210, Visits: 1},
+	}
+	for _, trip := range backend.trips {
+		if !reflect.DeepEqual(trip.ZoneTime, want) {
+			t.Errorf("Expected %+v without the school, got %+v", want, trip.ZoneTime)
+		}
+	}
+}
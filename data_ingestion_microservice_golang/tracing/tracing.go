@@ -0,0 +1,112 @@
+// Package tracing sets up OpenTelemetry tracing for the ingestion pipeline
+// and carries trace context across MQTT payloads and finalization queues.
+// Spans are exported over OTLP/gRPC; while tracing is disabled the global
+// no-op tracer makes every span free.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"data-ingestion-microservice/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// propagator encodes trace context as W3C traceparent and tracestate
+var propagator = propagation.TraceContext{}
+
+// Setup installs the global tracer provider exporting to the configured
+// OTLP endpoint. The returned function flushes pending spans and must be
+// called on shutdown.
+func Setup(ctx context.Context, config types.TracingConfig) (func(context.Context) error, error) {
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Accept both host:port and the URL form of OTEL_EXPORTER_OTLP_ENDPOINT
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if strings.Contains(config.Endpoint, "://") {
+		options = []otlptracegrpc.Option{otlptracegrpc.WithEndpointURL(config.Endpoint)}
+	}
+	if config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithAttributes(attribute.String("service.name", config.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	return provider.Shutdown, nil
+}
+
+// payloadCarrier holds the optional trace context fields of an MQTT payload
+type payloadCarrier struct {
+	TraceParent string `json:"traceparent"`
+	TraceState  string `json:"tracestate"`
+}
+
+// ExtractPayload continues the trace of a publisher that put traceparent
+// and tracestate fields in the JSON payload, since MQTT 3.1.1 messages have
+// no headers. Payloads without them start a new trace.
+func ExtractPayload(ctx context.Context, payload []byte) context.Context {
+	var carrier payloadCarrier
+	if json.Unmarshal(payload, &carrier) != nil || carrier.TraceParent == "" {
+		return ctx
+	}
+	return Extract(ctx, map[string]string{"traceparent": carrier.TraceParent, "tracestate": carrier.TraceState})
+}
+
+// Inject returns the trace context of ctx as a map to store alongside
+// queued work, or nil when ctx carries no sampled span
+func Inject(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsSampled() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract returns ctx continuing the trace stored by Inject
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
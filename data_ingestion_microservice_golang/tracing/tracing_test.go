@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestExtractPayload(t *testing.T) {
+	payload := []byte(`{"driverId":"driver-1","status":"in_route","traceparent":"` + traceParent + `"}`)
+	spanContext := trace.SpanContextFromContext(ExtractPayload(context.Background(), payload))
+	if spanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !spanContext.IsRemote() {
+		t.Errorf("Expected the publisher's trace, got %+v", spanContext)
+	}
+
+	for _, payload := range []string{`{"driverId":"driver-1"}`, `{"traceparent":"garbage"}`, `not json`} {
+		if spanContext := trace.SpanContextFromContext(ExtractPayload(context.Background(), []byte(payload))); spanContext.IsValid() {
+			t.Errorf("Expected no trace for %s, got %+v", payload, spanContext)
+		}
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	ctx := Extract(context.Background(), map[string]string{"traceparent": traceParent})
+
+	carrier := Inject(ctx)
+	if carrier["traceparent"] != traceParent {
+		t.Fatalf("Expected the trace context to be injected, got %v", carrier)
+	}
+	if got := trace.SpanContextFromContext(Extract(context.Background(), carrier)); !got.Equal(trace.SpanContextFromContext(ctx)) {
+		t.Errorf("Expected the trace context to round trip, got %+v", got)
+	}
+
+	if carrier := Inject(context.Background()); carrier != nil {
+		t.Errorf("Expected nothing to inject without a span, got %v", carrier)
+	}
+}
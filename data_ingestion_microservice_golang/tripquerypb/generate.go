@@ -0,0 +1,4 @@
+// Package tripquerypb holds the gRPC contract of the trip query API
+package tripquerypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative trip_query.proto
@@ -0,0 +1,854 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: trip_query.proto
+
+package tripquerypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Location struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Latitude      float64                `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude     float64                `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_trip_query_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Location) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Location) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+type TripStats struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	DurationSeconds float64                `protobuf:"fixed64,1,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	MovingSeconds   float64                `protobuf:"fixed64,2,opt,name=moving_seconds,json=movingSeconds,proto3" json:"moving_seconds,omitempty"`
+	IdleSeconds     float64                `protobuf:"fixed64,3,opt,name=idle_seconds,json=idleSeconds,proto3" json:"idle_seconds,omitempty"`
+	DistanceMeters  float64                `protobuf:"fixed64,4,opt,name=distance_meters,json=distanceMeters,proto3" json:"distance_meters,omitempty"`
+	AverageSpeedKmh float64                `protobuf:"fixed64,5,opt,name=average_speed_kmh,json=averageSpeedKmh,proto3" json:"average_speed_kmh,omitempty"`
+	MaxSpeedKmh     float64                `protobuf:"fixed64,6,opt,name=max_speed_kmh,json=maxSpeedKmh,proto3" json:"max_speed_kmh,omitempty"`
+	Stops           int32                  `protobuf:"varint,7,opt,name=stops,proto3" json:"stops,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *TripStats) Reset() {
+	*x = TripStats{}
+	mi := &file_trip_query_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TripStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TripStats) ProtoMessage() {}
+
+func (x *TripStats) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TripStats.ProtoReflect.Descriptor instead.
+func (*TripStats) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TripStats) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *TripStats) GetMovingSeconds() float64 {
+	if x != nil {
+		return x.MovingSeconds
+	}
+	return 0
+}
+
+func (x *TripStats) GetIdleSeconds() float64 {
+	if x != nil {
+		return x.IdleSeconds
+	}
+	return 0
+}
+
+func (x *TripStats) GetDistanceMeters() float64 {
+	if x != nil {
+		return x.DistanceMeters
+	}
+	return 0
+}
+
+func (x *TripStats) GetAverageSpeedKmh() float64 {
+	if x != nil {
+		return x.AverageSpeedKmh
+	}
+	return 0
+}
+
+func (x *TripStats) GetMaxSpeedKmh() float64 {
+	if x != nil {
+		return x.MaxSpeedKmh
+	}
+	return 0
+}
+
+func (x *TripStats) GetStops() int32 {
+	if x != nil {
+		return x.Stops
+	}
+	return 0
+}
+
+type Stop struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Location        *Location              `protobuf:"bytes,1,opt,name=location,proto3" json:"location,omitempty"`
+	StartTimestamp  uint64                 `protobuf:"varint,2,opt,name=start_timestamp,json=startTimestamp,proto3" json:"start_timestamp,omitempty"`
+	EndTimestamp    uint64                 `protobuf:"varint,3,opt,name=end_timestamp,json=endTimestamp,proto3" json:"end_timestamp,omitempty"`
+	DurationSeconds float64                `protobuf:"fixed64,4,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Stop) Reset() {
+	*x = Stop{}
+	mi := &file_trip_query_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stop) ProtoMessage() {}
+
+func (x *Stop) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stop.ProtoReflect.Descriptor instead.
+func (*Stop) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Stop) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *Stop) GetStartTimestamp() uint64 {
+	if x != nil {
+		return x.StartTimestamp
+	}
+	return 0
+}
+
+func (x *Stop) GetEndTimestamp() uint64 {
+	if x != nil {
+		return x.EndTimestamp
+	}
+	return 0
+}
+
+func (x *Stop) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+type Trip struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SchemaVersion   int32                  `protobuf:"varint,2,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	DriverId        string                 `protobuf:"bytes,3,opt,name=driver_id,json=driverId,proto3" json:"driver_id,omitempty"`
+	RouteId         string                 `protobuf:"bytes,4,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	SimplifiedRoute []*Location            `protobuf:"bytes,5,rep,name=simplified_route,json=simplifiedRoute,proto3" json:"simplified_route,omitempty"`
+	// Timestamp of the finished message in milliseconds.
+	Timestamp             int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	OriginalPointsCount   int32                  `protobuf:"varint,7,opt,name=original_points_count,json=originalPointsCount,proto3" json:"original_points_count,omitempty"`
+	SimplifiedPointsCount int32                  `protobuf:"varint,8,opt,name=simplified_points_count,json=simplifiedPointsCount,proto3" json:"simplified_points_count,omitempty"`
+	CompressionRatio      float64                `protobuf:"fixed64,9,opt,name=compression_ratio,json=compressionRatio,proto3" json:"compression_ratio,omitempty"`
+	ReductionPercent      float64                `protobuf:"fixed64,10,opt,name=reduction_percent,json=reductionPercent,proto3" json:"reduction_percent,omitempty"`
+	Stats                 *TripStats             `protobuf:"bytes,11,opt,name=stats,proto3" json:"stats,omitempty"`
+	RawArchiveUrl         string                 `protobuf:"bytes,12,opt,name=raw_archive_url,json=rawArchiveUrl,proto3" json:"raw_archive_url,omitempty"`
+	CreatedAt             *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Stops detected from the raw points, only set by GetTrip when raw routes
+	// are stored.
+	Stops         []*Stop `protobuf:"bytes,14,rep,name=stops,proto3" json:"stops,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trip) Reset() {
+	*x = Trip{}
+	mi := &file_trip_query_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trip) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trip) ProtoMessage() {}
+
+func (x *Trip) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trip.ProtoReflect.Descriptor instead.
+func (*Trip) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Trip) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Trip) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *Trip) GetDriverId() string {
+	if x != nil {
+		return x.DriverId
+	}
+	return ""
+}
+
+func (x *Trip) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *Trip) GetSimplifiedRoute() []*Location {
+	if x != nil {
+		return x.SimplifiedRoute
+	}
+	return nil
+}
+
+func (x *Trip) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Trip) GetOriginalPointsCount() int32 {
+	if x != nil {
+		return x.OriginalPointsCount
+	}
+	return 0
+}
+
+func (x *Trip) GetSimplifiedPointsCount() int32 {
+	if x != nil {
+		return x.SimplifiedPointsCount
+	}
+	return 0
+}
+
+func (x *Trip) GetCompressionRatio() float64 {
+	if x != nil {
+		return x.CompressionRatio
+	}
+	return 0
+}
+
+func (x *Trip) GetReductionPercent() float64 {
+	if x != nil {
+		return x.ReductionPercent
+	}
+	return 0
+}
+
+func (x *Trip) GetStats() *TripStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *Trip) GetRawArchiveUrl() string {
+	if x != nil {
+		return x.RawArchiveUrl
+	}
+	return ""
+}
+
+func (x *Trip) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Trip) GetStops() []*Stop {
+	if x != nil {
+		return x.Stops
+	}
+	return nil
+}
+
+type GetTripRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTripRequest) Reset() {
+	*x = GetTripRequest{}
+	mi := &file_trip_query_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTripRequest) ProtoMessage() {}
+
+func (x *GetTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTripRequest.ProtoReflect.Descriptor instead.
+func (*GetTripRequest) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetTripRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListTripsRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	DriverId string                 `protobuf:"bytes,1,opt,name=driver_id,json=driverId,proto3" json:"driver_id,omitempty"`
+	RouteId  string                 `protobuf:"bytes,2,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	// Trip timestamp bounds in milliseconds, [from, to). Zero is unbounded.
+	From int64 `protobuf:"varint,3,opt,name=from,proto3" json:"from,omitempty"`
+	To   int64 `protobuf:"varint,4,opt,name=to,proto3" json:"to,omitempty"`
+	// Defaults to GRPC_DEFAULT_PAGE_SIZE and is capped at GRPC_MAX_PAGE_SIZE.
+	PageSize  int32 `protobuf:"varint,5,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	Ascending bool  `protobuf:"varint,6,opt,name=ascending,proto3" json:"ascending,omitempty"`
+	// next_page_token of the previous page.
+	PageToken     string `protobuf:"bytes,7,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTripsRequest) Reset() {
+	*x = ListTripsRequest{}
+	mi := &file_trip_query_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTripsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTripsRequest) ProtoMessage() {}
+
+func (x *ListTripsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTripsRequest.ProtoReflect.Descriptor instead.
+func (*ListTripsRequest) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListTripsRequest) GetDriverId() string {
+	if x != nil {
+		return x.DriverId
+	}
+	return ""
+}
+
+func (x *ListTripsRequest) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *ListTripsRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *ListTripsRequest) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *ListTripsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListTripsRequest) GetAscending() bool {
+	if x != nil {
+		return x.Ascending
+	}
+	return false
+}
+
+func (x *ListTripsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListTripsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trips         []*Trip                `protobuf:"bytes,1,rep,name=trips,proto3" json:"trips,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTripsResponse) Reset() {
+	*x = ListTripsResponse{}
+	mi := &file_trip_query_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTripsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTripsResponse) ProtoMessage() {}
+
+func (x *ListTripsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTripsResponse.ProtoReflect.Descriptor instead.
+func (*ListTripsResponse) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListTripsResponse) GetTrips() []*Trip {
+	if x != nil {
+		return x.Trips
+	}
+	return nil
+}
+
+func (x *ListTripsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+// Every filter that is set must match; at least one is required.
+type StreamLivePositionsRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	RouteIds  []string               `protobuf:"bytes,1,rep,name=route_ids,json=routeIds,proto3" json:"route_ids,omitempty"`
+	DriverIds []string               `protobuf:"bytes,2,rep,name=driver_ids,json=driverIds,proto3" json:"driver_ids,omitempty"`
+	// Polygon of at least 3 vertices; only locations inside it are streamed.
+	Geofence      []*Location `protobuf:"bytes,3,rep,name=geofence,proto3" json:"geofence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamLivePositionsRequest) Reset() {
+	*x = StreamLivePositionsRequest{}
+	mi := &file_trip_query_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamLivePositionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamLivePositionsRequest) ProtoMessage() {}
+
+func (x *StreamLivePositionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamLivePositionsRequest.ProtoReflect.Descriptor instead.
+func (*StreamLivePositionsRequest) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StreamLivePositionsRequest) GetRouteIds() []string {
+	if x != nil {
+		return x.RouteIds
+	}
+	return nil
+}
+
+func (x *StreamLivePositionsRequest) GetDriverIds() []string {
+	if x != nil {
+		return x.DriverIds
+	}
+	return nil
+}
+
+func (x *StreamLivePositionsRequest) GetGeofence() []*Location {
+	if x != nil {
+		return x.Geofence
+	}
+	return nil
+}
+
+type LivePosition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DriverId      string                 `protobuf:"bytes,1,opt,name=driver_id,json=driverId,proto3" json:"driver_id,omitempty"`
+	RouteId       string                 `protobuf:"bytes,2,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Location      *Location              `protobuf:"bytes,4,opt,name=location,proto3" json:"location,omitempty"`
+	Timestamp     uint64                 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LivePosition) Reset() {
+	*x = LivePosition{}
+	mi := &file_trip_query_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LivePosition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LivePosition) ProtoMessage() {}
+
+func (x *LivePosition) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_query_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LivePosition.ProtoReflect.Descriptor instead.
+func (*LivePosition) Descriptor() ([]byte, []int) {
+	return file_trip_query_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *LivePosition) GetDriverId() string {
+	if x != nil {
+		return x.DriverId
+	}
+	return ""
+}
+
+func (x *LivePosition) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *LivePosition) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *LivePosition) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *LivePosition) GetTimestamp() uint64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+var File_trip_query_proto protoreflect.FileDescriptor
+
+const file_trip_query_proto_rawDesc = "" +
+	"\n" +
+	"\x10trip_query.proto\x12\vtracking.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"D\n" +
+	"\bLocation\x12\x1a\n" +
+	"\blatitude\x18\x01 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x02 \x01(\x01R\tlongitude\"\x8f\x02\n" +
+	"\tTripStats\x12)\n" +
+	"\x10duration_seconds\x18\x01 \x01(\x01R\x0fdurationSeconds\x12%\n" +
+	"\x0emoving_seconds\x18\x02 \x01(\x01R\rmovingSeconds\x12!\n" +
+	"\fidle_seconds\x18\x03 \x01(\x01R\vidleSeconds\x12'\n" +
+	"\x0fdistance_meters\x18\x04 \x01(\x01R\x0edistanceMeters\x12*\n" +
+	"\x11average_speed_kmh\x18\x05 \x01(\x01R\x0faverageSpeedKmh\x12\"\n" +
+	"\rmax_speed_kmh\x18\x06 \x01(\x01R\vmaxSpeedKmh\x12\x14\n" +
+	"\x05stops\x18\a \x01(\x05R\x05stops\"\xb2\x01\n" +
+	"\x04Stop\x121\n" +
+	"\blocation\x18\x01 \x01(\v2\x15.tracking.v1.LocationR\blocation\x12'\n" +
+	"\x0fstart_timestamp\x18\x02 \x01(\x04R\x0estartTimestamp\x12#\n" +
+	"\rend_timestamp\x18\x03 \x01(\x04R\fendTimestamp\x12)\n" +
+	"\x10duration_seconds\x18\x04 \x01(\x01R\x0fdurationSeconds\"\xd5\x04\n" +
+	"\x04Trip\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12%\n" +
+	"\x0eschema_version\x18\x02 \x01(\x05R\rschemaVersion\x12\x1b\n" +
+	"\tdriver_id\x18\x03 \x01(\tR\bdriverId\x12\x19\n" +
+	"\broute_id\x18\x04 \x01(\tR\arouteId\x12@\n" +
+	"\x10simplified_route\x18\x05 \x03(\v2\x15.tracking.v1.LocationR\x0fsimplifiedRoute\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\x122\n" +
+	"\x15original_points_count\x18\a \x01(\x05R\x13originalPointsCount\x126\n" +
+	"\x17simplified_points_count\x18\b \x01(\x05R\x15simplifiedPointsCount\x12+\n" +
+	"\x11compression_ratio\x18\t \x01(\x01R\x10compressionRatio\x12+\n" +
+	"\x11reduction_percent\x18\n" +
+	" \x01(\x01R\x10reductionPercent\x12,\n" +
+	"\x05stats\x18\v \x01(\v2\x16.tracking.v1.TripStatsR\x05stats\x12&\n" +
+	"\x0fraw_archive_url\x18\f \x01(\tR\rrawArchiveUrl\x129\n" +
+	"\n" +
+	"created_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12'\n" +
+	"\x05stops\x18\x0e \x03(\v2\x11.tracking.v1.StopR\x05stops\" \n" +
+	"\x0eGetTripRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xc8\x01\n" +
+	"\x10ListTripsRequest\x12\x1b\n" +
+	"\tdriver_id\x18\x01 \x01(\tR\bdriverId\x12\x19\n" +
+	"\broute_id\x18\x02 \x01(\tR\arouteId\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\x03R\x02to\x12\x1b\n" +
+	"\tpage_size\x18\x05 \x01(\x05R\bpageSize\x12\x1c\n" +
+	"\tascending\x18\x06 \x01(\bR\tascending\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\a \x01(\tR\tpageToken\"d\n" +
+	"\x11ListTripsResponse\x12'\n" +
+	"\x05trips\x18\x01 \x03(\v2\x11.tracking.v1.TripR\x05trips\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\x8b\x01\n" +
+	"\x1aStreamLivePositionsRequest\x12\x1b\n" +
+	"\troute_ids\x18\x01 \x03(\tR\brouteIds\x12\x1d\n" +
+	"\n" +
+	"driver_ids\x18\x02 \x03(\tR\tdriverIds\x121\n" +
+	"\bgeofence\x18\x03 \x03(\v2\x15.tracking.v1.LocationR\bgeofence\"\xaf\x01\n" +
+	"\fLivePosition\x12\x1b\n" +
+	"\tdriver_id\x18\x01 \x01(\tR\bdriverId\x12\x19\n" +
+	"\broute_id\x18\x02 \x01(\tR\arouteId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x121\n" +
+	"\blocation\x18\x04 \x01(\v2\x15.tracking.v1.LocationR\blocation\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x04R\ttimestamp2\xf6\x01\n" +
+	"\x10TripQueryService\x129\n" +
+	"\aGetTrip\x12\x1b.tracking.v1.GetTripRequest\x1a\x11.tracking.v1.Trip\x12J\n" +
+	"\tListTrips\x12\x1d.tracking.v1.ListTripsRequest\x1a\x1e.tracking.v1.ListTripsResponse\x12[\n" +
+	"\x13StreamLivePositions\x12'.tracking.v1.StreamLivePositionsRequest\x1a\x19.tracking.v1.LivePosition0\x01B)Z'data-ingestion-microservice/tripquerypbb\x06proto3"
+
+var (
+	file_trip_query_proto_rawDescOnce sync.Once
+	file_trip_query_proto_rawDescData []byte
+)
+
+func file_trip_query_proto_rawDescGZIP() []byte {
+	file_trip_query_proto_rawDescOnce.Do(func() {
+		file_trip_query_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_trip_query_proto_rawDesc), len(file_trip_query_proto_rawDesc)))
+	})
+	return file_trip_query_proto_rawDescData
+}
+
+var file_trip_query_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_trip_query_proto_goTypes = []any{
+	(*Location)(nil),                   // 0: tracking.v1.Location
+	(*TripStats)(nil),                  // 1: tracking.v1.TripStats
+	(*Stop)(nil),                       // 2: tracking.v1.Stop
+	(*Trip)(nil),                       // 3: tracking.v1.Trip
+	(*GetTripRequest)(nil),             // 4: tracking.v1.GetTripRequest
+	(*ListTripsRequest)(nil),           // 5: tracking.v1.ListTripsRequest
+	(*ListTripsResponse)(nil),          // 6: tracking.v1.ListTripsResponse
+	(*StreamLivePositionsRequest)(nil), // 7: tracking.v1.StreamLivePositionsRequest
+	(*LivePosition)(nil),               // 8: tracking.v1.LivePosition
+	(*timestamppb.Timestamp)(nil),      // 9: google.protobuf.Timestamp
+}
+var file_trip_query_proto_depIdxs = []int32{
+	0,  // 0: tracking.v1.Stop.location:type_name -> tracking.v1.Location
+	0,  // 1: tracking.v1.Trip.simplified_route:type_name -> tracking.v1.Location
+	1,  // 2: tracking.v1.Trip.stats:type_name -> tracking.v1.TripStats
+	9,  // 3: tracking.v1.Trip.created_at:type_name -> google.protobuf.Timestamp
+	2,  // 4: tracking.v1.Trip.stops:type_name -> tracking.v1.Stop
+	3,  // 5: tracking.v1.ListTripsResponse.trips:type_name -> tracking.v1.Trip
+	0,  // 6: tracking.v1.StreamLivePositionsRequest.geofence:type_name -> tracking.v1.Location
+	0,  // 7: tracking.v1.LivePosition.location:type_name -> tracking.v1.Location
+	4,  // 8: tracking.v1.TripQueryService.GetTrip:input_type -> tracking.v1.GetTripRequest
+	5,  // 9: tracking.v1.TripQueryService.ListTrips:input_type -> tracking.v1.ListTripsRequest
+	7,  // 10: tracking.v1.TripQueryService.StreamLivePositions:input_type -> tracking.v1.StreamLivePositionsRequest
+	3,  // 11: tracking.v1.TripQueryService.GetTrip:output_type -> tracking.v1.Trip
+	6,  // 12: tracking.v1.TripQueryService.ListTrips:output_type -> tracking.v1.ListTripsResponse
+	8,  // 13: tracking.v1.TripQueryService.StreamLivePositions:output_type -> tracking.v1.LivePosition
+	11, // [11:14] is the sub-list for method output_type
+	8,  // [8:11] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_trip_query_proto_init() }
+func file_trip_query_proto_init() {
+	if File_trip_query_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_trip_query_proto_rawDesc), len(file_trip_query_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_trip_query_proto_goTypes,
+		DependencyIndexes: file_trip_query_proto_depIdxs,
+		MessageInfos:      file_trip_query_proto_msgTypes,
+	}.Build()
+	File_trip_query_proto = out.File
+	file_trip_query_proto_goTypes = nil
+	file_trip_query_proto_depIdxs = nil
+}
@@ -0,0 +1,217 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: trip_query.proto
+
+package tripquerypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TripQueryService_GetTrip_FullMethodName             = "/tracking.v1.TripQueryService/GetTrip"
+	TripQueryService_ListTrips_FullMethodName           = "/tracking.v1.TripQueryService/ListTrips"
+	TripQueryService_StreamLivePositions_FullMethodName = "/tracking.v1.TripQueryService/StreamLivePositions"
+)
+
+// TripQueryServiceClient is the client API for TripQueryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TripQueryService serves stored trips and live positions to internal
+// services.
+type TripQueryServiceClient interface {
+	// GetTrip returns a stored trip with its detected stops.
+	GetTrip(ctx context.Context, in *GetTripRequest, opts ...grpc.CallOption) (*Trip, error)
+	// ListTrips returns one page of stored trips, newest first by default.
+	ListTrips(ctx context.Context, in *ListTripsRequest, opts ...grpc.CallOption) (*ListTripsResponse, error)
+	// StreamLivePositions streams every location processed that matches the
+	// requested routes, drivers, and geofence until the client cancels.
+	// Clients that fall behind are disconnected with RESOURCE_EXHAUSTED.
+	StreamLivePositions(ctx context.Context, in *StreamLivePositionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LivePosition], error)
+}
+
+type tripQueryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTripQueryServiceClient(cc grpc.ClientConnInterface) TripQueryServiceClient {
+	return &tripQueryServiceClient{cc}
+}
+
+func (c *tripQueryServiceClient) GetTrip(ctx context.Context, in *GetTripRequest, opts ...grpc.CallOption) (*Trip, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Trip)
+	err := c.cc.Invoke(ctx, TripQueryService_GetTrip_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tripQueryServiceClient) ListTrips(ctx context.Context, in *ListTripsRequest, opts ...grpc.CallOption) (*ListTripsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTripsResponse)
+	err := c.cc.Invoke(ctx, TripQueryService_ListTrips_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tripQueryServiceClient) StreamLivePositions(ctx context.Context, in *StreamLivePositionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LivePosition], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TripQueryService_ServiceDesc.Streams[0], TripQueryService_StreamLivePositions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamLivePositionsRequest, LivePosition]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TripQueryService_StreamLivePositionsClient = grpc.ServerStreamingClient[LivePosition]
+
+// TripQueryServiceServer is the server API for TripQueryService service.
+// All implementations must embed UnimplementedTripQueryServiceServer
+// for forward compatibility.
+//
+// TripQueryService serves stored trips and live positions to internal
+// services.
+type TripQueryServiceServer interface {
+	// GetTrip returns a stored trip with its detected stops.
+	GetTrip(context.Context, *GetTripRequest) (*Trip, error)
+	// ListTrips returns one page of stored trips, newest first by default.
+	ListTrips(context.Context, *ListTripsRequest) (*ListTripsResponse, error)
+	// StreamLivePositions streams every location processed that matches the
+	// requested routes, drivers, and geofence until the client cancels.
+	// Clients that fall behind are disconnected with RESOURCE_EXHAUSTED.
+	StreamLivePositions(*StreamLivePositionsRequest, grpc.ServerStreamingServer[LivePosition]) error
+	mustEmbedUnimplementedTripQueryServiceServer()
+}
+
+// UnimplementedTripQueryServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTripQueryServiceServer struct{}
+
+func (UnimplementedTripQueryServiceServer) GetTrip(context.Context, *GetTripRequest) (*Trip, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrip not implemented")
+}
+func (UnimplementedTripQueryServiceServer) ListTrips(context.Context, *ListTripsRequest) (*ListTripsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrips not implemented")
+}
+func (UnimplementedTripQueryServiceServer) StreamLivePositions(*StreamLivePositionsRequest, grpc.ServerStreamingServer[LivePosition]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLivePositions not implemented")
+}
+func (UnimplementedTripQueryServiceServer) mustEmbedUnimplementedTripQueryServiceServer() {}
+func (UnimplementedTripQueryServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeTripQueryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TripQueryServiceServer will
+// result in compilation errors.
+type UnsafeTripQueryServiceServer interface {
+	mustEmbedUnimplementedTripQueryServiceServer()
+}
+
+func RegisterTripQueryServiceServer(s grpc.ServiceRegistrar, srv TripQueryServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTripQueryServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TripQueryService_ServiceDesc, srv)
+}
+
+func _TripQueryService_GetTrip_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTripRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TripQueryServiceServer).GetTrip(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TripQueryService_GetTrip_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TripQueryServiceServer).GetTrip(ctx, req.(*GetTripRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TripQueryService_ListTrips_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTripsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TripQueryServiceServer).ListTrips(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TripQueryService_ListTrips_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TripQueryServiceServer).ListTrips(ctx, req.(*ListTripsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TripQueryService_StreamLivePositions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLivePositionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TripQueryServiceServer).StreamLivePositions(m, &grpc.GenericServerStream[StreamLivePositionsRequest, LivePosition]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TripQueryService_StreamLivePositionsServer = grpc.ServerStreamingServer[LivePosition]
+
+// TripQueryService_ServiceDesc is the grpc.ServiceDesc for TripQueryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TripQueryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tracking.v1.TripQueryService",
+	HandlerType: (*TripQueryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTrip",
+			Handler:    _TripQueryService_GetTrip_Handler,
+		},
+		{
+			MethodName: "ListTrips",
+			Handler:    _TripQueryService_ListTrips_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLivePositions",
+			Handler:       _TripQueryService_StreamLivePositions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "trip_query.proto",
+}
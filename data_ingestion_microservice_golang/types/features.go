@@ -0,0 +1,68 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Feature flags that toggle capabilities at runtime
+const (
+	// FeatureLiveRepublish republishes locations to Redis Pub/Sub and the
+	// public feed
+	FeatureLiveRepublish = "live_republish"
+	// FeatureRawRouteArchival keeps the raw points of finalized trips in the
+	// raw routes collection and the object storage archive
+	FeatureRawRouteArchival = "raw_route_archival"
+)
+
+// FeatureFlags lists the known feature flags
+var FeatureFlags = []string{FeatureLiveRepublish, FeatureRawRouteArchival}
+
+// FeatureFlagKey returns the key of a flag for a tenant, or of the flag for
+// every tenant when tenant is empty
+func FeatureFlagKey(tenant, flag string) string {
+	if tenant == "" {
+		return flag
+	}
+	return tenant + ":" + flag
+}
+
+// ParseFeatureFlags parses feature flags written as flag=bool or
+// tenant:flag=bool into a map keyed like FeatureFlagKey
+func ParseFeatureFlags(specs []string) (map[string]bool, error) {
+	flags := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("feature flag %q must be [tenant:]flag=bool", spec)
+		}
+		key = strings.TrimSpace(key)
+		enabled, err := ParseFeatureFlag(key, value)
+		if err != nil {
+			return nil, err
+		}
+		flags[key] = enabled
+	}
+	return flags, nil
+}
+
+// ParseFeatureFlag checks that key names a known flag, optionally prefixed
+// by a tenant, and parses its value
+func ParseFeatureFlag(key, value string) (bool, error) {
+	tenant, flag, scoped := strings.Cut(key, ":")
+	if !scoped {
+		flag = tenant
+	} else if tenant == "" {
+		return false, fmt.Errorf("feature flag %q has an empty tenant", key)
+	}
+	if !slices.Contains(FeatureFlags, flag) {
+		return false, fmt.Errorf("unknown feature flag %q", flag)
+	}
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("feature flag %q has an invalid value %q", key, value)
+	}
+	return enabled, nil
+}
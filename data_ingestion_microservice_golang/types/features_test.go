@@ -0,0 +1,30 @@
+package types
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseFeatureFlags(t *testing.T) {
+	flags, err := ParseFeatureFlags([]string{"live_republish=false", "acme:live_republish=true", "raw_route_archival = 0"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]bool{
+		"live_republish":      false,
+		"acme:live_republish": true,
+		"raw_route_archival":  false,
+	}
+	if !maps.Equal(flags, expected) {
+		t.Errorf("Expected %v, got %v", expected, flags)
+	}
+	if FeatureFlagKey("acme", FeatureLiveRepublish) != "acme:live_republish" || FeatureFlagKey("", FeatureLiveRepublish) != "live_republish" {
+		t.Error("Expected tenant keys to prefix the flag")
+	}
+
+	for _, spec := range []string{"live_republish", "live_republish=maybe", "map_matching=true", ":live_republish=true"} {
+		if _, err := ParseFeatureFlags([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
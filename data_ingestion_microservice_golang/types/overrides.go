@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SimplificationAlgorithms lists the route simplification algorithms
+var SimplificationAlgorithms = []string{"douglas-peucker", "visvalingam-whyatt"}
+
+// ParseRouteOverrides parses per-route simplification overrides written as
+// pattern=tolerance, pattern=tolerance:algorithm, or pattern=:algorithm
+func ParseRouteOverrides(specs []string) ([]RouteSimplificationOverride, error) {
+	overrides := make([]RouteSimplificationOverride, 0, len(specs))
+	for _, spec := range specs {
+		pattern, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("route override %q must be pattern=tolerance[:algorithm]", spec)
+		}
+		override := RouteSimplificationOverride{Pattern: strings.TrimSpace(pattern)}
+		tolerance, algorithm, _ := strings.Cut(value, ":")
+		if tolerance = strings.TrimSpace(tolerance); tolerance != "" {
+			parsed, err := strconv.ParseFloat(tolerance, 64)
+			if err != nil {
+				return nil, fmt.Errorf("route override %q has an invalid tolerance: %w", spec, err)
+			}
+			override.Tolerance = parsed
+		}
+		override.Algorithm = strings.TrimSpace(algorithm)
+		if err := override.Check(); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, nil
+}
+
+// Check reports whether the override has a valid pattern and changes the
+// tolerance or algorithm to valid values
+func (o RouteSimplificationOverride) Check() error {
+	if o.Pattern == "" {
+		return fmt.Errorf("route override without a pattern")
+	}
+	if _, err := path.Match(o.Pattern, ""); err != nil {
+		return fmt.Errorf("route override %q has an invalid pattern: %w", o.Pattern, err)
+	}
+	if o.Tolerance < 0 || (o.Tolerance == 0 && o.Algorithm == "") {
+		return fmt.Errorf("route override %q needs a positive tolerance or an algorithm", o.Pattern)
+	}
+	if o.Algorithm != "" && !slices.Contains(SimplificationAlgorithms, o.Algorithm) {
+		return fmt.Errorf("route override %q has an unknown algorithm %q", o.Pattern, o.Algorithm)
+	}
+	return nil
+}
+
+// Matches reports whether the override applies to a route
+func (o RouteSimplificationOverride) Matches(routeID string) bool {
+	matched, _ := path.Match(o.Pattern, routeID)
+	return matched
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRouteOverrides(t *testing.T) {
+	overrides, err := ParseRouteOverrides([]string{"circulator-*=0.00002", "intercity-*=0.0005:visvalingam-whyatt", "express-?=:visvalingam-whyatt"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []RouteSimplificationOverride{
+		{Pattern: "circulator-*", Tolerance: 0.00002},
+		{Pattern: "intercity-*", Tolerance: 0.0005, Algorithm: "visvalingam-whyatt"},
+		{Pattern: "express-?", Algorithm: "visvalingam-whyatt"},
+	}
+	if !slices.Equal(overrides, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, overrides)
+	}
+	if !overrides[0].Matches("circulator-12") || overrides[2].Matches("express-12") {
+		t.Error("Expected glob patterns to match route IDs")
+	}
+
+	for _, spec := range []string{"circulator-*", "circulator-*=fast", "circulator-*=-1", "circulator-*=", "[=0.1", "a=0.1:spline"} {
+		if _, err := ParseRouteOverrides([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
@@ -1,12 +1,48 @@
 package types
 
+import (
+	"time"
+)
+
 // BusMessage represents the incoming MQTT message structure
 type BusMessage struct {
-	DriverID        string   `json:"driverId"`
-	DriverLocation  Location `json:"driverLocation"`
-	Timestamp       uint64   `json:"timestamp"`
-	CurrentRouteID  string   `json:"currentRouteId"`
-	Status          string   `json:"status"` // "in_route" or "finished"
+	DriverID       string   `json:"driverId"`
+	VehicleID      string   `json:"vehicleId,omitempty"` // optional, for drivers switching vehicles
+	DriverLocation Location `json:"driverLocation"`
+	Timestamp      uint64   `json:"timestamp"`
+	CurrentRouteID string   `json:"currentRouteId"`
+	Status         string   `json:"status"` // "in_route", "finished", "passenger_count", "shift_start", or "shift_end"
+	Telemetry
+	Passengers *PassengerCount `json:"passengers,omitempty"` // only on "passenger_count" messages
+}
+
+// PassengerCount holds the boardings and alightings an automatic passenger
+// counter reported at once, with the stop when the device knows it
+type PassengerCount struct {
+	Boardings  int    `json:"boardings"`
+	Alightings int    `json:"alightings"`
+	StopID     string `json:"stopId,omitempty"`
+}
+
+// Telemetry holds the optional readings devices send along with a location.
+// Fields the device did not send are nil, so zero readings are kept apart
+// from missing ones.
+type Telemetry struct {
+	AltitudeMeters *float64 `json:"altitude,omitempty"`
+	Heading        *float64 `json:"heading,omitempty"`  // degrees clockwise from north
+	SpeedKmh       *float64 `json:"speed,omitempty"`    // km/h as measured by the device
+	AccuracyMeters *float64 `json:"accuracy,omitempty"` // horizontal accuracy radius
+	Satellites     *int     `json:"satellites,omitempty"`
+	BatteryPercent *float64 `json:"battery,omitempty"`
+	// Accelerometer readings in m/s²: forward positive and braking
+	// negative, and sideways in either direction
+	AccelerationMps2        *float64 `json:"acceleration,omitempty"`
+	LateralAccelerationMps2 *float64 `json:"lateralAcceleration,omitempty"`
+}
+
+// IsZero reports whether no telemetry was sent
+func (t Telemetry) IsZero() bool {
+	return t == Telemetry{}
 }
 
 // Location represents GPS coordinates
@@ -15,37 +51,1563 @@ type Location struct {
 	Longitude float64 `json:"longitude"`
 }
 
+// TrackPoint is a location stamped with the device timestamp (milliseconds)
+// and the telemetry sent with it. Points buffered per vehicle record the
+// driver who sent them, and points of passenger count messages the count.
+type TrackPoint struct {
+	Location
+	Timestamp uint64 `json:"timestamp,omitempty"`
+	DriverID  string `json:"driverId,omitempty"`
+	Telemetry
+	Passengers *PassengerCount `json:"passengers,omitempty"`
+}
+
+// RoutePoint is a point of a simplified route stamped with the device
+// timestamp (milliseconds) of the raw point it was kept from. Trips stored
+// before schema version 5 have no timestamps.
+type RoutePoint struct {
+	Location  `bson:",inline"`
+	Timestamp uint64 `bson:"timestamp,omitempty" json:"timestamp,omitempty"`
+}
+
+// RouteLocations returns the locations of a route's points
+func RouteLocations(route []RoutePoint) []Location {
+	locations := make([]Location, len(route))
+	for i, point := range route {
+		locations[i] = point.Location
+	}
+	return locations
+}
+
+// GeoJSONGeometry is a GeoJSON geometry as stored in MongoDB
+type GeoJSONGeometry struct {
+	Type        string      `bson:"type" json:"type"`
+	Coordinates interface{} `bson:"coordinates" json:"coordinates"`
+}
+
+// TripStats holds summary statistics computed from the raw points of a trip
+type TripStats struct {
+	DurationSeconds float64 `json:"durationSeconds" bson:"durationSeconds"`
+	MovingSeconds   float64 `json:"movingSeconds" bson:"movingSeconds"`
+	IdleSeconds     float64 `json:"idleSeconds" bson:"idleSeconds"`
+	DistanceMeters  float64 `json:"distanceMeters" bson:"distanceMeters"`
+	AverageSpeedKmh float64 `json:"averageSpeedKmh" bson:"averageSpeedKmh"`
+	MaxSpeedKmh     float64 `json:"maxSpeedKmh" bson:"maxSpeedKmh"`
+	Stops           int     `json:"stops" bson:"stops"`
+}
+
+// TripTelemetry summarizes the telemetry of the raw points of a trip. A
+// reading no point carried is nil.
+type TripTelemetry struct {
+	Points              int      `json:"points" bson:"points"` // raw points with any telemetry
+	MinAltitudeMeters   *float64 `json:"minAltitudeMeters,omitempty" bson:"minAltitudeMeters,omitempty"`
+	MaxAltitudeMeters   *float64 `json:"maxAltitudeMeters,omitempty" bson:"maxAltitudeMeters,omitempty"`
+	MaxSpeedKmh         *float64 `json:"maxSpeedKmh,omitempty" bson:"maxSpeedKmh,omitempty"`
+	AvgAccuracyMeters   *float64 `json:"avgAccuracyMeters,omitempty" bson:"avgAccuracyMeters,omitempty"`
+	MinSatellites       *int     `json:"minSatellites,omitempty" bson:"minSatellites,omitempty"`
+	BatteryStartPercent *float64 `json:"batteryStartPercent,omitempty" bson:"batteryStartPercent,omitempty"`
+	BatteryEndPercent   *float64 `json:"batteryEndPercent,omitempty" bson:"batteryEndPercent,omitempty"`
+}
+
+// FleetEvent is a trip lifecycle event streamed to dashboards: trip_started,
+// location_update, or trip_finished
+type FleetEvent struct {
+	Type      string     `json:"type"`
+	DriverID  string     `json:"driverId"`
+	RouteID   string     `json:"routeId"`
+	TripID    string     `json:"tripId,omitempty"`
+	Location  Location   `json:"location"`
+	Timestamp uint64     `json:"timestamp"`
+	Stats     *TripStats `json:"stats,omitempty"`
+	Status    string     `json:"status,omitempty"`
+}
+
+// Stop is a period during a trip in which the vehicle stayed idle
+type Stop struct {
+	Location        Location `json:"location"`
+	StartTimestamp  uint64   `json:"startTimestamp"`
+	EndTimestamp    uint64   `json:"endTimestamp"`
+	DurationSeconds float64  `json:"durationSeconds"`
+}
+
+// SpeedingViolation is a period during a trip in which the vehicle drove
+// above the speed limit. LimitKmh is the limit where it was fastest.
+type SpeedingViolation struct {
+	Start           Location `json:"start" bson:"start"`
+	End             Location `json:"end" bson:"end"`
+	StartTimestamp  uint64   `json:"startTimestamp" bson:"startTimestamp"`
+	EndTimestamp    uint64   `json:"endTimestamp" bson:"endTimestamp"`
+	DurationSeconds float64  `json:"durationSeconds" bson:"durationSeconds"`
+	DistanceMeters  float64  `json:"distanceMeters" bson:"distanceMeters"`
+	MaxSpeedKmh     float64  `json:"maxSpeedKmh" bson:"maxSpeedKmh"`
+	LimitKmh        float64  `json:"limitKmh" bson:"limitKmh"`
+}
+
+// HarshEvent is a moment of harsh braking, acceleration, or cornering.
+// AccelerationMps2 is the peak magnitude of the event, measured by the
+// device's accelerometer or derived from its GPS speeds and headings.
+type HarshEvent struct {
+	Type             string   `json:"type" bson:"type"` // "harsh_braking", "harsh_acceleration", or "harsh_cornering"
+	Location         Location `json:"location" bson:"location"`
+	Timestamp        uint64   `json:"timestamp" bson:"timestamp"`
+	AccelerationMps2 float64  `json:"accelerationMps2" bson:"accelerationMps2"`
+	SpeedKmh         float64  `json:"speedKmh" bson:"speedKmh"`
+	Source           string   `json:"source" bson:"source"` // "accelerometer" or "gps"
+}
+
+// TripSafety counts the harsh driving events of a trip. Score, from 0 to
+// 100, falls with the events per 100 km.
+type TripSafety struct {
+	HarshBraking      int          `json:"harshBraking" bson:"harshBraking"`
+	HarshAcceleration int          `json:"harshAcceleration" bson:"harshAcceleration"`
+	HarshCornering    int          `json:"harshCornering" bson:"harshCornering"`
+	EventsPer100Km    float64      `json:"eventsPer100Km" bson:"eventsPer100Km"`
+	Score             float64      `json:"score" bson:"score"`
+	Events            []HarshEvent `json:"events,omitempty" bson:"events,omitempty"`
+}
+
+// EventCount returns the number of harsh events of every type
+func (s TripSafety) EventCount() int {
+	return s.HarshBraking + s.HarshAcceleration + s.HarshCornering
+}
+
+// TripQuality rates how reliable the trace of a trip is, from 0 to 100.
+// Sampling counts the intervals within a factor of two of the median as
+// regular, gaps are intervals longer than the gap threshold, and the
+// accuracy fields are nil when no point reported its accuracy. Flags name
+// the problems found, such as has_gaps and low_accuracy.
+type TripQuality struct {
+	Score                 float64  `json:"score" bson:"score"`
+	MedianIntervalSeconds float64  `json:"medianIntervalSeconds" bson:"medianIntervalSeconds"`
+	RegularPercent        float64  `json:"regularPercent" bson:"regularPercent"`
+	Gaps                  int      `json:"gaps" bson:"gaps"`
+	GapSeconds            float64  `json:"gapSeconds" bson:"gapSeconds"`
+	MedianAccuracyMeters  *float64 `json:"medianAccuracyMeters,omitempty" bson:"medianAccuracyMeters,omitempty"`
+	P90AccuracyMeters     *float64 `json:"p90AccuracyMeters,omitempty" bson:"p90AccuracyMeters,omitempty"`
+	LowAccuracyPercent    *float64 `json:"lowAccuracyPercent,omitempty" bson:"lowAccuracyPercent,omitempty"`
+	OutliersRemoved       int      `json:"outliersRemoved" bson:"outliersRemoved"`
+	Flags                 []string `json:"flags" bson:"flags"`
+}
+
+// IdlePeriod is a time a vehicle stood still with its engine presumably
+// running, away from the terminals where it is expected to wait
+type IdlePeriod struct {
+	Location        Location `json:"location" bson:"location"`
+	StartTimestamp  uint64   `json:"startTimestamp" bson:"startTimestamp"`
+	EndTimestamp    uint64   `json:"endTimestamp" bson:"endTimestamp"`
+	DurationSeconds float64  `json:"durationSeconds" bson:"durationSeconds"`
+}
+
+// ZoneTime is the time a trip spent in an operational zone and how many
+// times it entered it
+type ZoneTime struct {
+	ZoneID  string  `json:"zoneId" bson:"zoneId"`
+	Seconds float64 `json:"seconds" bson:"seconds"`
+	Visits  int     `json:"visits" bson:"visits"`
+}
+
+// TripIdle holds the idle periods of a trip and their total length
+type TripIdle struct {
+	IdleSeconds float64      `json:"idleSeconds" bson:"idleSeconds"`
+	Periods     []IdlePeriod `json:"periods" bson:"periods"`
+}
+
+// StopVisit is a stay of a vehicle at a planned stop. DepartureTimestamp is
+// zero while the vehicle is still there; ScheduledTimestamp and
+// DelaySeconds, positive when late, are only set for stops with a scheduled
+// offset.
+type StopVisit struct {
+	StopID             string   `json:"stopId" bson:"stopId"`
+	Name               string   `json:"name,omitempty" bson:"name,omitempty"`
+	Location           Location `json:"location" bson:"location"`
+	ArrivalTimestamp   uint64   `json:"arrivalTimestamp" bson:"arrivalTimestamp"`
+	DepartureTimestamp uint64   `json:"departureTimestamp,omitempty" bson:"departureTimestamp,omitempty"`
+	DwellSeconds       float64  `json:"dwellSeconds" bson:"dwellSeconds"`
+	ScheduledTimestamp uint64   `json:"scheduledTimestamp,omitempty" bson:"scheduledTimestamp,omitempty"`
+	DelaySeconds       *float64 `json:"delaySeconds,omitempty" bson:"delaySeconds,omitempty"`
+}
+
+// LoadProfile is the passenger load of a trip: the boardings and alightings
+// at every stop in the order served, and how many passengers were on board
+// when the vehicle left it
+type LoadProfile struct {
+	Boardings  int        `json:"boardings" bson:"boardings"`
+	Alightings int        `json:"alightings" bson:"alightings"`
+	PeakLoad   int        `json:"peakLoad" bson:"peakLoad"`
+	Stops      []StopLoad `json:"stops" bson:"stops"`
+}
+
+// StopLoad is the passengers counted at a stop. StopID is empty for counts
+// away from the known stops, which keep the location they were counted at.
+type StopLoad struct {
+	StopID     string   `json:"stopId,omitempty" bson:"stopId,omitempty"`
+	Location   Location `json:"location" bson:"location"`
+	Timestamp  uint64   `json:"timestamp" bson:"timestamp"`
+	Boardings  int      `json:"boardings" bson:"boardings"`
+	Alightings int      `json:"alightings" bson:"alightings"`
+	Load       int      `json:"load" bson:"load"`
+}
+
+// TripAdherence compares a trip with the planned route of its route. Score
+// is the on-route percentage, averaged with the percentage of stops served
+// on time when the route has stops.
+type TripAdherence struct {
+	OnRoutePercent float64         `json:"onRoutePercent" bson:"onRoutePercent"`
+	StopsVisited   int             `json:"stopsVisited" bson:"stopsVisited"`
+	StopsOnTime    int             `json:"stopsOnTime" bson:"stopsOnTime"`
+	Stops          []StopAdherence `json:"stops,omitempty" bson:"stops,omitempty"`
+	Score          float64         `json:"score" bson:"score"`
+}
+
+// StopAdherence is when a trip reached a planned stop, and how far from the
+// timetable. ArrivalTimestamp is zero for missed stops; ScheduledTimestamp
+// and DeviationSeconds, positive when late, are only set for stops with a
+// scheduled offset. Stops without one are on time when reached.
+type StopAdherence struct {
+	StopID             string   `json:"stopId" bson:"stopId"`
+	ArrivalTimestamp   uint64   `json:"arrivalTimestamp,omitempty" bson:"arrivalTimestamp,omitempty"`
+	ScheduledTimestamp uint64   `json:"scheduledTimestamp,omitempty" bson:"scheduledTimestamp,omitempty"`
+	DeviationSeconds   *float64 `json:"deviationSeconds,omitempty" bson:"deviationSeconds,omitempty"`
+	OnTime             bool     `json:"onTime" bson:"onTime"`
+}
+
+// Trip is a finalized trip ready to be persisted
+type Trip struct {
+	ID                    string
+	DriverID              string
+	VehicleID             string // empty for trips of messages without a vehicle
+	CurrentRouteID        string
+	SimplifiedRoute       []RoutePoint
+	Timestamp             int64
+	OriginalPointsCount   int
+	SimplifiedPointsCount int
+	CompressionRatio      float64
+	ReductionPercent      float64
+	Stats                 TripStats
+	RawArchiveURL         string
+	Zones                 []string // IDs of the geofences visited
+	SpeedingViolations    []SpeedingViolation
+	StopVisits            []StopVisit
+	Telemetry             *TripTelemetry // nil when no point carried telemetry
+	Adherence             *TripAdherence // nil when the route has no planned path
+	LoadProfile           *LoadProfile   // nil when no passengers were counted
+	Safety                *TripSafety    // nil when harsh driving detection is disabled
+	Idle                  *TripIdle      // nil when idle analysis is disabled
+	Quality               *TripQuality   // nil when quality scoring is disabled
+	ZoneTime              []ZoneTime     // nil when zone time accounting is disabled
+	Status                string         // "finished", "auto_closed", or "segmented"
+	CreatedAt             time.Time
+}
+
+// StoredTrip is a trip document read back from MongoDB. Fields added by
+// later schema versions are zero for older documents.
+type StoredTrip struct {
+	ID                    string              `bson:"_id" json:"id"`
+	SchemaVersion         int                 `bson:"schemaVersion" json:"schemaVersion"`
+	DriverID              string              `bson:"driverId" json:"driverId"`
+	VehicleID             string              `bson:"vehicleId,omitempty" json:"vehicleId,omitempty"`
+	CurrentRouteID        string              `bson:"currentRouteId" json:"currentRouteId"`
+	SimplifiedRoute       []RoutePoint        `bson:"simplifiedRoute" json:"simplifiedRoute"`
+	Timestamp             int64               `bson:"timestamp" json:"timestamp"`
+	OriginalPointsCount   int                 `bson:"originalPointsCount" json:"originalPointsCount"`
+	SimplifiedPointsCount int                 `bson:"simplifiedPointsCount" json:"simplifiedPointsCount"`
+	CompressionRatio      float64             `bson:"compressionRatio" json:"compressionRatio"`
+	ReductionPercent      float64             `bson:"reductionPercent" json:"reductionPercent"`
+	Stats                 TripStats           `bson:"stats" json:"stats"`
+	RawArchiveURL         string              `bson:"rawArchiveUrl,omitempty" json:"rawArchiveUrl,omitempty"`
+	Zones                 []string            `bson:"zones,omitempty" json:"zones,omitempty"`
+	SpeedingViolations    []SpeedingViolation `bson:"speedingViolations,omitempty" json:"speedingViolations,omitempty"`
+	StopVisits            []StopVisit         `bson:"stopVisits,omitempty" json:"stopVisits,omitempty"`
+	Telemetry             *TripTelemetry      `bson:"telemetry,omitempty" json:"telemetry,omitempty"`
+	Adherence             *TripAdherence      `bson:"adherence,omitempty" json:"adherence,omitempty"`
+	LoadProfile           *LoadProfile        `bson:"loadProfile,omitempty" json:"loadProfile,omitempty"`
+	Safety                *TripSafety         `bson:"safety,omitempty" json:"safety,omitempty"`
+	Idle                  *TripIdle           `bson:"idle,omitempty" json:"idle,omitempty"`
+	Quality               *TripQuality        `bson:"quality,omitempty" json:"quality,omitempty"`
+	ZoneTime              []ZoneTime          `bson:"zoneTime,omitempty" json:"zoneTime,omitempty"`
+	Status                string              `bson:"status,omitempty" json:"status,omitempty"`
+	CreatedAt             time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// TripQuery selects a page of stored trips. Zero bounds are unbounded, and
+// From/To are trip timestamps in milliseconds ([From, To)). MinQuality
+// keeps the trips with a quality score of at least it, and WithoutFlag
+// drops the trips flagged with it.
+type TripQuery struct {
+	DriverID    string
+	VehicleID   string
+	RouteID     string
+	From        int64
+	To          int64
+	MinQuality  float64
+	WithoutFlag string
+	Limit       int
+	Descending  bool
+	Cursor      string
+}
+
+// TripPage is one page of trips and the cursor of the next page, if any
+type TripPage struct {
+	Trips      []StoredTrip `json:"trips"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+// BoundingBox is a longitude/latitude rectangle
+type BoundingBox struct {
+	MinLon float64 `json:"minLon"`
+	MinLat float64 `json:"minLat"`
+	MaxLon float64 `json:"maxLon"`
+	MaxLat float64 `json:"maxLat"`
+}
+
+// TripGeoQuery selects trips whose simplified route intersects a bounding
+// box or passes within RadiusMeters of Near. From/To bound the trip
+// timestamp in milliseconds ([From, To)) when set.
+type TripGeoQuery struct {
+	BoundingBox  *BoundingBox
+	Near         *Location
+	RadiusMeters float64
+	From         int64
+	To           int64
+	Limit        int
+}
+
+// TripStatsQuery selects the trips aggregated by a stats report and how they
+// are grouped. From/To bound the trip timestamp in milliseconds ([From, To)).
+type TripStatsQuery struct {
+	From    int64
+	To      int64
+	GroupBy string
+}
+
+// TripAggregate summarizes a group of trips
+type TripAggregate struct {
+	Key                 string  `json:"key,omitempty"`
+	Trips               int     `json:"trips"`
+	DistanceKm          float64 `json:"distanceKm"`
+	AvgCompressionRatio float64 `json:"avgCompressionRatio"`
+	AvgDurationSeconds  float64 `json:"avgDurationSeconds"`
+	// AvgAdherenceScore averages the trips scored against a planned route,
+	// and is nil when none was
+	AvgAdherenceScore *float64 `json:"avgAdherenceScore,omitempty"`
+	// HarshEvents and SafetyScore cover the trips checked for harsh
+	// driving, and SafetyScore is nil when none was
+	HarshEvents int      `json:"harshEvents,omitempty"`
+	SafetyScore *float64 `json:"safetyScore,omitempty"`
+}
+
+// TripStatsReport holds per-group and overall trip aggregates
+type TripStatsReport struct {
+	GroupBy string          `json:"groupBy"`
+	Groups  []TripAggregate `json:"groups"`
+	Totals  TripAggregate   `json:"totals"`
+}
+
+// IdleAggregate sums the idling of a group of trips checked for it.
+// IdlePercent is the share of their duration spent idle.
+type IdleAggregate struct {
+	Key         string  `json:"key,omitempty"`
+	Trips       int     `json:"trips"`
+	IdlePeriods int     `json:"idlePeriods"`
+	IdleMinutes float64 `json:"idleMinutes"`
+	IdlePercent float64 `json:"idlePercent"`
+}
+
+// IdleReport holds per-group and overall idle time
+type IdleReport struct {
+	GroupBy string          `json:"groupBy"`
+	Groups  []IdleAggregate `json:"groups"`
+	Totals  IdleAggregate   `json:"totals"`
+}
+
+// ZoneTimeAggregate sums the time a group of trips spent in a zone
+type ZoneTimeAggregate struct {
+	Key     string  `json:"key,omitempty"`
+	ZoneID  string  `json:"zoneId"`
+	Trips   int     `json:"trips"`
+	Visits  int     `json:"visits"`
+	Minutes float64 `json:"minutes"`
+}
+
+// ZoneTimeReport holds the time spent in every zone per group and overall
+type ZoneTimeReport struct {
+	GroupBy string              `json:"groupBy"`
+	Groups  []ZoneTimeAggregate `json:"groups"`
+	Totals  []ZoneTimeAggregate `json:"totals"`
+}
+
 // Config holds all configuration values for the application
 type Config struct {
+	// Profile selects a bundle of defaults for an environment: dev,
+	// staging, or prod. Prod also refuses insecure settings.
+	Profile string
+	// InstanceID identifies this instance to the others, in the cluster
+	// membership, driver leases, finalization consumer group, and
+	// finalization checkpoints. It must be unique and survive restarts.
+	InstanceID          string
+	Storage             StorageConfig
 	MQTT                MQTTConfig
 	Redis               RedisConfig
 	MongoDB             MongoDBConfig
 	RouteSimplification RouteSimplificationConfig
+	RouteDeviation      RouteDeviationConfig
+	Geofence            GeofenceConfig
+	Signatures          SignatureConfig
+	ETA                 ETAConfig
+	Adherence           AdherenceConfig
+	PassengerCounts     PassengerCountConfig
+	HarshDriving        HarshDrivingConfig
+	Idle                IdleConfig
+	Quality             QualityConfig
+	ZoneTime            ZoneTimeConfig
+	StopEvents          StopEventsConfig
+	Headway             HeadwayConfig
+	Speeding            SpeedingConfig
+	Shifts              ShiftConfig
+	Odometer            OdometerConfig
+	Heatmap             HeatmapConfig
+	TripStats           TripStatsConfig
+	Ingest              IngestConfig
+	Dedup               DedupConfig
+	RateLimit           RateLimitConfig
+	Partition           PartitionConfig
+	Standby             StandbyConfig
+	Janitor             JanitorConfig
+	Segmentation        SegmentationConfig
+	Shutdown            ShutdownConfig
+	Finalization        FinalizationConfig
+	Failures            FailureConfig
+	Retry               RetryConfig
+	Startup             StartupConfig
+	Secrets             SecretsConfig
+	CircuitBreaker      CircuitBreakerConfig
+	RawRoutes           RawRouteConfig
+	Timescale           TimescaleConfig
+	ClickHouse          ClickHouseConfig
+	OpenSearch          OpenSearchConfig
+	Kafka               KafkaConfig
+	Archive             ArchiveConfig
+	HTTP                HTTPConfig
+	GRPC                GRPCConfig
+	Auth                AuthConfig
+	PublicFeed          PublicFeedConfig
+	Webhooks            WebhookConfig
+	Tracing             TracingConfig
+	Log                 LogConfig
+	ErrorReporting      ErrorReportingConfig
+	FeatureFlags        FeatureFlagConfig
+	RuntimeSettings     RuntimeSettingsConfig
+	// Malformed lists the settings whose values could not be parsed and
+	// fell back to their defaults, reported by Validate
+	Malformed []string
+}
+
+// StorageConfig selects between external (Redis/MongoDB) and embedded storage
+type StorageConfig struct {
+	Mode         string
+	EmbeddedPath string
 }
 
 // MQTTConfig holds MQTT broker configuration
 type MQTTConfig struct {
-	Broker   string
-	Port     int
-	ClientID string
-	Topic    string
+	Broker       string
+	Port         int
+	ClientID     string
+	Topic        string
+	CleanSession bool
+	Username     string
+	Password     string
+	TLS          bool
 }
 
 // RedisConfig holds Redis connection configuration
 type RedisConfig struct {
-	Address  string
-	Password string
-	DB       int
+	Address               string
+	Password              string
+	TLS                   bool
+	DB                    int
+	PoolSize              int
+	MinIdleConns          int
+	DialTimeout           time.Duration
+	ReadTimeout           time.Duration
+	WriteTimeout          time.Duration
+	MaxRetries            int
+	StreamMaxLen          int64
+	FinalizeConsumerGroup bool
+	FinalizeStream        string
+	FinalizeGroup         string
+	FinalizeClaimIdle     time.Duration
+	RouteTTL              time.Duration
+	WatchExpiredRoutes    bool
+	SentinelMasterName    string
+	SentinelAddresses     []string
+	SentinelUsername      string
+	SentinelPassword      string
+	PipelineSize          int
+	PipelineInterval      time.Duration
+	RouteMaxPoints        int
+	LivePositions         bool
+	LivePositionsKey      string
+	LivePubSub            bool
+	LiveChannelPrefix     string
+	LiveStaleAfter        time.Duration
+	FeatureFlagsKey       string
+	SettingsKey           string
+	SettingsChannel       string
+	EventsChannel         string
+	ETAKeyPrefix          string
 }
 
 // MongoDBConfig holds MongoDB connection configuration
 type MongoDBConfig struct {
-	URI        string
-	Database   string
-	Collection string
+	URI                     string
+	Username                string
+	Password                string
+	Database                string
+	Collection              string
+	EnsureIndexes           bool
+	WriteConcern            string
+	WriteJournal            bool
+	WriteTimeout            time.Duration
+	ReadPreference          string
+	RetryWrites             bool
+	BatchSize               int
+	BatchInterval           time.Duration
+	TripSchemaVersion       int
+	MigrateOnStartup        bool
+	RetentionDays           int
+	Transactions            bool
+	FinalizedCollection     string
+	SettingsCollection      string
+	RouteSettingsCollection string
+	AuditCollection         string
+	WebhookDLQCollection    string
+	AssignmentCollection    string
 }
 
 // RouteSimplificationConfig holds route simplification parameters
 type RouteSimplificationConfig struct {
-	Tolerance float64
-} 
\ No newline at end of file
+	Tolerance       float64
+	Algorithm       string
+	Overrides       []string
+	SettingsRefresh time.Duration
+}
+
+// RouteSimplificationOverride sets the simplification of the trips whose
+// currentRouteId matches Pattern, a glob such as "circulator-*". A zero
+// Tolerance or empty Algorithm keeps the default one.
+type RouteSimplificationOverride struct {
+	Pattern   string  `bson:"_id" json:"pattern"`
+	Tolerance float64 `bson:"tolerance,omitempty" json:"tolerance,omitempty"`
+	Algorithm string  `bson:"algorithm,omitempty" json:"algorithm,omitempty"`
+}
+
+// SimplificationSettings is a runtime override of the route simplification
+// configuration
+type SimplificationSettings struct {
+	Tolerance float64   `bson:"tolerance" json:"tolerance"`
+	Algorithm string    `bson:"algorithm" json:"algorithm"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// RuntimeSettings are settings shared by every instance through Redis and
+// applied without a restart. Zero values keep the configured setting, and
+// messages on topics matching a PausedTopics filter are dropped.
+type RuntimeSettings struct {
+	Tolerance      float64   `json:"tolerance,omitempty"`
+	Algorithm      string    `json:"algorithm,omitempty"`
+	RateLimitRate  float64   `json:"rateLimitRate,omitempty"`
+	RateLimitBurst int       `json:"rateLimitBurst,omitempty"`
+	PausedTopics   []string  `json:"pausedTopics,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// AuditEntry records an administrative action, who performed it, and the
+// values it changed
+type AuditEntry struct {
+	Action    string      `bson:"action" json:"action"`
+	Actor     string      `bson:"actor" json:"actor"`
+	Target    string      `bson:"target,omitempty" json:"target,omitempty"`
+	OldValue  interface{} `bson:"oldValue,omitempty" json:"oldValue,omitempty"`
+	NewValue  interface{} `bson:"newValue,omitempty" json:"newValue,omitempty"`
+	Timestamp time.Time   `bson:"timestamp" json:"timestamp"`
+}
+
+// AuditQuery selects audit entries, newest first. Empty filters match every
+// entry, and From/To bound the entry time in milliseconds ([From, To)).
+type AuditQuery struct {
+	Action string
+	Actor  string
+	Target string
+	From   int64
+	To     int64
+	Limit  int
+}
+
+// AuditLogPage is a page of audit entries, newest first
+type AuditLogPage struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// ShiftTrip is a trip counted in a driver's shift
+type ShiftTrip struct {
+	TripID         string    `bson:"tripId" json:"tripId"`
+	RouteID        string    `bson:"routeId" json:"routeId"`
+	StartedAt      time.Time `bson:"startedAt" json:"startedAt"`
+	EndedAt        time.Time `bson:"endedAt" json:"endedAt"`
+	DistanceMeters float64   `bson:"distanceMeters" json:"distanceMeters"`
+	DrivingSeconds float64   `bson:"drivingSeconds" json:"drivingSeconds"`
+}
+
+// ShiftBreak is a pause between two trips of a shift
+type ShiftBreak struct {
+	Start           time.Time `bson:"start" json:"start"`
+	End             time.Time `bson:"end" json:"end"`
+	DurationSeconds float64   `bson:"durationSeconds" json:"durationSeconds"`
+}
+
+// Shift is a driver's working period and the trips driven in it. Shifts are
+// opened and closed by shift_start and shift_end messages; trips outside
+// them are grouped into an inferred shift per driver and Day, spanning its
+// first to its last trip. EndedAt is nil while a shift is open, and Breaks
+// are derived from the trips when the shift is read.
+type Shift struct {
+	ID             string       `bson:"_id" json:"id"`
+	DriverID       string       `bson:"driverId" json:"driverId"`
+	Inferred       bool         `bson:"inferred" json:"inferred"`
+	Day            string       `bson:"day,omitempty" json:"day,omitempty"`
+	StartedAt      time.Time    `bson:"startedAt" json:"startedAt"`
+	EndedAt        *time.Time   `bson:"endedAt,omitempty" json:"endedAt,omitempty"`
+	Trips          []ShiftTrip  `bson:"trips" json:"trips"`
+	TripCount      int          `bson:"tripCount" json:"tripCount"`
+	DistanceMeters float64      `bson:"distanceMeters" json:"distanceMeters"`
+	DrivingSeconds float64      `bson:"drivingSeconds" json:"drivingSeconds"`
+	Breaks         []ShiftBreak `bson:"breaks,omitempty" json:"breaks"`
+	BreakSeconds   float64      `bson:"breakSeconds,omitempty" json:"breakSeconds"`
+}
+
+// ShiftQuery selects a driver's shifts, newest first. From/To bound the
+// shift start in milliseconds ([From, To)).
+type ShiftQuery struct {
+	DriverID string
+	From     int64
+	To       int64
+	Limit    int
+}
+
+// ShiftPage is a page of a driver's shifts, newest first
+type ShiftPage struct {
+	DriverID string  `json:"driverId"`
+	Shifts   []Shift `json:"shifts"`
+}
+
+// VehicleAssignment is a period in which a driver drove a vehicle, from the
+// first message they sent with it until they sent one with another vehicle
+// or another driver took it. EndedAt is nil while the assignment lasts.
+type VehicleAssignment struct {
+	ID        string     `bson:"_id" json:"id"`
+	DriverID  string     `bson:"driverId" json:"driverId"`
+	VehicleID string     `bson:"vehicleId" json:"vehicleId"`
+	StartedAt time.Time  `bson:"startedAt" json:"startedAt"`
+	EndedAt   *time.Time `bson:"endedAt,omitempty" json:"endedAt,omitempty"`
+}
+
+// AssignmentQuery selects the assignments of a driver, a vehicle, or both.
+// From/To bound the assignment start in milliseconds ([From, To)), and zero
+// bounds are unbounded.
+type AssignmentQuery struct {
+	DriverID  string
+	VehicleID string
+	From      int64
+	To        int64
+	Limit     int
+}
+
+// AssignmentPage is a page of vehicle assignments, newest first
+type AssignmentPage struct {
+	Assignments []VehicleAssignment `json:"assignments"`
+}
+
+// DailyDistance is the distance a driver or a vehicle covered on a calendar
+// day (YYYY-MM-DD). Exactly one of DriverID and VehicleID is set.
+type DailyDistance struct {
+	ID             string    `bson:"_id" json:"-"`
+	DriverID       string    `bson:"driverId,omitempty" json:"driverId,omitempty"`
+	VehicleID      string    `bson:"vehicleId,omitempty" json:"vehicleId,omitempty"`
+	Day            string    `bson:"day" json:"day"`
+	DistanceMeters float64   `bson:"distanceMeters" json:"distanceMeters"`
+	UpdatedAt      time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// OdometerQuery selects the daily distances of a driver or a vehicle between
+// the From and To days (YYYY-MM-DD, both inclusive). Empty bounds are
+// unbounded.
+type OdometerQuery struct {
+	DriverID  string
+	VehicleID string
+	From      string
+	To        string
+}
+
+// OdometerReport is the daily distances of a driver or a vehicle, oldest
+// first, and their total
+type OdometerReport struct {
+	DriverID       string          `json:"driverId,omitempty"`
+	VehicleID      string          `json:"vehicleId,omitempty"`
+	Days           []DailyDistance `json:"days"`
+	DistanceMeters float64         `json:"distanceMeters"`
+}
+
+// Periods heatmap cells are counted over. Weeks start on Monday.
+const (
+	HeatmapPeriodDay  = "day"
+	HeatmapPeriodWeek = "week"
+)
+
+// HeatmapCell counts the trip points that fell in a geohash cell during the
+// day or week starting on Start (YYYY-MM-DD)
+type HeatmapCell struct {
+	ID        string    `bson:"_id" json:"-"`
+	Period    string    `bson:"period" json:"-"`
+	Start     string    `bson:"start" json:"-"`
+	Geohash   string    `bson:"geohash" json:"geohash"`
+	Latitude  float64   `bson:"-" json:"latitude"`
+	Longitude float64   `bson:"-" json:"longitude"`
+	Points    int64     `bson:"points" json:"points"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// HeatmapQuery selects the heatmap of the day or week containing Date
+// (YYYY-MM-DD), optionally within a bounding box and merged into coarser
+// cells of Precision characters
+type HeatmapQuery struct {
+	Period      string
+	Date        string
+	BoundingBox *BoundingBox
+	Precision   int
+}
+
+// HeatmapReport is the heatmap of a day or week with the largest cell count
+// to scale the colors by
+type HeatmapReport struct {
+	Period    string        `json:"period"`
+	Start     string        `json:"start"`
+	Precision int           `json:"precision"`
+	Cells     []HeatmapCell `json:"cells"`
+	MaxPoints int64         `json:"maxPoints"`
+}
+
+// DriverDeletionReport counts what was removed when a driver's data was
+// deleted
+type DriverDeletionReport struct {
+	DriverID       string    `bson:"driverId" json:"driverId"`
+	Trips          int64     `bson:"trips" json:"trips"`
+	RawRoutes      int64     `bson:"rawRoutes" json:"rawRoutes"`
+	Shifts         int64     `bson:"shifts" json:"shifts"`
+	Assignments    int64     `bson:"assignments" json:"assignments"`
+	DailyDistances int64     `bson:"dailyDistances" json:"dailyDistances"`
+	ArchivedTraces int64     `bson:"archivedTraces" json:"archivedTraces"`
+	RouteBuffers   int64     `bson:"routeBuffers" json:"routeBuffers"`
+	VehiclePoints  int64     `bson:"vehiclePoints" json:"vehiclePoints"`
+	LivePositions  int64     `bson:"livePositions" json:"livePositions"`
+	ETAs           int64     `bson:"etas" json:"etas"`
+	DeletedAt      time.Time `bson:"deletedAt" json:"deletedAt"`
+}
+
+// Add accumulates the counts of another report
+func (r *DriverDeletionReport) Add(other DriverDeletionReport) {
+	r.Trips += other.Trips
+	r.RawRoutes += other.RawRoutes
+	r.Shifts += other.Shifts
+	r.Assignments += other.Assignments
+	r.DailyDistances += other.DailyDistances
+	r.ArchivedTraces += other.ArchivedTraces
+	r.RouteBuffers += other.RouteBuffers
+	r.VehiclePoints += other.VehiclePoints
+	r.LivePositions += other.LivePositions
+	r.ETAs += other.ETAs
+}
+
+// RouteDeviationConfig holds route deviation detection parameters
+type RouteDeviationConfig struct {
+	Enabled           bool
+	Collection        string
+	ThresholdMeters   float64
+	ConsecutivePoints int
+	EventTopic        string
+}
+
+// AdherenceConfig holds the route adherence scoring parameters. A point is
+// on route within OnRouteMeters of the planned path, and reaches a stop
+// within StopRadiusMeters of it. A stop is served on time from EarlyTolerance
+// before to LateTolerance after its scheduled time.
+type AdherenceConfig struct {
+	Enabled          bool
+	OnRouteMeters    float64
+	StopRadiusMeters float64
+	EarlyTolerance   time.Duration
+	LateTolerance    time.Duration
+}
+
+// PassengerCountConfig holds passenger counting parameters. Counts without a
+// stop are attributed to the nearest planned stop within StopRadiusMeters.
+type PassengerCountConfig struct {
+	Enabled          bool
+	StopRadiusMeters float64
+}
+
+// HarshDrivingConfig holds harsh driving detection thresholds in m/s².
+// Accelerations derived from GPS are only computed between samples at most
+// MaxSampleGap apart.
+type HarshDrivingConfig struct {
+	Enabled          bool
+	BrakingMps2      float64
+	AccelerationMps2 float64
+	CorneringMps2    float64
+	MaxSampleGap     time.Duration
+}
+
+// IdleConfig holds idle analysis parameters. A vehicle idles when it stays
+// below the trip stats idle speed for MinDuration, unless it waits within
+// TerminalRadiusMeters of the first or last stop of its planned route or
+// inside one of the TerminalGeofences.
+type IdleConfig struct {
+	Enabled              bool
+	MinDuration          time.Duration
+	TerminalRadiusMeters float64
+	TerminalGeofences    []string
+}
+
+// ZoneTimeConfig holds zone time accounting parameters. Zones are the IDs
+// of the geofences accounted for, every geofence when empty. Intervals
+// between points longer than MaxGap are not counted.
+type ZoneTimeConfig struct {
+	Enabled bool
+	Zones   []string
+	MaxGap  time.Duration
+}
+
+// QualityConfig holds trip quality scoring parameters. Points reached and
+// left faster than MaxSpeedKmh are removed as outliers, intervals longer
+// than GapThreshold are gaps, and readings above LowAccuracyMeters are
+// inaccurate.
+type QualityConfig struct {
+	Enabled           bool
+	MaxSpeedKmh       float64
+	GapThreshold      time.Duration
+	LowAccuracyMeters float64
+}
+
+// ETAConfig holds the stop arrival estimation parameters. The speed of a
+// vehicle is its progress along the planned route over SpeedWindow, or
+// DefaultSpeedKmh until it has moved for that long, and never below
+// MinSpeedKmh. Stored ETAs expire after TTL without updates.
+type ETAConfig struct {
+	Enabled         bool
+	SpeedWindow     time.Duration
+	DefaultSpeedKmh float64
+	MinSpeedKmh     float64
+	TTL             time.Duration
+}
+
+// GeofenceConfig holds geofencing parameters. Geofences are reloaded from
+// the collection every Refresh.
+type GeofenceConfig struct {
+	Enabled    bool
+	Collection string
+	Refresh    time.Duration
+	EventTopic string
+}
+
+// SignatureConfig holds device message signature verification parameters.
+// Device keys are loaded from Collection and reloaded every Refresh.
+// Messages of devices with a key must be signed, and with Required so must
+// those of devices without one. Signed messages timestamped more than
+// Window away from the time they are received are rejected, so that a
+// captured message cannot be replayed later.
+type SignatureConfig struct {
+	Enabled    bool
+	Required   bool
+	Collection string
+	Refresh    time.Duration
+	Window     time.Duration
+}
+
+// SpeedingConfig holds speeding detection parameters. A vehicle is speeding
+// when it drives more than ToleranceKmh above the limit of the road it is on,
+// or DefaultLimitKmh away from the roads of RoadLimitsFile, and a violation
+// is reported once it lasts MinDuration.
+type SpeedingConfig struct {
+	Enabled         bool
+	DefaultLimitKmh float64
+	ToleranceKmh    float64
+	MinDuration     time.Duration
+	RoadLimitsFile  string
+	RoadSnapMeters  float64
+	EventTopic      string
+}
+
+// StopEventsConfig holds stop arrival and departure detection parameters.
+// A vehicle arrives at a stop of its planned route once it has stayed within
+// RadiusMeters of it for MinDwell, and departs when it leaves the radius.
+type StopEventsConfig struct {
+	Enabled      bool
+	RadiusMeters float64
+	MinDwell     time.Duration
+	EventTopic   string
+}
+
+// HeadwayConfig holds bunching and gap detection parameters. The headway of
+// a vehicle is how long ago the vehicle ahead of it on its planned route was
+// where it is; vehicles closer than BunchingThreshold are bunched, and
+// vehicles further behind than GapThreshold leave a gap.
+type HeadwayConfig struct {
+	Enabled           bool
+	BunchingThreshold time.Duration
+	GapThreshold      time.Duration
+	EventTopic        string
+}
+
+// ShiftConfig holds driver shift tracking parameters. Inferred shifts group
+// the trips of a calendar day in Timezone, and pauses between trips of at
+// least MinBreak are reported as breaks.
+type ShiftConfig struct {
+	Enabled    bool
+	Collection string
+	Timezone   string
+	MinBreak   time.Duration
+}
+
+// OdometerConfig holds the daily distance counter parameters. Counters add
+// up the distance between consecutive points of a route on the calendar day
+// of Timezone, skipping steps faster than MaxSpeedKmh as GPS jumps. Redis
+// keeps a day's counters for CounterTTL, and they are rolled up into the
+// Collection summaries every RollupInterval.
+type OdometerConfig struct {
+	Enabled        bool
+	Collection     string
+	Timezone       string
+	MaxSpeedKmh    float64
+	CounterTTL     time.Duration
+	RollupInterval time.Duration
+}
+
+// HeatmapConfig holds the heatmap aggregation parameters. Every Interval the
+// points of the trips that ended in the last LookbackDays days, in Timezone,
+// are counted in geohash cells of Precision characters and stored in
+// Collection per day and week.
+type HeatmapConfig struct {
+	Enabled      bool
+	Collection   string
+	Precision    int
+	Timezone     string
+	Interval     time.Duration
+	LookbackDays int
+}
+
+// TripStatsConfig holds parameters used when computing trip summary statistics
+type TripStatsConfig struct {
+	IdleSpeedKmh   float64
+	MinStopSeconds float64
+}
+
+// IngestConfig holds the MQTT message worker pool settings. Overflow picks
+// what happens when the queue is full: block, drop_oldest, or spill to the
+// BoltDB file at SpillPath, holding up to SpillMaxMessages messages.
+type IngestConfig struct {
+	Workers          int
+	QueueSize        int
+	Overflow         string
+	SpillPath        string
+	SpillMaxMessages int
+}
+
+// DedupConfig controls duplicate message detection. The hashes of the last
+// History messages of every driver are kept for TTL; a message matching one
+// of them, or timestamped more than Window before the driver's newest
+// message, is dropped.
+type DedupConfig struct {
+	Enabled bool
+	History int
+	Window  time.Duration
+	TTL     time.Duration
+}
+
+// RateLimitConfig controls the per-driver rate limit of in-route locations.
+// Each driver may send Rate locations per second with bursts of up to Burst;
+// the Policy either throttles the excess or samples one of every SampleEvery
+// excess locations. A driver is alerted about at most once per AlertInterval.
+type RateLimitConfig struct {
+	Enabled       bool
+	Rate          float64
+	Burst         int
+	Policy        string
+	SampleEvery   int
+	AlertInterval time.Duration
+}
+
+// PartitionConfig controls splitting the drivers between service instances.
+// Every instance heartbeats in Redis every HeartbeatInterval and leaves the
+// cluster when it misses heartbeats for MemberTTL. Drivers are placed on a
+// consistent-hash ring with VirtualNodes points per instance, and the owner
+// of a driver holds a lease on it for LeaseTTL after its last message.
+type PartitionConfig struct {
+	Enabled           bool
+	HeartbeatInterval time.Duration
+	MemberTTL         time.Duration
+	LeaseTTL          time.Duration
+	VirtualNodes      int
+}
+
+// InstanceStatus is what an instance reports about itself to the cluster.
+// RingShare is the fraction of the drivers the hash ring assigns to it, and
+// Jobs lists the cluster-wide jobs it runs as the leader.
+type InstanceStatus struct {
+	InstanceID        string    `json:"instanceId"`
+	StartedAt         time.Time `json:"startedAt"`
+	LastHeartbeat     time.Time `json:"lastHeartbeat"`
+	Leader            bool      `json:"leader"`
+	Jobs              []string  `json:"jobs"`
+	RingShare         float64   `json:"ringShare"`
+	OwnedDrivers      int       `json:"ownedDrivers"`
+	MessagesTotal     int64     `json:"messagesTotal"`
+	MessagesPerSecond float64   `json:"messagesPerSecond"`
+}
+
+// ClusterStatus is the view of the cluster returned by the admin API
+type ClusterStatus struct {
+	Instance     string           `json:"instance"`
+	Partitioning bool             `json:"partitioning"`
+	Leader       string           `json:"leader,omitempty"`
+	Instances    []InstanceStatus `json:"instances"`
+}
+
+// StandbyConfig controls active-passive failover. Instances compete for an
+// active lease in Redis, renewed every HeartbeatInterval; the others stay
+// connected but unsubscribed, and one of them takes over once the active
+// instance has not renewed its lease for TakeoverAfter.
+type StandbyConfig struct {
+	Enabled           bool
+	HeartbeatInterval time.Duration
+	TakeoverAfter     time.Duration
+}
+
+// JanitorConfig controls closing abandoned routes. Every Interval, routes
+// whose last point is older than IdleAfter are finalized as auto-closed
+// trips.
+type JanitorConfig struct {
+	Enabled   bool
+	IdleAfter time.Duration
+	Interval  time.Duration
+}
+
+// SegmentationConfig controls splitting routes whose device never sends
+// "finished". A live point arriving more than MaxGap after the previous one,
+// or more than MaxJumpKm away from it, closes the trip so far and starts a
+// new one. Zero disables either check.
+type SegmentationConfig struct {
+	MaxGap    time.Duration
+	MaxJumpKm float64
+}
+
+// ShutdownConfig bounds graceful shutdown. Queued messages and trip
+// finalizations get up to DrainTimeout to finish before connections close.
+type ShutdownConfig struct {
+	DrainTimeout time.Duration
+}
+
+// FinalizationConfig holds the trip finalization worker pool settings
+type FinalizationConfig struct {
+	Workers   int
+	QueueSize int
+	// Checkpoints records finalizations in progress in Redis; on startup the
+	// ones left by this instance, or by any instance for longer than
+	// ResumeAfter, are resumed
+	Checkpoints bool
+	ResumeAfter time.Duration
+}
+
+// FinalizationCheckpoint marks a finalization in progress, so one
+// interrupted by a crash can be resumed after a restart
+type FinalizationCheckpoint struct {
+	Key       string     `json:"key"`
+	TripID    string     `json:"tripId"`
+	Instance  string     `json:"instance"`
+	StartedAt time.Time  `json:"startedAt"`
+	Message   BusMessage `json:"message"`
+}
+
+// FailureConfig controls the sampling of processing failures kept for the
+// admin API
+type FailureConfig struct {
+	SampleSize int
+	SampleRate float64
+}
+
+// RetryConfig controls retries of transient Redis and MongoDB failures.
+// Operations are tried up to Attempts times, waiting InitialBackoff doubled
+// per attempt up to MaxBackoff, moved randomly by up to the Jitter fraction.
+type RetryConfig struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+}
+
+// StartupConfig controls waiting for the backends at startup. Redis,
+// MongoDB, and the MQTT broker are each retried with their own policy. With
+// Degraded, the service starts even when Redis or MongoDB are still down
+// after their retries, and connects to them in the background.
+type StartupConfig struct {
+	Redis    RetryConfig
+	MongoDB  RetryConfig
+	MQTT     RetryConfig
+	Degraded bool
+}
+
+// SecretsConfig controls where the Redis, MongoDB, and MQTT credentials are
+// read from: the environment, a directory of files, a Vault KV secret, or an
+// AWS Secrets Manager secret. Unless read from the environment, they are
+// fetched again every RefreshInterval so rotated credentials are picked up.
+type SecretsConfig struct {
+	Provider           string
+	Dir                string
+	VaultAddress       string
+	VaultToken         string
+	VaultMount         string
+	VaultPath          string
+	AWSRegion          string
+	AWSSecretID        string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	RefreshInterval    time.Duration
+}
+
+// CircuitBreakerConfig controls the Redis and MongoDB circuit breakers. A
+// breaker opens after FailureThreshold consecutive failed calls, or never
+// if it is zero, and probes the dependency again after OpenTimeout.
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// RawRouteConfig controls persistence of unsimplified routes
+type RawRouteConfig struct {
+	Enabled    bool
+	Collection string
+}
+
+// TimescaleConfig holds the TimescaleDB raw point sink configuration
+type TimescaleConfig struct {
+	Enabled bool
+	URL     string
+	Table   string
+}
+
+// ClickHouseConfig holds the ClickHouse raw point sink configuration
+type ClickHouseConfig struct {
+	Enabled       bool
+	URL           string
+	Database      string
+	Table         string
+	Username      string
+	Password      string
+	BatchSize     int
+	BatchInterval time.Duration
+}
+
+// OpenSearchConfig holds the OpenSearch trip summary sink configuration
+type OpenSearchConfig struct {
+	Enabled  bool
+	URL      string
+	Index    string
+	Username string
+	Password string
+}
+
+// KafkaConfig holds the Kafka finalized trip sink configuration
+type KafkaConfig struct {
+	Enabled      bool
+	Brokers      []string
+	Topic        string
+	BatchTimeout time.Duration
+}
+
+// ArchiveConfig holds the S3-compatible raw trace archive configuration
+type ArchiveConfig struct {
+	Enabled   bool
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Tenant    string
+}
+
+// HTTPConfig holds the HTTP API server configuration
+type HTTPConfig struct {
+	Enabled            bool
+	Address            string
+	AdminToken         string
+	DefaultPageSize    int
+	MaxPageSize        int
+	AllowedOrigins     []string
+	StreamBufferSize   int
+	StreamWriteTimeout time.Duration
+	EventsInterval     time.Duration
+	// GraphQLMaxDepth and GraphQLMaxComplexity reject expensive queries
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+	// DebugEnabled serves pprof profiles and expvar metrics to admins
+	DebugEnabled bool
+}
+
+// GRPCConfig holds the gRPC query API configuration
+type GRPCConfig struct {
+	Enabled         bool
+	Address         string
+	DefaultPageSize int
+	MaxPageSize     int
+	HealthInterval  time.Duration
+	Reflection      bool
+}
+
+// AuthConfig holds the JWT authentication parameters of the HTTP and gRPC
+// APIs. Tokens must be signed by a key of the JWKS at JWKSURL, which is
+// refetched every JWKSRefresh, and carry the Issuer and Audience when set.
+// ClockSkew is tolerated on the exp and nbf claims.
+type AuthConfig struct {
+	Enabled     bool
+	JWKSURL     string
+	Issuer      string
+	Audience    string
+	JWKSRefresh time.Duration
+	ClockSkew   time.Duration
+}
+
+// LogConfig holds the structured logger configuration. Messages and
+// finalizations taking longer than SlowThreshold are logged with a
+// per-stage breakdown; zero disables the warning.
+type LogConfig struct {
+	Level         string
+	Format        string
+	Source        bool
+	SlowThreshold time.Duration
+}
+
+// ErrorReportingConfig holds the error tracker configuration. Errors of a
+// failure class are reported once they repeat RepeatThreshold times within
+// RepeatWindow, and at most once per window.
+type ErrorReportingConfig struct {
+	DSN             string
+	Environment     string
+	Release         string
+	SampleRate      float64
+	RepeatThreshold int
+	RepeatWindow    time.Duration
+}
+
+// FeatureFlagConfig holds the feature flags of this instance, written as
+// [tenant:]flag=bool, and how often the ones stored in Redis are reloaded.
+// Flags scoped to Tenant take precedence over unscoped ones.
+type FeatureFlagConfig struct {
+	Flags   []string
+	Tenant  string
+	Refresh time.Duration
+}
+
+// RuntimeSettingsConfig controls the settings shared through Redis. Changes
+// are announced on a channel, and reloaded every Refresh in case an
+// announcement is missed.
+type RuntimeSettingsConfig struct {
+	Enabled bool
+	Refresh time.Duration
+}
+
+// TracingConfig holds the OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Enabled     bool
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
+}
+
+// PublicFeedConfig holds the public MQTT position feed configuration
+type PublicFeedConfig struct {
+	Enabled     bool
+	TopicPrefix string
+	Interval    time.Duration
+	Precision   int
+	StaleAfter  time.Duration
+	Salt        string
+}
+
+// WebhookConfig holds the webhook dispatcher configuration. Webhooks are
+// disabled when no URLs are configured.
+type WebhookConfig struct {
+	URLs           []string
+	Secret         string
+	Events         []string
+	Timeout        time.Duration
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Workers        int
+	QueueSize      int
+	OfflineAfter   time.Duration
+}
+
+// PlannedRoute represents the planned geometry of a route stored in
+// MongoDB, with its stops in the order they are served
+type PlannedRoute struct {
+	RouteID string        `bson:"routeId" json:"routeId"`
+	Path    []Location    `bson:"path" json:"path"`
+	Stops   []PlannedStop `bson:"stops,omitempty" json:"stops,omitempty"`
+}
+
+// PlannedStop is a stop served along a planned route. ScheduledOffset is
+// its timetable, in seconds after the start of the trip, when loaded.
+type PlannedStop struct {
+	ID              string   `bson:"id" json:"id"`
+	Name            string   `bson:"name,omitempty" json:"name,omitempty"`
+	Location        Location `bson:"location" json:"location"`
+	ScheduledOffset *float64 `bson:"scheduledOffsetSeconds,omitempty" json:"scheduledOffsetSeconds,omitempty"`
+}
+
+// StopETA is the estimated arrival of a vehicle at an upcoming stop.
+// ArrivalAt is in milliseconds.
+type StopETA struct {
+	StopID         string   `json:"stopId"`
+	Name           string   `json:"name,omitempty"`
+	Location       Location `json:"location"`
+	DistanceMeters float64  `json:"distanceMeters"`
+	ETASeconds     float64  `json:"etaSeconds"`
+	ArrivalAt      int64    `json:"arrivalAt"`
+}
+
+// VehicleETA holds the ETAs of a vehicle at the stops ahead of it on its
+// route, estimated from its location at Timestamp and its recent speed
+type VehicleETA struct {
+	DriverID       string    `json:"driverId"`
+	CurrentRouteID string    `json:"currentRouteId"`
+	SpeedKmh       float64   `json:"speedKmh"`
+	Timestamp      uint64    `json:"timestamp"`
+	UpdatedAt      int64     `json:"updatedAt"`
+	Stops          []StopETA `json:"stops"`
+}
+
+// Headway states of a vehicle relative to the vehicle ahead of it
+const (
+	HeadwayLeading = "leading"
+	HeadwayUnknown = "unknown"
+	HeadwayNormal  = "normal"
+	HeadwayBunched = "bunched"
+	HeadwayGap     = "gap"
+)
+
+// VehicleHeadway is the live headway of a vehicle behind the vehicle ahead of
+// it on its route. The leading vehicle has no headway, and neither does a
+// vehicle whose leader was not followed long enough to know it.
+type VehicleHeadway struct {
+	DriverID       string   `json:"driverId"`
+	VehicleID      string   `json:"vehicleId,omitempty"`
+	CurrentRouteID string   `json:"currentRouteId"`
+	ProgressMeters float64  `json:"progressMeters"`
+	LeaderDriverID string   `json:"leaderDriverId,omitempty"`
+	DistanceMeters float64  `json:"distanceMeters,omitempty"`
+	HeadwaySeconds *float64 `json:"headwaySeconds,omitempty"`
+	Status         string   `json:"status"`
+	Timestamp      uint64   `json:"timestamp"`
+}
+
+// DeviationEvent is published when a driver leaves its planned route
+type DeviationEvent struct {
+	Type              string   `json:"type"`
+	DriverID          string   `json:"driverId"`
+	CurrentRouteID    string   `json:"currentRouteId"`
+	Location          Location `json:"location"`
+	DistanceMeters    float64  `json:"distanceMeters"`
+	ConsecutivePoints int      `json:"consecutivePoints"`
+	Timestamp         uint64   `json:"timestamp"`
+}
+
+// Geofence is a zone whose entries and exits are reported: a polygon, or a
+// circle of RadiusMeters around Center
+type Geofence struct {
+	ID           string     `bson:"_id" json:"id"`
+	Name         string     `bson:"name,omitempty" json:"name,omitempty"`
+	Polygon      []Location `bson:"polygon,omitempty" json:"polygon,omitempty"`
+	Center       *Location  `bson:"center,omitempty" json:"center,omitempty"`
+	RadiusMeters float64    `bson:"radiusMeters,omitempty" json:"radiusMeters,omitempty"`
+}
+
+// DeviceKey is the HMAC key a device signs its messages with. Devices are
+// identified by the driver ID they report.
+type DeviceKey struct {
+	DeviceID string `bson:"_id" json:"id"`
+	Key      string `bson:"key" json:"key"`
+}
+
+// GeofenceEvent is emitted when a driver enters or exits a geofence:
+// geofence_entered or geofence_exited
+type GeofenceEvent struct {
+	Type           string   `json:"type"`
+	GeofenceID     string   `json:"geofenceId"`
+	GeofenceName   string   `json:"geofenceName,omitempty"`
+	DriverID       string   `json:"driverId"`
+	CurrentRouteID string   `json:"currentRouteId"`
+	Location       Location `json:"location"`
+	Timestamp      uint64   `json:"timestamp"`
+}
+
+// SpeedingEvent is emitted once a driver has been speeding for the minimum
+// duration, with the violation so far
+type SpeedingEvent struct {
+	Type           string            `json:"type"`
+	DriverID       string            `json:"driverId"`
+	CurrentRouteID string            `json:"currentRouteId"`
+	Location       Location          `json:"location"`
+	Timestamp      uint64            `json:"timestamp"`
+	Violation      SpeedingViolation `json:"violation"`
+}
+
+// StopEvent is published when a vehicle arrives at or departs from a stop
+// of its planned route
+type StopEvent struct {
+	Type           string    `json:"type"`
+	DriverID       string    `json:"driverId"`
+	VehicleID      string    `json:"vehicleId,omitempty"`
+	CurrentRouteID string    `json:"currentRouteId"`
+	Timestamp      uint64    `json:"timestamp"`
+	Visit          StopVisit `json:"visit"`
+}
+
+// HeadwayEvent is published when a vehicle gets bunched with the vehicle
+// ahead of it, or falls far enough behind it to leave a gap
+type HeadwayEvent struct {
+	Type             string   `json:"type"`
+	DriverID         string   `json:"driverId"`
+	VehicleID        string   `json:"vehicleId,omitempty"`
+	CurrentRouteID   string   `json:"currentRouteId"`
+	Location         Location `json:"location"`
+	Timestamp        uint64   `json:"timestamp"`
+	LeaderDriverID   string   `json:"leaderDriverId"`
+	DistanceMeters   float64  `json:"distanceMeters"`
+	HeadwaySeconds   float64  `json:"headwaySeconds"`
+	ThresholdSeconds float64  `json:"thresholdSeconds"`
+}
+
+// DeviceOfflineEvent is emitted when a driver on a route stops sending
+// locations without finishing it
+type DeviceOfflineEvent struct {
+	Type           string   `json:"type"`
+	DriverID       string   `json:"driverId"`
+	CurrentRouteID string   `json:"currentRouteId"`
+	Location       Location `json:"location"`
+	Timestamp      uint64   `json:"timestamp"`
+	SilentSeconds  float64  `json:"silentSeconds"`
+}
+
+// DeviceRateLimitedEvent is emitted when a driver sends locations faster
+// than its rate limit, with the number of locations limited since the
+// previous event
+type DeviceRateLimitedEvent struct {
+	Type           string  `json:"type"`
+	DriverID       string  `json:"driverId"`
+	CurrentRouteID string  `json:"currentRouteId"`
+	Timestamp      uint64  `json:"timestamp"`
+	RatePerSecond  float64 `json:"ratePerSecond"`
+	Policy         string  `json:"policy"`
+	Limited        int64   `json:"limited"`
+}
+
+// WebhookEvent is the body of a webhook delivery
+type WebhookEvent struct {
+	ID        string      `bson:"id" json:"id"`
+	Type      string      `bson:"type" json:"type"`
+	CreatedAt time.Time   `bson:"createdAt" json:"createdAt"`
+	Data      interface{} `bson:"data" json:"data"`
+}
+
+// WebhookDeadLetter is a webhook delivery that failed every attempt
+type WebhookDeadLetter struct {
+	Event     WebhookEvent `bson:"event" json:"event"`
+	URL       string       `bson:"url" json:"url"`
+	Attempts  int          `bson:"attempts" json:"attempts"`
+	LastError string       `bson:"lastError" json:"lastError"`
+	FailedAt  time.Time    `bson:"failedAt" json:"failedAt"`
+}
+
+// PublicVehicle is a vehicle position without driver details, published to
+// public topics
+type PublicVehicle struct {
+	VehicleID string   `json:"vehicleId"`
+	Location  Location `json:"location"`
+	Timestamp uint64   `json:"timestamp"`
+}
+
+// PublicRouteUpdate lists the latest positions of the vehicles on a route
+type PublicRouteUpdate struct {
+	RouteID     string          `json:"routeId"`
+	Vehicles    []PublicVehicle `json:"vehicles"`
+	PublishedAt int64           `json:"publishedAt"`
+}
+
+// FleetSnapshot is the latest state of every active driver
+type FleetSnapshot struct {
+	Vehicles    []LivePosition `json:"vehicles"`
+	Total       int            `json:"total"`
+	Stale       int            `json:"stale"`
+	GeneratedAt int64          `json:"generatedAt"`
+}
+
+// ReplayPoint is one raw point of a trip replay
+type ReplayPoint struct {
+	Index     int      `json:"index"`
+	Total     int      `json:"total"`
+	Location  Location `json:"location"`
+	Timestamp uint64   `json:"timestamp,omitempty"`
+	Telemetry
+}
+
+// ReplayEnd closes a trip replay
+type ReplayEnd struct {
+	TripID string `json:"tripId"`
+	Points int    `json:"points"`
+}
+
+// LivePosition is a driver's latest known position and state
+type LivePosition struct {
+	DriverID       string     `json:"driverId"`
+	VehicleID      string     `json:"vehicleId,omitempty"`
+	CurrentRouteID string     `json:"currentRouteId"`
+	Status         string     `json:"status"`
+	Location       Location   `json:"location"`
+	Heading        float64    `json:"heading"`
+	Timestamp      uint64     `json:"timestamp"`
+	UpdatedAt      int64      `json:"updatedAt"`
+	Stale          bool       `json:"stale"`
+	DistanceMeters float64    `json:"distanceMeters,omitempty"`
+	Bearing        *float64   `json:"bearing,omitempty"`   // from the searched location, in degrees
+	Telemetry      *Telemetry `json:"telemetry,omitempty"` // sent with the latest position
+}
+
+// ProcessingFailure is a sampled message or finalization that failed
+type ProcessingFailure struct {
+	Time           time.Time `json:"time"`
+	Class          string    `json:"class"`
+	DriverID       string    `json:"driverId,omitempty"`
+	CurrentRouteID string    `json:"currentRouteId,omitempty"`
+	Error          string    `json:"error"`
+	Payload        string    `json:"payload,omitempty"`
+}
+
+// FailureReport counts processing failures by class, with the most recent
+// sampled failures first
+type FailureReport struct {
+	Counts   map[string]int64    `json:"counts"`
+	Failures []ProcessingFailure `json:"failures"`
+}
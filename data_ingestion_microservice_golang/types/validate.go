@@ -0,0 +1,446 @@
+package types
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ConfigErrors lists every problem found in a configuration, each naming
+// the setting to fix
+type ConfigErrors []string
+
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return "invalid configuration: " + e[0]
+	}
+	return fmt.Sprintf("invalid configuration, %d problems: %s", len(e), strings.Join(e, "; "))
+}
+
+// configCheck collects the problems found while validating a configuration
+type configCheck struct {
+	problems ConfigErrors
+}
+
+func (c *configCheck) failf(format string, args ...any) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+func (c *configCheck) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		c.failf("%s is required", key)
+	}
+}
+
+func (c *configCheck) positive(key string, value float64) {
+	if value <= 0 {
+		c.failf("%s must be greater than 0, got %v", key, value)
+	}
+}
+
+func (c *configCheck) fraction(key string, value float64) {
+	if value < 0 || value > 1 {
+		c.failf("%s must be between 0 and 1, got %v", key, value)
+	}
+}
+
+func (c *configCheck) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		c.failf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+	}
+}
+
+func (c *configCheck) port(key string, value int) {
+	if value < 1 || value > 65535 {
+		c.failf("%s must be between 1 and 65535, got %d", key, value)
+	}
+}
+
+// url checks that value is an absolute URL with one of the schemes
+func (c *configCheck) url(key, value string, schemes ...string) {
+	parsed, err := url.Parse(value)
+	switch {
+	case err != nil:
+		c.failf("%s is not a valid URL: %v", key, err)
+	case !slices.Contains(schemes, parsed.Scheme) || parsed.Host == "":
+		c.failf("%s must be a %s URL with a host, got %q", key, strings.Join(schemes, " or "), value)
+	}
+}
+
+// Validate checks the configuration for values that did not parse,
+// out-of-range values, malformed URIs, missing required settings, and
+// options that cannot be combined, returning every problem at once as
+// ConfigErrors
+func (config Config) Validate() error {
+	c := &configCheck{problems: slices.Clone(config.Malformed)}
+	external := config.Storage.Mode == "external"
+
+	if config.Profile != "" {
+		c.oneOf("PROFILE", config.Profile, "dev", "staging", "prod")
+	}
+
+	c.oneOf("STORAGE_MODE", config.Storage.Mode, "external", "embedded")
+	if config.Storage.Mode == "embedded" {
+		c.required("EMBEDDED_DB_PATH", config.Storage.EmbeddedPath)
+	}
+	c.required("INSTANCE_ID", config.InstanceID)
+
+	c.required("MQTT_BROKER", config.MQTT.Broker)
+	c.port("MQTT_PORT", config.MQTT.Port)
+	c.required("MQTT_CLIENT_ID", config.MQTT.ClientID)
+	c.required("MQTT_TOPIC", config.MQTT.Topic)
+
+	if external {
+		if config.Redis.SentinelMasterName != "" {
+			if len(config.Redis.SentinelAddresses) == 0 {
+				c.failf("REDIS_SENTINEL_ADDRESSES is required with REDIS_SENTINEL_MASTER")
+			}
+		} else {
+			c.required("REDIS_ADDRESS", config.Redis.Address)
+		}
+		if config.Redis.DB < 0 {
+			c.failf("REDIS_DB must not be negative, got %d", config.Redis.DB)
+		}
+		c.positive("REDIS_POOL_SIZE", float64(config.Redis.PoolSize))
+
+		c.url("MONGODB_URI", config.MongoDB.URI, "mongodb", "mongodb+srv")
+		c.required("MONGODB_DATABASE", config.MongoDB.Database)
+		c.required("MONGODB_COLLECTION", config.MongoDB.Collection)
+		c.oneOf("MONGODB_READ_PREFERENCE", strings.ToLower(config.MongoDB.ReadPreference),
+			"primary", "primarypreferred", "secondary", "secondarypreferred", "nearest")
+	}
+
+	c.positive("ROUTE_TOLERANCE", config.RouteSimplification.Tolerance)
+	c.oneOf("ROUTE_ALGORITHM", config.RouteSimplification.Algorithm, SimplificationAlgorithms...)
+	if _, err := ParseRouteOverrides(config.RouteSimplification.Overrides); err != nil {
+		c.failf("ROUTE_TOLERANCE_OVERRIDES: %v", err)
+	}
+
+	if config.Geofence.Enabled {
+		c.required("GEOFENCE_TOPIC", config.Geofence.EventTopic)
+		if config.Geofence.Refresh < 0 {
+			c.failf("GEOFENCE_REFRESH_INTERVAL must not be negative, got %v", config.Geofence.Refresh)
+		}
+	}
+
+	if config.Signatures.Enabled {
+		c.required("DEVICE_REGISTRY_COLLECTION", config.Signatures.Collection)
+		if config.Signatures.Refresh < 0 {
+			c.failf("DEVICE_REGISTRY_REFRESH_INTERVAL must not be negative, got %v", config.Signatures.Refresh)
+		}
+		c.positive("SIGNATURE_WINDOW", config.Signatures.Window.Seconds())
+	}
+
+	if config.ETA.Enabled {
+		c.positive("ETA_SPEED_WINDOW", config.ETA.SpeedWindow.Seconds())
+		c.positive("ETA_DEFAULT_SPEED_KMH", config.ETA.DefaultSpeedKmh)
+		c.positive("ETA_MIN_SPEED_KMH", config.ETA.MinSpeedKmh)
+		c.positive("ETA_TTL", config.ETA.TTL.Seconds())
+	}
+
+	if config.StopEvents.Enabled {
+		c.positive("STOP_EVENTS_RADIUS_METERS", config.StopEvents.RadiusMeters)
+		c.required("STOP_EVENTS_TOPIC", config.StopEvents.EventTopic)
+		if config.StopEvents.MinDwell < 0 {
+			c.failf("STOP_EVENTS_MIN_DWELL must not be negative, got %v", config.StopEvents.MinDwell)
+		}
+	}
+
+	if config.Headway.Enabled {
+		c.positive("HEADWAY_BUNCHING_THRESHOLD", config.Headway.BunchingThreshold.Seconds())
+		c.required("HEADWAY_TOPIC", config.Headway.EventTopic)
+		if config.Headway.GapThreshold <= config.Headway.BunchingThreshold {
+			c.failf("HEADWAY_GAP_THRESHOLD must be above HEADWAY_BUNCHING_THRESHOLD, got %v and %v",
+				config.Headway.GapThreshold, config.Headway.BunchingThreshold)
+		}
+	}
+
+	if config.PassengerCounts.Enabled {
+		c.positive("PASSENGER_COUNTS_STOP_RADIUS_METERS", config.PassengerCounts.StopRadiusMeters)
+	}
+
+	if config.HarshDriving.Enabled {
+		c.positive("HARSH_BRAKING_MPS2", config.HarshDriving.BrakingMps2)
+		c.positive("HARSH_ACCELERATION_MPS2", config.HarshDriving.AccelerationMps2)
+		c.positive("HARSH_CORNERING_MPS2", config.HarshDriving.CorneringMps2)
+		c.positive("HARSH_MAX_SAMPLE_GAP", config.HarshDriving.MaxSampleGap.Seconds())
+	}
+
+	if config.Idle.Enabled {
+		c.positive("IDLE_MIN_DURATION", config.Idle.MinDuration.Seconds())
+		c.positive("IDLE_TERMINAL_RADIUS_METERS", config.Idle.TerminalRadiusMeters)
+		if len(config.Idle.TerminalGeofences) > 0 && !config.Geofence.Enabled {
+			c.failf("IDLE_TERMINAL_GEOFENCES requires GEOFENCE_ENABLED")
+		}
+	}
+
+	if config.Quality.Enabled {
+		c.positive("QUALITY_MAX_SPEED_KMH", config.Quality.MaxSpeedKmh)
+		c.positive("QUALITY_GAP_THRESHOLD", config.Quality.GapThreshold.Seconds())
+		c.positive("QUALITY_LOW_ACCURACY_METERS", config.Quality.LowAccuracyMeters)
+	}
+
+	if config.ZoneTime.Enabled {
+		c.positive("ZONE_TIME_MAX_GAP", config.ZoneTime.MaxGap.Seconds())
+		if !config.Geofence.Enabled {
+			c.failf("ZONE_TIME_ENABLED requires GEOFENCE_ENABLED")
+		}
+	}
+
+	if config.Adherence.Enabled {
+		c.positive("ADHERENCE_ON_ROUTE_METERS", config.Adherence.OnRouteMeters)
+		c.positive("ADHERENCE_STOP_RADIUS_METERS", config.Adherence.StopRadiusMeters)
+		if config.Adherence.EarlyTolerance < 0 || config.Adherence.LateTolerance < 0 {
+			c.failf("ADHERENCE_EARLY_TOLERANCE and ADHERENCE_LATE_TOLERANCE must not be negative, got %v and %v",
+				config.Adherence.EarlyTolerance, config.Adherence.LateTolerance)
+		}
+	}
+
+	if config.Speeding.Enabled {
+		c.positive("SPEEDING_DEFAULT_LIMIT_KMH", config.Speeding.DefaultLimitKmh)
+		if config.Speeding.ToleranceKmh < 0 {
+			c.failf("SPEEDING_TOLERANCE_KMH must not be negative, got %v", config.Speeding.ToleranceKmh)
+		}
+		if config.Speeding.MinDuration < 0 {
+			c.failf("SPEEDING_MIN_DURATION must not be negative, got %v", config.Speeding.MinDuration)
+		}
+		// Roads are only looked up in the grid cells next to a location
+		if config.Speeding.RoadSnapMeters <= 0 || config.Speeding.RoadSnapMeters > 1000 {
+			c.failf("SPEEDING_ROAD_SNAP_METERS must be between 0 and 1000, got %v", config.Speeding.RoadSnapMeters)
+		}
+		c.required("SPEEDING_TOPIC", config.Speeding.EventTopic)
+	}
+
+	if config.Shifts.Enabled {
+		if _, err := time.LoadLocation(config.Shifts.Timezone); err != nil {
+			c.failf("SHIFT_TIMEZONE %q is not a known time zone", config.Shifts.Timezone)
+		}
+		if config.Shifts.MinBreak < 0 {
+			c.failf("SHIFT_MIN_BREAK must not be negative, got %v", config.Shifts.MinBreak)
+		}
+	}
+
+	if config.Odometer.Enabled {
+		c.required("ODOMETER_COLLECTION", config.Odometer.Collection)
+		if _, err := time.LoadLocation(config.Odometer.Timezone); err != nil {
+			c.failf("ODOMETER_TIMEZONE %q is not a known time zone", config.Odometer.Timezone)
+		}
+		c.positive("ODOMETER_MAX_SPEED_KMH", config.Odometer.MaxSpeedKmh)
+		c.positive("ODOMETER_COUNTER_TTL", config.Odometer.CounterTTL.Seconds())
+		c.positive("ODOMETER_ROLLUP_INTERVAL", config.Odometer.RollupInterval.Seconds())
+		if config.Odometer.CounterTTL < 24*time.Hour+config.Odometer.RollupInterval {
+			c.failf("ODOMETER_COUNTER_TTL must outlast a day and a rollup interval, got %v", config.Odometer.CounterTTL)
+		}
+	}
+
+	if config.Heatmap.Enabled {
+		c.required("HEATMAP_COLLECTION", config.Heatmap.Collection)
+		if config.Heatmap.Precision < 1 || config.Heatmap.Precision > 12 {
+			c.failf("HEATMAP_PRECISION must be between 1 and 12, got %d", config.Heatmap.Precision)
+		}
+		if _, err := time.LoadLocation(config.Heatmap.Timezone); err != nil {
+			c.failf("HEATMAP_TIMEZONE %q is not a known time zone", config.Heatmap.Timezone)
+		}
+		c.positive("HEATMAP_INTERVAL", config.Heatmap.Interval.Seconds())
+		c.positive("HEATMAP_LOOKBACK_DAYS", float64(config.Heatmap.LookbackDays))
+	}
+
+	if config.Segmentation.MaxGap < 0 {
+		c.failf("TRIP_SEGMENT_MAX_GAP must not be negative, got %v", config.Segmentation.MaxGap)
+	}
+	if config.Segmentation.MaxJumpKm < 0 {
+		c.failf("TRIP_SEGMENT_MAX_JUMP_KM must not be negative, got %v", config.Segmentation.MaxJumpKm)
+	}
+
+	c.positive("INGEST_WORKERS", float64(config.Ingest.Workers))
+	c.oneOf("INGEST_OVERFLOW", config.Ingest.Overflow, "block", "drop_oldest", "spill")
+	c.positive("FINALIZATION_WORKERS", float64(config.Finalization.Workers))
+
+	if config.RateLimit.Enabled {
+		c.positive("RATE_LIMIT_RATE", config.RateLimit.Rate)
+		c.positive("RATE_LIMIT_BURST", float64(config.RateLimit.Burst))
+		c.oneOf("RATE_LIMIT_POLICY", config.RateLimit.Policy, "throttle", "sample")
+		if config.RateLimit.Policy == "sample" {
+			c.positive("RATE_LIMIT_SAMPLE_EVERY", float64(config.RateLimit.SampleEvery))
+		}
+	}
+
+	// Cluster modes
+	if config.Partition.Enabled && config.Standby.Enabled {
+		c.failf("PARTITION_ENABLED and STANDBY_ENABLED cannot both be true")
+	}
+	if config.Partition.Enabled {
+		if !external {
+			c.failf("PARTITION_ENABLED requires STORAGE_MODE=external")
+		}
+		if config.Partition.MemberTTL <= config.Partition.HeartbeatInterval {
+			c.failf("PARTITION_MEMBER_TTL (%v) must be longer than PARTITION_HEARTBEAT_INTERVAL (%v)", config.Partition.MemberTTL, config.Partition.HeartbeatInterval)
+		}
+		c.positive("PARTITION_VIRTUAL_NODES", float64(config.Partition.VirtualNodes))
+	}
+	if config.RuntimeSettings.Enabled && !external {
+		c.failf("RUNTIME_SETTINGS_ENABLED requires STORAGE_MODE=external")
+	}
+	if config.Standby.Enabled {
+		if !external {
+			c.failf("STANDBY_ENABLED requires STORAGE_MODE=external")
+		}
+		if config.Standby.TakeoverAfter <= config.Standby.HeartbeatInterval {
+			c.failf("STANDBY_TAKEOVER_AFTER (%v) must be longer than STANDBY_HEARTBEAT_INTERVAL (%v)", config.Standby.TakeoverAfter, config.Standby.HeartbeatInterval)
+		}
+	}
+
+	c.positive("RETRY_ATTEMPTS", float64(config.Retry.Attempts))
+	c.fraction("RETRY_JITTER", config.Retry.Jitter)
+	c.fraction("STARTUP_RETRY_JITTER", config.Startup.Redis.Jitter)
+	c.fraction("FAILURE_SAMPLE_RATE", config.Failures.SampleRate)
+
+	c.oneOf("SECRETS_PROVIDER", config.Secrets.Provider, "env", "file", "vault", "aws")
+	switch config.Secrets.Provider {
+	case "file":
+		c.required("SECRETS_DIR", config.Secrets.Dir)
+	case "vault":
+		c.url("VAULT_ADDR", config.Secrets.VaultAddress, "http", "https")
+		c.required("VAULT_TOKEN", config.Secrets.VaultToken)
+		c.required("SECRETS_VAULT_PATH", config.Secrets.VaultPath)
+	case "aws":
+		c.required("AWS_REGION", config.Secrets.AWSRegion)
+		c.required("SECRETS_AWS_SECRET_ID", config.Secrets.AWSSecretID)
+		// Without a static access key, the AWS default credential chain is used
+		if config.Secrets.AWSAccessKeyID != "" {
+			c.required("AWS_SECRET_ACCESS_KEY", config.Secrets.AWSSecretAccessKey)
+		}
+	}
+
+	// Optional sinks
+	if config.Timescale.Enabled {
+		c.url("TIMESCALE_URL", config.Timescale.URL, "postgres", "postgresql")
+	}
+	if config.ClickHouse.Enabled {
+		c.url("CLICKHOUSE_URL", config.ClickHouse.URL, "http", "https")
+	}
+	if config.OpenSearch.Enabled {
+		c.url("OPENSEARCH_URL", config.OpenSearch.URL, "http", "https")
+	}
+	if config.Kafka.Enabled && len(config.Kafka.Brokers) == 0 {
+		c.failf("KAFKA_BROKERS is required with KAFKA_ENABLED")
+	}
+	if config.Archive.Enabled {
+		c.required("ARCHIVE_S3_ENDPOINT", config.Archive.Endpoint)
+		c.required("ARCHIVE_S3_BUCKET", config.Archive.Bucket)
+	}
+	for _, webhook := range config.Webhooks.URLs {
+		c.url("WEBHOOK_URLS", webhook, "http", "https")
+	}
+
+	// APIs
+	if config.HTTP.Enabled && config.GRPC.Enabled && config.HTTP.Address == config.GRPC.Address {
+		c.failf("HTTP_ADDRESS and GRPC_ADDRESS cannot both be %q", config.HTTP.Address)
+	}
+	if config.HTTP.DefaultPageSize > config.HTTP.MaxPageSize {
+		c.failf("HTTP_DEFAULT_PAGE_SIZE (%d) must not exceed HTTP_MAX_PAGE_SIZE (%d)", config.HTTP.DefaultPageSize, config.HTTP.MaxPageSize)
+	}
+	if config.GRPC.DefaultPageSize > config.GRPC.MaxPageSize {
+		c.failf("GRPC_DEFAULT_PAGE_SIZE (%d) must not exceed GRPC_MAX_PAGE_SIZE (%d)", config.GRPC.DefaultPageSize, config.GRPC.MaxPageSize)
+	}
+	if config.Auth.Enabled {
+		c.url("AUTH_JWKS_URL", config.Auth.JWKSURL, "http", "https")
+		c.required("AUTH_ISSUER", config.Auth.Issuer)
+		c.required("AUTH_AUDIENCE", config.Auth.Audience)
+		c.positive("AUTH_JWKS_REFRESH_INTERVAL", config.Auth.JWKSRefresh.Seconds())
+		if config.Auth.ClockSkew < 0 {
+			c.failf("AUTH_CLOCK_SKEW must not be negative, got %v", config.Auth.ClockSkew)
+		}
+	}
+
+	if _, err := ParseFeatureFlags(config.FeatureFlags.Flags); err != nil {
+		c.failf("FEATURE_FLAGS: %v", err)
+	}
+
+	// Observability
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Log.Level)); err != nil {
+		c.failf("LOG_LEVEL must be one of debug, info, warn, error, got %q", config.Log.Level)
+	}
+	c.oneOf("LOG_FORMAT", strings.ToLower(config.Log.Format), "json", "text")
+	c.fraction("TRACING_SAMPLE_RATIO", config.Tracing.SampleRatio)
+	c.fraction("SENTRY_SAMPLE_RATE", config.ErrorReporting.SampleRate)
+
+	if config.Profile == "prod" {
+		config.checkProduction(c)
+	}
+
+	if len(c.problems) > 0 {
+		return c.problems
+	}
+	return nil
+}
+
+// defaultMongoPassword is the password in the default MONGODB_URI, meant for
+// the local docker-compose setup only
+const defaultMongoPassword = "examplepassword"
+
+// checkProduction rejects settings that are unsafe in production: plain
+// connections, missing credentials, and the default MongoDB password.
+// Credentials are only required when read from the environment, since other
+// secrets providers supply them after validation.
+func (config Config) checkProduction(c *configCheck) {
+	envSecrets := config.Secrets.Provider == "" || config.Secrets.Provider == "env"
+
+	if !config.MQTT.TLS {
+		c.failf("MQTT_TLS must be true with PROFILE=prod")
+	}
+	if envSecrets && (config.MQTT.Username == "" || config.MQTT.Password == "") {
+		c.failf("MQTT_USERNAME and MQTT_PASSWORD are required with PROFILE=prod")
+	}
+
+	if config.Storage.Mode == "external" {
+		if !config.Redis.TLS {
+			c.failf("REDIS_TLS must be true with PROFILE=prod")
+		}
+		if envSecrets && config.Redis.Password == "" {
+			c.failf("REDIS_PASSWORD is required with PROFILE=prod")
+		}
+
+		if uri, err := url.Parse(config.MongoDB.URI); err == nil {
+			if !mongoTLS(uri) {
+				c.failf("MONGODB_URI must enable TLS with PROFILE=prod, with tls=true or a mongodb+srv URL")
+			}
+			password, _ := uri.User.Password()
+			if config.MongoDB.Password != "" {
+				password = config.MongoDB.Password
+			}
+			if password == defaultMongoPassword {
+				c.failf("MONGODB_URI must not use the default password with PROFILE=prod")
+			}
+			if envSecrets && uri.User == nil && config.MongoDB.Username == "" {
+				c.failf("MONGODB_URI or MONGODB_USERNAME must set credentials with PROFILE=prod")
+			}
+		}
+	}
+
+	if (config.HTTP.Enabled || config.GRPC.Enabled) && !config.Auth.Enabled {
+		c.failf("AUTH_ENABLED must be true with PROFILE=prod")
+	}
+	if config.Auth.Enabled && strings.HasPrefix(config.Auth.JWKSURL, "http:") {
+		c.failf("AUTH_JWKS_URL must use https with PROFILE=prod")
+	}
+	if config.Archive.Enabled && !config.Archive.UseSSL {
+		c.failf("ARCHIVE_S3_USE_SSL must be true with PROFILE=prod")
+	}
+}
+
+// mongoTLS reports whether a MongoDB URI connects over TLS, which SRV URIs
+// do unless it is turned off
+func mongoTLS(uri *url.URL) bool {
+	query := uri.Query()
+	for _, option := range []string{"tls", "ssl"} {
+		if value := query.Get(option); value != "" {
+			return value == "true"
+		}
+	}
+	return uri.Scheme == "mongodb+srv"
+}
@@ -0,0 +1,319 @@
+// Package webhook delivers service events to HTTP endpoints. Deliveries are
+// signed with HMAC-SHA256, retried with exponential backoff, and stored as
+// dead letters when every attempt fails.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"data-ingestion-microservice/database"
+	"data-ingestion-microservice/metrics"
+	"data-ingestion-microservice/types"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// deadLetterTimeout bounds storing a failed delivery
+const deadLetterTimeout = 5 * time.Second
+
+// Dispatcher queues events and delivers them to every configured endpoint
+// from a pool of workers, so dispatching never blocks message processing
+type Dispatcher struct {
+	config      types.WebhookConfig
+	events      map[string]bool
+	client      *http.Client
+	deadLetters database.WebhookDeadLetters
+
+	mu       sync.RWMutex
+	closed   bool
+	queue    chan delivery
+	overflow chan delivery
+
+	stop    context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// delivery is one event bound for one endpoint
+type delivery struct {
+	url     string
+	event   types.WebhookEvent
+	payload []byte
+}
+
+// Subject identifies the occurrence an event reports: the location message
+// of a driver on a route that raised it, and for events a single location
+// can raise several of, the geofence, stop, or trip it is about
+type Subject struct {
+	DriverID  string
+	RouteID   string
+	Timestamp uint64
+	Key       string
+}
+
+// NewDispatcher starts a dispatcher for the configured endpoints and events.
+// Deliveries that fail every attempt are saved to deadLetters, or only
+// logged when it is nil.
+func NewDispatcher(config types.WebhookConfig, deadLetters database.WebhookDeadLetters) *Dispatcher {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+
+	stop, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		config:      config,
+		events:      make(map[string]bool),
+		client:      &http.Client{Timeout: config.Timeout},
+		deadLetters: deadLetters,
+		queue:       make(chan delivery, config.QueueSize),
+		overflow:    make(chan delivery, config.QueueSize),
+		stop:        stop,
+		cancel:      cancel,
+	}
+	for _, event := range config.Events {
+		d.events[event] = true
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		d.workers.Add(1)
+		go d.run()
+	}
+	d.workers.Add(1)
+	go d.runOverflow()
+	return d
+}
+
+// Dispatch queues an event for every endpoint if its type is enabled. When
+// the queue is full the event is handed to a background dead-letter writer
+// instead of blocking, and only logged when that falls behind too.
+func (d *Dispatcher) Dispatch(eventType string, subject Subject, data interface{}) {
+	if !d.events[eventType] {
+		return
+	}
+
+	event := types.WebhookEvent{
+		ID:        EventID(eventType, subject),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error marshaling webhook event", "event", eventType, "error", err)
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	for _, url := range d.config.URLs {
+		next := delivery{url: url, event: event, payload: payload}
+		select {
+		case d.queue <- next:
+			metrics.WebhookQueueDepth.Add(1)
+		default:
+			select {
+			case d.overflow <- next:
+			default:
+				metrics.WebhookFailed.Add(1)
+				slog.Error("Dropped webhook delivery", "event", eventType, "eventId", event.ID, "url", url, "error", "webhook queue and dead letter backlog are full")
+			}
+		}
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries. When ctx
+// is done first, pending retries are abandoned and dead-lettered.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+		close(d.overflow)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// run delivers queued events until the queue is closed
+func (d *Dispatcher) run() {
+	defer d.workers.Done()
+	for next := range d.queue {
+		metrics.WebhookQueueDepth.Add(-1)
+		d.deliver(next)
+	}
+}
+
+// runOverflow dead-letters the deliveries that found the queue full, until
+// the dispatcher is closed
+func (d *Dispatcher) runOverflow() {
+	defer d.workers.Done()
+	for next := range d.overflow {
+		d.deadLetter(next, 0, fmt.Errorf("webhook queue is full"))
+	}
+}
+
+// deliver sends one delivery, retrying transient failures with exponential
+// backoff
+func (d *Dispatcher) deliver(next delivery) {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		var retry bool
+		retry, err = d.send(next)
+		if err == nil {
+			metrics.WebhookDelivered.Add(1)
+			return
+		}
+		if !retry || attempt >= d.config.MaxAttempts {
+			break
+		}
+
+		metrics.WebhookRetries.Add(1)
+		select {
+		case <-time.After(d.backoff(attempt)):
+		case <-d.stop.Done():
+			d.deadLetter(next, attempt, fmt.Errorf("shutting down after: %w", err))
+			return
+		}
+	}
+	d.deadLetter(next, attempt, err)
+}
+
+// send makes one delivery attempt and reports whether a failure is worth
+// retrying: network errors, 408, 429, and 5xx responses are
+func (d *Dispatcher) send(next delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(d.stop, http.MethodPost, next.url, bytes.NewReader(next.payload))
+	if err != nil {
+		return false, fmt.Errorf("invalid webhook request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "data-ingestion-microservice-webhooks")
+	req.Header.Set(HeaderEvent, next.event.Type)
+	req.Header.Set(HeaderID, next.event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if d.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.config.Secret, timestamp, next.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("endpoint responded %s", resp.Status)
+	default:
+		return false, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+}
+
+// backoff returns the delay after a failed attempt: the initial backoff
+// doubled per attempt, capped at the maximum
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.config.InitialBackoff
+	for i := 1; i < attempt && (d.config.MaxBackoff <= 0 || delay < d.config.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if d.config.MaxBackoff > 0 && delay > d.config.MaxBackoff {
+		delay = d.config.MaxBackoff
+	}
+	return delay
+}
+
+// deadLetter records a delivery that will not be retried
+func (d *Dispatcher) deadLetter(next delivery, attempts int, err error) {
+	metrics.WebhookFailed.Add(1)
+	slog.Error("Webhook delivery failed",
+		"event", next.event.Type,
+		"eventId", next.event.ID,
+		"url", next.url,
+		"attempts", attempts,
+		"error", err,
+	)
+	if d.deadLetters == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	letter := types.WebhookDeadLetter{
+		Event:     next.event,
+		URL:       next.url,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now().UTC(),
+	}
+	if err := d.deadLetters.SaveDeadLetter(ctx, letter); err != nil {
+		slog.Error("Error storing webhook dead letter", "eventId", next.event.ID, "error", err)
+	}
+}
+
+// Sign returns the signature header value of a payload: the hex HMAC-SHA256
+// of "{timestamp}.{payload}" keyed with the secret, prefixed with "sha256="
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// EventID derives the ID of an event from its type and subject, so the
+// event raised again for a redelivered or replayed location message gets
+// the same ID and receivers can drop it as a duplicate
+func EventID(eventType string, subject Subject) string {
+	h := sha256.New()
+	for _, part := range []string{eventType, subject.DriverID, subject.RouteID, strconv.FormatUint(subject.Timestamp, 10), subject.Key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,225 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-ingestion-microservice/types"
+)
+
+// recordingDLQ keeps dead letters in memory
+type recordingDLQ struct {
+	mu      sync.Mutex
+	letters []types.WebhookDeadLetter
+}
+
+func (r *recordingDLQ) SaveDeadLetter(ctx context.Context, letter types.WebhookDeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.letters = append(r.letters, letter)
+	return nil
+}
+
+// testConfig returns a config delivering every event to url without delays
+func testConfig(url string) types.WebhookConfig {
+	return types.WebhookConfig{
+		URLs:           []string{url},
+		Secret:         "secret",
+		Events:         []string{"trip_finished"},
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Workers:        1,
+		QueueSize:      10,
+	}
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(testConfig(server.URL), nil)
+	subject := Subject{DriverID: "driver_001", RouteID: "route_1", Timestamp: 1700000000000, Key: "trip_1"}
+	d.Dispatch("route_deviation", subject, map[string]string{"driverId": "driver_001"})
+	d.Dispatch("trip_finished", subject, map[string]string{"tripId": "trip_1"})
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("Expected only the enabled event to be delivered, got %d deliveries", len(received))
+	}
+	req, body := <-received, <-bodies
+
+	want := Sign("secret", req.Header.Get(HeaderTimestamp), body)
+	if got := req.Header.Get(HeaderSignature); got != want {
+		t.Errorf("Expected signature %s, got %s", want, got)
+	}
+	if req.Header.Get(HeaderEvent) != "trip_finished" {
+		t.Errorf("Expected event header trip_finished, got %s", req.Header.Get(HeaderEvent))
+	}
+
+	var event types.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("Failed to decode delivery: %v", err)
+	}
+	if event.ID != EventID("trip_finished", subject) || event.ID != req.Header.Get(HeaderID) || event.Type != "trip_finished" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
+
+func TestDispatcherRetriesTransientFailures(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dlq := &recordingDLQ{}
+	d := NewDispatcher(testConfig(server.URL), dlq)
+	d.Dispatch("trip_finished", Subject{}, nil)
+	d.Close(context.Background())
+
+	if attempts.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts.Load())
+	}
+	if len(dlq.letters) != 0 {
+		t.Errorf("Expected no dead letters, got %+v", dlq.letters)
+	}
+}
+
+func TestDispatcherDeadLetters(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+	}{
+		{"after the last retry", http.StatusInternalServerError, 3},
+		{"without retrying client errors", http.StatusBadRequest, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			dlq := &recordingDLQ{}
+			d := NewDispatcher(testConfig(server.URL), dlq)
+			d.Dispatch("trip_finished", Subject{}, nil)
+			d.Close(context.Background())
+
+			if int(attempts.Load()) != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, attempts.Load())
+			}
+			if len(dlq.letters) != 1 {
+				t.Fatalf("Expected 1 dead letter, got %d", len(dlq.letters))
+			}
+			letter := dlq.letters[0]
+			if letter.URL != server.URL || letter.Attempts != tt.attempts || letter.Event.Type != "trip_finished" {
+				t.Errorf("Unexpected dead letter %+v", letter)
+			}
+		})
+	}
+}
+
+func TestDispatcherDeadLettersWhenQueueIsFull(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+
+	dlq := &slowDLQ{saved: make(chan types.WebhookDeadLetter, 10), release: make(chan struct{})}
+	config := testConfig(server.URL)
+	config.QueueSize = 1
+	d := NewDispatcher(config, dlq)
+
+	// The first event is being delivered, the second fills the queue, and
+	// the rest must not wait for the dead letter store
+	d.Dispatch("trip_finished", Subject{DriverID: "driver_001", Timestamp: 0}, nil)
+	time.Sleep(50 * time.Millisecond)
+	dispatched := make(chan struct{})
+	go func() {
+		for i := uint64(1); i < 4; i++ {
+			d.Dispatch("trip_finished", Subject{DriverID: "driver_001", Timestamp: i}, nil)
+		}
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("Expected dispatching to return without storing dead letters")
+	}
+
+	close(dlq.release)
+	close(block)
+	d.Close(context.Background())
+
+	if len(dlq.saved) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %d", len(dlq.saved))
+	}
+	if letter := <-dlq.saved; letter.Attempts != 0 || letter.LastError != "webhook queue is full" {
+		t.Errorf("Unexpected dead letter %+v", letter)
+	}
+}
+
+// slowDLQ stores dead letters only once released
+type slowDLQ struct {
+	saved   chan types.WebhookDeadLetter
+	release chan struct{}
+}
+
+func (s *slowDLQ) SaveDeadLetter(ctx context.Context, letter types.WebhookDeadLetter) error {
+	<-s.release
+	s.saved <- letter
+	return nil
+}
+
+func TestEventID(t *testing.T) {
+	subject := Subject{DriverID: "driver_001", RouteID: "route_1", Timestamp: 1700000000000}
+	id := EventID("route_deviation", subject)
+	if len(id) != 32 || id != EventID("route_deviation", subject) {
+		t.Errorf("Expected a stable 32 character ID, got %q", id)
+	}
+
+	for _, other := range []string{
+		EventID("speeding", subject),
+		EventID("route_deviation", Subject{DriverID: "driver_002", RouteID: "route_1", Timestamp: 1700000000000}),
+		EventID("route_deviation", Subject{DriverID: "driver_001", RouteID: "route_2", Timestamp: 1700000000000}),
+		EventID("route_deviation", Subject{DriverID: "driver_001", RouteID: "route_1", Timestamp: 1700000000001}),
+		EventID("route_deviation", Subject{DriverID: "driver_001", RouteID: "route_1", Timestamp: 1700000000000, Key: "geofence_1"}),
+	} {
+		if other == id {
+			t.Errorf("Expected a different ID for a different subject, got %s", id)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{config: types.WebhookConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := d.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}